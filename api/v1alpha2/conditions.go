@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -41,6 +42,14 @@ const (
 	//   - Progressing
 	//   - Failed
 	//   - Ready: config successfully applied and ready
+	//   - Queued: another run of the same NodeObservability is active
+	//   - Waiting: the CRI-O profiling is not enabled on all the nodes yet
+	//   - ReferenceNotFound: the referenced NodeObservability does not exist
+	//   - DryRun: the machine config changes are previewed without being applied
+	//   - MachineConfigPoolNotFound: the worker or the named MachineConfigPool does not exist
+	//   - MachineConfigPoolMismatch: the named MachineConfigPool does not select all the targeted nodes
+	//   - Forbidden: the operator is not allowed to manage the service account or the RBAC of the agents
+	//   - CABundleNotFound: the config map with the service CA bundle referenced by the run does not exist
 	DebugReady string = "Ready"
 
 	// DebugFinished is the condition type used to inform state of running debug
@@ -52,7 +61,182 @@ const (
 	//   - Progressing
 	//   - Failed
 	//   - Finished
+	//   - Rejected: another run of the same NodeObservability was active
+	//   - ReferenceNotFound: the referenced NodeObservability was not created in time
+	//   - Interrupted: the operator shut down during the run, which is resumed once it restarts
+	//   - Cancelled: the run was cancelled with its spec
 	DebugFinished string = "Finished"
+
+	// MachineConfigCleanup is the condition type used to inform state of the removal
+	// of the machine config changes when NodeObservability is deleted
+	//   Status:
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - Failed: cleanup did not complete in time
+	MachineConfigCleanup string = "MachineConfigCleanup"
+
+	// MachineConfigPoolUpdating is the condition type used to inform that the
+	// machines of the profiling MachineConfigPool are being updated
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - Failed
+	//   - Ready
+	//   - DryRun
+	MachineConfigPoolUpdating string = "MachineConfigPoolUpdating"
+
+	// MachineConfigPoolReady is the condition type used to inform that all the
+	// machines of the profiling MachineConfigPool are updated
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - Failed: the pool is degraded
+	//   - Ready
+	//   - DryRun: the machine config changes are previewed without being applied
+	//   - MachineConfigPoolNotFound: the worker or the named MachineConfigPool does not exist
+	//   - MachineConfigPoolMismatch: the named MachineConfigPool does not select all the targeted nodes
+	MachineConfigPoolReady string = "MachineConfigPoolReady"
+
+	// MachineConfigDegraded is the condition type used to inform that a profiling
+	// MachineConfigPool is degraded by the MachineConfig of the operator while rolling it out,
+	// i.e. the MCO mentions the MachineConfig or its files, the message carries the reason and the message of the MCO
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - RenderDegraded: the rendered configuration of the pool cannot be generated, e.g. conflicting MachineConfigs
+	//   - NodeDegraded: the machines failed to apply the rendered configuration
+	//   - AsExpected
+	MachineConfigDegraded string = "MachineConfigDegraded"
+
+	// MachineConfigPoolPaused is the condition type used to inform that the
+	// profiling MachineConfigPool is paused by the Paused rollout strategy
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Paused
+	//   - Unpaused
+	MachineConfigPoolPaused string = "MachineConfigPoolPaused"
+
+	// DaemonSetRolledOut is the condition type used to inform that the agent
+	// pods of the current DaemonSet spec are available on all the nodes
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - Ready
+	DaemonSetRolledOut string = "DaemonSetRolledOut"
+
+	// AgentReady is the condition type used to inform that the agent
+	// pods are ready on all the nodes on which the DaemonSet schedules them
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - Ready
+	//   - Failed: some agent pods are crash looping
+	AgentReady string = "AgentReady"
+
+	// PriorityClassAvailable is the condition type used to inform that the
+	// priority class of the agent pods exists
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - Invalid: the priority class does not exist
+	PriorityClassAvailable string = "PriorityClassAvailable"
+
+	// ImagePullSecretsAvailable is the condition type used to inform that the
+	// pull secrets of the agent image exist in the operator namespace
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - Invalid: some of the pull secrets do not exist
+	ImagePullSecretsAvailable string = "ImagePullSecretsAvailable"
+
+	// ServingCertAvailable is the condition type used to inform that the
+	// serving certificate secret of the agent service exists
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - Disabled: the injection of the serving certificate is disabled
+	//   - Progressing: waiting for the service CA operator to generate the secret
+	//   - ServingCertNotInjected: the secret was not generated in time
+	ServingCertAvailable string = "ServingCertAvailable"
+
+	// NodesMatched is the condition type used to inform that the node selector
+	// of NodeObservability matches some of the current nodes
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - NoMatchingNodes: no node matches the node selector, no agent is deployed
+	NodesMatched string = "NodesMatched"
+
+	// Scheduled is the condition type used to inform that the profiling runs
+	// are created on the schedule of NodeObservability
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - Disabled: no schedule is set
+	//   - Invalid: the schedule cannot be parsed
+	Scheduled string = "Scheduled"
+
+	// Available is the condition type used to inform that the agents
+	// and the machine config changes are ready for the profiling runs,
+	// rolled up from the conditions of the components
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Ready
+	//   - Progressing
+	//   - Invalid
+	//   - Failed
+	Available string = "Available"
+
+	// Progressing is the condition type used to inform that the agent pods
+	// or the machine config changes are being rolled out
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Progressing
+	//   - AsExpected
+	Progressing string = "Progressing"
+
+	// Degraded is the condition type used to inform that the agents
+	// or the machine config changes cannot be rolled out without an intervention
+	//   Status:
+	//   - True
+	//   - False
+	//   Reason:
+	//   - Invalid: the spec or the resources it references are invalid
+	//   - Forbidden: the operator is not allowed to manage the service account or the RBAC of the agents
+	//   - RenderDegraded, NodeDegraded: the MachineConfig of the operator is rejected by the MCO
+	//   - Failed: the machines failed to be updated, some agent pods are crash looping or the reconcile failed
+	//   - MachineConfigPoolNotFound: the worker or the named MachineConfigPool does not exist
+	//   - MachineConfigPoolMismatch: the named MachineConfigPool does not select all the targeted nodes
+	//   - ServingCertNotInjected: the serving certificate secret was not generated in time
+	//   - NoMatchingNodes: no node matches the node selector
+	//   - AsExpected
+	Degraded string = "Degraded"
 )
 
 const (
@@ -69,6 +253,44 @@ const (
 	ReasonInProgress string = "Progressing"
 
 	ReasonInvalid string = "Invalid"
+
+	ReasonPaused string = "Paused"
+
+	ReasonUnpaused string = "Unpaused"
+
+	ReasonQueued string = "Queued"
+
+	ReasonWaiting string = "Waiting"
+
+	ReasonRejected string = "Rejected"
+
+	ReasonReferenceNotFound string = "ReferenceNotFound"
+
+	ReasonDryRun string = "DryRun"
+
+	ReasonMachineConfigPoolNotFound string = "MachineConfigPoolNotFound"
+
+	ReasonMachineConfigPoolMismatch string = "MachineConfigPoolMismatch"
+
+	ReasonServingCertNotInjected string = "ServingCertNotInjected"
+
+	ReasonForbidden string = "Forbidden"
+
+	ReasonCABundleNotFound string = "CABundleNotFound"
+
+	ReasonInterrupted string = "Interrupted"
+
+	ReasonArtifactsExpired string = "ArtifactsExpired"
+
+	ReasonCancelled string = "Cancelled"
+
+	ReasonAsExpected string = "AsExpected"
+
+	ReasonNoMatchingNodes string = "NoMatchingNodes"
+
+	ReasonRenderDegraded string = "RenderDegraded"
+
+	ReasonNodeDegraded string = "NodeDegraded"
 )
 
 type ConditionalStatus struct {
@@ -103,5 +325,23 @@ func (c *ConditionalStatus) SetCondition(t string, cs metav1.ConditionStatus, re
 		condition.Message = msg
 		return true
 	}
+	if condition.Message != msg {
+		condition.Message = msg
+		return true
+	}
 	return false
 }
+
+// SetStatusCondition sets the given standard condition, its last transition time
+// is only changed with its status. Returns true if the condition changed.
+func (c *ConditionalStatus) SetStatusCondition(cond metav1.Condition) bool {
+	if current := c.GetCondition(cond.Type); current != nil &&
+		current.Status == cond.Status &&
+		current.Reason == cond.Reason &&
+		current.Message == cond.Message &&
+		current.ObservedGeneration == cond.ObservedGeneration {
+		return false
+	}
+	meta.SetStatusCondition(&c.Conditions, cond)
+	return true
+}
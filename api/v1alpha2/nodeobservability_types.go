@@ -17,16 +17,52 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// +kubebuilder:validation:Enum=crio-kubelet;
+// +kubebuilder:validation:Enum=crio-kubelet;kubelet;etcd
 type NodeObservabilityType string
 
 const (
 	CrioKubeletNodeObservabilityType NodeObservabilityType = "crio-kubelet"
+	KubeletNodeObservabilityType     NodeObservabilityType = "kubelet"
+	EtcdNodeObservabilityType        NodeObservabilityType = "etcd"
+)
+
+// +kubebuilder:validation:Enum=debug;info;warn;error
+type AgentLogLevel string
+
+const (
+	DebugAgentLogLevel AgentLogLevel = "debug"
+	InfoAgentLogLevel  AgentLogLevel = "info"
+	WarnAgentLogLevel  AgentLogLevel = "warn"
+	ErrorAgentLogLevel AgentLogLevel = "error"
 )
 
+// ForceReconcileAnnotation requests a full reconcile of NodeObservability
+// and of its machine config changes when its value changes, e.g. to a timestamp.
+const ForceReconcileAnnotation = "nodeobservability.openshift.io/force-reconcile"
+
+// IncludeMasterNodesConfirmationAnnotation confirms, when set to "true", that the master nodes
+// are meant to be profiled with IncludeMasterNodes as the control plane nodes may be rebooted.
+const IncludeMasterNodesConfirmationAnnotation = "nodeobservability.openshift.io/confirm-include-master-nodes"
+
+// ForceDeleteAnnotation allows, when set to "true", the deletion of NodeObservability
+// while some of its NodeObservabilityRuns are not finished.
+const ForceDeleteAnnotation = "nodeobservability.openshift.io/force-delete"
+
+// ServiceDryRunAnnotation holds, when set to "true", the changes of the existing agent service:
+// they are reported in the PendingServiceChanges status instead of being applied.
+const ServiceDryRunAnnotation = "nodeobservability.openshift.io/service-dry-run"
+
+// ExcludeNodeLabel excludes, when set to "true" on a node, the node from the profiling:
+// the agent is not deployed on it and the CRI-O profiling is not enabled on it.
+const ExcludeNodeLabel = "nodeobservability.openshift.io/exclude"
+
 // NodeObservabilitySpec defines the desired state of NodeObservability
 type NodeObservabilitySpec struct {
 	// +kubebuilder:validation:Required
@@ -36,7 +72,312 @@ type NodeObservabilitySpec struct {
 	// Type defines the type of profiling queries, which will be enabled
 	// The following types are supported:
 	//   * crio-kubelet - 30s of /pprof data, requesting this type might cause node restart
+	//   * kubelet - 30s of kubelet /pprof data only, the CRI-O profiling is not enabled
+	//   * etcd - 30s of kubelet and etcd /pprof data of the master nodes, requires IncludeMasterNodes,
+	//     the etcd client certificates of the nodes are mounted in the agent pods
+	//     and the nodes are not restarted
 	Type NodeObservabilityType `json:"type"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	// Port is the port on which the agent service is exposed.
+	// Defaults to 8443 when not set.
+	Port *int32 `json:"port,omitempty"`
+	// +optional
+	// EnableMonitoring enables the creation of a ServiceMonitor
+	// which allows Prometheus to scrape the agents.
+	// Requires the Prometheus Operator CRDs to be installed in the cluster.
+	EnableMonitoring bool `json:"enableMonitoring,omitempty"`
+	// +optional
+	// ServingCertSecretName is the name of the secret in which the serving certificate
+	// of the agent service is generated. Defaults to the name of the agent service.
+	ServingCertSecretName string `json:"servingCertSecretName,omitempty"`
+	// +optional
+	// DisableServingCertInjection stops requesting the serving certificate
+	// of the agent service from the service CA operator.
+	// The secret named ServingCertSecretName must then be provided,
+	// the secret previously generated by the service CA operator is deleted.
+	DisableServingCertInjection bool `json:"disableServingCertInjection,omitempty"`
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	// +optional
+	// IPFamilyPolicy is the IP family policy of the agent service.
+	// Defaults to PreferDualStack when not set.
+	IPFamilyPolicy *corev1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
+	// +optional
+	// PublishNotReadyAddresses publishes the addresses of the agent pods
+	// in the agent service before they are ready, which allows them
+	// to be resolved through DNS during their rollout.
+	// Defaults to true when not set.
+	PublishNotReadyAddresses *bool `json:"publishNotReadyAddresses,omitempty"`
+	// +optional
+	// MachineConfigRolloutStrategy defines how the machine config changes
+	// required by the profiling are rolled out on the nodes.
+	// Defaults to Immediate.
+	MachineConfigRolloutStrategy MachineConfigRolloutStrategy `json:"machineConfigRolloutStrategy,omitempty"`
+	// +optional
+	// MachineConfigRolloutPauseDuration is the time the MachineConfigPool stays paused
+	// with the Paused rollout strategy. Defaults to 1h.
+	MachineConfigRolloutPauseDuration *metav1.Duration `json:"machineConfigRolloutPauseDuration,omitempty"`
+	// +optional
+	// MachineConfigDryRun previews the machine config changes required by the profiling
+	// without applying them: the MachineConfigs which would be created and the number
+	// of machines which would be rebooted are reported in the status of the
+	// NodeObservabilityMachineConfig. Unsetting it applies the changes.
+	MachineConfigDryRun bool `json:"machineConfigDryRun,omitempty"`
+	// +optional
+	// Resources are the compute resource requirements of the agent container.
+	// The limits cannot be lower than the requests.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// NodePools splits the observed nodes into pools whose machine config changes
+	// are rolled out by distinct MachineConfigPools, named nodeobservability-<name>.
+	// The agents are deployed on the nodes of all the pools.
+	// A node must not be selected by more than one pool.
+	NodePools []NodePool `json:"nodePools,omitempty"`
+	// +optional
+	// MachineConfigPoolName is the name of an existing MachineConfigPool which rolls out
+	// the machine config changes required by the profiling, instead of the MachineConfigPools
+	// created by the operator for the observed nodes. The MachineConfigPool must select
+	// all the observed nodes except the master ones, they are not relabeled.
+	// Cannot be set with nodePools.
+	MachineConfigPoolName string `json:"machineConfigPoolName,omitempty"`
+	// +optional
+	// Affinity defines the scheduling constraints of the agent pods.
+	// The required node affinity also restricts the nodes
+	// on which the machine config changes are applied.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// +optional
+	// Tolerations allow the agent pods to be scheduled on the tainted nodes.
+	// A toleration with an empty key and the Exists operator tolerates all the taints.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// +optional
+	// IncludeMasterNodes allows the master nodes selected by the NodeSelector to be profiled:
+	// the agent pods tolerate the master taints and, with the crio-kubelet type,
+	// the CRI-O profiling is rolled out by the master MachineConfigPool, rebooting the control plane nodes.
+	// Requires the nodeobservability.openshift.io/confirm-include-master-nodes annotation set to "true".
+	// Defaults to false.
+	IncludeMasterNodes bool `json:"includeMasterNodes,omitempty"`
+	// +optional
+	// PriorityClassName is the name of the priority class of the agent pods.
+	// The priority class must exist. Defaults to the operator's default agent priority class if any.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// +optional
+	// AgentImage is the image of the agent container,
+	// e.g. a mirror of the default image in a disconnected environment.
+	// Defaults to the operator's built-in agent image,
+	// the default is recorded in the spec at admission.
+	AgentImage string `json:"agentImage,omitempty"`
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	// ImagePullPolicy is the pull policy of the agent image.
+	// Defaults to IfNotPresent.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// +optional
+	// ImagePullSecrets are the secrets used to pull the agent image
+	// and the image of the collector pods of the runs.
+	// The secrets must exist in the operator namespace,
+	// and in the namespace of the runs for the collector pods.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// +optional
+	// Labels are added to the resources managed by the operator for the agents:
+	// the service, the daemonset and its pods, and the serving certificate secret.
+	// The labels used by the operator to select the agents are never overridden.
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	// PodAnnotations are added to the agent pods, e.g. the annotations of a service mesh
+	// or of a pod security exemption. The annotations of the nodeobservability.olm.openshift.io
+	// domain are reserved for the operator. Changing them restarts the agent pods.
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	// +optional
+	// ReadinessProbe tunes the readiness probe of the agent containers,
+	// the agents are probed on the /healthz endpoint of the agent port.
+	ReadinessProbe *AgentProbe `json:"readinessProbe,omitempty"`
+	// +optional
+	// LivenessProbe tunes the liveness probe of the agent containers,
+	// the agents failing it are restarted.
+	LivenessProbe *AgentProbe `json:"livenessProbe,omitempty"`
+	// +optional
+	// UpdateStrategy is the strategy replacing the agent pods when their template changes,
+	// e.g. a RollingUpdate with a lower maxUnavailable to keep the profiling coverage on large clusters.
+	// The maxUnavailable and maxSurge of the rolling update are either a number or a percentage
+	// of the agent pods, only one of them can be non-zero.
+	// Defaults to a RollingUpdate replacing one agent pod at a time.
+	UpdateStrategy *appsv1.DaemonSetUpdateStrategy `json:"updateStrategy,omitempty"`
+	// +optional
+	// PodSecurityContext is the security context of the agent pods.
+	// Defaults to the RuntimeDefault seccomp profile when no seccomp profile is set.
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// +optional
+	// SecurityContext is the security context of the agent container.
+	// The agent container is privileged unless set otherwise:
+	// the privileged mode is required to profile CRI-O through its host socket
+	// and can only be dropped with the kubelet type.
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// +optional
+	// LogLevel is the log level of the agents, one of debug, info, warn or error,
+	// passed to the agent container in the AGENT_LOG_LEVEL environment variable.
+	// Changing it restarts the agent pods. Defaults to the agent's own log level.
+	LogLevel AgentLogLevel `json:"logLevel,omitempty"`
+	// +optional
+	// Env is the list of the extra environment variables of the agent container,
+	// e.g. to toggle the experimental features of the agent image.
+	// The variables set by the operator (NODE_IP, AGENT_LOG_LEVEL, ETCD_CERTS_DIR) cannot be overridden.
+	// Changing it restarts the agent pods.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// +optional
+	// CrioSocketPath is the absolute path of the CRI-O unix socket on the nodes,
+	// for the OpenShift versions and the custom builds where it's not the standard one.
+	// The socket is mounted at the standard path in the agent container.
+	// Defaults to /var/run/crio/crio.sock.
+	CrioSocketPath string `json:"crioSocketPath,omitempty"`
+	// +optional
+	// ProfilesHostPath is the absolute path of the directory on the nodes
+	// in which the agents write the profiles, e.g. on a filesystem larger than the root one.
+	// The directory is created if it doesn't exist. Changing it restarts the agent pods.
+	// Defaults to the filesystem of the agent container.
+	ProfilesHostPath string `json:"profilesHostPath,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	// TerminationGracePeriodSeconds is the number of seconds the agent pods are given
+	// to finish the profiling in progress, e.g. when the nodes are drained.
+	// Defaults to 330, the longest profile duration and a margin to write the profiles.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// +optional
+	// HostNetwork runs the agent pods in the network namespace of the nodes,
+	// for the clusters where the pod network can't reliably reach the kubelet and CRI-O.
+	// The agents are then exposed on the port of the nodes, which must be free on all of them,
+	// the default 8443 port can't be used. Changing it restarts the agent pods.
+	// Defaults to false.
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+	// +kubebuilder:validation:Enum=ClusterFirst;ClusterFirstWithHostNet;Default;None
+	// +optional
+	// DNSPolicy is the DNS policy of the agent pods, e.g. to resolve the names
+	// with the nameservers of the nodes on the clusters with a custom DNS.
+	// The None policy requires DNSConfig. Defaults to ClusterFirst,
+	// ClusterFirstWithHostNet with HostNetwork.
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+	// +optional
+	// DNSConfig is the DNS configuration of the agent pods,
+	// merged into the one generated from DNSPolicy.
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+	// +optional
+	// Schedule is the cron schedule, in UTC, on which NodeObservabilityRuns are created,
+	// e.g. "0 2 * * *" for a nightly run. The standard 5 fields syntax
+	// and the @yearly, @monthly, @weekly, @daily and @hourly macros are supported.
+	// No run is created when not set.
+	Schedule string `json:"schedule,omitempty"`
+	// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+	// +optional
+	// ConcurrencyPolicy specifies how to treat the scheduled runs
+	// when the previous scheduled run hasn't finished yet:
+	//   * Allow - the scheduled runs are created regardless,
+	//     they are started according to their own concurrencyPolicy
+	//   * Forbid - the new scheduled run is skipped
+	//   * Replace - the unfinished scheduled runs are deleted before the new one is created
+	// Defaults to Allow.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	// SuccessfulRunsHistoryLimit is the number of successfully finished scheduled runs to keep,
+	// the older ones are deleted. Defaults to 3.
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	// RunHistoryLimit is the number of finished runs referenced in the status,
+	// the runs which finished first are dropped from it. Defaults to 10.
+	RunHistoryLimit *int32 `json:"runHistoryLimit,omitempty"`
+	// +optional
+	// PruneRuns deletes the runs dropped from the run references of the status
+	// once the history limit is reached, the runs are kept otherwise.
+	PruneRuns bool `json:"pruneRuns,omitempty"`
+}
+
+// AgentProbe tunes a probe of the agent containers,
+// the fields which are not set fall back to the defaults of the probe.
+type AgentProbe struct {
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	// InitialDelaySeconds is the number of seconds after the start of the container
+	// before the probe is initiated. Defaults to 5 for readiness and 15 for liveness.
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	// TimeoutSeconds is the number of seconds after which the probe times out.
+	// Defaults to 5.
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	// PeriodSeconds is how often, in seconds, the probe is performed.
+	// Defaults to 10 for readiness and 20 for liveness.
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	// FailureThreshold is the number of consecutive failures
+	// after which the probe is considered failed. Defaults to 3.
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+// ConcurrencyPolicy describes how the concurrent scheduled runs are handled.
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows the scheduled runs to run concurrently
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the new scheduled run if the previous one hasn't finished yet
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent deletes the unfinished scheduled runs before creating the new one
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// NodePool is a subset of the observed nodes
+type NodePool struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// Name of the pool, used in the names of its MachineConfigPool and MachineConfig
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinProperties=1
+	// NodeSelector selects the nodes of the pool among the observed nodes
+	NodeSelector map[string]string `json:"nodeSelector"`
+}
+
+// NodePoolStatus is the rollout progress of the MachineConfigPool of a node pool
+type NodePoolStatus struct {
+	// Name of the node pool
+	Name string `json:"name"`
+	// MachineConfigPool is the name of the MachineConfigPool of the node pool
+	MachineConfigPool string `json:"machineConfigPool"`
+	// MachineCount is the number of machines in the MachineConfigPool
+	MachineCount int32 `json:"machineCount"`
+	// UpdatedMachineCount is the number of machines updated with the machine config changes
+	UpdatedMachineCount int32 `json:"updatedMachineCount"`
+	// DegradedMachineCount is the number of machines which failed to be updated
+	DegradedMachineCount int32 `json:"degradedMachineCount"`
+	// Ready is true once all the machines of the MachineConfigPool are updated
+	Ready bool `json:"ready"`
+}
+
+// ServiceChange is a change of a field of the agent service held by the service dry run
+type ServiceChange struct {
+	// Field is the path of the changed field, e.g. spec.ports or metadata.annotations[<key>]
+	Field string `json:"field"`
+	// Current is the JSON encoded current value of the field, empty if not set
+	Current string `json:"current,omitempty"`
+	// Desired is the JSON encoded desired value of the field, empty if removed
+	Desired string `json:"desired,omitempty"`
+}
+
+// MachineConfigStatus is the state of the machine config changes of a NodeObservability
+type MachineConfigStatus struct {
+	// RenderedHash is the hash of the desired profiling MachineConfigs,
+	// it only changes when the MachineConfigs applied by the operator change
+	RenderedHash string `json:"renderedHash,omitempty"`
+	// MachineConfigPoolUnpauseTime is the time at which the MachineConfigPool paused
+	// by the Paused rollout strategy will be unpaused, not set when no pool is paused
+	MachineConfigPoolUnpauseTime *metav1.Time `json:"machineConfigPoolUnpauseTime,omitempty"`
 }
 
 // NodeObservabilityStatus defines the observed state of NodeObservability
@@ -46,8 +387,53 @@ type NodeObservabilityStatus struct {
 	LastUpdate *metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions contain details for aspects of the current state of this API Resource.
 	ConditionalStatus `json:"conditions,omitempty"`
+	// LastScheduleTime is the last time a run was scheduled
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// NextScheduleTime is the next time a run is scheduled
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	// NodePools is the rollout progress of the MachineConfigPools of the node pools
+	NodePools []NodePoolStatus `json:"nodePools,omitempty"`
+	// ProfilingEnabledNodes is the number of machines on which the CRI-O profiling is enabled:
+	// the updated machines of the MachineConfigPools whose rendered config enables it
+	ProfilingEnabledNodes int32 `json:"profilingEnabledNodes,omitempty"`
+	// ProfilingPendingNodes is the number of machines on which the CRI-O profiling
+	// is not enabled yet while the machine config changes are rolled out
+	ProfilingPendingNodes int32 `json:"profilingPendingNodes,omitempty"`
+	// MachineConfig is the state of the machine config changes enabling the CRI-O profiling,
+	// not set when no machine config change is requested
+	MachineConfig *MachineConfigStatus `json:"machineConfig,omitempty"`
+	// ObservedForceReconcile is the last value of the force reconcile annotation
+	// for which the reconcile completed
+	ObservedForceReconcile string `json:"observedForceReconcile,omitempty"`
+	// PendingServiceChanges are the changes of the existing agent service,
+	// only set while they are held with the service dry run annotation
+	PendingServiceChanges []ServiceChange `json:"pendingServiceChanges,omitempty"`
+	// ObservedGeneration is the generation of the spec reflected in the status,
+	// the status is stale while it's lower than the generation of NodeObservability
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// RunRefs references the finished runs of NodeObservability, the most recent first
+	RunRefs []RunRef `json:"runRefs,omitempty"`
 }
 
+// RunRef references a finished NodeObservabilityRun
+type RunRef struct {
+	// Name is the name of the run
+	Name string `json:"name"`
+	// Namespace is the namespace of the run
+	Namespace string `json:"namespace"`
+	// Phase is the phase in which the run finished
+	Phase NodeObservabilityRunPhase `json:"phase,omitempty"`
+	// StartTimestamp is the time the run started, not set if it never started
+	StartTimestamp *metav1.Time `json:"startTimestamp,omitempty"`
+	// FinishedTimestamp is the time the run finished
+	FinishedTimestamp *metav1.Time `json:"finishedTimestamp,omitempty"`
+}
+
+//+kubebuilder:printcolumn:JSONPath=".spec.type", name="Type", type="string"
+//+kubebuilder:printcolumn:JSONPath=".status.count", name="Nodes", type="integer"
+//+kubebuilder:printcolumn:JSONPath=".status.profilingEnabledNodes", name="Profiling", type="integer"
+//+kubebuilder:printcolumn:JSONPath=".status.conditions[?(@.type==\"Ready\")].status", name="Ready", type="string"
+//+kubebuilder:printcolumn:JSONPath=".metadata.creationTimestamp", name="Age", type="date"
 //+kubebuilder:resource:scope=Cluster,shortName=nob
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
@@ -80,6 +466,75 @@ func (s *NodeObservabilityStatus) IsReady() bool {
 	return false
 }
 
+// RollUpConditions sets the Available, Progressing and Degraded conditions
+// from the conditions of the agents and of the machine config changes,
+// the status is marked as observed for the given generation of the spec.
+func (s *NodeObservabilityStatus) RollUpConditions(generation int64) {
+	s.ObservedGeneration = generation
+
+	available := metav1.Condition{Type: Available, Status: metav1.ConditionFalse, Reason: ReasonInProgress, ObservedGeneration: generation}
+	if cond := s.GetCondition(DebugReady); cond != nil {
+		available.Reason, available.Message = cond.Reason, cond.Message
+		if cond.Status == metav1.ConditionTrue {
+			available.Status = metav1.ConditionTrue
+		}
+	}
+	s.SetStatusCondition(available)
+
+	progressing := metav1.Condition{Type: Progressing, Status: metav1.ConditionFalse, Reason: ReasonAsExpected, ObservedGeneration: generation}
+	for _, t := range []string{DaemonSetRolledOut, MachineConfigPoolReady} {
+		if cond := s.GetCondition(t); cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == ReasonInProgress {
+			progressing.Status, progressing.Reason, progressing.Message = metav1.ConditionTrue, ReasonInProgress, cond.Message
+			break
+		}
+	}
+	s.SetStatusCondition(progressing)
+
+	degraded := metav1.Condition{Type: Degraded, Status: metav1.ConditionFalse, Reason: ReasonAsExpected, ObservedGeneration: generation}
+	if cond := s.GetCondition(DebugReady); cond != nil && cond.Status == metav1.ConditionFalse &&
+		(cond.Reason == ReasonInvalid || cond.Reason == ReasonForbidden) {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	} else if cond := s.GetCondition(MachineConfigDegraded); cond != nil && cond.Status == metav1.ConditionTrue {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	} else if cond := s.GetCondition(MachineConfigPoolReady); cond != nil && cond.Status == metav1.ConditionFalse &&
+		(cond.Reason == ReasonFailed || cond.Reason == ReasonMachineConfigPoolNotFound || cond.Reason == ReasonMachineConfigPoolMismatch) {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	} else if cond := s.GetCondition(ServingCertAvailable); cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == ReasonServingCertNotInjected {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	} else if cond := s.GetCondition(AgentReady); cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == ReasonFailed {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	} else if cond := s.GetCondition(NodesMatched); cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == ReasonNoMatchingNodes {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, cond.Reason, cond.Message
+	}
+	s.SetStatusCondition(degraded)
+}
+
+// MarkGenerationProgressing sets the Progressing condition, and the Available one to false,
+// while the given generation of the spec is not reflected in the status yet.
+// Returns true if the conditions changed.
+func (s *NodeObservabilityStatus) MarkGenerationProgressing(generation int64) bool {
+	if s.ObservedGeneration == generation {
+		return false
+	}
+	msg := fmt.Sprintf("Reconciling generation %d of the spec", generation)
+	progressing := s.SetStatusCondition(metav1.Condition{Type: Progressing, Status: metav1.ConditionTrue, Reason: ReasonInProgress, Message: msg, ObservedGeneration: generation})
+	available := s.SetStatusCondition(metav1.Condition{Type: Available, Status: metav1.ConditionFalse, Reason: ReasonInProgress, Message: msg, ObservedGeneration: generation})
+	return progressing || available
+}
+
+// MasterNodesIncluded returns true if the profiling of the master nodes
+// is requested and confirmed with the annotation.
+func (r *NodeObservability) MasterNodesIncluded() bool {
+	return r.Spec.IncludeMasterNodes && r.Annotations[IncludeMasterNodesConfirmationAnnotation] == "true"
+}
+
+// IsAvailable returns true if the rolled up Available condition is true
+// for the given generation of the spec.
+func (s *NodeObservabilityStatus) IsAvailable(generation int64) bool {
+	cond := s.GetCondition(Available)
+	return s.ObservedGeneration == generation && cond != nil && cond.Status == metav1.ConditionTrue
+}
+
 func init() {
 	SchemeBuilder.Register(&NodeObservability{}, &NodeObservabilityList{})
 }
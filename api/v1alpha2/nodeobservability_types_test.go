@@ -0,0 +1,217 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRollUpConditions(t *testing.T) {
+	type condition struct {
+		status metav1.ConditionStatus
+		reason string
+	}
+	cases := []struct {
+		name     string
+		setup    func(*NodeObservabilityStatus)
+		expected map[string]condition
+	}{
+		{
+			name:  "no condition",
+			setup: func(*NodeObservabilityStatus) {},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionFalse, ReasonAsExpected},
+			},
+		},
+		{
+			name: "ready",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(MachineConfigPoolReady, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(DebugReady, metav1.ConditionTrue, ReasonReady, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionTrue, ReasonReady},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionFalse, ReasonAsExpected},
+			},
+		},
+		{
+			name: "daemonset rolling out",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionFalse, ReasonInProgress, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionTrue, ReasonInProgress},
+				Degraded:    {metav1.ConditionFalse, ReasonAsExpected},
+			},
+		},
+		{
+			name: "machine config pool updating",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(MachineConfigPoolReady, metav1.ConditionFalse, ReasonInProgress, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionTrue, ReasonInProgress},
+				Degraded:    {metav1.ConditionFalse, ReasonAsExpected},
+			},
+		},
+		{
+			name: "machine config pool degraded",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(MachineConfigPoolReady, metav1.ConditionFalse, ReasonFailed, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionTrue, ReasonFailed},
+			},
+		},
+		{
+			name: "machine config rejected",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(MachineConfigPoolReady, metav1.ConditionFalse, ReasonFailed, "")
+				s.SetCondition(MachineConfigDegraded, metav1.ConditionTrue, ReasonRenderDegraded, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionTrue, ReasonRenderDegraded},
+			},
+		},
+		{
+			name: "serving cert not injected",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionFalse, ReasonInProgress, "")
+				s.SetCondition(ServingCertAvailable, metav1.ConditionFalse, ReasonServingCertNotInjected, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionTrue, ReasonInProgress},
+				Degraded:    {metav1.ConditionTrue, ReasonServingCertNotInjected},
+			},
+		},
+		{
+			name: "agents crash looping",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionFalse, ReasonInProgress, "")
+				s.SetCondition(AgentReady, metav1.ConditionFalse, ReasonFailed, "")
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInProgress, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInProgress},
+				Progressing: {metav1.ConditionTrue, ReasonInProgress},
+				Degraded:    {metav1.ConditionTrue, ReasonFailed},
+			},
+		},
+		{
+			name: "no matching node",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DaemonSetRolledOut, metav1.ConditionTrue, ReasonReady, "")
+				s.SetCondition(NodesMatched, metav1.ConditionFalse, ReasonNoMatchingNodes, "")
+				s.SetCondition(DebugReady, metav1.ConditionTrue, ReasonReady, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionTrue, ReasonReady},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionTrue, ReasonNoMatchingNodes},
+			},
+		},
+		{
+			name: "invalid",
+			setup: func(s *NodeObservabilityStatus) {
+				s.SetCondition(DebugReady, metav1.ConditionFalse, ReasonInvalid, "")
+			},
+			expected: map[string]condition{
+				Available:   {metav1.ConditionFalse, ReasonInvalid},
+				Progressing: {metav1.ConditionFalse, ReasonAsExpected},
+				Degraded:    {metav1.ConditionTrue, ReasonInvalid},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &NodeObservabilityStatus{}
+			tc.setup(s)
+
+			s.RollUpConditions(2)
+
+			if s.ObservedGeneration != 2 {
+				t.Errorf("expected observed generation 2, got %d", s.ObservedGeneration)
+			}
+			for condType, expected := range tc.expected {
+				cond := s.GetCondition(condType)
+				if cond == nil || cond.Status != expected.status || cond.Reason != expected.reason || cond.ObservedGeneration != 2 {
+					t.Errorf("expected %s condition with status %s and reason %s, got %+v", condType, expected.status, expected.reason, cond)
+				}
+			}
+			if available := s.IsAvailable(2); available != (tc.expected[Available].status == metav1.ConditionTrue) {
+				t.Errorf("unexpected availability %t", available)
+			}
+			if s.IsAvailable(3) {
+				t.Errorf("expected a stale status not to be available")
+			}
+		})
+	}
+}
+
+func TestMarkGenerationProgressing(t *testing.T) {
+	s := &NodeObservabilityStatus{}
+	s.SetCondition(DebugReady, metav1.ConditionTrue, ReasonReady, "")
+	s.RollUpConditions(1)
+	if s.MarkGenerationProgressing(1) {
+		t.Errorf("expected the observed generation not to change the conditions")
+	}
+
+	if !s.MarkGenerationProgressing(2) {
+		t.Fatalf("expected the new generation to change the conditions")
+	}
+	if s.ObservedGeneration != 1 {
+		t.Errorf("expected observed generation 1, got %d", s.ObservedGeneration)
+	}
+	if cond := s.GetCondition(Progressing); cond == nil || cond.Status != metav1.ConditionTrue || cond.ObservedGeneration != 2 {
+		t.Errorf("expected the new generation to be progressing, got %+v", cond)
+	}
+	if cond := s.GetCondition(Available); cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != ReasonInProgress {
+		t.Errorf("expected the new generation not to be available, got %+v", cond)
+	}
+	if s.MarkGenerationProgressing(2) {
+		t.Errorf("expected the conditions not to change again")
+	}
+
+	s.RollUpConditions(2)
+	if !s.IsAvailable(2) {
+		t.Errorf("expected the status to be available once the generation is observed")
+	}
+}
+
+func TestSetStatusCondition(t *testing.T) {
+	s := &ConditionalStatus{}
+	cond := metav1.Condition{Type: Degraded, Status: metav1.ConditionTrue, Reason: ReasonFailed, Message: "failed", ObservedGeneration: 1}
+	if !s.SetStatusCondition(cond) {
+		t.Errorf("expected the new condition to be set")
+	}
+	transition := s.GetCondition(Degraded).LastTransitionTime
+	if s.SetStatusCondition(cond) {
+		t.Errorf("expected the same condition not to change")
+	}
+	cond.Message = "failed again"
+	if !s.SetStatusCondition(cond) {
+		t.Errorf("expected the message change to be set")
+	}
+	if got := s.GetCondition(Degraded); got.Message != cond.Message || !got.LastTransitionTime.Equal(&transition) {
+		t.Errorf("expected the message to change without transition, got %+v", got)
+	}
+}
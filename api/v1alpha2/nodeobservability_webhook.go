@@ -17,11 +17,384 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"context"
+	"fmt"
+	"net"
+	gopath "path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// DefaultAgentPort is the port of the agent service when not set in the spec
+	DefaultAgentPort = 8443
+	// DefaultServingCertSecretName is the name of the secret with the serving certificate
+	// of the agent service when not set in the spec
+	DefaultServingCertSecretName = "node-observability-agent"
+	// maxDNSNameservers is the number of nameservers accepted by the pods
+	maxDNSNameservers = 3
 )
 
-func (r *NodeObservability) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// supportedNodeObservabilityTypes are the profiling types handled by the operator
+var supportedNodeObservabilityTypes = []string{
+	string(CrioKubeletNodeObservabilityType),
+	string(KubeletNodeObservabilityType),
+	string(EtcdNodeObservabilityType),
+}
+
+// reservedAgentEnvNames are the environment variables of the agent container set by the operator
+var reservedAgentEnvNames = []string{
+	"NODE_IP",
+	"AGENT_LOG_LEVEL",
+	"ETCD_CERTS_DIR",
+}
+
+// supportedAgentLogLevels are the log levels of the agents
+var supportedAgentLogLevels = []string{
+	string(DebugAgentLogLevel),
+	string(InfoAgentLogLevel),
+	string(WarnAgentLogLevel),
+	string(ErrorAgentLogLevel),
+}
+
+func (r *NodeObservability) SetupWebhookWithManager(mgr ctrl.Manager, defaulter *NodeObservabilityDefaulter, validator *NodeObservabilityValidator) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithDefaulter(defaulter).
+		WithValidator(validator).
 		Complete()
 }
+
+//+kubebuilder:webhook:path=/mutate-nodeobservability-olm-openshift-io-v1alpha2-nodeobservability,mutating=true,failurePolicy=fail,sideEffects=None,groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities,verbs=create;update,versions=v1alpha2,name=mnodeobservability.kb.io,admissionReviewVersions=v1
+
+// NodeObservabilityDefaulter sets the defaults of the fields not set in the NodeObservability spec,
+// the fields set by the user are left untouched.
+// +kubebuilder:object:generate=false
+type NodeObservabilityDefaulter struct {
+	// AgentImage is the operator's default agent image
+	AgentImage string
+}
+
+var _ webhook.CustomDefaulter = &NodeObservabilityDefaulter{}
+
+// Default implements webhook.CustomDefaulter
+func (d *NodeObservabilityDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	nodeObs, ok := obj.(*NodeObservability)
+	if !ok {
+		return fmt.Errorf("expected a NodeObservability but got a %T", obj)
+	}
+	if nodeObs.Spec.Port == nil {
+		port := int32(DefaultAgentPort)
+		nodeObs.Spec.Port = &port
+	}
+	if nodeObs.Spec.ServingCertSecretName == "" {
+		nodeObs.Spec.ServingCertSecretName = DefaultServingCertSecretName
+	}
+	if nodeObs.Spec.AgentImage == "" {
+		nodeObs.Spec.AgentImage = d.AgentImage
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-nodeobservability-olm-openshift-io-v1alpha2-nodeobservability,mutating=false,failurePolicy=fail,sideEffects=None,groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities,verbs=create;update;delete,versions=v1alpha2,name=vnodeobservability.kb.io,admissionReviewVersions=v1
+
+// NodeObservabilityValidator validates the NodeObservability spec
+// and prevents the deletion of NodeObservability while some of its runs are not finished.
+// +kubebuilder:object:generate=false
+type NodeObservabilityValidator struct {
+	// Client lists the NodeObservabilityRuns
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &NodeObservabilityValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *NodeObservabilityValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	nodeObs, ok := obj.(*NodeObservability)
+	if !ok {
+		return fmt.Errorf("expected a NodeObservability but got a %T", obj)
+	}
+	return nodeObs.ValidateCreate()
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *NodeObservabilityValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	nodeObs, ok := newObj.(*NodeObservability)
+	if !ok {
+		return fmt.Errorf("expected a NodeObservability but got a %T", newObj)
+	}
+	return nodeObs.ValidateUpdate(oldObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator,
+// the deletion is forbidden while NodeObservabilityRuns referencing NodeObservability are not finished
+// as their agent requests would be interrupted, unless the ForceDeleteAnnotation is set to "true".
+func (v *NodeObservabilityValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	nodeObs, ok := obj.(*NodeObservability)
+	if !ok {
+		return fmt.Errorf("expected a NodeObservability but got a %T", obj)
+	}
+	if nodeObs.Annotations[ForceDeleteAnnotation] == "true" {
+		return nil
+	}
+	runs := &NodeObservabilityRunList{}
+	if err := v.Client.List(ctx, runs); err != nil {
+		return fmt.Errorf("failed to list nodeobservabilityruns: %w", err)
+	}
+	var active []string
+	for _, run := range runs.Items {
+		if run.Spec.NodeObservabilityRef == nil || run.Spec.NodeObservabilityRef.Name != nodeObs.Name {
+			continue
+		}
+		if run.Status.FinishedTimestamp != nil && !run.Status.FinishedTimestamp.IsZero() {
+			continue
+		}
+		active = append(active, run.Namespace+"/"+run.Name)
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	sort.Strings(active)
+	return apierrors.NewForbidden(GroupVersion.WithResource("nodeobservabilities").GroupResource(), nodeObs.Name,
+		fmt.Errorf("nodeobservabilityruns %s are not finished, wait for them to finish or set the %s annotation to \"true\"",
+			strings.Join(active, ", "), ForceDeleteAnnotation))
+}
+
+var _ webhook.Validator = &NodeObservability{}
+
+// ValidateCreate implements webhook.Validator
+func (r *NodeObservability) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (r *NodeObservability) ValidateUpdate(old runtime.Object) error {
+	// the finalizers of the resources created before the validation
+	// must be removable even if their spec is not valid
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator,
+// the active runs are checked by NodeObservabilityValidator which lists them.
+func (r *NodeObservability) ValidateDelete() error {
+	return nil
+}
+
+func (r *NodeObservability) validate() error {
+	errs := r.Spec.validate(field.NewPath("spec"))
+	// etcd only runs on the master nodes
+	if r.Spec.Type == EtcdNodeObservabilityType && !r.Spec.IncludeMasterNodes {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "type"), "the etcd type requires includeMasterNodes"))
+	}
+	if r.Spec.IncludeMasterNodes && r.Annotations[IncludeMasterNodesConfirmationAnnotation] != "true" {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "includeMasterNodes"),
+			fmt.Sprintf("requires the %s annotation set to \"true\" as the control plane nodes may be rebooted", IncludeMasterNodesConfirmationAnnotation)))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("NodeObservability").GroupKind(), r.Name, errs)
+}
+
+func (s *NodeObservabilitySpec) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if !contains(supportedNodeObservabilityTypes, string(s.Type)) {
+		errs = append(errs, field.NotSupported(path.Child("type"), s.Type, supportedNodeObservabilityTypes))
+	}
+	if len(s.NodeSelector) == 0 {
+		errs = append(errs, field.Required(path.Child("nodeSelector"), "at least one label is required to select the nodes to profile, e.g. node-role.kubernetes.io/worker: \"\""))
+	}
+	if s.Port != nil && (*s.Port < 1 || *s.Port > 65535) {
+		errs = append(errs, field.Invalid(path.Child("port"), *s.Port, "must be between 1 and 65535"))
+	}
+	if s.HostNetwork && (s.Port == nil || *s.Port == DefaultAgentPort) {
+		errs = append(errs, field.Invalid(path.Child("port"), DefaultAgentPort, "must be set to a port free on the nodes with hostNetwork, the default one is taken by other host network services"))
+	}
+	errs = append(errs, s.validateDNS(path)...)
+	if s.MachineConfigRolloutPauseDuration != nil && s.MachineConfigRolloutStrategy != PausedMachineConfigRolloutStrategy {
+		errs = append(errs, field.Forbidden(path.Child("machineConfigRolloutPauseDuration"), "may only be set with the Paused machineConfigRolloutStrategy"))
+	}
+	if s.LogLevel != "" && !contains(supportedAgentLogLevels, string(s.LogLevel)) {
+		errs = append(errs, field.NotSupported(path.Child("logLevel"), s.LogLevel, supportedAgentLogLevels))
+	}
+	errs = append(errs, validateAgentEnv(s.Env, path.Child("env"))...)
+	errs = append(errs, validatePodAnnotations(s.PodAnnotations, path.Child("podAnnotations"))...)
+	if s.CrioSocketPath != "" && (!gopath.IsAbs(s.CrioSocketPath) || gopath.Clean(s.CrioSocketPath) != s.CrioSocketPath) {
+		errs = append(errs, field.Invalid(path.Child("crioSocketPath"), s.CrioSocketPath, "must be a clean absolute path"))
+	}
+	if s.ProfilesHostPath != "" {
+		if !gopath.IsAbs(s.ProfilesHostPath) || gopath.Clean(s.ProfilesHostPath) != s.ProfilesHostPath {
+			errs = append(errs, field.Invalid(path.Child("profilesHostPath"), s.ProfilesHostPath, "must be a clean absolute path"))
+		} else if s.ProfilesHostPath == "/" {
+			errs = append(errs, field.Invalid(path.Child("profilesHostPath"), s.ProfilesHostPath, "must not be the root directory"))
+		}
+	}
+	if s.MachineConfigDryRun && s.Type != CrioKubeletNodeObservabilityType {
+		errs = append(errs, field.Forbidden(path.Child("machineConfigDryRun"), "may only be set with the crio-kubelet type"))
+	}
+	if s.Schedule == "" {
+		if s.ConcurrencyPolicy != "" {
+			errs = append(errs, field.Forbidden(path.Child("concurrencyPolicy"), "may only be set with a schedule"))
+		}
+		if s.SuccessfulRunsHistoryLimit != nil {
+			errs = append(errs, field.Forbidden(path.Child("successfulRunsHistoryLimit"), "may only be set with a schedule"))
+		}
+	}
+	errs = append(errs, validateNodePools(s.NodePools, path.Child("nodePools"))...)
+	if s.MachineConfigPoolName != "" {
+		mcpPath := path.Child("machineConfigPoolName")
+		for _, msg := range validation.IsDNS1123Subdomain(s.MachineConfigPoolName) {
+			errs = append(errs, field.Invalid(mcpPath, s.MachineConfigPoolName, msg))
+		}
+		if s.Type != CrioKubeletNodeObservabilityType {
+			errs = append(errs, field.Forbidden(mcpPath, "may only be set with the crio-kubelet type"))
+		}
+		if len(s.NodePools) != 0 {
+			errs = append(errs, field.Forbidden(mcpPath, "may not be set with nodePools"))
+		}
+		// the operator doesn't pause the MachineConfigPools it doesn't manage
+		if s.MachineConfigRolloutStrategy == PausedMachineConfigRolloutStrategy {
+			errs = append(errs, field.Forbidden(mcpPath, "may not be set with the Paused machineConfigRolloutStrategy"))
+		}
+	}
+	return errs
+}
+
+// validateDNS checks the DNS policy against the host network
+// and the DNS config against the DNS policy.
+func (s *NodeObservabilitySpec) validateDNS(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	// the pods in the host network fall back to the Default policy with ClusterFirst
+	if s.HostNetwork && s.DNSPolicy == corev1.DNSClusterFirst {
+		errs = append(errs, field.Invalid(path.Child("dnsPolicy"), s.DNSPolicy, "resolves with the nameservers of the nodes with hostNetwork, use ClusterFirstWithHostNet or Default"))
+	}
+	if s.DNSConfig == nil {
+		if s.DNSPolicy == corev1.DNSNone {
+			errs = append(errs, field.Required(path.Child("dnsConfig"), "required with the None dnsPolicy"))
+		}
+		return errs
+	}
+	configPath := path.Child("dnsConfig")
+	if s.DNSPolicy == corev1.DNSNone && len(s.DNSConfig.Nameservers) == 0 {
+		errs = append(errs, field.Required(configPath.Child("nameservers"), "at least one nameserver is required with the None dnsPolicy"))
+	}
+	if len(s.DNSConfig.Nameservers) > maxDNSNameservers {
+		errs = append(errs, field.TooMany(configPath.Child("nameservers"), len(s.DNSConfig.Nameservers), maxDNSNameservers))
+	}
+	for i, ns := range s.DNSConfig.Nameservers {
+		if net.ParseIP(ns) == nil {
+			errs = append(errs, field.Invalid(configPath.Child("nameservers").Index(i), ns, "must be an IP address"))
+		}
+	}
+	for i, search := range s.DNSConfig.Searches {
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimSuffix(search, ".")) {
+			errs = append(errs, field.Invalid(configPath.Child("searches").Index(i), search, msg))
+		}
+	}
+	for i, opt := range s.DNSConfig.Options {
+		if opt.Name == "" {
+			errs = append(errs, field.Required(configPath.Child("options").Index(i).Child("name"), ""))
+		}
+	}
+	return errs
+}
+
+// validateAgentEnv checks that the extra environment variables of the agent container
+// are valid, unique and don't override the ones set by the operator.
+func validateAgentEnv(env []corev1.EnvVar, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]struct{}{}
+	for i, e := range env {
+		namePath := path.Index(i).Child("name")
+		for _, msg := range validation.IsEnvVarName(e.Name) {
+			errs = append(errs, field.Invalid(namePath, e.Name, msg))
+		}
+		if contains(reservedAgentEnvNames, e.Name) {
+			errs = append(errs, field.Forbidden(namePath, fmt.Sprintf("%s is set by the operator and cannot be overridden", e.Name)))
+		}
+		if _, found := names[e.Name]; found {
+			errs = append(errs, field.Duplicate(namePath, e.Name))
+		}
+		names[e.Name] = struct{}{}
+		if e.Value != "" && e.ValueFrom != nil {
+			errs = append(errs, field.Forbidden(path.Index(i).Child("valueFrom"), "may not be set together with value"))
+		}
+	}
+	return errs
+}
+
+// validatePodAnnotations checks the format of the annotations of the agent pods
+// and that they don't take the domain reserved for the operator.
+func validatePodAnnotations(annotations map[string]string, path *field.Path) field.ErrorList {
+	errs := apivalidation.ValidateAnnotations(annotations, path)
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if IsReservedAnnotation(k) {
+			errs = append(errs, field.Forbidden(path.Key(k), fmt.Sprintf("the %s domain is reserved for the operator", GroupVersion.Group)))
+		}
+	}
+	return errs
+}
+
+// IsReservedAnnotation returns true if the annotation key is in the domain reserved for the operator.
+func IsReservedAnnotation(key string) bool {
+	return strings.HasPrefix(key, GroupVersion.Group+"/")
+}
+
+// validateNodePools checks that the node pools have unique names
+// and that no node can be selected by more than one pool.
+func validateNodePools(pools []NodePool, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]struct{}{}
+	for i, pool := range pools {
+		if _, ok := names[pool.Name]; ok {
+			errs = append(errs, field.Duplicate(path.Index(i).Child("name"), pool.Name))
+		}
+		names[pool.Name] = struct{}{}
+		for _, other := range pools[:i] {
+			if !disjointSelectors(pool.NodeSelector, other.NodeSelector) {
+				errs = append(errs, field.Invalid(path.Index(i).Child("nodeSelector"), pool.NodeSelector,
+					fmt.Sprintf("may select the same nodes as %s pool, the selectors must require different values for a common label", other.Name)))
+			}
+		}
+	}
+	return errs
+}
+
+// disjointSelectors returns true if no node can match both selectors:
+// they require different values for the same label.
+func disjointSelectors(a, b map[string]string) bool {
+	for key, value := range a {
+		if other, ok := b[key]; ok && other != value {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,575 @@
+package v1alpha2
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateNodeObservability(t *testing.T) {
+	pauseDuration := &metav1.Duration{}
+	cases := []struct {
+		name             string
+		mutate           func(*NodeObservability)
+		deleted          bool
+		expectedMessages []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(*NodeObservability) {},
+		},
+		{
+			name: "kubelet type",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = KubeletNodeObservabilityType
+			},
+		},
+		{
+			name: "valid with all fields",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Port = pointer.Int32(9443)
+				nodeObs.Spec.MachineConfigRolloutStrategy = PausedMachineConfigRolloutStrategy
+				nodeObs.Spec.MachineConfigRolloutPauseDuration = pauseDuration
+				nodeObs.Spec.MachineConfigDryRun = true
+				nodeObs.Spec.Schedule = "@daily"
+				nodeObs.Spec.ConcurrencyPolicy = ForbidConcurrent
+				nodeObs.Spec.SuccessfulRunsHistoryLimit = pointer.Int32(1)
+				nodeObs.Spec.LogLevel = DebugAgentLogLevel
+			},
+		},
+		{
+			name: "unknown type",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = "perf"
+			},
+			expectedMessages: []string{`spec.type: Unsupported value: "perf": supported values: "crio-kubelet", "kubelet", "etcd"`},
+		},
+		{
+			name: "etcd type",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Annotations = map[string]string{IncludeMasterNodesConfirmationAnnotation: "true"}
+				nodeObs.Spec.Type = EtcdNodeObservabilityType
+				nodeObs.Spec.IncludeMasterNodes = true
+			},
+		},
+		{
+			name: "etcd type without the master nodes",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = EtcdNodeObservabilityType
+			},
+			expectedMessages: []string{"spec.type: Forbidden: the etcd type requires includeMasterNodes"},
+		},
+		{
+			name: "unknown log level",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.LogLevel = "trace"
+			},
+			expectedMessages: []string{`spec.logLevel: Unsupported value: "trace": supported values: "debug", "info", "warn", "error"`},
+		},
+		{
+			name: "extra agent environment variables",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Env = []corev1.EnvVar{
+					{Name: "EXPERIMENTAL_COLLECTORS", Value: "true"},
+					{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "token"}}},
+				}
+			},
+		},
+		{
+			name: "reserved agent environment variable",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Env = []corev1.EnvVar{{Name: "AGENT_LOG_LEVEL", Value: "debug"}}
+			},
+			expectedMessages: []string{"spec.env[0].name: Forbidden: AGENT_LOG_LEVEL is set by the operator and cannot be overridden"},
+		},
+		{
+			name: "invalid agent environment variables",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Env = []corev1.EnvVar{
+					{Name: "1FLAG", Value: "true"},
+					{Name: "FLAG", Value: "true"},
+					{Name: "FLAG", Value: "true", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "flag"}}},
+				}
+			},
+			expectedMessages: []string{
+				`spec.env[0].name: Invalid value: "1FLAG"`,
+				`spec.env[2].name: Duplicate value: "FLAG"`,
+				"spec.env[2].valueFrom: Forbidden: may not be set together with value",
+			},
+		},
+		{
+			name: "pod annotations",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.PodAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+			},
+		},
+		{
+			name: "invalid pod annotations",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.PodAnnotations = map[string]string{
+					"sidecar.istio.io/inject/mode":                      "false",
+					"nodeobservability.olm.openshift.io/managed-labels": "app",
+				}
+			},
+			expectedMessages: []string{
+				`spec.podAnnotations: Invalid value: "sidecar.istio.io/inject/mode"`,
+				"spec.podAnnotations[nodeobservability.olm.openshift.io/managed-labels]: Forbidden: the nodeobservability.olm.openshift.io domain is reserved for the operator",
+			},
+		},
+		{
+			name: "crio socket path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.CrioSocketPath = "/run/custom/crio.sock"
+			},
+		},
+		{
+			name: "relative crio socket path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.CrioSocketPath = "run/crio/crio.sock"
+			},
+			expectedMessages: []string{`spec.crioSocketPath: Invalid value: "run/crio/crio.sock": must be a clean absolute path`},
+		},
+		{
+			name: "unclean crio socket path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.CrioSocketPath = "/var/run/../run/crio/crio.sock"
+			},
+			expectedMessages: []string{`spec.crioSocketPath: Invalid value: "/var/run/../run/crio/crio.sock": must be a clean absolute path`},
+		},
+		{
+			name: "profiles host path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.ProfilesHostPath = "/var/lib/node-observability"
+			},
+		},
+		{
+			name: "relative profiles host path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.ProfilesHostPath = "var/lib/node-observability"
+			},
+			expectedMessages: []string{`spec.profilesHostPath: Invalid value: "var/lib/node-observability": must be a clean absolute path`},
+		},
+		{
+			name: "root profiles host path",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.ProfilesHostPath = "/"
+			},
+			expectedMessages: []string{`spec.profilesHostPath: Invalid value: "/": must not be the root directory`},
+		},
+		{
+			name: "host network",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.HostNetwork = true
+				nodeObs.Spec.Port = pointer.Int32(9443)
+			},
+		},
+		{
+			name: "host network on the default port",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.HostNetwork = true
+				nodeObs.Spec.Port = pointer.Int32(DefaultAgentPort)
+			},
+			expectedMessages: []string{"spec.port: Invalid value: 8443: must be set to a port free on the nodes with hostNetwork"},
+		},
+		{
+			name: "host network without port",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.HostNetwork = true
+			},
+			expectedMessages: []string{"spec.port: Invalid value: 8443: must be set to a port free on the nodes with hostNetwork"},
+		},
+		{
+			name: "host network with the ClusterFirst DNS policy",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.HostNetwork = true
+				nodeObs.Spec.Port = pointer.Int32(9443)
+				nodeObs.Spec.DNSPolicy = corev1.DNSClusterFirst
+			},
+			expectedMessages: []string{"spec.dnsPolicy: Invalid value: \"ClusterFirst\": resolves with the nameservers of the nodes with hostNetwork"},
+		},
+		{
+			name: "DNS config",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.DNSPolicy = corev1.DNSNone
+				nodeObs.Spec.DNSConfig = &corev1.PodDNSConfig{
+					Nameservers: []string{"10.0.0.10", "fd00::10"},
+					Searches:    []string{"node-observability-operator.svc.cluster.local.", "cluster.local"},
+					Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: pointer.String("2")}},
+				}
+			},
+		},
+		{
+			name: "None DNS policy without DNS config",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.DNSPolicy = corev1.DNSNone
+			},
+			expectedMessages: []string{"spec.dnsConfig: Required value: required with the None dnsPolicy"},
+		},
+		{
+			name: "None DNS policy without nameserver",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.DNSPolicy = corev1.DNSNone
+				nodeObs.Spec.DNSConfig = &corev1.PodDNSConfig{Searches: []string{"cluster.local"}}
+			},
+			expectedMessages: []string{"spec.dnsConfig.nameservers: Required value: at least one nameserver is required"},
+		},
+		{
+			name: "invalid DNS config",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.DNSConfig = &corev1.PodDNSConfig{
+					Nameservers: []string{"10.0.0.10", "10.0.0.11", "10.0.0.12", "dns.example.com"},
+					Searches:    []string{"Cluster_Local"},
+					Options:     []corev1.PodDNSConfigOption{{Value: pointer.String("2")}},
+				}
+			},
+			expectedMessages: []string{
+				"spec.dnsConfig.nameservers: Too many: 4: must have at most 3 items",
+				"spec.dnsConfig.nameservers[3]: Invalid value: \"dns.example.com\": must be an IP address",
+				"spec.dnsConfig.searches[0]: Invalid value: \"Cluster_Local\"",
+				"spec.dnsConfig.options[0].name: Required value",
+			},
+		},
+		{
+			name: "missing node selector",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.NodeSelector = nil
+			},
+			expectedMessages: []string{"spec.nodeSelector: Required value: at least one label is required"},
+		},
+		{
+			name: "port out of range",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Port = pointer.Int32(70000)
+			},
+			expectedMessages: []string{"spec.port: Invalid value: 70000: must be between 1 and 65535"},
+		},
+		{
+			name: "pause duration without paused rollout strategy",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.MachineConfigRolloutStrategy = ImmediateMachineConfigRolloutStrategy
+				nodeObs.Spec.MachineConfigRolloutPauseDuration = pauseDuration
+			},
+			expectedMessages: []string{"spec.machineConfigRolloutPauseDuration: Forbidden: may only be set with the Paused machineConfigRolloutStrategy"},
+		},
+		{
+			name: "machine config dry run with kubelet type",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = KubeletNodeObservabilityType
+				nodeObs.Spec.MachineConfigDryRun = true
+			},
+			expectedMessages: []string{"spec.machineConfigDryRun: Forbidden: may only be set with the crio-kubelet type"},
+		},
+		{
+			name: "schedule fields without schedule",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.ConcurrencyPolicy = ReplaceConcurrent
+				nodeObs.Spec.SuccessfulRunsHistoryLimit = pointer.Int32(1)
+			},
+			expectedMessages: []string{
+				"spec.concurrencyPolicy: Forbidden: may only be set with a schedule",
+				"spec.successfulRunsHistoryLimit: Forbidden: may only be set with a schedule",
+			},
+		},
+		{
+			name: "machine config pool name",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.MachineConfigPoolName = "infra"
+			},
+		},
+		{
+			name: "invalid machine config pool name",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.MachineConfigPoolName = "Infra"
+			},
+			expectedMessages: []string{`spec.machineConfigPoolName: Invalid value: "Infra": a lowercase RFC 1123 subdomain`},
+		},
+		{
+			name: "machine config pool name with kubelet type",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = KubeletNodeObservabilityType
+				nodeObs.Spec.MachineConfigPoolName = "infra"
+			},
+			expectedMessages: []string{"spec.machineConfigPoolName: Forbidden: may only be set with the crio-kubelet type"},
+		},
+		{
+			name: "machine config pool name with node pools",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.MachineConfigPoolName = "infra"
+				nodeObs.Spec.NodePools = []NodePool{{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}}}
+			},
+			expectedMessages: []string{"spec.machineConfigPoolName: Forbidden: may not be set with nodePools"},
+		},
+		{
+			name: "machine config pool name with paused rollout strategy",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.MachineConfigPoolName = "infra"
+				nodeObs.Spec.MachineConfigRolloutStrategy = PausedMachineConfigRolloutStrategy
+			},
+			expectedMessages: []string{"spec.machineConfigPoolName: Forbidden: may not be set with the Paused machineConfigRolloutStrategy"},
+		},
+		{
+			name: "disjoint node pools",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.NodePools = []NodePool{
+					{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu", "zone": "a"}},
+					{Name: "cpu", NodeSelector: map[string]string{"pool": "cpu"}},
+				}
+			},
+		},
+		{
+			name: "duplicate node pool names",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.NodePools = []NodePool{
+					{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+					{Name: "gpu", NodeSelector: map[string]string{"pool": "cpu"}},
+				}
+			},
+			expectedMessages: []string{`spec.nodePools[1].name: Duplicate value: "gpu"`},
+		},
+		{
+			name: "overlapping node pools",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.NodePools = []NodePool{
+					{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+					{Name: "zone-a", NodeSelector: map[string]string{"zone": "a"}},
+				}
+			},
+			expectedMessages: []string{"spec.nodePools[1].nodeSelector: Invalid value", "may select the same nodes as gpu pool"},
+		},
+		{
+			name: "master nodes included with confirmation",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Annotations = map[string]string{IncludeMasterNodesConfirmationAnnotation: "true"}
+				nodeObs.Spec.IncludeMasterNodes = true
+			},
+		},
+		{
+			name: "master nodes included without confirmation",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.IncludeMasterNodes = true
+			},
+			expectedMessages: []string{"spec.includeMasterNodes: Forbidden: requires the " + IncludeMasterNodesConfirmationAnnotation + " annotation"},
+		},
+		{
+			name: "master nodes included with declined confirmation",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Annotations = map[string]string{IncludeMasterNodesConfirmationAnnotation: "false"}
+				nodeObs.Spec.IncludeMasterNodes = true
+			},
+			expectedMessages: []string{"spec.includeMasterNodes: Forbidden"},
+		},
+		{
+			name: "all errors reported",
+			mutate: func(nodeObs *NodeObservability) {
+				nodeObs.Spec.Type = ""
+				nodeObs.Spec.NodeSelector = map[string]string{}
+				nodeObs.Spec.Port = pointer.Int32(0)
+			},
+			expectedMessages: []string{"spec.type", "spec.nodeSelector", "spec.port"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: NodeObservabilitySpec{
+					NodeSelector: map[string]string{"node-role.kubernetes.io/worker": ""},
+					Type:         CrioKubeletNodeObservabilityType,
+				},
+			}
+			tc.mutate(nodeObs)
+
+			for op, err := range map[string]error{
+				"create": nodeObs.ValidateCreate(),
+				"update": nodeObs.ValidateUpdate(nodeObs.DeepCopy()),
+			} {
+				if len(tc.expectedMessages) == 0 {
+					if err != nil {
+						t.Fatalf("unexpected %s error: %v", op, err)
+					}
+					continue
+				}
+				if !apierrors.IsInvalid(err) {
+					t.Fatalf("expected %s invalid error, got %v", op, err)
+				}
+				for _, msg := range tc.expectedMessages {
+					if !strings.Contains(err.Error(), msg) {
+						t.Errorf("expected %s error to contain %q, got %q", op, msg, err.Error())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDeletedNodeObservability(t *testing.T) {
+	now := metav1.Now()
+	nodeObs := &NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", DeletionTimestamp: &now},
+	}
+	if err := nodeObs.ValidateUpdate(nodeObs.DeepCopy()); err != nil {
+		t.Fatalf("expected the update of the deleted nodeobservability to be allowed, got %v", err)
+	}
+}
+
+func TestValidateNodeObservabilityDelete(t *testing.T) {
+	now := metav1.Now()
+	testRun := func(namespace, name, nodeObsName string, finished bool) *NodeObservabilityRun {
+		run := &NodeObservabilityRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       NodeObservabilityRunSpec{NodeObservabilityRef: &NodeObservabilityRef{Name: nodeObsName}},
+		}
+		if finished {
+			run.Status.FinishedTimestamp = &now
+		}
+		return run
+	}
+	cases := []struct {
+		name             string
+		annotations      map[string]string
+		runs             []runtime.Object
+		expectedMessages []string
+	}{
+		{
+			name: "no runs",
+		},
+		{
+			name: "finished runs",
+			runs: []runtime.Object{testRun("test", "run-1", "cluster", true), testRun("other", "run-2", "cluster", true)},
+		},
+		{
+			name: "active runs of another nodeobservability",
+			runs: []runtime.Object{testRun("test", "run-1", "other", false)},
+		},
+		{
+			name: "active runs",
+			runs: []runtime.Object{testRun("test", "run-1", "cluster", false), testRun("other", "run-2", "cluster", false), testRun("test", "run-3", "cluster", true)},
+			expectedMessages: []string{
+				"nodeobservabilityruns other/run-2, test/run-1 are not finished",
+				"set the " + ForceDeleteAnnotation + " annotation",
+			},
+		},
+		{
+			name:        "active runs with force annotation",
+			annotations: map[string]string{ForceDeleteAnnotation: "true"},
+			runs:        []runtime.Object{testRun("test", "run-1", "cluster", false)},
+		},
+		{
+			name:             "active runs with disabled force annotation",
+			annotations:      map[string]string{ForceDeleteAnnotation: "false"},
+			runs:             []runtime.Object{testRun("test", "run-1", "cluster", false)},
+			expectedMessages: []string{"nodeobservabilityruns test/run-1 are not finished"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to build scheme: %v", err)
+			}
+			v := &NodeObservabilityValidator{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tc.runs...).Build(),
+			}
+			nodeObs := &NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", Annotations: tc.annotations},
+			}
+
+			err := v.ValidateDelete(context.TODO(), nodeObs)
+			if len(tc.expectedMessages) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !apierrors.IsForbidden(err) {
+				t.Fatalf("expected forbidden error, got %v", err)
+			}
+			for _, msg := range tc.expectedMessages {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("expected error to contain %q, got %q", msg, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultNodeObservability(t *testing.T) {
+	const agentImage = "quay.io/openshift/node-observability-agent:latest"
+	cases := []struct {
+		name     string
+		spec     NodeObservabilitySpec
+		expected NodeObservabilitySpec
+	}{
+		{
+			name: "port defaulted",
+			spec: NodeObservabilitySpec{ServingCertSecretName: "certs", AgentImage: "mirror/agent:v1"},
+			expected: NodeObservabilitySpec{
+				Port:                  pointer.Int32(DefaultAgentPort),
+				ServingCertSecretName: "certs",
+				AgentImage:            "mirror/agent:v1",
+			},
+		},
+		{
+			name: "serving cert secret name defaulted",
+			spec: NodeObservabilitySpec{Port: pointer.Int32(9443), AgentImage: "mirror/agent:v1"},
+			expected: NodeObservabilitySpec{
+				Port:                  pointer.Int32(9443),
+				ServingCertSecretName: DefaultServingCertSecretName,
+				AgentImage:            "mirror/agent:v1",
+			},
+		},
+		{
+			name: "agent image defaulted",
+			spec: NodeObservabilitySpec{Port: pointer.Int32(9443), ServingCertSecretName: "certs"},
+			expected: NodeObservabilitySpec{
+				Port:                  pointer.Int32(9443),
+				ServingCertSecretName: "certs",
+				AgentImage:            agentImage,
+			},
+		},
+		{
+			name: "all fields defaulted",
+			expected: NodeObservabilitySpec{
+				Port:                  pointer.Int32(DefaultAgentPort),
+				ServingCertSecretName: DefaultServingCertSecretName,
+				AgentImage:            agentImage,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defaulter := &NodeObservabilityDefaulter{AgentImage: agentImage}
+			nodeObs := &NodeObservability{Spec: tc.spec}
+			if err := defaulter.Default(context.Background(), nodeObs); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(nodeObs.Spec, tc.expected) {
+				t.Fatalf("expected spec %+v, got %+v", tc.expected, nodeObs.Spec)
+			}
+
+			// the defaulting of an already defaulted spec changes nothing
+			defaulted := nodeObs.DeepCopy()
+			if err := defaulter.Default(context.Background(), nodeObs); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(nodeObs, defaulted) {
+				t.Fatalf("expected the defaulting to be idempotent, got %+v after %+v", nodeObs.Spec, defaulted.Spec)
+			}
+		})
+	}
+}
+
+func TestDefaultNotNodeObservability(t *testing.T) {
+	defaulter := &NodeObservabilityDefaulter{}
+	if err := defaulter.Default(context.Background(), &NodeObservabilityRun{}); err == nil {
+		t.Fatalf("expected error but got none")
+	}
+}
@@ -17,18 +17,76 @@ limitations under the License.
 package v1alpha2
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 // Important: Run "make" to regenerate code after modifying this file
 
+// +kubebuilder:validation:Enum=Immediate;Paused
+// MachineConfigRolloutStrategy defines how the machine config changes are rolled out on the nodes
+type MachineConfigRolloutStrategy string
+
+const (
+	// ImmediateMachineConfigRolloutStrategy updates the nodes as soon as the machine config is applied
+	ImmediateMachineConfigRolloutStrategy MachineConfigRolloutStrategy = "Immediate"
+	// PausedMachineConfigRolloutStrategy pauses the MachineConfigPool while the machine config is staged
+	PausedMachineConfigRolloutStrategy MachineConfigRolloutStrategy = "Paused"
+)
+
 // NodeObservabilityMachineConfigSpec defines the desired state of NodeObservabilityMachineConfig
 type NodeObservabilityMachineConfigSpec struct {
 	Debug NodeObservabilityDebug `json:"debug,omitempty"`
 	// +kubebuilder:validation:Required
 	// NodeSelector is a map of key:value pair that are used to match against node labels to be configured
 	NodeSelector map[string]string `json:"nodeSelector"`
+	// +optional
+	// RequiredNodeAffinity restricts the nodes to be configured
+	// to the ones matching at least one of its terms, in addition to the NodeSelector.
+	RequiredNodeAffinity *corev1.NodeSelector `json:"requiredNodeAffinity,omitempty"`
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	// NodePools splits the nodes to be configured into pools,
+	// each of them is configured by its own MachineConfigPool and MachineConfig.
+	// All the nodes are configured by the nodeobservability MachineConfigPool if not set.
+	NodePools []NodePool `json:"nodePools,omitempty"`
+	// +optional
+	// MachineConfigPoolName is the name of an existing MachineConfigPool which configures the nodes,
+	// the nodes are neither labeled nor configured by MachineConfigPools created by the operator.
+	// It must select all the nodes to be configured, except the master ones.
+	MachineConfigPoolName string `json:"machineConfigPoolName,omitempty"`
+	// +optional
+	// MachineConfigRolloutStrategy defines how the machine config changes are rolled out on the nodes.
+	// The following strategies are supported:
+	//   * Immediate - the nodes are updated as soon as the machine config is applied
+	//   * Paused - the MachineConfigPool is paused while the machine config is staged,
+	//     the nodes are updated once the pause duration elapsed or when the pool is unpaused manually
+	// Defaults to Immediate.
+	MachineConfigRolloutStrategy MachineConfigRolloutStrategy `json:"machineConfigRolloutStrategy,omitempty"`
+	// +optional
+	// MachineConfigRolloutPauseDuration is the time the MachineConfigPool stays paused
+	// with the Paused rollout strategy. Defaults to 1h.
+	MachineConfigRolloutPauseDuration *metav1.Duration `json:"machineConfigRolloutPauseDuration,omitempty"`
+	// +optional
+	// DryRun previews the machine config changes in the status without applying them,
+	// the nodes, MachineConfigs and MachineConfigPools are left untouched.
+	DryRun bool `json:"dryRun,omitempty"`
+	// +optional
+	// IncludeMasterNodes rolls out the CRI-O profiling on the master nodes
+	// through the master MachineConfigPool, the master nodes never join the profiling pools.
+	IncludeMasterNodes bool `json:"includeMasterNodes,omitempty"`
+}
+
+// MachineConfigPreview is a machine config change previewed in dry run
+type MachineConfigPreview struct {
+	// MachineConfigPool is the name of the MachineConfigPool which would roll out the change
+	MachineConfigPool string `json:"machineConfigPool"`
+	// MachineCount is the number of machines which would be rebooted by the rollout
+	MachineCount int32 `json:"machineCount"`
+	// MachineConfig is the MachineConfig which would be created, rendered in YAML
+	MachineConfig string `json:"machineConfig"`
 }
 
 // NodeObservabilityDebug is for holding the configurations defined for
@@ -47,6 +105,26 @@ type NodeObservabilityMachineConfigStatus struct {
 	// lastReconcile is the time of last reconciliation
 	// +nullable
 	LastReconcile metav1.Time `json:"lastReconcile"`
+
+	// machineConfigPoolUnpauseTime is the time at which
+	// the paused MachineConfigPool will be unpaused
+	// +optional
+	MachineConfigPoolUnpauseTime *metav1.Time `json:"machineConfigPoolUnpauseTime,omitempty"`
+
+	// pausedMachineConfigPools are the names of the MachineConfigPools
+	// paused by the operator, they are unpaused before the finalizer is removed
+	// +optional
+	PausedMachineConfigPools []string `json:"pausedMachineConfigPools,omitempty"`
+
+	// machineConfigPreview are the machine config changes
+	// which would be applied if dry run was not requested
+	// +optional
+	MachineConfigPreview []MachineConfigPreview `json:"machineConfigPreview,omitempty"`
+
+	// renderedHash is the hash of the desired profiling MachineConfigs,
+	// the live ones are re-applied when they differ from them
+	// +optional
+	RenderedHash string `json:"renderedHash,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,71 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"bytes"
+	"fmt"
+	gopath "path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultArtifactKeyTemplate is the layout of the stored profiles
+// when the run has no artifact key template
+const DefaultArtifactKeyTemplate = "{{.Namespace}}/{{.RunName}}/{{.Node}}/{{.Type}}.pprof"
+
+// ArtifactKeyData are the fields available to the artifact key template of a run
+// +kubebuilder:object:generate=false
+type ArtifactKeyData struct {
+	// Namespace is the namespace of the run
+	Namespace string
+	// RunName is the name of the run
+	RunName string
+	// Node is the name of the agent of the node
+	Node string
+	// Type is the name of the profile without its extension, e.g. kubelet or crio-heap
+	Type string
+	// Timestamp is the time the run started
+	Timestamp time.Time
+}
+
+// ParseArtifactKeyTemplate parses the given artifact key template,
+// the default template is used if empty.
+func ParseArtifactKeyTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultArtifactKeyTemplate
+	}
+	return template.New("artifactKey").Option("missingkey=error").Parse(text)
+}
+
+// RenderArtifactKey renders the key of a profile with the given template,
+// the key must be a relative path which doesn't go up the directory tree.
+func RenderArtifactKey(tmpl *template.Template, data ArtifactKeyData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	key := buf.String()
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("key %q doesn't name a file", key)
+	}
+	if gopath.IsAbs(key) || gopath.Clean(key) != key || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("key %q must be a clean relative path", key)
+	}
+	return key, nil
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha2
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,6 +27,369 @@ type NodeObservabilityRunSpec struct {
 
 	// NodeObservabilityRef is the reference to the parent NodeObservability resource
 	NodeObservabilityRef *NodeObservabilityRef `json:"nodeObservabilityRef"`
+
+	// +optional
+	// NodeSelector restricts the run to the agents running on the nodes matching all its labels,
+	// e.g. a label set on the nodes showing a high load. The agents of the other nodes
+	// are not contacted and not counted as failed. The agents keep running on all the nodes
+	// selected by the NodeObservability. Defaults to all the agents.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// +optional
+	// +listType=set
+	// Nodes restricts the run to the agents running on the listed nodes, e.g. to profile a single node.
+	// The run fails without being started if one of the nodes doesn't exist or has no running agent.
+	// May not be set with NodeSelector. Defaults to all the agents.
+	Nodes []string `json:"nodes,omitempty"`
+
+	// +optional
+	// Timeout is the maximum duration of the run.
+	// The agents which haven't finished the profiling when the timeout is reached
+	// are moved to the failed agents and the run is marked as failed.
+	// Defaults to 10 minutes.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// +optional
+	// ProfileDuration is the duration of the CPU profiles taken on each node,
+	// e.g. 120s for a longer CPU profile. Must be between 1s and 300s.
+	// Defaults to the duration of the agents, 30 seconds.
+	ProfileDuration metav1.Duration `json:"profileDuration,omitempty"`
+
+	// +optional
+	// ProfileTypes are the types of the profiles taken on each node:
+	//   * cpu - the CPU profile, taken over the profile duration
+	//   * heap - the memory allocations of the live objects
+	//   * goroutine - the stack traces of all the goroutines
+	//   * block - the stack traces of the goroutines blocked on synchronization primitives
+	// Each type is stored as a separate artifact named after the type.
+	// Defaults to cpu.
+	ProfileTypes []ProfileType `json:"profileTypes,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	// MaxRetries is the maximum number of times a failed request
+	// to the agent of a node is retried before the node is reported as failed.
+	// Defaults to 3.
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+
+	// +optional
+	// RetryBackoff is the delay before the first retry of a failed request
+	// to the agent of a node, the delay is doubled for each subsequent retry.
+	// Defaults to 1 second.
+	RetryBackoff metav1.Duration `json:"retryBackoff,omitempty"`
+
+	// +optional
+	// AgentRequestTimeout is the maximum duration of each request to the agent of a node,
+	// including the retrieval of its profiles, e.g. 5m for the slow nodes.
+	// Defaults to the profile duration plus the margin set on the operator, 30 seconds by default.
+	AgentRequestTimeout metav1.Duration `json:"agentRequestTimeout,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	// MaxConcurrentNodes is the maximum number of nodes profiled at the same time.
+	// The remaining nodes wait in the pending agents and start as soon as a node finishes.
+	// Defaults to 25.
+	MaxConcurrentNodes *int32 `json:"maxConcurrentNodes,omitempty"`
+
+	// +optional
+	// StorageBackend is the storage where the profiles are uploaded
+	// once the profiling is completed.
+	// When not set, the profiles are kept on the nodes.
+	StorageBackend *StorageBackend `json:"storageBackend,omitempty"`
+
+	// +optional
+	// CollectorEndpoint instructs the agents to push the profiles to an external collector
+	// once the profiling is completed, instead of keeping them for the operator to pull.
+	// Cannot be set with a storage backend.
+	CollectorEndpoint *CollectorEndpoint `json:"collectorEndpoint,omitempty"`
+
+	// +kubebuilder:validation:Enum=none;gzip
+	// +optional
+	// Compression is the compression of the profiles written into the storage backend:
+	//   * none - the profiles are stored as retrieved from the agents
+	//   * gzip - the profiles are gzipped, their names are suffixed with .gz, e.g. kubelet.pprof.gz
+	// Requires a storage backend.
+	// Defaults to none.
+	Compression ArtifactCompression `json:"compression,omitempty"`
+
+	// +optional
+	// Symbolize requests self-contained profiles: the agents supporting it resolve the addresses
+	// of the profiles with the binaries of the node and keep the build IDs of the binaries
+	// for a later symbolization. The profiles uploaded by the operator are inspected,
+	// whether they are symbolized and the build IDs of their binaries are reported in their artifacts.
+	// The symbolized profiles are larger.
+	Symbolize bool `json:"symbolize,omitempty"`
+
+	// +optional
+	// MaxArtifactSize is the maximum size of each profile retrieved from the agents, e.g. 100Mi.
+	// The retrieval of a larger profile is aborted and its node is marked as failed
+	// instead of filling the storage. Requires a storage backend.
+	// Unlimited by default.
+	MaxArtifactSize *resource.Quantity `json:"maxArtifactSize,omitempty"`
+
+	// +optional
+	// ArtifactTTL is the time the profiles are kept in the storage backend after the completion of the run, e.g. 720h.
+	// The profiles of all the nodes are deleted from the storage backend once it elapses,
+	// and no longer listed in the status. Requires a storage backend.
+	// The profiles are kept forever by default.
+	ArtifactTTL metav1.Duration `json:"artifactTTL,omitempty"`
+
+	// +optional
+	// ArtifactKeyTemplate is the Go template of the keys of the profiles in the storage backend:
+	// the object keys with the S3 storage backend, the paths in the claim with the PVC storage backend.
+	// The available fields are .Namespace and .RunName of the run, .Node the name of the agent,
+	// .Type the name of the profile without its extension, e.g. kubelet or crio-heap,
+	// and .Timestamp the start time of the run, e.g. profiles/{{.Timestamp.Format "2006-01-02"}}/{{.Node}}/{{.Type}}.pprof.
+	// The keys must be relative paths, suffixed with .gz when the profiles are gzipped.
+	// Requires a storage backend.
+	// Defaults to {{.Namespace}}/{{.RunName}}/{{.Node}}/{{.Type}}.pprof.
+	ArtifactKeyTemplate string `json:"artifactKeyTemplate,omitempty"`
+
+	// +kubebuilder:validation:Enum=Queue;Reject
+	// +optional
+	// ConcurrencyPolicy specifies how to treat the run
+	// when another run of the same NodeObservability is active:
+	//   * Queue - the run waits for the active runs to finish, in the order of creation
+	//   * Reject - the run is marked as rejected and never started
+	// The finished runs, successful or failed, do not block the new runs.
+	// Defaults to Queue.
+	ConcurrencyPolicy RunConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// +kubebuilder:validation:Enum=AllOrNothing;BestEffort
+	// +optional
+	// FailurePolicy specifies how the failed nodes affect the result of the run:
+	//   * AllOrNothing - the run fails if the profiling fails on any node
+	//   * BestEffort - the run succeeds if the profiling succeeds on at least
+	//     MinSucceededNodesPercent of the targeted nodes, the failed nodes are listed in the failed agents
+	// Defaults to AllOrNothing.
+	FailurePolicy RunFailurePolicy `json:"failurePolicy,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	// MinSucceededNodesPercent is the minimum percentage of the targeted nodes
+	// on which the profiling must succeed for the run to succeed with the BestEffort failure policy.
+	// The run never succeeds if the profiling failed on all the nodes.
+	// Ignored with the AllOrNothing failure policy.
+	// Defaults to 0.
+	MinSucceededNodesPercent *int32 `json:"minSucceededNodesPercent,omitempty"`
+
+	// +optional
+	// InsecureSkipTLSVerify skips the verification of the serving certificates of the agents.
+	// Strictly for debugging: the profiles are retrieved from whoever answers on the agent addresses.
+	// Defaults to false.
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+
+	// +optional
+	// ServiceCABundleRef is the reference to the config map holding the service CA bundle
+	// verifying the serving certificates of the agents in the service-ca.crt key,
+	// for the clusters injecting the service CA bundle into another config map.
+	// The config map must be in the namespace of the NodeObservabilityRun,
+	// the run waits until it exists.
+	// Defaults to the openshift-service-ca.crt config map.
+	ServiceCABundleRef *corev1.LocalObjectReference `json:"serviceCABundleRef,omitempty"`
+
+	// +optional
+	// AgentAuth is the authentication presented to the agents on each request of the operator,
+	// for the agents behind an authenticating proxy.
+	AgentAuth *AgentAuth `json:"agentAuth,omitempty"`
+
+	// +optional
+	// PodLabels are the labels set on the collector pods writing the profiles
+	// into the persistent volume claim of the PVC storage backend.
+	// The keys of the nodeobservability.olm.openshift.io domain are reserved for the operator.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+
+	// +optional
+	// PodAnnotations are the annotations set on the collector pods writing the profiles
+	// into the persistent volume claim of the PVC storage backend.
+	// The keys of the nodeobservability.olm.openshift.io domain are reserved for the operator.
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// +optional
+	// SkipMachineConfigRolloutCheck starts the run as soon as the agents are ready,
+	// without waiting for the machine config changes enabling the CRI-O profiling
+	// to be rolled out on all the nodes. The CRI-O profiles of the nodes
+	// which are not updated yet are empty or fail.
+	// Defaults to false.
+	SkipMachineConfigRolloutCheck bool `json:"skipMachineConfigRolloutCheck,omitempty"`
+
+	// +optional
+	// Cancel aborts the run: the requests in flight to the agents are aborted,
+	// the nodes which haven't finished the profiling are moved to the cancelled agents
+	// and the run ends in the Cancelled phase. The profiles of the nodes which finished are stored.
+	// A run cancelled before it starts never profiles the nodes.
+	// May not be unset once set. Defaults to false.
+	Cancel bool `json:"cancel,omitempty"`
+}
+
+// ProfileType is the type of a profile taken by the agents
+// +kubebuilder:validation:Enum=cpu;heap;goroutine;block
+type ProfileType string
+
+const (
+	// CPUProfileType is the CPU profile
+	CPUProfileType ProfileType = "cpu"
+	// HeapProfileType is the profile of the memory allocations of the live objects
+	HeapProfileType ProfileType = "heap"
+	// GoroutineProfileType is the profile of the stack traces of all the goroutines
+	GoroutineProfileType ProfileType = "goroutine"
+	// BlockProfileType is the profile of the goroutines blocked on synchronization primitives
+	BlockProfileType ProfileType = "block"
+)
+
+// RunFailurePolicy describes how the failed nodes affect the result of a run.
+type RunFailurePolicy string
+
+const (
+	// AllOrNothingFailurePolicy fails the run if any node fails
+	AllOrNothingFailurePolicy RunFailurePolicy = "AllOrNothing"
+	// BestEffortFailurePolicy succeeds the run if enough nodes succeed
+	BestEffortFailurePolicy RunFailurePolicy = "BestEffort"
+)
+
+// RunConcurrencyPolicy describes how a run is handled
+// when another run of the same NodeObservability is active.
+type RunConcurrencyPolicy string
+
+const (
+	// QueueConcurrentRun starts the run once the active runs are finished
+	QueueConcurrentRun RunConcurrencyPolicy = "Queue"
+	// RejectConcurrentRun rejects the run if another run is active
+	RejectConcurrentRun RunConcurrencyPolicy = "Reject"
+)
+
+// ArtifactCompression is the compression of the stored profiles
+type ArtifactCompression string
+
+const (
+	// NoArtifactCompression stores the profiles uncompressed
+	NoArtifactCompression ArtifactCompression = "none"
+	// GzipArtifactCompression gzips the profiles
+	GzipArtifactCompression ArtifactCompression = "gzip"
+)
+
+// StorageBackendType is the type of the storage of the profiles
+// +kubebuilder:validation:Enum=S3;PVC
+type StorageBackendType string
+
+const (
+	// S3StorageBackendType uploads the profiles to an S3-compatible bucket
+	S3StorageBackendType StorageBackendType = "S3"
+	// PVCStorageBackendType writes the profiles into a persistent volume claim
+	PVCStorageBackendType StorageBackendType = "PVC"
+)
+
+// StorageBackend is the storage where the profiles are uploaded
+type StorageBackend struct {
+	// Type is the type of the storage backend
+	Type StorageBackendType `json:"type"`
+
+	// +optional
+	// S3 is the configuration of the S3 storage backend,
+	// required when the type is S3
+	S3 *S3StorageBackend `json:"s3,omitempty"`
+
+	// +optional
+	// PVC is the configuration of the PVC storage backend,
+	// required when the type is PVC
+	PVC *PVCStorageBackend `json:"pvc,omitempty"`
+}
+
+// S3StorageBackend is an S3-compatible bucket
+type S3StorageBackend struct {
+	// +optional
+	// Endpoint is the URL of the S3-compatible service.
+	// Defaults to the AWS S3 endpoint of the region.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +kubebuilder:validation:MinLength=3
+	// Bucket is the name of the bucket where the profiles are uploaded
+	Bucket string `json:"bucket"`
+
+	// +optional
+	// Region is the region of the bucket.
+	// Defaults to us-east-1.
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecretRef is the reference to the secret holding the credentials
+	// to access the bucket in the aws_access_key_id and aws_secret_access_key keys.
+	// The secret must be in the namespace of the NodeObservabilityRun.
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+
+	// +optional
+	// ForcePathStyle addresses the bucket in the path of the URL
+	// instead of the host name, as required by MinIO.
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+
+	// +optional
+	// CABundleRef is the reference to the config map holding the CA bundle
+	// to trust in addition to the system ones in the ca-bundle.crt key.
+	// The config map must be in the namespace of the NodeObservabilityRun.
+	CABundleRef *corev1.LocalObjectReference `json:"caBundleRef,omitempty"`
+
+	// +kubebuilder:validation:Enum=Environment;None
+	// +optional
+	// ProxyPolicy specifies how the uploads reach the endpoint:
+	//   * Environment - through the proxy set in the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	//     environment variables of the operator, e.g. the cluster-wide proxy injected by OLM
+	//   * None - directly, e.g. for a bucket served from within the cluster
+	// Defaults to Environment.
+	ProxyPolicy ProxyPolicy `json:"proxyPolicy,omitempty"`
+
+	// +optional
+	// TrustClusterProxyCA trusts the CA bundle of the cluster-wide Proxy
+	// in addition to the system ones, e.g. when the proxy intercepts the TLS connections.
+	TrustClusterProxyCA bool `json:"trustClusterProxyCA,omitempty"`
+}
+
+// ProxyPolicy describes how the uploads to the storage backend reach its endpoint.
+type ProxyPolicy string
+
+const (
+	// EnvironmentProxyPolicy uses the proxy set in the environment of the operator
+	EnvironmentProxyPolicy ProxyPolicy = "Environment"
+	// NoProxyPolicy connects directly to the endpoint
+	NoProxyPolicy ProxyPolicy = "None"
+)
+
+// PVCStorageBackend is a persistent volume claim
+type PVCStorageBackend struct {
+	// +kubebuilder:validation:MinLength=1
+	// ClaimName is the name of the persistent volume claim where the profiles are written.
+	// The claim must be in the namespace of the NodeObservabilityRun.
+	// The profiles are collected by a single pod which mounts the claim,
+	// the collectors of the runs sharing a ReadWriteOnce claim are run one after the other.
+	ClaimName string `json:"claimName"`
+}
+
+// CollectorEndpoint is an external collector the agents push the profiles to
+type CollectorEndpoint struct {
+	// +kubebuilder:validation:MinLength=1
+	// URL is the https URL the agents POST the profiles to
+	URL string `json:"url"`
+
+	// +optional
+	// BearerTokenSecretRef is the reference to the secret holding the bearer token
+	// presented by the agents to the collector in the token key.
+	// The secret must be in the namespace of the NodeObservabilityRun.
+	BearerTokenSecretRef *corev1.LocalObjectReference `json:"bearerTokenSecretRef,omitempty"`
+}
+
+// AgentAuth is the bearer token and the headers sent to the agents,
+// their values are read from a secret and never written to the status or the logs
+type AgentAuth struct {
+	// SecretRef is the reference to the secret holding the bearer token in the token key
+	// and the values of the headers.
+	// The bearer token replaces the one of the operator in the Authorization header.
+	// The secret must be in the namespace of the NodeObservabilityRun.
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// +optional
+	// Headers maps the name of each header sent to the agents
+	// to the key of the secret holding its value.
+	// The Authorization header is set with the bearer token only.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // NodeObservabilityRef is the reference to the parent NodeObservability resource
@@ -35,6 +400,15 @@ type NodeObservabilityRef struct {
 
 // NodeObservabilityRunStatus defines the observed state of NodeObservabilityRun
 type NodeObservabilityRunStatus struct {
+	// Phase is the overall state of the NodeObservabilityRun:
+	//   * Pending - the run hasn't started yet
+	//   * Running - the profiling is in progress on the nodes
+	//   * Succeeded - the profiling finished on the targeted nodes as required by the FailurePolicy
+	//   * Failed - the run was rejected or the profiling failed on more nodes than allowed by the FailurePolicy
+	//   * Cancelled - the run was cancelled before all the nodes finished the profiling
+	// The start and completion times are StartTimestamp and FinishedTimestamp.
+	Phase NodeObservabilityRunPhase `json:"phase,omitempty"`
+
 	// StartTimestamp represents the server time when the NodeObservabilityRun started.
 	// When not set, the NodeObservabilityRun hasn't started.
 	// It is represented in RFC3339 form and is in UTC.
@@ -53,21 +427,181 @@ type NodeObservabilityRunStatus struct {
 	// This could be due to Node/Pod/Network failure
 	FailedAgents []AgentNode `json:"failedAgents,omitempty"`
 
+	// PendingAgents represents the list of Nodes waiting for the profiling to start,
+	// bounded by the MaxConcurrentNodes of the run.
+	PendingAgents []AgentNode `json:"pendingAgents,omitempty"`
+
+	// CancelledAgents represents the list of Nodes which hadn't finished the profiling
+	// when the Run was cancelled, their profiles are not stored.
+	CancelledAgents []AgentNode `json:"cancelledAgents,omitempty"`
+
+	// PeakConcurrentNodes is the highest number of nodes profiled at the same time by this Run
+	PeakConcurrentNodes int32 `json:"peakConcurrentNodes,omitempty"`
+
+	// TotalNodes is the number of nodes targeted by this Run
+	TotalNodes int32 `json:"totalNodes,omitempty"`
+
+	// SelectedNodes are the names of the nodes matching the NodeSelector of this Run
+	// when it started or listed in its Nodes, the agents of the other nodes were skipped.
+	// When not set, the Run has neither NodeSelector nor Nodes.
+	SelectedNodes []string `json:"selectedNodes,omitempty"`
+
+	// FinishedNodes is the number of targeted nodes which either
+	// finished the profiling or failed
+	FinishedNodes int32 `json:"finishedNodes,omitempty"`
+
+	// Duration is the time the run took from its start to its completion.
+	// When not set, the NodeObservabilityRun hasn't finished.
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Summary aggregates the profiling of the nodes which finished it,
+	// updated as the nodes finish. When not set, no node finished the profiling yet.
+	Summary *RunSummary `json:"summary,omitempty"`
+
 	// Conditions contain details for aspects of the current state of this API Resource.
 	ConditionalStatus `json:"conditions,omitempty"`
 
+	// ProfilingType is the type of the profiling run on the agents,
+	// taken from the NodeObservability when the run started.
+	ProfilingType NodeObservabilityType `json:"profilingType,omitempty"`
+
 	// Output is the output location of this NodeObservabilityRun
 	// When not set, no output location is known
 	Output *string `json:"output,omitempty"`
+
+	// ArtifactsExpired is true once the profiles were deleted from the storage backend
+	// after the artifact TTL of the run elapsed.
+	ArtifactsExpired bool `json:"artifactsExpired,omitempty"`
+}
+
+// RunSummary aggregates the profiling of the nodes of a run
+type RunSummary struct {
+	// SucceededNodes is the number of nodes on which the profiling succeeded
+	SucceededNodes int32 `json:"succeededNodes"`
+	// FailedNodes is the number of nodes on which the profiling failed
+	FailedNodes int32 `json:"failedNodes"`
+	// MinDuration is the shortest time a node took to finish or fail the profiling
+	MinDuration *metav1.Duration `json:"minDuration,omitempty"`
+	// MinDurationNode is the name of the node which took the shortest time
+	MinDurationNode string `json:"minDurationNode,omitempty"`
+	// MaxDuration is the longest time a node took to finish or fail the profiling
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+	// MaxDurationNode is the name of the node which took the longest time
+	MaxDurationNode string `json:"maxDurationNode,omitempty"`
+	// AverageDuration is the average time the nodes took to finish or fail the profiling
+	AverageDuration *metav1.Duration `json:"averageDuration,omitempty"`
+	// TotalBytes is the size in bytes of the profiles collected from the nodes as stored,
+	// only the profiles whose size is known to the operator are counted
+	TotalBytes int64 `json:"totalBytes,omitempty"`
 }
 
 type AgentNode struct {
 	Name string `json:"name,omitempty"`
 	IP   string `json:"ip,omitempty"`
 	Port int32  `json:"port,omitempty"`
+	// NodeName is the name of the node the agent runs on
+	NodeName string `json:"nodeName,omitempty"`
+	// StartTimestamp is the time the profiling started on the node
+	StartTimestamp *metav1.Time `json:"startTimestamp,omitempty"`
+	// FinishedTimestamp is the time the profiling finished or failed on the node
+	FinishedTimestamp *metav1.Time `json:"finishedTimestamp,omitempty"`
+	// Result is the result of the profiling on the node
+	Result AgentResult `json:"result,omitempty"`
+	// Attempts is the number of requests sent to the agent to start the profiling
+	Attempts int32 `json:"attempts,omitempty"`
+	// ProfileDuration is the duration of the CPU profiles requested to the agent
+	ProfileDuration *metav1.Duration `json:"profileDuration,omitempty"`
+	// ObjectKeys are the keys of the profiles of the node uploaded to the storage backend
+	ObjectKeys []string `json:"objectKeys,omitempty"`
+	// Path is the directory of the profiles of the node in the persistent volume claim
+	Path string `json:"path,omitempty"`
+	// Artifacts are the profiles produced on the node and their location,
+	// listed once the profiling finished on the node and the profiles are stored
+	Artifacts []ProfileArtifact `json:"artifacts,omitempty"`
+	// PendingUpload is set while the upload of the profiles of the node
+	// to the storage backend is retried after a transient failure
+	PendingUpload *PendingUpload `json:"pendingUpload,omitempty"`
 }
 
+// PendingUpload is the retry state of the upload of the profiles of a node
+type PendingUpload struct {
+	// Attempts is the number of failed uploads
+	Attempts int32 `json:"attempts"`
+	// LastError is the error of the last failed upload
+	LastError string `json:"lastError,omitempty"`
+	// NextAttemptTime is the earliest time of the next upload
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+}
+
+// ProfileArtifact is a profile produced by the agent of a node
+type ProfileArtifact struct {
+	// Name is the name of the profile, e.g. kubelet.pprof
+	Name string `json:"name"`
+	// Size is the size of the profile in bytes as stored, when known to the operator
+	Size *int64 `json:"size,omitempty"`
+	// UncompressedSize is the size of the profile in bytes before its compression,
+	// when the profile is compressed and its size is known to the operator
+	UncompressedSize *int64 `json:"uncompressedSize,omitempty"`
+	// Compression is the compression of the stored profile,
+	// the profile is uncompressed when not set
+	Compression ArtifactCompression `json:"compression,omitempty"`
+	// Symbolized is true if all the addresses of the profile are resolved to functions,
+	// reported when the run requests symbolized profiles and the profile is read by the operator
+	Symbolized *bool `json:"symbolized,omitempty"`
+	// BuildIDs are the build IDs of the binaries mapped in the profile to symbolize it later,
+	// reported when the run requests symbolized profiles and the profile is read by the operator
+	BuildIDs []string `json:"buildIDs,omitempty"`
+	// URI is the location of the profile:
+	//   * the URL of the object with the S3 storage backend
+	//   * pvc://<claim name>/<path> with the PVC storage backend
+	//   * the URL of the profile on the agent without a storage backend
+	URI string `json:"uri,omitempty"`
+}
+
+// NodeObservabilityRunPhase is the overall state of a run
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Cancelled
+type NodeObservabilityRunPhase string
+
+const (
+	// RunPending means that the run hasn't started yet
+	RunPending NodeObservabilityRunPhase = "Pending"
+	// RunRunning means that the profiling is in progress on the nodes
+	RunRunning NodeObservabilityRunPhase = "Running"
+	// RunSucceeded means that the profiling finished on the targeted nodes as required by the failure policy
+	RunSucceeded NodeObservabilityRunPhase = "Succeeded"
+	// RunFailed means that the profiling failed on more nodes than allowed by the failure policy
+	RunFailed NodeObservabilityRunPhase = "Failed"
+	// RunCancelled means that the run was cancelled before all the nodes finished the profiling
+	RunCancelled NodeObservabilityRunPhase = "Cancelled"
+)
+
+// AgentResult is the result of the profiling on a node
+// +kubebuilder:validation:Enum=Dispatched;Running;PendingUpload;Succeeded;Failed;Cancelled
+type AgentResult string
+
+const (
+	// AgentDispatched means that the request starting the profiling is being sent to the agent,
+	// the agent is checked for a profiling in progress when the run is resumed after a restart of the operator
+	AgentDispatched AgentResult = "Dispatched"
+	// AgentRunning means that the profiling is in progress on the node
+	AgentRunning AgentResult = "Running"
+	// AgentPendingUpload means that the profiling finished on the node
+	// and the upload of its profiles to the storage backend is retried
+	AgentPendingUpload AgentResult = "PendingUpload"
+	// AgentSucceeded means that the profiling finished on the node
+	AgentSucceeded AgentResult = "Succeeded"
+	// AgentFailed means that the profiling or the storage of the profiles failed on the node
+	AgentFailed AgentResult = "Failed"
+	// AgentCancelled means that the run was cancelled before the profiling finished on the node
+	AgentCancelled AgentResult = "Cancelled"
+)
+
 // +kubebuilder:printcolumn:JSONPath=".spec.nodeObservabilityRef.name", name="NodeObservabilityRef", type="string"
+// +kubebuilder:printcolumn:JSONPath=".status.phase", name="Phase", type="string"
+// +kubebuilder:printcolumn:JSONPath=".status.finishedNodes", name="Finished", type="integer"
+// +kubebuilder:printcolumn:JSONPath=".status.totalNodes", name="Total", type="integer"
+// +kubebuilder:printcolumn:JSONPath=".status.duration", name="Duration", type="string"
+// +kubebuilder:printcolumn:JSONPath=".metadata.creationTimestamp", name="Age", type="date"
 // +kubebuilder:resource:shortName=nobr
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
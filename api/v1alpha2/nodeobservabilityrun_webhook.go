@@ -0,0 +1,261 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// supportedProfileTypes are the types of the profiles the agents can take
+var supportedProfileTypes = []string{
+	string(CPUProfileType),
+	string(HeapProfileType),
+	string(GoroutineProfileType),
+	string(BlockProfileType),
+}
+
+// reservedKeyDomain is the domain of the label and annotation keys reserved for the operator
+const reservedKeyDomain = "nodeobservability.olm.openshift.io"
+
+func (r *NodeObservabilityRun) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-nodeobservability-olm-openshift-io-v1alpha2-nodeobservabilityrun,mutating=false,failurePolicy=fail,sideEffects=None,groups=nodeobservability.olm.openshift.io,resources=nodeobservabilityruns,verbs=create;update,versions=v1alpha2,name=vnodeobservabilityrun.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &NodeObservabilityRun{}
+
+// ValidateCreate implements webhook.Validator
+func (r *NodeObservabilityRun) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (r *NodeObservabilityRun) ValidateUpdate(old runtime.Object) error {
+	if oldRun, ok := old.(*NodeObservabilityRun); ok && oldRun.Spec.Cancel && !r.Spec.Cancel {
+		errs := field.ErrorList{field.Forbidden(field.NewPath("spec", "cancel"), "may not be unset once the run is cancelled")}
+		return apierrors.NewInvalid(GroupVersion.WithKind("NodeObservabilityRun").GroupKind(), r.Name, errs)
+	}
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator
+func (r *NodeObservabilityRun) ValidateDelete() error {
+	return nil
+}
+
+func (r *NodeObservabilityRun) validate() error {
+	errs := r.Spec.validate(field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("NodeObservabilityRun").GroupKind(), r.Name, errs)
+}
+
+func (s *NodeObservabilityRunSpec) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	errs = append(errs, metav1validation.ValidateLabels(s.NodeSelector, path.Child("nodeSelector"))...)
+	nodes := map[string]struct{}{}
+	for i, node := range s.Nodes {
+		for _, msg := range validation.IsDNS1123Subdomain(node) {
+			errs = append(errs, field.Invalid(path.Child("nodes").Index(i), node, msg))
+		}
+		if _, ok := nodes[node]; ok {
+			errs = append(errs, field.Duplicate(path.Child("nodes").Index(i), node))
+		}
+		nodes[node] = struct{}{}
+	}
+	if len(s.Nodes) > 0 && len(s.NodeSelector) > 0 {
+		errs = append(errs, field.Forbidden(path.Child("nodes"), "may not be set with a node selector"))
+	}
+
+	types := map[ProfileType]struct{}{}
+	for i, profileType := range s.ProfileTypes {
+		if !contains(supportedProfileTypes, string(profileType)) {
+			errs = append(errs, field.NotSupported(path.Child("profileTypes").Index(i), profileType, supportedProfileTypes))
+			continue
+		}
+		if _, ok := types[profileType]; ok {
+			errs = append(errs, field.Duplicate(path.Child("profileTypes").Index(i), profileType))
+		}
+		types[profileType] = struct{}{}
+	}
+	if s.Compression != "" && s.Compression != NoArtifactCompression && s.StorageBackend == nil {
+		errs = append(errs, field.Forbidden(path.Child("compression"), "may only be set with a storage backend"))
+	}
+	if s.MaxArtifactSize != nil {
+		if s.MaxArtifactSize.Sign() <= 0 {
+			errs = append(errs, field.Invalid(path.Child("maxArtifactSize"), s.MaxArtifactSize.String(), "must be positive"))
+		}
+		if s.StorageBackend == nil {
+			errs = append(errs, field.Forbidden(path.Child("maxArtifactSize"), "may only be set with a storage backend"))
+		}
+	}
+	collectorPods := s.StorageBackend != nil && s.StorageBackend.Type == PVCStorageBackendType
+	if len(s.PodLabels) > 0 {
+		errs = append(errs, metav1validation.ValidateLabels(s.PodLabels, path.Child("podLabels"))...)
+		errs = append(errs, validateUnreservedKeys(s.PodLabels, path.Child("podLabels"))...)
+		if !collectorPods {
+			errs = append(errs, field.Forbidden(path.Child("podLabels"), "may only be set with the PVC storage backend"))
+		}
+	}
+	if len(s.PodAnnotations) > 0 {
+		errs = append(errs, apivalidation.ValidateAnnotations(s.PodAnnotations, path.Child("podAnnotations"))...)
+		errs = append(errs, validateUnreservedKeys(s.PodAnnotations, path.Child("podAnnotations"))...)
+		if !collectorPods {
+			errs = append(errs, field.Forbidden(path.Child("podAnnotations"), "may only be set with the PVC storage backend"))
+		}
+	}
+	if s.AgentRequestTimeout.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("agentRequestTimeout"), s.AgentRequestTimeout.Duration.String(), "must be positive"))
+	}
+	if s.ArtifactTTL.Duration != 0 {
+		if s.ArtifactTTL.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("artifactTTL"), s.ArtifactTTL.Duration.String(), "must be positive"))
+		}
+		if s.StorageBackend == nil {
+			errs = append(errs, field.Forbidden(path.Child("artifactTTL"), "may only be set with a storage backend"))
+		}
+	}
+	if s.ArtifactKeyTemplate != "" {
+		errs = append(errs, s.validateArtifactKeyTemplate(path.Child("artifactKeyTemplate"))...)
+	}
+	if s.CollectorEndpoint != nil {
+		errs = append(errs, s.CollectorEndpoint.validate(path.Child("collectorEndpoint"))...)
+		if s.StorageBackend != nil {
+			errs = append(errs, field.Forbidden(path.Child("collectorEndpoint"), "may not be set with a storage backend"))
+		}
+	}
+	if s.AgentAuth != nil {
+		errs = append(errs, s.AgentAuth.validate(path.Child("agentAuth"))...)
+	}
+	return errs
+}
+
+// validateArtifactKeyTemplate parses the artifact key template and renders it with sample values
+// to catch the unknown fields, the invalid keys and the keys shared by several profiles.
+func (s *NodeObservabilityRunSpec) validateArtifactKeyTemplate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if s.StorageBackend == nil {
+		errs = append(errs, field.Forbidden(path, "may only be set with a storage backend"))
+	}
+	tmpl, err := ParseArtifactKeyTemplate(s.ArtifactKeyTemplate)
+	if err != nil {
+		return append(errs, field.Invalid(path, s.ArtifactKeyTemplate, err.Error()))
+	}
+	now := time.Now()
+	keys := map[string]struct{}{}
+	for _, sample := range []struct{ node, profile string }{{"node-a", "kubelet"}, {"node-b", "kubelet"}, {"node-a", "crio"}} {
+		key, err := RenderArtifactKey(tmpl, ArtifactKeyData{
+			Namespace: "namespace",
+			RunName:   "run",
+			Node:      sample.node,
+			Type:      sample.profile,
+			Timestamp: now,
+		})
+		if err != nil {
+			return append(errs, field.Invalid(path, s.ArtifactKeyTemplate, err.Error()))
+		}
+		keys[key] = struct{}{}
+	}
+	if len(keys) != 3 {
+		errs = append(errs, field.Invalid(path, s.ArtifactKeyTemplate, "must include the .Node and .Type fields for each profile to have its own key"))
+	}
+	return errs
+}
+
+// validateUnreservedKeys rejects the keys of the domain reserved for the operator,
+// the collector pods are tracked with them.
+func validateUnreservedKeys(m map[string]string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		i := strings.Index(k, "/")
+		if i < 0 {
+			continue
+		}
+		if prefix := k[:i]; prefix == reservedKeyDomain || strings.HasSuffix(prefix, "."+reservedKeyDomain) {
+			errs = append(errs, field.Invalid(path, k, "the keys of the "+reservedKeyDomain+" domain are reserved"))
+		}
+	}
+	return errs
+}
+
+func (c *CollectorEndpoint) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	u, err := neturl.Parse(c.URL)
+	if err != nil {
+		return append(errs, field.Invalid(path.Child("url"), c.URL, err.Error()))
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("url"), c.URL, "must be an https URL"))
+	}
+	if c.BearerTokenSecretRef != nil && c.BearerTokenSecretRef.Name == "" {
+		errs = append(errs, field.Required(path.Child("bearerTokenSecretRef", "name"), "the name of the secret holding the bearer token is required"))
+	}
+	return errs
+}
+
+// validate requires valid header names and secret keys,
+// the Authorization header can only be set with the bearer token.
+func (a *AgentAuth) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if a.SecretRef.Name == "" {
+		errs = append(errs, field.Required(path.Child("secretRef", "name"), "the name of the secret holding the bearer token and the headers is required"))
+	}
+	names := make([]string, 0, len(a.Headers))
+	for name := range a.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, msg := range validation.IsHTTPHeaderName(name) {
+			errs = append(errs, field.Invalid(path.Child("headers"), name, msg))
+		}
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			errs = append(errs, field.Invalid(path.Child("headers"), name, "the Authorization header is set with the bearer token of the secret"))
+		}
+		for _, msg := range validation.IsConfigMapKey(a.Headers[name]) {
+			errs = append(errs, field.Invalid(path.Child("headers").Key(name), a.Headers[name], msg))
+		}
+	}
+	return errs
+}
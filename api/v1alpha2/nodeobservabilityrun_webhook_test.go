@@ -0,0 +1,345 @@
+package v1alpha2
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateNodeObservabilityRun(t *testing.T) {
+	cases := []struct {
+		name              string
+		profileTypes      []ProfileType
+		collectorEndpoint *CollectorEndpoint
+		agentAuth         *AgentAuth
+		storageBackend    *StorageBackend
+		compression       ArtifactCompression
+		maxArtifactSize   string
+		artifactTTL       time.Duration
+		requestTimeout    time.Duration
+		keyTemplate       string
+		nodeSelector      map[string]string
+		nodes             []string
+		podLabels         map[string]string
+		podAnnotations    map[string]string
+		expectedMessages  []string
+	}{
+		{
+			name: "default profile types",
+		},
+		{
+			name:         "all profile types",
+			profileTypes: []ProfileType{CPUProfileType, HeapProfileType, GoroutineProfileType, BlockProfileType},
+		},
+		{
+			name:         "node selector",
+			nodeSelector: map[string]string{"load": "high"},
+		},
+		{
+			name:             "invalid node selector",
+			nodeSelector:     map[string]string{"load": "very high"},
+			expectedMessages: []string{`spec.nodeSelector: Invalid value: "very high"`},
+		},
+		{
+			name:  "nodes",
+			nodes: []string{"worker-a", "worker-b"},
+		},
+		{
+			name:             "invalid node name",
+			nodes:            []string{"worker-a", "Worker_B"},
+			expectedMessages: []string{`spec.nodes[1]: Invalid value: "Worker_B"`},
+		},
+		{
+			name:             "duplicate node",
+			nodes:            []string{"worker-a", "worker-a"},
+			expectedMessages: []string{`spec.nodes[1]: Duplicate value: "worker-a"`},
+		},
+		{
+			name:             "nodes with node selector",
+			nodeSelector:     map[string]string{"load": "high"},
+			nodes:            []string{"worker-a"},
+			expectedMessages: []string{"spec.nodes: Forbidden: may not be set with a node selector"},
+		},
+		{
+			name:             "unknown profile type",
+			profileTypes:     []ProfileType{CPUProfileType, "mutex"},
+			expectedMessages: []string{`spec.profileTypes[1]: Unsupported value: "mutex": supported values: "cpu", "heap", "goroutine", "block"`},
+		},
+		{
+			name:             "duplicate profile type",
+			profileTypes:     []ProfileType{HeapProfileType, CPUProfileType, HeapProfileType},
+			expectedMessages: []string{`spec.profileTypes[2]: Duplicate value: "heap"`},
+		},
+		{
+			name: "collector endpoint",
+			collectorEndpoint: &CollectorEndpoint{
+				URL:                  "https://collector.example.com/profiles",
+				BearerTokenSecretRef: &corev1.LocalObjectReference{Name: "collector-token"},
+			},
+		},
+		{
+			name:              "plain http collector endpoint",
+			collectorEndpoint: &CollectorEndpoint{URL: "http://collector.example.com/profiles"},
+			expectedMessages:  []string{`spec.collectorEndpoint.url: Invalid value: "http://collector.example.com/profiles": must be an https URL`},
+		},
+		{
+			name:              "collector endpoint without host",
+			collectorEndpoint: &CollectorEndpoint{URL: "https:///profiles"},
+			expectedMessages:  []string{"spec.collectorEndpoint.url: Invalid value", "must be an https URL"},
+		},
+		{
+			name: "collector endpoint with an empty token secret name",
+			collectorEndpoint: &CollectorEndpoint{
+				URL:                  "https://collector.example.com/profiles",
+				BearerTokenSecretRef: &corev1.LocalObjectReference{},
+			},
+			expectedMessages: []string{"spec.collectorEndpoint.bearerTokenSecretRef.name: Required value"},
+		},
+		{
+			name:              "collector endpoint with storage backend",
+			collectorEndpoint: &CollectorEndpoint{URL: "https://collector.example.com/profiles"},
+			storageBackend:    &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			expectedMessages:  []string{"spec.collectorEndpoint: Forbidden: may not be set with a storage backend"},
+		},
+		{
+			name: "agent auth",
+			agentAuth: &AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: "agent-auth"},
+				Headers:   map[string]string{"X-Proxy-Tenant": "tenant"},
+			},
+		},
+		{
+			name:             "agent auth with an empty secret name",
+			agentAuth:        &AgentAuth{},
+			expectedMessages: []string{"spec.agentAuth.secretRef.name: Required value"},
+		},
+		{
+			name: "agent auth with an invalid header",
+			agentAuth: &AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: "agent-auth"},
+				Headers:   map[string]string{"X Proxy": "tenant", "X-Proxy-Tenant": "tenant/id"},
+			},
+			expectedMessages: []string{
+				`spec.agentAuth.headers: Invalid value: "X Proxy"`,
+				`spec.agentAuth.headers[X-Proxy-Tenant]: Invalid value: "tenant/id"`,
+			},
+		},
+		{
+			name: "agent auth with the authorization header",
+			agentAuth: &AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: "agent-auth"},
+				Headers:   map[string]string{"authorization": "token"},
+			},
+			expectedMessages: []string{`spec.agentAuth.headers: Invalid value: "authorization": the Authorization header is set with the bearer token of the secret`},
+		},
+		{
+			name:           "gzip compression with storage backend",
+			storageBackend: &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			compression:    GzipArtifactCompression,
+		},
+		{
+			name:        "no compression without storage backend",
+			compression: NoArtifactCompression,
+		},
+		{
+			name:             "gzip compression without storage backend",
+			compression:      GzipArtifactCompression,
+			expectedMessages: []string{"spec.compression: Forbidden: may only be set with a storage backend"},
+		},
+		{
+			name:            "max artifact size",
+			storageBackend:  &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			maxArtifactSize: "100Mi",
+		},
+		{
+			name:             "zero max artifact size",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			maxArtifactSize:  "0",
+			expectedMessages: []string{`spec.maxArtifactSize: Invalid value: "0": must be positive`},
+		},
+		{
+			name:             "max artifact size without storage backend",
+			maxArtifactSize:  "100Mi",
+			expectedMessages: []string{"spec.maxArtifactSize: Forbidden: may only be set with a storage backend"},
+		},
+		{
+			name:           "artifact TTL",
+			storageBackend: &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			artifactTTL:    30 * 24 * time.Hour,
+		},
+		{
+			name:             "negative artifact TTL",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			artifactTTL:      -time.Hour,
+			expectedMessages: []string{`spec.artifactTTL: Invalid value: "-1h0m0s": must be positive`},
+		},
+		{
+			name:             "artifact TTL without storage backend",
+			artifactTTL:      time.Hour,
+			expectedMessages: []string{"spec.artifactTTL: Forbidden: may only be set with a storage backend"},
+		},
+		{
+			name:           "agent request timeout",
+			requestTimeout: 5 * time.Minute,
+		},
+		{
+			name:             "negative agent request timeout",
+			requestTimeout:   -time.Minute,
+			expectedMessages: []string{`spec.agentRequestTimeout: Invalid value: "-1m0s": must be positive`},
+		},
+		{
+			name:           "artifact key template",
+			storageBackend: &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:    `profiles/{{.Timestamp.Format "2006-01-02"}}/{{.Node}}/{{.Type}}.pprof`,
+		},
+		{
+			name:             "artifact key template without storage backend",
+			keyTemplate:      "{{.Node}}/{{.Type}}.pprof",
+			expectedMessages: []string{"spec.artifactKeyTemplate: Forbidden: may only be set with a storage backend"},
+		},
+		{
+			name:             "unparsable artifact key template",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:      "{{.Node}/{{.Type}}.pprof",
+			expectedMessages: []string{"spec.artifactKeyTemplate: Invalid value", "bad character"},
+		},
+		{
+			name:             "unknown field in artifact key template",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:      "{{.Cluster}}/{{.Node}}/{{.Type}}.pprof",
+			expectedMessages: []string{"spec.artifactKeyTemplate: Invalid value", "can't evaluate field Cluster"},
+		},
+		{
+			name:             "absolute artifact key",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:      "/{{.Node}}/{{.Type}}.pprof",
+			expectedMessages: []string{"must be a clean relative path"},
+		},
+		{
+			name:             "artifact key going up the tree",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:      "../{{.Node}}/{{.Type}}.pprof",
+			expectedMessages: []string{"must be a clean relative path"},
+		},
+		{
+			name:             "artifact key shared by the nodes",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			keyTemplate:      "profiles/{{.Type}}.pprof",
+			expectedMessages: []string{"must include the .Node and .Type fields"},
+		},
+		{
+			name:           "pod labels and annotations",
+			storageBackend: &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			podLabels:      map[string]string{"cost-center": "infra", "example.com/team": "node"},
+			podAnnotations: map[string]string{"example.com/chargeback": "infra team"},
+		},
+		{
+			name:             "invalid pod label",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			podLabels:        map[string]string{"cost center": "infra"},
+			expectedMessages: []string{`spec.podLabels: Invalid value: "cost center"`},
+		},
+		{
+			name:             "invalid pod annotation",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			podAnnotations:   map[string]string{"example.com/": "infra"},
+			expectedMessages: []string{`spec.podAnnotations: Invalid value: "example.com/"`},
+		},
+		{
+			name:             "reserved pod label",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			podLabels:        map[string]string{"nodeobservability.olm.openshift.io/collector": "false"},
+			expectedMessages: []string{`spec.podLabels: Invalid value: "nodeobservability.olm.openshift.io/collector": the keys of the nodeobservability.olm.openshift.io domain are reserved`},
+		},
+		{
+			name:             "reserved pod annotation subdomain",
+			storageBackend:   &StorageBackend{Type: PVCStorageBackendType, PVC: &PVCStorageBackend{ClaimName: "profiles"}},
+			podAnnotations:   map[string]string{"run.nodeobservability.olm.openshift.io/owner": "me"},
+			expectedMessages: []string{`spec.podAnnotations: Invalid value: "run.nodeobservability.olm.openshift.io/owner"`},
+		},
+		{
+			name:             "pod labels without storage backend",
+			podLabels:        map[string]string{"cost-center": "infra"},
+			expectedMessages: []string{"spec.podLabels: Forbidden: may only be set with the PVC storage backend"},
+		},
+		{
+			name:             "pod annotations with S3 storage backend",
+			storageBackend:   &StorageBackend{Type: S3StorageBackendType},
+			podAnnotations:   map[string]string{"example.com/chargeback": "infra"},
+			expectedMessages: []string{"spec.podAnnotations: Forbidden: may only be set with the PVC storage backend"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var maxArtifactSize *resource.Quantity
+			if tc.maxArtifactSize != "" {
+				q := resource.MustParse(tc.maxArtifactSize)
+				maxArtifactSize = &q
+			}
+			run := &NodeObservabilityRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "test"},
+				Spec: NodeObservabilityRunSpec{
+					NodeObservabilityRef: &NodeObservabilityRef{Name: "cluster"},
+					ProfileTypes:         tc.profileTypes,
+					CollectorEndpoint:    tc.collectorEndpoint,
+					AgentAuth:            tc.agentAuth,
+					StorageBackend:       tc.storageBackend,
+					Compression:          tc.compression,
+					MaxArtifactSize:      maxArtifactSize,
+					ArtifactTTL:          metav1.Duration{Duration: tc.artifactTTL},
+					AgentRequestTimeout:  metav1.Duration{Duration: tc.requestTimeout},
+					ArtifactKeyTemplate:  tc.keyTemplate,
+					NodeSelector:         tc.nodeSelector,
+					Nodes:                tc.nodes,
+					PodLabels:            tc.podLabels,
+					PodAnnotations:       tc.podAnnotations,
+				},
+			}
+
+			for op, err := range map[string]error{
+				"create": run.ValidateCreate(),
+				"update": run.ValidateUpdate(run.DeepCopy()),
+			} {
+				if len(tc.expectedMessages) == 0 {
+					if err != nil {
+						t.Fatalf("unexpected %s error: %v", op, err)
+					}
+					continue
+				}
+				if !apierrors.IsInvalid(err) {
+					t.Fatalf("expected %s invalid error, got %v", op, err)
+				}
+				for _, msg := range tc.expectedMessages {
+					if !strings.Contains(err.Error(), msg) {
+						t.Errorf("expected %s error to contain %q, got %q", op, msg, err.Error())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCancelledNodeObservabilityRun(t *testing.T) {
+	cancelled := &NodeObservabilityRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "test"},
+		Spec: NodeObservabilityRunSpec{
+			NodeObservabilityRef: &NodeObservabilityRef{Name: "cluster"},
+			Cancel:               true,
+		},
+	}
+	running := cancelled.DeepCopy()
+	running.Spec.Cancel = false
+
+	if err := cancelled.ValidateUpdate(running); err != nil {
+		t.Fatalf("expected the cancellation of the run to be allowed, got %v", err)
+	}
+	err := running.ValidateUpdate(cancelled)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.cancel") {
+		t.Fatalf("expected the cancellation to be kept, got %v", err)
+	}
+}
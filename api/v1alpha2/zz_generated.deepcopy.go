@@ -22,13 +22,68 @@ limitations under the License.
 package v1alpha2
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentAuth) DeepCopyInto(out *AgentAuth) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentAuth.
+func (in *AgentAuth) DeepCopy() *AgentAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AgentNode) DeepCopyInto(out *AgentNode) {
 	*out = *in
+	if in.StartTimestamp != nil {
+		in, out := &in.StartTimestamp, &out.StartTimestamp
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishedTimestamp != nil {
+		in, out := &in.FinishedTimestamp, &out.FinishedTimestamp
+		*out = (*in).DeepCopy()
+	}
+	if in.ProfileDuration != nil {
+		in, out := &in.ProfileDuration, &out.ProfileDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ObjectKeys != nil {
+		in, out := &in.ObjectKeys, &out.ObjectKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]ProfileArtifact, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingUpload != nil {
+		in, out := &in.PendingUpload, &out.PendingUpload
+		*out = new(PendingUpload)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentNode.
@@ -41,6 +96,61 @@ func (in *AgentNode) DeepCopy() *AgentNode {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentProbe) DeepCopyInto(out *AgentProbe) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentProbe.
+func (in *AgentProbe) DeepCopy() *AgentProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CollectorEndpoint) DeepCopyInto(out *CollectorEndpoint) {
+	*out = *in
+	if in.BearerTokenSecretRef != nil {
+		in, out := &in.BearerTokenSecretRef, &out.BearerTokenSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectorEndpoint.
+func (in *CollectorEndpoint) DeepCopy() *CollectorEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(CollectorEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConditionalStatus) DeepCopyInto(out *ConditionalStatus) {
 	*out = *in
@@ -63,6 +173,40 @@ func (in *ConditionalStatus) DeepCopy() *ConditionalStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigPreview) DeepCopyInto(out *MachineConfigPreview) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPreview.
+func (in *MachineConfigPreview) DeepCopy() *MachineConfigPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigStatus) DeepCopyInto(out *MachineConfigStatus) {
+	*out = *in
+	if in.MachineConfigPoolUnpauseTime != nil {
+		in, out := &in.MachineConfigPoolUnpauseTime, &out.MachineConfigPoolUnpauseTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigStatus.
+func (in *MachineConfigStatus) DeepCopy() *MachineConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeObservability) DeepCopyInto(out *NodeObservability) {
 	*out = *in
@@ -207,6 +351,23 @@ func (in *NodeObservabilityMachineConfigSpec) DeepCopyInto(out *NodeObservabilit
 			(*out)[key] = val
 		}
 	}
+	if in.RequiredNodeAffinity != nil {
+		in, out := &in.RequiredNodeAffinity, &out.RequiredNodeAffinity
+		*out = new(corev1.NodeSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodePools != nil {
+		in, out := &in.NodePools, &out.NodePools
+		*out = make([]NodePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MachineConfigRolloutPauseDuration != nil {
+		in, out := &in.MachineConfigRolloutPauseDuration, &out.MachineConfigRolloutPauseDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeObservabilityMachineConfigSpec.
@@ -224,6 +385,20 @@ func (in *NodeObservabilityMachineConfigStatus) DeepCopyInto(out *NodeObservabil
 	*out = *in
 	in.ConditionalStatus.DeepCopyInto(&out.ConditionalStatus)
 	in.LastReconcile.DeepCopyInto(&out.LastReconcile)
+	if in.MachineConfigPoolUnpauseTime != nil {
+		in, out := &in.MachineConfigPoolUnpauseTime, &out.MachineConfigPoolUnpauseTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PausedMachineConfigPools != nil {
+		in, out := &in.PausedMachineConfigPools, &out.PausedMachineConfigPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MachineConfigPreview != nil {
+		in, out := &in.MachineConfigPreview, &out.MachineConfigPreview
+		*out = make([]MachineConfigPreview, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeObservabilityMachineConfigStatus.
@@ -318,6 +493,82 @@ func (in *NodeObservabilityRunSpec) DeepCopyInto(out *NodeObservabilityRunSpec)
 		*out = new(NodeObservabilityRef)
 		**out = **in
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Timeout = in.Timeout
+	out.ProfileDuration = in.ProfileDuration
+	if in.ProfileTypes != nil {
+		in, out := &in.ProfileTypes, &out.ProfileTypes
+		*out = make([]ProfileType, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	out.RetryBackoff = in.RetryBackoff
+	out.AgentRequestTimeout = in.AgentRequestTimeout
+	if in.MaxConcurrentNodes != nil {
+		in, out := &in.MaxConcurrentNodes, &out.MaxConcurrentNodes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StorageBackend != nil {
+		in, out := &in.StorageBackend, &out.StorageBackend
+		*out = new(StorageBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CollectorEndpoint != nil {
+		in, out := &in.CollectorEndpoint, &out.CollectorEndpoint
+		*out = new(CollectorEndpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxArtifactSize != nil {
+		in, out := &in.MaxArtifactSize, &out.MaxArtifactSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	out.ArtifactTTL = in.ArtifactTTL
+	if in.MinSucceededNodesPercent != nil {
+		in, out := &in.MinSucceededNodesPercent, &out.MinSucceededNodesPercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ServiceCABundleRef != nil {
+		in, out := &in.ServiceCABundleRef, &out.ServiceCABundleRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.AgentAuth != nil {
+		in, out := &in.AgentAuth, &out.AgentAuth
+		*out = new(AgentAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodLabels != nil {
+		in, out := &in.PodLabels, &out.PodLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeObservabilityRunSpec.
@@ -344,13 +595,46 @@ func (in *NodeObservabilityRunStatus) DeepCopyInto(out *NodeObservabilityRunStat
 	if in.Agents != nil {
 		in, out := &in.Agents, &out.Agents
 		*out = make([]AgentNode, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.FailedAgents != nil {
 		in, out := &in.FailedAgents, &out.FailedAgents
 		*out = make([]AgentNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingAgents != nil {
+		in, out := &in.PendingAgents, &out.PendingAgents
+		*out = make([]AgentNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CancelledAgents != nil {
+		in, out := &in.CancelledAgents, &out.CancelledAgents
+		*out = make([]AgentNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SelectedNodes != nil {
+		in, out := &in.SelectedNodes, &out.SelectedNodes
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(RunSummary)
+		(*in).DeepCopyInto(*out)
+	}
 	in.ConditionalStatus.DeepCopyInto(&out.ConditionalStatus)
 	if in.Output != nil {
 		in, out := &in.Output, &out.Output
@@ -379,6 +663,117 @@ func (in *NodeObservabilitySpec) DeepCopyInto(out *NodeObservabilitySpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IPFamilyPolicy != nil {
+		in, out := &in.IPFamilyPolicy, &out.IPFamilyPolicy
+		*out = new(corev1.IPFamilyPolicy)
+		**out = **in
+	}
+	if in.PublishNotReadyAddresses != nil {
+		in, out := &in.PublishNotReadyAddresses, &out.PublishNotReadyAddresses
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MachineConfigRolloutPauseDuration != nil {
+		in, out := &in.MachineConfigRolloutPauseDuration, &out.MachineConfigRolloutPauseDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodePools != nil {
+		in, out := &in.NodePools, &out.NodePools
+		*out = make([]NodePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(AgentProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(AgentProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(appsv1.DaemonSetUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(corev1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SuccessfulRunsHistoryLimit != nil {
+		in, out := &in.SuccessfulRunsHistoryLimit, &out.SuccessfulRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RunHistoryLimit != nil {
+		in, out := &in.RunHistoryLimit, &out.RunHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeObservabilitySpec.
@@ -399,6 +794,36 @@ func (in *NodeObservabilityStatus) DeepCopyInto(out *NodeObservabilityStatus) {
 		*out = (*in).DeepCopy()
 	}
 	in.ConditionalStatus.DeepCopyInto(&out.ConditionalStatus)
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NodePools != nil {
+		in, out := &in.NodePools, &out.NodePools
+		*out = make([]NodePoolStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.MachineConfig != nil {
+		in, out := &in.MachineConfig, &out.MachineConfig
+		*out = new(MachineConfigStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingServiceChanges != nil {
+		in, out := &in.PendingServiceChanges, &out.PendingServiceChanges
+		*out = make([]ServiceChange, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunRefs != nil {
+		in, out := &in.RunRefs, &out.RunRefs
+		*out = make([]RunRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeObservabilityStatus.
@@ -410,3 +835,223 @@ func (in *NodeObservabilityStatus) DeepCopy() *NodeObservabilityStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePool) DeepCopyInto(out *NodePool) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePool.
+func (in *NodePool) DeepCopy() *NodePool {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePoolStatus) DeepCopyInto(out *NodePoolStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePoolStatus.
+func (in *NodePoolStatus) DeepCopy() *NodePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCStorageBackend) DeepCopyInto(out *PVCStorageBackend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCStorageBackend.
+func (in *PVCStorageBackend) DeepCopy() *PVCStorageBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCStorageBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingUpload) DeepCopyInto(out *PendingUpload) {
+	*out = *in
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingUpload.
+func (in *PendingUpload) DeepCopy() *PendingUpload {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingUpload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileArtifact) DeepCopyInto(out *ProfileArtifact) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(int64)
+		**out = **in
+	}
+	if in.UncompressedSize != nil {
+		in, out := &in.UncompressedSize, &out.UncompressedSize
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Symbolized != nil {
+		in, out := &in.Symbolized, &out.Symbolized
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BuildIDs != nil {
+		in, out := &in.BuildIDs, &out.BuildIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileArtifact.
+func (in *ProfileArtifact) DeepCopy() *ProfileArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunRef) DeepCopyInto(out *RunRef) {
+	*out = *in
+	if in.StartTimestamp != nil {
+		in, out := &in.StartTimestamp, &out.StartTimestamp
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishedTimestamp != nil {
+		in, out := &in.FinishedTimestamp, &out.FinishedTimestamp
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunRef.
+func (in *RunRef) DeepCopy() *RunRef {
+	if in == nil {
+		return nil
+	}
+	out := new(RunRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunSummary) DeepCopyInto(out *RunSummary) {
+	*out = *in
+	if in.MinDuration != nil {
+		in, out := &in.MinDuration, &out.MinDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.AverageDuration != nil {
+		in, out := &in.AverageDuration, &out.AverageDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunSummary.
+func (in *RunSummary) DeepCopy() *RunSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(RunSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3StorageBackend) DeepCopyInto(out *S3StorageBackend) {
+	*out = *in
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3StorageBackend.
+func (in *S3StorageBackend) DeepCopy() *S3StorageBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(S3StorageBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceChange) DeepCopyInto(out *ServiceChange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceChange.
+func (in *ServiceChange) DeepCopy() *ServiceChange {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageBackend) DeepCopyInto(out *StorageBackend) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3StorageBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(PVCStorageBackend)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageBackend.
+func (in *StorageBackend) DeepCopy() *StorageBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageBackend)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -30,6 +30,7 @@ import (
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"github.com/openshift/node-observability-operator/pkg/collector"
 	"github.com/openshift/node-observability-operator/pkg/operator"
 	operatorconfig "github.com/openshift/node-observability-operator/pkg/operator/config"
 	"github.com/openshift/node-observability-operator/pkg/version"
@@ -40,14 +41,29 @@ var (
 )
 
 func main() {
+	// the operator image also runs the collector of the profiles
+	if len(os.Args) > 1 && os.Args[1] == collector.Command {
+		os.Exit(collector.Main(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == collector.PurgeCommand {
+		os.Exit(collector.PurgeMain(os.Args[2:]))
+	}
+
 	flag.StringVar(&opCfg.OperatorNamespace, "operator-namespace", operatorconfig.DefaultOperatorNamespace, "The node observability operator namespace.")
+	flag.StringVar(&opCfg.OperandNamespace, "operand-namespace", "", "The namespace of the operands (agent DaemonSet, Service, etc.). Defaults to the operator namespace.")
 	flag.StringVar(&opCfg.AgentImage, "agent-image", operatorconfig.DefaultAgentImage, "The node observability agent container image to use.")
+	flag.StringVar(&opCfg.CollectorImage, "collector-image", operatorconfig.DefaultCollectorImage, "The container image of the collector of the profiles, the operator image.")
 	flag.StringVar(&opCfg.MetricsBindAddress, "metrics-bind-address", operatorconfig.DefaultMetricsAddr, "The address the metric endpoint binds to.")
 	flag.StringVar(&opCfg.HealthProbeBindAddress, "health-probe-bind-address", operatorconfig.DefaultHealthProbeAddr, "The address the probe endpoint binds to.")
 	flag.StringVar(&opCfg.TokenFile, "token-file", operatorconfig.DefaultTokenFile, "The path of the service account token.")
 	flag.StringVar(&opCfg.CaCertFile, "ca-cert-file", operatorconfig.DefaultCACertFile, "The path of the CA cert of the Agents' signing key pair.")
+	flag.StringVar(&opCfg.AgentClientCertFile, "agent-client-cert-file", "", "The path of the client certificate presented to the Agents. No client certificate is presented if not set.")
+	flag.StringVar(&opCfg.AgentClientKeyFile, "agent-client-key-file", "", "The path of the key of the client certificate presented to the Agents.")
 	flag.BoolVar(&opCfg.EnableLeaderElection, "leader-elect", operatorconfig.DefaultEnableLeaderElection, "Enable leader election for controller manager. "+"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&opCfg.EnableWebhook, "enable-webhook", operatorconfig.DefaultEnableWebhook, "Enable the webhook server(s). Defaults to true.")
+	flag.DurationVar(&opCfg.RequeuePeriod, "requeue-period", operatorconfig.DefaultRequeuePeriod, "The period at which NodeObservability is reconciled again while waiting for a resource or for the machine config changes to be rolled out. Periods shorter than 1s are raised to 1s.")
+	flag.DurationVar(&opCfg.AgentRequestTimeoutMargin, "agent-request-timeout-margin", operatorconfig.DefaultAgentRequestTimeoutMargin, "The margin added to the profile duration to bound the requests to the Agents of the runs which don't set their agentRequestTimeout.")
+	flag.BoolVar(&opCfg.EnableAgentNodeCriticalPriority, "enable-agent-node-critical-priority", operatorconfig.DefaultEnableAgentNodeCriticalPriority, "Set the system-node-critical priority class on the agent pods when NodeObservability doesn't set any.")
 
 	opts := zap.Options{
 		TimeEncoder: zapcore.TimeEncoder(func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
@@ -62,7 +78,12 @@ func main() {
 	setupLog := ctrl.Log.WithName("setup")
 	ctrl.Log.Info("build info", "commit", version.COMMIT)
 	ctrl.Log.Info("using operator namespace", "namespace", opCfg.OperatorNamespace)
+	if opCfg.OperandNamespace != "" {
+		ctrl.Log.Info("using operand namespace", "namespace", opCfg.OperandNamespace)
+	}
 	ctrl.Log.Info("using AgentImage image", "image", opCfg.AgentImage)
+	ctrl.Log.Info("using CollectorImage image", "image", opCfg.CollectorImage)
+	ctrl.Log.Info("using requeue period", "period", opCfg.RequeuePeriod)
 
 	kubeConfig := ctrl.GetConfigOrDie()
 	op, err := operator.New(kubeConfig, &opCfg)
@@ -0,0 +1,360 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package collector retrieves the profiles from the agents
+// and writes them into a directory, typically a mounted persistent volume claim.
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// Command is the argument of the operator binary which starts the collector
+	Command = "collect"
+	// DefaultTerminationMessagePath is the file where the results of the collection are written
+	DefaultTerminationMessagePath = "/dev/termination-log"
+	// maxErrorLength bounds the error reported for each node
+	// to stay within the size limit of the termination message
+	maxErrorLength = 128
+	requestTimeout = time.Minute
+	// GzipExt is the suffix of the gzipped profiles
+	GzipExt = ".gz"
+)
+
+// Agent is an agent whose profiles are collected
+type Agent struct {
+	// Name is the name of the node of the agent
+	Name string
+	// URL is the base URL of the agent
+	URL string
+}
+
+// Config is the configuration of the collector
+type Config struct {
+	// OutputDir is the directory where the profiles of each node are written
+	OutputDir string
+	// Artifacts are the profiles retrieved from each agent
+	Artifacts []string
+	// Agents are the agents whose profiles are collected
+	Agents []Agent
+	// AuthToken is the bearer token sent to the agents
+	AuthToken []byte
+	// CACert is the pool of the CA certificates of the agents
+	CACert *x509.CertPool
+	// Gzip compresses the profiles, their names are suffixed with GzipExt
+	Gzip bool
+	// KeyTemplate renders the paths of the profiles in the output directory,
+	// the profiles are written in a subdirectory named after the node if not set
+	KeyTemplate *template.Template
+	// KeyData are the fields of the run available to the key template,
+	// the node and the type are set for each profile
+	KeyData v1alpha2.ArtifactKeyData
+	// MaxArtifactSize is the maximum size in bytes of each profile,
+	// the profiles are not bounded if not positive
+	MaxArtifactSize int64
+}
+
+// ArtifactTooLargeError is returned when a profile exceeds the maximum artifact size
+type ArtifactTooLargeError struct {
+	// Size is the size of the profile announced by the agent,
+	// 0 if unknown as the retrieval was aborted once the maximum was exceeded
+	Size int64
+	// MaxSize is the maximum artifact size
+	MaxSize int64
+}
+
+func (e *ArtifactTooLargeError) Error() string {
+	if e.Size > 0 {
+		return fmt.Sprintf("size of %d bytes exceeds the maximum artifact size of %d bytes", e.Size, e.MaxSize)
+	}
+	return fmt.Sprintf("size of more than %d bytes exceeds the maximum artifact size", e.MaxSize)
+}
+
+// ReadArtifact reads the profile from the body of the response,
+// the reading is aborted once the profile exceeds maxSize bytes if positive.
+func ReadArtifact(resp *http.Response, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, &ArtifactTooLargeError{Size: resp.ContentLength, MaxSize: maxSize}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, &ArtifactTooLargeError{MaxSize: maxSize}
+	}
+	return body, nil
+}
+
+// Results are the errors of the nodes whose profiles could not be collected keyed by node name,
+// the profiles of the nodes which are not in the results were collected.
+type Results map[string]string
+
+// Collect retrieves the profiles of all the agents,
+// the failure of an agent doesn't prevent the collection of the others.
+func Collect(ctx context.Context, cfg Config) Results {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{
+		RootCAs:    cfg.CACert,
+		MinVersion: tls.VersionTLS12,
+	}
+	client := &http.Client{
+		Timeout:   requestTimeout,
+		Transport: t,
+	}
+
+	results := Results{}
+	for _, agent := range cfg.Agents {
+		if err := collectAgent(ctx, client, cfg, agent); err != nil {
+			msg := err.Error()
+			if len(msg) > maxErrorLength {
+				msg = msg[:maxErrorLength]
+			}
+			results[agent.Name] = msg
+		}
+	}
+	return results
+}
+
+func collectAgent(ctx context.Context, client *http.Client, cfg Config, agent Agent) error {
+	for _, artifact := range cfg.Artifacts {
+		file, err := artifactFile(cfg, agent, artifact)
+		if err != nil {
+			return fmt.Errorf("failed to compute the path of %s: %w", artifact, err)
+		}
+		data, err := get(ctx, client, cfg.AuthToken, fmt.Sprintf("%s/%s", strings.TrimSuffix(agent.URL, "/"), artifact), cfg.MaxArtifactSize)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve %s: %w", artifact, err)
+		}
+		if cfg.Gzip {
+			if data, err = Gzip(data); err != nil {
+				return fmt.Errorf("failed to compress %s: %w", artifact, err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0o640); err != nil {
+			return fmt.Errorf("failed to write %s: %w", artifact, err)
+		}
+	}
+	return nil
+}
+
+// artifactFile returns the file where the given profile of the agent is written:
+// the key rendered with the key template in the output directory if any,
+// the profile in the directory of the agent otherwise.
+func artifactFile(cfg Config, agent Agent, artifact string) (string, error) {
+	key := filepath.Join(agent.Name, artifact)
+	if cfg.KeyTemplate != nil {
+		data := cfg.KeyData
+		data.Node = agent.Name
+		data.Type = strings.TrimSuffix(artifact, filepath.Ext(artifact))
+		var err error
+		if key, err = v1alpha2.RenderArtifactKey(cfg.KeyTemplate, data); err != nil {
+			return "", err
+		}
+	}
+	if cfg.Gzip {
+		key += GzipExt
+	}
+	return filepath.Join(cfg.OutputDir, key), nil
+}
+
+// Gzip returns the gzipped data.
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func get(ctx context.Context, client *http.Client, token []byte, url string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", string(token)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("code %d", resp.StatusCode)
+	}
+	return ReadArtifact(resp, maxSize)
+}
+
+// ParseResults parses the results written by the collector.
+func ParseResults(msg string) (Results, error) {
+	results := Results{}
+	if err := json.Unmarshal([]byte(msg), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// agentsFlag parses the repeated name=url agent arguments
+type agentsFlag []Agent
+
+func (f *agentsFlag) String() string {
+	agents := make([]string, 0, len(*f))
+	for _, a := range *f {
+		agents = append(agents, fmt.Sprintf("%s=%s", a.Name, a.URL))
+	}
+	return strings.Join(agents, ",")
+}
+
+func (f *agentsFlag) Set(value string) error {
+	name, url, found := strings.Cut(value, "=")
+	if !found || name == "" || url == "" {
+		return fmt.Errorf("expected name=url, got %q", value)
+	}
+	*f = append(*f, Agent{Name: name, URL: url})
+	return nil
+}
+
+// Main runs the collector with the given command line arguments
+// and writes the results into the termination message file.
+// Returns the exit code of the collector.
+func Main(args []string) int {
+	var (
+		agents                 agentsFlag
+		outputDir              string
+		artifacts              string
+		tokenFile              string
+		caCertFile             string
+		terminationMessagePath string
+		gzipArtifacts          bool
+		keyTemplate            string
+		namespace              string
+		runName                string
+		timestamp              string
+		maxArtifactSize        int64
+	)
+	fs := flag.NewFlagSet(Command, flag.ContinueOnError)
+	fs.Var(&agents, "agent", "The name and the base URL of an agent as name=url, can be repeated.")
+	fs.StringVar(&outputDir, "output-dir", "", "The directory where the profiles are written.")
+	fs.StringVar(&artifacts, "artifacts", "", "The comma separated profiles to retrieve from each agent.")
+	fs.StringVar(&tokenFile, "token-file", "", "The path of the service account token.")
+	fs.StringVar(&caCertFile, "ca-cert-file", "", "The path of the CA cert of the Agents' signing key pair.")
+	fs.BoolVar(&gzipArtifacts, "gzip", false, "Gzip the profiles, their names are suffixed with .gz.")
+	fs.StringVar(&keyTemplate, "key-template", "", "The template of the paths of the profiles in the output directory.")
+	fs.StringVar(&namespace, "namespace", "", "The namespace of the run, available to the key template.")
+	fs.StringVar(&runName, "run-name", "", "The name of the run, available to the key template.")
+	fs.StringVar(&timestamp, "timestamp", "", "The RFC3339 start time of the run, available to the key template.")
+	fs.Int64Var(&maxArtifactSize, "max-artifact-size", 0, "The maximum size in bytes of each profile, unlimited if not positive.")
+	fs.StringVar(&terminationMessagePath, "termination-message-path", DefaultTerminationMessagePath, "The file where the results are written.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if outputDir == "" || artifacts == "" {
+		fmt.Fprintln(os.Stderr, "--output-dir and --artifacts are required")
+		return 2
+	}
+
+	cfg := Config{
+		OutputDir:       outputDir,
+		Artifacts:       strings.Split(artifacts, ","),
+		Agents:          agents,
+		Gzip:            gzipArtifacts,
+		MaxArtifactSize: maxArtifactSize,
+	}
+	if keyTemplate != "" {
+		tmpl, err := v1alpha2.ParseArtifactKeyTemplate(keyTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to parse key template: %v\n", err)
+			return 2
+		}
+		cfg.KeyTemplate = tmpl
+		cfg.KeyData = v1alpha2.ArtifactKeyData{Namespace: namespace, RunName: runName}
+		if timestamp != "" {
+			if cfg.KeyData.Timestamp, err = time.Parse(time.RFC3339, timestamp); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to parse timestamp: %v\n", err)
+				return 2
+			}
+		}
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read serviceaccount token: %v\n", err)
+		return 1
+	}
+	caCert, err := os.ReadFile(caCertFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read CA cert: %v\n", err)
+		return 1
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		fmt.Fprintf(os.Stderr, "no valid certificate found in %s\n", caCertFile)
+		return 1
+	}
+
+	cfg.AuthToken = token
+	cfg.CACert = pool
+	results := Collect(context.Background(), cfg)
+
+	failed := make([]string, 0, len(results))
+	for name := range results {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		fmt.Fprintf(os.Stderr, "failed to collect the profiles of node %s: %s\n", name, results[name])
+	}
+	fmt.Printf("collected the profiles of %d/%d node(s)\n", len(agents)-len(results), len(agents))
+
+	msg, err := json.Marshal(results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode results: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(terminationMessagePath, msg, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,314 @@
+package collector
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const testToken = "test-token"
+
+// testAgent returns a server serving the profiles as the agents,
+// the requests without the expected token or for the failing profiles are rejected.
+func testAgent(failing ...string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		for _, f := range failing {
+			if req.URL.Path == "/node-observability-output/"+f {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		_, _ = w.Write([]byte(req.URL.Path))
+	}))
+}
+
+func TestCollect(t *testing.T) {
+	healthy := testAgent()
+	defer healthy.Close()
+	failing := testAgent("crio.pprof")
+	defer failing.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(healthy.Certificate())
+
+	outputDir := t.TempDir()
+	results := Collect(context.Background(), Config{
+		OutputDir: outputDir,
+		Artifacts: []string{"kubelet.pprof", "crio.pprof"},
+		Agents: []Agent{
+			{Name: "node-1", URL: healthy.URL + "/node-observability-output"},
+			{Name: "node-2", URL: failing.URL + "/node-observability-output/"},
+		},
+		AuthToken: []byte(testToken),
+		CACert:    pool,
+	})
+
+	expected := Results{"node-2": "failed to retrieve crio.pprof: code 500"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected results %v, got %v", expected, results)
+	}
+	for _, f := range []string{"kubelet.pprof", "crio.pprof"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, "node-1", f))
+		if err != nil {
+			t.Fatalf("failed to read profile %s: %v", f, err)
+		}
+		if string(content) != "/node-observability-output/"+f {
+			t.Errorf("unexpected content %q of profile %s", content, f)
+		}
+	}
+}
+
+func TestCollectGzip(t *testing.T) {
+	agent := testAgent()
+	defer agent.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(agent.Certificate())
+
+	outputDir := t.TempDir()
+	results := Collect(context.Background(), Config{
+		OutputDir: outputDir,
+		Artifacts: []string{"kubelet.pprof"},
+		Agents:    []Agent{{Name: "node-1", URL: agent.URL + "/node-observability-output"}},
+		AuthToken: []byte(testToken),
+		CACert:    pool,
+		Gzip:      true,
+	})
+	if len(results) != 0 {
+		t.Fatalf("unexpected failures %v", results)
+	}
+	f, err := os.Open(filepath.Join(outputDir, "node-1", "kubelet.pprof.gz"))
+	if err != nil {
+		t.Fatalf("failed to open profile: %v", err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read gzipped profile: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decompress profile: %v", err)
+	}
+	if string(content) != "/node-observability-output/kubelet.pprof" {
+		t.Errorf("unexpected content %q of the profile", content)
+	}
+}
+
+func TestCollectKeyTemplate(t *testing.T) {
+	agent := testAgent()
+	defer agent.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(agent.Certificate())
+	tmpl, err := v1alpha2.ParseArtifactKeyTemplate(`profiles/{{.Timestamp.Format "2006-01-02"}}/{{.Node}}/{{.Type}}.pprof`)
+	if err != nil {
+		t.Fatalf("failed to parse key template: %v", err)
+	}
+
+	outputDir := t.TempDir()
+	results := Collect(context.Background(), Config{
+		OutputDir:   outputDir,
+		Artifacts:   []string{"kubelet.pprof", "crio-heap.pprof"},
+		Agents:      []Agent{{Name: "node-1", URL: agent.URL + "/node-observability-output"}},
+		AuthToken:   []byte(testToken),
+		CACert:      pool,
+		KeyTemplate: tmpl,
+		KeyData:     v1alpha2.ArtifactKeyData{Timestamp: time.Date(2022, time.May, 12, 15, 25, 54, 0, time.UTC)},
+	})
+	if len(results) != 0 {
+		t.Fatalf("unexpected failures %v", results)
+	}
+	for _, f := range []string{"kubelet.pprof", "crio-heap.pprof"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, "profiles", "2022-05-12", "node-1", f))
+		if err != nil {
+			t.Fatalf("failed to read profile %s: %v", f, err)
+		}
+		if string(content) != "/node-observability-output/"+f {
+			t.Errorf("unexpected content %q of profile %s", content, f)
+		}
+	}
+}
+
+func TestCollectMaxArtifactSize(t *testing.T) {
+	agent := testAgent()
+	defer agent.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(agent.Certificate())
+
+	outputDir := t.TempDir()
+	results := Collect(context.Background(), Config{
+		OutputDir: outputDir,
+		// the content of the profiles is their 37 and 40 bytes long path
+		Artifacts:       []string{"crio.pprof", "kubelet.pprof"},
+		Agents:          []Agent{{Name: "node-1", URL: agent.URL + "/node-observability-output"}},
+		AuthToken:       []byte(testToken),
+		CACert:          pool,
+		MaxArtifactSize: 38,
+	})
+
+	expected := Results{"node-1": "failed to retrieve kubelet.pprof: size of 40 bytes exceeds the maximum artifact size of 38 bytes"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected results %v, got %v", expected, results)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "node-1", "kubelet.pprof")); !os.IsNotExist(err) {
+		t.Errorf("expected the profile exceeding the maximum size not to be written, got %v", err)
+	}
+}
+
+func TestReadArtifact(t *testing.T) {
+	testCases := []struct {
+		name          string
+		contentLength int64
+		maxSize       int64
+		expectedError string
+	}{
+		{
+			name:          "unbounded",
+			contentLength: 10,
+		},
+		{
+			name:          "within the maximum size",
+			contentLength: 10,
+			maxSize:       10,
+		},
+		{
+			name:          "announced size over the maximum",
+			contentLength: 10,
+			maxSize:       9,
+			expectedError: "size of 10 bytes exceeds the maximum artifact size of 9 bytes",
+		},
+		{
+			name:          "unknown size over the maximum",
+			contentLength: -1,
+			maxSize:       9,
+			expectedError: "size of more than 9 bytes exceeds the maximum artifact size",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{
+				ContentLength: tc.contentLength,
+				Body:          io.NopCloser(strings.NewReader("0123456789")),
+			}
+			data, err := ReadArtifact(resp, tc.maxSize)
+			if tc.expectedError != "" {
+				var tooLarge *ArtifactTooLargeError
+				if !errors.As(err, &tooLarge) || err.Error() != tc.expectedError {
+					t.Fatalf("expected error %q, got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != "0123456789" {
+				t.Errorf("unexpected content %q", data)
+			}
+		})
+	}
+}
+
+func TestCollectorMain(t *testing.T) {
+	agent := testAgent()
+	defer agent.Close()
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	caCertFile := filepath.Join(dir, "ca.crt")
+	resultsFile := filepath.Join(dir, "termination-log")
+	if err := os.WriteFile(tokenFile, []byte(testToken), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: agent.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name             string
+		args             []string
+		expectedCode     int
+		expectedFailures Results
+	}{
+		{
+			name: "profiles collected",
+			args: []string{
+				"--output-dir=" + filepath.Join(dir, "profiles"),
+				"--artifacts=kubelet.pprof,crio.pprof",
+				"--token-file=" + tokenFile,
+				"--ca-cert-file=" + caCertFile,
+				"--termination-message-path=" + resultsFile,
+				"--agent=node-1=" + agent.URL + "/node-observability-output",
+				"--agent=node-2=https://127.0.0.1:1/node-observability-output",
+			},
+			expectedFailures: Results{"node-2": ""},
+		},
+		{
+			name:         "missing output dir",
+			args:         []string{"--artifacts=kubelet.pprof"},
+			expectedCode: 2,
+		},
+		{
+			name: "invalid key template",
+			args: []string{
+				"--output-dir=" + filepath.Join(dir, "profiles"),
+				"--artifacts=kubelet.pprof",
+				"--key-template={{.Node",
+			},
+			expectedCode: 2,
+		},
+		{
+			name:         "invalid agent",
+			args:         []string{"--agent=node-1"},
+			expectedCode: 2,
+		},
+		{
+			name: "missing token",
+			args: []string{
+				"--output-dir=" + filepath.Join(dir, "profiles"),
+				"--artifacts=kubelet.pprof",
+				"--token-file=" + filepath.Join(dir, "missing"),
+			},
+			expectedCode: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := Main(tc.args); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			if tc.expectedCode != 0 {
+				return
+			}
+			msg, err := os.ReadFile(resultsFile)
+			if err != nil {
+				t.Fatalf("failed to read results: %v", err)
+			}
+			results, err := ParseResults(string(msg))
+			if err != nil {
+				t.Fatalf("failed to parse results: %v", err)
+			}
+			if len(results) != len(tc.expectedFailures) {
+				t.Fatalf("expected failures %v, got %v", tc.expectedFailures, results)
+			}
+			for name := range tc.expectedFailures {
+				if _, found := results[name]; !found {
+					t.Errorf("expected node %s to fail, got %v", name, results)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the fields of the profile.proto messages read from the profiles
+const (
+	profileMappingField     protowire.Number = 3
+	profileLocationField    protowire.Number = 4
+	profileStringTableField protowire.Number = 6
+	mappingBuildIDField     protowire.Number = 6
+	locationLineField       protowire.Number = 4
+)
+
+// ProfileSymbols describes the symbolization of a pprof profile
+type ProfileSymbols struct {
+	// Symbolized is true if all the locations of the profile are resolved to functions
+	Symbolized bool
+	// BuildIDs are the build IDs of the binaries mapped in the profile,
+	// in the order of the mappings, the binaries without build ID are skipped
+	BuildIDs []string
+}
+
+// InspectSymbols reads the given pprof profile, gzipped or not,
+// and returns its symbolization.
+func InspectSymbols(data []byte) (*ProfileSymbols, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var table []string
+	var buildIDs []uint64
+	symbolized := true
+	err := consumeFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case profileStringTableField:
+			table = append(table, string(value))
+		case profileMappingField:
+			return consumeFields(value, func(num protowire.Number, value []byte) error {
+				if num == mappingBuildIDField {
+					index, n := protowire.ConsumeVarint(value)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					buildIDs = append(buildIDs, index)
+				}
+				return nil
+			})
+		case profileLocationField:
+			var lines int
+			if err := consumeFields(value, func(num protowire.Number, _ []byte) error {
+				if num == locationLineField {
+					lines++
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if lines == 0 {
+				symbolized = false
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	symbols := &ProfileSymbols{Symbolized: symbolized}
+	seen := map[string]struct{}{}
+	for _, index := range buildIDs {
+		if index >= uint64(len(table)) {
+			return nil, fmt.Errorf("invalid profile: build ID %d out of the string table", index)
+		}
+		id := table[index]
+		if _, found := seen[id]; id == "" || found {
+			continue
+		}
+		seen[id] = struct{}{}
+		symbols.BuildIDs = append(symbols.BuildIDs, id)
+	}
+	return symbols, nil
+}
+
+// consumeFields calls fn with the number and the value of each field of the given message,
+// the value of the varint fields is passed encoded.
+func consumeFields(msg []byte, fn func(protowire.Number, []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		n = protowire.ConsumeFieldValue(num, typ, msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		value := msg[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		if err := fn(num, value); err != nil {
+			return err
+		}
+		msg = msg[n:]
+	}
+	return nil
+}
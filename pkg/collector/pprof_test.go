@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testProfile returns a profile.proto message with the given string table,
+// a mapping per build ID index and a location per symbolization flag.
+func testProfile(table []string, buildIDs []uint64, symbolized ...bool) []byte {
+	var profile []byte
+	for i, index := range buildIDs {
+		var mapping []byte
+		mapping = protowire.AppendTag(mapping, 1, protowire.VarintType)
+		mapping = protowire.AppendVarint(mapping, uint64(i+1))
+		mapping = protowire.AppendTag(mapping, mappingBuildIDField, protowire.VarintType)
+		mapping = protowire.AppendVarint(mapping, index)
+		profile = protowire.AppendTag(profile, profileMappingField, protowire.BytesType)
+		profile = protowire.AppendBytes(profile, mapping)
+	}
+	for i, s := range symbolized {
+		var location []byte
+		location = protowire.AppendTag(location, 1, protowire.VarintType)
+		location = protowire.AppendVarint(location, uint64(i+1))
+		if s {
+			var line []byte
+			line = protowire.AppendTag(line, 1, protowire.VarintType)
+			line = protowire.AppendVarint(line, 1)
+			location = protowire.AppendTag(location, locationLineField, protowire.BytesType)
+			location = protowire.AppendBytes(location, line)
+		}
+		profile = protowire.AppendTag(profile, profileLocationField, protowire.BytesType)
+		profile = protowire.AppendBytes(profile, location)
+	}
+	// the string table comes last as in the profiles written by the Go runtime
+	for _, s := range table {
+		profile = protowire.AppendTag(profile, profileStringTableField, protowire.BytesType)
+		profile = protowire.AppendString(profile, s)
+	}
+	return profile
+}
+
+func TestInspectSymbols(t *testing.T) {
+	gzipped := func(data []byte) []byte {
+		data, err := Gzip(data)
+		if err != nil {
+			t.Fatalf("failed to gzip profile: %v", err)
+		}
+		return data
+	}
+	table := []string{"", "kubelet-build-id", "libc-build-id"}
+
+	testCases := []struct {
+		name            string
+		profile         []byte
+		expectedSymbols *ProfileSymbols
+		errExpected     bool
+	}{
+		{
+			name:            "symbolized profile",
+			profile:         testProfile(table, []uint64{1, 2}, true, true),
+			expectedSymbols: &ProfileSymbols{Symbolized: true, BuildIDs: []string{"kubelet-build-id", "libc-build-id"}},
+		},
+		{
+			name:            "gzipped profile",
+			profile:         gzipped(testProfile(table, []uint64{1}, true)),
+			expectedSymbols: &ProfileSymbols{Symbolized: true, BuildIDs: []string{"kubelet-build-id"}},
+		},
+		{
+			name:            "unresolved location",
+			profile:         testProfile(table, []uint64{1}, true, false),
+			expectedSymbols: &ProfileSymbols{BuildIDs: []string{"kubelet-build-id"}},
+		},
+		{
+			name:            "mappings without build id skipped",
+			profile:         testProfile(table, []uint64{0, 2, 2}, true),
+			expectedSymbols: &ProfileSymbols{Symbolized: true, BuildIDs: []string{"libc-build-id"}},
+		},
+		{
+			name:        "build id out of the string table",
+			profile:     testProfile(table, []uint64{3}),
+			errExpected: true,
+		},
+		{
+			name:        "not a profile",
+			profile:     []byte("pong\n"),
+			errExpected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			symbols, err := InspectSymbols(tc.profile)
+			if tc.errExpected {
+				if err == nil {
+					t.Fatalf("expected error, got symbols %+v", symbols)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(symbols, tc.expectedSymbols) {
+				t.Errorf("expected symbols %+v, got %+v", tc.expectedSymbols, symbols)
+			}
+		})
+	}
+}
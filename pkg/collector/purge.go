@@ -0,0 +1,76 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PurgeCommand is the argument of the operator binary which deletes the expired profiles
+const PurgeCommand = "purge"
+
+// Purge deletes the given profiles from the directory, their paths are relative to it.
+// The profiles which don't exist are skipped for the deletion to be safely retried,
+// the directories left empty are deleted as well up to the directory.
+func Purge(dir string, paths []string) error {
+	for _, p := range paths {
+		if filepath.IsAbs(p) || filepath.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("profile path %q must be a clean relative path", p)
+		}
+	}
+	for _, p := range paths {
+		file := filepath.Join(dir, p)
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete profile %q: %w", p, err)
+		}
+		// the deletion of a non empty directory fails and stops the cleanup of its parents
+		for parent := filepath.Dir(p); parent != "."; parent = filepath.Dir(parent) {
+			if err := os.Remove(filepath.Join(dir, parent)); err != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// PurgeMain runs the purge of the profiles with the given command line arguments,
+// the paths of the profiles are the positional arguments.
+// Returns the exit code of the purge.
+func PurgeMain(args []string) int {
+	var dir string
+	fs := flag.NewFlagSet(PurgeCommand, flag.ContinueOnError)
+	fs.StringVar(&dir, "dir", "", "The directory of the profiles.")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "--dir is required")
+		return 2
+	}
+
+	if err := Purge(dir, fs.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("deleted %d profile(s)\n", fs.NArg())
+	return 0
+}
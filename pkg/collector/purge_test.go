@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"test/run/node-1/kubelet.pprof", "test/run/node-1/crio.pprof", "test/run/node-2/kubelet.pprof", "test/other/node-1/kubelet.pprof"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, p), []byte("pong\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the missing profile of node-3 is skipped, the purge can be repeated
+	paths := []string{"test/run/node-1/kubelet.pprof", "test/run/node-1/crio.pprof", "test/run/node-3/kubelet.pprof"}
+	for i := 0; i < 2; i++ {
+		if err := Purge(dir, paths); err != nil {
+			t.Fatalf("unexpected error at purge %d: %v", i+1, err)
+		}
+	}
+	for _, p := range []string{"test/run/node-1", "test/run/node-3"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted, got %v", p, err)
+		}
+	}
+	for _, p := range []string{"test/run/node-2/kubelet.pprof", "test/other/node-1/kubelet.pprof"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("expected %s to be kept, got %v", p, err)
+		}
+	}
+
+	for _, p := range []string{"/etc/passwd", "../kubelet.pprof", "test/../../kubelet.pprof"} {
+		if err := Purge(dir, []string{p}); err == nil {
+			t.Errorf("expected error for path %q", p)
+		}
+	}
+}
+
+func TestPurgeMain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kubelet.pprof"), []byte("pong\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name         string
+		args         []string
+		expectedCode int
+	}{
+		{
+			name: "profiles deleted",
+			args: []string{"--dir=" + dir, "kubelet.pprof", "crio.pprof"},
+		},
+		{
+			name:         "missing dir",
+			args:         []string{"kubelet.pprof"},
+			expectedCode: 2,
+		},
+		{
+			name:         "path out of the dir",
+			args:         []string{"--dir=" + dir, "../kubelet.pprof"},
+			expectedCode: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := PurgeMain(tc.args); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kubelet.pprof")); !os.IsNotExist(err) {
+		t.Errorf("expected the profile to be deleted, got %v", err)
+	}
+}
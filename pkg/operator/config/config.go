@@ -16,13 +16,25 @@ limitations under the License.
 
 package config
 
+import "time"
+
 const (
 	DefaultOperatorNamespace    = "node-observability-operator"
 	DefaultAgentImage           = "quay.io/node-observability-operator/node-observability-agent:latest"
+	DefaultCollectorImage       = "quay.io/openshift/node-observability-operator:latest"
 	DefaultMetricsAddr          = ":8080"
 	DefaultEnableWebhook        = true
 	DefaultHealthProbeAddr      = ":8081"
 	DefaultEnableLeaderElection = false
+	// DefaultEnableAgentNodeCriticalPriority doesn't set any priority class
+	// on the agent pods unless requested in NodeObservability
+	DefaultEnableAgentNodeCriticalPriority = false
+	// DefaultRequeuePeriod is the period at which NodeObservability is reconciled again
+	// while waiting for a resource or for the machine config changes to be rolled out
+	DefaultRequeuePeriod = 5 * time.Second
+	// DefaultAgentRequestTimeoutMargin is added to the profile duration
+	// to bound the requests to the agents of the runs which don't set a timeout
+	DefaultAgentRequestTimeoutMargin = 30 * time.Second
 	// #nosec G101: Potential hardcoded credentials; path to token, not the content itself
 	DefaultTokenFile  = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	DefaultCACertFile = "/var/run/secrets/openshift.io/certs/service-ca.crt"
@@ -34,9 +46,17 @@ type Config struct {
 	// OperatorNamespace is the namespace that the operator is deployed in.
 	OperatorNamespace string
 
+	// OperandNamespace is the namespace of the operands: agent DaemonSet, Service, etc.
+	// Defaults to the operator namespace.
+	OperandNamespace string
+
 	// The node observability agent container image to use.
 	AgentImage string
 
+	// CollectorImage is the container image of the collector of the profiles,
+	// the collector is run by the operator binary.
+	CollectorImage string
+
 	// MetricsBindAddress is the TCP address that the operator should bind to for
 	// serving prometheus metrics. It can be set to "0" to disable the metrics serving.
 	MetricsBindAddress string
@@ -51,9 +71,26 @@ type Config struct {
 	// The path of the CA cert of the Agents' signing key pair.
 	CaCertFile string
 
+	// AgentClientCertFile and AgentClientKeyFile are the paths of the client certificate
+	// presented to the agents for the mutual TLS, no client certificate is presented if not set.
+	AgentClientCertFile string
+	AgentClientKeyFile  string
+
 	// EnableWebhook is the flag indicating if the webhook server should be started.
 	EnableWebhook bool
 
 	// EnableLeaderElection enables the controller runtime's leader election.
 	EnableLeaderElection bool
+
+	// EnableAgentNodeCriticalPriority sets the system-node-critical priority class
+	// on the agent pods when NodeObservability doesn't set any.
+	EnableAgentNodeCriticalPriority bool
+
+	// RequeuePeriod is the period at which NodeObservability is reconciled again
+	// while waiting for a resource or for the machine config changes to be rolled out.
+	RequeuePeriod time.Duration
+
+	// AgentRequestTimeoutMargin is added to the profile duration of the runs
+	// to bound the requests to the agents when the runs don't set a timeout.
+	AgentRequestTimeoutMargin time.Duration
 }
@@ -28,6 +28,10 @@ const (
 	// defaultRequeueTime is the default reconcile requeue time
 	defaultRequeueTime = time.Minute
 
+	// defaultRolloutPauseDuration is the default time the profiling
+	// MachineConfigPool stays paused with the Paused rollout strategy
+	defaultRolloutPauseDuration = time.Hour
+
 	// Empty is defined for empty string
 	Empty = ""
 
@@ -54,19 +58,33 @@ const (
 	// role label name
 	MCNodeObservabilityLabelName = "machineconfiguration.openshift.io/nodeobservability"
 
+	// ManagedByLabelName is the label set on the MachineConfigs
+	// created by the operator, only these are garbage collected
+	ManagedByLabelName = "nodeobservability.olm.openshift.io/managed-by"
+
+	// ManagedByLabelValue is the value of the ManagedByLabelName label
+	ManagedByLabelValue = "node-observability-operator"
+
 	// MCPoolKind is the machine config pool resource king
 	MCPoolKind = "MachineConfigPool"
 
 	// MCRoleLabelName is the machine config role label name
 	MCRoleLabelName = "machineconfiguration.openshift.io/role"
 
+	// NodeRoleLabelPrefix is the prefix of the node role labels
+	NodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
 	// NodeObservabilityNodeRoleLabelName is the role label name
 	// used for enabling profiling of services on requested nodes
-	NodeObservabilityNodeRoleLabelName = "node-role.kubernetes.io/nodeobservability"
+	NodeObservabilityNodeRoleLabelName = NodeRoleLabelPrefix + NodeObservabilityNodeRoleName
 
 	// NodeObservabilityNodeRoleName is the nodeobservability node role name
 	NodeObservabilityNodeRoleName = "nodeobservability"
 
+	// UnpauseMCPAnnotation is the annotation of NodeObservabilityMachineConfig
+	// which requests the paused profiling MCP to be unpaused immediately
+	UnpauseMCPAnnotation = "nodeobservability.openshift.io/unpause-machineconfigpool"
+
 	// ProfilingMCPName is the name of the MCP created for
 	// applying nodeobservability related MC changes on
 	// nodes with nodeobservability role
@@ -75,6 +93,26 @@ const (
 	// ResourceLabelsPath is the path of Labels in resource
 	ResourceLabelsPath = "/metadata/labels"
 
+	// ResourceAnnotationsPath is the path of Annotations in resource
+	ResourceAnnotationsPath = "/metadata/annotations"
+
+	// ResourceSpecPath is the path of Spec in resource
+	ResourceSpecPath = "/spec"
+
+	// MasterNodeMCPName is the name of the MCP of the nodes with master role,
+	// it rolls out the CRI-O profiling on them when the master nodes are included
+	MasterNodeMCPName = "master"
+
+	// MasterNodeRoleLabelName is the role label name used for master nodes
+	MasterNodeRoleLabelName = NodeRoleLabelPrefix + "master"
+
+	// ControlPlaneNodeRoleLabelName is the role label name used for control plane nodes
+	ControlPlaneNodeRoleLabelName = NodeRoleLabelPrefix + "control-plane"
+
+	// CrioProfilingMasterConfigName is the name of the CRI-O MachineConfig CR
+	// rolled out by the master MCP
+	CrioProfilingMasterConfigName = "10-master-crio-nodeobservability"
+
 	// WorkerNodeMCPName is the name of the MCP created for
 	// applying required MC changes on nodes with worker role
 	WorkerNodeMCPName = "worker"
@@ -93,6 +131,9 @@ const (
 
 	// remove is the patch operation for removing info from the resource
 	remove
+
+	// replace is the patch operation for replacing existing info in the resource
+	replace
 )
 
 var (
@@ -101,15 +142,16 @@ var (
 	CrioUnixSocketConfData = fmt.Sprintf(`[Service]
 Environment="%s"`, CrioUnixSocketEnvString)
 
-	// NodeSelectorLabels is for storing the labels to
-	// match the nodes to include in MCP
-	NodeSelectorLabels = map[string]string{
-		NodeObservabilityNodeRoleLabelName: Empty,
-	}
-
 	// MachineConfigLabels is for storing the labels to
 	// add in machine config resources
 	MachineConfigLabels = map[string]string{
 		MCRoleLabelName: NodeObservabilityNodeRoleName,
 	}
+
+	// ManagedMachineConfigLabels is for storing the labels to
+	// add in the machine config resources created by the operator
+	ManagedMachineConfigLabels = map[string]string{
+		MCRoleLabelName:    NodeObservabilityNodeRoleName,
+		ManagedByLabelName: ManagedByLabelValue,
+	}
 )
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -33,7 +34,9 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 
@@ -44,7 +47,7 @@ import (
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilitymachineconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilitymachineconfigs/finalizers,verbs=update
 //+kubebuilder:rbac:groups=machineconfiguration.openshift.io,resources=machineconfigs,verbs=get;list;watch;create;delete
-//+kubebuilder:rbac:groups=machineconfiguration.openshift.io,resources=machineconfigpools,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=machineconfiguration.openshift.io,resources=machineconfigpools,verbs=get;list;watch;create;delete;update;patch
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create
 
@@ -93,8 +96,13 @@ func (r *MachineConfigReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		if kerrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// The MachineConfigs left behind by the deleted owner are removed here,
+			// the watch on the owned MachineConfigs triggers this reconcile for them.
 			// Return and don't requeue
 			r.Log.V(1).Info("nodeobservabilitymachineconfig resource not found. Ignoring as it could have been deleted")
+			if err := r.deleteOrphanedMachineConfigs(ctx); err != nil {
+				return ctrl.Result{RequeueAfter: defaultRequeueTime}, fmt.Errorf("failed to delete orphaned machineconfigs: %w", err)
+			}
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -142,6 +150,16 @@ func (r *MachineConfigReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		}
 	}()
 
+	if r.CtrlConfig.Spec.DryRun && r.CtrlConfig.Spec.Debug.EnableCrioProfiling {
+		// nothing is applied, the preview is refreshed
+		// to follow the changes of the targeted nodes
+		if err := r.previewProfConf(ctx); err != nil {
+			return ctrl.Result{RequeueAfter: defaultRequeueTime}, fmt.Errorf("failed to preview requested configuration: %w", err)
+		}
+		return ctrl.Result{RequeueAfter: defaultRequeueTime}, nil
+	}
+	r.CtrlConfig.Status.MachineConfigPreview = nil
+
 	requeue, err := r.handleProfilingRequest(ctx)
 	if err != nil {
 		return ctrl.Result{RequeueAfter: defaultRequeueTime}, fmt.Errorf("failed to reconcile requested configuration: %w", err)
@@ -159,17 +177,30 @@ func (r *MachineConfigReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MachineConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha2.NodeObservabilityMachineConfig{}, builder.WithPredicates(ignoreNOMCStatusUpdates())).
 		Owns(&mcv1.MachineConfig{}).
 		Owns(&mcv1.MachineConfigPool{}).
-		Complete(r)
+		// the excluded nodes leave the profiling pools
+		Watches(&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForNode),
+			builder.WithPredicates(nodeExclusionChanged())).
+		Complete(r); err != nil {
+		return err
+	}
+	// the paused MCPs would block the machine config updates until the operator restarts
+	return mgr.Add(&pausedMCPReleaser{impl: r.impl, reader: mgr.GetAPIReader(), log: r.Log.WithName("shutdown")})
 }
 
 // cleanUp is handling the deletion of NodeObservabilityMachineConfig resource.
 // Reverts all the changes made when debugging is enabled and
 // restores the cluster to earlier state.
 func (r *MachineConfigReconciler) cleanUp(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// the MCPs paused by the operator would block the rollback
+	if err := r.unpauseTrackedMCPs(ctx); err != nil {
+		return ctrl.Result{RequeueAfter: defaultRequeueTime}, err
+	}
+
 	nodesTouched, err := r.ensureProfConfDisabled(ctx)
 	if err != nil {
 		// failed to remove the label from the observed nodes: retry
@@ -330,14 +361,44 @@ func (r *MachineConfigReconciler) handleProfilingRequest(ctx context.Context) (b
 // Returns true if the requeue is needed.
 func (r *MachineConfigReconciler) ensureProfConfEnabled(ctx context.Context) (bool, error) {
 
-	labelEnsured, err := r.ensureReqNodeLabelExists(ctx)
-	if err != nil {
-		return true, fmt.Errorf("failed to ensure nodes are labelled: %w", err)
-	}
-	if !labelEnsured {
-		// not all (or none of) the labels are present,
-		// requeue to retry later
-		return true, nil
+	if r.CtrlConfig.Spec.MachineConfigPoolName != "" {
+		// the nodes are configured by the named MCP as they are,
+		// the ones labeled for the profiling pools are released
+		usable, err := r.checkNamedMCP(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to check named mcp: %w", err)
+		}
+		if !usable {
+			return true, nil
+		}
+		if err := r.removeStaleNodeLabels(ctx, nil); err != nil {
+			return true, fmt.Errorf("failed to ensure nodes are not labelled: %w", err)
+		}
+	} else {
+		// the profiling MachineConfigPools inherit the machine configs of the worker one,
+		// nothing is applied until it exists
+		workerMCPFound, err := r.workerMCPExists(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to get worker mcp: %w", err)
+		}
+		if !workerMCPFound {
+			msg := fmt.Sprintf("machineconfigpool %s not found, debug configurations not applied", WorkerNodeMCPName)
+			r.Log.V(1).Info("Worker MachineConfigPool not found, retry later", "MCPName", WorkerNodeMCPName)
+			if r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonMachineConfigPoolNotFound, msg) {
+				r.EventRecorder.Event(r.CtrlConfig, corev1.EventTypeWarning, v1alpha2.ReasonMachineConfigPoolNotFound, msg)
+			}
+			return true, nil
+		}
+
+		labelEnsured, err := r.ensureReqNodeLabelExists(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to ensure nodes are labelled: %w", err)
+		}
+		if !labelEnsured {
+			// not all (or none of) the labels are present,
+			// requeue to retry later
+			return true, nil
+		}
 	}
 
 	if err := r.enableCrioProf(ctx); err != nil {
@@ -348,6 +409,11 @@ func (r *MachineConfigReconciler) ensureProfConfEnabled(ctx context.Context) (bo
 		return false, fmt.Errorf("failed to ensure mcp exists: %w", err)
 	}
 
+	// the nodes of the pools no longer requested got their role label removed
+	if err := r.deleteStaleProfilingPools(ctx); err != nil {
+		return false, fmt.Errorf("failed to delete stale profiling pools: %w", err)
+	}
+
 	if !r.CtrlConfig.Status.IsDebuggingEnabled() {
 		// we just applied all the config for CRI-O profiling,
 		// setup the status and requeue to wait for the MCO
@@ -361,14 +427,21 @@ func (r *MachineConfigReconciler) ensureProfConfEnabled(ctx context.Context) (bo
 	return false, nil
 }
 
-// ensureProfConfDisabled disables the profiling on the requested nodes by removing the nodeobservability label.
-// Returns true if at least 1 node was touched, false otherwise.
+// ensureProfConfDisabled disables the profiling on the requested nodes by removing the nodeobservability label,
+// or the MachineConfig of the named MCP. Returns true if at least 1 node or the named MCP was touched, false otherwise.
 func (r *MachineConfigReconciler) ensureProfConfDisabled(ctx context.Context) (bool, error) {
 
 	modCount, err := r.ensureReqNodeLabelNotExists(ctx)
 	if err != nil {
 		return true, fmt.Errorf("failed to ensure nodes are not labelled: %w", err)
 	}
+	deleted, err := r.disableNamedPoolCrioProf(ctx)
+	if err != nil {
+		return true, err
+	}
+	if deleted {
+		modCount++
+	}
 
 	if modCount > 0 {
 		r.CtrlConfig.Status.SetCondition(v1alpha2.DebugEnabled, metav1.ConditionFalse, v1alpha2.ReasonDisabled,
@@ -387,6 +460,13 @@ func (r *MachineConfigReconciler) ensureProfConfDisabled(ctx context.Context) (b
 func (r *MachineConfigReconciler) monitorProgress(ctx context.Context) (result ctrl.Result, err error) {
 
 	if r.CtrlConfig.Status.IsDebuggingEnabled() {
+		var paused bool
+		if paused, result, err = r.handlePausedRollout(ctx); err != nil {
+			err = fmt.Errorf("failed to handle paused rollout: %w", err)
+			return
+		} else if paused {
+			return
+		}
 		if result, err = r.checkNodeObservabilityMCPStatus(ctx); err != nil {
 			err = fmt.Errorf("failed to check nodeobservability mcp status: %w", err)
 			return
@@ -406,7 +486,10 @@ func (r *MachineConfigReconciler) monitorProgress(ctx context.Context) (result c
 // revertEnabledProfConf is for restoring the cluster state to
 // as it was, before enabling the debug configurations
 func (r *MachineConfigReconciler) revertEnabledProfConf(ctx context.Context) error {
-	_, err := r.ensureReqNodeLabelNotExists(ctx)
+	if _, err := r.ensureReqNodeLabelNotExists(ctx); err != nil {
+		return err
+	}
+	_, err := r.disableNamedPoolCrioProf(ctx)
 	return err
 }
 
@@ -428,7 +511,12 @@ func ignoreNOMCStatusUpdates() predicate.Predicate {
 			// spec or metadata has not changed and the event could be for
 			// status update which need not be queued for reconciliation
 			if _, ok := e.ObjectOld.(*v1alpha2.NodeObservabilityMachineConfig); ok {
-				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration()
+				// the unpause of the profiling MCP is requested through an annotation
+				_, oldUnpause := e.ObjectOld.GetAnnotations()[UnpauseMCPAnnotation]
+				_, newUnpause := e.ObjectNew.GetAnnotations()[UnpauseMCPAnnotation]
+				// so is the forced reconcile
+				forced := e.ObjectOld.GetAnnotations()[v1alpha2.ForceReconcileAnnotation] != e.ObjectNew.GetAnnotations()[v1alpha2.ForceReconcileAnnotation]
+				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() || oldUnpause != newUnpause || forced
 			}
 			return true
 		},
@@ -19,6 +19,8 @@ package machineconfigcontroller
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,9 +30,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -135,7 +139,7 @@ func testNodeObsNodes() []runtime.Object {
 }
 
 func testNodeObsMCP(r *MachineConfigReconciler) *mcv1.MachineConfigPool {
-	mcp := r.getCrioProfMachineConfigPool(ProfilingMCPName)
+	mcp := r.getCrioProfMachineConfigPool(r.profilingPools()[0])
 
 	mcp.Spec.Configuration.ObjectReference = corev1.ObjectReference{
 		Name: "rendered-nodeobservability-9d2d6f47a54e5828cf2917d760b54a99",
@@ -288,7 +292,7 @@ func TestReconcile(t *testing.T) {
 	labeledNodes := testNodeObsNodes()
 	mcp := testNodeObsMCP(r)
 	workerMCP := testWorkerMCP()
-	criomc, _ := r.getCrioProfMachineConfig()
+	criomc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 
 	tests := []struct {
 		name       string
@@ -830,7 +834,7 @@ func TestReconcileClientFakes(t *testing.T) {
 						}
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -874,7 +878,7 @@ func TestReconcileClientFakes(t *testing.T) {
 						}
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -929,7 +933,7 @@ func TestReconcileClientFakes(t *testing.T) {
 						}
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -970,7 +974,7 @@ func TestReconcileClientFakes(t *testing.T) {
 						}
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -1059,7 +1063,7 @@ func TestReconcileClientFakes(t *testing.T) {
 					case *mcv1.MachineConfigPool:
 						return testError
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -1174,7 +1178,7 @@ func TestReconcileClientFakes(t *testing.T) {
 						}
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -1253,7 +1257,7 @@ func TestReconcileClientFakes(t *testing.T) {
 							mcv1.MachineConfigPoolUpdated, corev1.ConditionTrue)
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -1325,7 +1329,7 @@ func TestReconcileClientFakes(t *testing.T) {
 							mcv1.MachineConfigPoolUpdated, corev1.ConditionTrue)
 						mcp.DeepCopyInto(o)
 					case *mcv1.MachineConfig:
-						mc, _ := r.getCrioProfMachineConfig()
+						mc, _ := r.getCrioProfMachineConfig(r.profilingPools()[0])
 						mc.DeepCopyInto(o)
 					case *v1alpha2.NodeObservabilityMachineConfig:
 						nomc := testNodeObsMC()
@@ -1775,6 +1779,64 @@ func TestEnsureProfConfEnabled(t *testing.T) {
 	}
 }
 
+func TestEnsureProfConfEnabledWorkerMCPNotFound(t *testing.T) {
+	r := testReconciler()
+	recorder := record.NewFakeRecorder(10)
+	r.EventRecorder = recorder
+	c := fake.NewClientBuilder().
+		WithScheme(test.Scheme).
+		WithRuntimeObjects(append(testWorkerNodes(), r.CtrlConfig)...).
+		Build()
+	r.impl = &defaultImpl{Client: c}
+
+	requeue, err := r.ensureProfConfEnabled(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !requeue {
+		t.Errorf("expected requeue while the worker MCP does not exist")
+	}
+	cond := r.CtrlConfig.Status.GetCondition(v1alpha2.DebugReady)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != v1alpha2.ReasonMachineConfigPoolNotFound {
+		t.Fatalf("expected DebugReady condition with reason %s, got %v", v1alpha2.ReasonMachineConfigPoolNotFound, cond)
+	}
+	if r.CtrlConfig.Status.IsDebuggingEnabled() {
+		t.Errorf("expected debugging not to be enabled")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning MachineConfigPoolNotFound") {
+			t.Errorf("expected MachineConfigPoolNotFound event, got %q", event)
+		}
+	default:
+		t.Errorf("expected MachineConfigPoolNotFound event, got none")
+	}
+	mcList := &mcv1.MachineConfigList{}
+	if err := c.List(context.TODO(), mcList); err != nil {
+		t.Fatalf("failed to list machineconfigs: %v", err)
+	}
+	if len(mcList.Items) != 0 {
+		t.Errorf("expected no machineconfig to be created, got %d", len(mcList.Items))
+	}
+
+	// the configuration is applied once the worker MCP appears
+	if err := c.Create(context.TODO(), testWorkerMCP()); err != nil {
+		t.Fatalf("failed to create worker mcp: %v", err)
+	}
+	if _, err := r.ensureProfConfEnabled(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !r.CtrlConfig.Status.IsDebuggingEnabled() || !r.CtrlConfig.Status.IsMachineConfigInProgress() {
+		t.Errorf("expected debug configurations to be applied, got status %+v", r.CtrlConfig.Status)
+	}
+	if err := c.List(context.TODO(), mcList); err != nil {
+		t.Fatalf("failed to list machineconfigs: %v", err)
+	}
+	if len(mcList.Items) != 1 {
+		t.Errorf("expected the crio profiling machineconfig to be created, got %d machineconfigs", len(mcList.Items))
+	}
+}
+
 func TestEnsureProfConfDisabled(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1847,3 +1909,436 @@ func TestNewPatch(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPatchOperations(t *testing.T) {
+	tests := []struct {
+		name       string
+		op         patchOp
+		pathPrefix string
+		arg        map[string]interface{}
+		wantPatch  string
+	}{
+		{
+			name:       "add label",
+			op:         add,
+			pathPrefix: ResourceLabelsPath,
+			arg:        map[string]interface{}{NodeObservabilityNodeRoleLabelName: ""},
+			wantPatch:  `[{"op":"add","path":"/metadata/labels/node-role.kubernetes.io~1nodeobservability","value":""}]`,
+		},
+		{
+			name:       "remove annotation",
+			op:         remove,
+			pathPrefix: ResourceAnnotationsPath,
+			arg:        map[string]interface{}{UnpauseMCPAnnotation: nil},
+			wantPatch:  `[{"op":"remove","path":"/metadata/annotations/` + escape(UnpauseMCPAnnotation) + `"}]`,
+		},
+		{
+			name:       "replace spec field",
+			op:         replace,
+			pathPrefix: ResourceSpecPath,
+			arg:        map[string]interface{}{"paused": false},
+			wantPatch:  `[{"op":"replace","path":"/spec/paused","value":false}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := newPatch(tt.op, tt.pathPrefix, tt.arg)
+			if err != nil {
+				t.Fatalf("newPatch() unexpected err: %v", err)
+			}
+			if string(patch) != tt.wantPatch {
+				t.Errorf("newPatch() patch: %s, want: %s", patch, tt.wantPatch)
+			}
+		})
+	}
+}
+
+func TestUnpatchedFieldsKept(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Annotations = map[string]string{UnpauseMCPAnnotation: "true", "other": "kept"}
+	mcp := testNodeObsMCP(r)
+	mcp.Spec.Paused = true
+	c := fake.NewClientBuilder().
+		WithScheme(test.Scheme).
+		WithRuntimeObjects(r.CtrlConfig.DeepCopy(), mcp).
+		Build()
+	r.impl = &defaultImpl{Client: c}
+
+	// the pool read before MCO updated it
+	stale := &mcv1.MachineConfigPool{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ProfilingMCPName}, stale); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	updated := stale.DeepCopy()
+	updated.Status.MachineCount = 3
+	updated.Status.UpdatedMachineCount = 1
+	if err := c.Update(ctx, updated); err != nil {
+		t.Fatalf("failed to update MCP: %v", err)
+	}
+
+	patch, _ := newPatch(replace, ResourceSpecPath, map[string]interface{}{"paused": false})
+	if err := r.ClientPatch(ctx, stale, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		t.Fatalf("failed to patch the stale MCP: %v", err)
+	}
+	got := &mcv1.MachineConfigPool{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ProfilingMCPName}, got); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	if got.Spec.Paused {
+		t.Errorf("MCP expected to be unpaused")
+	}
+	if got.Status.MachineCount != 3 || got.Status.UpdatedMachineCount != 1 {
+		t.Errorf("MCP status updated by MCO expected to be kept, got: %+v", got.Status)
+	}
+	if !reflect.DeepEqual(got.Spec.NodeSelector, mcp.Spec.NodeSelector) {
+		t.Errorf("MCP node selector expected to be kept, got: %v", got.Spec.NodeSelector)
+	}
+
+	if err := r.removeUnpauseAnnotation(ctx); err != nil {
+		t.Fatalf("removeUnpauseAnnotation() unexpected err: %v", err)
+	}
+	nomc := &v1alpha2.NodeObservabilityMachineConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: TestControllerResourceName}, nomc); err != nil {
+		t.Fatalf("failed to get NOMC: %v", err)
+	}
+	if !reflect.DeepEqual(nomc.Annotations, map[string]string{"other": "kept"}) {
+		t.Errorf("unexpected annotations: %v", nomc.Annotations)
+	}
+	// nothing is patched once the annotation is removed
+	if err := r.removeUnpauseAnnotation(ctx); err != nil {
+		t.Fatalf("removeUnpauseAnnotation() unexpected err: %v", err)
+	}
+}
+
+func TestHandlePausedRollout(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+
+	tests := []struct {
+		name             string
+		mcpPaused        *bool
+		unpauseTime      *metav1.Time
+		unpauseRequested bool
+		wantPaused       bool
+		wantMCPPaused    bool
+		wantReason       string
+	}{
+		{
+			name: "nodeobservability MCP does not exist",
+		},
+		{
+			name:      "nodeobservability MCP not paused",
+			mcpPaused: pointer.Bool(false),
+		},
+		{
+			name:          "nodeobservability MCP paused until unpause time",
+			mcpPaused:     pointer.Bool(true),
+			unpauseTime:   &metav1.Time{Time: time.Now().Add(time.Hour)},
+			wantPaused:    true,
+			wantMCPPaused: true,
+			wantReason:    v1alpha2.ReasonPaused,
+		},
+		{
+			name:        "nodeobservability MCP unpaused after unpause time",
+			mcpPaused:   pointer.Bool(true),
+			unpauseTime: &metav1.Time{Time: time.Now().Add(-time.Minute)},
+			wantPaused:  true,
+			wantReason:  v1alpha2.ReasonUnpaused,
+		},
+		{
+			name:             "nodeobservability MCP unpaused on request",
+			mcpPaused:        pointer.Bool(true),
+			unpauseTime:      &metav1.Time{Time: time.Now().Add(time.Hour)},
+			unpauseRequested: true,
+			wantPaused:       true,
+			wantReason:       v1alpha2.ReasonUnpaused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler()
+			r.CtrlConfig.Spec.MachineConfigRolloutStrategy = v1alpha2.PausedMachineConfigRolloutStrategy
+			r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = tt.unpauseTime
+			if tt.unpauseRequested {
+				r.CtrlConfig.Annotations = map[string]string{UnpauseMCPAnnotation: "true"}
+			}
+
+			objs := []runtime.Object{r.CtrlConfig.DeepCopy()}
+			if tt.mcpPaused != nil {
+				mcp := testNodeObsMCP(r)
+				mcp.Spec.Paused = *tt.mcpPaused
+				objs = append(objs, mcp)
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(test.Scheme).
+				WithRuntimeObjects(objs...).
+				Build()
+			r.impl = &defaultImpl{Client: c}
+
+			paused, result, err := r.handlePausedRollout(ctx)
+			if err != nil {
+				t.Fatalf("handlePausedRollout() unexpected err: %v", err)
+			}
+			if paused != tt.wantPaused {
+				t.Errorf("handlePausedRollout() paused: %t, want: %t", paused, tt.wantPaused)
+			}
+			if result.RequeueAfter > defaultRequeueTime {
+				t.Errorf("handlePausedRollout() requeue after %s, expected at most %s", result.RequeueAfter, defaultRequeueTime)
+			}
+
+			cond := r.CtrlConfig.Status.GetCondition(v1alpha2.MachineConfigPoolPaused)
+			if tt.wantReason == "" {
+				if cond != nil {
+					t.Errorf("handlePausedRollout() unexpected condition: %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Reason != tt.wantReason {
+				t.Fatalf("handlePausedRollout() condition: %+v, want reason: %s", cond, tt.wantReason)
+			}
+
+			mcp := &mcv1.MachineConfigPool{}
+			if err := c.Get(ctx, types.NamespacedName{Name: ProfilingMCPName}, mcp); err != nil {
+				t.Fatalf("failed to get MCP: %v", err)
+			}
+			if mcp.Spec.Paused != tt.wantMCPPaused {
+				t.Errorf("MCP paused: %t, want: %t", mcp.Spec.Paused, tt.wantMCPPaused)
+			}
+			if !tt.wantMCPPaused && r.CtrlConfig.Status.MachineConfigPoolUnpauseTime != nil {
+				t.Errorf("unpause time expected to be reset, got: %v", r.CtrlConfig.Status.MachineConfigPoolUnpauseTime)
+			}
+
+			nomc := &v1alpha2.NodeObservabilityMachineConfig{}
+			if err := c.Get(ctx, types.NamespacedName{Name: TestControllerResourceName}, nomc); err != nil {
+				t.Fatalf("failed to get NOMC: %v", err)
+			}
+			if _, ok := nomc.Annotations[UnpauseMCPAnnotation]; ok {
+				t.Errorf("unpause annotation expected to be removed")
+			}
+		})
+	}
+}
+
+func TestCreateProfMCPPaused(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Spec.MachineConfigRolloutStrategy = v1alpha2.PausedMachineConfigRolloutStrategy
+	r.CtrlConfig.Spec.MachineConfigRolloutPauseDuration = &metav1.Duration{Duration: 2 * time.Hour}
+	c := fake.NewClientBuilder().WithScheme(test.Scheme).Build()
+	r.impl = &defaultImpl{Client: c}
+
+	before := time.Now()
+	if err := r.createProfMCP(ctx); err != nil {
+		t.Fatalf("createProfMCP() unexpected err: %v", err)
+	}
+
+	mcp := &mcv1.MachineConfigPool{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ProfilingMCPName}, mcp); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	if !mcp.Spec.Paused {
+		t.Errorf("MCP expected to be created paused")
+	}
+	unpauseTime := r.CtrlConfig.Status.MachineConfigPoolUnpauseTime
+	if unpauseTime == nil || unpauseTime.Time.Before(before.Add(2*time.Hour)) {
+		t.Errorf("unexpected unpause time: %v", unpauseTime)
+	}
+	if cond := r.CtrlConfig.Status.GetCondition(v1alpha2.MachineConfigPoolPaused); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("unexpected paused condition: %+v", cond)
+	}
+}
+
+func TestEnsureReqNodeLabelExists(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+
+	tests := []struct {
+		name                 string
+		nodeSelector         map[string]string
+		requiredNodeAffinity *corev1.NodeSelector
+		excluded             bool
+		wantLabeled          []string
+		wantEnsured          bool
+	}{
+		{
+			name:        "all worker nodes targeted",
+			wantLabeled: []string{"test-worker-1", "test-worker-2", "test-worker-3"},
+			// the labels are confirmed on the next reconcile
+			// as they were already present on some nodes only
+			wantEnsured: false,
+		},
+		{
+			name:         "node selector restricts the targeted nodes",
+			nodeSelector: map[string]string{"kubernetes.io/hostname": "test-worker-2"},
+			wantLabeled:  []string{"test-worker-2"},
+			wantEnsured:  true,
+		},
+		{
+			name: "required node affinity restricts the targeted nodes",
+			requiredNodeAffinity: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: "kubernetes.io/hostname", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"test-worker-1"}},
+						},
+					},
+				},
+			},
+			wantLabeled: []string{"test-worker-2", "test-worker-3"},
+			wantEnsured: true,
+		},
+		{
+			name:         "no node targeted",
+			nodeSelector: map[string]string{"kubernetes.io/hostname": "unknown"},
+		},
+		{
+			name:        "excluded node loses its label",
+			excluded:    true,
+			wantLabeled: []string{"test-worker-2", "test-worker-3"},
+			wantEnsured: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler()
+			r.CtrlConfig.Spec.NodeSelector = tt.nodeSelector
+			r.CtrlConfig.Spec.RequiredNodeAffinity = tt.requiredNodeAffinity
+			// test-worker-1 is labeled from a previous selection
+			nodes := testWorkerNodes()
+			nodes[0].(*corev1.Node).Labels[NodeObservabilityNodeRoleLabelName] = Empty
+			if tt.excluded {
+				nodes[0].(*corev1.Node).Labels[v1alpha2.ExcludeNodeLabel] = "true"
+			}
+			c := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodes...).Build()
+			r.impl = &defaultImpl{Client: c}
+
+			ensured, err := r.ensureReqNodeLabelExists(ctx)
+			if err != nil {
+				t.Fatalf("ensureReqNodeLabelExists() unexpected err: %v", err)
+			}
+			if ensured != tt.wantEnsured {
+				t.Errorf("ensureReqNodeLabelExists() ensured: %v, want: %v", ensured, tt.wantEnsured)
+			}
+
+			labeled := &corev1.NodeList{}
+			if err := c.List(ctx, labeled, client.MatchingLabels{NodeObservabilityNodeRoleLabelName: Empty}); err != nil {
+				t.Fatalf("failed to list nodes: %v", err)
+			}
+			var labeledNames []string
+			for _, node := range labeled.Items {
+				labeledNames = append(labeledNames, node.Name)
+			}
+			if !reflect.DeepEqual(labeledNames, tt.wantLabeled) {
+				t.Errorf("labeled nodes: %v, want: %v", labeledNames, tt.wantLabeled)
+			}
+		})
+	}
+}
+
+func TestNodeExclusionChanged(t *testing.T) {
+	node := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-worker-1", Labels: labels}}
+	}
+	tests := []struct {
+		name      string
+		oldLabels map[string]string
+		newLabels map[string]string
+		want      bool
+	}{
+		{
+			name:      "node excluded",
+			oldLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+			newLabels: map[string]string{"node-role.kubernetes.io/worker": "", v1alpha2.ExcludeNodeLabel: "true"},
+			want:      true,
+		},
+		{
+			name:      "node no longer excluded",
+			oldLabels: map[string]string{v1alpha2.ExcludeNodeLabel: "true"},
+			newLabels: map[string]string{v1alpha2.ExcludeNodeLabel: "false"},
+			want:      true,
+		},
+		{
+			name:      "other label changed",
+			oldLabels: map[string]string{v1alpha2.ExcludeNodeLabel: "true"},
+			newLabels: map[string]string{v1alpha2.ExcludeNodeLabel: "true", "zone": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event.UpdateEvent{ObjectOld: node(tt.oldLabels), ObjectNew: node(tt.newLabels)}
+			if got := nodeExclusionChanged().Update(e); got != tt.want {
+				t.Errorf("nodeExclusionChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesNodeSelectorTerms(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-worker-1",
+			Labels: map[string]string{"zone": "a", "gpus": "2"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		nodeSelector *corev1.NodeSelector
+		want         bool
+		wantErr      bool
+	}{
+		{
+			name: "nil node selector",
+			want: true,
+		},
+		{
+			name:         "empty term",
+			nodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}},
+		},
+		{
+			name: "matching expression",
+			nodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a", "b"}}}},
+			}},
+			want: true,
+		},
+		{
+			name: "all expressions of a term must match",
+			nodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "zone", Operator: corev1.NodeSelectorOpExists},
+					{Key: "gpus", Operator: corev1.NodeSelectorOpGt, Values: []string{"4"}},
+				}},
+			}},
+		},
+		{
+			name: "any term can match",
+			nodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpDoesNotExist}}},
+				{MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"test-worker-1"}}}},
+			}},
+			want: true,
+		},
+		{
+			name: "invalid operator",
+			nodeSelector: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: "Unknown"}}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchesNodeSelectorTerms(node, tt.nodeSelector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchesNodeSelectorTerms() err: %v, wantErr: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("matchesNodeSelectorTerms() got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
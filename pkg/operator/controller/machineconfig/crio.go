@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,29 +33,123 @@ import (
 	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 )
 
-// enableCrioProf creates MachineConfig CR for CRI-O profiling.
+// enableCrioProf ensures the MachineConfig CRs for CRI-O profiling, one per profiling pool,
+// and records the hash of the desired ones in the status.
 func (r *MachineConfigReconciler) enableCrioProf(ctx context.Context) error {
-	criomc, err := r.getCrioProfMachineConfig()
+	var desired []*mcv1.MachineConfig
+	for _, pool := range r.profilingPools() {
+		criomc, err := r.getCrioProfMachineConfig(pool)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, criomc)
+	}
+	if r.CtrlConfig.Spec.IncludeMasterNodes {
+		criomc, err := r.getCrioProfMasterMachineConfig()
+		if err != nil {
+			return err
+		}
+		desired = append(desired, criomc)
+	}
+
+	var hashes []string
+	for _, criomc := range desired {
+		if err := ctrlutil.SetControllerReference(r.CtrlConfig, criomc, r.Scheme); err != nil {
+			return fmt.Errorf("failed to update controller reference in crio profiling machine config: %w", err)
+		}
+		hash, err := r.ensureCrioProfMachineConfig(ctx, criomc)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, fmt.Sprintf("%s=%s", criomc.Name, hash))
+	}
+	r.CtrlConfig.Status.RenderedHash = hashOf([]byte(strings.Join(hashes, ",")))
+	return nil
+}
+
+// ensureCrioProfMachineConfig creates the given MachineConfig CR for CRI-O profiling,
+// or re-applies it if the live one differs from it. The live one is left untouched otherwise,
+// not to trigger a needless rollout. Returns the hash of the desired MachineConfig.
+func (r *MachineConfigReconciler) ensureCrioProfMachineConfig(ctx context.Context, desired *mcv1.MachineConfig) (string, error) {
+	desiredHash, err := machineConfigHash(desired)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if err := ctrlutil.SetControllerReference(r.CtrlConfig, criomc, r.Scheme); err != nil {
-		return fmt.Errorf("failed to update controller reference in crio profiling machine config: %w", err)
+	current := &mcv1.MachineConfig{}
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: desired.Name}, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get crio profiling machine config %s: %w", desired.Name, err)
+		}
+		if err := r.ClientCreate(ctx, desired); err != nil && !errors.IsAlreadyExists(err) {
+			return "", fmt.Errorf("failed to create crio profiling machine config: %w", err)
+		}
+		r.Log.V(1).Info("Successfully created MachineConfig to enable CRI-O profiling", "CrioProfilingConfigName", desired.Name)
+		return desiredHash, nil
 	}
 
-	if err := r.ClientCreate(ctx, criomc); err != nil && !errors.IsAlreadyExists(err) {
-		return fmt.Errorf("failed to create crio profiling machine config: %w", err)
+	currentHash, err := machineConfigHash(current)
+	if err != nil {
+		return "", err
+	}
+	if currentHash == desiredHash {
+		return desiredHash, nil
 	}
 
-	r.Log.V(1).Info("Successfully created MachineConfig to enable CRI-O profiling", "CrioProfilingConfigName", CrioProfilingConfigName)
-	return nil
+	r.Log.Info("MachineConfig to enable CRI-O profiling differs from the desired one, re-applying it",
+		"CrioProfilingConfigName", desired.Name, "CurrentHash", currentHash, "DesiredHash", desiredHash)
+	updated := current.DeepCopy()
+	updated.Spec = desired.Spec
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		updated.Labels[key] = value
+	}
+	if err := r.ClientUpdate(ctx, updated); err != nil {
+		return "", fmt.Errorf("failed to update crio profiling machine config %s: %w", desired.Name, err)
+	}
+	return desiredHash, nil
 }
 
-// disableCrioProf deletes MachineConfig CR for CRI-O profiling if it exists.
+// disableCrioProf deletes the MachineConfig CRs for CRI-O profiling if they exist,
+// including the one rolled out on the master nodes.
 func (r *MachineConfigReconciler) disableCrioProf(ctx context.Context) error {
+	for _, pool := range r.profilingPools() {
+		if err := r.deleteCrioProfMachineConfig(ctx, pool.machineConfigName()); err != nil {
+			return err
+		}
+	}
+	if err := r.deleteCrioProfMachineConfig(ctx, CrioProfilingMasterConfigName); err != nil {
+		return err
+	}
+	r.CtrlConfig.Status.RenderedHash = ""
+	return nil
+}
+
+// disableNamedPoolCrioProf deletes the MachineConfig CR for CRI-O profiling of the named MCP if it exists,
+// its nodes are rolled back by the named MCP itself. Returns true if it was deleted.
+func (r *MachineConfigReconciler) disableNamedPoolCrioProf(ctx context.Context) (bool, error) {
+	if r.CtrlConfig.Spec.MachineConfigPoolName == "" {
+		return false, nil
+	}
+	name := CrioProfilingConfigNameForPool(r.CtrlConfig.Spec.MachineConfigPoolName)
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: name}, &mcv1.MachineConfig{}); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get crio profiling machine config %s: %w", name, err)
+	}
+	if err := r.deleteCrioProfMachineConfig(ctx, name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteCrioProfMachineConfig deletes the MachineConfig CR for CRI-O profiling with the given name if it exists.
+func (r *MachineConfigReconciler) deleteCrioProfMachineConfig(ctx context.Context, name string) error {
 	criomc := &mcv1.MachineConfig{}
-	if err := r.ClientGet(ctx, types.NamespacedName{Name: CrioProfilingConfigName}, criomc); err != nil {
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: name}, criomc); err != nil {
 		if errors.IsNotFound(err) {
 			return nil
 		}
@@ -65,13 +160,13 @@ func (r *MachineConfigReconciler) disableCrioProf(ctx context.Context) error {
 		return fmt.Errorf("failed to remove crio profiling machine config: %w", err)
 	}
 
-	r.Log.V(1).Info("Successfully removed MachineConfig to disable CRI-O profiling", "CrioProfilingConfigName", CrioProfilingConfigName)
+	r.Log.V(1).Info("Successfully removed MachineConfig to disable CRI-O profiling", "CrioProfilingConfigName", name)
 
 	return nil
 }
 
-// getCrioProfMachineConfig returns the MachineConfig CR definition to enable CRI-O profiling.
-func (r *MachineConfigReconciler) getCrioProfMachineConfig() (*mcv1.MachineConfig, error) {
+// getCrioProfMachineConfig returns the MachineConfig CR definition to enable CRI-O profiling on the given pool.
+func (r *MachineConfigReconciler) getCrioProfMachineConfig(pool profilingPool) (*mcv1.MachineConfig, error) {
 	config := getCrioProfIgnitionConfig()
 
 	rawExt, err := convertIgnConfToRawExt(config)
@@ -85,8 +180,11 @@ func (r *MachineConfigReconciler) getCrioProfMachineConfig() (*mcv1.MachineConfi
 			APIVersion: MCAPIVersion,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   CrioProfilingConfigName,
-			Labels: MachineConfigLabels,
+			Name: pool.machineConfigName(),
+			Labels: map[string]string{
+				MCRoleLabelName:    pool.name,
+				ManagedByLabelName: ManagedByLabelValue,
+			},
 		},
 		Spec: mcv1.MachineConfigSpec{
 			Config: rawExt,
@@ -94,6 +192,17 @@ func (r *MachineConfigReconciler) getCrioProfMachineConfig() (*mcv1.MachineConfi
 	}, nil
 }
 
+// getCrioProfMasterMachineConfig returns the MachineConfig CR definition
+// to enable CRI-O profiling on the nodes of the master MCP.
+func (r *MachineConfigReconciler) getCrioProfMasterMachineConfig() (*mcv1.MachineConfig, error) {
+	mc, err := r.getCrioProfMachineConfig(profilingPool{name: MasterNodeMCPName})
+	if err != nil {
+		return nil, err
+	}
+	mc.Name = CrioProfilingMasterConfigName
+	return mc, nil
+}
+
 // getCrioProfIgnitionConfig returns the ignition config to enable CRI-O profiling.
 func getCrioProfIgnitionConfig() igntypes.Config {
 	dropins := []igntypes.Dropin{
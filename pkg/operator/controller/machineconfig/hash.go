@@ -0,0 +1,56 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// machineConfigHash returns a stable hash of the parts of the MachineConfig set by the operator:
+// its spec and the labels of the operator. The spec is normalized first,
+// the hash doesn't depend on the order of the keys of the ignition config.
+func machineConfigHash(mc *mcv1.MachineConfig) (string, error) {
+	spec, err := json.Marshal(mc.Spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the spec of machine config %s: %w", mc.Name, err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(spec, &normalized); err != nil {
+		return "", fmt.Errorf("failed to normalize the spec of machine config %s: %w", mc.Name, err)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"labels": map[string]string{
+			MCRoleLabelName:    mc.Labels[MCRoleLabelName],
+			ManagedByLabelName: mc.Labels[ManagedByLabelName],
+		},
+		"spec": normalized,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal machine config %s: %w", mc.Name, err)
+	}
+	return hashOf(data), nil
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
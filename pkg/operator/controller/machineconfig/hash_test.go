@@ -0,0 +1,170 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestMachineConfigHash(t *testing.T) {
+	r := testReconciler()
+	desired, err := r.getCrioProfMachineConfig(profilingPool{name: ProfilingMCPName})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	desiredHash, err := machineConfigHash(desired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		mutate        func(*mcv1.MachineConfig)
+		expectedEqual bool
+	}{
+		{
+			name:          "same machine config",
+			mutate:        func(*mcv1.MachineConfig) {},
+			expectedEqual: true,
+		},
+		{
+			name: "ignition config keys reordered",
+			mutate: func(mc *mcv1.MachineConfig) {
+				var config map[string]json.RawMessage
+				if err := json.Unmarshal(mc.Spec.Config.Raw, &config); err != nil {
+					t.Fatalf("failed to unmarshal ignition config: %v", err)
+				}
+				keys := make([]string, 0, len(config))
+				for key := range config {
+					keys = append(keys, key)
+				}
+				sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+				var fields []string
+				for _, key := range keys {
+					fields = append(fields, fmt.Sprintf("%q:%s", key, config[key]))
+				}
+				mc.Spec.Config.Raw = []byte("{" + strings.Join(fields, ",") + "}")
+			},
+			expectedEqual: true,
+		},
+		{
+			name: "other labels and annotations added",
+			mutate: func(mc *mcv1.MachineConfig) {
+				mc.Labels["added-by"] = "admin"
+				mc.Annotations = map[string]string{"note": "value"}
+				mc.ResourceVersion = "42"
+			},
+			expectedEqual: true,
+		},
+		{
+			name: "kernel arguments added",
+			mutate: func(mc *mcv1.MachineConfig) {
+				mc.Spec.KernelArguments = []string{"debug"}
+			},
+		},
+		{
+			name: "ignition config changed",
+			mutate: func(mc *mcv1.MachineConfig) {
+				mc.Spec.Config.Raw = []byte(`{"ignition":{"version":"3.2.0"}}`)
+			},
+		},
+		{
+			name: "role label changed",
+			mutate: func(mc *mcv1.MachineConfig) {
+				mc.Labels[MCRoleLabelName] = "worker"
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := desired.DeepCopy()
+			tc.mutate(mc)
+			hash, err := machineConfigHash(mc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (hash == desiredHash) != tc.expectedEqual {
+				t.Errorf("expected equal hashes to be %t, got %s and %s", tc.expectedEqual, desiredHash, hash)
+			}
+		})
+	}
+}
+
+func TestEnsureCrioProfMachineConfig(t *testing.T) {
+	r := testReconciler()
+	c := fake.NewClientBuilder().WithScheme(test.Scheme).Build()
+	r.impl = &defaultImpl{Client: c}
+	desired, err := r.getCrioProfMachineConfig(profilingPool{name: ProfilingMCPName})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key := types.NamespacedName{Name: desired.Name}
+	get := func() *mcv1.MachineConfig {
+		mc := &mcv1.MachineConfig{}
+		if err := c.Get(context.TODO(), key, mc); err != nil {
+			t.Fatalf("failed to get machineconfig: %v", err)
+		}
+		return mc
+	}
+
+	hash, err := r.ensureCrioProfMachineConfig(context.TODO(), desired.DeepCopy())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := get()
+
+	// nothing is updated while the live machine config matches the desired one
+	if again, err := r.ensureCrioProfMachineConfig(context.TODO(), desired.DeepCopy()); err != nil || again != hash {
+		t.Fatalf("expected the same hash %s, got %s (error: %v)", hash, again, err)
+	}
+	if current := get(); current.ResourceVersion != created.ResourceVersion {
+		t.Errorf("expected the machineconfig not to be updated, resource version changed from %s to %s", created.ResourceVersion, current.ResourceVersion)
+	}
+
+	// the drifted machine config is re-applied
+	drifted := get()
+	drifted.Spec.KernelArguments = []string{"debug"}
+	drifted.Labels["added-by"] = "admin"
+	if err := c.Update(context.TODO(), drifted); err != nil {
+		t.Fatalf("failed to update machineconfig: %v", err)
+	}
+	if _, err := r.ensureCrioProfMachineConfig(context.TODO(), desired.DeepCopy()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current := get()
+	if len(current.Spec.KernelArguments) != 0 {
+		t.Errorf("expected the kernel arguments to be removed, got %v", current.Spec.KernelArguments)
+	}
+	if current.Labels["added-by"] != "admin" {
+		t.Errorf("expected the other labels to be kept, got %v", current.Labels)
+	}
+	if currentHash, _ := machineConfigHash(current); currentHash != hash {
+		t.Errorf("expected the re-applied machineconfig to have hash %s, got %s", hash, currentHash)
+	}
+}
@@ -20,14 +20,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -40,26 +43,61 @@ const (
 	mcpChangePollInterval = 2 * time.Second
 )
 
-// createProfMCP creates MachineConfigPool CR to enable the CRI-O profiling on the targeted nodes.
+// createProfMCP creates the MachineConfigPool CRs to enable the CRI-O profiling on the targeted nodes,
+// one per profiling pool.
 func (r *MachineConfigReconciler) createProfMCP(ctx context.Context) error {
-	mcp := r.getCrioProfMachineConfigPool(ProfilingMCPName)
+	var paused []string
+	for _, pool := range r.profilingPools() {
+		if pool.named {
+			continue
+		}
+		mcp := r.getCrioProfMachineConfigPool(pool)
 
-	if err := ctrlutil.SetControllerReference(r.CtrlConfig, mcp, r.Scheme); err != nil {
-		return fmt.Errorf("failed to update controller reference in crio profiling machine config pool: %w", err)
-	}
+		if err := ctrlutil.SetControllerReference(r.CtrlConfig, mcp, r.Scheme); err != nil {
+			return fmt.Errorf("failed to update controller reference in crio profiling machine config pool: %w", err)
+		}
 
-	if err := r.ClientCreate(ctx, mcp); err != nil && !apierrors.IsAlreadyExists(err) {
-		return fmt.Errorf("failed to create crio profiling machine config pool: %w", err)
+		if err := r.ClientCreate(ctx, mcp); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return fmt.Errorf("failed to create crio profiling machine config pool: %w", err)
+		}
+		if mcp.Spec.Paused {
+			paused = append(paused, mcp.Name)
+			r.trackPausedMCP(mcp.Name)
+		}
+
+		r.Log.V(1).Info("Successfully created MachineConfigPool to enable CRI-O profiling", "MCPName", mcp.Name, "Paused", mcp.Spec.Paused)
 	}
 
-	r.Log.V(1).Info("Successfully created MachineConfigPool to enable CRI-O profiling", "MCPName", ProfilingMCPName)
+	if len(paused) != 0 {
+		unpauseTime := metav1.NewTime(time.Now().Add(r.rolloutPauseDuration()))
+		r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = &unpauseTime
+		r.CtrlConfig.Status.SetCondition(v1alpha2.MachineConfigPoolPaused, metav1.ConditionTrue, v1alpha2.ReasonPaused, pausedMCPMessage(paused, unpauseTime))
+	}
 	return nil
 }
 
-// deleteProfMCP deletes MachineConfigPool CR which enables the CRI-O profiling on the nodes if it exists.
+// deleteProfMCP deletes the MachineConfigPool CRs which enable the CRI-O profiling on the nodes if they exist.
 func (r *MachineConfigReconciler) deleteProfMCP(ctx context.Context) error {
+	for _, pool := range r.profilingPools() {
+		if pool.named {
+			continue
+		}
+		if err := r.deleteCrioProfMachineConfigPool(ctx, pool.name); err != nil {
+			return err
+		}
+	}
+	r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = nil
+	r.CtrlConfig.Status.PausedMachineConfigPools = nil
+	return nil
+}
+
+// deleteCrioProfMachineConfigPool deletes the MachineConfigPool CR with the given name if it exists.
+func (r *MachineConfigReconciler) deleteCrioProfMachineConfigPool(ctx context.Context, name string) error {
 	mcp := &mcv1.MachineConfigPool{}
-	if err := r.ClientGet(ctx, types.NamespacedName{Name: ProfilingMCPName}, mcp); err != nil {
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: name}, mcp); err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil
 		}
@@ -70,13 +108,13 @@ func (r *MachineConfigReconciler) deleteProfMCP(ctx context.Context) error {
 		return fmt.Errorf("failed to remove crio profiling machine config pool: %w", err)
 	}
 
-	r.Log.V(1).Info("Successfully removed MachineConfigPool which was enabling CRI-O profiling", "MCPName", ProfilingMCPName)
+	r.Log.V(1).Info("Successfully removed MachineConfigPool which was enabling CRI-O profiling", "MCPName", name)
 	return nil
 }
 
 // getCrioProfMachineConfigPool returns the MachineConfigPool CR definition
-// to enable CRI-O profiling on the targeted nodes.
-func (r *MachineConfigReconciler) getCrioProfMachineConfigPool(name string) *mcv1.MachineConfigPool {
+// to enable CRI-O profiling on the nodes of the given pool.
+func (r *MachineConfigReconciler) getCrioProfMachineConfigPool(pool profilingPool) *mcv1.MachineConfigPool {
 
 	return &mcv1.MachineConfigPool{
 		TypeMeta: metav1.TypeMeta{
@@ -84,10 +122,13 @@ func (r *MachineConfigReconciler) getCrioProfMachineConfigPool(name string) *mcv
 			APIVersion: MCAPIVersion,
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   name,
-			Labels: MachineConfigLabels,
+			Name: pool.name,
+			Labels: map[string]string{
+				MCRoleLabelName: pool.name,
+			},
 		},
 		Spec: mcv1.MachineConfigPoolSpec{
+			Paused: r.CtrlConfig.Spec.MachineConfigRolloutStrategy == v1alpha2.PausedMachineConfigRolloutStrategy,
 			MachineConfigSelector: &metav1.LabelSelector{
 				MatchExpressions: []metav1.LabelSelectorRequirement{
 					{
@@ -95,20 +136,22 @@ func (r *MachineConfigReconciler) getCrioProfMachineConfigPool(name string) *mcv
 						Operator: metav1.LabelSelectorOpIn,
 						Values: []string{
 							WorkerNodeRoleName,
-							NodeObservabilityNodeRoleName,
+							pool.name,
 						},
 					},
 				},
 			},
 			NodeSelector: &metav1.LabelSelector{
-				MatchLabels: NodeSelectorLabels,
+				MatchLabels: map[string]string{
+					pool.nodeRoleLabel(): Empty,
+				},
 			},
 			Configuration: mcv1.MachineConfigPoolStatusConfiguration{
 				Source: []corev1.ObjectReference{
 					{
 						APIVersion: MCAPIVersion,
 						Kind:       MCKind,
-						Name:       CrioProfilingConfigName,
+						Name:       pool.machineConfigName(),
 					},
 				},
 			},
@@ -116,27 +159,277 @@ func (r *MachineConfigReconciler) getCrioProfMachineConfigPool(name string) *mcv
 	}
 }
 
-// checkNodeObservabilityMCPStatus is for reconciling update status of all machines in profiling MCP
-func (r *MachineConfigReconciler) checkNodeObservabilityMCPStatus(ctx context.Context) (ctrl.Result, error) {
+// checkNamedMCP checks that the MachineConfigPool named in the spec exists,
+// that it selects the CRI-O profiling MachineConfig and all the targeted nodes but the control plane ones.
+// The DebugReady condition reports why it cannot be used. Returns true if it can be used.
+func (r *MachineConfigReconciler) checkNamedMCP(ctx context.Context) (bool, error) {
+	name := r.CtrlConfig.Spec.MachineConfigPoolName
 	mcp := &mcv1.MachineConfigPool{}
-	if err := r.ClientGet(ctx, types.NamespacedName{Name: ProfilingMCPName}, mcp); err != nil {
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: name}, mcp); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get machineconfigpool %s: %w", name, err)
+		}
+		r.setNamedMCPUnusable(v1alpha2.ReasonMachineConfigPoolNotFound, fmt.Sprintf("machineconfigpool %s not found, debug configurations not applied", name))
+		return false, nil
+	}
+
+	mcSelector, err := metav1.LabelSelectorAsSelector(mcp.Spec.MachineConfigSelector)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the machine config selector of machineconfigpool %s: %w", name, err)
+	}
+	if !mcSelector.Matches(labels.Set{MCRoleLabelName: name, ManagedByLabelName: ManagedByLabelValue}) {
+		r.setNamedMCPUnusable(v1alpha2.ReasonMachineConfigPoolMismatch,
+			fmt.Sprintf("machineconfigpool %s does not select the machineconfigs with role %s, debug configurations not applied", name, name))
+		return false, nil
+	}
+
+	nodeSelector, err := metav1.LabelSelectorAsSelector(mcp.Spec.NodeSelector)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the node selector of machineconfigpool %s: %w", name, err)
+	}
+	nodeList, err := r.listTargetedNodes(ctx)
+	if err != nil {
+		return false, err
+	}
+	workerNodes, _ := splitControlPlaneNodes(nodeList.Items)
+	for _, node := range workerNodes {
+		if !nodeSelector.Matches(labels.Set(node.Labels)) {
+			r.setNamedMCPUnusable(v1alpha2.ReasonMachineConfigPoolMismatch,
+				fmt.Sprintf("machineconfigpool %s does not select node %s, debug configurations not applied", name, node.Name))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// setNamedMCPUnusable reports why the named MachineConfigPool cannot be used,
+// the warning event is only recorded when the reason or the message changes.
+func (r *MachineConfigReconciler) setNamedMCPUnusable(reason, msg string) {
+	r.Log.V(1).Info("Named MachineConfigPool cannot be used, retry later", "MCPName", r.CtrlConfig.Spec.MachineConfigPoolName, "Reason", reason)
+	if r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, reason, msg) {
+		r.EventRecorder.Event(r.CtrlConfig, corev1.EventTypeWarning, reason, msg)
+	}
+}
+
+// rollbackMCPName returns the name of the MachineConfigPool which rolls back
+// the CRI-O profiling: the named one if any, the worker one otherwise.
+func (r *MachineConfigReconciler) rollbackMCPName() string {
+	if r.CtrlConfig.Spec.MachineConfigPoolName != "" {
+		return r.CtrlConfig.Spec.MachineConfigPoolName
+	}
+	return WorkerNodeMCPName
+}
+
+// workerMCPExists returns true if the worker MachineConfigPool exists.
+func (r *MachineConfigReconciler) workerMCPExists(ctx context.Context) (bool, error) {
+	mcp := &mcv1.MachineConfigPool{}
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: WorkerNodeMCPName}, mcp); err != nil {
 		if apierrors.IsNotFound(err) {
-			r.Log.V(1).Info("Profiling MCP does not exist, skipping status check", "MCP", ProfilingMCPName)
-			return ctrl.Result{}, nil
+			return false, nil
 		}
-		return ctrl.Result{}, err
+		return false, err
+	}
+	return true, nil
+}
+
+// getProfMCPs returns the existing MachineConfigPools of the profiling pools.
+func (r *MachineConfigReconciler) getProfMCPs(ctx context.Context) ([]*mcv1.MachineConfigPool, error) {
+	var mcps []*mcv1.MachineConfigPool
+	for _, pool := range r.profilingPools() {
+		mcp := &mcv1.MachineConfigPool{}
+		if err := r.ClientGet(ctx, types.NamespacedName{Name: pool.name}, mcp); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		mcps = append(mcps, mcp)
+	}
+	return mcps, nil
+}
+
+// deleteStaleProfilingPools deletes the MachineConfigs and MachineConfigPools
+// created for the profiling pools which are no longer requested.
+func (r *MachineConfigReconciler) deleteStaleProfilingPools(ctx context.Context) error {
+	requested := map[string]struct{}{}
+	for _, pool := range r.profilingPools() {
+		requested[pool.name] = struct{}{}
+	}
+	if r.CtrlConfig.Spec.IncludeMasterNodes {
+		requested[MasterNodeMCPName] = struct{}{}
+	}
+
+	mcpList := &mcv1.MachineConfigPoolList{}
+	if err := r.ClientList(ctx, mcpList); err != nil {
+		return fmt.Errorf("failed to list machineconfigpools: %w", err)
+	}
+	for i := range mcpList.Items {
+		mcp := &mcpList.Items[i]
+		if _, ok := requested[mcp.Name]; ok || !IsProfilingMCPName(mcp.Name) || !metav1.IsControlledBy(mcp, r.CtrlConfig) {
+			continue
+		}
+		if err := r.deleteCrioProfMachineConfigPool(ctx, mcp.Name); err != nil {
+			return err
+		}
+	}
+
+	mcList := &mcv1.MachineConfigList{}
+	if err := r.ClientList(ctx, mcList, client.MatchingLabels{ManagedByLabelName: ManagedByLabelValue}); err != nil {
+		return fmt.Errorf("failed to list machineconfigs: %w", err)
+	}
+	for i := range mcList.Items {
+		mc := &mcList.Items[i]
+		if _, ok := requested[mc.Labels[MCRoleLabelName]]; ok || !metav1.IsControlledBy(mc, r.CtrlConfig) {
+			continue
+		}
+		if err := r.deleteCrioProfMachineConfig(ctx, mc.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlePausedRollout keeps the profiling MCPs paused until the pause duration elapsed
+// or until the unpause is requested with the annotation, unpauses them otherwise.
+// Returns true if any of the profiling MCPs was paused.
+func (r *MachineConfigReconciler) handlePausedRollout(ctx context.Context) (bool, ctrl.Result, error) {
+	// the named MCP is not managed by the operator, it's never unpaused
+	if r.CtrlConfig.Spec.MachineConfigPoolName != "" {
+		return false, ctrl.Result{}, nil
+	}
+	mcps, err := r.getProfMCPs(ctx)
+	if err != nil {
+		return false, ctrl.Result{}, err
 	}
 
-	if mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdating) &&
-		mcp.Status.DegradedMachineCount == 0 {
-		msg := "Machine config update to enable debugging in progress"
+	var paused []*mcv1.MachineConfigPool
+	var pausedNames []string
+	for _, mcp := range mcps {
+		if mcp.Spec.Paused {
+			paused = append(paused, mcp)
+			pausedNames = append(pausedNames, mcp.Name)
+			// the status may not have been updated if the operator crashed after creating the MCP
+			if metav1.IsControlledBy(mcp, r.CtrlConfig) {
+				r.trackPausedMCP(mcp.Name)
+			}
+		}
+	}
+	if len(paused) == 0 {
+		// the MCPs may have been unpaused when the operator shut down
+		r.CtrlConfig.Status.PausedMachineConfigPools = nil
+		return false, ctrl.Result{}, nil
+	}
+
+	if r.CtrlConfig.Status.MachineConfigPoolUnpauseTime == nil {
+		unpauseTime := metav1.NewTime(time.Now().Add(r.rolloutPauseDuration()))
+		r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = &unpauseTime
+	}
+	unpauseTime := *r.CtrlConfig.Status.MachineConfigPoolUnpauseTime
+
+	_, unpauseRequested := r.CtrlConfig.Annotations[UnpauseMCPAnnotation]
+	if untilUnpause := time.Until(unpauseTime.Time); !unpauseRequested && untilUnpause > 0 {
+		msg := pausedMCPMessage(pausedNames, unpauseTime)
 		r.Log.V(1).Info(msg)
-		r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+		r.CtrlConfig.Status.SetCondition(v1alpha2.MachineConfigPoolPaused, metav1.ConditionTrue, v1alpha2.ReasonPaused, msg)
+		if untilUnpause > defaultRequeueTime {
+			untilUnpause = defaultRequeueTime
+		}
+		return true, ctrl.Result{RequeueAfter: untilUnpause}, nil
+	}
+
+	for _, mcp := range paused {
+		// only the paused field is patched not to conflict with the updates of MCO
+		patch, _ := newPatch(replace, ResourceSpecPath, map[string]interface{}{"paused": false})
+		if err := r.ClientPatch(ctx, mcp, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return true, ctrl.Result{RequeueAfter: defaultRequeueTime}, fmt.Errorf("failed to unpause %s MCP: %w", mcp.Name, err)
+		}
+		r.EventRecorder.Eventf(r.CtrlConfig, corev1.EventTypeNormal, "ConfigUpdate", "%s MCP unpaused", mcp.Name)
+		r.untrackPausedMCP(mcp.Name)
+	}
+	if unpauseRequested {
+		if err := r.removeUnpauseAnnotation(ctx); err != nil {
+			return true, ctrl.Result{RequeueAfter: defaultRequeueTime}, fmt.Errorf("failed to remove %s annotation: %w", UnpauseMCPAnnotation, err)
+		}
+	}
+
+	r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = nil
+	msg := fmt.Sprintf("%s MCP unpaused, machine config update to enable debugging started", strings.Join(pausedNames, ", "))
+	r.Log.V(1).Info(msg)
+	r.CtrlConfig.Status.SetCondition(v1alpha2.MachineConfigPoolPaused, metav1.ConditionFalse, v1alpha2.ReasonUnpaused, msg)
+
+	// give MCO the time to start updating the machines
+	return true, ctrl.Result{RequeueAfter: defaultRequeueTime}, nil
+}
 
+// removeUnpauseAnnotation removes the annotation requesting
+// the unpause of the profiling MCP from NodeObservabilityMachineConfig
+func (r *MachineConfigReconciler) removeUnpauseAnnotation(ctx context.Context) error {
+	nomc := &v1alpha2.NodeObservabilityMachineConfig{}
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: r.CtrlConfig.Name}, nomc); err != nil {
+		return err
+	}
+	if _, ok := nomc.Annotations[UnpauseMCPAnnotation]; !ok {
+		return nil
+	}
+	patch, _ := newPatch(remove, ResourceAnnotationsPath, map[string]interface{}{UnpauseMCPAnnotation: nil})
+	return r.ClientPatch(ctx, nomc, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// rolloutPauseDuration returns the time the profiling MCP stays paused
+func (r *MachineConfigReconciler) rolloutPauseDuration() time.Duration {
+	if r.CtrlConfig.Spec.MachineConfigRolloutPauseDuration != nil {
+		return r.CtrlConfig.Spec.MachineConfigRolloutPauseDuration.Duration
+	}
+	return defaultRolloutPauseDuration
+}
+
+// pausedMCPMessage returns the message of the paused condition
+func pausedMCPMessage(names []string, unpauseTime metav1.Time) string {
+	return fmt.Sprintf("%s MCP is paused, it will be unpaused at %s or when %s annotation is set",
+		strings.Join(names, ", "), unpauseTime.UTC().Format(time.RFC3339), UnpauseMCPAnnotation)
+}
+
+// checkNodeObservabilityMCPStatus is for reconciling update status of all machines in profiling MCPs
+func (r *MachineConfigReconciler) checkNodeObservabilityMCPStatus(ctx context.Context) (ctrl.Result, error) {
+	mcps, err := r.getProfMCPs(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.CtrlConfig.Spec.IncludeMasterNodes {
+		// the master nodes are configured by the master MCP
+		masterMCP := &mcv1.MachineConfigPool{}
+		if err := r.ClientGet(ctx, types.NamespacedName{Name: MasterNodeMCPName}, masterMCP); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+		} else {
+			mcps = append(mcps, masterMCP)
+		}
+	}
+	if len(mcps) == 0 {
+		r.Log.V(1).Info("Profiling MCPs do not exist, skipping status check")
 		return ctrl.Result{}, nil
 	}
 
-	if mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdated) {
+	updated := 0
+	var degraded *mcv1.MachineConfigPool
+	for _, mcp := range mcps {
+		if mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdating) &&
+			mcp.Status.DegradedMachineCount == 0 {
+			msg := "Machine config update to enable debugging in progress"
+			r.Log.V(1).Info(msg, "MCP", mcp.Name)
+			r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+
+			return ctrl.Result{}, nil
+		}
+		if mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdated) {
+			updated++
+		} else if mcp.Status.DegradedMachineCount != 0 && degraded == nil {
+			degraded = mcp
+		}
+	}
+
+	if updated == len(mcps) {
 		r.EventRecorder.Eventf(r.CtrlConfig, corev1.EventTypeNormal, "ConfigUpdate", "debug config enabled on all machines")
 		msg := "Machine config update to enable debugging completed on all machines"
 		r.Log.V(1).Info(msg)
@@ -146,32 +439,33 @@ func (r *MachineConfigReconciler) checkNodeObservabilityMCPStatus(ctx context.Co
 		return ctrl.Result{}, nil
 	}
 
-	if mcp.Status.DegradedMachineCount != 0 {
+	if degraded != nil {
 		r.EventRecorder.Eventf(r.CtrlConfig, corev1.EventTypeWarning, "ConfigUpdate", "%s MCP has %d machines in degraded state",
-			mcp.Name, mcp.Status.DegradedMachineCount)
+			degraded.Name, degraded.Status.DegradedMachineCount)
 
 		if err := r.revertEnabledProfConf(ctx); err != nil {
 			msg := fmt.Sprintf("%s MCP has %d machines in degraded state. Reverting changes failed, reconcile again",
-				mcp.Name, mcp.Status.DegradedMachineCount)
+				degraded.Name, degraded.Status.DegradedMachineCount)
 			r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
 
 			return ctrl.Result{RequeueAfter: defaultRequeueTime},
 				fmt.Errorf("failed to revert changes to recover degraded machines: %w", err)
 		}
 
-		msg := fmt.Sprintf("%s MCP has %d machines in degraded state, reverted changes", mcp.Name, mcp.Status.DegradedMachineCount)
+		msg := fmt.Sprintf("%s MCP has %d machines in degraded state, reverted changes", degraded.Name, degraded.Status.DegradedMachineCount)
 		r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonFailed, msg)
 		return ctrl.Result{}, nil
 	}
 
-	r.Log.V(1).Info("Waiting for machine config update to complete on all machines", "MCP", mcp.Name)
+	r.Log.V(1).Info("Waiting for machine config update to complete on all machines", "UpdatedMCPs", updated, "MCPs", len(mcps))
 	return ctrl.Result{}, nil
 }
 
-// checkWorkerMCPStatus is for reconciling update status of all machines in profiling MCP
+// checkWorkerMCPStatus is for reconciling update status of all machines in the MCP
+// rolling back the profiling, the worker one unless the MCP is named
 func (r *MachineConfigReconciler) checkWorkerMCPStatus(ctx context.Context) (ctrl.Result, error) {
 	mcp := &mcv1.MachineConfigPool{}
-	if err := r.ClientGet(ctx, types.NamespacedName{Name: WorkerNodeMCPName}, mcp); err != nil {
+	if err := r.ClientGet(ctx, types.NamespacedName{Name: r.rollbackMCPName()}, mcp); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -200,6 +494,9 @@ func (r *MachineConfigReconciler) checkWorkerMCPStatus(ctx context.Context) (ctr
 		if err := r.deleteProfMCP(ctx); err != nil {
 			return ctrl.Result{RequeueAfter: defaultRequeueTime}, err
 		}
+		if err := r.deleteStaleProfilingPools(ctx); err != nil {
+			return ctrl.Result{RequeueAfter: defaultRequeueTime}, err
+		}
 
 		var msg string
 		if !r.CtrlConfig.Status.IsDebuggingEnabled() {
@@ -249,7 +546,7 @@ func (r *MachineConfigReconciler) checkWorkerMCPStatus(ctx context.Context) (ctr
 func (r *MachineConfigReconciler) waitWorkerMCPStatusUpdating(ctx context.Context) (ctrl.Result, error) {
 	err := wait.PollImmediateWithContext(ctx, mcpChangePollInterval, mcpChangeTimeout, func(ctx context.Context) (bool, error) {
 		mcp := &mcv1.MachineConfigPool{}
-		if err := r.ClientGet(ctx, types.NamespacedName{Name: WorkerNodeMCPName}, mcp); err != nil {
+		if err := r.ClientGet(ctx, types.NamespacedName{Name: r.rollbackMCPName()}, mcp); err != nil {
 			return false, nil
 		}
 		return mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdating), nil
@@ -24,11 +24,19 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
 )
 
 // ensureReqNodeLabelExists is for checking the if the required labels exist on the nodes.
+// Each targeted node is labeled with the role of the profiling pool it belongs to.
 // Returns true if all the targeted nodes have the required labels.
 // Return false if not all the nodes have the labels or none were selected.
 func (r *MachineConfigReconciler) ensureReqNodeLabelExists(ctx context.Context) (bool, error) {
@@ -36,18 +44,39 @@ func (r *MachineConfigReconciler) ensureReqNodeLabelExists(ctx context.Context)
 	// the number of the nodes which already had the required labels
 	// and the number of the nodes updated with the labels
 	existingNodeCount, updNodeCount := 0, 0
-	nodeList := &corev1.NodeList{}
-	if err := r.listNodes(ctx, nodeList, r.CtrlConfig.Spec.NodeSelector); err != nil {
+	nodeList, err := r.listTargetedNodes(ctx)
+	if err != nil {
+		return false, err
+	}
+	// the control plane nodes cannot join the profiling pools,
+	// they are configured by the master MCP when included
+	workerNodes, controlPlaneNodes := splitControlPlaneNodes(nodeList.Items)
+	roleLabels, err := nodePoolRoleLabels(workerNodes, r.profilingPools())
+	if err != nil {
 		return false, err
 	}
 
-	if len(nodeList.Items) == 0 {
-		r.Log.V(1).Info("No nodes matching the given selector were found", "NodeSelector", r.CtrlConfig.Spec.NodeSelector)
+	// the profiling configuration must not stay enabled
+	// on the nodes which are no longer targeted
+	if err := r.removeStaleNodeLabels(ctx, roleLabels); err != nil {
+		return false, err
+	}
+
+	if len(roleLabels) == 0 {
+		if r.CtrlConfig.Spec.IncludeMasterNodes && controlPlaneNodes != 0 {
+			r.Log.V(1).Info("Only master nodes are targeted, no node label required", "MasterNodeCount", controlPlaneNodes)
+			return true, nil
+		}
+		r.Log.V(1).Info("No nodes matching the given selector were found", "NodeSelector", r.CtrlConfig.Spec.NodeSelector, "RequiredNodeAffinity", r.CtrlConfig.Spec.RequiredNodeAffinity, "NodePools", r.CtrlConfig.Spec.NodePools)
 		return false, nil
 	}
 
 	for i, node := range nodeList.Items {
-		if _, exist := node.Labels[NodeObservabilityNodeRoleLabelName]; exist {
+		roleLabel, targeted := roleLabels[node.Name]
+		if !targeted {
+			continue
+		}
+		if _, exist := node.Labels[roleLabel]; exist {
 			existingNodeCount++
 			continue
 		}
@@ -55,17 +84,17 @@ func (r *MachineConfigReconciler) ensureReqNodeLabelExists(ctx context.Context)
 		patch, _ := newPatch(add,
 			ResourceLabelsPath,
 			map[string]interface{}{
-				NodeObservabilityNodeRoleLabelName: Empty,
+				roleLabel: Empty,
 			})
 		if err := r.ClientPatch(ctx, &nodeList.Items[i], client.RawPatch(types.JSONPatchType, patch)); err != nil {
 			return false, err
 		}
-		r.Log.V(1).Info("Successfully added label", "Node", node.Name, "Label", NodeObservabilityNodeRoleLabelName)
+		r.Log.V(1).Info("Successfully added label", "Node", node.Name, "Label", roleLabel)
 
 		updNodeCount++
 	}
 
-	if (existingNodeCount == len(nodeList.Items)) || (updNodeCount == len(nodeList.Items)) {
+	if (existingNodeCount == len(roleLabels)) || (updNodeCount == len(roleLabels)) {
 		r.Log.V(1).Info("Nodeobservability role is present on all the nodes with worker role", "ExistingNodeCount", existingNodeCount, "UpdatedNodeCount", updNodeCount)
 		return true, nil
 	}
@@ -73,7 +102,7 @@ func (r *MachineConfigReconciler) ensureReqNodeLabelExists(ctx context.Context)
 	return false, nil
 }
 
-// ensureReqNodeLabelNotExists removes the nodeobservability label from the nodes.
+// ensureReqNodeLabelNotExists removes the nodeobservability labels from the nodes.
 // Returns the number of updated nodes.
 func (r *MachineConfigReconciler) ensureReqNodeLabelNotExists(ctx context.Context) (int, error) {
 
@@ -84,19 +113,16 @@ func (r *MachineConfigReconciler) ensureReqNodeLabelNotExists(ctx context.Contex
 	}
 
 	for i, node := range nodeList.Items {
-		if _, exist := node.Labels[NodeObservabilityNodeRoleLabelName]; !exist {
+		roleLabels := profilingNodeRoleLabels(&node)
+		if len(roleLabels) == 0 {
 			continue
 		}
 
-		patch, _ := newPatch(remove,
-			ResourceLabelsPath,
-			map[string]interface{}{
-				NodeObservabilityNodeRoleLabelName: Empty,
-			})
+		patch, _ := newPatch(remove, ResourceLabelsPath, roleLabels)
 		if err := r.ClientPatch(ctx, &nodeList.Items[i], client.RawPatch(types.JSONPatchType, patch)); err != nil {
 			return updNodeCount, err
 		}
-		r.Log.V(1).Info("Successfully removed label", "Node", node.Name, "Label", NodeObservabilityNodeRoleLabelName)
+		r.Log.V(1).Info("Successfully removed label", "Node", node.Name, "Labels", roleLabels)
 
 		updNodeCount++
 	}
@@ -107,16 +133,189 @@ func (r *MachineConfigReconciler) ensureReqNodeLabelNotExists(ctx context.Contex
 	return updNodeCount, nil
 }
 
-// listNoObsLabeledNodes returns the list of nodes having NodeObservability role label
-func (r *MachineConfigReconciler) listNoObsLabeledNodes(ctx context.Context, nodeList *corev1.NodeList) error {
-	nodeLabel := map[string]string{
-		NodeObservabilityNodeRoleLabelName: Empty,
+// removeStaleNodeLabels removes the nodeobservability labels from the labeled nodes
+// which are not among the targeted ones or which moved to another pool.
+// The given role labels are the ones required on the targeted nodes.
+func (r *MachineConfigReconciler) removeStaleNodeLabels(ctx context.Context, roleLabels map[string]string) error {
+	nodeList := &corev1.NodeList{}
+	if err := r.listNoObsLabeledNodes(ctx, nodeList); err != nil {
+		return err
+	}
+
+	for i, node := range nodeList.Items {
+		stale := profilingNodeRoleLabels(&node)
+		delete(stale, roleLabels[node.Name])
+		if len(stale) == 0 {
+			continue
+		}
+
+		patch, _ := newPatch(remove, ResourceLabelsPath, stale)
+		if err := r.ClientPatch(ctx, &nodeList.Items[i], client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return err
+		}
+		r.Log.V(1).Info("Successfully removed label from node no longer targeted", "Node", node.Name, "Labels", stale)
+	}
+	return nil
+}
+
+// isControlPlaneNode returns true if the given node has the master or control plane role
+func isControlPlaneNode(node *corev1.Node) bool {
+	_, master := node.Labels[MasterNodeRoleLabelName]
+	_, controlPlane := node.Labels[ControlPlaneNodeRoleLabelName]
+	return master || controlPlane
+}
+
+// splitControlPlaneNodes returns the given nodes without the control plane ones
+// and the number of control plane nodes left out.
+func splitControlPlaneNodes(nodes []corev1.Node) ([]corev1.Node, int) {
+	workers := make([]corev1.Node, 0, len(nodes))
+	for i := range nodes {
+		if !isControlPlaneNode(&nodes[i]) {
+			workers = append(workers, nodes[i])
+		}
+	}
+	return workers, len(nodes) - len(workers)
+}
+
+// profilingNodeRoleLabels returns the role labels of the profiling pools set on the given node
+func profilingNodeRoleLabels(node *corev1.Node) map[string]interface{} {
+	roleLabels := map[string]interface{}{}
+	for label := range node.Labels {
+		if isProfilingNodeRoleLabel(label) {
+			roleLabels[label] = Empty
+		}
+	}
+	return roleLabels
+}
+
+// isExcludedNode returns true if the node is labeled to be excluded from the profiling
+func isExcludedNode(node *corev1.Node) bool {
+	return node.Labels[v1alpha2.ExcludeNodeLabel] == "true"
+}
+
+// requestsForNode enqueues all the NodeObservabilityMachineConfigs,
+// the nodes they target may change with the exclusion of the given node.
+func (r *MachineConfigReconciler) requestsForNode(o client.Object) []reconcile.Request {
+	nomcList := &v1alpha2.NodeObservabilityMachineConfigList{}
+	if err := r.ClientList(context.Background(), nomcList); err != nil {
+		r.Log.Error(err, "failed to list nodeobservabilitymachineconfigs for node", "node.name", o.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(nomcList.Items))
+	for _, nomc := range nomcList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: nomc.Name}})
+	}
+	return requests
+}
+
+// nodeExclusionChanged filters the events of the nodes
+// which neither get nor lose the exclusion label.
+func nodeExclusionChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return false },
+		DeleteFunc: func(e event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetLabels()[v1alpha2.ExcludeNodeLabel] != e.ObjectNew.GetLabels()[v1alpha2.ExcludeNodeLabel]
+		},
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
+
+// listTargetedNodes returns the list of nodes matching
+// the node selector and the required node affinity, except the excluded ones
+func (r *MachineConfigReconciler) listTargetedNodes(ctx context.Context) (*corev1.NodeList, error) {
+	nodeList := &corev1.NodeList{}
+	if err := r.listNodes(ctx, nodeList, r.CtrlConfig.Spec.NodeSelector); err != nil {
+		return nil, err
+	}
+
+	targeted := &corev1.NodeList{}
+	for i := range nodeList.Items {
+		if isExcludedNode(&nodeList.Items[i]) {
+			r.Log.V(1).Info("Skipping excluded node", "Node", nodeList.Items[i].Name)
+			continue
+		}
+		matches, err := matchesNodeSelectorTerms(&nodeList.Items[i], r.CtrlConfig.Spec.RequiredNodeAffinity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match node %q against the required node affinity: %w", nodeList.Items[i].Name, err)
+		}
+		if matches {
+			targeted.Items = append(targeted.Items, nodeList.Items[i])
+		}
+	}
+	return targeted, nil
+}
+
+// matchesNodeSelectorTerms returns true if the node matches at least one of the terms
+// of the given node selector, a nil node selector matches all the nodes.
+// The terms are evaluated the same way the scheduler does for the required node affinity.
+func matchesNodeSelectorTerms(node *corev1.Node, nodeSelector *corev1.NodeSelector) (bool, error) {
+	if nodeSelector == nil {
+		return true, nil
+	}
+	for _, term := range nodeSelector.NodeSelectorTerms {
+		// an empty term matches no nodes
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		labelSelector, err := nodeSelectorRequirementsAsSelector(term.MatchExpressions)
+		if err != nil {
+			return false, err
+		}
+		// metadata.name is the only field supported by the scheduler
+		fieldSelector, err := nodeSelectorRequirementsAsSelector(term.MatchFields)
+		if err != nil {
+			return false, err
+		}
+		if labelSelector.Matches(labels.Set(node.Labels)) && fieldSelector.Matches(labels.Set{"metadata.name": node.Name}) {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	if err := r.listNodes(ctx, nodeList, nodeLabel); err != nil {
+// nodeSelectorRequirementsAsSelector converts the node selector requirements to a label selector
+func nodeSelectorRequirementsAsSelector(reqs []corev1.NodeSelectorRequirement) (labels.Selector, error) {
+	selector := labels.NewSelector()
+	for _, req := range reqs {
+		var op selection.Operator
+		switch req.Operator {
+		case corev1.NodeSelectorOpIn:
+			op = selection.In
+		case corev1.NodeSelectorOpNotIn:
+			op = selection.NotIn
+		case corev1.NodeSelectorOpExists:
+			op = selection.Exists
+		case corev1.NodeSelectorOpDoesNotExist:
+			op = selection.DoesNotExist
+		case corev1.NodeSelectorOpGt:
+			op = selection.GreaterThan
+		case corev1.NodeSelectorOpLt:
+			op = selection.LessThan
+		default:
+			return nil, fmt.Errorf("%q is not a valid node selector operator", req.Operator)
+		}
+		r, err := labels.NewRequirement(req.Key, op, req.Values)
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*r)
+	}
+	return selector, nil
+}
+
+// listNoObsLabeledNodes returns the list of nodes having the role label of a profiling pool
+func (r *MachineConfigReconciler) listNoObsLabeledNodes(ctx context.Context, nodeList *corev1.NodeList) error {
+	allNodes := &corev1.NodeList{}
+	if err := r.listNodes(ctx, allNodes, nil); err != nil {
 		return fmt.Errorf("failed to get the list of nodes labeled nodeobservability: %w", err)
 	}
 
+	for i := range allNodes.Items {
+		if len(profilingNodeRoleLabels(&allNodes.Items[i])) != 0 {
+			nodeList.Items = append(nodeList.Items, allNodes.Items[i])
+		}
+	}
 	return nil
 }
 
@@ -150,6 +349,8 @@ func newPatch(op patchOp, pathPrefix string, patch map[string]interface{}) ([]by
 		return newAddPatch(pathPrefix, patch), nil
 	case remove:
 		return newRemovePatch(pathPrefix, patch), nil
+	case replace:
+		return newReplacePatch(pathPrefix, patch), nil
 	}
 	return nil, fmt.Errorf("patch operation type[%v] not supported", op)
 }
@@ -178,6 +379,18 @@ func newRemovePatch(pathPrefix string, patch map[string]interface{}) []byte {
 	return data
 }
 
+// newReplacePatch returns the patch data to replace in the format required by the client
+func newReplacePatch(pathPrefix string, patch map[string]interface{}) []byte {
+	values := make([]ResourcePatchValue, 0, len(patch))
+	for k, v := range patch {
+		ppath := path.Join(pathPrefix, escape(k))
+		values = append(values, getPatchValue("replace", ppath, v))
+	}
+
+	data, _ := json.Marshal(values)
+	return data
+}
+
 // getPatchValue returns the patch data in the required format
 func getPatchValue(op, path string, value interface{}) ResourcePatchValue {
 	return ResourcePatchValue{
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// deleteOrphanedMachineConfigs deletes the MachineConfigs created by the operator
+// whose NodeObservabilityMachineConfig owner no longer exists.
+// The MachineConfigs without the ManagedByLabelName label are never touched.
+func (r *MachineConfigReconciler) deleteOrphanedMachineConfigs(ctx context.Context) error {
+	mcList := &mcv1.MachineConfigList{}
+	if err := r.ClientList(ctx, mcList, client.MatchingLabels{ManagedByLabelName: ManagedByLabelValue}); err != nil {
+		return fmt.Errorf("failed to list machineconfigs: %w", err)
+	}
+
+	for i := range mcList.Items {
+		mc := &mcList.Items[i]
+		if !mc.DeletionTimestamp.IsZero() {
+			continue
+		}
+		orphaned, err := r.isOrphaned(ctx, mc)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			continue
+		}
+		if err := r.ClientDelete(ctx, mc); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete orphaned machineconfig %s: %w", mc.Name, err)
+		}
+		r.Log.V(1).Info("Deleted orphaned MachineConfig", "MachineConfig", mc.Name)
+		r.EventRecorder.Eventf(mc, corev1.EventTypeNormal, "ConfigCleanup", "orphaned %s MachineConfig deleted", mc.Name)
+	}
+	return nil
+}
+
+// isOrphaned returns true if none of the NodeObservabilityMachineConfig owners
+// of the given MachineConfig exists.
+func (r *MachineConfigReconciler) isOrphaned(ctx context.Context, mc *mcv1.MachineConfig) (bool, error) {
+	for _, ref := range mc.OwnerReferences {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil || gv.Group != v1alpha2.GroupVersion.Group || ref.Kind != "NodeObservabilityMachineConfig" {
+			continue
+		}
+		owner := &v1alpha2.NodeObservabilityMachineConfig{}
+		if err := r.ClientGet(ctx, types.NamespacedName{Name: ref.Name}, owner); err == nil {
+			return false, nil
+		} else if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get nodeobservabilitymachineconfig %s owning machineconfig %s: %w", ref.Name, mc.Name, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig/machineconfigfakes"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func testOwnedMachineConfig(name string, labels map[string]string, owners ...string) *mcv1.MachineConfig {
+	mc := &mcv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+	for _, owner := range owners {
+		mc.OwnerReferences = append(mc.OwnerReferences, metav1.OwnerReference{
+			APIVersion: "nodeobservability.olm.openshift.io/v1alpha2",
+			Kind:       "NodeObservabilityMachineConfig",
+			Name:       owner,
+			Controller: pointer.Bool(true),
+		})
+	}
+	return mc
+}
+
+func TestReconcileOrphanedMachineConfigs(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+
+	tests := []struct {
+		name           string
+		reqObjs        []runtime.Object
+		expectedMCs    []string
+		deletedMCs     []string
+		expectedEvents int
+	}{
+		{
+			name:        "no machineconfigs",
+			expectedMCs: []string{},
+		},
+		{
+			name: "orphaned machineconfig deleted",
+			reqObjs: []runtime.Object{
+				testOwnedMachineConfig(CrioProfilingConfigName, ManagedMachineConfigLabels, TestControllerResourceName),
+			},
+			deletedMCs:     []string{CrioProfilingConfigName},
+			expectedEvents: 1,
+		},
+		{
+			name: "managed machineconfig without owner deleted",
+			reqObjs: []runtime.Object{
+				testOwnedMachineConfig(CrioProfilingConfigName, ManagedMachineConfigLabels),
+			},
+			deletedMCs:     []string{CrioProfilingConfigName},
+			expectedEvents: 1,
+		},
+		{
+			name: "machineconfig of existing owner kept",
+			reqObjs: []runtime.Object{
+				testNodeObsMC(),
+				testOwnedMachineConfig("10-crio-other", ManagedMachineConfigLabels, TestControllerResourceName),
+			},
+			expectedMCs: []string{"10-crio-other"},
+		},
+		{
+			name: "machineconfig with one existing owner kept",
+			reqObjs: []runtime.Object{
+				testNodeObsMC(),
+				testOwnedMachineConfig("10-crio-other", ManagedMachineConfigLabels, "deleted", TestControllerResourceName),
+			},
+			expectedMCs: []string{"10-crio-other"},
+		},
+		{
+			name: "machineconfigs not created by the operator kept",
+			reqObjs: []runtime.Object{
+				testOwnedMachineConfig(CrioProfilingConfigName, MachineConfigLabels, TestControllerResourceName),
+				testOwnedMachineConfig("99-worker-ssh", map[string]string{MCRoleLabelName: WorkerNodeRoleName}),
+			},
+			expectedMCs: []string{CrioProfilingConfigName, "99-worker-ssh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(test.Scheme).
+				WithRuntimeObjects(tt.reqObjs...).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := testReconciler()
+			r.EventRecorder = recorder
+			r.impl = &defaultImpl{Client: c}
+
+			// the owner of the request is gone
+			request := testReconcileRequest()
+			request.Name = "deleted"
+			if _, err := r.Reconcile(ctx, request); err != nil {
+				t.Fatalf("Reconcile() unexpected error: %v", err)
+			}
+
+			for _, name := range tt.expectedMCs {
+				if err := c.Get(ctx, types.NamespacedName{Name: name}, &mcv1.MachineConfig{}); err != nil {
+					t.Errorf("expected machineconfig %s to be kept: %v", name, err)
+				}
+			}
+			for _, name := range tt.deletedMCs {
+				if err := c.Get(ctx, types.NamespacedName{Name: name}, &mcv1.MachineConfig{}); !kerrors.IsNotFound(err) {
+					t.Errorf("expected machineconfig %s to be deleted, got %v", name, err)
+				}
+			}
+			if len(recorder.Events) != tt.expectedEvents {
+				t.Fatalf("expected %d events, got %d", tt.expectedEvents, len(recorder.Events))
+			}
+			for i := 0; i < tt.expectedEvents; i++ {
+				if event := <-recorder.Events; !strings.HasPrefix(event, corev1.EventTypeNormal+" ConfigCleanup") {
+					t.Errorf("unexpected event %q", event)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteOrphanedMachineConfigsErrors(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+
+	tests := []struct {
+		name   string
+		preReq func(*machineconfigfakes.FakeImpl)
+	}{
+		{
+			name: "list fails",
+			preReq: func(m *machineconfigfakes.FakeImpl) {
+				m.ClientListReturns(testError)
+			},
+		},
+		{
+			name: "owner get fails",
+			preReq: func(m *machineconfigfakes.FakeImpl) {
+				m.ClientListStub = func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+					list.(*mcv1.MachineConfigList).Items = []mcv1.MachineConfig{
+						*testOwnedMachineConfig(CrioProfilingConfigName, ManagedMachineConfigLabels, TestControllerResourceName),
+					}
+					return nil
+				}
+				m.ClientGetReturns(testError)
+			},
+		},
+		{
+			name: "delete fails",
+			preReq: func(m *machineconfigfakes.FakeImpl) {
+				m.ClientListStub = func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+					list.(*mcv1.MachineConfigList).Items = []mcv1.MachineConfig{
+						*testOwnedMachineConfig(CrioProfilingConfigName, ManagedMachineConfigLabels, TestControllerResourceName),
+					}
+					return nil
+				}
+				m.ClientGetReturns(kerrors.NewNotFound(mcv1.Resource("machineconfig"), TestControllerResourceName))
+				m.ClientDeleteReturns(testError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &machineconfigfakes.FakeImpl{}
+			tt.preReq(mock)
+			r := testReconciler()
+			r.impl = mock
+
+			if err := r.deleteOrphanedMachineConfigs(ctx); err == nil {
+				t.Fatalf("deleteOrphanedMachineConfigs() expected error but got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// profilingPool is a subset of the targeted nodes on which the CRI-O profiling
+// is enabled by a dedicated MachineConfigPool and MachineConfig.
+type profilingPool struct {
+	// name is the name of the MachineConfigPool,
+	// it's also the machine config role of the nodes of the pool
+	name string
+	// nodeSelector selects the nodes of the pool among the targeted nodes,
+	// all the targeted nodes are selected if nil
+	nodeSelector map[string]string
+	// named is true for the MachineConfigPool named in the spec, which is not managed
+	// by the operator: only its MachineConfig is created, its nodes are not labeled
+	named bool
+}
+
+// ProfilingMCPNameForPool returns the name of the profiling MachineConfigPool
+// of the given node pool, the default profiling MachineConfigPool if no pool is given.
+func ProfilingMCPNameForPool(pool string) string {
+	if pool == "" {
+		return ProfilingMCPName
+	}
+	return ProfilingMCPName + "-" + pool
+}
+
+// CrioProfilingConfigNameForPool returns the name of the MachineConfig enabling
+// the CRI-O profiling on the given node pool, the default one if no pool is given.
+func CrioProfilingConfigNameForPool(pool string) string {
+	if pool == "" {
+		return CrioProfilingConfigName
+	}
+	return CrioProfilingConfigName + "-" + pool
+}
+
+// IsProfilingMCPName returns true if the given name is
+// the name of a profiling MachineConfigPool.
+func IsProfilingMCPName(name string) bool {
+	return name == ProfilingMCPName || strings.HasPrefix(name, ProfilingMCPName+"-")
+}
+
+// profilingPools returns the pools of the targeted nodes,
+// a single pool holds all of them when no node pool is requested
+// or when the MachineConfigPool is named.
+func (r *MachineConfigReconciler) profilingPools() []profilingPool {
+	if r.CtrlConfig.Spec.MachineConfigPoolName != "" {
+		return []profilingPool{{name: r.CtrlConfig.Spec.MachineConfigPoolName, named: true}}
+	}
+	if len(r.CtrlConfig.Spec.NodePools) == 0 {
+		return []profilingPool{{name: ProfilingMCPName}}
+	}
+	pools := make([]profilingPool, 0, len(r.CtrlConfig.Spec.NodePools))
+	for _, pool := range r.CtrlConfig.Spec.NodePools {
+		pools = append(pools, profilingPool{
+			name:         ProfilingMCPNameForPool(pool.Name),
+			nodeSelector: pool.NodeSelector,
+		})
+	}
+	return pools
+}
+
+// nodeRoleLabel returns the label setting the machine config role of the pool on its nodes
+func (p profilingPool) nodeRoleLabel() string {
+	return NodeRoleLabelPrefix + p.name
+}
+
+// machineConfigName returns the name of the MachineConfig enabling the CRI-O profiling on the pool
+func (p profilingPool) machineConfigName() string {
+	if p.named {
+		return CrioProfilingConfigNameForPool(p.name)
+	}
+	if p.name == ProfilingMCPName {
+		return CrioProfilingConfigName
+	}
+	return CrioProfilingConfigName + strings.TrimPrefix(p.name, ProfilingMCPName)
+}
+
+// selects returns true if the given node belongs to the pool
+func (p profilingPool) selects(node *corev1.Node) bool {
+	return labels.SelectorFromSet(p.nodeSelector).Matches(labels.Set(node.Labels))
+}
+
+// isProfilingNodeRoleLabel returns true if the given label
+// sets the machine config role of a profiling pool
+func isProfilingNodeRoleLabel(label string) bool {
+	return strings.HasPrefix(label, NodeRoleLabelPrefix) && IsProfilingMCPName(strings.TrimPrefix(label, NodeRoleLabelPrefix))
+}
+
+// nodePoolRoleLabels returns the role label to set on each of the given nodes
+// according to the pool they belong to, the nodes not selected by any pool are left out.
+// An error is returned if a node is selected by more than one pool
+// as it cannot be part of several MachineConfigPools.
+func nodePoolRoleLabels(nodes []corev1.Node, pools []profilingPool) (map[string]string, error) {
+	roleLabels := make(map[string]string, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		var selectedBy *profilingPool
+		for j := range pools {
+			if !pools[j].selects(node) {
+				continue
+			}
+			if selectedBy != nil {
+				return nil, fmt.Errorf("node %q is selected by both %s and %s pools", node.Name, selectedBy.name, pools[j].name)
+			}
+			selectedBy = &pools[j]
+		}
+		if selectedBy != nil {
+			roleLabels[node.Name] = selectedBy.nodeRoleLabel()
+		}
+	}
+	return roleLabels, nil
+}
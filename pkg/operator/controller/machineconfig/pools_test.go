@@ -0,0 +1,361 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func testPoolNode(name string, labels map[string]string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func TestNodePoolRoleLabels(t *testing.T) {
+	nodes := []corev1.Node{
+		testPoolNode("gpu-node", map[string]string{"pool": "gpu"}),
+		testPoolNode("cpu-node", map[string]string{"pool": "cpu"}),
+		testPoolNode("other-node", map[string]string{"pool": "other"}),
+	}
+
+	tests := []struct {
+		name       string
+		pools      []profilingPool
+		wantLabels map[string]string
+		wantErr    bool
+	}{
+		{
+			name:  "default pool selects all nodes",
+			pools: []profilingPool{{name: ProfilingMCPName}},
+			wantLabels: map[string]string{
+				"gpu-node":   NodeObservabilityNodeRoleLabelName,
+				"cpu-node":   NodeObservabilityNodeRoleLabelName,
+				"other-node": NodeObservabilityNodeRoleLabelName,
+			},
+		},
+		{
+			name: "nodes labeled with the role of their pool",
+			pools: []profilingPool{
+				{name: ProfilingMCPNameForPool("gpu"), nodeSelector: map[string]string{"pool": "gpu"}},
+				{name: ProfilingMCPNameForPool("cpu"), nodeSelector: map[string]string{"pool": "cpu"}},
+			},
+			wantLabels: map[string]string{
+				"gpu-node": "node-role.kubernetes.io/nodeobservability-gpu",
+				"cpu-node": "node-role.kubernetes.io/nodeobservability-cpu",
+			},
+		},
+		{
+			name: "node selected by several pools",
+			pools: []profilingPool{
+				{name: ProfilingMCPNameForPool("gpu"), nodeSelector: map[string]string{"pool": "gpu"}},
+				{name: ProfilingMCPNameForPool("all")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nodePoolRoleLabels(nodes, tt.pools)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nodePoolRoleLabels() err: %v, wantErr: %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.wantLabels) {
+				t.Errorf("nodePoolRoleLabels() got: %v, want: %v", got, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestEnsureReqNodeLabelExistsWithNodePools(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Spec.NodeSelector = map[string]string{WorkerNodeRoleLabelName: Empty}
+	r.CtrlConfig.Spec.NodePools = []v1alpha2.NodePool{
+		{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+		{Name: "cpu", NodeSelector: map[string]string{"pool": "cpu"}},
+	}
+
+	gpuNode := testPoolNode("gpu-node", map[string]string{WorkerNodeRoleLabelName: Empty, "pool": "gpu"})
+	// previously in the default pool
+	cpuNode := testPoolNode("cpu-node", map[string]string{WorkerNodeRoleLabelName: Empty, "pool": "cpu", NodeObservabilityNodeRoleLabelName: Empty})
+	otherNode := testPoolNode("other-node", map[string]string{WorkerNodeRoleLabelName: Empty, "pool": "other"})
+	c := fake.NewClientBuilder().
+		WithScheme(test.Scheme).
+		WithObjects(&gpuNode, &cpuNode, &otherNode).
+		Build()
+	r.impl = &defaultImpl{Client: c}
+
+	ensured, err := r.ensureReqNodeLabelExists(ctx)
+	if err != nil {
+		t.Fatalf("ensureReqNodeLabelExists() unexpected err: %v", err)
+	}
+	if !ensured {
+		t.Errorf("ensureReqNodeLabelExists() expected all the nodes to be labeled")
+	}
+
+	for name, wantRoles := range map[string][]string{
+		"gpu-node":   {"node-role.kubernetes.io/nodeobservability-gpu"},
+		"cpu-node":   {"node-role.kubernetes.io/nodeobservability-cpu"},
+		"other-node": nil,
+	} {
+		node := &corev1.Node{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, node); err != nil {
+			t.Fatalf("failed to get node %s: %v", name, err)
+		}
+		var gotRoles []string
+		for label := range profilingNodeRoleLabels(node) {
+			gotRoles = append(gotRoles, label)
+		}
+		if !reflect.DeepEqual(gotRoles, wantRoles) {
+			t.Errorf("node %s profiling roles: %v, want: %v", name, gotRoles, wantRoles)
+		}
+	}
+}
+
+func TestDeleteStaleProfilingPools(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Spec.NodePools = []v1alpha2.NodePool{
+		{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+		{Name: "cpu", NodeSelector: map[string]string{"pool": "cpu"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(test.Scheme).Build()
+	r.impl = &defaultImpl{Client: c}
+
+	if err := r.enableCrioProf(ctx); err != nil {
+		t.Fatalf("enableCrioProf() unexpected err: %v", err)
+	}
+	if err := r.createProfMCP(ctx); err != nil {
+		t.Fatalf("createProfMCP() unexpected err: %v", err)
+	}
+	// not owned by the operator
+	if err := c.Create(ctx, &mcv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: "nodeobservability-custom"}}); err != nil {
+		t.Fatalf("failed to create MCP: %v", err)
+	}
+
+	r.CtrlConfig.Spec.NodePools = r.CtrlConfig.Spec.NodePools[:1]
+	if err := r.deleteStaleProfilingPools(ctx); err != nil {
+		t.Fatalf("deleteStaleProfilingPools() unexpected err: %v", err)
+	}
+
+	for name, wantExists := range map[string]bool{
+		"nodeobservability-gpu":         true,
+		"nodeobservability-cpu":         false,
+		"nodeobservability-custom":      true,
+		"10-crio-nodeobservability-gpu": true,
+		"10-crio-nodeobservability-cpu": false,
+	} {
+		var err error
+		if IsProfilingMCPName(name) {
+			err = c.Get(ctx, types.NamespacedName{Name: name}, &mcv1.MachineConfigPool{})
+		} else {
+			err = c.Get(ctx, types.NamespacedName{Name: name}, &mcv1.MachineConfig{})
+		}
+		if err != nil && !kerrors.IsNotFound(err) {
+			t.Fatalf("failed to get %s: %v", name, err)
+		}
+		if exists := err == nil; exists != wantExists {
+			t.Errorf("%s exists: %t, want: %t", name, exists, wantExists)
+		}
+	}
+}
+
+func TestSplitControlPlaneNodes(t *testing.T) {
+	nodes := []corev1.Node{
+		testPoolNode("worker", map[string]string{WorkerNodeRoleLabelName: ""}),
+		testPoolNode("master", map[string]string{MasterNodeRoleLabelName: ""}),
+		testPoolNode("control-plane", map[string]string{ControlPlaneNodeRoleLabelName: ""}),
+	}
+	workers, controlPlane := splitControlPlaneNodes(nodes)
+	if len(workers) != 1 || workers[0].Name != "worker" {
+		t.Errorf("expected only the worker node to be kept, got %v", workers)
+	}
+	if controlPlane != 2 {
+		t.Errorf("expected 2 control plane nodes, got %d", controlPlane)
+	}
+}
+
+func TestMasterNodesProfiling(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Spec.IncludeMasterNodes = true
+	c := fake.NewClientBuilder().WithScheme(test.Scheme).Build()
+	r.impl = &defaultImpl{Client: c}
+
+	if err := r.enableCrioProf(ctx); err != nil {
+		t.Fatalf("enableCrioProf() unexpected err: %v", err)
+	}
+	mc := &mcv1.MachineConfig{}
+	if err := c.Get(ctx, types.NamespacedName{Name: CrioProfilingMasterConfigName}, mc); err != nil {
+		t.Fatalf("failed to get master machine config: %v", err)
+	}
+	if role := mc.Labels[MCRoleLabelName]; role != MasterNodeMCPName {
+		t.Errorf("expected master machine config role %q, got %q", MasterNodeMCPName, role)
+	}
+	if !metav1.IsControlledBy(mc, r.CtrlConfig) {
+		t.Errorf("expected master machine config to be controlled by %s", r.CtrlConfig.Name)
+	}
+
+	if err := r.deleteStaleProfilingPools(ctx); err != nil {
+		t.Fatalf("deleteStaleProfilingPools() unexpected err: %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: CrioProfilingMasterConfigName}, &mcv1.MachineConfig{}); err != nil {
+		t.Fatalf("expected master machine config to be kept, got: %v", err)
+	}
+
+	r.CtrlConfig.Spec.IncludeMasterNodes = false
+	if err := r.deleteStaleProfilingPools(ctx); err != nil {
+		t.Fatalf("deleteStaleProfilingPools() unexpected err: %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: CrioProfilingMasterConfigName}, &mcv1.MachineConfig{}); !kerrors.IsNotFound(err) {
+		t.Errorf("expected master machine config to be deleted, got: %v", err)
+	}
+}
+
+func testNamedMCP(nodeSelector map[string]string, mcRoles ...string) *mcv1.MachineConfigPool {
+	return &mcv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "infra"},
+		Spec: mcv1.MachineConfigPoolSpec{
+			MachineConfigSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: MCRoleLabelName, Operator: metav1.LabelSelectorOpIn, Values: mcRoles},
+				},
+			},
+			NodeSelector: &metav1.LabelSelector{MatchLabels: nodeSelector},
+		},
+	}
+}
+
+func TestNamedMachineConfigPool(t *testing.T) {
+	nodes := []runtime.Object{
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "infra-1", Labels: map[string]string{"pool": "infra", NodeObservabilityNodeRoleLabelName: Empty}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "infra-2", Labels: map[string]string{"pool": "infra", "zone": "a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master", Labels: map[string]string{"pool": "infra", MasterNodeRoleLabelName: Empty}}},
+	}
+	cases := []struct {
+		name           string
+		mcp            *mcv1.MachineConfigPool
+		expectedReason string
+	}{
+		{
+			name:           "named MCP not found",
+			expectedReason: v1alpha2.ReasonMachineConfigPoolNotFound,
+		},
+		{
+			name:           "named MCP not selecting all the nodes",
+			mcp:            testNamedMCP(map[string]string{"zone": "a"}, WorkerNodeRoleName, "infra"),
+			expectedReason: v1alpha2.ReasonMachineConfigPoolMismatch,
+		},
+		{
+			name:           "named MCP not selecting the machine config",
+			mcp:            testNamedMCP(map[string]string{"pool": "infra"}, WorkerNodeRoleName),
+			expectedReason: v1alpha2.ReasonMachineConfigPoolMismatch,
+		},
+		{
+			name: "named MCP used",
+			mcp:  testNamedMCP(map[string]string{"pool": "infra"}, WorkerNodeRoleName, "infra"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+			r := testReconciler()
+			r.CtrlConfig.Spec.NodeSelector = map[string]string{"pool": "infra"}
+			r.CtrlConfig.Spec.MachineConfigPoolName = "infra"
+			objs := append([]runtime.Object{r.CtrlConfig}, nodes...)
+			if tc.mcp != nil {
+				objs = append(objs, tc.mcp)
+			}
+			c := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r.impl = &defaultImpl{Client: c}
+
+			if _, err := r.ensureProfConfEnabled(ctx); err != nil {
+				t.Fatalf("ensureProfConfEnabled() unexpected err: %v", err)
+			}
+			mcName := CrioProfilingConfigNameForPool("infra")
+			err := c.Get(ctx, types.NamespacedName{Name: mcName}, &mcv1.MachineConfig{})
+			if err != nil && !kerrors.IsNotFound(err) {
+				t.Fatalf("failed to get %s: %v", mcName, err)
+			}
+			if created := err == nil; created != (tc.expectedReason == "") {
+				t.Fatalf("machine config %s created: %t, want: %t", mcName, created, tc.expectedReason == "")
+			}
+			if tc.expectedReason != "" {
+				cond := r.CtrlConfig.Status.GetCondition(v1alpha2.DebugReady)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != tc.expectedReason {
+					t.Fatalf("expected DebugReady condition with reason %s, got %v", tc.expectedReason, cond)
+				}
+				return
+			}
+
+			if !r.CtrlConfig.Status.IsDebuggingEnabled() {
+				t.Errorf("expected debugging to be enabled")
+			}
+			mcpList := &mcv1.MachineConfigPoolList{}
+			if err := c.List(ctx, mcpList); err != nil {
+				t.Fatalf("failed to list machineconfigpools: %v", err)
+			}
+			if len(mcpList.Items) != 1 {
+				t.Errorf("expected no profiling MCP to be created, got %d MCPs", len(mcpList.Items))
+			}
+			node := &corev1.Node{}
+			if err := c.Get(ctx, types.NamespacedName{Name: "infra-1"}, node); err != nil {
+				t.Fatalf("failed to get node: %v", err)
+			}
+			if _, found := node.Labels[NodeObservabilityNodeRoleLabelName]; found {
+				t.Errorf("expected the profiling role label to be removed from node %s", node.Name)
+			}
+
+			// the profiling is rolled back by the named MCP once its machine config is deleted
+			r.CtrlConfig.Spec.Debug.EnableCrioProfiling = false
+			touched, err := r.ensureProfConfDisabled(ctx)
+			if err != nil {
+				t.Fatalf("ensureProfConfDisabled() unexpected err: %v", err)
+			}
+			if !touched {
+				t.Errorf("expected the named MCP to be touched")
+			}
+			if err := c.Get(ctx, types.NamespacedName{Name: mcName}, &mcv1.MachineConfig{}); !kerrors.IsNotFound(err) {
+				t.Errorf("expected machine config %s to be deleted, got: %v", mcName, err)
+			}
+			if name := r.rollbackMCPName(); name != "infra" {
+				t.Errorf("expected the named MCP to roll back the profiling, got %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// previewProfConf computes the machine config changes which would enable
+// the CRI-O profiling and reports them in the status without applying them.
+func (r *MachineConfigReconciler) previewProfConf(ctx context.Context) error {
+	nodeList, err := r.listTargetedNodes(ctx)
+	if err != nil {
+		return err
+	}
+	pools := r.profilingPools()
+	workerNodes, controlPlaneNodes := splitControlPlaneNodes(nodeList.Items)
+	roleLabels, err := nodePoolRoleLabels(workerNodes, pools)
+	if err != nil {
+		return err
+	}
+
+	var previews []v1alpha2.MachineConfigPreview
+	for _, pool := range pools {
+		mc, err := r.getCrioProfMachineConfig(pool)
+		if err != nil {
+			return err
+		}
+		rendered, err := yaml.Marshal(mc)
+		if err != nil {
+			return fmt.Errorf("failed to render crio profiling machine config: %w", err)
+		}
+
+		var machineCount int32
+		for _, roleLabel := range roleLabels {
+			if roleLabel == pool.nodeRoleLabel() {
+				machineCount++
+			}
+		}
+		previews = append(previews, v1alpha2.MachineConfigPreview{
+			MachineConfigPool: pool.name,
+			MachineCount:      machineCount,
+			MachineConfig:     string(rendered),
+		})
+	}
+	machineCount := len(roleLabels)
+	if r.CtrlConfig.Spec.IncludeMasterNodes && controlPlaneNodes != 0 {
+		mc, err := r.getCrioProfMasterMachineConfig()
+		if err != nil {
+			return err
+		}
+		rendered, err := yaml.Marshal(mc)
+		if err != nil {
+			return fmt.Errorf("failed to render crio profiling master machine config: %w", err)
+		}
+		previews = append(previews, v1alpha2.MachineConfigPreview{
+			MachineConfigPool: MasterNodeMCPName,
+			MachineCount:      int32(controlPlaneNodes),
+			MachineConfig:     string(rendered),
+		})
+		machineCount += controlPlaneNodes
+	}
+	r.CtrlConfig.Status.MachineConfigPreview = previews
+
+	msg := fmt.Sprintf("Dry run: %d machines would be rebooted to enable debugging, machine config changes not applied", machineCount)
+	r.Log.V(1).Info(msg)
+	r.CtrlConfig.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonDryRun, msg)
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machineconfigcontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestPreviewProfConf(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+
+	tests := []struct {
+		name          string
+		nodePools     []v1alpha2.NodePool
+		wantPreviews  map[string]int32
+		wantCondition string
+	}{
+		{
+			name:          "default pool",
+			wantPreviews:  map[string]int32{ProfilingMCPName: 3},
+			wantCondition: "Dry run: 3 machines would be rebooted",
+		},
+		{
+			name: "node pools",
+			nodePools: []v1alpha2.NodePool{
+				{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+				{Name: "cpu", NodeSelector: map[string]string{"pool": "cpu"}},
+			},
+			wantPreviews:  map[string]int32{"nodeobservability-gpu": 1, "nodeobservability-cpu": 0},
+			wantCondition: "Dry run: 1 machines would be rebooted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler()
+			r.CtrlConfig.Spec.DryRun = true
+			r.CtrlConfig.Spec.NodeSelector = map[string]string{WorkerNodeRoleLabelName: Empty}
+			r.CtrlConfig.Spec.NodePools = tt.nodePools
+
+			nodes := []corev1.Node{
+				testPoolNode("gpu-node", map[string]string{WorkerNodeRoleLabelName: Empty, "pool": "gpu"}),
+				testPoolNode("other-node", map[string]string{WorkerNodeRoleLabelName: Empty, "pool": "other"}),
+				testPoolNode("worker-node", map[string]string{WorkerNodeRoleLabelName: Empty}),
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(test.Scheme).
+				WithObjects(&nodes[0], &nodes[1], &nodes[2]).
+				Build()
+			r.impl = &defaultImpl{Client: c}
+
+			if err := r.previewProfConf(ctx); err != nil {
+				t.Fatalf("previewProfConf() unexpected err: %v", err)
+			}
+
+			if len(r.CtrlConfig.Status.MachineConfigPreview) != len(tt.wantPreviews) {
+				t.Fatalf("previewProfConf() previews: %+v, want: %v", r.CtrlConfig.Status.MachineConfigPreview, tt.wantPreviews)
+			}
+			for _, preview := range r.CtrlConfig.Status.MachineConfigPreview {
+				wantCount, ok := tt.wantPreviews[preview.MachineConfigPool]
+				if !ok || preview.MachineCount != wantCount {
+					t.Errorf("previewProfConf() unexpected preview for %s: %d machines", preview.MachineConfigPool, preview.MachineCount)
+				}
+				if !strings.Contains(preview.MachineConfig, "name: "+CrioProfilingConfigName) || !strings.Contains(preview.MachineConfig, CrioServiceFile) {
+					t.Errorf("previewProfConf() unexpected rendered machine config:\n%s", preview.MachineConfig)
+				}
+			}
+
+			cond := r.CtrlConfig.Status.GetCondition(v1alpha2.DebugReady)
+			if cond == nil || cond.Reason != v1alpha2.ReasonDryRun || !strings.HasPrefix(cond.Message, tt.wantCondition) {
+				t.Errorf("previewProfConf() unexpected condition: %+v", cond)
+			}
+		})
+	}
+}
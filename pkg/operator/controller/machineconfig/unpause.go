@@ -0,0 +1,118 @@
+package machineconfigcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// shutdownUnpauseTimeout bounds the unpause of the paused MCPs when the operator shuts down
+const shutdownUnpauseTimeout = 20 * time.Second
+
+// trackPausedMCP records the MCP paused by the operator in the status
+func (r *MachineConfigReconciler) trackPausedMCP(name string) {
+	for _, tracked := range r.CtrlConfig.Status.PausedMachineConfigPools {
+		if tracked == name {
+			return
+		}
+	}
+	r.CtrlConfig.Status.PausedMachineConfigPools = append(r.CtrlConfig.Status.PausedMachineConfigPools, name)
+}
+
+// untrackPausedMCP removes the unpaused MCP from the status
+func (r *MachineConfigReconciler) untrackPausedMCP(name string) {
+	var tracked []string
+	for _, pool := range r.CtrlConfig.Status.PausedMachineConfigPools {
+		if pool != name {
+			tracked = append(tracked, pool)
+		}
+	}
+	r.CtrlConfig.Status.PausedMachineConfigPools = tracked
+}
+
+// unpauseTrackedMCPs unpauses the MCPs paused by the operator
+// and removes them from the status.
+func (r *MachineConfigReconciler) unpauseTrackedMCPs(ctx context.Context) error {
+	unpaused, err := unpauseMCPs(ctx, r.impl, r.CtrlConfig.Status.PausedMachineConfigPools)
+	for _, name := range unpaused {
+		r.Log.V(1).Info("Unpaused MachineConfigPool paused by the operator", "MCPName", name)
+		r.EventRecorder.Eventf(r.CtrlConfig, corev1.EventTypeNormal, "ConfigUpdate", "%s MCP unpaused", name)
+	}
+	if err != nil {
+		return err
+	}
+	r.CtrlConfig.Status.PausedMachineConfigPools = nil
+	r.CtrlConfig.Status.MachineConfigPoolUnpauseTime = nil
+	return nil
+}
+
+// unpauseMCPs unpauses the named MCPs which are still paused,
+// the deleted ones are skipped. Returns the names of the unpaused MCPs.
+func unpauseMCPs(ctx context.Context, c impl, names []string) ([]string, error) {
+	var unpaused []string
+	var errs []error
+	for _, name := range names {
+		mcp := &mcv1.MachineConfigPool{}
+		if err := c.ClientGet(ctx, types.NamespacedName{Name: name}, mcp); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to get %s MCP: %w", name, err))
+			}
+			continue
+		}
+		if !mcp.Spec.Paused {
+			continue
+		}
+		patch, _ := newPatch(replace, ResourceSpecPath, map[string]interface{}{"paused": false})
+		if err := c.ClientPatch(ctx, mcp, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unpause %s MCP: %w", name, err))
+			continue
+		}
+		unpaused = append(unpaused, name)
+	}
+	return unpaused, utilerrors.NewAggregate(errs)
+}
+
+// pausedMCPReleaser unpauses the MCPs paused by the operator when it shuts down,
+// they would block the machine config updates of their nodes if it's not restarted.
+// The restarted operator doesn't pause them again, the staged rollout proceeds.
+type pausedMCPReleaser struct {
+	impl
+	// reader reads the NodeObservabilityMachineConfigs from the API server
+	// as the cache may be stopped with the operator
+	reader client.Reader
+	log    logr.Logger
+}
+
+// Start waits for the operator to shut down and unpauses the MCPs.
+func (p *pausedMCPReleaser) Start(ctx context.Context) error {
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownUnpauseTimeout)
+	defer cancel()
+	nomcs := &v1alpha2.NodeObservabilityMachineConfigList{}
+	if err := p.reader.List(shutdownCtx, nomcs); err != nil {
+		p.log.Error(err, "failed to list nodeobservabilitymachineconfigs, paused MCPs not unpaused")
+		return nil
+	}
+	for _, nomc := range nomcs.Items {
+		unpaused, err := unpauseMCPs(shutdownCtx, p.impl, nomc.Status.PausedMachineConfigPools)
+		if err != nil {
+			p.log.Error(err, "failed to unpause MCPs", "nodeobservabilitymachineconfig", nomc.Name)
+		}
+		if len(unpaused) != 0 {
+			p.log.Info("Unpaused MCPs on shutdown", "nodeobservabilitymachineconfig", nomc.Name, "MCPNames", unpaused)
+		}
+	}
+	return nil
+}
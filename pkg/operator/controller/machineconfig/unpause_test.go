@@ -0,0 +1,162 @@
+package machineconfigcontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestPausedMCPRecoveredAfterCrash(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	r := testReconciler()
+	r.CtrlConfig.Spec.MachineConfigRolloutStrategy = v1alpha2.PausedMachineConfigRolloutStrategy
+	// the operator crashed after creating the paused MCP, before updating the status
+	mcp := testNodeObsMCP(r)
+	mcp.Spec.Paused = true
+	if err := ctrlutil.SetControllerReference(r.CtrlConfig, mcp, r.Scheme); err != nil {
+		t.Fatalf("failed to set controller reference: %v", err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(test.Scheme).
+		WithRuntimeObjects(r.CtrlConfig.DeepCopy(), mcp).
+		Build()
+	r.impl = &defaultImpl{Client: c}
+
+	// the restarted operator keeps the MCP paused until the unpause time
+	paused, _, err := r.handlePausedRollout(ctx)
+	if err != nil {
+		t.Fatalf("handlePausedRollout() unexpected err: %v", err)
+	}
+	if !paused {
+		t.Fatalf("handlePausedRollout() expected the MCP to stay paused")
+	}
+	if tracked := r.CtrlConfig.Status.PausedMachineConfigPools; !reflect.DeepEqual(tracked, []string{ProfilingMCPName}) {
+		t.Fatalf("expected the paused MCP to be tracked, got: %v", tracked)
+	}
+
+	// NodeObservability is deleted before the unpause time
+	if _, err := r.cleanUp(ctx, testReconcileRequest()); err != nil {
+		t.Fatalf("cleanUp() unexpected err: %v", err)
+	}
+	got := &mcv1.MachineConfigPool{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ProfilingMCPName}, got); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	if got.Spec.Paused {
+		t.Errorf("MCP expected to be unpaused before the finalizer is removed")
+	}
+	if tracked := r.CtrlConfig.Status.PausedMachineConfigPools; len(tracked) != 0 {
+		t.Errorf("expected no tracked MCP, got: %v", tracked)
+	}
+}
+
+func TestUnpauseMCPs(t *testing.T) {
+	ctx := log.IntoContext(context.TODO(), zap.New(zap.UseDevMode(true)))
+	testMCP := func(name string, paused bool) *mcv1.MachineConfigPool {
+		return &mcv1.MachineConfigPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       mcv1.MachineConfigPoolSpec{Paused: paused},
+		}
+	}
+	tests := []struct {
+		name         string
+		existing     []runtime.Object
+		names        []string
+		wantUnpaused []string
+	}{
+		{
+			name:         "paused MCP",
+			existing:     []runtime.Object{testMCP(ProfilingMCPName, true)},
+			names:        []string{ProfilingMCPName},
+			wantUnpaused: []string{ProfilingMCPName},
+		},
+		{
+			name:     "MCP already unpaused",
+			existing: []runtime.Object{testMCP(ProfilingMCPName, false)},
+			names:    []string{ProfilingMCPName},
+		},
+		{
+			name:  "MCP deleted",
+			names: []string{ProfilingMCPName},
+		},
+		{
+			name:     "MCP not paused by the operator",
+			existing: []runtime.Object{testMCP(WorkerNodeMCPName, true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tt.existing...).Build()
+			unpaused, err := unpauseMCPs(ctx, &defaultImpl{Client: c}, tt.names)
+			if err != nil {
+				t.Fatalf("unpauseMCPs() unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(unpaused, tt.wantUnpaused) {
+				t.Errorf("unpauseMCPs() unpaused: %v, want: %v", unpaused, tt.wantUnpaused)
+			}
+			if tt.name != "MCP not paused by the operator" {
+				return
+			}
+			mcp := &mcv1.MachineConfigPool{}
+			if err := c.Get(ctx, types.NamespacedName{Name: WorkerNodeMCPName}, mcp); err != nil {
+				t.Fatalf("failed to get MCP: %v", err)
+			}
+			if !mcp.Spec.Paused {
+				t.Errorf("MCP not paused by the operator expected to stay paused")
+			}
+		})
+	}
+}
+
+func TestPausedMCPReleaser(t *testing.T) {
+	nomc := testNodeObsMC()
+	nomc.Status.PausedMachineConfigPools = []string{ProfilingMCPName}
+	mcp := &mcv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: ProfilingMCPName},
+		Spec:       mcv1.MachineConfigPoolSpec{Paused: true},
+	}
+	c := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nomc, mcp).Build()
+	releaser := &pausedMCPReleaser{impl: &defaultImpl{Client: c}, reader: c, log: zap.New(zap.UseDevMode(true))}
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- releaser.Start(ctx) }()
+
+	got := &mcv1.MachineConfigPool{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: ProfilingMCPName}, got); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	if !got.Spec.Paused {
+		t.Fatalf("MCP expected to stay paused while the operator is running")
+	}
+
+	shutdown()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start() unexpected err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Start() expected to return once the operator shuts down")
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: ProfilingMCPName}, got); err != nil {
+		t.Fatalf("failed to get MCP: %v", err)
+	}
+	if got.Spec.Paused {
+		t.Errorf("MCP expected to be unpaused when the operator shuts down")
+	}
+}
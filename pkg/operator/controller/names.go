@@ -22,6 +22,11 @@ const (
 	SourceKubeletCAConfigMapNamespace = "openshift-config-managed"
 	AgentName                         = "node-observability-agent"
 	KubeletCAConfigMapName            = "kubelet-serving-ca"
+
+	// AgentServiceAccountName is the service account of the agents
+	AgentServiceAccountName = "node-observability-agent"
+	// CollectorServiceAccountName is the service account of the collector of the profiles
+	CollectorServiceAccountName = "node-observability-collector"
 )
 
 // NamespacedKubeletCAConfigMapName returns the namespaced name of the kubelet CA configmap with the provided namespace.
@@ -0,0 +1,100 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// crashLoopBackOffReason is the reason of the waiting state
+// of a container restarted after repeated failures
+const crashLoopBackOffReason = "CrashLoopBackOff"
+
+var (
+	agentsReady = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nodeobservability_agents_ready",
+			Help: "Number of nodes on which the agent pod is ready.",
+		},
+	)
+	agentsDesired = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nodeobservability_agents_desired",
+			Help: "Number of nodes on which the agent pod should be running.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(agentsReady, agentsDesired)
+}
+
+// setAgentReadyCondition reflects the readiness of the agent pods of the daemonset
+// in the NodeObservability status and in the agent metrics.
+// The condition fails while some agents are crash looping, an event is recorded once they start to.
+func (r *NodeObservabilityReconciler) setAgentReadyCondition(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ds *appsv1.DaemonSet) error {
+	agentsReady.Set(float64(ds.Status.NumberReady))
+	agentsDesired.Set(float64(ds.Status.DesiredNumberScheduled))
+
+	crashLooping, err := r.crashLoopingAgents(ctx, ds)
+	if err != nil {
+		return err
+	}
+	if len(crashLooping) > 0 {
+		msg := fmt.Sprintf("%d agent pod(s) of daemonset %s/%s crash looping: %s",
+			len(crashLooping), ds.Namespace, ds.Name, strings.Join(crashLooping, ", "))
+		if cond := nodeObs.Status.GetCondition(v1alpha2.AgentReady); cond == nil || cond.Reason != v1alpha2.ReasonFailed {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, eventReasonAgentsCrashLooping, msg)
+		}
+		nodeObs.Status.SetCondition(v1alpha2.AgentReady, metav1.ConditionFalse, v1alpha2.ReasonFailed, msg)
+		return nil
+	}
+
+	msg := fmt.Sprintf("%d of %d agent pods ready in daemonset %s/%s",
+		ds.Status.NumberReady, ds.Status.DesiredNumberScheduled, ds.Namespace, ds.Name)
+	if ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+		nodeObs.Status.SetCondition(v1alpha2.AgentReady, metav1.ConditionTrue, v1alpha2.ReasonReady, msg)
+	} else {
+		nodeObs.Status.SetCondition(v1alpha2.AgentReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+	}
+	return nil
+}
+
+// crashLoopingAgents returns the sorted names of the agent pods of the daemonset
+// with a container restarted after repeated failures.
+func (r *NodeObservabilityReconciler) crashLoopingAgents(ctx context.Context, ds *appsv1.DaemonSet) ([]string, error) {
+	if ds.Spec.Selector == nil {
+		return nil, nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ds.Namespace), client.MatchingLabels(ds.Spec.Selector.MatchLabels)); err != nil {
+		return nil, fmt.Errorf("failed to list the agent pods: %w", err)
+	}
+	var names []string
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == crashLoopBackOffReason {
+				names = append(names, pod.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resetAgentMetrics clears the agent metrics once NodeObservability is deleted.
+func resetAgentMetrics() {
+	agentsReady.Set(0)
+	agentsDesired.Set(0)
+}
@@ -0,0 +1,132 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestSetAgentReadyCondition(t *testing.T) {
+	agentPod := func(name string, labels map[string]string, waitingReason string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: test.OperatorNamespace, Labels: labels},
+		}
+		if waitingReason != "" {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: podName, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: waitingReason}}},
+			}
+		}
+		return pod
+	}
+	agentLabels := labelsForNodeObservability(nodeObsInstanceName)
+	otherLabels := map[string]string{"app": "other"}
+
+	testCases := []struct {
+		name  string
+		ready int32
+		pods  []runtime.Object
+		// agentReady is the initial reason of the agent ready condition
+		agentReady     string
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+		expectedEvent  string
+	}{
+		{
+			name:           "all agents ready",
+			ready:          3,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: operatorv1alpha2.ReasonReady,
+		},
+		{
+			name:           "agents starting",
+			ready:          2,
+			pods:           []runtime.Object{agentPod("agent-1", agentLabels, "ContainerCreating")},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:           "agents crash looping",
+			ready:          1,
+			pods:           []runtime.Object{agentPod("agent-2", agentLabels, crashLoopBackOffReason), agentPod("agent-1", agentLabels, crashLoopBackOffReason)},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonFailed,
+			expectedEvent:  "Warning AgentsCrashLooping 2 agent pod(s) of daemonset node-observability-operator/node-observability-agent crash looping: agent-1, agent-2",
+		},
+		{
+			name:           "agents still crash looping",
+			ready:          2,
+			pods:           []runtime.Object{agentPod("agent-1", agentLabels, crashLoopBackOffReason)},
+			agentReady:     operatorv1alpha2.ReasonFailed,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonFailed,
+		},
+		{
+			name:           "other pods crash looping",
+			ready:          3,
+			pods:           []runtime.Object{agentPod("other", otherLabels, crashLoopBackOffReason)},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: operatorv1alpha2.ReasonReady,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(100)
+			r := &NodeObservabilityReconciler{
+				Client:        fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.pods...).Build(),
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeObsInstanceName},
+			}
+			if tc.agentReady != "" {
+				nodeObs.Status.SetCondition(operatorv1alpha2.AgentReady, metav1.ConditionFalse, tc.agentReady, "")
+			}
+			ds := &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: daemonSetName, Namespace: test.OperatorNamespace},
+				Spec: appsv1.DaemonSetSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: agentLabels},
+				},
+				Status: appsv1.DaemonSetStatus{
+					DesiredNumberScheduled: 3,
+					NumberReady:            tc.ready,
+				},
+			}
+
+			if err := r.setAgentReadyCondition(context.Background(), nodeObs, ds); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cond := nodeObs.Status.GetCondition(operatorv1alpha2.AgentReady)
+			if cond == nil || cond.Status != tc.expectedStatus || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected agent ready condition with status %s and reason %s, got %v", tc.expectedStatus, tc.expectedReason, cond)
+			}
+			if ready := metricValue(t, agentsReady).GetGauge().GetValue(); ready != float64(tc.ready) {
+				t.Errorf("expected %d ready agents, got %v", tc.ready, ready)
+			}
+			if desired := metricValue(t, agentsDesired).GetGauge().GetValue(); desired != 3 {
+				t.Errorf("expected 3 desired agents, got %v", desired)
+			}
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	goerrors "errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// conflictRequeuePeriod is the delay before retrying a reconcile which failed
+	// on a conflict, the next attempt is likely to succeed
+	conflictRequeuePeriod = time.Second
+	// minErrorBackoff and maxErrorBackoff bound the delay before retrying a reconcile
+	// which failed on any other error, e.g. while the API server is unavailable
+	// or while an object stays missing
+	minErrorBackoff = 5 * time.Second
+	maxErrorBackoff = 5 * time.Minute
+	// errorBackoffJitter is the maximum fraction of the delay added to it
+	// for the retries of the different replicas not to be synchronized
+	errorBackoffJitter = 0.2
+
+	requeueReasonLabel    = "reason"
+	requeueReasonConflict = "conflict"
+	requeueReasonNotFound = "notfound"
+	requeueReasonTimeout  = "timeout"
+	requeueReasonError    = "error"
+)
+
+var requeuesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nodeobservability_reconcile_requeues_total",
+		Help: "Number of NodeObservability reconciles requeued after an error by reason.",
+	},
+	[]string{requeueReasonLabel},
+)
+
+func init() {
+	// registered on the controller-runtime registry
+	// to be exposed on the operator metrics endpoint
+	metrics.Registry.MustRegister(requeuesTotal)
+}
+
+// errorBackoff tracks the consecutive failures of the reconciles of each request,
+// the delay before the next retry doubles after each of them.
+type errorBackoff struct {
+	mu       sync.Mutex
+	failures map[types.NamespacedName]int
+}
+
+// next records a failure of the given request and returns the delay before its retry.
+func (b *errorBackoff) next(key types.NamespacedName) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures == nil {
+		b.failures = map[types.NamespacedName]int{}
+	}
+	b.failures[key]++
+
+	delay := minErrorBackoff
+	for i := 1; i < b.failures[key] && delay < maxErrorBackoff; i++ {
+		delay *= 2
+	}
+	delay = wait.Jitter(delay, errorBackoffJitter)
+	if delay > maxErrorBackoff {
+		delay = maxErrorBackoff
+	}
+	return delay
+}
+
+// reset forgets the failures of the given request.
+func (b *errorBackoff) reset(key types.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, key)
+}
+
+// requeueOnError turns the error returned by the reconcile of the given request
+// into a delayed requeue instead of the immediate retry of the controller.
+// The conflicts are retried quickly, the other errors, including the missing objects
+// and the exceeded reconcile deadline, are retried with an exponential backoff.
+// The error is not returned to the controller, it's not counted by controller_runtime_reconcile_errors_total.
+func (r *NodeObservabilityReconciler) requeueOnError(key types.NamespacedName, result ctrl.Result, err error) (ctrl.Result, error) {
+	if err == nil {
+		r.backoff.reset(key)
+		return result, nil
+	}
+
+	var delay time.Duration
+	var reason string
+	switch {
+	case errors.IsConflict(err):
+		delay, reason = conflictRequeuePeriod, requeueReasonConflict
+	case errors.IsNotFound(err):
+		delay, reason = r.backoff.next(key), requeueReasonNotFound
+	case goerrors.Is(err, context.DeadlineExceeded):
+		delay, reason = r.backoff.next(key), requeueReasonTimeout
+	default:
+		delay, reason = r.backoff.next(key), requeueReasonError
+	}
+	requeuesTotal.WithLabelValues(reason).Inc()
+	r.Log.Error(err, "reconciliation failed, requeueing", "reason", reason, "after", delay)
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
@@ -0,0 +1,126 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestRequeueOnError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "services"}
+	testCases := []struct {
+		name string
+		// errs are the errors of the consecutive reconciles
+		errs           []error
+		expectedReason string
+		minDelay       time.Duration
+		maxDelay       time.Duration
+	}{
+		{
+			name:           "conflict",
+			errs:           []error{fmt.Errorf("failed to update service: %w", kerrors.NewConflict(gr, "agent", fmt.Errorf("modified")))},
+			expectedReason: requeueReasonConflict,
+			minDelay:       conflictRequeuePeriod,
+			maxDelay:       conflictRequeuePeriod,
+		},
+		{
+			name:           "not found",
+			errs:           []error{kerrors.NewNotFound(gr, "agent")},
+			expectedReason: requeueReasonNotFound,
+			minDelay:       minErrorBackoff,
+			maxDelay:       minErrorBackoff + time.Duration(float64(minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "object missing again",
+			errs:           []error{fmt.Errorf("failed to get configmap: %w", kerrors.NewNotFound(gr, "agent")), kerrors.NewNotFound(gr, "agent")},
+			expectedReason: requeueReasonNotFound,
+			minDelay:       2 * minErrorBackoff,
+			maxDelay:       2*minErrorBackoff + time.Duration(float64(2*minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "deadline exceeded",
+			errs:           []error{fmt.Errorf("failed to get service: %w", context.DeadlineExceeded)},
+			expectedReason: requeueReasonTimeout,
+			minDelay:       minErrorBackoff,
+			maxDelay:       minErrorBackoff + time.Duration(float64(minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "first failure",
+			errs:           []error{kerrors.NewServiceUnavailable("unavailable")},
+			expectedReason: requeueReasonError,
+			minDelay:       minErrorBackoff,
+			maxDelay:       minErrorBackoff + time.Duration(float64(minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "third consecutive failure",
+			errs:           testFailures(3),
+			expectedReason: requeueReasonError,
+			minDelay:       4 * minErrorBackoff,
+			maxDelay:       4*minErrorBackoff + time.Duration(float64(4*minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "failure after success",
+			errs:           append(append(testFailures(2), nil), testFailures(1)...),
+			expectedReason: requeueReasonError,
+			minDelay:       minErrorBackoff,
+			maxDelay:       minErrorBackoff + time.Duration(float64(minErrorBackoff)*errorBackoffJitter),
+		},
+		{
+			name:           "capped backoff",
+			errs:           testFailures(20),
+			expectedReason: requeueReasonError,
+			minDelay:       maxErrorBackoff,
+			maxDelay:       maxErrorBackoff,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeObservabilityReconciler{
+				Log: zap.New(zap.UseDevMode(true)),
+			}
+			requeuesBefore := metricValue(t, requeuesTotal.WithLabelValues(tc.expectedReason)).GetCounter().GetValue()
+
+			var result ctrl.Result
+			for _, reconcileErr := range tc.errs {
+				var err error
+				result, err = r.requeueOnError(testRequest().NamespacedName, ctrl.Result{}, reconcileErr)
+				if err != nil {
+					t.Fatalf("unexpected error received: %v", err)
+				}
+			}
+
+			if result.RequeueAfter < tc.minDelay || result.RequeueAfter > tc.maxDelay {
+				t.Errorf("expected requeue after [%v, %v], got %v", tc.minDelay, tc.maxDelay, result.RequeueAfter)
+			}
+			if got := metricValue(t, requeuesTotal.WithLabelValues(tc.expectedReason)).GetCounter().GetValue(); got <= requeuesBefore {
+				t.Errorf("expected %s requeues counter to be incremented, got %v", tc.expectedReason, got)
+			}
+		})
+	}
+}
+
+// testFailures returns the errors of the given number of consecutive failed reconciles
+func testFailures(n int) []error {
+	errs := make([]error, 0, n)
+	for i := 0; i < n; i++ {
+		errs = append(errs, fmt.Errorf("the server is currently unable to handle the request"))
+	}
+	return errs
+}
+
+func metricValue(t *testing.T, m prometheus.Metric) *dto.Metric {
+	t.Helper()
+	out := &dto.Metric{}
+	if err := m.Write(out); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return out
+}
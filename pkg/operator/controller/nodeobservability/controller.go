@@ -29,10 +29,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	utilclock "k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -51,8 +53,48 @@ import (
 const (
 	finalizer = "NodeObservability"
 	// the name of the NodeObservability resource which will be reconciled
-	nodeObsCRName        = "cluster"
+	nodeObsCRName = "cluster"
+	// defaultRequeuePeriod is the period at which NodeObservability is reconciled again
+	// while waiting for a resource or for the machine config changes to be rolled out
 	defaultRequeuePeriod = time.Duration(5) * time.Second
+	// minRequeuePeriod bounds the requeue period for the reconciles
+	// not to flood the workqueue and the API server
+	minRequeuePeriod = time.Second
+	// machineConfigCleanupRequeuePeriod is the period at which the rollback
+	// of the machine config changes is checked during the deletion
+	machineConfigCleanupRequeuePeriod = time.Duration(30) * time.Second
+	// machineConfigCleanupTimeout is the maximum time the deletion waits
+	// for the machine config changes to be rolled back
+	machineConfigCleanupTimeout = time.Duration(30) * time.Minute
+	// rbacForbiddenRequeuePeriod is the period at which the agent service account
+	// and RBAC are retried while the operator is not allowed to manage them
+	rbacForbiddenRequeuePeriod = time.Duration(30) * time.Second
+	// defaultReconcileTimeout bounds the duration of a reconcile,
+	// the client calls are cancelled once it elapsed not to stall the worker on a slow API server
+	defaultReconcileTimeout = time.Duration(2) * time.Minute
+	// failureStatusTimeout bounds the update of the status reporting the failure of a reconcile,
+	// it's not bound by the reconcile deadline for the exceeded deadline to be reported too
+	failureStatusTimeout = time.Duration(5) * time.Second
+
+	// reasons of the events recorded for NodeObservability
+	eventReasonServiceCreated               = "ServiceCreated"
+	eventReasonServiceUpdated               = "ServiceUpdated"
+	eventReasonServingCertSecretDeleted     = "ServingCertSecretDeleted"
+	eventReasonServingCertNotInjected       = "ServingCertNotInjected"
+	eventReasonDaemonSetCreated             = "DaemonSetCreated"
+	eventReasonDaemonSetUpdated             = "DaemonSetUpdated"
+	eventReasonDaemonSetRolledOut           = "DaemonSetRolledOut"
+	eventReasonAgentsCrashLooping           = "AgentsCrashLooping"
+	eventReasonProfilesHostPathMemoryBacked = "ProfilesHostPathMemoryBacked"
+	eventReasonMachineConfigApplied         = "MachineConfigApplied"
+	eventReasonMachineConfigPoolUpdated     = "MachineConfigPoolUpdated"
+	eventReasonMachineConfigPoolDegraded    = "MachineConfigPoolDegraded"
+	eventReasonMachineConfigPoolNotFound    = "MachineConfigPoolNotFound"
+	eventReasonMachineConfigDegraded        = "MachineConfigDegraded"
+	eventReasonMachineConfigPoolMismatch    = "MachineConfigPoolMismatch"
+	eventReasonMasterNodesIncluded          = "MasterNodesIncluded"
+	eventReasonReconcileFailed              = "ReconcileFailed"
+	eventReasonForceReconcile               = "ForceReconcile"
 )
 
 var clock utilclock.Clock = utilclock.RealClock{}
@@ -60,10 +102,31 @@ var clock utilclock.Clock = utilclock.RealClock{}
 // NodeObservabilityReconciler reconciles a NodeObservability object
 type NodeObservabilityReconciler struct {
 	client.Client
-	Log        logr.Logger
-	Scheme     *runtime.Scheme
-	Namespace  string
-	AgentImage string
+	Log           logr.Logger
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+	// Namespace is the namespace of the operands: agent DaemonSet, Service, etc.
+	Namespace string
+	// OperatorNamespace is the namespace of the operator,
+	// the operand namespace is verified before use if it differs
+	OperatorNamespace string
+	AgentImage        string
+	// AgentPriorityClassName is the priority class of the agent pods
+	// when NodeObservability doesn't set any
+	AgentPriorityClassName string
+	// ReconcileTimeout bounds the duration of a reconcile,
+	// defaultReconcileTimeout is used if not set
+	ReconcileTimeout time.Duration
+	// RequeuePeriod is the period at which NodeObservability is reconciled again
+	// while waiting for a resource or for the machine config changes to be rolled out,
+	// defaultRequeuePeriod is used if not set
+	RequeuePeriod time.Duration
+	// backoff delays the retries of the failed reconciles
+	backoff errorBackoff
+	// intervals tracks the time between the reconciles
+	intervals reconcileIntervals
+	// generations tracks the latest generation of the spec reconciled
+	generations specGenerations
 	// Used to inject errors for testing
 	Err error
 }
@@ -72,8 +135,10 @@ type NodeObservabilityReconciler struct {
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=list;get;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;
-//+kubebuilder:rbac:groups=core,resources=nodes,verbs=list;get;
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=list;get;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get
 //+kubebuilder:rbac:groups=core,resources=nodes/proxy,verbs=list;get;
 //+kubebuilder:rbac:urls=/debug/*,verbs=get;
 //+kubebuilder:rbac:urls=/node-observability-status,verbs=get;
@@ -82,11 +147,14 @@ type NodeObservabilityReconciler struct {
 //+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create;
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get,resourceNames=node-observability-operator-agent
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=list;get;create;watch;delete;update;patch
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch
 //+kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,verbs=list;get;create;watch;use;delete;update;patch
 //+kubebuilder:rbac:groups=apps,namespace=node-observability-operator,resources=daemonsets,verbs=list;get;create;watch;update;patch;delete
 //+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=services,verbs=list;get;create;watch;delete;update;patch;
 //+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=serviceaccounts,verbs=list;get;create;watch;delete;update;patch;
 //+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=configmaps,verbs=list;get;create;watch;delete;update;patch
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=secrets,verbs=get;update;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=node-observability-operator,resources=servicemonitors,verbs=list;get;create;watch;delete;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -94,38 +162,63 @@ type NodeObservabilityReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
 
-func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	if ctxLog, err := logr.FromContext(ctx); err == nil {
 		r.Log = ctxLog
 	}
 
+	// the failed reconciles are retried after a delay
+	// not to hammer the API server while it's unavailable
+	defer func() {
+		result, err = r.requeueOnError(req.NamespacedName, result, err)
+	}()
+
+	// the client calls fail once the deadline is exceeded,
+	// the reconcile is requeued instead of blocking the worker
+	ctx, cancel := context.WithTimeout(ctx, r.reconcileTimeout())
+	defer cancel()
+
 	r.Log.V(1).Info("reconciliation started")
+	r.intervals.observe(req.NamespacedName, clock.Now())
 
 	// Fetch the NodeObservability instance
 	nodeObs := &operatorv1alpha2.NodeObservability{}
-	err := r.Get(ctx, req.NamespacedName, nodeObs)
+	err = r.Get(ctx, req.NamespacedName, nodeObs)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			r.Log.V(1).Info("nodeobservability resource not found. Ignoring since object must be deleted")
+			r.intervals.forget(req.NamespacedName)
+			r.generations.forget(req.NamespacedName)
+			resetAgentMetrics()
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, fmt.Errorf("failed to get nodeobservability: %w", err)
 	}
 
+	// a lagging cache may return an older spec than the one already reconciled,
+	// the reconcile is retried once the cache catches up
+	if !r.generations.observe(req.NamespacedName, nodeObs.Generation) {
+		r.Log.V(1).Info("skipping the reconcile of a stale spec", "generation", nodeObs.Generation)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	err = isClusterNodeObservability(ctx, nodeObs)
 	if err != nil {
 		// Update nodeObs Status
-		nodeObs.Status.SetCondition(operatorv1alpha2.DebugReady, metav1.ConditionFalse, operatorv1alpha2.ReasonInvalid, err.Error())
+		if nodeObs.Status.SetCondition(operatorv1alpha2.DebugReady, metav1.ConditionFalse, operatorv1alpha2.ReasonInvalid, err.Error()) {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, operatorv1alpha2.ReasonInvalid, err.Error())
+		}
 
 		nodeObs.Status.Count = 0
 		now := metav1.NewTime(clock.Now())
 		nodeObs.Status.LastUpdate = &now
 
 		// Call API Update Status
+		nodeObs.Status.RollUpConditions(nodeObs.Generation)
 		errUpdate := r.Status().Update(ctx, nodeObs)
 		if errUpdate != nil {
 			errUpdate = fmt.Errorf("failed to update status for NodeObservability %v: %w", nodeObs, errUpdate)
@@ -137,12 +230,39 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, nil
 	}
 
+	// the failures are reported on the NodeObservability
+	// as they would only be visible in the logs otherwise
+	defer func() {
+		if err != nil && !errors.IsConflict(err) {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, eventReasonReconcileFailed, err.Error())
+			// degraded until the next successful reconcile
+			if nodeObs.Status.SetStatusCondition(metav1.Condition{
+				Type:               operatorv1alpha2.Degraded,
+				Status:             metav1.ConditionTrue,
+				Reason:             operatorv1alpha2.ReasonFailed,
+				Message:            err.Error(),
+				ObservedGeneration: nodeObs.Generation,
+			}) && nodeObs.DeletionTimestamp == nil {
+				statusCtx, cancel := context.WithTimeout(context.Background(), failureStatusTimeout)
+				defer cancel()
+				if errUpdate := r.Status().Update(statusCtx, nodeObs); errUpdate != nil {
+					r.Log.Error(errUpdate, "failed to report the reconcile failure in the status")
+				}
+			}
+		}
+	}()
+
 	// nodeObs is named cluster: proceed
 	if nodeObs.DeletionTimestamp != nil {
 		r.Log.V(1).Info("nodeobservability resource is going to be deleted. Taking action")
-		if err := r.ensureNodeObservabilityDeleted(ctx, nodeObs); err != nil {
+		deleted, err := r.ensureNodeObservabilityDeleted(ctx, nodeObs)
+		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to ensure nodeobservability deletion: %w", err)
 		}
+		if !deleted {
+			r.Log.V(1).Info("waiting for the machine config changes to be rolled back")
+			return ctrl.Result{RequeueAfter: machineConfigCleanupRequeuePeriod}, nil
+		}
 		return ctrl.Result{}, nil
 
 	}
@@ -155,6 +275,38 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	nodeObs = updated
 
+	// the new spec is reported as progressing before it's acted upon,
+	// Available is set again once the status reflects its generation
+	if nodeObs.Status.MarkGenerationProgressing(nodeObs.Generation) {
+		if err := r.Status().Update(ctx, nodeObs); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status for NodeObservability %s: %w", nodeObs.Name, err)
+		}
+	}
+
+	// the reconcile is the same as usual, the value of the annotation is propagated
+	// to NodeObservabilityMachineConfig for the machine config to be reconciled too
+	forceReconcile := nodeObs.Annotations[operatorv1alpha2.ForceReconcileAnnotation]
+	if forceReconcile != "" && forceReconcile != nodeObs.Status.ObservedForceReconcile {
+		r.Log.V(1).Info("forced reconcile requested", "value", forceReconcile)
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonForceReconcile, "Forced reconcile requested with %s", forceReconcile)
+	}
+
+	// verify the operand namespace before creating any resource in it
+	nsInvalid, err := r.verifyOperandNamespace(ctx)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to verify operand namespace : %w", err)
+	}
+	if nsInvalid != "" {
+		if nodeObs.Status.SetCondition(operatorv1alpha2.DebugReady, metav1.ConditionFalse, operatorv1alpha2.ReasonInvalid, nsInvalid) {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, operatorv1alpha2.ReasonInvalid, nsInvalid)
+		}
+		nodeObs.Status.RollUpConditions(nodeObs.Generation)
+		if err := r.Status().Update(ctx, nodeObs); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status for NodeObservability %s: %w", nodeObs.Name, err)
+		}
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
 	// For the pods to deploy on each node and execute the crio & kubelet script we need the following
 	// - custom scc (mainly allowHostPathDirPlugin set to true)
 	// - serviceaccount
@@ -164,6 +316,9 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	// ensure scc (cannot be part of OLM bundle yet)
 	scc, err := r.ensureSecurityContextConstraints(ctx, nodeObs)
 	if err != nil {
+		if errors.IsForbidden(err) {
+			return r.reportRBACForbidden(ctx, nodeObs, err)
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to ensure securitycontectconstraints : %w", err)
 	}
 	r.Log.V(1).Info("securitycontextconstraint ensured", "scc.name", scc.Name)
@@ -171,6 +326,9 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	// ensure serviceaccount
 	sa, err := r.ensureServiceAccount(ctx, nodeObs, r.Namespace)
 	if err != nil {
+		if errors.IsForbidden(err) {
+			return r.reportRBACForbidden(ctx, nodeObs, err)
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to ensure serviceaccount : %w", err)
 	}
 	r.Log.V(1).Info("serviceaccount ensured", "sa.namespace", sa.Namespace, "sa.name", sa.Name)
@@ -182,9 +340,40 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	r.Log.V(1).Info("service ensured", "svc.namespace", svc.Namespace, "svc.name", svc.Name)
 
+	// delete the serving certificate secret generated before the injection was disabled
+	if err := r.deleteGeneratedServingCertSecret(ctx, nodeObs, r.Namespace); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to delete generated serving cert secret : %w", err)
+	}
+
+	// ensure the labels of the serving certificate secret of the service
+	if err := r.ensureSecretLabels(ctx, nodeObs, r.Namespace); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to ensure secret labels : %w", err)
+	}
+
+	// the generated serving certificate secret is garbage collected with the nodeobservability
+	if err := r.ensureServingCertSecretOwner(ctx, nodeObs, r.Namespace); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to ensure secret owner reference : %w", err)
+	}
+
+	// verify the serving certificate secret was generated,
+	// the secrets are not watched so its generation is polled
+	servingCertAvailable, err := r.verifyServingCertSecret(ctx, nodeObs, r.Namespace)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to verify serving cert secret : %w", err)
+	}
+
+	// ensure servicemonitor
+	if _, err := r.ensureServiceMonitor(ctx, nodeObs, r.Namespace); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to ensure servicemonitor : %w", err)
+	}
+	r.Log.V(1).Info("servicemonitor ensured", "monitoring.enabled", nodeObs.Spec.EnableMonitoring)
+
 	// verify if clusterrole exists
 	exists, err := r.verifyClusterRole(ctx)
 	if err != nil {
+		if errors.IsForbidden(err) {
+			return r.reportRBACForbidden(ctx, nodeObs, err)
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to verify clusterrole %s : %w", clusterRoleName, err)
 	} else if !exists {
 		return ctrl.Result{}, fmt.Errorf("clusterrole %q does not exist", clusterRoleName)
@@ -194,6 +383,9 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	// ensure clusterolebinding with serviceaccount
 	crb, err := r.ensureClusterRoleBinding(ctx, nodeObs, sa.Name, r.Namespace)
 	if err != nil {
+		if errors.IsForbidden(err) {
+			return r.reportRBACForbidden(ctx, nodeObs, err)
+		}
 		return ctrl.Result{}, fmt.Errorf("failed to ensure clusterrolebinding : %w", err)
 	}
 	r.Log.V(1).Info("clusterrolebinding ensured", "clusterrolebinding.name", crb.Name)
@@ -208,8 +400,50 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	if !configMapExists {
 		// kubelet CA configmap was not synced yet or doesn't exist at all,
 		// either way: no need to requeue immediately polluting the logs.
-		return reconcile.Result{RequeueAfter: defaultRequeuePeriod}, fmt.Errorf("target CA configmap %q not found", configMapNsName)
+		return reconcile.Result{RequeueAfter: r.requeuePeriod()}, fmt.Errorf("target CA configmap %q not found", configMapNsName)
+	}
+	// verify the priority class of the agents, the daemonset pods
+	// would be rejected if it doesn't exist
+	priorityClassAvailable, err := r.verifyPriorityClass(ctx, nodeObs)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to verify priorityclass : %w", err)
 	}
+	if !priorityClassAvailable {
+		msg := fmt.Sprintf("priorityclass %q does not exist", r.agentPriorityClassName(nodeObs))
+		if nodeObs.Status.SetCondition(operatorv1alpha2.DebugReady, metav1.ConditionFalse, operatorv1alpha2.ReasonInvalid, msg) {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, operatorv1alpha2.ReasonInvalid, msg)
+		}
+		nodeObs.Status.RollUpConditions(nodeObs.Generation)
+		if err := r.Status().Update(ctx, nodeObs); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status for NodeObservability %s: %w", nodeObs.Name, err)
+		}
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
+
+	// verify the pull secrets of the agent image, the daemonset is deployed
+	// even if some are missing as the kubelet retries the pulls once they are created
+	pullSecretsCond := nodeObs.Status.GetCondition(operatorv1alpha2.ImagePullSecretsAvailable)
+	pullSecretsAvailable, err := r.verifyImagePullSecrets(ctx, nodeObs, r.Namespace)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to verify image pull secrets : %w", err)
+	}
+	if !pullSecretsAvailable && (pullSecretsCond == nil || pullSecretsCond.Status != metav1.ConditionFalse) {
+		r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, operatorv1alpha2.ReasonInvalid,
+			nodeObs.Status.GetCondition(operatorv1alpha2.ImagePullSecretsAvailable).Message)
+	}
+
+	// verify that some nodes match the node selector, the daemonset is deployed
+	// anyway as the agents are scheduled on the nodes labeled later on
+	nodesMatchedCond := nodeObs.Status.GetCondition(operatorv1alpha2.NodesMatched)
+	nodesMatched, err := r.verifyMatchingNodes(ctx, nodeObs)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to verify matching nodes : %w", err)
+	}
+	if !nodesMatched && (nodesMatchedCond == nil || nodesMatchedCond.Status != metav1.ConditionFalse) {
+		r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, operatorv1alpha2.ReasonNoMatchingNodes,
+			nodeObs.Status.GetCondition(operatorv1alpha2.NodesMatched).Message)
+	}
+
 	// check daemonset
 	ds, err := r.ensureDaemonSet(ctx, nodeObs, sa, r.Namespace, kubeletCAConfigMap)
 	if err != nil {
@@ -218,9 +452,18 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	r.Log.V(1).Info("daemonset ensured", "ds.namespace", ds.Namespace, "ds.name", ds.Name)
 
 	dsReady := ds.Status.NumberReady == ds.Status.DesiredNumberScheduled
+	r.setDaemonSetRolledOutCondition(nodeObs, ds)
+	if err := r.setAgentReadyCondition(ctx, nodeObs, ds); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to check agent readiness : %w", err)
+	}
 
 	// if machine config change is not requested, we can mark it as ready
 	var mcReady bool = true
+	// the machines with the CRI-O profiling are only counted from the rollout progress
+	nodeObs.Status.ProfilingEnabledNodes, nodeObs.Status.ProfilingPendingNodes = 0, 0
+	nodeObs.Status.MachineConfig = nil
+	// the MachineConfig is only reported as degraded while its rollout is observed
+	var mcRolloutObserved bool
 	if r.machineConfigChangeRequested(ctx, nodeObs) {
 		nomc, err := r.ensureNOMC(ctx, nodeObs)
 		if err != nil {
@@ -228,6 +471,30 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 		r.Log.V(1).Info("nodeobservabilitymachineconfig ensured", "nomc.name", nomc.Name)
 		mcReady = nomc.Status.IsReady()
+		if nomc.Status.RenderedHash != "" {
+			nodeObs.Status.MachineConfig = &operatorv1alpha2.MachineConfigStatus{RenderedHash: nomc.Status.RenderedHash}
+		}
+
+		if nodeObs.Spec.MachineConfigDryRun {
+			setDryRunConditions(nodeObs, nomc)
+		} else if r.setMachineConfigPoolUnusableConditions(nodeObs, nomc) {
+			r.Log.V(1).Info("machine config changes not applied as the worker machineconfigpool does not exist")
+		} else if err := r.setMachineConfigPoolConditions(ctx, nodeObs, nomc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to check machineconfigpool rollout : %w", err)
+		} else {
+			mcRolloutObserved = true
+		}
+	} else if nodeObs.Spec.Type != operatorv1alpha2.CrioKubeletNodeObservabilityType {
+		// the CRI-O profiling enabled for the previous type is rolled back
+		if err := r.deleteNOMC(ctx, nodeObs); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to delete nodeobservabilitymachineconfig : %w", err)
+		}
+	}
+	if !mcRolloutObserved && machineConfigDegraded(nodeObs) {
+		nodeObs.Status.SetCondition(operatorv1alpha2.MachineConfigDegraded, metav1.ConditionFalse, operatorv1alpha2.ReasonAsExpected, "")
+	}
+	if !mcRolloutObserved {
+		meta.RemoveStatusCondition(&nodeObs.Status.Conditions, operatorv1alpha2.MachineConfigPoolPaused)
 	}
 
 	msg := fmt.Sprintf("DaemonSet %s ready: %t MachineConfig ready: %t", ds.Name, dsReady, mcReady)
@@ -238,17 +505,47 @@ func (r *NodeObservabilityReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 
 	nodeObs.Status.Count = ds.Status.NumberReady
+	nodeObs.Status.ObservedForceReconcile = forceReconcile
 	now := metav1.NewTime(clock.Now())
 	nodeObs.Status.LastUpdate = &now
+	nodeObs.Status.RollUpConditions(nodeObs.Generation)
 	err = r.Status().Update(ctx, nodeObs)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 	r.Log.V(1).Info("Status updated", "Count", ds.Status.NumberReady, "LastUpdated", now)
 
+	if !servingCertAvailable {
+		return ctrl.Result{RequeueAfter: servingCertRequeuePeriod}, nil
+	}
+	if !mcReady && !nodeObs.Spec.MachineConfigDryRun && !machineConfigDegraded(nodeObs) {
+		// the machineconfigpool watch may miss the end of the rollout
+		// when the pool is recreated or the operator restarts
+		return ctrl.Result{RequeueAfter: r.requeuePeriod()}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// reconcileTimeout returns the maximum duration of a reconcile.
+func (r *NodeObservabilityReconciler) reconcileTimeout() time.Duration {
+	if r.ReconcileTimeout > 0 {
+		return r.ReconcileTimeout
+	}
+	return defaultReconcileTimeout
+}
+
+// requeuePeriod returns the period at which NodeObservability is reconciled again
+// while waiting, bounded by minRequeuePeriod.
+func (r *NodeObservabilityReconciler) requeuePeriod() time.Duration {
+	if r.RequeuePeriod <= 0 {
+		return defaultRequeuePeriod
+	}
+	if r.RequeuePeriod < minRequeuePeriod {
+		return minRequeuePeriod
+	}
+	return r.RequeuePeriod
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeObservabilityReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// SCC doesn't belong to any NOB instance, thus no owner reference.
@@ -281,6 +578,15 @@ func (r *NodeObservabilityReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(&source.Kind{Type: &securityv1.SecurityContextConstraints{}},
 			handler.EnqueueRequestsFromMapFunc(anyNobInstance),
 			builder.WithPredicates(predicate.NewPredicateFuncs(ctrlutils.HasName(sccName)))).
+		// MCP doesn't belong to any NOB instance either,
+		// its rollout progress is reflected in the status of the NOBs it targets.
+		Watches(&source.Kind{Type: &mcv1.MachineConfigPool{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForMCP),
+			builder.WithPredicates(mcpRolloutChanged())).
+		// the nodes matched by the node selector are reflected in the NodesMatched condition
+		Watches(&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForNode),
+			builder.WithPredicates(nodeLabelsChanged())).
 		Complete(r)
 }
 
@@ -330,26 +636,54 @@ func (r *NodeObservabilityReconciler) withFinalizers(ctx context.Context, nodeOb
 	return withFinalizers, nil
 }
 
-func (r *NodeObservabilityReconciler) ensureNodeObservabilityDeleted(ctx context.Context, nodeObs *operatorv1alpha2.NodeObservability) error {
+// ensureNodeObservabilityDeleted removes the resources which are not garbage collected
+// and removes the finalizer once the machine config changes are rolled back.
+// Returns true if the finalizer was removed.
+func (r *NodeObservabilityReconciler) ensureNodeObservabilityDeleted(ctx context.Context, nodeObs *operatorv1alpha2.NodeObservability) (bool, error) {
 	errs := []error{}
 
-	if err := r.deleteClusterRoleBinding(nodeObs); err != nil {
+	if err := r.deleteClusterRoleBinding(ctx, nodeObs); err != nil {
 		errs = append(errs, fmt.Errorf("failed to delete clusterrolebinding : %w", err))
 	}
-	if err := r.deleteSecurityContextConstraints(nodeObs); err != nil {
+	if err := r.deleteSecurityContextConstraints(ctx, nodeObs); err != nil {
 		errs = append(errs, fmt.Errorf("failed to delete SCC : %w", err))
 	}
 	if err := r.deleteNOMC(ctx, nodeObs); err != nil {
 		errs = append(errs, fmt.Errorf("failed to delete nodeobservabilitymachineconfig : %w", err))
 	}
-	if len(errs) == 0 && hasFinalizer(nodeObs) {
+	if len(errs) != 0 {
+		return false, utilerrors.NewAggregate(errs)
+	}
+
+	cleanedUp, msg, err := r.machineConfigCleanedUp(ctx, nodeObs)
+	if err != nil {
+		return false, fmt.Errorf("failed to check machine config cleanup: %w", err)
+	}
+	if !cleanedUp {
+		if clock.Since(nodeObs.DeletionTimestamp.Time) < machineConfigCleanupTimeout {
+			nodeObs.Status.SetCondition(operatorv1alpha2.MachineConfigCleanup, metav1.ConditionFalse, operatorv1alpha2.ReasonInProgress, msg)
+			if err := r.Status().Update(ctx, nodeObs); err != nil {
+				return false, fmt.Errorf("failed to update status for nodeobservability %s: %w", nodeObs.Name, err)
+			}
+			return false, nil
+		}
+		// do not block the deletion forever
+		msg = fmt.Sprintf("machine config cleanup did not complete within %s: %s", machineConfigCleanupTimeout, msg)
+		r.Log.Info(msg)
+		nodeObs.Status.SetCondition(operatorv1alpha2.MachineConfigCleanup, metav1.ConditionFalse, operatorv1alpha2.ReasonFailed, msg)
+		if err := r.Status().Update(ctx, nodeObs); err != nil {
+			return false, fmt.Errorf("failed to update status for nodeobservability %s: %w", nodeObs.Name, err)
+		}
+	}
+
+	if hasFinalizer(nodeObs) {
 		// Remove the finalizer.
 		_, err := r.withoutFinalizers(ctx, nodeObs, finalizer)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to remove finalizer from nodeobservability %s/%s: %w", nodeObs.Namespace, nodeObs.Name, err))
+			return false, fmt.Errorf("failed to remove finalizer from nodeobservability %s/%s: %w", nodeObs.Namespace, nodeObs.Name, err)
 		}
 	}
-	return utilerrors.NewAggregate(errs)
+	return true, nil
 }
 
 // machineConfigChangeRequested returns true, when a given NodeObservabilityType needs
@@ -359,8 +693,16 @@ func (r *NodeObservabilityReconciler) machineConfigChangeRequested(ctx context.C
 	if !isChangeRequested {
 		return isChangeRequested
 	}
+	// the profiling of the node pools is enabled by the MachineConfigs of their own pools,
+	// the rendered MC of the worker pool doesn't tell whether they are enabled
+	if len(nodeObs.Spec.NodePools) != 0 {
+		return isChangeRequested
+	}
 	// avoid the creation of a new NOMC if the CRIO profiling is already enabled in the rendered MC
 	mcpName := types.NamespacedName{Name: machineconfigcontroller.WorkerNodeMCPName}
+	if nodeObs.Spec.MachineConfigPoolName != "" {
+		mcpName.Name = nodeObs.Spec.MachineConfigPoolName
+	}
 	mcp := &mcv1.MachineConfigPool{}
 	if err := r.Client.Get(ctx, mcpName, mcp); err != nil {
 		r.Log.Error(err, "failed to get the machineconfigpool instance ", "mcp.name", mcpName.Name)
@@ -18,7 +18,9 @@ package nodeobservabilitycontroller
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,8 +30,10 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/google/go-cmp/cmp"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -38,7 +42,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	securityv1 "github.com/openshift/api/security/v1"
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
 	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	machineconfigcontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig"
 	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
 )
 
@@ -91,7 +99,7 @@ func TestReconcile(t *testing.T) {
 	}{
 		{
 			name:                 "Bootstrapping",
-			existingObjects:      []runtime.Object{testNodeObservability(), makeKubeletCACM(), makeTestTargetKubeletCACM(), testClusterRole()},
+			existingObjects:      []runtime.Object{testNodeObservability(), makeKubeletCACM(), makeTestTargetKubeletCACM(), testClusterRole(), testServingCertSecret()},
 			inputRequest:         testRequest(),
 			expectedResult:       reconcile.Result{},
 			expectedEvents:       []test.Event{},
@@ -134,11 +142,12 @@ func TestReconcile(t *testing.T) {
 			tc.expectedEvents = nil
 
 			r := &NodeObservabilityReconciler{
-				Client:     cl,
-				Scheme:     test.Scheme,
-				Namespace:  test.TestNamespace,
-				Log:        zap.New(zap.UseDevMode(true)),
-				AgentImage: "test",
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+				AgentImage:    "test",
 			}
 
 			// the add and modify events should only be added when there are no errors
@@ -210,6 +219,486 @@ func TestReconcile(t *testing.T) {
 	}
 }
 
+func TestReconcileProfilingType(t *testing.T) {
+	cases := []struct {
+		name         string
+		profileType  operatorv1alpha2.NodeObservabilityType
+		nomcExpected bool
+		expectedHash string
+	}{
+		{
+			name:         "crio-kubelet enables the CRI-O profiling",
+			profileType:  operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			nomcExpected: true,
+			expectedHash: "10-crio-nodeobservability=abc",
+		},
+		{
+			name:        "kubelet rolls back the CRI-O profiling",
+			profileType: operatorv1alpha2.KubeletNodeObservabilityType,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = tc.profileType
+			// left by the previous crio-kubelet type
+			nomc := &operatorv1alpha2.NodeObservabilityMachineConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeObs.Name},
+				Spec: operatorv1alpha2.NodeObservabilityMachineConfigSpec{
+					Debug: operatorv1alpha2.NodeObservabilityDebug{EnableCrioProfiling: true},
+				},
+				Status: operatorv1alpha2.NodeObservabilityMachineConfigStatus{RenderedHash: "10-crio-nodeobservability=abc"},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs, nomc, makeKubeletCACM(), makeTestTargetKubeletCACM(), testClusterRole()).Build()
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+				AgentImage:    "test",
+			}
+
+			if _, err := r.Reconcile(context.TODO(), testRequest()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, &operatorv1alpha2.NodeObservabilityMachineConfig{})
+			if tc.nomcExpected && err != nil {
+				t.Fatalf("expected nodeobservabilitymachineconfig to exist, got %v", err)
+			}
+			if !tc.nomcExpected && !kerrors.IsNotFound(err) {
+				t.Fatalf("expected nodeobservabilitymachineconfig to be deleted, got %v", err)
+			}
+
+			got := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, got); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+			var gotHash string
+			if got.Status.MachineConfig != nil {
+				gotHash = got.Status.MachineConfig.RenderedHash
+			}
+			if gotHash != tc.expectedHash {
+				t.Errorf("expected the rendered hash %q, got %q", tc.expectedHash, gotHash)
+			}
+		})
+	}
+}
+
+func TestReconcileForceReconcile(t *testing.T) {
+	nodeObs := testNodeObservability()
+	nodeObs.Spec.Type = operatorv1alpha2.CrioKubeletNodeObservabilityType
+	nodeObs.Annotations = map[string]string{operatorv1alpha2.ForceReconcileAnnotation: "2022-06-08T12:00:00Z"}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs, makeKubeletCACM(), makeTestTargetKubeletCACM(), testClusterRole()).Build()
+	recorder := record.NewFakeRecorder(100)
+	r := &NodeObservabilityReconciler{
+		Client:        cl,
+		Scheme:        test.Scheme,
+		Namespace:     test.TestNamespace,
+		Log:           zap.New(zap.UseDevMode(true)),
+		EventRecorder: recorder,
+		AgentImage:    "test",
+	}
+
+	// the second reconcile sees the value observed by the first one
+	for i, expectedEvents := range []int{1, 0} {
+		if _, err := r.Reconcile(context.TODO(), testRequest()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got := &operatorv1alpha2.NodeObservability{}
+		if err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, got); err != nil {
+			t.Fatalf("failed to get nodeobservability: %v", err)
+		}
+		if got.Status.ObservedForceReconcile != "2022-06-08T12:00:00Z" {
+			t.Errorf("reconcile %d: expected the force reconcile value to be observed, got %q", i, got.Status.ObservedForceReconcile)
+		}
+		nomc := &operatorv1alpha2.NodeObservabilityMachineConfig{}
+		if err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, nomc); err != nil {
+			t.Fatalf("failed to get nodeobservabilitymachineconfig: %v", err)
+		}
+		if value := nomc.Annotations[operatorv1alpha2.ForceReconcileAnnotation]; value != "2022-06-08T12:00:00Z" {
+			t.Errorf("reconcile %d: expected the force reconcile annotation to be propagated, got %q", i, value)
+		}
+
+		forceEvents := 0
+		for len(recorder.Events) > 0 {
+			if event := <-recorder.Events; strings.Contains(event, eventReasonForceReconcile) {
+				forceEvents++
+			}
+		}
+		if forceEvents != expectedEvents {
+			t.Errorf("reconcile %d: expected %d force reconcile events, got %d", i, expectedEvents, forceEvents)
+		}
+	}
+}
+
+func TestReconcileObservedGeneration(t *testing.T) {
+	testCases := []struct {
+		name string
+		// reconciledGeneration is the generation already reconciled by the controller
+		reconciledGeneration int64
+		expectSkipped        bool
+	}{
+		{
+			name: "new generation",
+		},
+		{
+			name:                 "same generation",
+			reconciledGeneration: 2,
+		},
+		{
+			name:                 "stale generation",
+			reconciledGeneration: 3,
+			expectSkipped:        true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = operatorv1alpha2.KubeletNodeObservabilityType
+			nodeObs.Generation = 2
+			nodeObs.Status.ObservedGeneration = 1
+			nodeObs.Status.SetStatusCondition(metav1.Condition{Type: operatorv1alpha2.Available, Status: metav1.ConditionTrue, Reason: operatorv1alpha2.ReasonReady, ObservedGeneration: 1})
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs, makeKubeletCACM(), makeTestTargetKubeletCACM(), testClusterRole()).Build()
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+				AgentImage:    "test",
+			}
+			r.generations.observe(testRequest().NamespacedName, tc.reconciledGeneration)
+
+			result, err := r.Reconcile(context.TODO(), testRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Requeue != tc.expectSkipped {
+				t.Errorf("expected requeue to be %t, got %t", tc.expectSkipped, result.Requeue)
+			}
+
+			got := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, got); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+			expectedGeneration := map[bool]int64{true: 1, false: 2}[tc.expectSkipped]
+			if got.Status.ObservedGeneration != expectedGeneration {
+				t.Errorf("expected observed generation %d, got %d", expectedGeneration, got.Status.ObservedGeneration)
+			}
+			ds := &appsv1.DaemonSet{}
+			err = cl.Get(context.TODO(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, ds)
+			if tc.expectSkipped != kerrors.IsNotFound(err) {
+				t.Errorf("expected the daemonset to be created only when the spec isn't stale, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	testCases := []struct {
+		name              string
+		delayReads        bool
+		delayWrites       bool
+		delayStatusWrites bool
+		expectedDegraded  bool
+	}{
+		{
+			name:       "slow reads",
+			delayReads: true,
+		},
+		{
+			name:              "slow writes",
+			delayWrites:       true,
+			delayStatusWrites: true,
+		},
+		{
+			name:             "timeout reported in the status",
+			delayWrites:      true,
+			expectedDegraded: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = operatorv1alpha2.KubeletNodeObservabilityType
+			cl := &delayingClient{
+				Client:            fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs).Build(),
+				delay:             time.Minute,
+				delayReads:        tc.delayReads,
+				delayWrites:       tc.delayWrites,
+				delayStatusWrites: tc.delayStatusWrites,
+			}
+			r := &NodeObservabilityReconciler{
+				Client:           cl,
+				Scheme:           test.Scheme,
+				Namespace:        test.TestNamespace,
+				Log:              zap.New(zap.UseDevMode(true)),
+				EventRecorder:    record.NewFakeRecorder(100),
+				AgentImage:       "test",
+				ReconcileTimeout: 100 * time.Millisecond,
+			}
+			requeuesBefore := metricValue(t, requeuesTotal.WithLabelValues(requeueReasonTimeout)).GetCounter().GetValue()
+
+			start := time.Now()
+			result, err := r.Reconcile(context.TODO(), testRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("expected the client calls to be cancelled after the reconcile timeout, reconcile took %v", elapsed)
+			}
+			if result.RequeueAfter < minErrorBackoff {
+				t.Errorf("expected the reconcile to be requeued after at least %v, got %v", minErrorBackoff, result.RequeueAfter)
+			}
+			if got := metricValue(t, requeuesTotal.WithLabelValues(requeueReasonTimeout)).GetCounter().GetValue(); got <= requeuesBefore {
+				t.Errorf("expected %s requeues counter to be incremented, got %v", requeueReasonTimeout, got)
+			}
+			if !tc.expectedDegraded {
+				return
+			}
+			got := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Client.Get(context.TODO(), testRequest().NamespacedName, got); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+			if cond := got.Status.GetCondition(operatorv1alpha2.Degraded); cond == nil || cond.Status != metav1.ConditionTrue ||
+				!strings.Contains(cond.Message, context.DeadlineExceeded.Error()) {
+				t.Errorf("expected the exceeded deadline to be reported in the degraded condition, got %v", cond)
+			}
+		})
+	}
+}
+
+func TestReconcileRBACForbidden(t *testing.T) {
+	testCases := []struct {
+		name      string
+		forbidden client.Object
+	}{
+		{
+			name:      "service account",
+			forbidden: &corev1.ServiceAccount{},
+		},
+		{
+			name:      "securitycontextconstraints",
+			forbidden: &securityv1.SecurityContextConstraints{},
+		},
+		{
+			name:      "clusterrolebinding",
+			forbidden: &rbacv1.ClusterRoleBinding{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = operatorv1alpha2.KubeletNodeObservabilityType
+			cl := &forbiddingClient{
+				Client:    fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs, testClusterRole()).Build(),
+				forbidden: tc.forbidden,
+			}
+			recorder := record.NewFakeRecorder(100)
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+				AgentImage:    "test",
+			}
+
+			result, err := r.Reconcile(context.TODO(), testRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != rbacForbiddenRequeuePeriod {
+				t.Errorf("expected the reconcile to be requeued after %v, got %v", rbacForbiddenRequeuePeriod, result.RequeueAfter)
+			}
+
+			got := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Get(context.TODO(), testRequest().NamespacedName, got); err != nil {
+				t.Fatalf("failed to get NodeObservability: %v", err)
+			}
+			for _, condType := range []string{operatorv1alpha2.DebugReady, operatorv1alpha2.Degraded} {
+				cond := got.Status.GetCondition(condType)
+				if cond == nil || cond.Reason != operatorv1alpha2.ReasonForbidden {
+					t.Errorf("expected %s condition with %s reason, got %v", condType, operatorv1alpha2.ReasonForbidden, cond)
+				}
+			}
+			close(recorder.Events)
+			var warned bool
+			for event := range recorder.Events {
+				warned = warned || strings.HasPrefix(event, "Warning "+operatorv1alpha2.ReasonForbidden)
+			}
+			if !warned {
+				t.Errorf("expected %s warning event, got none", operatorv1alpha2.ReasonForbidden)
+			}
+		})
+	}
+}
+
+// forbiddingClient forbids the creation of the objects
+// of the same type as the forbidden one.
+type forbiddingClient struct {
+	client.Client
+	forbidden client.Object
+}
+
+func (c *forbiddingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if reflect.TypeOf(obj) == reflect.TypeOf(c.forbidden) {
+		return kerrors.NewForbidden(schema.GroupResource{}, obj.GetName(), fmt.Errorf("operator is not allowed"))
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// delayingClient delays the calls of the wrapped client
+// until the delay elapsed or the context is done, like a slow API server.
+type delayingClient struct {
+	client.Client
+	delay             time.Duration
+	delayReads        bool
+	delayWrites       bool
+	delayStatusWrites bool
+}
+
+func (c *delayingClient) wait(ctx context.Context, delayed bool) error {
+	// the calls fail right away once the context is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !delayed {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.delay):
+		return nil
+	}
+}
+
+func (c *delayingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.wait(ctx, c.delayReads); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *delayingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.wait(ctx, c.delayReads); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *delayingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.wait(ctx, c.delayWrites); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *delayingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.wait(ctx, c.delayWrites); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *delayingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.wait(ctx, c.delayWrites); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *delayingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.wait(ctx, c.delayWrites); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *delayingClient) Status() client.StatusWriter {
+	return &delayingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// delayingStatusWriter delays the status updates of delayingClient.
+type delayingStatusWriter struct {
+	client.StatusWriter
+	client *delayingClient
+}
+
+func (w *delayingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.client.wait(ctx, w.client.delayStatusWrites); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *delayingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.client.wait(ctx, w.client.delayStatusWrites); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestMachineConfigChangeRequested(t *testing.T) {
+	renderedMC := &mcv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rendered-worker-1"},
+		Spec: mcv1.MachineConfigSpec{
+			Config: runtime.RawExtension{Raw: []byte(`{"storage":{"files":[{"contents":{"source":"data:,` + machineconfigcontroller.CrioUnixSocketEnvString + `"}}]}}`)},
+		},
+	}
+	workerMCP := &mcv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: machineconfigcontroller.WorkerNodeMCPName},
+		Spec: mcv1.MachineConfigPoolSpec{
+			Configuration: mcv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: renderedMC.Name}},
+		},
+	}
+	testCases := []struct {
+		name      string
+		nodeObs   *operatorv1alpha2.NodeObservability
+		objects   []runtime.Object
+		requested bool
+	}{
+		{
+			name:    "kubelet profiling only",
+			nodeObs: &operatorv1alpha2.NodeObservability{Spec: operatorv1alpha2.NodeObservabilitySpec{Type: operatorv1alpha2.KubeletNodeObservabilityType}},
+		},
+		{
+			name:      "crio profiling not enabled yet",
+			nodeObs:   &operatorv1alpha2.NodeObservability{Spec: operatorv1alpha2.NodeObservabilitySpec{Type: operatorv1alpha2.CrioKubeletNodeObservabilityType}},
+			objects:   []runtime.Object{workerMCP},
+			requested: true,
+		},
+		{
+			name:    "crio profiling already enabled in the worker pool",
+			nodeObs: &operatorv1alpha2.NodeObservability{Spec: operatorv1alpha2.NodeObservabilitySpec{Type: operatorv1alpha2.CrioKubeletNodeObservabilityType}},
+			objects: []runtime.Object{workerMCP, renderedMC},
+		},
+		{
+			name: "node pools with crio profiling enabled in the worker pool",
+			nodeObs: &operatorv1alpha2.NodeObservability{Spec: operatorv1alpha2.NodeObservabilitySpec{
+				Type:      operatorv1alpha2.CrioKubeletNodeObservabilityType,
+				NodePools: []operatorv1alpha2.NodePool{{Name: "us-east"}, {Name: "us-west"}},
+			}},
+			objects:   []runtime.Object{workerMCP, renderedMC},
+			requested: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeObservabilityReconciler{
+				Client: fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.objects...).Build(),
+				Log:    zap.New(zap.UseDevMode(true)),
+			}
+			if got := r.machineConfigChangeRequested(context.TODO(), tc.nodeObs); got != tc.requested {
+				t.Errorf("expected machine config change requested %t, got %t", tc.requested, got)
+			}
+		})
+	}
+}
+
 func TestIsClusterNodeObservability(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -311,3 +800,130 @@ func makeTestTargetKubeletCACM() *corev1.ConfigMap {
 		},
 	}
 }
+
+func TestEnsureNodeObservabilityDeleted(t *testing.T) {
+	testCases := []struct {
+		name              string
+		existingObjects   []runtime.Object
+		deletionTimestamp time.Time
+		expectedDeleted   bool
+		expectedReason    string
+	}{
+		{
+			name:              "machine config changes rolled back",
+			existingObjects:   []runtime.Object{testWorkerMCP(true, false, 3, 3)},
+			deletionTimestamp: time.Now(),
+			expectedDeleted:   true,
+		},
+		{
+			name:              "nodeobservabilitymachineconfig not removed yet",
+			existingObjects:   []runtime.Object{testNOMCToBeDeleted(), testWorkerMCP(true, false, 3, 3)},
+			deletionTimestamp: time.Now(),
+			expectedReason:    operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:              "profiling machineconfig not removed yet",
+			existingObjects:   []runtime.Object{testProfilingMC(), testWorkerMCP(true, false, 3, 3)},
+			deletionTimestamp: time.Now(),
+			expectedReason:    operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:              "worker machineconfigpool updating",
+			existingObjects:   []runtime.Object{testWorkerMCP(false, true, 3, 1)},
+			deletionTimestamp: time.Now(),
+			expectedReason:    operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:              "cleanup timed out",
+			existingObjects:   []runtime.Object{testWorkerMCP(false, true, 3, 1)},
+			deletionTimestamp: time.Now().Add(-2 * machineConfigCleanupTimeout),
+			expectedDeleted:   true,
+			expectedReason:    operatorv1alpha2.ReasonFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservabilityToBeDeleted()
+			nodeObs.DeletionTimestamp = &metav1.Time{Time: tc.deletionTimestamp}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(append(tc.existingObjects, nodeObs)...).Build()
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+			}
+
+			current := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: nodeObs.Name}, current); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+
+			deleted, err := r.ensureNodeObservabilityDeleted(context.TODO(), current)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if deleted != tc.expectedDeleted {
+				t.Errorf("expected deleted to be %t, got %t", tc.expectedDeleted, deleted)
+			}
+			if !tc.expectedDeleted && !hasFinalizer(current) {
+				t.Errorf("expected finalizer to be kept")
+			}
+
+			cond := current.Status.GetCondition(operatorv1alpha2.MachineConfigCleanup)
+			if tc.expectedReason == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %s: %v", operatorv1alpha2.MachineConfigCleanup, cond)
+				}
+				return
+			}
+			if cond == nil {
+				t.Fatalf("expected condition %s to be set", operatorv1alpha2.MachineConfigCleanup)
+			}
+			if cond.Status != metav1.ConditionFalse || cond.Reason != tc.expectedReason {
+				t.Errorf("expected condition %s to be False with reason %s, got %s with reason %s", operatorv1alpha2.MachineConfigCleanup, tc.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func testNOMCToBeDeleted() *operatorv1alpha2.NodeObservabilityMachineConfig {
+	return &operatorv1alpha2.NodeObservabilityMachineConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "cluster",
+			Finalizers:        []string{"NodeObservabilityMachineConfig"},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+	}
+}
+
+func testProfilingMC() *mcv1.MachineConfig {
+	return &mcv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: machineconfigcontroller.CrioProfilingConfigName,
+		},
+	}
+}
+
+func testWorkerMCP(updated, updating bool, machineCount, updatedMachineCount int32) *mcv1.MachineConfigPool {
+	condStatus := func(b bool) corev1.ConditionStatus {
+		if b {
+			return corev1.ConditionTrue
+		}
+		return corev1.ConditionFalse
+	}
+	return &mcv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: machineconfigcontroller.WorkerNodeMCPName,
+		},
+		Status: mcv1.MachineConfigPoolStatus{
+			MachineCount:        machineCount,
+			UpdatedMachineCount: updatedMachineCount,
+			Conditions: []mcv1.MachineConfigPoolCondition{
+				{Type: mcv1.MachineConfigPoolUpdated, Status: condStatus(updated)},
+				{Type: mcv1.MachineConfigPoolUpdating, Status: condStatus(updating)},
+			},
+		},
+	}
+}
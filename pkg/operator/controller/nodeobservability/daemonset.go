@@ -3,23 +3,34 @@ package nodeobservabilitycontroller
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	machineconfigcontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig"
 )
 
 const (
-	podName               = "node-observability-agent"
-	socketName            = "socket"
-	socketPath            = "/var/run/crio/crio.sock"
+	podName    = "node-observability-agent"
+	socketName = "socket"
+	// defaultSocketPath is the path of the CRI-O socket on the nodes
+	// when not set in the spec, it's mounted at the same path in the agent container
+	defaultSocketPath     = "/var/run/crio/crio.sock"
 	socketMountPath       = "/var/run/crio/crio.sock"
 	kbltCAMountPath       = "/var/run/secrets/kubelet-serving-ca/"
 	kbltCAMountedFile     = "ca-bundle.crt"
@@ -29,12 +40,81 @@ const (
 	daemonSetName         = "node-observability-agent"
 	certsName             = "certs"
 	certsMountPath        = "/var/run/secrets/openshift.io/certs"
+	profilesName          = "profiles"
+	// profilesMountPath is the directory in which the agent writes the profiles,
+	// the profiles host path is mounted on it when set in the spec
+	profilesMountPath = "/run/node-observability"
+	// defaultImagePullPolicy is the pull policy of the agent image
+	// when not set in the spec
+	defaultImagePullPolicy = corev1.PullIfNotPresent
+	// healthzPath is the endpoint of the agent probed through the agent port,
+	// the agent must serve it without authentication
+	healthzPath = "/healthz"
+	// agentLogLevelEnvName is the environment variable
+	// setting the log level of the agent
+	agentLogLevelEnvName = "AGENT_LOG_LEVEL"
+	// etcdCertsName is the volume of the etcd client certificates,
+	// mounted with the etcd type only
+	etcdCertsName = "etcd-certs"
+	// etcdCertsHostPath is the directory of the etcd certificates on the master nodes
+	etcdCertsHostPath  = "/etc/kubernetes/static-pod-resources/etcd-certs"
+	etcdCertsMountPath = "/var/run/secrets/etcd-certs"
+	// etcdCertsDirEnvName is the environment variable
+	// passing the directory of the etcd client certificates to the agent
+	etcdCertsDirEnvName = "ETCD_CERTS_DIR"
+	// defaultTerminationGracePeriod is the grace period of the agent pods
+	// when not set in the spec: the longest CPU profile of a run (5m)
+	// and a margin to write the profiles
+	defaultTerminationGracePeriod = int64(330)
 )
 
+var (
+	// defaultReadinessProbe is the readiness probe of the agent
+	// when not tuned in the spec
+	defaultReadinessProbe = v1alpha2.AgentProbe{
+		InitialDelaySeconds: pointer.Int32(5),
+		TimeoutSeconds:      pointer.Int32(5),
+		PeriodSeconds:       pointer.Int32(10),
+		FailureThreshold:    pointer.Int32(3),
+	}
+	// defaultLivenessProbe is the liveness probe of the agent
+	// when not tuned in the spec
+	defaultLivenessProbe = v1alpha2.AgentProbe{
+		InitialDelaySeconds: pointer.Int32(15),
+		TimeoutSeconds:      pointer.Int32(5),
+		PeriodSeconds:       pointer.Int32(20),
+		FailureThreshold:    pointer.Int32(3),
+	}
+)
+
+// percentRegexp matches the percentages of the rolling update parameters
+var percentRegexp = regexp.MustCompile(`^[0-9]+%$`)
+
+// imageReferenceRegexp matches the image references: [domain[:port]/]name[:tag][@digest]
+var imageReferenceRegexp = regexp.MustCompile(`^` +
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9A-Fa-f]{32,})?` +
+	`$`)
+
 // ensureDaemonSet ensures that the daemonset exists
 // Returns a Boolean value indicating whether it exists, a pointer to the
 // daemonset and an error when relevant
 func (r *NodeObservabilityReconciler) ensureDaemonSet(ctx context.Context, nodeObs *v1alpha2.NodeObservability, sa *corev1.ServiceAccount, ns string, kubeletCAConfigMap *corev1.ConfigMap) (*appsv1.DaemonSet, error) {
+	if err := validateResources(nodeObs.Spec.Resources); err != nil {
+		return nil, fmt.Errorf("failed to build daemonset: %w", err)
+	}
+	if err := validateImage(r.agentImage(nodeObs)); err != nil {
+		return nil, fmt.Errorf("failed to build daemonset: %w", err)
+	}
+	if err := validateUpdateStrategy(agentUpdateStrategy(nodeObs)); err != nil {
+		return nil, fmt.Errorf("failed to build daemonset: %w", err)
+	}
+	if err := validateSecurityContext(nodeObs); err != nil {
+		return nil, fmt.Errorf("failed to build daemonset: %w", err)
+	}
+
 	desired := r.desiredDaemonSet(nodeObs, sa, ns, kubeletCAConfigMap.Name)
 	if err := controllerutil.SetControllerReference(nodeObs, desired, r.Scheme); err != nil {
 		return nil, fmt.Errorf("failed to set the controller reference for daemonset: %w", err)
@@ -55,6 +135,8 @@ func (r *NodeObservabilityReconciler) ensureDaemonSet(ctx context.Context, nodeO
 			return nil, fmt.Errorf("failed to create daemonset %q: %w", nameSpace, err)
 		}
 		r.Log.V(1).Info("created daemonset", "ds.namespace", nameSpace.Namespace, "ds.name", nameSpace.Name)
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonDaemonSetCreated, "Created daemonset %s", nameSpace)
+		r.warnProfilesHostPath(nodeObs)
 
 		return r.currentDaemonSet(ctx, nameSpace)
 	}
@@ -70,6 +152,8 @@ func (r *NodeObservabilityReconciler) ensureDaemonSet(ctx context.Context, nodeO
 			return nil, fmt.Errorf("failed to get existing daemonset %q: %w", nameSpace, err)
 		}
 		r.Log.V(1).Info("successfully updated daemonset", "ds.name", nameSpace.Name, "ds.namespace", nameSpace.Namespace)
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonDaemonSetUpdated, "Updated daemonset %s", nameSpace)
+		r.warnProfilesHostPath(nodeObs)
 	}
 	return current, nil
 }
@@ -83,6 +167,30 @@ func (r *NodeObservabilityReconciler) currentDaemonSet(ctx context.Context, name
 	return ds, nil
 }
 
+// setDaemonSetRolledOutCondition reflects the rollout progress of the agent DaemonSet
+// in the NodeObservability status, an event is recorded once the rollout completes.
+func (r *NodeObservabilityReconciler) setDaemonSetRolledOutCondition(nodeObs *v1alpha2.NodeObservability, ds *appsv1.DaemonSet) {
+	msg := fmt.Sprintf("%d of %d agent pods updated and available in daemonset %s/%s",
+		ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled, ds.Namespace, ds.Name)
+	if !daemonSetRolledOut(ds) {
+		nodeObs.Status.SetCondition(v1alpha2.DaemonSetRolledOut, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+		return
+	}
+	if cond := nodeObs.Status.GetCondition(v1alpha2.DaemonSetRolledOut); cond == nil || cond.Status != metav1.ConditionTrue {
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonDaemonSetRolledOut, "Rolled out daemonset %s/%s on %d node(s)",
+			ds.Namespace, ds.Name, ds.Status.DesiredNumberScheduled)
+	}
+	nodeObs.Status.SetCondition(v1alpha2.DaemonSetRolledOut, metav1.ConditionTrue, v1alpha2.ReasonReady, msg)
+}
+
+// daemonSetRolledOut returns true if the pods of the current spec
+// of the given daemonset are available on all the nodes.
+func daemonSetRolledOut(ds *appsv1.DaemonSet) bool {
+	return ds.Status.ObservedGeneration >= ds.Generation &&
+		ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+		ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled
+}
+
 // createDaemonSet creates the serviceaccount
 func (r *NodeObservabilityReconciler) createDaemonSet(ctx context.Context, ds *appsv1.DaemonSet) error {
 	return r.Create(ctx, ds)
@@ -97,11 +205,25 @@ func (r *NodeObservabilityReconciler) updateDaemonset(ctx context.Context, curre
 		updated = true
 	}
 
+	// the new strategy applies to the rollout of the template changes of the same update
+	if !equality.Semantic.DeepEqual(current.Spec.UpdateStrategy, desired.Spec.UpdateStrategy) {
+		updatedDS.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+		updated = true
+	}
+
+	if setUserLabels(updatedDS, managedLabels(desired)) {
+		updated = true
+	}
+
 	if !cmp.Equal(current.Spec.Template.Labels, desired.Spec.Template.Labels) {
 		updatedDS.Spec.Template.Labels = desired.Spec.Template.Labels
 		updated = true
 	}
 
+	if setPodAnnotations(&updatedDS.Spec.Template, desired.Spec.Template.Annotations) {
+		updated = true
+	}
+
 	if changed, updatedContainers := containersChanged(current.Spec.Template.Spec.Containers, desired.Spec.Template.Spec.Containers); changed {
 		updatedDS.Spec.Template.Spec.Containers = updatedContainers
 		updated = true
@@ -112,11 +234,68 @@ func (r *NodeObservabilityReconciler) updateDaemonset(ctx context.Context, curre
 		updated = true
 	}
 
+	// the unexpected volumes are kept, except the ones of the spec once they're no longer required
+	for _, name := range []string{profilesName, etcdCertsName} {
+		if !hasVolume(desired, name) && dropVolume(updatedDS, name) {
+			updated = true
+		}
+	}
+
+	if !cmp.Equal(current.Spec.Template.Spec.NodeSelector, desired.Spec.Template.Spec.NodeSelector, cmpopts.EquateEmpty()) {
+		updatedDS.Spec.Template.Spec.NodeSelector = desired.Spec.Template.Spec.NodeSelector
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Spec.Template.Spec.Affinity, desired.Spec.Template.Spec.Affinity) {
+		updatedDS.Spec.Template.Spec.Affinity = desired.Spec.Template.Spec.Affinity
+		updated = true
+	}
+
+	if !cmp.Equal(current.Spec.Template.Spec.Tolerations, desired.Spec.Template.Spec.Tolerations, cmpopts.EquateEmpty()) {
+		updatedDS.Spec.Template.Spec.Tolerations = desired.Spec.Template.Spec.Tolerations
+		updated = true
+	}
+
+	if current.Spec.Template.Spec.ServiceAccountName != desired.Spec.Template.Spec.ServiceAccountName {
+		updatedDS.Spec.Template.Spec.ServiceAccountName = desired.Spec.Template.Spec.ServiceAccountName
+		updated = true
+	}
+
+	if current.Spec.Template.Spec.PriorityClassName != desired.Spec.Template.Spec.PriorityClassName {
+		updatedDS.Spec.Template.Spec.PriorityClassName = desired.Spec.Template.Spec.PriorityClassName
+		updated = true
+	}
+
+	if !cmp.Equal(current.Spec.Template.Spec.ImagePullSecrets, desired.Spec.Template.Spec.ImagePullSecrets, cmpopts.EquateEmpty()) {
+		updatedDS.Spec.Template.Spec.ImagePullSecrets = desired.Spec.Template.Spec.ImagePullSecrets
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Spec.Template.Spec.SecurityContext, desired.Spec.Template.Spec.SecurityContext) {
+		updatedDS.Spec.Template.Spec.SecurityContext = desired.Spec.Template.Spec.SecurityContext
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Spec.Template.Spec.TerminationGracePeriodSeconds, desired.Spec.Template.Spec.TerminationGracePeriodSeconds) {
+		updatedDS.Spec.Template.Spec.TerminationGracePeriodSeconds = desired.Spec.Template.Spec.TerminationGracePeriodSeconds
+		updated = true
+	}
+
+	if current.Spec.Template.Spec.HostNetwork != desired.Spec.Template.Spec.HostNetwork {
+		updatedDS.Spec.Template.Spec.HostNetwork = desired.Spec.Template.Spec.HostNetwork
+		updated = true
+	}
+
 	if !cmp.Equal(current.Spec.Template.Spec.DNSPolicy, desired.Spec.Template.Spec.DNSPolicy) {
 		updatedDS.Spec.Template.Spec.DNSPolicy = desired.Spec.Template.Spec.DNSPolicy
 		updated = true
 	}
 
+	if !equality.Semantic.DeepEqual(current.Spec.Template.Spec.DNSConfig, desired.Spec.Template.Spec.DNSConfig) {
+		updatedDS.Spec.Template.Spec.DNSConfig = desired.Spec.Template.Spec.DNSConfig
+		updated = true
+	}
+
 	if updated {
 		if err := r.Update(ctx, updatedDS); err != nil {
 			return false, err
@@ -130,11 +309,9 @@ func (r *NodeObservabilityReconciler) updateDaemonset(ctx context.Context, curre
 // desiredDaemonSet returns a DaemonSet object
 func (r *NodeObservabilityReconciler) desiredDaemonSet(nodeObs *v1alpha2.NodeObservability, sa *corev1.ServiceAccount, ns string, kubeletCAConfigMapName string) *appsv1.DaemonSet {
 	ls := labelsForNodeObservability(nodeObs.Name)
-	// profiling probe currently takes 30 seconds (default),
-	// giving enough time to gracefully finish all the profiling requests
-	tgp := int64(45)
+	tgp := agentTerminationGracePeriod(nodeObs)
 	vst := corev1.HostPathSocket
-	privileged := true
+	p := agentPort(nodeObs)
 
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -145,36 +322,29 @@ func (r *NodeObservabilityReconciler) desiredDaemonSet(nodeObs *v1alpha2.NodeObs
 			Selector: &metav1.LabelSelector{
 				MatchLabels: ls,
 			},
+			UpdateStrategy: *agentUpdateStrategy(nodeObs),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: ls,
+					Labels:      withUserLabels(nodeObs, ls),
+					Annotations: podAnnotations(nodeObs),
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Image:           r.AgentImage,
-							ImagePullPolicy: corev1.PullIfNotPresent,
+							Image:           r.agentImage(nodeObs),
+							ImagePullPolicy: agentImagePullPolicy(nodeObs),
 							Name:            podName,
 							Command:         []string{"node-observability-agent"},
 							Args: []string{
 								"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
-								"--storage=/run/node-observability",
+								fmt.Sprintf("--storage=%s", profilesMountPath),
 								fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
 							},
-							Resources: corev1.ResourceRequirements{},
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &privileged,
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "NODE_IP",
-									ValueFrom: &corev1.EnvVarSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											FieldPath: "status.hostIP",
-										},
-									},
-								},
-							},
+							Resources:       *nodeObs.Spec.Resources.DeepCopy(),
+							ReadinessProbe:  agentProbe(nodeObs.Spec.ReadinessProbe, defaultReadinessProbe, p),
+							LivenessProbe:   agentProbe(nodeObs.Spec.LivenessProbe, defaultLivenessProbe, p),
+							SecurityContext: agentSecurityContext(nodeObs),
+							Env:             agentEnv(nodeObs),
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									MountPath: socketMountPath,
@@ -193,13 +363,22 @@ func (r *NodeObservabilityReconciler) desiredDaemonSet(nodeObs *v1alpha2.NodeObs
 							Image:           "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0",
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Args: []string{
-								"--secure-listen-address=0.0.0.0:8443",
+								fmt.Sprintf("--secure-listen-address=0.0.0.0:%d", p),
 								"--upstream=http://127.0.0.1:9000/",
+								// the probes of the kubelet are not authenticated
+								fmt.Sprintf("--ignore-paths=%s", healthzPath),
 								fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
 								fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
 								"--logtostderr=true",
 								"--v=2",
 							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "https",
+									ContainerPort: p,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      certsName,
@@ -219,7 +398,7 @@ func (r *NodeObservabilityReconciler) desiredDaemonSet(nodeObs *v1alpha2.NodeObs
 							Name: socketName,
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: socketPath,
+									Path: crioSocketPath(nodeObs),
 									Type: &vst,
 								},
 							},
@@ -238,19 +417,553 @@ func (r *NodeObservabilityReconciler) desiredDaemonSet(nodeObs *v1alpha2.NodeObs
 							Name: certsName,
 							VolumeSource: corev1.VolumeSource{
 								Secret: &corev1.SecretVolumeSource{
-									SecretName: secretName,
+									SecretName: servingCertSecretName(nodeObs),
 								},
 							},
 						},
 					},
-					NodeSelector: nodeObs.Spec.NodeSelector,
+					SecurityContext:   agentPodSecurityContext(nodeObs),
+					NodeSelector:      nodeObs.Spec.NodeSelector,
+					Affinity:          agentAffinity(nodeObs),
+					Tolerations:       agentTolerations(nodeObs),
+					PriorityClassName: r.agentPriorityClassName(nodeObs),
+					ImagePullSecrets:  nodeObs.Spec.ImagePullSecrets,
 				},
 			},
 		},
 	}
+	setProfilesVolume(ds, nodeObs)
+	setEtcdCertsVolume(ds, nodeObs)
+	setHostNetwork(ds, nodeObs)
+	setDNS(ds, nodeObs)
+	setUserLabels(ds, userLabels(nodeObs))
 	return ds
 }
 
+// setHostNetwork runs the agent pods in the network namespace of the nodes if requested in the spec,
+// the agent port is declared as a host port for the scheduler to rule out the nodes where it's taken.
+func setHostNetwork(ds *appsv1.DaemonSet, nodeObs *v1alpha2.NodeObservability) {
+	if !nodeObs.Spec.HostNetwork {
+		return
+	}
+	spec := &ds.Spec.Template.Spec
+	spec.HostNetwork = true
+	// the services of the cluster are still resolved
+	spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	for i := range spec.Containers {
+		for j := range spec.Containers[i].Ports {
+			spec.Containers[i].Ports[j].HostPort = spec.Containers[i].Ports[j].ContainerPort
+		}
+	}
+}
+
+// setDNS sets the DNS policy and config of the spec, if any,
+// the agent pods resolve the agent service and the nodes with them.
+func setDNS(ds *appsv1.DaemonSet, nodeObs *v1alpha2.NodeObservability) {
+	spec := &ds.Spec.Template.Spec
+	if nodeObs.Spec.DNSPolicy != "" {
+		spec.DNSPolicy = nodeObs.Spec.DNSPolicy
+	}
+	spec.DNSConfig = nodeObs.Spec.DNSConfig
+}
+
+// memoryBackedHostPaths are the directories of the nodes on a memory backed filesystem
+var memoryBackedHostPaths = []string{"/run", "/var/run", "/tmp", "/dev/shm"}
+
+// setProfilesVolume mounts the profiles host path of the spec, if any,
+// on the directory in which the agent writes the profiles.
+func setProfilesVolume(ds *appsv1.DaemonSet, nodeObs *v1alpha2.NodeObservability) {
+	if nodeObs.Spec.ProfilesHostPath == "" {
+		return
+	}
+	dirType := corev1.HostPathDirectoryOrCreate
+	podSpec := &ds.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: profilesName,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: nodeObs.Spec.ProfilesHostPath,
+				Type: &dirType,
+			},
+		},
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      profilesName,
+		MountPath: profilesMountPath,
+	})
+}
+
+// setEtcdCertsVolume mounts the etcd client certificates of the master nodes with the etcd type,
+// the agent presents them to etcd to retrieve its profiles.
+func setEtcdCertsVolume(ds *appsv1.DaemonSet, nodeObs *v1alpha2.NodeObservability) {
+	if nodeObs.Spec.Type != v1alpha2.EtcdNodeObservabilityType {
+		return
+	}
+	dirType := corev1.HostPathDirectory
+	podSpec := &ds.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: etcdCertsName,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: etcdCertsHostPath,
+				Type: &dirType,
+			},
+		},
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      etcdCertsName,
+		MountPath: etcdCertsMountPath,
+		ReadOnly:  true,
+	})
+}
+
+// dropVolume removes the named volume and its mount from the daemonset,
+// returns true if it was mounted.
+func dropVolume(ds *appsv1.DaemonSet, name string) bool {
+	dropped := false
+	podSpec := &ds.Spec.Template.Spec
+	volumes := make([]corev1.Volume, 0, len(podSpec.Volumes))
+	for _, vol := range podSpec.Volumes {
+		if vol.Name == name {
+			dropped = true
+			continue
+		}
+		volumes = append(volumes, vol)
+	}
+	podSpec.Volumes = volumes
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name != podName {
+			continue
+		}
+		mounts := make([]corev1.VolumeMount, 0, len(podSpec.Containers[i].VolumeMounts))
+		for _, mount := range podSpec.Containers[i].VolumeMounts {
+			if mount.Name == name {
+				dropped = true
+				continue
+			}
+			mounts = append(mounts, mount)
+		}
+		podSpec.Containers[i].VolumeMounts = mounts
+	}
+	return dropped
+}
+
+// hasVolume returns true if the pods of the daemonset have the named volume.
+func hasVolume(ds *appsv1.DaemonSet, name string) bool {
+	for _, vol := range ds.Spec.Template.Spec.Volumes {
+		if vol.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryBackedHostPath returns true if the given host path is
+// on a filesystem backed by the memory of the nodes.
+func memoryBackedHostPath(hostPath string) bool {
+	for _, dir := range memoryBackedHostPaths {
+		if hostPath == dir || strings.HasPrefix(hostPath, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// warnProfilesHostPath records a warning event if the profiles host path of the spec
+// is on a memory backed filesystem, the profiles then take the memory of the nodes.
+func (r *NodeObservabilityReconciler) warnProfilesHostPath(nodeObs *v1alpha2.NodeObservability) {
+	if hostPath := nodeObs.Spec.ProfilesHostPath; hostPath != "" && memoryBackedHostPath(hostPath) {
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeWarning, eventReasonProfilesHostPathMemoryBacked,
+			"Profiles host path %s is on a memory backed filesystem, the profiles take the memory of the nodes", hostPath)
+	}
+}
+
+// podAnnotations returns the annotations of the agent pods with the key listing them as managed,
+// the annotations of the spec in the domain reserved for the operator are left out.
+// Returns nil without annotations not to restart the agents otherwise.
+func podAnnotations(nodeObs *v1alpha2.NodeObservability) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range nodeObs.Spec.PodAnnotations {
+		if v1alpha2.IsReservedAnnotation(k) {
+			continue
+		}
+		annotations[k] = v
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return withManagedAnnotations(annotations)
+}
+
+// setPodAnnotations sets the given annotations on the pod template,
+// the annotations previously set by the operator which are no longer desired are removed,
+// the annotations added by others (e.g. by oc rollout restart) are kept untouched.
+// Returns true if the pod template changed.
+func setPodAnnotations(template *corev1.PodTemplateSpec, desired map[string]string) bool {
+	var changed bool
+	annotations := template.GetAnnotations()
+	for _, k := range append(managedAnnotations(template), managedAnnotationsKey) {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		if _, ok := annotations[k]; ok {
+			delete(annotations, k)
+			changed = true
+		}
+	}
+	for k, v := range desired {
+		if current, ok := annotations[k]; !ok || current != v {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[k] = v
+			changed = true
+		}
+	}
+	template.SetAnnotations(annotations)
+	return changed
+}
+
+// crioSocketPath returns the path of the CRI-O socket on the nodes.
+func crioSocketPath(nodeObs *v1alpha2.NodeObservability) string {
+	if nodeObs.Spec.CrioSocketPath != "" {
+		return nodeObs.Spec.CrioSocketPath
+	}
+	return defaultSocketPath
+}
+
+// agentEnv returns the environment variables of the agent container,
+// the log level is only passed when set not to restart the agents otherwise.
+// The extra variables of the spec come last, the ones set by the operator are never overridden.
+func agentEnv(nodeObs *v1alpha2.NodeObservability) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{
+			Name: "NODE_IP",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "status.hostIP",
+				},
+			},
+		},
+	}
+	if nodeObs.Spec.LogLevel != "" {
+		env = append(env, corev1.EnvVar{Name: agentLogLevelEnvName, Value: string(nodeObs.Spec.LogLevel)})
+	}
+	if nodeObs.Spec.Type == v1alpha2.EtcdNodeObservabilityType {
+		env = append(env, corev1.EnvVar{Name: etcdCertsDirEnvName, Value: etcdCertsMountPath})
+	}
+	reserved := map[string]struct{}{agentLogLevelEnvName: {}, etcdCertsDirEnvName: {}}
+	for _, e := range env {
+		reserved[e.Name] = struct{}{}
+	}
+	for _, e := range nodeObs.Spec.Env {
+		if _, found := reserved[e.Name]; found {
+			continue
+		}
+		env = append(env, e)
+	}
+	return env
+}
+
+// agentProbe returns the HTTPS probe of the agent health endpoint on the given port,
+// the settings which are not tuned in the spec fall back to the given defaults.
+func agentProbe(spec *v1alpha2.AgentProbe, defaults v1alpha2.AgentProbe, port int32) *corev1.Probe {
+	settings := defaults
+	if spec != nil {
+		if spec.InitialDelaySeconds != nil {
+			settings.InitialDelaySeconds = spec.InitialDelaySeconds
+		}
+		if spec.TimeoutSeconds != nil {
+			settings.TimeoutSeconds = spec.TimeoutSeconds
+		}
+		if spec.PeriodSeconds != nil {
+			settings.PeriodSeconds = spec.PeriodSeconds
+		}
+		if spec.FailureThreshold != nil {
+			settings.FailureThreshold = spec.FailureThreshold
+		}
+	}
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   healthzPath,
+				Port:   intstr.FromInt(int(port)),
+				Scheme: corev1.URISchemeHTTPS,
+			},
+		},
+		InitialDelaySeconds: *settings.InitialDelaySeconds,
+		TimeoutSeconds:      *settings.TimeoutSeconds,
+		PeriodSeconds:       *settings.PeriodSeconds,
+		SuccessThreshold:    1,
+		FailureThreshold:    *settings.FailureThreshold,
+	}
+}
+
+// validateResources checks that none of the limits is lower than the corresponding request.
+func validateResources(resources corev1.ResourceRequirements) error {
+	names := make([]string, 0, len(resources.Requests))
+	for name := range resources.Requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request := resources.Requests[corev1.ResourceName(name)]
+		limit, ok := resources.Limits[corev1.ResourceName(name)]
+		if ok && limit.Cmp(request) < 0 {
+			return fmt.Errorf("%s limit %s is lower than the request %s", name, limit.String(), request.String())
+		}
+	}
+	return nil
+}
+
+// agentTerminationGracePeriod returns the grace period of the agent pods in seconds,
+// falls back to the default one if not set in the spec.
+func agentTerminationGracePeriod(nodeObs *v1alpha2.NodeObservability) int64 {
+	if nodeObs.Spec.TerminationGracePeriodSeconds != nil {
+		return *nodeObs.Spec.TerminationGracePeriodSeconds
+	}
+	return defaultTerminationGracePeriod
+}
+
+// agentUpdateStrategy returns the update strategy of the agent daemonset,
+// falls back to the default rolling update if not set in the spec.
+// The unset parameters of the rolling update are defaulted like the API server does,
+// except maxUnavailable which defaults to 0 when a non-zero maxSurge is set.
+func agentUpdateStrategy(nodeObs *v1alpha2.NodeObservability) *appsv1.DaemonSetUpdateStrategy {
+	strategy := &appsv1.DaemonSetUpdateStrategy{}
+	if nodeObs.Spec.UpdateStrategy != nil {
+		strategy = nodeObs.Spec.UpdateStrategy.DeepCopy()
+	}
+	if strategy.Type == "" {
+		strategy.Type = appsv1.RollingUpdateDaemonSetStrategyType
+	}
+	if strategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return strategy
+	}
+	if strategy.RollingUpdate == nil {
+		strategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{}
+	}
+	if strategy.RollingUpdate.MaxSurge == nil {
+		maxSurge := intstr.FromInt(0)
+		strategy.RollingUpdate.MaxSurge = &maxSurge
+	}
+	if strategy.RollingUpdate.MaxUnavailable == nil {
+		maxUnavailable := intstr.FromInt(1)
+		if !isZeroIntOrPercent(*strategy.RollingUpdate.MaxSurge) {
+			maxUnavailable = intstr.FromInt(0)
+		}
+		strategy.RollingUpdate.MaxUnavailable = &maxUnavailable
+	}
+	return strategy
+}
+
+// validateUpdateStrategy checks that the update strategy of the agent daemonset
+// is accepted by the API server, the parameters of the rolling update must be set.
+func validateUpdateStrategy(strategy *appsv1.DaemonSetUpdateStrategy) error {
+	switch strategy.Type {
+	case appsv1.OnDeleteDaemonSetStrategyType:
+		if strategy.RollingUpdate != nil {
+			return fmt.Errorf("rollingUpdate cannot be set with the %s update strategy", strategy.Type)
+		}
+		return nil
+	case appsv1.RollingUpdateDaemonSetStrategyType:
+	default:
+		return fmt.Errorf("unsupported update strategy %q", strategy.Type)
+	}
+
+	maxUnavailable, maxSurge := *strategy.RollingUpdate.MaxUnavailable, *strategy.RollingUpdate.MaxSurge
+	if err := validateIntOrPercent("maxUnavailable", maxUnavailable); err != nil {
+		return err
+	}
+	if err := validateIntOrPercent("maxSurge", maxSurge); err != nil {
+		return err
+	}
+	if isZeroIntOrPercent(maxUnavailable) && isZeroIntOrPercent(maxSurge) {
+		return fmt.Errorf("maxUnavailable and maxSurge cannot both be 0")
+	}
+	if !isZeroIntOrPercent(maxUnavailable) && !isZeroIntOrPercent(maxSurge) {
+		return fmt.Errorf("maxSurge must be 0 when maxUnavailable is not 0")
+	}
+	return nil
+}
+
+// validateIntOrPercent checks that the given value is either
+// a non-negative integer or a percentage between 0% and 100%.
+func validateIntOrPercent(name string, value intstr.IntOrString) error {
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return fmt.Errorf("%s %d cannot be negative", name, value.IntVal)
+		}
+		return nil
+	}
+	if !percentRegexp.MatchString(value.StrVal) {
+		return fmt.Errorf("%s %q must be an integer or a percentage", name, value.StrVal)
+	}
+	if percent, _ := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%")); percent > 100 {
+		return fmt.Errorf("%s %q cannot be greater than 100%%", name, value.StrVal)
+	}
+	return nil
+}
+
+// isZeroIntOrPercent returns true if the given value is 0 or 0%.
+func isZeroIntOrPercent(value intstr.IntOrString) bool {
+	if value.Type == intstr.Int {
+		return value.IntVal == 0
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	return err == nil && percent == 0
+}
+
+// agentAffinity returns the affinity of the agent pods, restricted to the nodes
+// of the requested node pools: each pool selector is ANDed with every required
+// node selector term of the spec, the resulting terms are ORed.
+// The nodes labeled as excluded are ruled out by every term.
+// With the etcd type, only the master nodes, which run etcd and hold its certificates, are kept.
+func agentAffinity(nodeObs *v1alpha2.NodeObservability) *corev1.Affinity {
+	affinity := &corev1.Affinity{}
+	if nodeObs.Spec.Affinity != nil {
+		affinity = nodeObs.Spec.Affinity.DeepCopy()
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+
+	userTerms := []corev1.NodeSelectorTerm{{}}
+	if affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil &&
+		len(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) != 0 {
+		userTerms = affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	}
+
+	excluded := corev1.NodeSelectorRequirement{
+		Key:      v1alpha2.ExcludeNodeLabel,
+		Operator: corev1.NodeSelectorOpNotIn,
+		Values:   []string{"true"},
+	}
+	// without node pools all the nodes matching the spec are profiled
+	pools := nodeObs.Spec.NodePools
+	if len(pools) == 0 {
+		pools = []v1alpha2.NodePool{{}}
+	}
+
+	// the master nodes are labeled with either of the roles depending on the cluster version
+	roleReqs := [][]corev1.NodeSelectorRequirement{nil}
+	if nodeObs.Spec.Type == v1alpha2.EtcdNodeObservabilityType {
+		roleReqs = nil
+		for _, role := range []string{machineconfigcontroller.MasterNodeRoleLabelName, machineconfigcontroller.ControlPlaneNodeRoleLabelName} {
+			roleReqs = append(roleReqs, []corev1.NodeSelectorRequirement{{Key: role, Operator: corev1.NodeSelectorOpExists}})
+		}
+	}
+
+	terms := []corev1.NodeSelectorTerm{}
+	for _, pool := range pools {
+		keys := make([]string, 0, len(pool.NodeSelector))
+		for key := range pool.NodeSelector {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		poolReqs := make([]corev1.NodeSelectorRequirement, 0, len(keys))
+		for _, key := range keys {
+			poolReqs = append(poolReqs, corev1.NodeSelectorRequirement{
+				Key:      key,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{pool.NodeSelector[key]},
+			})
+		}
+		for _, userTerm := range userTerms {
+			for _, roleReq := range roleReqs {
+				term := *userTerm.DeepCopy()
+				term.MatchExpressions = append(term.MatchExpressions, poolReqs...)
+				term.MatchExpressions = append(term.MatchExpressions, roleReq...)
+				term.MatchExpressions = append(term.MatchExpressions, excluded)
+				terms = append(terms, term)
+			}
+		}
+	}
+	affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{NodeSelectorTerms: terms}
+	return affinity
+}
+
+// agentTolerations returns the tolerations of the agent pods,
+// the taints of the master nodes are tolerated if their profiling is included.
+func agentTolerations(nodeObs *v1alpha2.NodeObservability) []corev1.Toleration {
+	if !nodeObs.MasterNodesIncluded() {
+		return nodeObs.Spec.Tolerations
+	}
+	tolerations := append([]corev1.Toleration{}, nodeObs.Spec.Tolerations...)
+	for _, key := range []string{machineconfigcontroller.MasterNodeRoleLabelName, machineconfigcontroller.ControlPlaneNodeRoleLabelName} {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      key,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+	return tolerations
+}
+
+// agentPodSecurityContext returns the security context of the agent pods,
+// the RuntimeDefault seccomp profile is used if no seccomp profile is set in the spec.
+func agentPodSecurityContext(nodeObs *v1alpha2.NodeObservability) *corev1.PodSecurityContext {
+	sc := &corev1.PodSecurityContext{}
+	if nodeObs.Spec.PodSecurityContext != nil {
+		sc = nodeObs.Spec.PodSecurityContext.DeepCopy()
+	}
+	if sc.SeccompProfile == nil {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	return sc
+}
+
+// agentSecurityContext returns the security context of the agent container,
+// the container is privileged if the privileged mode is not set in the spec.
+func agentSecurityContext(nodeObs *v1alpha2.NodeObservability) *corev1.SecurityContext {
+	sc := &corev1.SecurityContext{}
+	if nodeObs.Spec.SecurityContext != nil {
+		sc = nodeObs.Spec.SecurityContext.DeepCopy()
+	}
+	if sc.Privileged == nil {
+		sc.Privileged = pointer.Bool(true)
+	}
+	return sc
+}
+
+// validateSecurityContext checks that the agent container keeps
+// the privileged mode required by the CRI-O profiling.
+func validateSecurityContext(nodeObs *v1alpha2.NodeObservability) error {
+	if nodeObs.Spec.Type == v1alpha2.KubeletNodeObservabilityType {
+		return nil
+	}
+	if privileged := agentSecurityContext(nodeObs).Privileged; !*privileged {
+		return fmt.Errorf("the agent container must be privileged to profile CRI-O or to read the etcd certificates, only the %s type can run unprivileged", v1alpha2.KubeletNodeObservabilityType)
+	}
+	return nil
+}
+
+// agentImage returns the image of the agent container,
+// falls back to the operator's default one if not set in the spec.
+func (r *NodeObservabilityReconciler) agentImage(nodeObs *v1alpha2.NodeObservability) string {
+	if nodeObs.Spec.AgentImage != "" {
+		return nodeObs.Spec.AgentImage
+	}
+	return r.AgentImage
+}
+
+// agentImagePullPolicy returns the pull policy of the agent image,
+// falls back to the default one if not set in the spec.
+func agentImagePullPolicy(nodeObs *v1alpha2.NodeObservability) corev1.PullPolicy {
+	if nodeObs.Spec.ImagePullPolicy != "" {
+		return nodeObs.Spec.ImagePullPolicy
+	}
+	return defaultImagePullPolicy
+}
+
+// validateImage checks that the given image is a valid image reference.
+func validateImage(image string) error {
+	if !imageReferenceRegexp.MatchString(image) {
+		return fmt.Errorf("%q is not a valid image reference", image)
+	}
+	return nil
+}
+
 // purgeObsoleteDaemonset deletes the obsolete version of daemonset if present.
 func (r *NodeObservabilityReconciler) purgeObsoleteDaemonset(ctx context.Context, name types.NamespacedName) error {
 	ds := &appsv1.DaemonSet{
@@ -259,7 +972,7 @@ func (r *NodeObservabilityReconciler) purgeObsoleteDaemonset(ctx context.Context
 			Namespace: name.Namespace,
 		},
 	}
-	if err := r.Client.Delete(context.TODO(), ds); err != nil && !errors.IsNotFound(err) {
+	if err := r.Client.Delete(ctx, ds); err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 	return nil
@@ -23,12 +23,16 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -47,7 +51,18 @@ func TestEnsureDaemonset(t *testing.T) {
 		name            string
 		existingObjects []runtime.Object
 		serviceaccount  *corev1.ServiceAccount
+		secretName      string
+		resources       corev1.ResourceRequirements
+		agentImage      string
+		pullPolicy      corev1.PullPolicy
+		readinessProbe  *operatorv1alpha2.AgentProbe
+		livenessProbe   *operatorv1alpha2.AgentProbe
+		updateStrategy  *appsv1.DaemonSetUpdateStrategy
+		profilingType   operatorv1alpha2.NodeObservabilityType
+		podSecurity     *corev1.PodSecurityContext
+		securityContext *corev1.SecurityContext
 		expectedDS      *appsv1.DaemonSet
+		errExpected     bool
 	}{
 		{
 			name: "New daemonset",
@@ -56,6 +71,7 @@ func TestEnsureDaemonset(t *testing.T) {
 			},
 			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
 				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
 				withControllerReference(nodeObsInstanceName).
 				withContainers(
 					testContainer(podName, "node-observability-agent:latest").
@@ -69,24 +85,349 @@ func TestEnsureDaemonset(t *testing.T) {
 						withPrivileged().
 						withVolumeMount(socketName, socketMountPath, false).
 						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
 						build(),
 					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
 						withArgs(
 							"--secure-listen-address=0.0.0.0:8443",
 							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
 							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
 							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
 							"--logtostderr=true",
 							"--v=2",
 						).
+						withPort("https", port).
 						withVolumeMount(certsName, certsMountPath, true).
 						build(),
 				).
-				withHostPathVolume(socketName, socketPath, corev1.HostPathSocket).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
 				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
 				withSecretVolume(certsName, secretName).
 				build(),
 		},
+		{
+			name:          "New daemonset with custom security contexts",
+			profilingType: operatorv1alpha2.KubeletNodeObservabilityType,
+			podSecurity:   &corev1.PodSecurityContext{RunAsUser: pointer.Int64(0)},
+			securityContext: &corev1.SecurityContext{
+				Privileged:     pointer.Bool(false),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: pointer.String("agent.json")},
+			},
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withPodSecurityContext(&corev1.PodSecurityContext{
+					RunAsUser:      pointer.Int64(0),
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+				}).
+				withContainers(
+					testContainer(podName, "node-observability-agent:latest").
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withSecurityContext(&corev1.SecurityContext{
+							Privileged:     pointer.Bool(false),
+							SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: pointer.String("agent.json")},
+						}).
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, secretName).
+				build(),
+		},
+		{
+			name:            "New daemonset with unprivileged agent profiling CRI-O",
+			securityContext: &corev1.SecurityContext{Privileged: pointer.Bool(false)},
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			errExpected: true,
+		},
+		{
+			name:           "New daemonset with custom update strategy",
+			updateStrategy: testRollingUpdate(intstr.FromString("10%"), intstr.FromInt(0)),
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withUpdateStrategy(testRollingUpdate(intstr.FromString("10%"), intstr.FromInt(0))).
+				withContainers(
+					testContainer(podName, "node-observability-agent:latest").
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withPrivileged().
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, secretName).
+				build(),
+		},
+		{
+			name:           "New daemonset with invalid update strategy",
+			updateStrategy: testRollingUpdate(intstr.FromString("10"), intstr.FromInt(0)),
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			errExpected: true,
+		},
+		{
+			name: "New daemonset with tuned probes",
+			readinessProbe: &operatorv1alpha2.AgentProbe{
+				TimeoutSeconds:   pointer.Int32(10),
+				FailureThreshold: pointer.Int32(6),
+			},
+			livenessProbe: &operatorv1alpha2.AgentProbe{
+				InitialDelaySeconds: pointer.Int32(60),
+				PeriodSeconds:       pointer.Int32(30),
+			},
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withContainers(
+					testContainer(podName, "node-observability-agent:latest").
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withPrivileged().
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 10, 10, 6), testAgentProbe(60, 5, 30, 3)).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, secretName).
+				build(),
+		},
+		{
+			name:       "New daemonset with custom serving cert secret",
+			secretName: "custom-serving-cert",
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withContainers(
+					testContainer(podName, "node-observability-agent:latest").
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withPrivileged().
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, "custom-serving-cert").
+				build(),
+		},
+		{
+			name: "New daemonset with agent resources",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withContainers(
+					testContainer(podName, "node-observability-agent:latest").
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withPrivileged().
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+						withResources(corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+							Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+						}).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, secretName).
+				build(),
+		},
+		{
+			name: "New daemonset with limits lower than requests",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			},
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			errExpected: true,
+		},
+		{
+			name:       "New daemonset with custom agent image",
+			agentImage: "mirror.example.com:5000/node-observability/agent:v1",
+			pullPolicy: corev1.PullAlways,
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
+				withControllerReference(nodeObsInstanceName).
+				withContainers(
+					testContainer(podName, "mirror.example.com:5000/node-observability/agent:v1").
+						withImagePullPolicy(corev1.PullAlways).
+						withFieldEnv("NODE_IP", "status.hostIP").
+						withCommand("node-observability-agent").
+						withArgs(
+							"--tokenFile=/var/run/secrets/kubernetes.io/serviceaccount/token",
+							"--storage=/run/node-observability",
+							fmt.Sprintf("--caCertFile=%s%s", kbltCAMountPath, kbltCAMountedFile),
+						).
+						withPrivileged().
+						withVolumeMount(socketName, socketMountPath, false).
+						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+						build(),
+					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
+						withArgs(
+							"--secure-listen-address=0.0.0.0:8443",
+							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
+							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
+							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
+							"--logtostderr=true",
+							"--v=2",
+						).
+						withPort("https", port).
+						withVolumeMount(certsName, certsMountPath, true).
+						build(),
+				).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
+				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
+				withSecretVolume(certsName, secretName).
+				build(),
+		},
+		{
+			name:       "New daemonset with invalid agent image",
+			agentImage: "Invalid Image",
+			existingObjects: []runtime.Object{
+				makeKubeletCACM(),
+			},
+			errExpected: true,
+		},
 		{
 			name: "Update existing daemonset",
 			existingObjects: []runtime.Object{
@@ -109,13 +450,14 @@ func TestEnsureDaemonset(t *testing.T) {
 							withVolumeMount(kbltCAName, kbltCAMountPath, true).
 							build(),
 					).
-					withHostPathVolume(socketName, socketPath, corev1.HostPathSocket).
+					withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
 					withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
 					withSecretVolume(certsName, secretName).
 					build(),
 			},
 			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
 				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
 				withControllerReference(nodeObsInstanceName).
 				withResourceVersion("2").
 				withContainers(
@@ -130,20 +472,23 @@ func TestEnsureDaemonset(t *testing.T) {
 						withPrivileged().
 						withVolumeMount(socketName, socketMountPath, false).
 						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
 						build(),
 					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
 						withArgs(
 							"--secure-listen-address=0.0.0.0:8443",
 							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
 							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
 							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
 							"--logtostderr=true",
 							"--v=2",
 						).
+						withPort("https", port).
 						withVolumeMount(certsName, certsMountPath, true).
 						build(),
 				).
-				withHostPathVolume(socketName, socketPath, corev1.HostPathSocket).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
 				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
 				withSecretVolume(certsName, secretName).
 				build(),
@@ -156,6 +501,7 @@ func TestEnsureDaemonset(t *testing.T) {
 			},
 			expectedDS: testDaemonset(daemonSetName, test.TestNamespace, serviceAccountName).
 				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withAffinity(testExcludedNodesAffinity()).
 				withControllerReference(nodeObsInstanceName).
 				withResourceVersion("1").
 				withContainers(
@@ -170,20 +516,23 @@ func TestEnsureDaemonset(t *testing.T) {
 						withPrivileged().
 						withVolumeMount(socketName, socketMountPath, false).
 						withVolumeMount(kbltCAName, kbltCAMountPath, true).
+						withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
 						build(),
 					testContainer("kube-rbac-proxy", "gcr.io/kubebuilder/kube-rbac-proxy:v0.11.0").
 						withArgs(
 							"--secure-listen-address=0.0.0.0:8443",
 							"--upstream=http://127.0.0.1:9000/",
+							"--ignore-paths=/healthz",
 							fmt.Sprintf("--tls-cert-file=%s/tls.crt", certsMountPath),
 							fmt.Sprintf("--tls-private-key-file=%s/tls.key", certsMountPath),
 							"--logtostderr=true",
 							"--v=2",
 						).
+						withPort("https", port).
 						withVolumeMount(certsName, certsMountPath, true).
 						build(),
 				).
-				withHostPathVolume(socketName, socketPath, corev1.HostPathSocket).
+				withHostPathVolume(socketName, defaultSocketPath, corev1.HostPathSocket).
 				withConfigMapVolume(kbltCAName, kubeletCAConfigMapName).
 				withSecretVolume(certsName, secretName).
 				build(),
@@ -193,11 +542,12 @@ func TestEnsureDaemonset(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			cl := fake.NewClientBuilder().WithRuntimeObjects(tc.existingObjects...).Build()
 			r := &NodeObservabilityReconciler{
-				Client:     cl,
-				Scheme:     test.Scheme,
-				Namespace:  test.TestNamespace,
-				Log:        zap.New(zap.UseDevMode(true)),
-				AgentImage: "node-observability-agent:latest",
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+				AgentImage:    "node-observability-agent:latest",
 			}
 			nodeObs := &operatorv1alpha2.NodeObservability{
 				ObjectMeta: metav1.ObjectMeta{Name: nodeObsInstanceName},
@@ -205,6 +555,16 @@ func TestEnsureDaemonset(t *testing.T) {
 					NodeSelector: map[string]string{
 						"node-role.kubernetes.io/worker": "",
 					},
+					ServingCertSecretName: tc.secretName,
+					Resources:             tc.resources,
+					AgentImage:            tc.agentImage,
+					ImagePullPolicy:       tc.pullPolicy,
+					ReadinessProbe:        tc.readinessProbe,
+					LivenessProbe:         tc.livenessProbe,
+					UpdateStrategy:        tc.updateStrategy,
+					Type:                  tc.profilingType,
+					PodSecurityContext:    tc.podSecurity,
+					SecurityContext:       tc.securityContext,
 				},
 			}
 			sa := &corev1.ServiceAccount{
@@ -221,7 +581,13 @@ func TestEnsureDaemonset(t *testing.T) {
 			}
 			_, err := r.ensureDaemonSet(context.TODO(), nodeObs, sa, r.Namespace, tempCM)
 			if err != nil {
-				t.Fatalf("unexpected error received: %v", err)
+				if !tc.errExpected {
+					t.Fatalf("unexpected error received: %v", err)
+				}
+				return
+			}
+			if tc.errExpected {
+				t.Fatalf("error expected but not received")
 			}
 
 			ds := &appsv1.DaemonSet{}
@@ -395,6 +761,25 @@ func TestUpdateDaemonSet(t *testing.T) {
 				build(),
 			expectUpdate: false,
 		},
+		{
+			name: "container port changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("kube-rbac-proxy", "proxy:v1").
+					withPort("https", 8443).
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("kube-rbac-proxy", "proxy:v1").
+					withPort("https", 9443).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("kube-rbac-proxy", "proxy:v1").
+					withPort("https", 9443).
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
 		{
 			name: "security context is modified",
 			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
@@ -414,6 +799,291 @@ func TestUpdateDaemonSet(t *testing.T) {
 				).build(),
 			expectUpdate: true,
 		},
+		{
+			name: "node selector changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/worker": ""}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/infra": ""}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withNodeSelector(map[string]string{"node-role.kubernetes.io/infra": ""}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "affinity added",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withAffinity(testNodeAffinity("zone", "a")).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withAffinity(testNodeAffinity("zone", "a")).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "tolerations changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withTolerations(corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withTolerations(corev1.Toleration{Operator: corev1.TolerationOpExists}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withTolerations(corev1.Toleration{Operator: corev1.TolerationOpExists}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "tolerations removed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withTolerations(corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "service account changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "old-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "priority class changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withPriorityClassName(NodeCriticalPriorityClassName).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withPriorityClassName(NodeCriticalPriorityClassName).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "image pull secrets changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withImagePullSecrets("old-pull-secret").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withImagePullSecrets("registry-pull-secret").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withImagePullSecrets("registry-pull-secret").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "image pull policy changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withImagePullPolicy(corev1.PullAlways).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withImagePullPolicy(corev1.PullAlways).
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "probes changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "probe settings changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withProbes(testAgentProbe(5, 5, 10, 3), testAgentProbe(15, 5, 20, 3)).
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withProbes(testAgentProbe(5, 10, 10, 3), testAgentProbe(15, 5, 20, 6)).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withProbes(testAgentProbe(5, 10, 10, 3), testAgentProbe(15, 5, 20, 6)).
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "resources changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withResources(corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					}).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withResources(corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					}).
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "resources are equivalent",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withResources(corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					}).
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withResources(corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1000m")},
+					}).
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					withResources(corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					}).
+					build(),
+				).build(),
+			expectUpdate: false,
+		},
+		{
+			name: "update strategy changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withUpdateStrategy(testRollingUpdate(intstr.FromString("10%"), intstr.FromInt(0))).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withUpdateStrategy(testRollingUpdate(intstr.FromString("10%"), intstr.FromInt(0))).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "update strategy edited manually",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withUpdateStrategy(&appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
+		{
+			name: "pod security context changed",
+			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			desiredDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withPodSecurityContext(&corev1.PodSecurityContext{
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+				}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectedDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
+				withPodSecurityContext(&corev1.PodSecurityContext{
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+				}).
+				withContainers(testContainer("agent", "agent:v1").
+					build(),
+				).build(),
+			expectUpdate: true,
+		},
 		{
 			name: "daemonset is the same",
 			existingDaemonset: testDaemonset("daemonset", "test-namespace", "test-sa").
@@ -470,42 +1140,257 @@ func TestUpdateDaemonSet(t *testing.T) {
 	}
 }
 
-func TestHasSecurityContextChanged(t *testing.T) {
+func TestValidateResources(t *testing.T) {
 	for _, tc := range []struct {
-		name      string
-		currentSC *corev1.SecurityContext
-		desiredSC *corev1.SecurityContext
-		changed   bool
+		name        string
+		resources   corev1.ResourceRequirements
+		errExpected bool
 	}{
 		{
-			name:      "current Privileged is nil",
-			currentSC: &corev1.SecurityContext{},
-			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
-			changed:   true,
+			name: "no resources",
 		},
 		{
-			// should be ignored to handle defaulting
-			name:      "desired Privileged is nil",
-			desiredSC: &corev1.SecurityContext{},
-			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
-			changed:   false,
+			name: "requests only",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			},
 		},
 		{
-			name:      "Privileged changes true->false",
-			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
-			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
-			changed:   true,
+			name: "limits equal to requests",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1000m")},
+			},
 		},
 		{
-			name:      "Privileged changes false->true",
-			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
-			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
-			changed:   true,
+			name: "memory limit lower than request",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("200m"),
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+				},
+			},
+			errExpected: true,
 		},
-		{
-			name:      "Privileged is same",
-			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
-			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateResources(tc.resources)
+			if tc.errExpected && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAgentUpdateStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy *appsv1.DaemonSetUpdateStrategy
+		expected *appsv1.DaemonSetUpdateStrategy
+	}{
+		{
+			name:     "defaults to rolling update",
+			expected: testRollingUpdate(intstr.FromInt(1), intstr.FromInt(0)),
+		},
+		{
+			name:     "rolling update without parameters",
+			strategy: &appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType},
+			expected: testRollingUpdate(intstr.FromInt(1), intstr.FromInt(0)),
+		},
+		{
+			name: "max unavailable only",
+			strategy: &appsv1.DaemonSetUpdateStrategy{
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: func() *intstr.IntOrString { v := intstr.FromString("25%"); return &v }()},
+			},
+			expected: testRollingUpdate(intstr.FromString("25%"), intstr.FromInt(0)),
+		},
+		{
+			name: "max surge only",
+			strategy: &appsv1.DaemonSetUpdateStrategy{
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxSurge: func() *intstr.IntOrString { v := intstr.FromInt(2); return &v }()},
+			},
+			expected: testRollingUpdate(intstr.FromInt(0), intstr.FromInt(2)),
+		},
+		{
+			name:     "on delete",
+			strategy: &appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType},
+			expected: &appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				Spec: operatorv1alpha2.NodeObservabilitySpec{UpdateStrategy: tc.strategy},
+			}
+			if diff := cmp.Diff(tc.expected, agentUpdateStrategy(nodeObs)); diff != "" {
+				t.Errorf("unexpected update strategy:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		strategy    *appsv1.DaemonSetUpdateStrategy
+		errExpected bool
+	}{
+		{
+			name:     "integer max unavailable",
+			strategy: testRollingUpdate(intstr.FromInt(5), intstr.FromInt(0)),
+		},
+		{
+			name:     "percentage max unavailable",
+			strategy: testRollingUpdate(intstr.FromString("10%"), intstr.FromString("0%")),
+		},
+		{
+			name:     "percentage max surge",
+			strategy: testRollingUpdate(intstr.FromInt(0), intstr.FromString("100%")),
+		},
+		{
+			name:     "on delete",
+			strategy: &appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType},
+		},
+		{
+			name:        "negative max unavailable",
+			strategy:    testRollingUpdate(intstr.FromInt(-1), intstr.FromInt(0)),
+			errExpected: true,
+		},
+		{
+			name:        "percentage without sign",
+			strategy:    testRollingUpdate(intstr.FromString("10"), intstr.FromInt(0)),
+			errExpected: true,
+		},
+		{
+			name:        "percentage above 100%",
+			strategy:    testRollingUpdate(intstr.FromInt(0), intstr.FromString("150%")),
+			errExpected: true,
+		},
+		{
+			name:        "both zero",
+			strategy:    testRollingUpdate(intstr.FromString("0%"), intstr.FromInt(0)),
+			errExpected: true,
+		},
+		{
+			name:        "both non-zero",
+			strategy:    testRollingUpdate(intstr.FromInt(1), intstr.FromString("10%")),
+			errExpected: true,
+		},
+		{
+			name: "on delete with rolling update",
+			strategy: &appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.OnDeleteDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{},
+			},
+			errExpected: true,
+		},
+		{
+			name:        "unsupported type",
+			strategy:    &appsv1.DaemonSetUpdateStrategy{Type: "Recreate"},
+			errExpected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateUpdateStrategy(tc.strategy)
+			if tc.errExpected && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	for _, tc := range []struct {
+		image       string
+		errExpected bool
+	}{
+		{image: "agent"},
+		{image: "agent:latest"},
+		{image: "quay.io/node-observability-operator/node-observability-agent:latest"},
+		{image: "registry.internal:5000/mirror/agent:v1.0.0"},
+		{image: "quay.io/agent@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{image: "quay.io/agent:v1@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{image: "", errExpected: true},
+		{image: "Agent", errExpected: true},
+		{image: "quay.io/agent:", errExpected: true},
+		{image: "quay.io/agent with spaces", errExpected: true},
+		{image: "quay.io/agent@sha256:short", errExpected: true},
+	} {
+		t.Run(tc.image, func(t *testing.T) {
+			err := validateImage(tc.image)
+			if tc.errExpected && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHasSecurityContextChanged(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		currentSC *corev1.SecurityContext
+		desiredSC *corev1.SecurityContext
+		changed   bool
+	}{
+		{
+			name:      "current Privileged is nil",
+			currentSC: &corev1.SecurityContext{},
+			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
+			changed:   true,
+		},
+		{
+			// should be ignored to handle defaulting
+			name:      "desired Privileged is nil",
+			desiredSC: &corev1.SecurityContext{},
+			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
+			changed:   false,
+		},
+		{
+			name:      "Privileged changes true->false",
+			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
+			changed:   true,
+		},
+		{
+			name:      "Privileged changes false->true",
+			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(false)},
+			changed:   true,
+		},
+		{
+			name:      "seccomp profile added",
+			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+			desiredSC: &corev1.SecurityContext{
+				Privileged:     pointer.BoolPtr(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			changed: true,
+		},
+		{
+			name: "seccomp profile removed",
+			currentSC: &corev1.SecurityContext{
+				Privileged:     pointer.BoolPtr(true),
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+			changed:   true,
+		},
+		{
+			name:      "Privileged is same",
+			currentSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
+			desiredSC: &corev1.SecurityContext{Privileged: pointer.BoolPtr(true)},
 			changed:   false,
 		},
 	} {
@@ -527,6 +1412,114 @@ type testDaemonsetBuilder struct {
 	ownerReference []metav1.OwnerReference
 	volumes        []corev1.Volume
 	nodeSelector   map[string]string
+	affinity       *corev1.Affinity
+	tolerations    []corev1.Toleration
+	priorityClass  string
+	pullSecrets    []corev1.LocalObjectReference
+	updateStrategy *appsv1.DaemonSetUpdateStrategy
+	podSecurity    *corev1.PodSecurityContext
+}
+
+func TestSetDaemonSetRolledOutCondition(t *testing.T) {
+	testCases := []struct {
+		name string
+		// generation is the observed generation of the daemonset, 1 being current
+		generation int64
+		updated    int32
+		available  int32
+		// rolledOut is the initial status of the rolled out condition
+		rolledOut      metav1.ConditionStatus
+		expectedStatus metav1.ConditionStatus
+		expectedEvent  string
+	}{
+		{
+			name:           "rollout completed",
+			generation:     1,
+			updated:        3,
+			available:      3,
+			expectedStatus: metav1.ConditionTrue,
+			expectedEvent:  "Normal DaemonSetRolledOut Rolled out daemonset node-observability-operator/node-observability-agent on 3 node(s)",
+		},
+		{
+			name:           "rollout completed already",
+			generation:     1,
+			updated:        3,
+			available:      3,
+			rolledOut:      metav1.ConditionTrue,
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:           "rollout completed after progressing",
+			generation:     1,
+			updated:        3,
+			available:      3,
+			rolledOut:      metav1.ConditionFalse,
+			expectedStatus: metav1.ConditionTrue,
+			expectedEvent:  "Normal DaemonSetRolledOut Rolled out daemonset node-observability-operator/node-observability-agent on 3 node(s)",
+		},
+		{
+			name:           "pods not updated",
+			generation:     1,
+			updated:        2,
+			available:      3,
+			rolledOut:      metav1.ConditionTrue,
+			expectedStatus: metav1.ConditionFalse,
+		},
+		{
+			name:           "pods not available",
+			generation:     1,
+			updated:        3,
+			available:      2,
+			expectedStatus: metav1.ConditionFalse,
+		},
+		{
+			name:           "spec not observed",
+			updated:        3,
+			available:      3,
+			expectedStatus: metav1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(100)
+			r := &NodeObservabilityReconciler{
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeObsInstanceName},
+			}
+			if tc.rolledOut != "" {
+				nodeObs.Status.SetCondition(operatorv1alpha2.DaemonSetRolledOut, tc.rolledOut, "", "")
+			}
+			ds := &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: daemonSetName, Namespace: "node-observability-operator", Generation: 1},
+				Status: appsv1.DaemonSetStatus{
+					ObservedGeneration:     tc.generation,
+					DesiredNumberScheduled: 3,
+					UpdatedNumberScheduled: tc.updated,
+					NumberAvailable:        tc.available,
+				},
+			}
+
+			r.setDaemonSetRolledOutCondition(nodeObs, ds)
+
+			cond := nodeObs.Status.GetCondition(operatorv1alpha2.DaemonSetRolledOut)
+			if cond == nil || cond.Status != tc.expectedStatus {
+				t.Fatalf("expected rolled out condition to be %s, got %v", tc.expectedStatus, cond)
+			}
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
 }
 
 func testDaemonset(name, namespace, serviceAccount string) *testDaemonsetBuilder {
@@ -542,6 +1535,38 @@ func (b *testDaemonsetBuilder) withNodeSelector(selector map[string]string) *tes
 	return b
 }
 
+func (b *testDaemonsetBuilder) withAffinity(affinity *corev1.Affinity) *testDaemonsetBuilder {
+	b.affinity = affinity
+	return b
+}
+
+func (b *testDaemonsetBuilder) withTolerations(tolerations ...corev1.Toleration) *testDaemonsetBuilder {
+	b.tolerations = tolerations
+	return b
+}
+
+func (b *testDaemonsetBuilder) withPriorityClassName(name string) *testDaemonsetBuilder {
+	b.priorityClass = name
+	return b
+}
+
+func (b *testDaemonsetBuilder) withImagePullSecrets(names ...string) *testDaemonsetBuilder {
+	for _, name := range names {
+		b.pullSecrets = append(b.pullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+	return b
+}
+
+func (b *testDaemonsetBuilder) withUpdateStrategy(strategy *appsv1.DaemonSetUpdateStrategy) *testDaemonsetBuilder {
+	b.updateStrategy = strategy
+	return b
+}
+
+func (b *testDaemonsetBuilder) withPodSecurityContext(sc *corev1.PodSecurityContext) *testDaemonsetBuilder {
+	b.podSecurity = sc
+	return b
+}
+
 func (b *testDaemonsetBuilder) withResourceVersion(version string) *testDaemonsetBuilder {
 	b.version = version
 	return b
@@ -606,6 +1631,16 @@ func (b *testDaemonsetBuilder) withSecretVolume(name, sname string) *testDaemons
 
 func (b *testDaemonsetBuilder) build() *appsv1.DaemonSet {
 	labels := labelsForNodeObservability(nodeObsInstanceName)
+	updateStrategy := testRollingUpdate(intstr.FromInt(1), intstr.FromInt(0))
+	if b.updateStrategy != nil {
+		updateStrategy = b.updateStrategy
+	}
+	podSecurity := &corev1.PodSecurityContext{
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	if b.podSecurity != nil {
+		podSecurity = b.podSecurity
+	}
 	d := &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "DaemonSet",
@@ -620,6 +1655,7 @@ func (b *testDaemonsetBuilder) build() *appsv1.DaemonSet {
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labelsForNodeObservability(nodeObsInstanceName),
 			},
+			UpdateStrategy: *updateStrategy,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels,
@@ -632,7 +1668,12 @@ func (b *testDaemonsetBuilder) build() *appsv1.DaemonSet {
 					ServiceAccountName:            b.serviceAccount,
 					Volumes:                       b.volumes,
 					NodeSelector:                  b.nodeSelector,
-					TerminationGracePeriodSeconds: pointer.Int64(45),
+					Affinity:                      b.affinity,
+					Tolerations:                   b.tolerations,
+					PriorityClassName:             b.priorityClass,
+					ImagePullSecrets:              b.pullSecrets,
+					SecurityContext:               podSecurity,
+					TerminationGracePeriodSeconds: pointer.Int64(defaultTerminationGracePeriod),
 				},
 			},
 		},
@@ -645,6 +1686,51 @@ func (b *testDaemonsetBuilder) build() *appsv1.DaemonSet {
 	return d
 }
 
+// testRollingUpdate returns the rolling update strategy with the given parameters
+func testRollingUpdate(maxUnavailable, maxSurge intstr.IntOrString) *appsv1.DaemonSetUpdateStrategy {
+	return &appsv1.DaemonSetUpdateStrategy{
+		Type: appsv1.RollingUpdateDaemonSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+}
+
+// testExcludedNodesAffinity returns the affinity ruling out the excluded nodes
+func testExcludedNodesAffinity() *corev1.Affinity {
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: operatorv1alpha2.ExcludeNodeLabel, Operator: corev1.NodeSelectorOpNotIn, Values: []string{"true"}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// testNodeAffinity returns an affinity requiring the nodes to have the given label
+func testNodeAffinity(key, value string) *corev1.Affinity {
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: key, Operator: corev1.NodeSelectorOpIn, Values: []string{value}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 type testContainerBuilder struct {
 	name            string
 	image           string
@@ -652,16 +1738,27 @@ type testContainerBuilder struct {
 	command         []string
 	env             []corev1.EnvVar
 	volumeMounts    []corev1.VolumeMount
+	ports           []corev1.ContainerPort
 	securityContext *corev1.SecurityContext
+	resources       corev1.ResourceRequirements
+	pullPolicy      corev1.PullPolicy
+	readinessProbe  *corev1.Probe
+	livenessProbe   *corev1.Probe
 }
 
 func testContainer(name, image string) *testContainerBuilder {
 	return &testContainerBuilder{
-		name:  name,
-		image: image,
+		name:       name,
+		image:      image,
+		pullPolicy: corev1.PullIfNotPresent,
 	}
 }
 
+func (b *testContainerBuilder) withImagePullPolicy(policy corev1.PullPolicy) *testContainerBuilder {
+	b.pullPolicy = policy
+	return b
+}
+
 func (b *testContainerBuilder) withEnv(name, value string) *testContainerBuilder {
 	b.env = append(b.env, corev1.EnvVar{Name: name, Value: value})
 	return b
@@ -698,6 +1795,15 @@ func (b *testContainerBuilder) withVolumeMount(name, path string, readOnly bool)
 	return b
 }
 
+func (b *testContainerBuilder) withPort(name string, port int32) *testContainerBuilder {
+	b.ports = append(b.ports, corev1.ContainerPort{
+		Name:          name,
+		ContainerPort: port,
+		Protocol:      corev1.ProtocolTCP,
+	})
+	return b
+}
+
 func (b *testContainerBuilder) withPrivileged() *testContainerBuilder {
 	b.securityContext = &corev1.SecurityContext{
 		Privileged: pointer.Bool(true),
@@ -705,6 +1811,11 @@ func (b *testContainerBuilder) withPrivileged() *testContainerBuilder {
 	return b
 }
 
+func (b *testContainerBuilder) withSecurityContext(sc *corev1.SecurityContext) *testContainerBuilder {
+	b.securityContext = sc
+	return b
+}
+
 func (b *testContainerBuilder) withUnprivileged() *testContainerBuilder {
 	b.securityContext = &corev1.SecurityContext{
 		Privileged: pointer.Bool(false),
@@ -712,16 +1823,49 @@ func (b *testContainerBuilder) withUnprivileged() *testContainerBuilder {
 	return b
 }
 
+func (b *testContainerBuilder) withResources(resources corev1.ResourceRequirements) *testContainerBuilder {
+	b.resources = resources
+	return b
+}
+
+func (b *testContainerBuilder) withProbes(readiness, liveness *corev1.Probe) *testContainerBuilder {
+	b.readinessProbe = readiness
+	b.livenessProbe = liveness
+	return b
+}
+
 func (b *testContainerBuilder) build() corev1.Container {
 	return corev1.Container{
 		Name:            b.name,
 		Image:           b.image,
-		ImagePullPolicy: corev1.PullIfNotPresent,
+		ImagePullPolicy: b.pullPolicy,
 		Command:         b.command,
 		Args:            b.args,
 		Env:             b.env,
 		VolumeMounts:    b.volumeMounts,
+		Ports:           b.ports,
 		SecurityContext: b.securityContext,
+		Resources:       b.resources,
+		ReadinessProbe:  b.readinessProbe,
+		LivenessProbe:   b.livenessProbe,
+	}
+}
+
+// testAgentProbe returns the probe of the agent health endpoint with the given settings
+func testAgentProbe(initialDelay, timeout, period, failureThreshold int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   "/healthz",
+				Port:   intstr.FromInt(int(port)),
+				Scheme: corev1.URISchemeHTTPS,
+			},
+		},
+		InitialDelaySeconds: initialDelay,
+		TimeoutSeconds:      timeout,
+		PeriodSeconds:       period,
+		SuccessThreshold:    1,
+		FailureThreshold:    failureThreshold,
 	}
 }
 
@@ -748,3 +1892,857 @@ func makeTargetKubeletCACM() *corev1.ConfigMap {
 		},
 	}
 }
+
+func TestAgentAffinity(t *testing.T) {
+	zoneA := corev1.NodeSelectorRequirement{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}
+	zoneB := corev1.NodeSelectorRequirement{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"b"}}
+	gpuPool := corev1.NodeSelectorRequirement{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"gpu"}}
+	cpuPool := corev1.NodeSelectorRequirement{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"cpu"}}
+	excluded := corev1.NodeSelectorRequirement{Key: operatorv1alpha2.ExcludeNodeLabel, Operator: corev1.NodeSelectorOpNotIn, Values: []string{"true"}}
+	master := corev1.NodeSelectorRequirement{Key: "node-role.kubernetes.io/master", Operator: corev1.NodeSelectorOpExists}
+	controlPlane := corev1.NodeSelectorRequirement{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.NodeSelectorOpExists}
+	userAffinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA}},
+					{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB}},
+				},
+			},
+		},
+	}
+	nodePools := []operatorv1alpha2.NodePool{
+		{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}},
+		{Name: "cpu", NodeSelector: map[string]string{"pool": "cpu"}},
+	}
+
+	testCases := []struct {
+		name             string
+		profilingType    operatorv1alpha2.NodeObservabilityType
+		affinity         *corev1.Affinity
+		nodePools        []operatorv1alpha2.NodePool
+		expectedAffinity *corev1.Affinity
+	}{
+		{
+			name:             "no node pools",
+			expectedAffinity: testExcludedNodesAffinity(),
+		},
+		{
+			name:     "user affinity without node pools",
+			affinity: userAffinity,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB, excluded}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "node pools",
+			nodePools: nodePools,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{gpuPool, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{cpuPool, excluded}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "node pools with user affinity",
+			affinity:  userAffinity,
+			nodePools: nodePools,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA, gpuPool, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB, gpuPool, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA, cpuPool, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB, cpuPool, excluded}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "etcd type restricted to the master nodes",
+			profilingType: operatorv1alpha2.EtcdNodeObservabilityType,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{master, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{controlPlane, excluded}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:          "etcd type with user affinity",
+			profilingType: operatorv1alpha2.EtcdNodeObservabilityType,
+			affinity:      userAffinity,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA, master, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneA, controlPlane, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB, master, excluded}},
+							{MatchExpressions: []corev1.NodeSelectorRequirement{zoneB, controlPlane, excluded}},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				Spec: operatorv1alpha2.NodeObservabilitySpec{
+					Type:      tc.profilingType,
+					Affinity:  tc.affinity,
+					NodePools: tc.nodePools,
+				},
+			}
+			if diff := cmp.Diff(tc.expectedAffinity, agentAffinity(nodeObs)); diff != "" {
+				t.Errorf("unexpected affinity (-want +got):\n%s", diff)
+			}
+			if tc.affinity != nil && len(tc.affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions) != 1 {
+				t.Errorf("spec affinity was modified")
+			}
+		})
+	}
+}
+
+func TestAgentTolerations(t *testing.T) {
+	userToleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "infra", Effect: corev1.TaintEffectNoSchedule}
+	masterTolerations := []corev1.Toleration{
+		{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+
+	testCases := []struct {
+		name                string
+		tolerations         []corev1.Toleration
+		includeMasterNodes  bool
+		annotations         map[string]string
+		expectedTolerations []corev1.Toleration
+	}{
+		{
+			name: "no tolerations",
+		},
+		{
+			name:                "user tolerations",
+			tolerations:         []corev1.Toleration{userToleration},
+			expectedTolerations: []corev1.Toleration{userToleration},
+		},
+		{
+			name:               "master nodes included without confirmation",
+			includeMasterNodes: true,
+		},
+		{
+			name:                "master nodes included",
+			includeMasterNodes:  true,
+			annotations:         map[string]string{operatorv1alpha2.IncludeMasterNodesConfirmationAnnotation: "true"},
+			expectedTolerations: masterTolerations,
+		},
+		{
+			name:                "master nodes included with user tolerations",
+			tolerations:         []corev1.Toleration{userToleration},
+			includeMasterNodes:  true,
+			annotations:         map[string]string{operatorv1alpha2.IncludeMasterNodesConfirmationAnnotation: "true"},
+			expectedTolerations: append([]corev1.Toleration{userToleration}, masterTolerations...),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+				Spec: operatorv1alpha2.NodeObservabilitySpec{
+					Tolerations:        tc.tolerations,
+					IncludeMasterNodes: tc.includeMasterNodes,
+				},
+			}
+			if diff := cmp.Diff(tc.expectedTolerations, agentTolerations(nodeObs)); diff != "" {
+				t.Errorf("unexpected tolerations (-want +got):\n%s", diff)
+			}
+			if len(nodeObs.Spec.Tolerations) != len(tc.tolerations) {
+				t.Errorf("spec tolerations were modified")
+			}
+		})
+	}
+}
+
+func TestAgentEnv(t *testing.T) {
+	testCases := []struct {
+		name             string
+		logLevel         operatorv1alpha2.AgentLogLevel
+		extraEnv         []corev1.EnvVar
+		expectedLogLevel string
+		expectedExtra    []corev1.EnvVar
+	}{
+		{
+			name: "default log level",
+		},
+		{
+			name:             "debug log level",
+			logLevel:         operatorv1alpha2.DebugAgentLogLevel,
+			expectedLogLevel: "debug",
+		},
+		{
+			name:          "extra variables",
+			extraEnv:      []corev1.EnvVar{{Name: "EXPERIMENTAL_COLLECTORS", Value: "true"}},
+			expectedExtra: []corev1.EnvVar{{Name: "EXPERIMENTAL_COLLECTORS", Value: "true"}},
+		},
+		{
+			name:     "reserved variables not overridden",
+			logLevel: operatorv1alpha2.InfoAgentLogLevel,
+			extraEnv: []corev1.EnvVar{
+				{Name: "NODE_IP", Value: "10.0.0.1"},
+				{Name: agentLogLevelEnvName, Value: "debug"},
+				{Name: etcdCertsDirEnvName, Value: "/tmp"},
+				{Name: "EXPERIMENTAL_COLLECTORS", Value: "true"},
+			},
+			expectedLogLevel: "info",
+			expectedExtra:    []corev1.EnvVar{{Name: "EXPERIMENTAL_COLLECTORS", Value: "true"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				Spec: operatorv1alpha2.NodeObservabilitySpec{LogLevel: tc.logLevel, Env: tc.extraEnv},
+			}
+			env := agentEnv(nodeObs)
+			if env[0].Name != "NODE_IP" || env[0].ValueFrom == nil {
+				t.Errorf("expected the NODE_IP environment variable first, got %v", env)
+			}
+			var logLevel string
+			var extra []corev1.EnvVar
+			for _, e := range env[1:] {
+				switch e.Name {
+				case agentLogLevelEnvName:
+					logLevel = e.Value
+				case "NODE_IP", etcdCertsDirEnvName:
+					t.Errorf("unexpected environment variable %v", e)
+				default:
+					extra = append(extra, e)
+				}
+			}
+			if logLevel != tc.expectedLogLevel {
+				t.Errorf("expected %s %q, got %q", agentLogLevelEnvName, tc.expectedLogLevel, logLevel)
+			}
+			if diff := cmp.Diff(tc.expectedExtra, extra); diff != "" {
+				t.Errorf("unexpected extra environment variables (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEqualEnvVars(t *testing.T) {
+	secretEnv := func(secret string) corev1.EnvVar {
+		return corev1.EnvVar{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: secret}, Key: "token"},
+		}}
+	}
+	nodeIP := corev1.EnvVar{Name: "NODE_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"}}}
+	defaultedNodeIP := corev1.EnvVar{Name: "NODE_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "status.hostIP"}}}
+	testCases := []struct {
+		name     string
+		current  []corev1.EnvVar
+		expected []corev1.EnvVar
+		equal    bool
+	}{
+		{
+			name:     "different order",
+			current:  []corev1.EnvVar{secretEnv("a"), nodeIP},
+			expected: []corev1.EnvVar{nodeIP, secretEnv("a")},
+			equal:    true,
+		},
+		{
+			name:     "field reference defaulted",
+			current:  []corev1.EnvVar{defaultedNodeIP},
+			expected: []corev1.EnvVar{nodeIP},
+			equal:    true,
+		},
+		{
+			name:     "secret reference changed",
+			current:  []corev1.EnvVar{nodeIP, secretEnv("a")},
+			expected: []corev1.EnvVar{nodeIP, secretEnv("b")},
+		},
+		{
+			name:     "variable added",
+			current:  []corev1.EnvVar{nodeIP},
+			expected: []corev1.EnvVar{nodeIP, {Name: "EXPERIMENTAL_COLLECTORS", Value: "true"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if equal := equalEnvVars(tc.current, tc.expected); equal != tc.equal {
+				t.Errorf("expected %t, got %t", tc.equal, equal)
+			}
+		})
+	}
+}
+
+func TestCrioSocketPath(t *testing.T) {
+	testCases := []struct {
+		name             string
+		crioSocketPath   string
+		expectedHostPath string
+	}{
+		{
+			name:             "default path",
+			expectedHostPath: "/var/run/crio/crio.sock",
+		},
+		{
+			name:             "custom path",
+			crioSocketPath:   "/run/custom/crio.sock",
+			expectedHostPath: "/run/custom/crio.sock",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.CrioSocketPath = tc.crioSocketPath
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			ds := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			var hostPath string
+			for _, vol := range ds.Spec.Template.Spec.Volumes {
+				if vol.Name == socketName && vol.HostPath != nil {
+					hostPath = vol.HostPath.Path
+				}
+			}
+			if hostPath != tc.expectedHostPath {
+				t.Errorf("expected the CRI-O socket host path %q, got %q", tc.expectedHostPath, hostPath)
+			}
+			for _, mount := range ds.Spec.Template.Spec.Containers[0].VolumeMounts {
+				if mount.Name == socketName && mount.MountPath != socketMountPath {
+					t.Errorf("expected the CRI-O socket to be mounted at %q, got %q", socketMountPath, mount.MountPath)
+				}
+			}
+
+			// the agents are updated when the path changes
+			current := r.desiredDaemonSet(testNodeObservability(), sa, test.TestNamespace, "kubelet-ca")
+			changed, _ := volumesChanged(current.Spec.Template.Spec.Volumes, ds.Spec.Template.Spec.Volumes)
+			if expected := tc.crioSocketPath != ""; changed != expected {
+				t.Errorf("expected volumes changed to be %t, got %t", expected, changed)
+			}
+		})
+	}
+}
+
+func TestProfilesHostPath(t *testing.T) {
+	testCases := []struct {
+		name             string
+		currentHostPath  string
+		profilesHostPath string
+		expectUpdate     bool
+		expectedEvent    string
+	}{
+		{
+			name: "agent container filesystem",
+		},
+		{
+			name:             "host path set",
+			profilesHostPath: "/var/lib/node-observability",
+			expectUpdate:     true,
+		},
+		{
+			name:             "host path changed",
+			currentHostPath:  "/var/lib/node-observability",
+			profilesHostPath: "/mnt/profiles",
+			expectUpdate:     true,
+		},
+		{
+			name:            "host path unset",
+			currentHostPath: "/var/lib/node-observability",
+			expectUpdate:    true,
+		},
+		{
+			name:             "memory backed host path",
+			profilesHostPath: "/run/node-observability",
+			expectUpdate:     true,
+			expectedEvent:    "Warning ProfilesHostPathMemoryBacked Profiles host path /run/node-observability is on a memory backed filesystem, the profiles take the memory of the nodes",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(100)
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.ProfilesHostPath = tc.currentHostPath
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.ProfilesHostPath = tc.profilesHostPath
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1", EventRecorder: recorder}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+
+			var hostPath, mountPath string
+			for _, vol := range got.Spec.Template.Spec.Volumes {
+				if vol.Name == profilesName && vol.HostPath != nil {
+					hostPath = vol.HostPath.Path
+				}
+			}
+			for _, mount := range got.Spec.Template.Spec.Containers[0].VolumeMounts {
+				if mount.Name == profilesName {
+					mountPath = mount.MountPath
+				}
+			}
+			if hostPath != tc.profilesHostPath {
+				t.Errorf("expected the profiles host path %q, got %q", tc.profilesHostPath, hostPath)
+			}
+			if expected := map[bool]string{true: profilesMountPath}[tc.profilesHostPath != ""]; mountPath != expected {
+				t.Errorf("expected the profiles to be mounted at %q, got %q", expected, mountPath)
+			}
+
+			r.warnProfilesHostPath(nodeObs)
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestTerminationGracePeriod(t *testing.T) {
+	testCases := []struct {
+		name               string
+		currentGracePeriod *int64
+		gracePeriod        *int64
+		expectUpdate       bool
+		expectedPeriod     int64
+	}{
+		{
+			name:           "default grace period",
+			expectedPeriod: defaultTerminationGracePeriod,
+		},
+		{
+			name:           "grace period set",
+			gracePeriod:    pointer.Int64(600),
+			expectUpdate:   true,
+			expectedPeriod: 600,
+		},
+		{
+			name:               "grace period unchanged",
+			currentGracePeriod: pointer.Int64(600),
+			gracePeriod:        pointer.Int64(600),
+			expectedPeriod:     600,
+		},
+		{
+			name:               "grace period unset",
+			currentGracePeriod: pointer.Int64(600),
+			expectUpdate:       true,
+			expectedPeriod:     defaultTerminationGracePeriod,
+		},
+		{
+			name:           "no grace period",
+			gracePeriod:    pointer.Int64(0),
+			expectUpdate:   true,
+			expectedPeriod: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.TerminationGracePeriodSeconds = tc.currentGracePeriod
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.TerminationGracePeriodSeconds = tc.gracePeriod
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+			if period := got.Spec.Template.Spec.TerminationGracePeriodSeconds; period == nil || *period != tc.expectedPeriod {
+				t.Errorf("expected the termination grace period %d, got %v", tc.expectedPeriod, period)
+			}
+		})
+	}
+}
+
+func TestHostNetwork(t *testing.T) {
+	testCases := []struct {
+		name               string
+		currentHostNetwork bool
+		hostNetwork        bool
+		expectUpdate       bool
+	}{
+		{
+			name: "pod network",
+		},
+		{
+			name:         "host network enabled",
+			hostNetwork:  true,
+			expectUpdate: true,
+		},
+		{
+			name:               "host network unchanged",
+			currentHostNetwork: true,
+			hostNetwork:        true,
+		},
+		{
+			name:               "host network disabled",
+			currentHostNetwork: true,
+			expectUpdate:       true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.HostNetwork = tc.currentHostNetwork
+			current.Spec.Port = pointer.Int32(9443)
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.HostNetwork = tc.hostNetwork
+			nodeObs.Spec.Port = pointer.Int32(9443)
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+
+			podSpec := got.Spec.Template.Spec
+			if podSpec.HostNetwork != tc.hostNetwork {
+				t.Errorf("expected host network to be %t, got %t", tc.hostNetwork, podSpec.HostNetwork)
+			}
+			expectedDNSPolicy := map[bool]corev1.DNSPolicy{true: corev1.DNSClusterFirstWithHostNet, false: corev1.DNSClusterFirst}[tc.hostNetwork]
+			if podSpec.DNSPolicy != expectedDNSPolicy {
+				t.Errorf("expected DNS policy %q, got %q", expectedDNSPolicy, podSpec.DNSPolicy)
+			}
+			expectedHostPort := map[bool]int32{true: 9443}[tc.hostNetwork]
+			for _, c := range podSpec.Containers {
+				for _, p := range c.Ports {
+					if p.HostPort != expectedHostPort {
+						t.Errorf("expected host port %d for the %s port of the %s container, got %d", expectedHostPort, p.Name, c.Name, p.HostPort)
+					}
+				}
+			}
+
+			scc := r.desiredSecurityContextConstraints(nodeObs)
+			if scc.AllowHostNetwork != tc.hostNetwork || scc.AllowHostPorts != tc.hostNetwork {
+				t.Errorf("expected the SCC to allow the host network and ports: %t, got %t and %t", tc.hostNetwork, scc.AllowHostNetwork, scc.AllowHostPorts)
+			}
+		})
+	}
+}
+
+func TestEtcdCerts(t *testing.T) {
+	testCases := []struct {
+		name         string
+		currentType  operatorv1alpha2.NodeObservabilityType
+		desiredType  operatorv1alpha2.NodeObservabilityType
+		expectUpdate bool
+		expectCerts  bool
+	}{
+		{
+			name:        "crio-kubelet type",
+			currentType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			desiredType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+		},
+		{
+			name:         "etcd type enabled",
+			currentType:  operatorv1alpha2.KubeletNodeObservabilityType,
+			desiredType:  operatorv1alpha2.EtcdNodeObservabilityType,
+			expectUpdate: true,
+			expectCerts:  true,
+		},
+		{
+			name:        "etcd type unchanged",
+			currentType: operatorv1alpha2.EtcdNodeObservabilityType,
+			desiredType: operatorv1alpha2.EtcdNodeObservabilityType,
+			expectCerts: true,
+		},
+		{
+			name:         "etcd type disabled",
+			currentType:  operatorv1alpha2.EtcdNodeObservabilityType,
+			desiredType:  operatorv1alpha2.KubeletNodeObservabilityType,
+			expectUpdate: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.Type = tc.currentType
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = tc.desiredType
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+
+			if hasVolume(got, etcdCertsName) != tc.expectCerts {
+				t.Errorf("expected the %s volume to be present: %t", etcdCertsName, tc.expectCerts)
+			}
+			container := got.Spec.Template.Spec.Containers[0]
+			mounted := false
+			for _, m := range container.VolumeMounts {
+				if m.Name == etcdCertsName {
+					mounted = true
+					if m.MountPath != etcdCertsMountPath || !m.ReadOnly {
+						t.Errorf("expected a read-only mount of the etcd certificates in %s, got %+v", etcdCertsMountPath, m)
+					}
+				}
+			}
+			if mounted != tc.expectCerts {
+				t.Errorf("expected the %s volume to be mounted: %t", etcdCertsName, tc.expectCerts)
+			}
+			envSet := false
+			for _, e := range container.Env {
+				if e.Name == etcdCertsDirEnvName {
+					envSet = e.Value == etcdCertsMountPath
+				}
+			}
+			if envSet != tc.expectCerts {
+				t.Errorf("expected the %s variable to be set: %t", etcdCertsDirEnvName, tc.expectCerts)
+			}
+		})
+	}
+}
+
+func TestDNS(t *testing.T) {
+	customDNSConfig := &corev1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10"},
+		Searches:    []string{"node-observability-operator.svc.cluster.local"},
+	}
+	testCases := []struct {
+		name              string
+		currentPolicy     corev1.DNSPolicy
+		currentConfig     *corev1.PodDNSConfig
+		policy            corev1.DNSPolicy
+		config            *corev1.PodDNSConfig
+		hostNetwork       bool
+		expectUpdate      bool
+		expectedDNSPolicy corev1.DNSPolicy
+	}{
+		{
+			name:              "default DNS policy",
+			expectedDNSPolicy: corev1.DNSClusterFirst,
+		},
+		{
+			name:              "default DNS policy with host network",
+			hostNetwork:       true,
+			expectUpdate:      true,
+			expectedDNSPolicy: corev1.DNSClusterFirstWithHostNet,
+		},
+		{
+			name:              "custom DNS policy and config",
+			policy:            corev1.DNSNone,
+			config:            customDNSConfig,
+			expectUpdate:      true,
+			expectedDNSPolicy: corev1.DNSNone,
+		},
+		{
+			name:              "custom DNS policy with host network",
+			policy:            corev1.DNSDefault,
+			hostNetwork:       true,
+			expectUpdate:      true,
+			expectedDNSPolicy: corev1.DNSDefault,
+		},
+		{
+			name:              "DNS config unchanged",
+			currentPolicy:     corev1.DNSNone,
+			currentConfig:     customDNSConfig,
+			policy:            corev1.DNSNone,
+			config:            customDNSConfig,
+			expectedDNSPolicy: corev1.DNSNone,
+		},
+		{
+			name:              "DNS config removed",
+			currentConfig:     customDNSConfig,
+			expectUpdate:      true,
+			expectedDNSPolicy: corev1.DNSClusterFirst,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.DNSPolicy = tc.currentPolicy
+			current.Spec.DNSConfig = tc.currentConfig
+			current.Spec.Port = pointer.Int32(9443)
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.DNSPolicy = tc.policy
+			nodeObs.Spec.DNSConfig = tc.config
+			nodeObs.Spec.HostNetwork = tc.hostNetwork
+			nodeObs.Spec.Port = pointer.Int32(9443)
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+
+			podSpec := got.Spec.Template.Spec
+			if podSpec.DNSPolicy != tc.expectedDNSPolicy {
+				t.Errorf("expected DNS policy %q, got %q", tc.expectedDNSPolicy, podSpec.DNSPolicy)
+			}
+			if diff := cmp.Diff(tc.config, podSpec.DNSConfig); diff != "" {
+				t.Errorf("unexpected DNS config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPodAnnotations(t *testing.T) {
+	meshAnnotations := map[string]string{"sidecar.istio.io/inject": "false"}
+	testCases := []struct {
+		name                string
+		currentAnnotations  map[string]string
+		annotations         map[string]string
+		othersAnnotations   map[string]string
+		expectUpdate        bool
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:         "annotations added",
+			annotations:  map[string]string{"sidecar.istio.io/inject": "false", "pod-security.kubernetes.io/exempt": "true"},
+			expectUpdate: true,
+			expectedAnnotations: map[string]string{
+				"sidecar.istio.io/inject":           "false",
+				"pod-security.kubernetes.io/exempt": "true",
+				managedAnnotationsKey:               "pod-security.kubernetes.io/exempt,sidecar.istio.io/inject",
+			},
+		},
+		{
+			name:               "annotations unchanged",
+			currentAnnotations: meshAnnotations,
+			annotations:        meshAnnotations,
+			expectedAnnotations: map[string]string{
+				"sidecar.istio.io/inject": "false",
+				managedAnnotationsKey:     "sidecar.istio.io/inject",
+			},
+		},
+		{
+			name:                "annotations removed",
+			currentAnnotations:  meshAnnotations,
+			expectUpdate:        true,
+			expectedAnnotations: map[string]string{},
+		},
+		{
+			name:               "annotations of others kept",
+			currentAnnotations: meshAnnotations,
+			othersAnnotations:  map[string]string{"kubectl.kubernetes.io/restartedAt": "2022-10-01T00:00:00Z"},
+			expectUpdate:       true,
+			expectedAnnotations: map[string]string{
+				"kubectl.kubernetes.io/restartedAt": "2022-10-01T00:00:00Z",
+			},
+		},
+		{
+			name:         "reserved annotations left out",
+			annotations:  map[string]string{managedAnnotationsKey: "sidecar.istio.io/inject"},
+			expectUpdate: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccountName}}
+			current := testNodeObservability()
+			current.Spec.PodAnnotations = tc.currentAnnotations
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.PodAnnotations = tc.annotations
+			r := &NodeObservabilityReconciler{AgentImage: "agent:v1"}
+			existing := r.desiredDaemonSet(current, sa, test.TestNamespace, "kubelet-ca")
+			for k, v := range tc.othersAnnotations {
+				if existing.Spec.Template.Annotations == nil {
+					existing.Spec.Template.Annotations = map[string]string{}
+				}
+				existing.Spec.Template.Annotations[k] = v
+			}
+			r.Client = fake.NewClientBuilder().WithObjects(existing).Build()
+			desired := r.desiredDaemonSet(nodeObs, sa, test.TestNamespace, "kubelet-ca")
+
+			updated, err := r.updateDaemonset(context.Background(), existing, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tc.expectUpdate {
+				t.Fatalf("expected update to be %t, got %t", tc.expectUpdate, updated)
+			}
+			got := &appsv1.DaemonSet{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: daemonSetName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get daemonset: %v", err)
+			}
+			if diff := cmp.Diff(tc.expectedAnnotations, got.Spec.Template.Annotations, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected pod annotations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 )
 
 // indexedVolumeMount is the standard core volume mount with additional index field
@@ -78,6 +79,12 @@ func volumeMountsChanged(current, expected []corev1.VolumeMount) (bool, []corev1
 			}
 		}
 	}
+	// the expected volume mounts are already in the updated ones
+	for expName := range expectedVolumeMountMap {
+		if _, currExists := currentVolumeMountMap[expName]; !currExists {
+			changed = true
+		}
+	}
 
 	return changed, updated
 }
@@ -137,16 +144,51 @@ func volumesChanged(current []corev1.Volume, desired []corev1.Volume) (bool, []c
 func equalEnvVars(current, expected []corev1.EnvVar) bool {
 	var currentSorted, expectedSorted []string
 	for _, env := range current {
-		currentSorted = append(currentSorted, env.Name+" "+env.Value)
+		currentSorted = append(currentSorted, env.Name+" "+env.Value+" "+envVarSource(env.ValueFrom))
 	}
 	for _, env := range expected {
-		expectedSorted = append(expectedSorted, env.Name+" "+env.Value)
+		expectedSorted = append(expectedSorted, env.Name+" "+env.Value+" "+envVarSource(env.ValueFrom))
 	}
 	sort.Strings(currentSorted)
 	sort.Strings(expectedSorted)
 	return cmp.Equal(currentSorted, expectedSorted)
 }
 
+// envVarSource returns the configmap or secret key referenced by the env variable,
+// the field references are left out as the API server defaults their API version.
+func envVarSource(source *corev1.EnvVarSource) string {
+	switch {
+	case source == nil:
+		return ""
+	case source.ConfigMapKeyRef != nil:
+		return "configmap:" + source.ConfigMapKeyRef.Name + "/" + source.ConfigMapKeyRef.Key
+	case source.SecretKeyRef != nil:
+		return "secret:" + source.SecretKeyRef.Name + "/" + source.SecretKeyRef.Key
+	case source.FieldRef != nil:
+		return "field:" + source.FieldRef.FieldPath
+	case source.ResourceFieldRef != nil:
+		return "resource:" + source.ResourceFieldRef.ContainerName + "/" + source.ResourceFieldRef.Resource
+	}
+	return ""
+}
+
+// equalContainerPorts returns true if 2 container port slices have the same content (order doesn't matter).
+func equalContainerPorts(current, expected []corev1.ContainerPort) bool {
+	cmpOpts := cmpopts.SortSlices(func(a, b corev1.ContainerPort) bool { return a.Name < b.Name })
+	return cmp.Equal(current, expected, cmpOpts, cmpopts.EquateEmpty())
+}
+
+// equalResources returns true if 2 resource requirements have the same content,
+// quantities are compared by value (e.g. 1 CPU equals 1000m).
+func equalResources(current, expected corev1.ResourceRequirements) bool {
+	return cmp.Equal(current, expected, cmpopts.EquateEmpty())
+}
+
+// equalProbes returns true if 2 probes have the same content.
+func equalProbes(current, expected *corev1.Probe) bool {
+	return cmp.Equal(current, expected, cmpopts.EquateEmpty())
+}
+
 // buildIndexedContainerMap builds a map from the given list of containers,
 // key is the container name,
 // value is the indexed container with the index being the sequence number of the given list.
@@ -181,6 +223,10 @@ func containersChanged(current, expected []corev1.Container) (bool, []corev1.Con
 				updatedContainers[currCont.Index].Image = expCont.Image
 				changed = true
 			}
+			if currCont.ImagePullPolicy != expCont.ImagePullPolicy {
+				updatedContainers[currCont.Index].ImagePullPolicy = expCont.ImagePullPolicy
+				changed = true
+			}
 			cmpOpts := cmpopts.SortSlices(func(a, b string) bool { return a < b })
 			if !cmp.Equal(currCont.Args, expCont.Args, cmpOpts) {
 				updatedContainers[currCont.Index].Args = expCont.Args
@@ -198,6 +244,22 @@ func containersChanged(current, expected []corev1.Container) (bool, []corev1.Con
 				updatedContainers[currCont.Index].VolumeMounts = updatedVolumeMounts
 				changed = true
 			}
+			if !equalContainerPorts(currCont.Ports, expCont.Ports) {
+				updatedContainers[currCont.Index].Ports = expCont.Ports
+				changed = true
+			}
+			if !equalResources(currCont.Resources, expCont.Resources) {
+				updatedContainers[currCont.Index].Resources = expCont.Resources
+				changed = true
+			}
+			if !equalProbes(currCont.ReadinessProbe, expCont.ReadinessProbe) {
+				updatedContainers[currCont.Index].ReadinessProbe = expCont.ReadinessProbe
+				changed = true
+			}
+			if !equalProbes(currCont.LivenessProbe, expCont.LivenessProbe) {
+				updatedContainers[currCont.Index].LivenessProbe = expCont.LivenessProbe
+				changed = true
+			}
 			if hasSecurityContextChanged(currCont.SecurityContext, expCont.SecurityContext) {
 				updatedContainers[currCont.Index].SecurityContext = expCont.SecurityContext
 				changed = true
@@ -213,6 +275,8 @@ func containersChanged(current, expected []corev1.Container) (bool, []corev1.Con
 	return changed, updatedContainers
 }
 
+// hasSecurityContextChanged returns true if the current security context doesn't match the desired one,
+// the privileged mode is ignored when not desired to handle the defaulting.
 func hasSecurityContextChanged(current, desired *corev1.SecurityContext) bool {
 	if desired == nil {
 		return false
@@ -222,7 +286,13 @@ func hasSecurityContextChanged(current, desired *corev1.SecurityContext) bool {
 		return true
 	}
 
-	return !equalBoolPtr(current.Privileged, desired.Privileged)
+	if !equalBoolPtr(current.Privileged, desired.Privileged) {
+		return true
+	}
+
+	currentCopy, desiredCopy := current.DeepCopy(), desired.DeepCopy()
+	currentCopy.Privileged, desiredCopy.Privileged = nil, nil
+	return !equality.Semantic.DeepEqual(currentCopy, desiredCopy)
 }
 
 func equalBoolPtr(current, desired *bool) bool {
@@ -0,0 +1,36 @@
+package nodeobservabilitycontroller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// specGenerations tracks the latest generation of the spec reconciled for each request,
+// the reconciles of an older generation read from a lagging cache are skipped.
+type specGenerations struct {
+	mu     sync.Mutex
+	latest map[types.NamespacedName]int64
+}
+
+// observe records the given generation of the request,
+// returns false if a newer generation was already reconciled.
+func (g *specGenerations) observe(key types.NamespacedName, generation int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.latest == nil {
+		g.latest = map[types.NamespacedName]int64{}
+	}
+	if generation < g.latest[key] {
+		return false
+	}
+	g.latest[key] = generation
+	return true
+}
+
+// forget drops the given request, e.g. once its object is deleted.
+func (g *specGenerations) forget(key types.NamespacedName) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.latest, key)
+}
@@ -0,0 +1,49 @@
+package nodeobservabilitycontroller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var reconcileIntervalSeconds = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Name:    "nodeobservability_reconcile_interval_seconds",
+		Help:    "Time between the consecutive reconciles of a NodeObservability, its average reflects the requeue period.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 12),
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileIntervalSeconds)
+}
+
+// reconcileIntervals tracks the start time of the last reconcile of each request.
+type reconcileIntervals struct {
+	mu   sync.Mutex
+	last map[types.NamespacedName]time.Time
+}
+
+// observe records the start of a reconcile of the given request
+// and the time elapsed since the previous one.
+func (i *reconcileIntervals) observe(key types.NamespacedName, now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.last == nil {
+		i.last = map[types.NamespacedName]time.Time{}
+	}
+	if last, ok := i.last[key]; ok {
+		reconcileIntervalSeconds.Observe(now.Sub(last).Seconds())
+	}
+	i.last[key] = now
+}
+
+// forget drops the given request, e.g. once its object is deleted.
+func (i *reconcileIntervals) forget(key types.NamespacedName) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.last, key)
+}
@@ -0,0 +1,71 @@
+package nodeobservabilitycontroller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileIntervals(t *testing.T) {
+	key := types.NamespacedName{Name: "cluster"}
+	start := time.Now()
+	histogram := func() (uint64, float64) {
+		h := metricValue(t, reconcileIntervalSeconds).GetHistogram()
+		return h.GetSampleCount(), h.GetSampleSum()
+	}
+
+	var intervals reconcileIntervals
+	countBefore, sumBefore := histogram()
+	intervals.observe(key, start)
+	if count, _ := histogram(); count != countBefore {
+		t.Fatalf("expected no interval for the first reconcile, got %d new samples", count-countBefore)
+	}
+	intervals.observe(key, start.Add(5*time.Second))
+	intervals.observe(key, start.Add(15*time.Second))
+	count, sum := histogram()
+	if count-countBefore != 2 {
+		t.Fatalf("expected 2 new intervals, got %d", count-countBefore)
+	}
+	if avg := (sum - sumBefore) / float64(count-countBefore); math.Abs(avg-7.5) > 1e-9 {
+		t.Errorf("expected an average interval of 7.5s, got %vs", avg)
+	}
+
+	intervals.forget(key)
+	intervals.observe(key, start.Add(time.Hour))
+	if count, _ := histogram(); count-countBefore != 2 {
+		t.Errorf("expected no interval after the request was forgotten, got %d new samples", count-countBefore-2)
+	}
+}
+
+func TestRequeuePeriod(t *testing.T) {
+	testCases := []struct {
+		name     string
+		period   time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "not set",
+			expected: defaultRequeuePeriod,
+		},
+		{
+			name:     "set",
+			period:   time.Minute,
+			expected: time.Minute,
+		},
+		{
+			name:     "too short",
+			period:   10 * time.Millisecond,
+			expected: minRequeuePeriod,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeObservabilityReconciler{RequeuePeriod: tc.period}
+			if got := r.requeuePeriod(); got != tc.expected {
+				t.Errorf("expected requeue period %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
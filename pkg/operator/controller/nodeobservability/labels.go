@@ -0,0 +1,123 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// managedLabelsKey is the annotation listing the keys of
+// the user labels set by the operator on a resource
+const managedLabelsKey = "nodeobservability.olm.openshift.io/managed-labels"
+
+// userLabels returns the labels of the spec to add to the managed resources,
+// the labels reserved by the operator are left out.
+func userLabels(nodeObs *v1alpha2.NodeObservability) map[string]string {
+	reserved := labelsForNodeObservability(nodeObs.Name)
+	labels := map[string]string{}
+	for k, v := range nodeObs.Spec.Labels {
+		if _, ok := reserved[k]; ok {
+			continue
+		}
+		labels[k] = v
+	}
+	return labels
+}
+
+// withUserLabels returns the given labels merged with the user labels of the spec,
+// the given labels take precedence.
+func withUserLabels(nodeObs *v1alpha2.NodeObservability, labels map[string]string) map[string]string {
+	merged := userLabels(nodeObs)
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
+
+// managedLabels returns the user labels set by the operator on the given resource.
+func managedLabels(obj metav1.Object) map[string]string {
+	value := obj.GetAnnotations()[managedLabelsKey]
+	if value == "" {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, k := range strings.Split(value, ",") {
+		if v, ok := obj.GetLabels()[k]; ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// setUserLabels sets the given user labels on the resource and records their keys,
+// the user labels previously set by the operator which are no longer desired are removed,
+// the labels added by others are kept untouched.
+// Returns true if the resource changed.
+func setUserLabels(obj metav1.Object, desired map[string]string) bool {
+	var changed bool
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k := range managedLabels(obj) {
+		if _, ok := desired[k]; !ok {
+			delete(labels, k)
+			changed = true
+		}
+	}
+	keys := make([]string, 0, len(desired))
+	for k, v := range desired {
+		keys = append(keys, k)
+		if current, ok := labels[k]; !ok || current != v {
+			labels[k] = v
+			changed = true
+		}
+	}
+	obj.SetLabels(labels)
+
+	sort.Strings(keys)
+	annotations := obj.GetAnnotations()
+	value := strings.Join(keys, ",")
+	if current, ok := annotations[managedLabelsKey]; ok && value == "" {
+		delete(annotations, managedLabelsKey)
+		changed = true
+	} else if value != "" && current != value {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[managedLabelsKey] = value
+		changed = true
+	}
+	obj.SetAnnotations(annotations)
+	return changed
+}
+
+// ensureSecretLabels sets the user labels on the serving certificate secret of the agents.
+// The secret is created by the service CA operator, the labels are set once it exists.
+func (r *NodeObservabilityReconciler) ensureSecretLabels(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) error {
+	nameSpace := types.NamespacedName{Namespace: ns, Name: servingCertSecretName(nodeObs)}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, nameSpace, secret); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.V(1).Info("serving certificate secret not created yet, labels not set", "secret.namespace", ns, "secret.name", nameSpace.Name)
+			return nil
+		}
+		return fmt.Errorf("failed to get secret %q: %w", nameSpace, err)
+	}
+	if !setUserLabels(secret, userLabels(nodeObs)) {
+		return nil
+	}
+	if err := r.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update the labels of secret %q: %w", nameSpace, err)
+	}
+	r.Log.V(1).Info("successfully updated the labels of secret", "secret.namespace", ns, "secret.name", nameSpace.Name)
+	return nil
+}
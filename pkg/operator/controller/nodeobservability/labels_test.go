@@ -0,0 +1,149 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestUserLabels(t *testing.T) {
+	nodeObs := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: v1alpha2.NodeObservabilitySpec{
+			Labels: map[string]string{"cost-center": "1234", "app": "other", "nodeobs_cr": "other"},
+		},
+	}
+	expected := map[string]string{"cost-center": "1234"}
+	if got := userLabels(nodeObs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected user labels %v, got %v", expected, got)
+	}
+	expected = map[string]string{"cost-center": "1234", "app": "nodeobservability", "nodeobs_cr": "cluster"}
+	if got := withUserLabels(nodeObs, labelsForNodeObservability(nodeObs.Name)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected merged labels %v, got %v", expected, got)
+	}
+}
+
+func TestSetUserLabels(t *testing.T) {
+	testCases := []struct {
+		name                string
+		labels              map[string]string
+		annotations         map[string]string
+		desired             map[string]string
+		expectedChanged     bool
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "no user labels",
+		},
+		{
+			name:                "user labels added",
+			labels:              map[string]string{"app": "nodeobservability"},
+			desired:             map[string]string{"cost-center": "1234", "team": "node"},
+			expectedChanged:     true,
+			expectedLabels:      map[string]string{"app": "nodeobservability", "cost-center": "1234", "team": "node"},
+			expectedAnnotations: map[string]string{managedLabelsKey: "cost-center,team"},
+		},
+		{
+			name:                "user labels up to date",
+			labels:              map[string]string{"app": "nodeobservability", "cost-center": "1234"},
+			annotations:         map[string]string{managedLabelsKey: "cost-center"},
+			desired:             map[string]string{"cost-center": "1234"},
+			expectedLabels:      map[string]string{"app": "nodeobservability", "cost-center": "1234"},
+			expectedAnnotations: map[string]string{managedLabelsKey: "cost-center"},
+		},
+		{
+			name:                "user label changed and removed",
+			labels:              map[string]string{"app": "nodeobservability", "cost-center": "1234", "team": "node"},
+			annotations:         map[string]string{managedLabelsKey: "cost-center,team"},
+			desired:             map[string]string{"cost-center": "5678"},
+			expectedChanged:     true,
+			expectedLabels:      map[string]string{"app": "nodeobservability", "cost-center": "5678"},
+			expectedAnnotations: map[string]string{managedLabelsKey: "cost-center"},
+		},
+		{
+			name:                "all user labels removed, others kept",
+			labels:              map[string]string{"app": "nodeobservability", "cost-center": "1234", "added-by": "admin"},
+			annotations:         map[string]string{managedLabelsKey: "cost-center", "other": "value"},
+			expectedChanged:     true,
+			expectedLabels:      map[string]string{"app": "nodeobservability", "added-by": "admin"},
+			expectedAnnotations: map[string]string{"other": "value"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Labels: tc.labels, Annotations: tc.annotations},
+			}
+			if changed := setUserLabels(obj, tc.desired); changed != tc.expectedChanged {
+				t.Errorf("expected changed to be %t, got %t", tc.expectedChanged, changed)
+			}
+			if len(obj.Labels) != len(tc.expectedLabels) || (len(tc.expectedLabels) > 0 && !reflect.DeepEqual(obj.Labels, tc.expectedLabels)) {
+				t.Errorf("expected labels %v, got %v", tc.expectedLabels, obj.Labels)
+			}
+			if len(obj.Annotations) != len(tc.expectedAnnotations) || (len(tc.expectedAnnotations) > 0 && !reflect.DeepEqual(obj.Annotations, tc.expectedAnnotations)) {
+				t.Errorf("expected annotations %v, got %v", tc.expectedAnnotations, obj.Annotations)
+			}
+		})
+	}
+}
+
+func TestEnsureSecretLabels(t *testing.T) {
+	testCases := []struct {
+		name            string
+		existingObjects []runtime.Object
+		expectedLabels  map[string]string
+	}{
+		{
+			name: "secret not created yet",
+		},
+		{
+			name: "labels set on the secret",
+			existingObjects: []runtime.Object{
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: test.OperatorNamespace, Labels: map[string]string{"added-by": "service-ca"}}},
+			},
+			expectedLabels: map[string]string{"added-by": "service-ca", "cost-center": "1234"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := &NodeObservabilityReconciler{
+				Client: cl,
+				Scheme: test.Scheme,
+				Log:    zap.New(zap.UseDevMode(true)),
+			}
+			nodeObs := &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: v1alpha2.NodeObservabilitySpec{
+					Labels: map[string]string{"cost-center": "1234"},
+				},
+			}
+
+			if err := r.ensureSecretLabels(context.TODO(), nodeObs, test.OperatorNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if tc.expectedLabels == nil {
+				return
+			}
+			secret := &corev1.Secret{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: test.OperatorNamespace}, secret); err != nil {
+				t.Fatalf("failed to get secret: %v", err)
+			}
+			if !reflect.DeepEqual(secret.Labels, tc.expectedLabels) {
+				t.Errorf("expected secret labels %v, got %v", tc.expectedLabels, secret.Labels)
+			}
+		})
+	}
+}
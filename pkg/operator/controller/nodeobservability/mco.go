@@ -19,15 +19,27 @@ package nodeobservabilitycontroller
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 
 	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	machineconfigcontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig"
 )
 
 func (r *NodeObservabilityReconciler) ensureNOMC(ctx context.Context, instance *v1alpha2.NodeObservability) (*v1alpha2.NodeObservabilityMachineConfig, error) {
@@ -48,10 +60,31 @@ func (r *NodeObservabilityReconciler) ensureNOMC(ctx context.Context, instance *
 			return nil, fmt.Errorf("failed to create nodeobservabilitymachineconfig %q: %w", instance.Name, err)
 		}
 		r.Log.V(1).Info("created nodeobservabilitymachineconfig", "nomc.namespace", instance.Namespace, "nomc.name", instance.Name)
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, eventReasonMachineConfigApplied, "Created nodeobservabilitymachineconfig %s", nameSpace.Name)
+		r.warnMasterNodesIncluded(instance, desired)
 		return r.currentNOMC(ctx, nameSpace)
 	}
 
-	return r.updateNOMC(ctx, currentNOMC, desired)
+	nomc, updated, err := r.updateNOMC(ctx, currentNOMC, desired)
+	if err != nil {
+		return nil, err
+	}
+	if updated {
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, eventReasonMachineConfigApplied, "Updated nodeobservabilitymachineconfig %s", nameSpace.Name)
+		r.warnMasterNodesIncluded(instance, desired)
+	}
+	return nomc, nil
+}
+
+// warnMasterNodesIncluded records a warning event when the machine config changes
+// applied by the given NodeObservabilityMachineConfig reboot the control plane nodes.
+func (r *NodeObservabilityReconciler) warnMasterNodesIncluded(instance *v1alpha2.NodeObservability, nomc *v1alpha2.NodeObservabilityMachineConfig) {
+	if !nomc.Spec.IncludeMasterNodes {
+		return
+	}
+	r.EventRecorder.Eventf(instance, corev1.EventTypeWarning, eventReasonMasterNodesIncluded,
+		"The CRI-O profiling is rolled out by machineconfigpool %s: the control plane nodes are rebooted one at a time, which may affect the availability of the cluster",
+		machineconfigcontroller.MasterNodeMCPName)
 }
 
 // currentNOMC checks if the NodeObservabilityMachineConfig exists
@@ -65,12 +98,16 @@ func (r *NodeObservabilityReconciler) currentNOMC(ctx context.Context, nameSpace
 
 // desiredNOMC returns a NodeObservabilityMachineConfig object
 func (r *NodeObservabilityReconciler) desiredNOMC(instance *v1alpha2.NodeObservability, nameSpace types.NamespacedName) *v1alpha2.NodeObservabilityMachineConfig {
-	return &v1alpha2.NodeObservabilityMachineConfig{
+	nomc := &v1alpha2.NodeObservabilityMachineConfig{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: nameSpace.Name,
 		},
 		Spec: r.desiredNOMCSpec(instance),
 	}
+	if value, ok := instance.Annotations[v1alpha2.ForceReconcileAnnotation]; ok {
+		nomc.Annotations = map[string]string{v1alpha2.ForceReconcileAnnotation: value}
+	}
+	return nomc
 }
 
 // createNOMC creates the NodeObservabilityMachineConfig
@@ -87,11 +124,23 @@ func (r *NodeObservabilityReconciler) desiredNOMCSpec(instance *v1alpha2.NodeObs
 	if len(instance.Spec.NodeSelector) != 0 {
 		s.NodeSelector = instance.Spec.NodeSelector
 	}
+	// the machine config changes are applied only to the nodes the agent can be scheduled on
+	if instance.Spec.Affinity != nil && instance.Spec.Affinity.NodeAffinity != nil {
+		s.RequiredNodeAffinity = instance.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
+	s.NodePools = instance.Spec.NodePools
+	s.MachineConfigPoolName = instance.Spec.MachineConfigPoolName
+	s.MachineConfigRolloutStrategy = instance.Spec.MachineConfigRolloutStrategy
+	s.MachineConfigRolloutPauseDuration = instance.Spec.MachineConfigRolloutPauseDuration
+	s.DryRun = instance.Spec.MachineConfigDryRun
+	s.IncludeMasterNodes = instance.MasterNodesIncluded()
 	// TODO: ebpf, custom will go here
 	return s
 }
 
-func (r *NodeObservabilityReconciler) updateNOMC(ctx context.Context, current, desired *v1alpha2.NodeObservabilityMachineConfig) (*v1alpha2.NodeObservabilityMachineConfig, error) {
+// updateNOMC updates the NodeObservabilityMachineConfig if it differs from the desired one.
+// Returns true if it was updated.
+func (r *NodeObservabilityReconciler) updateNOMC(ctx context.Context, current, desired *v1alpha2.NodeObservabilityMachineConfig) (*v1alpha2.NodeObservabilityMachineConfig, bool, error) {
 	updatedNOMC := current.DeepCopy()
 	updated := false
 
@@ -100,16 +149,64 @@ func (r *NodeObservabilityReconciler) updateNOMC(ctx context.Context, current, d
 		updated = true
 	}
 
+	if value := desired.Annotations[v1alpha2.ForceReconcileAnnotation]; value != "" && current.Annotations[v1alpha2.ForceReconcileAnnotation] != value {
+		if updatedNOMC.Annotations == nil {
+			updatedNOMC.Annotations = map[string]string{}
+		}
+		updatedNOMC.Annotations[v1alpha2.ForceReconcileAnnotation] = value
+		updated = true
+	}
+
 	if current.Spec.Debug.EnableCrioProfiling != desired.Spec.Debug.EnableCrioProfiling {
 		updatedNOMC.Spec.Debug.EnableCrioProfiling = desired.Spec.Debug.EnableCrioProfiling
 		updated = true
 	}
 
+	if !cmp.Equal(current.Spec.NodeSelector, desired.Spec.NodeSelector, cmpopts.EquateEmpty()) {
+		updatedNOMC.Spec.NodeSelector = desired.Spec.NodeSelector
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Spec.RequiredNodeAffinity, desired.Spec.RequiredNodeAffinity) {
+		updatedNOMC.Spec.RequiredNodeAffinity = desired.Spec.RequiredNodeAffinity
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Spec.NodePools, desired.Spec.NodePools) {
+		updatedNOMC.Spec.NodePools = desired.Spec.NodePools
+		updated = true
+	}
+
+	if current.Spec.MachineConfigPoolName != desired.Spec.MachineConfigPoolName {
+		updatedNOMC.Spec.MachineConfigPoolName = desired.Spec.MachineConfigPoolName
+		updated = true
+	}
+
+	if current.Spec.MachineConfigRolloutStrategy != desired.Spec.MachineConfigRolloutStrategy {
+		updatedNOMC.Spec.MachineConfigRolloutStrategy = desired.Spec.MachineConfigRolloutStrategy
+		updated = true
+	}
+
+	if !cmp.Equal(current.Spec.MachineConfigRolloutPauseDuration, desired.Spec.MachineConfigRolloutPauseDuration) {
+		updatedNOMC.Spec.MachineConfigRolloutPauseDuration = desired.Spec.MachineConfigRolloutPauseDuration
+		updated = true
+	}
+
+	if current.Spec.DryRun != desired.Spec.DryRun {
+		updatedNOMC.Spec.DryRun = desired.Spec.DryRun
+		updated = true
+	}
+
+	if current.Spec.IncludeMasterNodes != desired.Spec.IncludeMasterNodes {
+		updatedNOMC.Spec.IncludeMasterNodes = desired.Spec.IncludeMasterNodes
+		updated = true
+	}
+
 	if updated {
-		return updatedNOMC, r.Update(ctx, updatedNOMC)
+		return updatedNOMC, true, r.Update(ctx, updatedNOMC)
 	}
 
-	return updatedNOMC, nil
+	return updatedNOMC, false, nil
 }
 
 func (r *NodeObservabilityReconciler) deleteNOMC(ctx context.Context, nodeObs *v1alpha2.NodeObservability) error {
@@ -124,3 +221,354 @@ func (r *NodeObservabilityReconciler) deleteNOMC(ctx context.Context, nodeObs *v
 	r.Log.V(1).Info("deleted nodeobservabilitymachineconfig", "nomc.name", mc.Name)
 	return nil
 }
+
+// machineConfigCleanedUp checks whether the machine config changes made for the profiling are rolled back:
+// the NodeObservabilityMachineConfig and the profiling MachineConfigs are removed
+// and the worker MachineConfigPool is updated.
+// Returns a message describing the pending cleanup step if any.
+func (r *NodeObservabilityReconciler) machineConfigCleanedUp(ctx context.Context, nodeObs *v1alpha2.NodeObservability) (bool, string, error) {
+	if _, err := r.currentNOMC(ctx, types.NamespacedName{Name: nodeObs.Name}); err == nil {
+		return false, fmt.Sprintf("waiting for nodeobservabilitymachineconfig %s to be removed", nodeObs.Name), nil
+	} else if !errors.IsNotFound(err) {
+		return false, "", fmt.Errorf("failed to get nodeobservabilitymachineconfig %s: %w", nodeObs.Name, err)
+	}
+
+	for _, pool := range nodePoolNames(nodeObs) {
+		_, mcName := profilingMCPNames(nodeObs, pool)
+		mc := &mcv1.MachineConfig{}
+		if err := r.Get(ctx, types.NamespacedName{Name: mcName}, mc); err == nil {
+			return false, fmt.Sprintf("waiting for machineconfig %s to be removed", mc.Name), nil
+		} else if !errors.IsNotFound(err) {
+			return false, "", fmt.Errorf("failed to get machineconfig %s: %w", mcName, err)
+		}
+	}
+
+	rollbackMCPName := machineconfigcontroller.WorkerNodeMCPName
+	if nodeObs.Spec.MachineConfigPoolName != "" {
+		rollbackMCPName = nodeObs.Spec.MachineConfigPoolName
+	}
+	mcp := &mcv1.MachineConfigPool{}
+	if err := r.Get(ctx, types.NamespacedName{Name: rollbackMCPName}, mcp); err != nil {
+		if errors.IsNotFound(err) {
+			// no pool to roll back
+			return true, "", nil
+		}
+		return false, "", fmt.Errorf("failed to get machineconfigpool %s: %w", rollbackMCPName, err)
+	}
+	if !mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdated) ||
+		mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdating) {
+		return false, fmt.Sprintf("waiting for machineconfigpool %s to be updated: %d of %d machines updated",
+			mcp.Name, mcp.Status.UpdatedMachineCount, mcp.Status.MachineCount), nil
+	}
+
+	return true, "", nil
+}
+
+// setMachineConfigPoolConditions reflects the rollout progress
+// of the profiling MachineConfigPools in the NodeObservability status.
+// The conditions aggregate all the pools: any degraded pool fails the rollout,
+// the rollout is ready once all the pools are updated.
+func (r *NodeObservabilityReconciler) setMachineConfigPoolConditions(ctx context.Context, nodeObs *v1alpha2.NodeObservability, nomc *v1alpha2.NodeObservabilityMachineConfig) error {
+	var (
+		msgs               []string
+		updated, degraded  []*mcv1.MachineConfigPool
+		updating, creating bool
+		rejectedReason     string
+		rejectedMsgs       []string
+		poolStatuses       []v1alpha2.NodePoolStatus
+		enabledNodes       int32
+		pendingNodes       int32
+	)
+	for _, pool := range nodePoolNames(nodeObs) {
+		mcpName, mcName := profilingMCPNames(nodeObs, pool)
+		poolStatus := v1alpha2.NodePoolStatus{Name: pool, MachineConfigPool: mcpName}
+
+		mcp := &mcv1.MachineConfigPool{}
+		if err := r.Get(ctx, types.NamespacedName{Name: mcpName}, mcp); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get machineconfigpool %s: %w", mcpName, err)
+			}
+			msgs = append(msgs, fmt.Sprintf("waiting for machineconfigpool %s to be created", mcpName))
+			creating = true
+			poolStatuses = append(poolStatuses, poolStatus)
+			continue
+		}
+
+		msgs = append(msgs, fmt.Sprintf("%d of %d machines updated in machineconfigpool %s, %d degraded",
+			mcp.Status.UpdatedMachineCount, mcp.Status.MachineCount, mcp.Name, mcp.Status.DegradedMachineCount))
+		poolStatus.MachineCount = mcp.Status.MachineCount
+		poolStatus.UpdatedMachineCount = mcp.Status.UpdatedMachineCount
+		poolStatus.DegradedMachineCount = mcp.Status.DegradedMachineCount
+		enabled, pending := profilingMachineCounts(mcp, mcName)
+		enabledNodes, pendingNodes = enabledNodes+enabled, pendingNodes+pending
+
+		switch {
+		case mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolDegraded):
+			degraded = append(degraded, mcp)
+			// the pool degraded before the profiling is rolled out on all its machines
+			// is only blamed on the MachineConfig of the operator if the MCO mentions it,
+			// the rollout is still polled if it's degraded by another MachineConfig or a node failure
+			if pending > 0 {
+				if reason, msg, ok := machineConfigDegradation(mcp, mcName); ok {
+					if rejectedReason == "" {
+						rejectedReason = reason
+					}
+					rejectedMsgs = append(rejectedMsgs, msg)
+				}
+			}
+		case mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdated) &&
+			!mcv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcv1.MachineConfigPoolUpdating) &&
+			mcp.Status.UpdatedMachineCount == mcp.Status.MachineCount:
+			updated = append(updated, mcp)
+			poolStatus.Ready = true
+		default:
+			updating = true
+		}
+		poolStatuses = append(poolStatuses, poolStatus)
+	}
+
+	if nodeObs.MasterNodesIncluded() {
+		mcp := &mcv1.MachineConfigPool{}
+		if err := r.Get(ctx, types.NamespacedName{Name: machineconfigcontroller.MasterNodeMCPName}, mcp); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get machineconfigpool %s: %w", machineconfigcontroller.MasterNodeMCPName, err)
+			}
+		} else {
+			enabled, pending := profilingMachineCounts(mcp, machineconfigcontroller.CrioProfilingMasterConfigName)
+			enabledNodes, pendingNodes = enabledNodes+enabled, pendingNodes+pending
+		}
+	}
+	nodeObs.Status.ProfilingEnabledNodes = enabledNodes
+	nodeObs.Status.ProfilingPendingNodes = pendingNodes
+
+	// the status of the pools is only detailed when they are requested
+	nodeObs.Status.NodePools = nil
+	if len(nodeObs.Spec.NodePools) != 0 {
+		nodeObs.Status.NodePools = poolStatuses
+	}
+
+	msg := strings.Join(msgs, "; ")
+	switch {
+	case len(degraded) != 0:
+		if cond := nodeObs.Status.GetCondition(v1alpha2.MachineConfigPoolReady); cond == nil || cond.Reason != v1alpha2.ReasonFailed {
+			for _, mcp := range degraded {
+				r.EventRecorder.Eventf(nodeObs, corev1.EventTypeWarning, eventReasonMachineConfigPoolDegraded, "Machineconfigpool %s has %d degraded machine(s)",
+					mcp.Name, mcp.Status.DegradedMachineCount)
+			}
+		}
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionFalse, v1alpha2.ReasonFailed, msg)
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionFalse, v1alpha2.ReasonFailed, msg)
+	case !creating && !updating:
+		if cond := nodeObs.Status.GetCondition(v1alpha2.MachineConfigPoolReady); cond == nil || cond.Status != metav1.ConditionTrue {
+			for _, mcp := range updated {
+				r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonMachineConfigPoolUpdated, "Machineconfigpool %s rolled out on %d machine(s)",
+					mcp.Name, mcp.Status.MachineCount)
+			}
+		}
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionFalse, v1alpha2.ReasonReady, msg)
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionTrue, v1alpha2.ReasonReady, msg)
+	case !updating:
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+	default:
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionTrue, v1alpha2.ReasonInProgress, msg)
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionFalse, v1alpha2.ReasonInProgress, msg)
+	}
+
+	setMachineConfigPoolPaused(nodeObs, nomc)
+
+	if len(rejectedMsgs) != 0 {
+		rejectedMsg := strings.Join(rejectedMsgs, "; ")
+		if nodeObs.Status.SetCondition(v1alpha2.MachineConfigDegraded, metav1.ConditionTrue, rejectedReason, rejectedMsg) {
+			r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, eventReasonMachineConfigDegraded, rejectedMsg)
+		}
+	} else {
+		nodeObs.Status.SetCondition(v1alpha2.MachineConfigDegraded, metav1.ConditionFalse, v1alpha2.ReasonAsExpected, "")
+	}
+	return nil
+}
+
+// setMachineConfigPoolPaused reflects in the NodeObservability status the pause
+// of the MachineConfigPool reported by the NodeObservabilityMachineConfig for the Paused rollout strategy.
+func setMachineConfigPoolPaused(nodeObs *v1alpha2.NodeObservability, nomc *v1alpha2.NodeObservabilityMachineConfig) {
+	cond := nomc.Status.GetCondition(v1alpha2.MachineConfigPoolPaused)
+	if cond == nil {
+		meta.RemoveStatusCondition(&nodeObs.Status.Conditions, v1alpha2.MachineConfigPoolPaused)
+		return
+	}
+	nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolPaused, cond.Status, cond.Reason, cond.Message)
+	if unpauseTime := nomc.Status.MachineConfigPoolUnpauseTime; unpauseTime != nil {
+		if nodeObs.Status.MachineConfig == nil {
+			nodeObs.Status.MachineConfig = &v1alpha2.MachineConfigStatus{}
+		}
+		nodeObs.Status.MachineConfig.MachineConfigPoolUnpauseTime = unpauseTime
+	}
+}
+
+// machineConfigDegradation returns the reason and the message of the given degraded MachineConfigPool
+// from the MCO conditions: the pool failing to render its configuration comes first, then its machines.
+// Returns false if the MCO doesn't mention the given MachineConfig or its CRI-O drop-in.
+func machineConfigDegradation(mcp *mcv1.MachineConfigPool, mcName string) (string, string, bool) {
+	var reason string
+	var cond *mcv1.MachineConfigPoolCondition
+	if c := mcv1.GetMachineConfigPoolCondition(mcp.Status, mcv1.MachineConfigPoolRenderDegraded); c != nil && c.Status == corev1.ConditionTrue {
+		reason, cond = v1alpha2.ReasonRenderDegraded, c
+	} else if c := mcv1.GetMachineConfigPoolCondition(mcp.Status, mcv1.MachineConfigPoolNodeDegraded); c != nil && c.Status == corev1.ConditionTrue {
+		reason, cond = v1alpha2.ReasonNodeDegraded, c
+	}
+	if cond == nil {
+		return "", "", false
+	}
+	if details := cond.Reason + " " + cond.Message; !strings.Contains(details, mcName) &&
+		!strings.Contains(details, machineconfigcontroller.CrioUnixSocketConfFile) {
+		return "", "", false
+	}
+	msg := fmt.Sprintf("machineconfig %s degraded machineconfigpool %s", mcName, mcp.Name)
+	if cond.Reason != "" {
+		msg += ": " + cond.Reason
+	}
+	if cond.Message != "" {
+		msg += ": " + cond.Message
+	}
+	return reason, msg, true
+}
+
+// machineConfigDegraded returns true if the MachineConfig of the operator is rejected by the MCO,
+// the rollout is not polled until the pool or the spec changes as it won't recover by itself.
+func machineConfigDegraded(nodeObs *v1alpha2.NodeObservability) bool {
+	cond := nodeObs.Status.GetCondition(v1alpha2.MachineConfigDegraded)
+	return cond != nil && cond.Status == metav1.ConditionTrue
+}
+
+// profilingMachineCounts returns the numbers of machines of the given MachineConfigPool
+// on which the CRI-O profiling enabled by the given MachineConfig is rolled out and pending.
+// The updated machines run the rendered config of the pool spec, the profiling is pending
+// on all the machines until the MachineConfig is part of it.
+func profilingMachineCounts(mcp *mcv1.MachineConfigPool, mcName string) (int32, int32) {
+	for _, source := range mcp.Spec.Configuration.Source {
+		if source.Name == mcName {
+			return mcp.Status.UpdatedMachineCount, mcp.Status.MachineCount - mcp.Status.UpdatedMachineCount
+		}
+	}
+	return 0, mcp.Status.MachineCount
+}
+
+// setDryRunConditions reflects the machine config dry run in the NodeObservability status,
+// the preview is reported in the status of the NodeObservabilityMachineConfig.
+func setDryRunConditions(nodeObs *v1alpha2.NodeObservability, nomc *v1alpha2.NodeObservabilityMachineConfig) {
+	msg := fmt.Sprintf("machine config changes not applied in dry run, see the preview in nodeobservabilitymachineconfig %s", nomc.Name)
+	if cond := nomc.Status.GetCondition(v1alpha2.DebugReady); cond != nil && cond.Reason == v1alpha2.ReasonDryRun {
+		msg = fmt.Sprintf("%s: %s", msg, cond.Message)
+	}
+	nodeObs.Status.NodePools = nil
+	nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionFalse, v1alpha2.ReasonDryRun, msg)
+	nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionFalse, v1alpha2.ReasonDryRun, msg)
+}
+
+// setMachineConfigPoolUnusableConditions reflects in the NodeObservability status
+// that the machine config changes are not applied as the worker or the named MachineConfigPool does not exist,
+// or as the named MachineConfigPool does not select all the targeted nodes.
+// Returns false if the NodeObservabilityMachineConfig does not report it.
+func (r *NodeObservabilityReconciler) setMachineConfigPoolUnusableConditions(nodeObs *v1alpha2.NodeObservability, nomc *v1alpha2.NodeObservabilityMachineConfig) bool {
+	cond := nomc.Status.GetCondition(v1alpha2.DebugReady)
+	if cond == nil {
+		return false
+	}
+	var eventReason string
+	switch cond.Reason {
+	case v1alpha2.ReasonMachineConfigPoolNotFound:
+		eventReason = eventReasonMachineConfigPoolNotFound
+	case v1alpha2.ReasonMachineConfigPoolMismatch:
+		eventReason = eventReasonMachineConfigPoolMismatch
+	default:
+		return false
+	}
+	nodeObs.Status.NodePools = nil
+	nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolUpdating, metav1.ConditionFalse, cond.Reason, cond.Message)
+	if nodeObs.Status.SetCondition(v1alpha2.MachineConfigPoolReady, metav1.ConditionFalse, cond.Reason, cond.Message) {
+		r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, eventReason, cond.Message)
+	}
+	return true
+}
+
+// profilingMCPNames returns the names of the MachineConfigPool and the MachineConfig
+// which roll out the CRI-O profiling on the given node pool.
+func profilingMCPNames(nodeObs *v1alpha2.NodeObservability, pool string) (string, string) {
+	if nodeObs.Spec.MachineConfigPoolName != "" {
+		return nodeObs.Spec.MachineConfigPoolName, machineconfigcontroller.CrioProfilingConfigNameForPool(nodeObs.Spec.MachineConfigPoolName)
+	}
+	return machineconfigcontroller.ProfilingMCPNameForPool(pool), machineconfigcontroller.CrioProfilingConfigNameForPool(pool)
+}
+
+// observedMCPNames returns the names of the MachineConfigPools whose rollout progress
+// is reflected in the status of the NodeObservability: the ones rolling out its machine config changes
+// and the one rolling them back.
+func observedMCPNames(nodeObs *v1alpha2.NodeObservability) []string {
+	var names []string
+	for _, pool := range nodePoolNames(nodeObs) {
+		mcpName, _ := profilingMCPNames(nodeObs, pool)
+		names = append(names, mcpName)
+	}
+	if nodeObs.MasterNodesIncluded() {
+		names = append(names, machineconfigcontroller.MasterNodeMCPName)
+	}
+	if nodeObs.Spec.MachineConfigPoolName == "" {
+		names = append(names, machineconfigcontroller.WorkerNodeMCPName)
+	}
+	return names
+}
+
+// requestsForMCP returns the reconcile requests of the NodeObservabilities
+// observing the rollout progress of the given MachineConfigPool.
+func (r *NodeObservabilityReconciler) requestsForMCP(o client.Object) []reconcile.Request {
+	nobList := &v1alpha2.NodeObservabilityList{}
+	if err := r.List(context.Background(), nobList); err != nil {
+		r.Log.Error(err, "failed to list node observability instances for machineconfigpool", "mcp.name", o.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range nobList.Items {
+		for _, name := range observedMCPNames(&nobList.Items[i]) {
+			if name == o.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: nobList.Items[i].Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// mcpRolloutChanged filters the updates of the MachineConfigPools
+// which don't change the rollout progress reflected in the NodeObservability status.
+func mcpRolloutChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldMCP, ok := e.ObjectOld.(*mcv1.MachineConfigPool)
+			if !ok {
+				return true
+			}
+			newMCP, ok := e.ObjectNew.(*mcv1.MachineConfigPool)
+			if !ok {
+				return true
+			}
+			return oldMCP.Status.MachineCount != newMCP.Status.MachineCount ||
+				oldMCP.Status.UpdatedMachineCount != newMCP.Status.UpdatedMachineCount ||
+				oldMCP.Status.DegradedMachineCount != newMCP.Status.DegradedMachineCount ||
+				!equality.Semantic.DeepEqual(oldMCP.Status.Conditions, newMCP.Status.Conditions) ||
+				!equality.Semantic.DeepEqual(oldMCP.Spec.Configuration, newMCP.Spec.Configuration)
+		},
+	}
+}
+
+// nodePoolNames returns the names of the requested node pools,
+// a single unnamed pool stands for all the targeted nodes when none is requested.
+func nodePoolNames(nodeObs *v1alpha2.NodeObservability) []string {
+	if len(nodeObs.Spec.NodePools) == 0 {
+		return []string{""}
+	}
+	names := make([]string, 0, len(nodeObs.Spec.NodePools))
+	for _, pool := range nodeObs.Spec.NodePools {
+		names = append(names, pool.Name)
+	}
+	return names
+}
@@ -18,17 +18,26 @@ package nodeobservabilitycontroller
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+
 	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	machineconfigcontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig"
 	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
 )
 
@@ -57,7 +66,9 @@ func TestEnsureMCO(t *testing.T) {
 	testCases := []struct {
 		name            string
 		existingObjects []runtime.Object
+		nodeObs         *v1alpha2.NodeObservability
 		expectedMCO     *v1alpha2.NodeObservabilityMachineConfig
+		expectedEvent   string
 	}{
 		{
 			name:            "Does not exist",
@@ -80,16 +91,89 @@ func TestEnsureMCO(t *testing.T) {
 			},
 			expectedMCO: nomc,
 		},
+		{
+			name: "Node subset changed",
+			existingObjects: []runtime.Object{
+				&v1alpha2.NodeObservabilityMachineConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: NodeObservabilityMachineConfigTest,
+					},
+					Spec: v1alpha2.NodeObservabilityMachineConfigSpec{
+						Debug: v1alpha2.NodeObservabilityDebug{
+							EnableCrioProfiling: true,
+						},
+						NodeSelector: map[string]string{"node-role.kubernetes.io/worker": ""},
+					},
+				},
+			},
+			nodeObs: &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: NodeObservabilityMachineConfigTest},
+				Spec: v1alpha2.NodeObservabilitySpec{
+					Type:         v1alpha2.CrioKubeletNodeObservabilityType,
+					NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+					Affinity:     testNodeAffinity("zone", "a"),
+				},
+			},
+			expectedMCO: &v1alpha2.NodeObservabilityMachineConfig{
+				Spec: v1alpha2.NodeObservabilityMachineConfigSpec{
+					Debug: v1alpha2.NodeObservabilityDebug{
+						EnableCrioProfiling: true,
+					},
+					NodeSelector:         map[string]string{"node-role.kubernetes.io/infra": ""},
+					RequiredNodeAffinity: testNodeAffinity("zone", "a").NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+				},
+			},
+		},
+		{
+			name:            "Master nodes included",
+			existingObjects: []runtime.Object{nomc.DeepCopy()},
+			nodeObs: &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        NodeObservabilityMachineConfigTest,
+					Annotations: map[string]string{v1alpha2.IncludeMasterNodesConfirmationAnnotation: "true"},
+				},
+				Spec: v1alpha2.NodeObservabilitySpec{
+					Type:               v1alpha2.CrioKubeletNodeObservabilityType,
+					IncludeMasterNodes: true,
+				},
+			},
+			expectedMCO: &v1alpha2.NodeObservabilityMachineConfig{
+				Spec: v1alpha2.NodeObservabilityMachineConfigSpec{
+					Debug: v1alpha2.NodeObservabilityDebug{
+						EnableCrioProfiling: true,
+					},
+					IncludeMasterNodes: true,
+				},
+			},
+			expectedEvent: "Warning " + eventReasonMasterNodesIncluded,
+		},
+		{
+			name: "Master nodes included without confirmation",
+			nodeObs: &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: NodeObservabilityMachineConfigTest},
+				Spec: v1alpha2.NodeObservabilitySpec{
+					Type:               v1alpha2.CrioKubeletNodeObservabilityType,
+					IncludeMasterNodes: true,
+				},
+			},
+			expectedMCO: nomc,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			recorder := record.NewFakeRecorder(100)
 			r := &NodeObservabilityReconciler{
-				Client: cl,
-				Scheme: test.Scheme,
-				Log:    zap.New(zap.UseDevMode(true)),
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
 			}
 
+			nodeObs := nodeObs
+			if tc.nodeObs != nil {
+				nodeObs = tc.nodeObs
+			}
 			obj, err := r.ensureNOMC(context.TODO(), nodeObs)
 			if err != nil {
 				t.Fatalf("unexpected error received: %v", err)
@@ -105,6 +189,17 @@ func TestEnsureMCO(t *testing.T) {
 				t.Errorf("resource mismatch:\n%s", diff)
 			}
 
+			if tc.expectedEvent != "" {
+				close(recorder.Events)
+				found := false
+				for event := range recorder.Events {
+					found = found || strings.HasPrefix(event, tc.expectedEvent)
+				}
+				if !found {
+					t.Errorf("expected %q event to be recorded", tc.expectedEvent)
+				}
+			}
+
 			if obj != nil {
 				for _, ref := range obj.GetOwnerReferences() {
 					if ref.Name == NodeObservabilityMachineConfigTest {
@@ -116,3 +211,629 @@ func TestEnsureMCO(t *testing.T) {
 		})
 	}
 }
+
+func TestSetMachineConfigPoolConditions(t *testing.T) {
+	testCases := []struct {
+		name            string
+		existingObjects []runtime.Object
+		// ready is the initial ready condition of the pool
+		ready            *metav1.Condition
+		expectedUpdating metav1.ConditionStatus
+		expectedReady    metav1.ConditionStatus
+		expectedReason   string
+		expectedMessage  string
+		expectedEvent    string
+		// degraded is the initial MachineConfigDegraded condition
+		degraded *metav1.Condition
+		// expectedDegradedReason is the reason of the MachineConfigDegraded condition, false if empty
+		expectedDegradedReason  string
+		expectedDegradedMessage string
+		expectedDegradedEvent   string
+		// nodePools are the names of the requested node pools
+		nodePools     []string
+		expectedPools []v1alpha2.NodePoolStatus
+		// includeMasterNodes requests the profiling of the master nodes
+		includeMasterNodes bool
+		// machineConfigPoolName is the name of the MachineConfigPool named in the spec
+		machineConfigPoolName string
+		expectedEnabledNodes  int32
+		expectedPendingNodes  int32
+	}{
+		{
+			name:             "machineconfigpool not created yet",
+			expectedUpdating: metav1.ConditionFalse,
+			expectedReady:    metav1.ConditionFalse,
+			expectedReason:   v1alpha2.ReasonInProgress,
+			expectedMessage:  "waiting for machineconfigpool nodeobservability to be created",
+		},
+		{
+			name:                 "machineconfigpool updating",
+			existingObjects:      []runtime.Object{testProfilingMCP(3, 1, 0, mcv1.MachineConfigPoolUpdating)},
+			expectedUpdating:     metav1.ConditionTrue,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonInProgress,
+			expectedMessage:      "1 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedEnabledNodes: 1,
+			expectedPendingNodes: 2,
+		},
+		{
+			name: "profiling machineconfig not rendered yet",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(3, 3, 0, mcv1.MachineConfigPoolUpdating)
+				mcp.Spec.Configuration.Source = nil
+				return mcp
+			}()},
+			expectedUpdating:     metav1.ConditionTrue,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonInProgress,
+			expectedMessage:      "3 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedPendingNodes: 3,
+		},
+		{
+			name:                 "machineconfigpool updated",
+			existingObjects:      []runtime.Object{testProfilingMCP(3, 3, 0, mcv1.MachineConfigPoolUpdated)},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedMessage:      "3 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedEvent:        "Normal MachineConfigPoolUpdated Machineconfigpool nodeobservability rolled out on 3 machine(s)",
+			expectedEnabledNodes: 3,
+		},
+		{
+			name:                  "named machineconfigpool updated",
+			machineConfigPoolName: "infra",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(2, 2, 0, mcv1.MachineConfigPoolUpdated)
+				mcp.Name = "infra"
+				mcp.Spec.Configuration.Source = []corev1.ObjectReference{{Name: machineconfigcontroller.CrioProfilingConfigNameForPool("infra")}}
+				return mcp
+			}()},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedMessage:      "2 of 2 machines updated in machineconfigpool infra, 0 degraded",
+			expectedEvent:        "Normal MachineConfigPoolUpdated Machineconfigpool infra rolled out on 2 machine(s)",
+			expectedEnabledNodes: 2,
+		},
+		{
+			name:               "machineconfigpools of worker and master nodes updating",
+			includeMasterNodes: true,
+			existingObjects: []runtime.Object{
+				testProfilingMCP(3, 3, 0, mcv1.MachineConfigPoolUpdated),
+				testMasterMCP(3, 1),
+			},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedMessage:      "3 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedEvent:        "Normal MachineConfigPoolUpdated Machineconfigpool nodeobservability rolled out on 3 machine(s)",
+			expectedEnabledNodes: 4,
+			expectedPendingNodes: 2,
+		},
+		{
+			name:                 "machineconfigpool updated already",
+			existingObjects:      []runtime.Object{testProfilingMCP(3, 3, 0, mcv1.MachineConfigPoolUpdated)},
+			ready:                &metav1.Condition{Type: v1alpha2.MachineConfigPoolReady, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonReady},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedMessage:      "3 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedEnabledNodes: 3,
+		},
+		{
+			name:                 "machineconfigpool degraded",
+			existingObjects:      []runtime.Object{testProfilingMCP(3, 2, 1, mcv1.MachineConfigPoolUpdating, mcv1.MachineConfigPoolDegraded)},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonFailed,
+			expectedMessage:      "2 of 3 machines updated in machineconfigpool nodeobservability, 1 degraded",
+			expectedEvent:        "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability has 1 degraded machine(s)",
+			expectedEnabledNodes: 2,
+			expectedPendingNodes: 1,
+		},
+		{
+			name: "machineconfig rejected already",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(3, 2, 1, mcv1.MachineConfigPoolUpdating, mcv1.MachineConfigPoolDegraded)
+				mcp.Status.Conditions = append(mcp.Status.Conditions,
+					mcv1.MachineConfigPoolCondition{Type: mcv1.MachineConfigPoolNodeDegraded, Status: corev1.ConditionTrue, Message: "failed to apply 10-crio-nodeobservability"})
+				return mcp
+			}()},
+			ready: &metav1.Condition{Type: v1alpha2.MachineConfigPoolReady, Status: metav1.ConditionFalse, Reason: v1alpha2.ReasonFailed},
+			degraded: &metav1.Condition{Type: v1alpha2.MachineConfigDegraded, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonNodeDegraded,
+				Message: "machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: failed to apply 10-crio-nodeobservability"},
+			expectedDegradedReason:  v1alpha2.ReasonNodeDegraded,
+			expectedDegradedMessage: "machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: failed to apply 10-crio-nodeobservability",
+			expectedUpdating:        metav1.ConditionFalse,
+			expectedReady:           metav1.ConditionFalse,
+			expectedReason:          v1alpha2.ReasonFailed,
+			expectedMessage:         "2 of 3 machines updated in machineconfigpool nodeobservability, 1 degraded",
+			expectedEnabledNodes:    2,
+			expectedPendingNodes:    1,
+		},
+		{
+			name: "machineconfig rejected",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(3, 3, 0)
+				mcp.Spec.Configuration.Source = nil
+				mcp.Status.Conditions = []mcv1.MachineConfigPoolCondition{
+					{Type: mcv1.MachineConfigPoolDegraded, Status: corev1.ConditionTrue, Reason: "RenderFailed", Message: "Failed to render configuration for pool nodeobservability"},
+					{Type: mcv1.MachineConfigPoolRenderDegraded, Status: corev1.ConditionTrue, Reason: "RenderFailed", Message: "could not merge 10-crio-nodeobservability: conflicting dropins 10-mco-profile-unix-socket.conf for unit crio.service"},
+				}
+				return mcp
+			}()},
+			expectedUpdating:        metav1.ConditionFalse,
+			expectedReady:           metav1.ConditionFalse,
+			expectedReason:          v1alpha2.ReasonFailed,
+			expectedMessage:         "3 of 3 machines updated in machineconfigpool nodeobservability, 0 degraded",
+			expectedEvent:           "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability has 0 degraded machine(s)",
+			expectedPendingNodes:    3,
+			expectedDegradedReason:  v1alpha2.ReasonRenderDegraded,
+			expectedDegradedMessage: "machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: RenderFailed: could not merge 10-crio-nodeobservability: conflicting dropins 10-mco-profile-unix-socket.conf for unit crio.service",
+			expectedDegradedEvent:   "Warning MachineConfigDegraded machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: RenderFailed: could not merge 10-crio-nodeobservability: conflicting dropins 10-mco-profile-unix-socket.conf for unit crio.service",
+		},
+		{
+			name: "machines failing to apply the machineconfig",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(3, 1, 1, mcv1.MachineConfigPoolUpdating)
+				mcp.Status.Conditions = append(mcp.Status.Conditions,
+					mcv1.MachineConfigPoolCondition{Type: mcv1.MachineConfigPoolDegraded, Status: corev1.ConditionTrue},
+					mcv1.MachineConfigPoolCondition{Type: mcv1.MachineConfigPoolNodeDegraded, Status: corev1.ConditionTrue, Reason: "1 nodes are reporting degraded status on sync",
+						Message: "Node worker-0 is reporting: failed to write /etc/systemd/system/crio.service.d/10-mco-profile-unix-socket.conf"})
+				return mcp
+			}()},
+			expectedUpdating:        metav1.ConditionFalse,
+			expectedReady:           metav1.ConditionFalse,
+			expectedReason:          v1alpha2.ReasonFailed,
+			expectedMessage:         "1 of 3 machines updated in machineconfigpool nodeobservability, 1 degraded",
+			expectedEvent:           "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability has 1 degraded machine(s)",
+			expectedEnabledNodes:    1,
+			expectedPendingNodes:    2,
+			expectedDegradedReason:  v1alpha2.ReasonNodeDegraded,
+			expectedDegradedMessage: "machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: 1 nodes are reporting degraded status on sync: Node worker-0 is reporting: failed to write /etc/systemd/system/crio.service.d/10-mco-profile-unix-socket.conf",
+			expectedDegradedEvent:   "Warning MachineConfigDegraded machineconfig 10-crio-nodeobservability degraded machineconfigpool nodeobservability: 1 nodes are reporting degraded status on sync: Node worker-0 is reporting: failed to write /etc/systemd/system/crio.service.d/10-mco-profile-unix-socket.conf",
+		},
+		{
+			name: "machines failing for another reason",
+			existingObjects: []runtime.Object{func() *mcv1.MachineConfigPool {
+				mcp := testProfilingMCP(3, 1, 1, mcv1.MachineConfigPoolUpdating)
+				mcp.Status.Conditions = append(mcp.Status.Conditions,
+					mcv1.MachineConfigPoolCondition{Type: mcv1.MachineConfigPoolDegraded, Status: corev1.ConditionTrue},
+					mcv1.MachineConfigPoolCondition{Type: mcv1.MachineConfigPoolNodeDegraded, Status: corev1.ConditionTrue, Reason: "1 nodes are reporting degraded status on sync",
+						Message: "Node worker-0 is reporting: failed to drain node: timed out waiting for the condition"})
+				return mcp
+			}()},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonFailed,
+			expectedMessage:      "1 of 3 machines updated in machineconfigpool nodeobservability, 1 degraded",
+			expectedEvent:        "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability has 1 degraded machine(s)",
+			expectedEnabledNodes: 1,
+			expectedPendingNodes: 2,
+		},
+		{
+			name:                 "machineconfigpool degraded after the rollout",
+			existingObjects:      []runtime.Object{testProfilingMCP(3, 3, 1, mcv1.MachineConfigPoolDegraded)},
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonFailed,
+			expectedMessage:      "3 of 3 machines updated in machineconfigpool nodeobservability, 1 degraded",
+			expectedEvent:        "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability has 1 degraded machine(s)",
+			expectedEnabledNodes: 3,
+		},
+		{
+			name:      "node pools partially updated",
+			nodePools: []string{"gpu", "cpu"},
+			existingObjects: []runtime.Object{
+				testNodePoolMCP("gpu", 2, 2, 0, mcv1.MachineConfigPoolUpdated),
+				testNodePoolMCP("cpu", 3, 1, 0, mcv1.MachineConfigPoolUpdating),
+			},
+			expectedUpdating: metav1.ConditionTrue,
+			expectedReady:    metav1.ConditionFalse,
+			expectedReason:   v1alpha2.ReasonInProgress,
+			expectedMessage:  "2 of 2 machines updated in machineconfigpool nodeobservability-gpu, 0 degraded; 1 of 3 machines updated in machineconfigpool nodeobservability-cpu, 0 degraded",
+			expectedPools: []v1alpha2.NodePoolStatus{
+				{Name: "gpu", MachineConfigPool: "nodeobservability-gpu", MachineCount: 2, UpdatedMachineCount: 2, Ready: true},
+				{Name: "cpu", MachineConfigPool: "nodeobservability-cpu", MachineCount: 3, UpdatedMachineCount: 1},
+			},
+			expectedEnabledNodes: 3,
+			expectedPendingNodes: 2,
+		},
+		{
+			name:      "node pool not created yet",
+			nodePools: []string{"gpu", "cpu"},
+			existingObjects: []runtime.Object{
+				testNodePoolMCP("gpu", 2, 2, 0, mcv1.MachineConfigPoolUpdated),
+			},
+			expectedEnabledNodes: 2,
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonInProgress,
+			expectedMessage:      "2 of 2 machines updated in machineconfigpool nodeobservability-gpu, 0 degraded; waiting for machineconfigpool nodeobservability-cpu to be created",
+			expectedPools: []v1alpha2.NodePoolStatus{
+				{Name: "gpu", MachineConfigPool: "nodeobservability-gpu", MachineCount: 2, UpdatedMachineCount: 2, Ready: true},
+				{Name: "cpu", MachineConfigPool: "nodeobservability-cpu"},
+			},
+		},
+		{
+			name:      "node pools updated",
+			nodePools: []string{"gpu", "cpu"},
+			existingObjects: []runtime.Object{
+				testNodePoolMCP("gpu", 2, 2, 0, mcv1.MachineConfigPoolUpdated),
+				testNodePoolMCP("cpu", 3, 3, 0, mcv1.MachineConfigPoolUpdated),
+			},
+			expectedUpdating: metav1.ConditionFalse,
+			expectedReady:    metav1.ConditionTrue,
+			expectedReason:   v1alpha2.ReasonReady,
+			expectedMessage:  "2 of 2 machines updated in machineconfigpool nodeobservability-gpu, 0 degraded; 3 of 3 machines updated in machineconfigpool nodeobservability-cpu, 0 degraded",
+			expectedEvent:    "Normal MachineConfigPoolUpdated Machineconfigpool nodeobservability-gpu rolled out on 2 machine(s)",
+			expectedPools: []v1alpha2.NodePoolStatus{
+				{Name: "gpu", MachineConfigPool: "nodeobservability-gpu", MachineCount: 2, UpdatedMachineCount: 2, Ready: true},
+				{Name: "cpu", MachineConfigPool: "nodeobservability-cpu", MachineCount: 3, UpdatedMachineCount: 3, Ready: true},
+			},
+			expectedEnabledNodes: 5,
+		},
+		{
+			name:      "node pool degraded",
+			nodePools: []string{"gpu", "cpu"},
+			existingObjects: []runtime.Object{
+				testNodePoolMCP("gpu", 2, 1, 0, mcv1.MachineConfigPoolUpdating),
+				testNodePoolMCP("cpu", 3, 2, 1, mcv1.MachineConfigPoolUpdating, mcv1.MachineConfigPoolDegraded),
+			},
+			expectedEnabledNodes: 3,
+			expectedPendingNodes: 2,
+			expectedUpdating:     metav1.ConditionFalse,
+			expectedReady:        metav1.ConditionFalse,
+			expectedReason:       v1alpha2.ReasonFailed,
+			expectedMessage:      "1 of 2 machines updated in machineconfigpool nodeobservability-gpu, 0 degraded; 2 of 3 machines updated in machineconfigpool nodeobservability-cpu, 1 degraded",
+			expectedEvent:        "Warning MachineConfigPoolDegraded Machineconfigpool nodeobservability-cpu has 1 degraded machine(s)",
+			expectedPools: []v1alpha2.NodePoolStatus{
+				{Name: "gpu", MachineConfigPool: "nodeobservability-gpu", MachineCount: 2, UpdatedMachineCount: 1},
+				{Name: "cpu", MachineConfigPool: "nodeobservability-cpu", MachineCount: 3, UpdatedMachineCount: 2, DegradedMachineCount: 1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			recorder := record.NewFakeRecorder(100)
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+			}
+			nodeObs := &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+			}
+			if tc.includeMasterNodes {
+				nodeObs.Spec.IncludeMasterNodes = true
+				nodeObs.Annotations = map[string]string{v1alpha2.IncludeMasterNodesConfirmationAnnotation: "true"}
+			}
+			if tc.ready != nil {
+				nodeObs.Status.Conditions = []metav1.Condition{*tc.ready}
+			}
+			if tc.degraded != nil {
+				nodeObs.Status.Conditions = append(nodeObs.Status.Conditions, *tc.degraded)
+			}
+			nodeObs.Spec.MachineConfigPoolName = tc.machineConfigPoolName
+			for _, pool := range tc.nodePools {
+				nodeObs.Spec.NodePools = append(nodeObs.Spec.NodePools, v1alpha2.NodePool{Name: pool})
+			}
+
+			if err := r.setMachineConfigPoolConditions(context.TODO(), nodeObs, &v1alpha2.NodeObservabilityMachineConfig{}); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+
+			for condType, expectedStatus := range map[string]metav1.ConditionStatus{
+				v1alpha2.MachineConfigPoolUpdating: tc.expectedUpdating,
+				v1alpha2.MachineConfigPoolReady:    tc.expectedReady,
+			} {
+				cond := nodeObs.Status.GetCondition(condType)
+				if cond == nil {
+					t.Fatalf("expected condition %s to be set", condType)
+				}
+				if cond.Status != expectedStatus || cond.Reason != tc.expectedReason {
+					t.Errorf("expected condition %s to be %s with reason %s, got %s with reason %s", condType, expectedStatus, tc.expectedReason, cond.Status, cond.Reason)
+				}
+				if cond.Message != tc.expectedMessage {
+					t.Errorf("expected condition %s message %q, got %q", condType, tc.expectedMessage, cond.Message)
+				}
+			}
+
+			if cond := nodeObs.Status.GetCondition(v1alpha2.MachineConfigDegraded); tc.expectedDegradedReason == "" {
+				if cond == nil || cond.Status != metav1.ConditionFalse {
+					t.Errorf("expected condition %s to be false, got %v", v1alpha2.MachineConfigDegraded, cond)
+				}
+			} else if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != tc.expectedDegradedReason || cond.Message != tc.expectedDegradedMessage {
+				t.Errorf("expected condition %s to be true with reason %s and message %q, got %v", v1alpha2.MachineConfigDegraded, tc.expectedDegradedReason, tc.expectedDegradedMessage, cond)
+			}
+
+			if diff := cmp.Diff(tc.expectedPools, nodeObs.Status.NodePools); diff != "" {
+				t.Errorf("unexpected node pools status (-want +got):\n%s", diff)
+			}
+			if nodeObs.Status.ProfilingEnabledNodes != tc.expectedEnabledNodes || nodeObs.Status.ProfilingPendingNodes != tc.expectedPendingNodes {
+				t.Errorf("expected profiling enabled on %d nodes and pending on %d nodes, got %d and %d", tc.expectedEnabledNodes, tc.expectedPendingNodes,
+					nodeObs.Status.ProfilingEnabledNodes, nodeObs.Status.ProfilingPendingNodes)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+			var degradedEvent string
+			for len(recorder.Events) != 0 {
+				if event := <-recorder.Events; strings.HasPrefix(event, "Warning "+eventReasonMachineConfigDegraded) {
+					degradedEvent = event
+				}
+			}
+			if degradedEvent != tc.expectedDegradedEvent {
+				t.Errorf("expected event %q, got %q", tc.expectedDegradedEvent, degradedEvent)
+			}
+		})
+	}
+}
+
+func TestSetMachineConfigPoolPaused(t *testing.T) {
+	unpauseTime := metav1.NewTime(time.Now().Add(time.Hour).Truncate(time.Second))
+	testCases := []struct {
+		name                string
+		existing            *metav1.Condition
+		nomcCondition       *metav1.Condition
+		nomcUnpauseTime     *metav1.Time
+		expectedCondition   *metav1.Condition
+		expectedUnpauseTime *metav1.Time
+	}{
+		{
+			name: "no pause",
+		},
+		{
+			name:                "pool paused",
+			nomcCondition:       &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonPaused, Message: "nodeobservability MCP paused"},
+			nomcUnpauseTime:     &unpauseTime,
+			expectedCondition:   &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonPaused, Message: "nodeobservability MCP paused"},
+			expectedUnpauseTime: &unpauseTime,
+		},
+		{
+			name:              "pool unpaused",
+			existing:          &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonPaused},
+			nomcCondition:     &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionFalse, Reason: v1alpha2.ReasonUnpaused, Message: "nodeobservability MCP unpaused"},
+			expectedCondition: &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionFalse, Reason: v1alpha2.ReasonUnpaused, Message: "nodeobservability MCP unpaused"},
+		},
+		{
+			name:     "rollout strategy changed",
+			existing: &metav1.Condition{Type: v1alpha2.MachineConfigPoolPaused, Status: metav1.ConditionTrue, Reason: v1alpha2.ReasonPaused},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := &v1alpha2.NodeObservability{}
+			if tc.existing != nil {
+				nodeObs.Status.Conditions = []metav1.Condition{*tc.existing}
+			}
+			nomc := &v1alpha2.NodeObservabilityMachineConfig{}
+			if tc.nomcCondition != nil {
+				nomc.Status.Conditions = []metav1.Condition{*tc.nomcCondition}
+			}
+			nomc.Status.MachineConfigPoolUnpauseTime = tc.nomcUnpauseTime
+
+			setMachineConfigPoolPaused(nodeObs, nomc)
+
+			cond := nodeObs.Status.GetCondition(v1alpha2.MachineConfigPoolPaused)
+			if diff := cmp.Diff(tc.expectedCondition, cond, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("unexpected condition (-want +got):\n%s", diff)
+			}
+			var gotUnpauseTime *metav1.Time
+			if nodeObs.Status.MachineConfig != nil {
+				gotUnpauseTime = nodeObs.Status.MachineConfig.MachineConfigPoolUnpauseTime
+			}
+			if !gotUnpauseTime.Equal(tc.expectedUnpauseTime) {
+				t.Errorf("expected unpause time %v, got %v", tc.expectedUnpauseTime, gotUnpauseTime)
+			}
+		})
+	}
+}
+
+func TestSetMachineConfigPoolUnusableConditions(t *testing.T) {
+	msg := "machineconfigpool worker not found, debug configurations not applied"
+	testCases := []struct {
+		name          string
+		nomcReason    string
+		expectedSet   bool
+		expectedEvent string
+	}{
+		{
+			name:       "machine config changes in progress",
+			nomcReason: v1alpha2.ReasonInProgress,
+		},
+		{
+			name:          "worker machineconfigpool not found",
+			nomcReason:    v1alpha2.ReasonMachineConfigPoolNotFound,
+			expectedSet:   true,
+			expectedEvent: "Warning MachineConfigPoolNotFound " + msg,
+		},
+		{
+			name:          "named machineconfigpool not selecting the nodes",
+			nomcReason:    v1alpha2.ReasonMachineConfigPoolMismatch,
+			expectedSet:   true,
+			expectedEvent: "Warning MachineConfigPoolMismatch " + msg,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &NodeObservabilityReconciler{
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+			}
+			nodeObs := &v1alpha2.NodeObservability{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			nomc := &v1alpha2.NodeObservabilityMachineConfig{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			nomc.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, tc.nomcReason, msg)
+
+			if set := r.setMachineConfigPoolUnusableConditions(nodeObs, nomc); set != tc.expectedSet {
+				t.Fatalf("expected conditions set to be %t, got %t", tc.expectedSet, set)
+			}
+			if !tc.expectedSet {
+				if len(nodeObs.Status.Conditions) != 0 {
+					t.Errorf("expected no condition, got %v", nodeObs.Status.Conditions)
+				}
+				return
+			}
+			for _, condType := range []string{v1alpha2.MachineConfigPoolUpdating, v1alpha2.MachineConfigPoolReady} {
+				cond := nodeObs.Status.GetCondition(condType)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != tc.nomcReason || cond.Message != msg {
+					t.Errorf("expected condition %s to be False with reason %s, got %v", condType, tc.nomcReason, cond)
+				}
+			}
+			nodeObs.Status.RollUpConditions(nodeObs.Generation)
+			if cond := nodeObs.Status.GetCondition(v1alpha2.Degraded); cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != tc.nomcReason {
+				t.Errorf("expected Degraded condition with reason %s, got %v", tc.nomcReason, cond)
+			}
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				t.Errorf("expected event %q, got none", tc.expectedEvent)
+			}
+			// the event is not repeated while the machineconfigpool is missing
+			r.setMachineConfigPoolUnusableConditions(nodeObs, nomc)
+			select {
+			case event := <-recorder.Events:
+				t.Errorf("expected no further event, got %q", event)
+			default:
+			}
+		})
+	}
+}
+
+func testNodePoolMCP(pool string, machineCount, updatedMachineCount, degradedMachineCount int32, trueConditions ...mcv1.MachineConfigPoolConditionType) *mcv1.MachineConfigPool {
+	mcp := testProfilingMCP(machineCount, updatedMachineCount, degradedMachineCount, trueConditions...)
+	mcp.Name = machineconfigcontroller.ProfilingMCPNameForPool(pool)
+	mcp.Spec.Configuration.Source = []corev1.ObjectReference{{Name: machineconfigcontroller.CrioProfilingConfigNameForPool(pool)}}
+	return mcp
+}
+
+func testMasterMCP(machineCount, updatedMachineCount int32) *mcv1.MachineConfigPool {
+	mcp := testProfilingMCP(machineCount, updatedMachineCount, 0, mcv1.MachineConfigPoolUpdating)
+	mcp.Name = machineconfigcontroller.MasterNodeMCPName
+	mcp.Spec.Configuration.Source = []corev1.ObjectReference{{Name: machineconfigcontroller.CrioProfilingMasterConfigName}}
+	return mcp
+}
+
+func testProfilingMCP(machineCount, updatedMachineCount, degradedMachineCount int32, trueConditions ...mcv1.MachineConfigPoolConditionType) *mcv1.MachineConfigPool {
+	mcp := &mcv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: machineconfigcontroller.ProfilingMCPName,
+		},
+		Spec: mcv1.MachineConfigPoolSpec{
+			Configuration: mcv1.MachineConfigPoolStatusConfiguration{
+				Source: []corev1.ObjectReference{{Name: machineconfigcontroller.CrioProfilingConfigName}},
+			},
+		},
+		Status: mcv1.MachineConfigPoolStatus{
+			MachineCount:         machineCount,
+			UpdatedMachineCount:  updatedMachineCount,
+			DegradedMachineCount: degradedMachineCount,
+		},
+	}
+	for _, condType := range trueConditions {
+		mcp.Status.Conditions = append(mcp.Status.Conditions, mcv1.MachineConfigPoolCondition{Type: condType, Status: corev1.ConditionTrue})
+	}
+	return mcp
+}
+
+func TestRequestsForMCP(t *testing.T) {
+	pooled := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "pooled"},
+		Spec:       v1alpha2.NodeObservabilitySpec{NodePools: []v1alpha2.NodePool{{Name: "gpu"}}},
+	}
+	named := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "named"},
+		Spec:       v1alpha2.NodeObservabilitySpec{MachineConfigPoolName: "infra"},
+	}
+	withMasters := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "masters",
+			Annotations: map[string]string{v1alpha2.IncludeMasterNodesConfirmationAnnotation: "true"},
+		},
+		Spec: v1alpha2.NodeObservabilitySpec{IncludeMasterNodes: true},
+	}
+	testCases := []struct {
+		mcp      string
+		expected []string
+	}{
+		{mcp: "nodeobservability-gpu", expected: []string{"pooled"}},
+		{mcp: "nodeobservability", expected: []string{"masters"}},
+		{mcp: "infra", expected: []string{"named"}},
+		{mcp: "master", expected: []string{"masters"}},
+		// the worker MCP rolls back the profiling pools
+		{mcp: "worker", expected: []string{"masters", "pooled"}},
+		{mcp: "nodeobservability-cpu"},
+	}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(pooled, named, withMasters).Build()
+	r := &NodeObservabilityReconciler{Client: cl, Log: zap.New(zap.UseDevMode(true))}
+	for _, tc := range testCases {
+		t.Run(tc.mcp, func(t *testing.T) {
+			var names []string
+			for _, req := range r.requestsForMCP(&mcv1.MachineConfigPool{ObjectMeta: metav1.ObjectMeta{Name: tc.mcp}}) {
+				names = append(names, req.Name)
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMCPRolloutChanged(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mutate   func(*mcv1.MachineConfigPool)
+		expected bool
+	}{
+		{
+			name:   "labels changed",
+			mutate: func(mcp *mcv1.MachineConfigPool) { mcp.Labels = map[string]string{"foo": "bar"} },
+		},
+		{
+			name:     "updated machine count changed",
+			mutate:   func(mcp *mcv1.MachineConfigPool) { mcp.Status.UpdatedMachineCount++ },
+			expected: true,
+		},
+		{
+			name:     "degraded machine count changed",
+			mutate:   func(mcp *mcv1.MachineConfigPool) { mcp.Status.DegradedMachineCount++ },
+			expected: true,
+		},
+		{
+			name: "condition changed",
+			mutate: func(mcp *mcv1.MachineConfigPool) {
+				mcp.Status.Conditions = []mcv1.MachineConfigPoolCondition{{Type: mcv1.MachineConfigPoolUpdated, Status: corev1.ConditionTrue}}
+			},
+			expected: true,
+		},
+		{
+			name:     "rendered config changed",
+			mutate:   func(mcp *mcv1.MachineConfigPool) { mcp.Spec.Configuration.Name = "rendered-worker-2" },
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldMCP := testProfilingMCP(3, 1, 0, mcv1.MachineConfigPoolUpdating)
+			newMCP := oldMCP.DeepCopy()
+			tc.mutate(newMCP)
+			if changed := mcpRolloutChanged().Update(event.UpdateEvent{ObjectOld: oldMCP, ObjectNew: newMCP}); changed != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, changed)
+			}
+		})
+	}
+}
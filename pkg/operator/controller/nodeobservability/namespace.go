@@ -0,0 +1,39 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// podSecurityEnforceLabel is the label of the pod security level enforced in a namespace
+	podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	// podSecurityPrivileged is the pod security level allowing the privileged agent pods
+	podSecurityPrivileged = "privileged"
+)
+
+// verifyOperandNamespace checks that the namespace of the operands exists
+// and that its pod security level allows the privileged agent pods.
+// The operator namespace is not checked as it's managed along with the operator.
+// Returns a message explaining why the namespace cannot be used, an empty string if it can.
+func (r *NodeObservabilityReconciler) verifyOperandNamespace(ctx context.Context) (string, error) {
+	if r.OperatorNamespace == "" || r.Namespace == r.OperatorNamespace {
+		return "", nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: r.Namespace}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("operand namespace %q does not exist", r.Namespace), nil
+		}
+		return "", fmt.Errorf("failed to get namespace %q: %w", r.Namespace, err)
+	}
+	if level, found := ns.Labels[podSecurityEnforceLabel]; found && level != podSecurityPrivileged {
+		return fmt.Sprintf("operand namespace %q enforces the %q pod security level, %s=%s label is required", r.Namespace, level, podSecurityEnforceLabel, podSecurityPrivileged), nil
+	}
+	return "", nil
+}
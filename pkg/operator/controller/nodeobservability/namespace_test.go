@@ -0,0 +1,76 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestVerifyOperandNamespace(t *testing.T) {
+	testCases := []struct {
+		name             string
+		existingObjects  []runtime.Object
+		operandNamespace string
+		expectedInvalid  bool
+	}{
+		{
+			name:             "operator namespace",
+			operandNamespace: test.OperatorNamespace,
+		},
+		{
+			name:             "operand namespace does not exist",
+			operandNamespace: "node-observability-operands",
+			expectedInvalid:  true,
+		},
+		{
+			name:             "operand namespace without pod security level",
+			existingObjects:  []runtime.Object{testOperandNamespace("node-observability-operands", nil)},
+			operandNamespace: "node-observability-operands",
+		},
+		{
+			name:             "operand namespace with privileged pod security level",
+			existingObjects:  []runtime.Object{testOperandNamespace("node-observability-operands", map[string]string{podSecurityEnforceLabel: podSecurityPrivileged})},
+			operandNamespace: "node-observability-operands",
+		},
+		{
+			name:             "operand namespace with restricted pod security level",
+			existingObjects:  []runtime.Object{testOperandNamespace("node-observability-operands", map[string]string{podSecurityEnforceLabel: "restricted"})},
+			operandNamespace: "node-observability-operands",
+			expectedInvalid:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := &NodeObservabilityReconciler{
+				Client:            cl,
+				Scheme:            test.Scheme,
+				Log:               zap.New(zap.UseDevMode(true)),
+				Namespace:         tc.operandNamespace,
+				OperatorNamespace: test.OperatorNamespace,
+			}
+
+			msg, err := r.verifyOperandNamespace(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if invalid := msg != ""; invalid != tc.expectedInvalid {
+				t.Errorf("expected invalid to be %t, got %t: %q", tc.expectedInvalid, invalid, msg)
+			}
+		})
+	}
+}
+
+func testOperandNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+}
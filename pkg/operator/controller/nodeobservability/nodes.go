@@ -0,0 +1,64 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// verifyMatchingNodes checks that the node selector of NodeObservability
+// matches some of the current nodes and reflects it in the NodesMatched condition.
+// Returns true if at least one node matches.
+func (r *NodeObservabilityReconciler) verifyMatchingNodes(ctx context.Context, nodeObs *v1alpha2.NodeObservability) (bool, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels(nodeObs.Spec.NodeSelector)); err != nil {
+		return false, fmt.Errorf("failed to list nodes matching the node selector: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		nodeObs.Status.SetCondition(v1alpha2.NodesMatched, metav1.ConditionFalse, v1alpha2.ReasonNoMatchingNodes,
+			fmt.Sprintf("no node matches the node selector %q, no agent is deployed", labels.SelectorFromSet(nodeObs.Spec.NodeSelector)))
+		return false, nil
+	}
+	nodeObs.Status.SetCondition(v1alpha2.NodesMatched, metav1.ConditionTrue, v1alpha2.ReasonReady,
+		fmt.Sprintf("%d node(s) match the node selector", len(nodes.Items)))
+	return true, nil
+}
+
+// requestsForNode returns the NodeObservabilities whose node selector matches the given node.
+func (r *NodeObservabilityReconciler) requestsForNode(o client.Object) []reconcile.Request {
+	nobList := &v1alpha2.NodeObservabilityList{}
+	if err := r.List(context.Background(), nobList); err != nil {
+		r.Log.Error(err, "failed to list node observability instances for node", "node.name", o.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range nobList.Items {
+		if labels.SelectorFromSet(nobList.Items[i].Spec.NodeSelector).Matches(labels.Set(o.GetLabels())) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: nobList.Items[i].Name}})
+		}
+	}
+	return requests
+}
+
+// nodeLabelsChanged filters the updates of the nodes
+// which cannot change the nodes matched by the node selectors.
+func nodeLabelsChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
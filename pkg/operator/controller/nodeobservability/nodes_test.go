@@ -0,0 +1,150 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestVerifyMatchingNodes(t *testing.T) {
+	workerSelector := map[string]string{"node-role.kubernetes.io/worker": ""}
+	testCases := []struct {
+		name                   string
+		existingObjects        []runtime.Object
+		nodeSelector           map[string]string
+		expectedMatched        bool
+		expectedConditionState metav1.ConditionStatus
+	}{
+		{
+			name:                   "nodes match",
+			existingObjects:        []runtime.Object{testNode("worker-1", workerSelector), testNode("master-1", nil)},
+			nodeSelector:           workerSelector,
+			expectedMatched:        true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "no node matches",
+			existingObjects:        []runtime.Object{testNode("master-1", nil)},
+			nodeSelector:           workerSelector,
+			expectedConditionState: metav1.ConditionFalse,
+		},
+		{
+			name:                   "no node selector",
+			existingObjects:        []runtime.Object{testNode("master-1", nil)},
+			expectedMatched:        true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "no node",
+			expectedConditionState: metav1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := &NodeObservabilityReconciler{
+				Client: cl,
+				Scheme: test.Scheme,
+				Log:    zap.New(zap.UseDevMode(true)),
+			}
+			nodeObs := &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec:       v1alpha2.NodeObservabilitySpec{NodeSelector: tc.nodeSelector},
+			}
+
+			matched, err := r.verifyMatchingNodes(context.TODO(), nodeObs)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if matched != tc.expectedMatched {
+				t.Errorf("expected matched to be %t, got %t", tc.expectedMatched, matched)
+			}
+			cond := nodeObs.Status.GetCondition(v1alpha2.NodesMatched)
+			if cond == nil || cond.Status != tc.expectedConditionState {
+				t.Errorf("expected %s condition with status %s, got %v", v1alpha2.NodesMatched, tc.expectedConditionState, cond)
+			}
+		})
+	}
+}
+
+func TestRequestsForNode(t *testing.T) {
+	workers := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+		Spec:       v1alpha2.NodeObservabilitySpec{NodeSelector: map[string]string{"node-role.kubernetes.io/worker": ""}},
+	}
+	gpus := &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpus"},
+		Spec:       v1alpha2.NodeObservabilitySpec{NodeSelector: map[string]string{"node-role.kubernetes.io/worker": "", "gpu": "true"}},
+	}
+	testCases := []struct {
+		name     string
+		labels   map[string]string
+		expected []string
+	}{
+		{name: "worker", labels: map[string]string{"node-role.kubernetes.io/worker": ""}, expected: []string{"workers"}},
+		{name: "gpu worker", labels: map[string]string{"node-role.kubernetes.io/worker": "", "gpu": "true"}, expected: []string{"gpus", "workers"}},
+		{name: "master", labels: map[string]string{"node-role.kubernetes.io/master": ""}},
+	}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(workers, gpus).Build()
+	r := &NodeObservabilityReconciler{Client: cl, Log: zap.New(zap.UseDevMode(true))}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			for _, req := range r.requestsForNode(testNode("node-1", tc.labels)) {
+				names = append(names, req.Name)
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodeLabelsChanged(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mutate   func(*corev1.Node)
+		expected bool
+	}{
+		{
+			name:   "status changed",
+			mutate: func(node *corev1.Node) { node.Status.Phase = corev1.NodeRunning },
+		},
+		{
+			name:     "label added",
+			mutate:   func(node *corev1.Node) { node.Labels["gpu"] = "true" },
+			expected: true,
+		},
+		{
+			name:     "label removed",
+			mutate:   func(node *corev1.Node) { delete(node.Labels, "node-role.kubernetes.io/worker") },
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldNode := testNode("node-1", map[string]string{"node-role.kubernetes.io/worker": ""})
+			newNode := oldNode.DeepCopy()
+			tc.mutate(newNode)
+			if changed := nodeLabelsChanged().Update(event.UpdateEvent{ObjectOld: oldNode, ObjectNew: newNode}); changed != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, changed)
+			}
+		})
+	}
+}
+
+func testNode(name string, labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+}
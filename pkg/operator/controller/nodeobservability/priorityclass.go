@@ -0,0 +1,50 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// NodeCriticalPriorityClassName is the name of the built-in priority class
+	// which protects the agent pods from being evicted under node pressure
+	NodeCriticalPriorityClassName = "system-node-critical"
+)
+
+// verifyPriorityClass checks that the priority class of the agent pods exists
+// and reflects it in the PriorityClassAvailable condition.
+// Returns true if no priority class is set or if it exists.
+func (r *NodeObservabilityReconciler) verifyPriorityClass(ctx context.Context, nodeObs *v1alpha2.NodeObservability) (bool, error) {
+	name := r.agentPriorityClassName(nodeObs)
+	if name == "" {
+		nodeObs.Status.SetCondition(v1alpha2.PriorityClassAvailable, metav1.ConditionTrue, v1alpha2.ReasonReady, "no priorityclass set for the agent pods")
+		return true, nil
+	}
+
+	pc := &schedulingv1.PriorityClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, pc); err != nil {
+		if errors.IsNotFound(err) {
+			nodeObs.Status.SetCondition(v1alpha2.PriorityClassAvailable, metav1.ConditionFalse, v1alpha2.ReasonInvalid, fmt.Sprintf("priorityclass %q does not exist", name))
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get priorityclass %q: %w", name, err)
+	}
+	nodeObs.Status.SetCondition(v1alpha2.PriorityClassAvailable, metav1.ConditionTrue, v1alpha2.ReasonReady, fmt.Sprintf("priorityclass %q exists", name))
+	return true, nil
+}
+
+// agentPriorityClassName returns the priority class of the agent pods,
+// falls back to the operator's default one if not set in the spec.
+func (r *NodeObservabilityReconciler) agentPriorityClassName(nodeObs *v1alpha2.NodeObservability) string {
+	if nodeObs.Spec.PriorityClassName != "" {
+		return nodeObs.Spec.PriorityClassName
+	}
+	return r.AgentPriorityClassName
+}
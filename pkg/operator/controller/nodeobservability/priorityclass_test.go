@@ -0,0 +1,95 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestVerifyPriorityClass(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		existingObjects        []runtime.Object
+		priorityClassName      string
+		defaultPriorityClass   string
+		expectedAvailable      bool
+		expectedConditionState metav1.ConditionStatus
+	}{
+		{
+			name:                   "no priority class",
+			expectedAvailable:      true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "priority class exists",
+			existingObjects:        []runtime.Object{testPriorityClass("high-priority")},
+			priorityClassName:      "high-priority",
+			expectedAvailable:      true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "priority class does not exist",
+			priorityClassName:      "high-priority",
+			expectedConditionState: metav1.ConditionFalse,
+		},
+		{
+			name:                   "default priority class exists",
+			existingObjects:        []runtime.Object{testPriorityClass(NodeCriticalPriorityClassName)},
+			defaultPriorityClass:   NodeCriticalPriorityClassName,
+			expectedAvailable:      true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "spec overrides default priority class",
+			existingObjects:        []runtime.Object{testPriorityClass(NodeCriticalPriorityClassName)},
+			priorityClassName:      "high-priority",
+			defaultPriorityClass:   NodeCriticalPriorityClassName,
+			expectedConditionState: metav1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := &NodeObservabilityReconciler{
+				Client:                 cl,
+				Scheme:                 test.Scheme,
+				Log:                    zap.New(zap.UseDevMode(true)),
+				AgentPriorityClassName: tc.defaultPriorityClass,
+			}
+			nodeObs := &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec: v1alpha2.NodeObservabilitySpec{
+					PriorityClassName: tc.priorityClassName,
+				},
+			}
+
+			available, err := r.verifyPriorityClass(context.TODO(), nodeObs)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if available != tc.expectedAvailable {
+				t.Errorf("expected available to be %t, got %t", tc.expectedAvailable, available)
+			}
+			cond := nodeObs.Status.GetCondition(v1alpha2.PriorityClassAvailable)
+			if cond == nil || cond.Status != tc.expectedConditionState {
+				t.Errorf("expected %s condition with status %s, got %v", v1alpha2.PriorityClassAvailable, tc.expectedConditionState, cond)
+			}
+		})
+	}
+}
+
+func testPriorityClass(name string) *schedulingv1.PriorityClass {
+	return &schedulingv1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Value:      2000001000,
+	}
+}
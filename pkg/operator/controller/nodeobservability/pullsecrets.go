@@ -0,0 +1,43 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// verifyImagePullSecrets checks that the pull secrets of the agent image
+// exist in the given namespace and reflects it in the ImagePullSecretsAvailable condition.
+// Returns true if all the pull secrets exist.
+func (r *NodeObservabilityReconciler) verifyImagePullSecrets(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) (bool, error) {
+	if len(nodeObs.Spec.ImagePullSecrets) == 0 {
+		nodeObs.Status.SetCondition(v1alpha2.ImagePullSecretsAvailable, metav1.ConditionTrue, v1alpha2.ReasonReady, "no image pull secrets set for the agent pods")
+		return true, nil
+	}
+
+	var missing []string
+	for _, ref := range nodeObs.Spec.ImagePullSecrets {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ns}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				missing = append(missing, fmt.Sprintf("%q", ref.Name))
+				continue
+			}
+			return false, fmt.Errorf("failed to get image pull secret %q: %w", ref.Name, err)
+		}
+	}
+	if len(missing) > 0 {
+		nodeObs.Status.SetCondition(v1alpha2.ImagePullSecretsAvailable, metav1.ConditionFalse, v1alpha2.ReasonInvalid,
+			fmt.Sprintf("image pull secrets %s do not exist in namespace %q", strings.Join(missing, ", "), ns))
+		return false, nil
+	}
+	nodeObs.Status.SetCondition(v1alpha2.ImagePullSecretsAvailable, metav1.ConditionTrue, v1alpha2.ReasonReady, "all image pull secrets exist")
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestVerifyImagePullSecrets(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		existingObjects        []runtime.Object
+		pullSecrets            []string
+		expectedAvailable      bool
+		expectedConditionState metav1.ConditionStatus
+	}{
+		{
+			name:                   "no pull secrets",
+			expectedAvailable:      true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "pull secrets exist",
+			existingObjects:        []runtime.Object{testPullSecret("registry-a", test.OperatorNamespace), testPullSecret("registry-b", test.OperatorNamespace)},
+			pullSecrets:            []string{"registry-a", "registry-b"},
+			expectedAvailable:      true,
+			expectedConditionState: metav1.ConditionTrue,
+		},
+		{
+			name:                   "pull secret does not exist",
+			existingObjects:        []runtime.Object{testPullSecret("registry-a", test.OperatorNamespace)},
+			pullSecrets:            []string{"registry-a", "registry-b"},
+			expectedConditionState: metav1.ConditionFalse,
+		},
+		{
+			name:                   "pull secret in another namespace",
+			existingObjects:        []runtime.Object{testPullSecret("registry-a", "default")},
+			pullSecrets:            []string{"registry-a"},
+			expectedConditionState: metav1.ConditionFalse,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := &NodeObservabilityReconciler{
+				Client: cl,
+				Scheme: test.Scheme,
+				Log:    zap.New(zap.UseDevMode(true)),
+			}
+			nodeObs := &v1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+			}
+			for _, name := range tc.pullSecrets {
+				nodeObs.Spec.ImagePullSecrets = append(nodeObs.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+			}
+
+			available, err := r.verifyImagePullSecrets(context.TODO(), nodeObs, test.OperatorNamespace)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if available != tc.expectedAvailable {
+				t.Errorf("expected available to be %t, got %t", tc.expectedAvailable, available)
+			}
+			cond := nodeObs.Status.GetCondition(v1alpha2.ImagePullSecretsAvailable)
+			if cond == nil || cond.Status != tc.expectedConditionState {
+				t.Errorf("expected %s condition with status %s, got %v", v1alpha2.ImagePullSecretsAvailable, tc.expectedConditionState, cond)
+			}
+		})
+	}
+}
+
+func testPullSecret(name, namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"reflect"
 
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
@@ -21,6 +23,22 @@ const (
 	clusterRoleBindingName = "node-observability-agent"
 )
 
+// reportRBACForbidden reports on NodeObservability that the operator is not allowed
+// to manage the service account or the RBAC of the agents, the agents are not deployed
+// until the permissions of the operator are fixed.
+func (r *NodeObservabilityReconciler) reportRBACForbidden(ctx context.Context, nodeObs *v1alpha2.NodeObservability, forbidden error) (ctrl.Result, error) {
+	msg := fmt.Sprintf("the operator is not allowed to manage the service account and the RBAC of the agents, "+
+		"check the permissions granted to the operator: %v", forbidden)
+	if nodeObs.Status.SetCondition(v1alpha2.DebugReady, metav1.ConditionFalse, v1alpha2.ReasonForbidden, msg) {
+		r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, v1alpha2.ReasonForbidden, msg)
+	}
+	nodeObs.Status.RollUpConditions(nodeObs.Generation)
+	if err := r.Status().Update(ctx, nodeObs); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status for NodeObservability %s: %w", nodeObs.Name, err)
+	}
+	return ctrl.Result{RequeueAfter: rbacForbiddenRequeuePeriod}, nil
+}
+
 func (r *NodeObservabilityReconciler) verifyClusterRole(ctx context.Context) (bool, error) {
 	cr := &rbacv1.ClusterRole{}
 	if err := r.Get(ctx, types.NamespacedName{Name: clusterRoleName}, cr); err != nil {
@@ -124,10 +142,10 @@ func (r *NodeObservabilityReconciler) updateClusterRoleBinding(ctx context.Conte
 	return changed, r.Client.Update(ctx, updated)
 }
 
-func (r *NodeObservabilityReconciler) deleteClusterRoleBinding(nodeObs *v1alpha2.NodeObservability) error {
+func (r *NodeObservabilityReconciler) deleteClusterRoleBinding(ctx context.Context, nodeObs *v1alpha2.NodeObservability) error {
 	crb := &rbacv1.ClusterRoleBinding{}
 	crb.Name = clusterRoleBindingName
-	if err := r.Client.Delete(context.TODO(), crb); err != nil {
+	if err := r.Client.Delete(ctx, crb); err != nil {
 		if errors.IsNotFound(err) {
 			return nil
 		}
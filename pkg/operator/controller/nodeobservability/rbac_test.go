@@ -76,7 +76,7 @@ func TestDeleteClusterRole(t *testing.T) {
 				Log:    zap.New(zap.UseDevMode(true)),
 			}
 			nodeObs := testNodeObservability()
-			err := r.deleteClusterRoleBinding(nodeObs)
+			err := r.deleteClusterRoleBinding(context.TODO(), nodeObs)
 			if err != nil {
 				if !tc.errExpected {
 					t.Fatalf("unexpected error received: %v", err)
@@ -76,8 +76,8 @@ func (r *NodeObservabilityReconciler) desiredSecurityContextConstraints(nodeObs
 		AllowedCapabilities:      nil,
 		AllowHostDirVolumePlugin: true,
 		Volumes:                  []securityv1.FSType{securityv1.FSTypeHostPath, securityv1.FSTypeSecret, securityv1.FSTypeConfigMap},
-		AllowHostNetwork:         false,
-		AllowHostPorts:           false,
+		AllowHostNetwork:         nodeObs.Spec.HostNetwork,
+		AllowHostPorts:           nodeObs.Spec.HostNetwork,
 		AllowHostPID:             false,
 		AllowHostIPC:             false,
 		SELinuxContext:           securityv1.SELinuxContextStrategyOptions{Type: securityv1.SELinuxStrategyMustRunAs},
@@ -87,8 +87,9 @@ func (r *NodeObservabilityReconciler) desiredSecurityContextConstraints(nodeObs
 		ReadOnlyRootFilesystem:   false,
 		AllowedUnsafeSysctls:     nil,
 		ForbiddenSysctls:         nil,
-		SeccompProfiles:          nil,
-		Groups:                   []string{"system:cluster-admins", "system:nodes"},
+		// the first profile is the default one of the pods which don't set any
+		SeccompProfiles: []string{"runtime/default", "localhost/*", "unconfined"},
+		Groups:          []string{"system:cluster-admins", "system:nodes"},
 	}
 	return scc
 }
@@ -200,10 +201,10 @@ func (r *NodeObservabilityReconciler) updateSecurityContextConstraintes(ctx cont
 	return updated, nil
 }
 
-func (r *NodeObservabilityReconciler) deleteSecurityContextConstraints(nodeObs *v1alpha2.NodeObservability) error {
+func (r *NodeObservabilityReconciler) deleteSecurityContextConstraints(ctx context.Context, nodeObs *v1alpha2.NodeObservability) error {
 	scc := &securityv1.SecurityContextConstraints{}
 	scc.Name = sccName
-	if err := r.Client.Delete(context.TODO(), scc); err != nil {
+	if err := r.Client.Delete(ctx, scc); err != nil {
 		if errors.IsNotFound(err) {
 			return nil
 		}
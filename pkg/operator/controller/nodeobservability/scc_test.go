@@ -60,7 +60,7 @@ func makeScc() *securityv1.SecurityContextConstraints {
 		ReadOnlyRootFilesystem:   false,
 		AllowedUnsafeSysctls:     nil,
 		ForbiddenSysctls:         nil,
-		SeccompProfiles:          nil,
+		SeccompProfiles:          []string{"runtime/default", "localhost/*", "unconfined"},
 		Groups:                   []string{"system:cluster-admins", "system:nodes"},
 	}
 	return scc
@@ -172,7 +172,7 @@ func TestDeleteSCC(t *testing.T) {
 				Log:    zap.New(zap.UseDevMode(true)),
 			}
 			nodeObs := testNodeObservability()
-			err := r.deleteSecurityContextConstraints(nodeObs)
+			err := r.deleteSecurityContextConstraints(context.TODO(), nodeObs)
 			if err != nil {
 				if !tc.errExpected {
 					t.Fatalf("unexpected error received: %v", err)
@@ -2,9 +2,11 @@ package nodeobservabilitycontroller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -20,15 +22,33 @@ import (
 )
 
 const (
-	serviceName    = podName
-	secretName     = podName
+	serviceName = podName
+	// secretName is the default name of the serving cert secret
+	secretName     = v1alpha2.DefaultServingCertSecretName
 	injectCertsKey = "service.beta.openshift.io/serving-cert-secret-name"
-	port           = 8443
-	targetPort     = port
-)
-
-var (
-	requestCerts = map[string]string{injectCertsKey: serviceName}
+	// originatingServiceNameKey is the annotation set by the service CA operator
+	// on the secrets it generates, with the name of the service requesting them
+	originatingServiceNameKey = "service.beta.openshift.io/originating-service-name"
+	// managedAnnotationsKey is the annotation listing the keys of
+	// the annotations set by the operator on the service
+	managedAnnotationsKey = "nodeobservability.olm.openshift.io/managed-annotations"
+	// port is the default port of the agent service
+	port       = v1alpha2.DefaultAgentPort
+	targetPort = port
+	minPort    = 1
+	maxPort    = 65535
+	// defaultIPFamilyPolicy allows the agent service
+	// to be reached on single and dual stack clusters
+	defaultIPFamilyPolicy = corev1.IPFamilyPolicyPreferDualStack
+	// defaultPublishNotReadyAddresses allows the agent pods
+	// to be resolved before they are ready
+	defaultPublishNotReadyAddresses = true
+	// servingCertInjectionTimeout is the time given to the service CA operator
+	// to generate the serving certificate secret before the injection is reported as failed
+	servingCertInjectionTimeout = 10 * time.Minute
+	// servingCertRequeuePeriod is the period at which the serving certificate secret
+	// is checked until it's generated
+	servingCertRequeuePeriod = 30 * time.Second
 )
 
 // ensureService ensures that the service exists
@@ -37,6 +57,10 @@ var (
 func (r *NodeObservabilityReconciler) ensureService(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) (*corev1.Service, error) {
 	nameSpace := types.NamespacedName{Namespace: ns, Name: serviceName}
 
+	if err := validatePort(agentPort(nodeObs)); err != nil {
+		return nil, fmt.Errorf("failed to build service %q: %w", nameSpace, err)
+	}
+
 	desired := r.desiredService(nodeObs, ns)
 	if err := controllerutil.SetControllerReference(nodeObs, desired, r.Scheme); err != nil {
 		return nil, fmt.Errorf("failed to set the controller reference for service %q: %w", nameSpace, err)
@@ -52,9 +76,20 @@ func (r *NodeObservabilityReconciler) ensureService(ctx context.Context, nodeObs
 			return nil, fmt.Errorf("failed to create service %q: %w", nameSpace, err)
 		}
 		r.Log.V(1).Info("successfully created service", "svc.name", nameSpace.Name, "svc.namespace", nameSpace.Namespace)
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonServiceCreated, "Created service %s", nameSpace)
 		return r.currentService(ctx, nameSpace)
 	}
 
+	// the changes are only previewed while the dry run is requested
+	if nodeObs.Annotations[v1alpha2.ServiceDryRunAnnotation] == "true" {
+		nodeObs.Status.PendingServiceChanges = pendingServiceChanges(current, desired)
+		if len(nodeObs.Status.PendingServiceChanges) != 0 {
+			r.Log.V(1).Info("service changes held by the dry run", "svc.name", nameSpace.Name, "svc.namespace", nameSpace.Namespace)
+		}
+		return current, nil
+	}
+	nodeObs.Status.PendingServiceChanges = nil
+
 	// update service since it already exists
 	updated, err := r.updateService(ctx, current, desired)
 	if err != nil {
@@ -67,6 +102,7 @@ func (r *NodeObservabilityReconciler) ensureService(ctx context.Context, nodeObs
 			return nil, fmt.Errorf("failed to get existing service %q: %w", nameSpace, err)
 		}
 		r.Log.V(1).Info("successfully updated service", "svc.name", nameSpace.Name, "svc.namespace", nameSpace.Namespace)
+		r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonServiceUpdated, "Updated service %s", nameSpace)
 	}
 	return current, nil
 }
@@ -86,6 +122,35 @@ func (r *NodeObservabilityReconciler) createService(ctx context.Context, svc *co
 }
 
 func (r *NodeObservabilityReconciler) updateService(ctx context.Context, current, desired *corev1.Service) (bool, error) {
+	// the agents are resolved through the DNS records of the headless service,
+	// the cluster IP cannot be changed in place: the service which got one has to be recreated
+	if current.Spec.ClusterIP != corev1.ClusterIPNone {
+		r.Log.Info("service is no longer headless, recreating it as its cluster ip is immutable and breaks the resolution of the agents",
+			"svc.name", current.Name, "svc.namespace", current.Namespace, "clusterIP", current.Spec.ClusterIP)
+		return true, r.recreateService(ctx, current, desired)
+	}
+
+	updatedService, updated, ipFamiliesChanged := mergeService(current, desired)
+	if updated {
+		if err := r.Update(ctx, updatedService); err != nil {
+			// IP families and their policy cannot be changed in place
+			// on some cluster versions, the service has to be recreated
+			if ipFamiliesChanged && errors.IsInvalid(err) {
+				r.Log.V(1).Info("ip families of the service cannot be updated, recreating it", "svc.name", current.Name, "svc.namespace", current.Namespace, "error", err.Error())
+				return true, r.recreateService(ctx, current, desired)
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// mergeService returns a copy of the current service with the fields of the desired one
+// managed by the operator, the fields and the metadata set by others are kept.
+// Returns true if the service changed, and if its IP families changed.
+func mergeService(current, desired *corev1.Service) (*corev1.Service, bool, bool) {
 	updatedService := current.DeepCopy()
 	var updated bool
 
@@ -109,6 +174,40 @@ func (r *NodeObservabilityReconciler) updateService(ctx context.Context, current
 		updated = true
 	}
 
+	if updatedService.Spec.SessionAffinity != desired.Spec.SessionAffinity ||
+		!equality.Semantic.DeepEqual(updatedService.Spec.SessionAffinityConfig, desired.Spec.SessionAffinityConfig) {
+		updatedService.Spec.SessionAffinity = desired.Spec.SessionAffinity
+		updatedService.Spec.SessionAffinityConfig = desired.Spec.SessionAffinityConfig
+		updated = true
+	}
+
+	if updatedService.Spec.PublishNotReadyAddresses != desired.Spec.PublishNotReadyAddresses {
+		updatedService.Spec.PublishNotReadyAddresses = desired.Spec.PublishNotReadyAddresses
+		updated = true
+	}
+
+	ipFamiliesChanged := updateIPFamilies(updatedService, desired)
+	if ipFamiliesChanged {
+		updated = true
+	}
+
+	if setUserLabels(updatedService, managedLabels(desired)) {
+		updated = true
+	}
+
+	// remove the annotations which were previously managed
+	// by the operator but are no longer desired,
+	// annotations added by others are kept untouched
+	for _, annotationKey := range managedAnnotations(current) {
+		if _, ok := desired.Annotations[annotationKey]; ok {
+			continue
+		}
+		if _, ok := updatedService.Annotations[annotationKey]; ok {
+			delete(updatedService.Annotations, annotationKey)
+			updated = true
+		}
+	}
+
 	if updatedService.Annotations == nil && len(desired.Annotations) > 0 {
 		updatedService.Annotations = make(map[string]string)
 	}
@@ -119,42 +218,341 @@ func (r *NodeObservabilityReconciler) updateService(ctx context.Context, current
 		}
 	}
 
-	if updated {
-		if err := r.Update(ctx, updatedService); err != nil {
-			return false, err
+	return updatedService, updated, ipFamiliesChanged
+}
+
+// serviceFields are the fields of the agent service set by mergeService,
+// other than its labels and annotations.
+var serviceFields = []struct {
+	path  string
+	value func(*corev1.Service) interface{}
+}{
+	{"metadata.ownerReferences", func(svc *corev1.Service) interface{} { return svc.OwnerReferences }},
+	{"spec.clusterIP", func(svc *corev1.Service) interface{} { return svc.Spec.ClusterIP }},
+	{"spec.type", func(svc *corev1.Service) interface{} { return svc.Spec.Type }},
+	{"spec.ports", func(svc *corev1.Service) interface{} { return svc.Spec.Ports }},
+	{"spec.selector", func(svc *corev1.Service) interface{} { return svc.Spec.Selector }},
+	{"spec.sessionAffinity", func(svc *corev1.Service) interface{} { return svc.Spec.SessionAffinity }},
+	{"spec.sessionAffinityConfig", func(svc *corev1.Service) interface{} { return svc.Spec.SessionAffinityConfig }},
+	{"spec.publishNotReadyAddresses", func(svc *corev1.Service) interface{} { return svc.Spec.PublishNotReadyAddresses }},
+	{"spec.ipFamilyPolicy", func(svc *corev1.Service) interface{} { return svc.Spec.IPFamilyPolicy }},
+	{"spec.ipFamilies", func(svc *corev1.Service) interface{} { return svc.Spec.IPFamilies }},
+}
+
+// pendingServiceChanges returns the changes updateService would apply to the current service,
+// in a stable order for the status not to change until the service or the desired one does.
+// The service which is no longer headless is recreated: its cluster IP is reported along with the other changes.
+func pendingServiceChanges(current, desired *corev1.Service) []v1alpha2.ServiceChange {
+	updated, _, _ := mergeService(current, desired)
+	if current.Spec.ClusterIP != corev1.ClusterIPNone {
+		updated.Spec.ClusterIP = desired.Spec.ClusterIP
+	}
+
+	var changes []v1alpha2.ServiceChange
+	for _, field := range serviceFields {
+		currentValue, updatedValue := field.value(current), field.value(updated)
+		if !equality.Semantic.DeepEqual(currentValue, updatedValue) {
+			changes = append(changes, v1alpha2.ServiceChange{
+				Field:   field.path,
+				Current: encodeServiceValue(currentValue),
+				Desired: encodeServiceValue(updatedValue),
+			})
 		}
-		return true, nil
 	}
+	changes = append(changes, mapChanges("metadata.labels", current.Labels, updated.Labels)...)
+	changes = append(changes, mapChanges("metadata.annotations", current.Annotations, updated.Annotations)...)
+	return changes
+}
 
-	return false, nil
+// mapChanges returns the changes of the keys of the given labels or annotations, sorted by key.
+func mapChanges(path string, current, updated map[string]string) []v1alpha2.ServiceChange {
+	keys := make([]string, 0, len(current)+len(updated))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range updated {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []v1alpha2.ServiceChange
+	for _, key := range keys {
+		currentValue, inCurrent := current[key]
+		updatedValue, inUpdated := updated[key]
+		if inCurrent == inUpdated && currentValue == updatedValue {
+			continue
+		}
+		change := v1alpha2.ServiceChange{Field: fmt.Sprintf("%s[%s]", path, key)}
+		if inCurrent {
+			change.Current = encodeServiceValue(currentValue)
+		}
+		if inUpdated {
+			change.Desired = encodeServiceValue(updatedValue)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// encodeServiceValue returns the JSON encoding of the given value of a service field,
+// empty if it's not set. The keys of the maps are sorted by the encoding.
+func encodeServiceValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
+
+// recreateService deletes the current service and creates the desired one
+func (r *NodeObservabilityReconciler) recreateService(ctx context.Context, current, desired *corev1.Service) error {
+	if err := r.Delete(ctx, current); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete service for recreation: %w", err)
+	}
+	if err := r.createService(ctx, desired); err != nil {
+		return fmt.Errorf("failed to recreate service: %w", err)
+	}
+	return nil
+}
+
+// updateIPFamilies sets the IP family policy and IP families of the desired service
+// on the updated one, returns true if any of them changed.
+func updateIPFamilies(updated, desired *corev1.Service) bool {
+	var changed bool
+
+	if desired.Spec.IPFamilyPolicy != nil && (updated.Spec.IPFamilyPolicy == nil || *updated.Spec.IPFamilyPolicy != *desired.Spec.IPFamilyPolicy) {
+		policy := *desired.Spec.IPFamilyPolicy
+		updated.Spec.IPFamilyPolicy = &policy
+		changed = true
+	}
+
+	// the IP families are allocated by the API server when not specified
+	if len(desired.Spec.IPFamilies) > 0 && !equality.Semantic.DeepEqual(updated.Spec.IPFamilies, desired.Spec.IPFamilies) {
+		updated.Spec.IPFamilies = desired.Spec.IPFamilies
+		changed = true
+	}
+
+	// the secondary IP family has to be dropped when downgrading to single stack
+	if updated.Spec.IPFamilyPolicy != nil && *updated.Spec.IPFamilyPolicy == corev1.IPFamilyPolicySingleStack && len(updated.Spec.IPFamilies) > 1 {
+		updated.Spec.IPFamilies = updated.Spec.IPFamilies[:1]
+		changed = true
+	}
+
+	return changed
 }
 
 // desiredService returns a service object
 func (r *NodeObservabilityReconciler) desiredService(nodeObs *v1alpha2.NodeObservability, ns string) *corev1.Service {
 	ls := labelsForNodeObservability(nodeObs.Name)
+	p := agentPort(nodeObs)
+	policy := ipFamilyPolicy(nodeObs)
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace:   ns,
 			Name:        serviceName,
-			Annotations: requestCerts,
+			Annotations: withManagedAnnotations(requestCerts(nodeObs)),
 			Labels:      ls,
 		},
 		Spec: corev1.ServiceSpec{
-			ClusterIP: corev1.ClusterIPNone,
-			Type:      corev1.ServiceTypeClusterIP,
-			Selector:  ls,
+			ClusterIP:                corev1.ClusterIPNone,
+			Type:                     corev1.ServiceTypeClusterIP,
+			Selector:                 ls,
+			SessionAffinity:          corev1.ServiceAffinityNone,
+			PublishNotReadyAddresses: publishNotReadyAddresses(nodeObs),
+			IPFamilyPolicy:           &policy,
 			Ports: []corev1.ServicePort{
 				{
 					Protocol:   corev1.ProtocolTCP,
-					Port:       port,
-					TargetPort: intstr.FromInt(targetPort),
+					Port:       p,
+					TargetPort: intstr.FromInt(int(p)),
 				},
 			},
 		},
 	}
+	setUserLabels(svc, userLabels(nodeObs))
 	return svc
 }
 
+// ipFamilyPolicy returns the IP family policy of the agent service,
+// falls back to the default one if not set in the spec.
+func ipFamilyPolicy(nodeObs *v1alpha2.NodeObservability) corev1.IPFamilyPolicy {
+	if nodeObs.Spec.IPFamilyPolicy != nil {
+		return *nodeObs.Spec.IPFamilyPolicy
+	}
+	return defaultIPFamilyPolicy
+}
+
+// publishNotReadyAddresses returns whether the agent service publishes the addresses
+// of the agent pods which are not ready, falls back to the default one if not set in the spec.
+func publishNotReadyAddresses(nodeObs *v1alpha2.NodeObservability) bool {
+	if nodeObs.Spec.PublishNotReadyAddresses != nil {
+		return *nodeObs.Spec.PublishNotReadyAddresses
+	}
+	return defaultPublishNotReadyAddresses
+}
+
+// servingCertSecretName returns the name of the secret with the serving certificate of the agent,
+// falls back to the default one if not set in the spec.
+func servingCertSecretName(nodeObs *v1alpha2.NodeObservability) string {
+	if nodeObs.Spec.ServingCertSecretName != "" {
+		return nodeObs.Spec.ServingCertSecretName
+	}
+	return secretName
+}
+
+// requestCerts returns the annotations requesting the generation
+// of the serving certificate for the agent service,
+// none if the injection of the serving certificate is disabled.
+func requestCerts(nodeObs *v1alpha2.NodeObservability) map[string]string {
+	if nodeObs.Spec.DisableServingCertInjection {
+		return map[string]string{}
+	}
+	return map[string]string{injectCertsKey: servingCertSecretName(nodeObs)}
+}
+
+// deleteGeneratedServingCertSecret deletes the serving certificate secret
+// generated by the service CA operator for the agent service
+// once the injection of the serving certificate is disabled.
+// The secrets not generated for the agent service, e.g. created by the user, are kept.
+func (r *NodeObservabilityReconciler) deleteGeneratedServingCertSecret(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) error {
+	if !nodeObs.Spec.DisableServingCertInjection {
+		return nil
+	}
+	nameSpace := types.NamespacedName{Namespace: ns, Name: servingCertSecretName(nodeObs)}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, nameSpace, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get secret %q: %w", nameSpace, err)
+	}
+	if secret.Annotations[originatingServiceNameKey] != serviceName {
+		return nil
+	}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete secret %q: %w", nameSpace, err)
+	}
+	r.Log.V(1).Info("deleted the generated serving certificate secret", "secret.namespace", ns, "secret.name", nameSpace.Name)
+	r.EventRecorder.Eventf(nodeObs, corev1.EventTypeNormal, eventReasonServingCertSecretDeleted, "Deleted serving certificate secret %s", nameSpace)
+	return nil
+}
+
+// ensureServingCertSecretOwner sets the NodeObservability as an owner of the serving certificate
+// secret generated by the service CA operator for the agent service, for the secret to be
+// garbage collected once the NodeObservability is deleted. The owner reference is not a controller one
+// and the references set by the service CA operator are kept, not to conflict with it.
+// The secrets not generated for the agent service, e.g. created by the user, are left untouched.
+func (r *NodeObservabilityReconciler) ensureServingCertSecretOwner(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) error {
+	if nodeObs.Spec.DisableServingCertInjection {
+		return nil
+	}
+	nameSpace := types.NamespacedName{Namespace: ns, Name: servingCertSecretName(nodeObs)}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, nameSpace, secret); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.V(1).Info("serving certificate secret not created yet, owner reference not set", "secret.namespace", ns, "secret.name", nameSpace.Name)
+			return nil
+		}
+		return fmt.Errorf("failed to get secret %q: %w", nameSpace, err)
+	}
+	if secret.Annotations[originatingServiceNameKey] != serviceName {
+		return nil
+	}
+	for _, ref := range secret.OwnerReferences {
+		if ref.UID == nodeObs.UID {
+			return nil
+		}
+	}
+	if err := controllerutil.SetOwnerReference(nodeObs, secret, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set the owner reference for secret %q: %w", nameSpace, err)
+	}
+	if err := r.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update the owner references of secret %q: %w", nameSpace, err)
+	}
+	r.Log.V(1).Info("successfully set the owner reference of secret", "secret.namespace", ns, "secret.name", nameSpace.Name)
+	return nil
+}
+
+// verifyServingCertSecret checks that the serving certificate secret requested from
+// the service CA operator exists and reflects it in the ServingCertAvailable condition.
+// The injection is reported as failed once the secret is missing for servingCertInjectionTimeout,
+// the condition is kept as is afterwards not to flap while the secret is awaited.
+// Returns true if the secret exists or is not requested.
+func (r *NodeObservabilityReconciler) verifyServingCertSecret(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) (bool, error) {
+	if nodeObs.Spec.DisableServingCertInjection {
+		nodeObs.Status.SetCondition(v1alpha2.ServingCertAvailable, metav1.ConditionTrue, v1alpha2.ReasonDisabled, "serving certificate injection disabled")
+		return true, nil
+	}
+
+	nameSpace := types.NamespacedName{Namespace: ns, Name: servingCertSecretName(nodeObs)}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, nameSpace, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get secret %q: %w", nameSpace, err)
+		}
+	} else {
+		nodeObs.Status.SetCondition(v1alpha2.ServingCertAvailable, metav1.ConditionTrue, v1alpha2.ReasonReady, fmt.Sprintf("serving certificate secret %q exists", nameSpace))
+		return true, nil
+	}
+
+	cond := nodeObs.Status.GetCondition(v1alpha2.ServingCertAvailable)
+	if cond == nil || cond.Status != metav1.ConditionFalse {
+		nodeObs.Status.SetCondition(v1alpha2.ServingCertAvailable, metav1.ConditionFalse, v1alpha2.ReasonInProgress,
+			fmt.Sprintf("waiting for the service CA operator to generate serving certificate secret %q", nameSpace))
+		return false, nil
+	}
+	if cond.Reason == v1alpha2.ReasonInProgress && clock.Since(cond.LastTransitionTime.Time) >= servingCertInjectionTimeout {
+		msg := fmt.Sprintf("serving certificate secret %q was not generated within %v, check that the service CA operator is running "+
+			"or set disableServingCertInjection and provide the secret", nameSpace, servingCertInjectionTimeout)
+		nodeObs.Status.SetCondition(v1alpha2.ServingCertAvailable, metav1.ConditionFalse, v1alpha2.ReasonServingCertNotInjected, msg)
+		r.EventRecorder.Event(nodeObs, corev1.EventTypeWarning, eventReasonServingCertNotInjected, msg)
+	}
+	return false, nil
+}
+
+// withManagedAnnotations returns a copy of the given annotations
+// with the additional annotation listing all the given keys as managed by the operator.
+func withManagedAnnotations(annotations map[string]string) map[string]string {
+	keys := make([]string, 0, len(annotations))
+	withManaged := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		keys = append(keys, k)
+		withManaged[k] = v
+	}
+	sort.Strings(keys)
+	withManaged[managedAnnotationsKey] = strings.Join(keys, ",")
+	return withManaged
+}
+
+// managedAnnotations returns the keys of the annotations
+// which are managed by the operator on the given object.
+func managedAnnotations(obj metav1.Object) []string {
+	value, ok := obj.GetAnnotations()[managedAnnotationsKey]
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// agentPort returns the port the agent is exposed on,
+// falls back to the default one if not set in the spec.
+func agentPort(nodeObs *v1alpha2.NodeObservability) int32 {
+	if nodeObs.Spec.Port != nil {
+		return *nodeObs.Spec.Port
+	}
+	return port
+}
+
+// validatePort checks that the given port is in the valid range.
+func validatePort(p int32) error {
+	if p < minPort || p > maxPort {
+		return fmt.Errorf("port %d is out of range [%d-%d]", p, minPort, maxPort)
+	}
+	return nil
+}
+
 type SortableServicePort []corev1.ServicePort
 
 func (s SortableServicePort) Len() int {
@@ -18,18 +18,29 @@ package nodeobservabilitycontroller
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 
+	"github.com/go-logr/logr/funcr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
@@ -37,6 +48,14 @@ import (
 )
 
 func testControllerService(name, namespace string, selector, annotations map[string]string) *corev1.Service {
+	return testControllerServiceWithPort(name, namespace, selector, annotations, port)
+}
+
+func testControllerServiceWithPort(name, namespace string, selector, annotations map[string]string, p int32) *corev1.Service {
+	return testControllerServiceWithIPFamilyPolicy(name, namespace, selector, annotations, p, defaultIPFamilyPolicy)
+}
+
+func testControllerServiceWithIPFamilyPolicy(name, namespace string, selector, annotations map[string]string, p int32, policy corev1.IPFamilyPolicy) *corev1.Service {
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        name,
@@ -44,13 +63,16 @@ func testControllerService(name, namespace string, selector, annotations map[str
 			Annotations: annotations,
 		},
 		Spec: corev1.ServiceSpec{
-			ClusterIP: corev1.ClusterIPNone,
-			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP:                corev1.ClusterIPNone,
+			Type:                     corev1.ServiceTypeClusterIP,
+			SessionAffinity:          corev1.ServiceAffinityNone,
+			PublishNotReadyAddresses: true,
+			IPFamilyPolicy:           &policy,
 			Ports: []corev1.ServicePort{
 				{
 					Protocol:   corev1.ProtocolTCP,
-					Port:       port,
-					TargetPort: intstr.FromInt(targetPort),
+					Port:       p,
+					TargetPort: intstr.FromInt(int(p)),
 				},
 			},
 			Selector: selector,
@@ -58,12 +80,39 @@ func testControllerService(name, namespace string, selector, annotations map[str
 	}
 }
 
+// immutableIPFamiliesClient rejects the updates of the IP families of services
+// the way the API server does on the cluster versions where they are immutable.
+type immutableIPFamiliesClient struct {
+	client.Client
+}
+
+func (c *immutableIPFamiliesClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if svc, ok := obj.(*corev1.Service); ok {
+		current := &corev1.Service{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, current); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(current.Spec.IPFamilyPolicy, svc.Spec.IPFamilyPolicy) || !equality.Semantic.DeepEqual(current.Spec.IPFamilies, svc.Spec.IPFamilies) {
+			return kerrors.NewInvalid(schema.GroupKind{Kind: "Service"}, svc.Name, field.ErrorList{
+				field.Invalid(field.NewPath("spec", "ipFamilyPolicy"), svc.Spec.IPFamilyPolicy, "field is immutable"),
+			})
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
 func TestEnsureService(t *testing.T) {
 	testCases := []struct {
-		name            string
-		existingObjects []runtime.Object
-		deployment      *appsv1.Deployment
-		expectedService *corev1.Service
+		name                     string
+		existingObjects          []runtime.Object
+		deployment               *appsv1.Deployment
+		port                     *int32
+		secretName               string
+		ipFamilyPolicy           *corev1.IPFamilyPolicy
+		immutableFamily          bool
+		publishNotReadyAddresses *bool
+		expectedService          *corev1.Service
+		errExpected              bool
 	}{
 		{
 			name: "new service",
@@ -78,7 +127,7 @@ func TestEnsureService(t *testing.T) {
 				podName,
 				test.TestNamespace,
 				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
-				map[string]string{injectCertsKey: podName},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
 			),
 		},
 		{
@@ -102,7 +151,7 @@ func TestEnsureService(t *testing.T) {
 				podName,
 				test.TestNamespace,
 				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
-				map[string]string{injectCertsKey: podName},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
 			),
 		},
 		{
@@ -135,7 +184,7 @@ func TestEnsureService(t *testing.T) {
 				podName,
 				test.TestNamespace,
 				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
-				map[string]string{injectCertsKey: podName},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
 			),
 		},
 		{
@@ -168,7 +217,7 @@ func TestEnsureService(t *testing.T) {
 				podName,
 				test.TestNamespace,
 				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
-				map[string]string{injectCertsKey: podName},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
 			),
 		},
 		{
@@ -196,30 +245,252 @@ func TestEnsureService(t *testing.T) {
 				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
 				map[string]string{
 					injectCertsKey:         podName,
+					managedAnnotationsKey:  injectCertsKey,
+					"extra-annotation-key": "extra-annotation-value",
+				},
+			),
+		},
+		{
+			name: "existing service, stale managed annotations are removed",
+			existingObjects: []runtime.Object{
+				testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					map[string]string{
+						injectCertsKey:         podName,
+						"stale-annotation-key": "stale-annotation-value",
+						managedAnnotationsKey:  injectCertsKey + ",stale-annotation-key",
+						"extra-annotation-key": "extra-annotation-value",
+					},
+				),
+			},
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				map[string]string{
+					injectCertsKey:         podName,
+					managedAnnotationsKey:  injectCertsKey,
 					"extra-annotation-key": "extra-annotation-value",
 				},
 			),
 		},
+		{
+			name: "existing service, port changed in the spec",
+			existingObjects: []runtime.Object{
+				testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				),
+			},
+			port: pointer.Int32(9443),
+			expectedService: testControllerServiceWithPort(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				9443,
+			),
+		},
+		{
+			name: "existing service, session affinity modified",
+			existingObjects: []runtime.Object{
+				func() *corev1.Service {
+					svc := testControllerService(
+						podName,
+						test.TestNamespace,
+						map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+						withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+					)
+					svc.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+					svc.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+						ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: pointer.Int32(60)},
+					}
+					return svc
+				}(),
+			},
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+			),
+		},
+		{
+			name: "existing service, publish not ready addresses modified",
+			existingObjects: []runtime.Object{
+				func() *corev1.Service {
+					svc := testControllerService(
+						podName,
+						test.TestNamespace,
+						map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+						withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+					)
+					svc.Spec.PublishNotReadyAddresses = false
+					return svc
+				}(),
+			},
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+			),
+		},
+		{
+			name: "existing service, publish not ready addresses disabled in the spec",
+			existingObjects: []runtime.Object{
+				testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				),
+			},
+			publishNotReadyAddresses: pointer.Bool(false),
+			expectedService: func() *corev1.Service {
+				svc := testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				)
+				svc.Spec.PublishNotReadyAddresses = false
+				return svc
+			}(),
+		},
+		{
+			name: "existing service, cluster ip modified, recreated as headless",
+			existingObjects: []runtime.Object{
+				func() *corev1.Service {
+					svc := testControllerService(
+						podName,
+						test.TestNamespace,
+						map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+						withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+					)
+					svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+					svc.Spec.ClusterIP = "172.30.0.10"
+					svc.Spec.ClusterIPs = []string{"172.30.0.10"}
+					return svc
+				}(),
+			},
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+			),
+		},
+		{
+			name:       "existing service, serving cert secret name changed in the spec",
+			secretName: "custom-serving-cert",
+			existingObjects: []runtime.Object{
+				testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				),
+			},
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: "custom-serving-cert"}),
+			),
+		},
+		{
+			name: "existing service, ip family policy changed in the spec",
+			existingObjects: []runtime.Object{
+				testControllerService(
+					podName,
+					test.TestNamespace,
+					map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				),
+			},
+			ipFamilyPolicy: ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack),
+			expectedService: testControllerServiceWithIPFamilyPolicy(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+				port,
+				corev1.IPFamilyPolicySingleStack,
+			),
+		},
+		{
+			name: "existing service without ip family policy, recreated when it cannot be updated",
+			existingObjects: []runtime.Object{
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: test.TestNamespace},
+					Spec: corev1.ServiceSpec{
+						ClusterIP: corev1.ClusterIPNone,
+						Type:      corev1.ServiceTypeClusterIP,
+						Ports: []corev1.ServicePort{
+							{
+								Protocol:   corev1.ProtocolTCP,
+								Port:       port,
+								TargetPort: intstr.FromInt(targetPort),
+							},
+						},
+						Selector: map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+					},
+				},
+			},
+			immutableFamily: true,
+			expectedService: testControllerService(
+				podName,
+				test.TestNamespace,
+				map[string]string{"app": "nodeobservability", "nodeobs_cr": "test"},
+				withManagedAnnotations(map[string]string{injectCertsKey: podName}),
+			),
+		},
+		{
+			name:        "port out of range",
+			port:        pointer.Int32(70000),
+			errExpected: true,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			cl := fake.NewClientBuilder().WithRuntimeObjects(tc.existingObjects...).Build()
+			var cl client.Client = fake.NewClientBuilder().WithRuntimeObjects(tc.existingObjects...).Build()
+			if tc.immutableFamily {
+				cl = &immutableIPFamiliesClient{Client: cl}
+			}
 			r := &NodeObservabilityReconciler{
-				Client:    cl,
-				Scheme:    test.Scheme,
-				Namespace: test.TestNamespace,
-				Log:       zap.New(zap.UseDevMode(true)),
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
 			}
 			nodeObs := &operatorv1alpha2.NodeObservability{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test",
 				},
+				Spec: operatorv1alpha2.NodeObservabilitySpec{
+					Port:                     tc.port,
+					ServingCertSecretName:    tc.secretName,
+					IPFamilyPolicy:           tc.ipFamilyPolicy,
+					PublishNotReadyAddresses: tc.publishNotReadyAddresses,
+				},
 			}
 
 			_, err := r.ensureService(context.TODO(), nodeObs, test.TestNamespace)
 			if err != nil {
-				t.Fatalf("unexpected error received: %v", err)
+				if !tc.errExpected {
+					t.Fatalf("unexpected error received: %v", err)
+				}
+				return
+			}
+			if tc.errExpected {
+				t.Fatal("error expected but not received")
 			}
 			var s corev1.Service
 			err = r.Client.Get(context.Background(), types.NamespacedName{Name: tc.expectedService.Name, Namespace: tc.expectedService.Namespace}, &s)
@@ -238,3 +509,537 @@ func TestEnsureService(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateServiceHeadless(t *testing.T) {
+	testCases := []struct {
+		name              string
+		clusterIP         string
+		expectedRecreated bool
+	}{
+		{
+			name:      "headless service kept",
+			clusterIP: corev1.ClusterIPNone,
+		},
+		{
+			name:              "service with a cluster ip recreated",
+			clusterIP:         "172.30.0.10",
+			expectedRecreated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current := testControllerService(podName, test.TestNamespace, map[string]string{"app": "nodeobservability"}, nil)
+			current.UID = "current-uid"
+			current.Spec.ClusterIP = tc.clusterIP
+			cl := fake.NewClientBuilder().WithRuntimeObjects(current).Build()
+			var logs []string
+			r := &NodeObservabilityReconciler{
+				Client: cl,
+				Log: funcr.New(func(prefix, args string) {
+					logs = append(logs, args)
+				}, funcr.Options{}),
+			}
+			desired := testControllerService(podName, test.TestNamespace, map[string]string{"app": "nodeobservability"}, nil)
+
+			if _, err := r.updateService(context.TODO(), current, desired); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			got := &corev1.Service{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get service: %v", err)
+			}
+			if got.Spec.ClusterIP != corev1.ClusterIPNone {
+				t.Errorf("expected a headless service, got cluster ip %q", got.Spec.ClusterIP)
+			}
+			if recreated := got.UID != current.UID; recreated != tc.expectedRecreated {
+				t.Errorf("expected recreated %t, got %t", tc.expectedRecreated, recreated)
+			}
+			var warned bool
+			for _, l := range logs {
+				if strings.Contains(l, "service is no longer headless") && strings.Contains(l, tc.clusterIP) {
+					warned = true
+				}
+			}
+			if warned != tc.expectedRecreated {
+				t.Errorf("expected the recreation to be logged %t, got logs %v", tc.expectedRecreated, logs)
+			}
+		})
+	}
+}
+
+func TestUpdateIPFamilies(t *testing.T) {
+	testCases := []struct {
+		name             string
+		current          *corev1.Service
+		desired          *corev1.Service
+		expectedChanged  bool
+		expectedPolicy   *corev1.IPFamilyPolicy
+		expectedFamilies []corev1.IPFamily
+	}{
+		{
+			name:             "same policy, allocated families are kept",
+			current:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack), corev1.IPv4Protocol, corev1.IPv6Protocol),
+			desired:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack)),
+			expectedPolicy:   ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack),
+			expectedFamilies: []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol},
+		},
+		{
+			name:             "policy not set",
+			current:          testIPFamiliesService(nil, corev1.IPv6Protocol),
+			desired:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack)),
+			expectedChanged:  true,
+			expectedPolicy:   ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack),
+			expectedFamilies: []corev1.IPFamily{corev1.IPv6Protocol},
+		},
+		{
+			name:             "downgrade to single stack drops the secondary family",
+			current:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicyPreferDualStack), corev1.IPv6Protocol, corev1.IPv4Protocol),
+			desired:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack)),
+			expectedChanged:  true,
+			expectedPolicy:   ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack),
+			expectedFamilies: []corev1.IPFamily{corev1.IPv6Protocol},
+		},
+		{
+			name:             "families changed",
+			current:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack), corev1.IPv4Protocol),
+			desired:          testIPFamiliesService(ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack), corev1.IPv6Protocol),
+			expectedChanged:  true,
+			expectedPolicy:   ipFamilyPolicyPtr(corev1.IPFamilyPolicySingleStack),
+			expectedFamilies: []corev1.IPFamily{corev1.IPv6Protocol},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := tc.current.DeepCopy()
+			changed := updateIPFamilies(updated, tc.desired)
+			if changed != tc.expectedChanged {
+				t.Errorf("expected changed to be %t, got %t", tc.expectedChanged, changed)
+			}
+			if diff := cmp.Diff(tc.expectedPolicy, updated.Spec.IPFamilyPolicy); diff != "" {
+				t.Errorf("unexpected ip family policy\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedFamilies, updated.Spec.IPFamilies); diff != "" {
+				t.Errorf("unexpected ip families\n%s", diff)
+			}
+		})
+	}
+}
+
+func testIPFamiliesService(policy *corev1.IPFamilyPolicy, families ...corev1.IPFamily) *corev1.Service {
+	return &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			IPFamilyPolicy: policy,
+			IPFamilies:     families,
+		},
+	}
+}
+
+func ipFamilyPolicyPtr(policy corev1.IPFamilyPolicy) *corev1.IPFamilyPolicy {
+	return &policy
+}
+
+func TestServingCertInjectionToggle(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		secretAnnotations    map[string]string
+		expectSecretDeletion bool
+	}{
+		{
+			name:                 "generated secret deleted",
+			secretAnnotations:    map[string]string{originatingServiceNameKey: serviceName},
+			expectSecretDeletion: true,
+		},
+		{
+			name: "user secret kept",
+		},
+		{
+			name:              "secret generated for another service kept",
+			secretAnnotations: map[string]string{originatingServiceNameKey: "other-service"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl := fake.NewClientBuilder().Build()
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			}
+			svcName := types.NamespacedName{Name: serviceName, Namespace: test.TestNamespace}
+			secretKey := types.NamespacedName{Name: secretName, Namespace: test.TestNamespace}
+			ensure := func() *corev1.Service {
+				t.Helper()
+				if _, err := r.ensureService(ctx, nodeObs, test.TestNamespace); err != nil {
+					t.Fatalf("unexpected error received: %v", err)
+				}
+				if err := r.deleteGeneratedServingCertSecret(ctx, nodeObs, test.TestNamespace); err != nil {
+					t.Fatalf("unexpected error received: %v", err)
+				}
+				svc := &corev1.Service{}
+				if err := cl.Get(ctx, svcName, svc); err != nil {
+					t.Fatalf("failed to get service: %v", err)
+				}
+				return svc
+			}
+
+			svc := ensure()
+			if svc.Annotations[injectCertsKey] != secretName {
+				t.Fatalf("expected the serving cert to be requested, got annotations %v", svc.Annotations)
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: test.TestNamespace, Annotations: tc.secretAnnotations},
+			}
+			if err := cl.Create(ctx, secret); err != nil {
+				t.Fatalf("failed to create secret: %v", err)
+			}
+
+			nodeObs.Spec.DisableServingCertInjection = true
+			svc = ensure()
+			if _, found := svc.Annotations[injectCertsKey]; found {
+				t.Errorf("expected the serving cert annotation to be removed, got annotations %v", svc.Annotations)
+			}
+			err := cl.Get(ctx, secretKey, &corev1.Secret{})
+			if tc.expectSecretDeletion && !kerrors.IsNotFound(err) {
+				t.Errorf("expected the secret to be deleted, got %v", err)
+			}
+			if !tc.expectSecretDeletion && err != nil {
+				t.Errorf("expected the secret to be kept, got %v", err)
+			}
+
+			nodeObs.Spec.DisableServingCertInjection = false
+			svc = ensure()
+			if svc.Annotations[injectCertsKey] != secretName {
+				t.Errorf("expected the serving cert to be requested again, got annotations %v", svc.Annotations)
+			}
+		})
+	}
+}
+
+func TestServiceDryRun(t *testing.T) {
+	testCases := []struct {
+		name           string
+		change         func(*operatorv1alpha2.NodeObservability)
+		expectedFields []string
+	}{
+		{
+			name:   "no pending change",
+			change: func(*operatorv1alpha2.NodeObservability) {},
+		},
+		{
+			name:           "port changed",
+			change:         func(nodeObs *operatorv1alpha2.NodeObservability) { nodeObs.Spec.Port = pointer.Int32(9000) },
+			expectedFields: []string{"spec.ports"},
+		},
+		{
+			name:   "serving cert injection disabled",
+			change: func(nodeObs *operatorv1alpha2.NodeObservability) { nodeObs.Spec.DisableServingCertInjection = true },
+			expectedFields: []string{
+				"metadata.annotations[" + managedAnnotationsKey + "]",
+				"metadata.annotations[" + injectCertsKey + "]",
+			},
+		},
+		{
+			name: "not ready addresses unpublished",
+			change: func(nodeObs *operatorv1alpha2.NodeObservability) {
+				nodeObs.Spec.PublishNotReadyAddresses = pointer.Bool(false)
+			},
+			expectedFields: []string{"spec.publishNotReadyAddresses"},
+		},
+		{
+			name: "single stack requested",
+			change: func(nodeObs *operatorv1alpha2.NodeObservability) {
+				policy := corev1.IPFamilyPolicySingleStack
+				nodeObs.Spec.IPFamilyPolicy = &policy
+			},
+			expectedFields: []string{"spec.ipFamilyPolicy"},
+		},
+		{
+			name: "user label added",
+			change: func(nodeObs *operatorv1alpha2.NodeObservability) {
+				nodeObs.Spec.Labels = map[string]string{"team": "perf"}
+			},
+			expectedFields: []string{"spec.selector", "metadata.labels[team]", "metadata.annotations[" + managedLabelsKey + "]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cl := fake.NewClientBuilder().Build()
+			r := &NodeObservabilityReconciler{
+				Client:        cl,
+				Scheme:        test.Scheme,
+				Namespace:     test.TestNamespace,
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: record.NewFakeRecorder(100),
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			}
+			if _, err := r.ensureService(ctx, nodeObs, test.TestNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			created := &corev1.Service{}
+			if err := cl.Get(ctx, types.NamespacedName{Name: serviceName, Namespace: test.TestNamespace}, created); err != nil {
+				t.Fatalf("failed to get service: %v", err)
+			}
+
+			nodeObs.Annotations = map[string]string{operatorv1alpha2.ServiceDryRunAnnotation: "true"}
+			tc.change(nodeObs)
+			svc, err := r.ensureService(ctx, nodeObs, test.TestNamespace)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if svc.ResourceVersion != created.ResourceVersion {
+				t.Errorf("expected the service not to be updated during the dry run")
+			}
+			var fields []string
+			for _, change := range nodeObs.Status.PendingServiceChanges {
+				fields = append(fields, change.Field)
+			}
+			if diff := cmp.Diff(tc.expectedFields, fields); diff != "" {
+				t.Errorf("unexpected pending changes (-want +got):\n%s", diff)
+			}
+			pending := nodeObs.Status.PendingServiceChanges
+			if _, err := r.ensureService(ctx, nodeObs, test.TestNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if diff := cmp.Diff(pending, nodeObs.Status.PendingServiceChanges); diff != "" {
+				t.Errorf("expected the pending changes to be stable (-first +second):\n%s", diff)
+			}
+
+			delete(nodeObs.Annotations, operatorv1alpha2.ServiceDryRunAnnotation)
+			if _, err := r.ensureService(ctx, nodeObs, test.TestNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if nodeObs.Status.PendingServiceChanges != nil {
+				t.Errorf("expected the pending changes to be cleared, got %v", nodeObs.Status.PendingServiceChanges)
+			}
+			updated := &corev1.Service{}
+			if err := cl.Get(ctx, types.NamespacedName{Name: serviceName, Namespace: test.TestNamespace}, updated); err != nil {
+				t.Fatalf("failed to get service: %v", err)
+			}
+			desired := r.desiredService(nodeObs, test.TestNamespace)
+			if err := controllerutil.SetControllerReference(nodeObs, desired, r.Scheme); err != nil {
+				t.Fatalf("failed to set the controller reference: %v", err)
+			}
+			if changes := pendingServiceChanges(updated, desired); len(changes) != 0 {
+				t.Errorf("expected the held changes to be applied once the dry run is over, got %v", changes)
+			}
+		})
+	}
+}
+
+func TestPendingServiceChanges(t *testing.T) {
+	current := testControllerService(podName, test.TestNamespace, map[string]string{"app": "nodeobservability"}, nil)
+	current.Annotations = map[string]string{"owner": "team-a"}
+	desired := current.DeepCopy()
+	desired.Annotations = map[string]string{}
+	desired.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+	desired.Spec.Selector = map[string]string{"app": "agent", "component": "profiling"}
+	current.Spec.ClusterIP = "172.30.0.10"
+
+	expected := []operatorv1alpha2.ServiceChange{
+		{Field: "spec.clusterIP", Current: `"172.30.0.10"`, Desired: `"None"`},
+		{Field: "spec.selector", Current: `{"app":"nodeobservability"}`, Desired: `{"app":"agent","component":"profiling"}`},
+		{Field: "spec.sessionAffinity", Current: `"None"`, Desired: `"ClientIP"`},
+	}
+	if diff := cmp.Diff(expected, pendingServiceChanges(current, desired)); diff != "" {
+		t.Errorf("unexpected pending changes (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerifyServingCertSecret(t *testing.T) {
+	waitingSince := func(d time.Duration, reason string) *metav1.Condition {
+		return &metav1.Condition{
+			Type:               operatorv1alpha2.ServingCertAvailable,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-d)),
+		}
+	}
+	testCases := []struct {
+		name              string
+		disableInjection  bool
+		existingObjects   []runtime.Object
+		condition         *metav1.Condition
+		expectedAvailable bool
+		expectedStatus    metav1.ConditionStatus
+		expectedReason    string
+		expectedEvent     bool
+	}{
+		{
+			name:              "secret generated",
+			existingObjects:   []runtime.Object{testServingCertSecret()},
+			condition:         waitingSince(time.Minute, operatorv1alpha2.ReasonInProgress),
+			expectedAvailable: true,
+			expectedStatus:    metav1.ConditionTrue,
+			expectedReason:    operatorv1alpha2.ReasonReady,
+		},
+		{
+			name:              "injection disabled",
+			disableInjection:  true,
+			expectedAvailable: true,
+			expectedStatus:    metav1.ConditionTrue,
+			expectedReason:    operatorv1alpha2.ReasonDisabled,
+		},
+		{
+			name:           "secret requested",
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:           "secret awaited",
+			condition:      waitingSince(time.Minute, operatorv1alpha2.ReasonInProgress),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:           "secret not generated in time",
+			condition:      waitingSince(servingCertInjectionTimeout, operatorv1alpha2.ReasonInProgress),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonServingCertNotInjected,
+			expectedEvent:  true,
+		},
+		{
+			name:           "secret still not generated",
+			condition:      waitingSince(2*servingCertInjectionTimeout, operatorv1alpha2.ReasonServingCertNotInjected),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: operatorv1alpha2.ReasonServingCertNotInjected,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(100)
+			r := &NodeObservabilityReconciler{
+				Client:        fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build(),
+				Log:           zap.New(zap.UseDevMode(true)),
+				EventRecorder: recorder,
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Spec:       operatorv1alpha2.NodeObservabilitySpec{DisableServingCertInjection: tc.disableInjection},
+			}
+			if tc.condition != nil {
+				nodeObs.Status.Conditions = []metav1.Condition{*tc.condition}
+			}
+
+			available, err := r.verifyServingCertSecret(context.TODO(), nodeObs, test.TestNamespace)
+			if err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if available != tc.expectedAvailable {
+				t.Errorf("expected available %t, got %t", tc.expectedAvailable, available)
+			}
+			cond := nodeObs.Status.GetCondition(operatorv1alpha2.ServingCertAvailable)
+			if cond == nil || cond.Status != tc.expectedStatus || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected condition %s/%s, got %v", tc.expectedStatus, tc.expectedReason, cond)
+			}
+			if tc.condition != nil && tc.condition.Reason == tc.expectedReason && !cond.LastTransitionTime.Equal(&tc.condition.LastTransitionTime) {
+				t.Errorf("expected the condition not to transition, got %v", cond.LastTransitionTime)
+			}
+			if events := len(recorder.Events); (events != 0) != tc.expectedEvent {
+				t.Errorf("expected event %t, got %d events", tc.expectedEvent, events)
+			}
+		})
+	}
+}
+
+func TestEnsureServingCertSecretOwner(t *testing.T) {
+	nodeObs := &operatorv1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", UID: "nodeobs-uid"},
+	}
+	// set by the service CA operator, must be kept
+	serviceRef := metav1.OwnerReference{APIVersion: "v1", Kind: "Service", Name: serviceName, UID: "svc-uid"}
+	nodeObsRef := metav1.OwnerReference{
+		APIVersion: operatorv1alpha2.GroupVersion.String(),
+		Kind:       "NodeObservability",
+		Name:       nodeObs.Name,
+		UID:        nodeObs.UID,
+	}
+	generated := func(refs ...metav1.OwnerReference) *corev1.Secret {
+		secret := testServingCertSecret()
+		secret.Annotations = map[string]string{originatingServiceNameKey: serviceName}
+		secret.OwnerReferences = refs
+		return secret
+	}
+	testCases := []struct {
+		name             string
+		disableInjection bool
+		existingSecret   *corev1.Secret
+		expectedRefs     []metav1.OwnerReference
+		expectedUpdate   bool
+	}{
+		{
+			name:           "generated secret owned",
+			existingSecret: generated(serviceRef),
+			expectedRefs:   []metav1.OwnerReference{serviceRef, nodeObsRef},
+			expectedUpdate: true,
+		},
+		{
+			name:           "generated secret already owned",
+			existingSecret: generated(serviceRef, nodeObsRef),
+			expectedRefs:   []metav1.OwnerReference{serviceRef, nodeObsRef},
+		},
+		{
+			name:           "user secret untouched",
+			existingSecret: testServingCertSecret(),
+		},
+		{
+			name:             "injection disabled",
+			disableInjection: true,
+			existingSecret:   generated(),
+		},
+		{
+			name: "secret not generated yet",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(test.Scheme)
+			if tc.existingSecret != nil {
+				builder = builder.WithObjects(tc.existingSecret)
+			}
+			cl := builder.Build()
+			r := &NodeObservabilityReconciler{
+				Client: cl,
+				Scheme: test.Scheme,
+				Log:    zap.New(zap.UseDevMode(true)),
+			}
+			nodeObs := nodeObs.DeepCopy()
+			nodeObs.Spec.DisableServingCertInjection = tc.disableInjection
+
+			if err := r.ensureServingCertSecretOwner(context.TODO(), nodeObs, test.TestNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if tc.existingSecret == nil {
+				return
+			}
+			got := &corev1.Secret{}
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: test.TestNamespace}, got); err != nil {
+				t.Fatalf("failed to get secret: %v", err)
+			}
+			// the garbage collector deletes the secret with its nodeobservability owner
+			if diff := cmp.Diff(tc.expectedRefs, got.OwnerReferences, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected owner references (-want +got):\n%s", diff)
+			}
+			if updated := got.ResourceVersion != "999"; updated != tc.expectedUpdate {
+				t.Errorf("expected update %t, got resource version %s", tc.expectedUpdate, got.ResourceVersion)
+			}
+		})
+	}
+}
+
+func testServingCertSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: test.TestNamespace},
+	}
+}
@@ -13,10 +13,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	opctrl "github.com/openshift/node-observability-operator/pkg/operator/controller"
 )
 
 const (
-	serviceAccountName = "node-observability-agent"
+	serviceAccountName = opctrl.AgentServiceAccountName
 )
 
 // ensureServiceAccount ensures that the serviceaccount exists
@@ -0,0 +1,210 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	serviceMonitorName = podName
+	// serviceCAFile is the path of the service CA bundle
+	// mounted into the Prometheus pods of the cluster monitoring stack
+	serviceCAFile = "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt"
+	// #nosec G101: Potential hardcoded credentials; path to token, not the content itself
+	bearerTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	metricsPath     = "/metrics"
+)
+
+var (
+	// serviceMonitorGVK is the group version kind of the Prometheus Operator's ServiceMonitor.
+	// The unstructured representation is used to avoid the hard dependency on the Prometheus Operator API.
+	serviceMonitorGVK = schema.GroupVersionKind{
+		Group:   "monitoring.coreos.com",
+		Version: "v1",
+		Kind:    "ServiceMonitor",
+	}
+)
+
+// ensureServiceMonitor ensures that the servicemonitor exists if the monitoring is enabled,
+// and that it's removed otherwise. A servicemonitor with the same name
+// which is not controlled by the NodeObservability is neither updated nor removed.
+func (r *NodeObservabilityReconciler) ensureServiceMonitor(ctx context.Context, nodeObs *v1alpha2.NodeObservability, ns string) (*unstructured.Unstructured, error) {
+	nameSpace := types.NamespacedName{Namespace: ns, Name: serviceMonitorName}
+
+	if !nodeObs.Spec.EnableMonitoring {
+		return nil, r.deleteServiceMonitor(ctx, nodeObs, nameSpace)
+	}
+
+	desired := r.desiredServiceMonitor(nodeObs, ns)
+	if err := controllerutil.SetControllerReference(nodeObs, desired, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set the controller reference for servicemonitor %q: %w", nameSpace, err)
+	}
+
+	current, err := r.currentServiceMonitor(ctx, nameSpace)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get servicemonitor %q due to: %w", nameSpace, err)
+	} else if err != nil && errors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil {
+			return nil, fmt.Errorf("failed to create servicemonitor %q: %w", nameSpace, err)
+		}
+		r.Log.V(1).Info("successfully created servicemonitor", "servicemonitor.name", nameSpace.Name, "servicemonitor.namespace", nameSpace.Namespace)
+		return r.currentServiceMonitor(ctx, nameSpace)
+	}
+
+	if !metav1.IsControlledBy(current, nodeObs) {
+		r.Log.Info("servicemonitor is not controlled by the NodeObservability, leaving it as is", "servicemonitor.name", nameSpace.Name, "servicemonitor.namespace", nameSpace.Namespace)
+		return current, nil
+	}
+
+	updated, err := r.updateServiceMonitor(ctx, current, desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update servicemonitor %q: %w", nameSpace, err)
+	}
+
+	if updated {
+		current, err = r.currentServiceMonitor(ctx, nameSpace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get existing servicemonitor %q: %w", nameSpace, err)
+		}
+		r.Log.V(1).Info("successfully updated servicemonitor", "servicemonitor.name", nameSpace.Name, "servicemonitor.namespace", nameSpace.Namespace)
+	}
+	return current, nil
+}
+
+// currentServiceMonitor gets the current servicemonitor
+func (r *NodeObservabilityReconciler) currentServiceMonitor(ctx context.Context, nameSpace types.NamespacedName) (*unstructured.Unstructured, error) {
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(serviceMonitorGVK)
+	if err := r.Get(ctx, nameSpace, sm); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// updateServiceMonitor updates the servicemonitor if its spec or owner reference differ from the desired ones
+func (r *NodeObservabilityReconciler) updateServiceMonitor(ctx context.Context, current, desired *unstructured.Unstructured) (bool, error) {
+	updatedSM := current.DeepCopy()
+	var updated bool
+
+	if !cmp.Equal(current.GetOwnerReferences(), desired.GetOwnerReferences()) {
+		updatedSM.SetOwnerReferences(desired.GetOwnerReferences())
+		updated = true
+	}
+
+	if !equality.Semantic.DeepEqual(current.Object["spec"], desired.Object["spec"]) {
+		updatedSM.Object["spec"] = desired.Object["spec"]
+		updated = true
+	}
+
+	if updated {
+		if err := r.Update(ctx, updatedSM); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// deleteServiceMonitor deletes the servicemonitor if it exists and is controlled by the NodeObservability.
+// The absence of the ServiceMonitor CRD is not considered as an error.
+func (r *NodeObservabilityReconciler) deleteServiceMonitor(ctx context.Context, nodeObs *v1alpha2.NodeObservability, nameSpace types.NamespacedName) error {
+	sm, err := r.currentServiceMonitor(ctx, nameSpace)
+	if err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get servicemonitor %q: %w", nameSpace, err)
+	}
+	if !metav1.IsControlledBy(sm, nodeObs) {
+		r.Log.V(1).Info("servicemonitor is not controlled by the NodeObservability, not deleting it", "servicemonitor.name", nameSpace.Name, "servicemonitor.namespace", nameSpace.Namespace)
+		return nil
+	}
+	// the servicemonitor recreated meanwhile is not deleted
+	uid := sm.GetUID()
+	if err := r.Delete(ctx, sm, client.Preconditions{UID: &uid}); err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete servicemonitor %q: %w", nameSpace, err)
+	}
+	r.Log.V(1).Info("deleted servicemonitor", "servicemonitor.name", nameSpace.Name, "servicemonitor.namespace", nameSpace.Namespace)
+	return nil
+}
+
+// desiredServiceMonitor returns a servicemonitor object targeting the agent service
+func (r *NodeObservabilityReconciler) desiredServiceMonitor(nodeObs *v1alpha2.NodeObservability, ns string) *unstructured.Unstructured {
+	ls := labelsForNodeObservability(nodeObs.Name)
+
+	matchLabels := map[string]interface{}{}
+	for k, v := range ls {
+		matchLabels[k] = v
+	}
+	labels := map[string]interface{}{}
+	for k, v := range ls {
+		labels[k] = v
+	}
+
+	sm := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      serviceMonitorName,
+				"namespace": ns,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"endpoints": []interface{}{
+					map[string]interface{}{
+						"bearerTokenFile": bearerTokenFile,
+						"interval":        "30s",
+						"path":            metricsPath,
+						"scheme":          "https",
+						"targetPort":      int64(agentPort(nodeObs)),
+						"tlsConfig": map[string]interface{}{
+							"caFile":     serviceCAFile,
+							"serverName": fmt.Sprintf("%s.%s.svc", serviceName, ns),
+						},
+					},
+				},
+				"namespaceSelector": map[string]interface{}{
+					"matchNames": []interface{}{ns},
+				},
+				"selector": map[string]interface{}{
+					"matchLabels": matchLabels,
+				},
+			},
+		},
+	}
+	sm.SetGroupVersionKind(serviceMonitorGVK)
+	return sm
+}
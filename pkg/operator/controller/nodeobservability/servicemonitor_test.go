@@ -0,0 +1,193 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeobservabilitycontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func testServiceMonitor(targetPort int64) *unstructured.Unstructured {
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(serviceMonitorGVK)
+	sm.SetName(serviceMonitorName)
+	sm.SetNamespace(test.TestNamespace)
+	_ = unstructured.SetNestedSlice(sm.Object, []interface{}{
+		map[string]interface{}{
+			"scheme":     "https",
+			"targetPort": targetPort,
+		},
+	}, "spec", "endpoints")
+	return sm
+}
+
+const testNodeObservabilityUID = types.UID("test-uid")
+
+// ownedServiceMonitor returns a servicemonitor controlled by the NodeObservability of the tests
+func ownedServiceMonitor(targetPort int64) *unstructured.Unstructured {
+	sm := testServiceMonitor(targetPort)
+	sm.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			APIVersion: operatorv1alpha2.GroupVersion.String(),
+			Kind:       "NodeObservability",
+			Name:       "test",
+			UID:        testNodeObservabilityUID,
+			Controller: pointer.Bool(true),
+		},
+	})
+	return sm
+}
+
+func TestEnsureServiceMonitor(t *testing.T) {
+	testCases := []struct {
+		name               string
+		existingObjects    []client.Object
+		enableMonitoring   bool
+		expectedTargetPort int64
+		expectedAbsent     bool
+		expectedForeign    bool
+		expectedDeletes    int
+	}{
+		{
+			name:           "monitoring disabled, no servicemonitor",
+			expectedAbsent: true,
+		},
+		{
+			name:               "monitoring enabled, new servicemonitor",
+			enableMonitoring:   true,
+			expectedTargetPort: port,
+		},
+		{
+			name:               "monitoring enabled, existing servicemonitor modified",
+			existingObjects:    []client.Object{ownedServiceMonitor(9440)},
+			enableMonitoring:   true,
+			expectedTargetPort: port,
+		},
+		{
+			name:            "monitoring disabled, existing servicemonitor removed",
+			existingObjects: []client.Object{ownedServiceMonitor(port)},
+			expectedAbsent:  true,
+			expectedDeletes: 1,
+		},
+		{
+			name:               "monitoring enabled, servicemonitor of the user not adopted",
+			existingObjects:    []client.Object{testServiceMonitor(9440)},
+			enableMonitoring:   true,
+			expectedTargetPort: 9440,
+			expectedForeign:    true,
+		},
+		{
+			name:               "monitoring disabled, servicemonitor of the user kept",
+			existingObjects:    []client.Object{testServiceMonitor(9440)},
+			expectedTargetPort: 9440,
+			expectedForeign:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := &deleteCountingClient{Client: fake.NewClientBuilder().WithScheme(test.Scheme).WithObjects(tc.existingObjects...).Build()}
+			r := &NodeObservabilityReconciler{
+				Client:    cl,
+				Scheme:    test.Scheme,
+				Namespace: test.TestNamespace,
+				Log:       zap.New(zap.UseDevMode(true)),
+			}
+			nodeObs := &operatorv1alpha2.NodeObservability{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					UID:  testNodeObservabilityUID,
+				},
+				Spec: operatorv1alpha2.NodeObservabilitySpec{
+					EnableMonitoring: tc.enableMonitoring,
+				},
+			}
+
+			if _, err := r.ensureServiceMonitor(context.TODO(), nodeObs, test.TestNamespace); err != nil {
+				t.Fatalf("unexpected error received: %v", err)
+			}
+			if cl.deletes != tc.expectedDeletes {
+				t.Errorf("expected %d servicemonitor deletion(s), got %d", tc.expectedDeletes, cl.deletes)
+			}
+
+			sm := &unstructured.Unstructured{}
+			sm.SetGroupVersionKind(serviceMonitorGVK)
+			err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.TestNamespace, Name: serviceMonitorName}, sm)
+			if tc.expectedAbsent {
+				if !kerrors.IsNotFound(err) {
+					t.Fatalf("expected servicemonitor to be absent, got error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get servicemonitor: %v", err)
+			}
+
+			if tc.expectedForeign {
+				if len(sm.GetOwnerReferences()) != 0 {
+					t.Errorf("expected the servicemonitor of the user not to be adopted, got owners %v", sm.GetOwnerReferences())
+				}
+				endpoints, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+				if got := endpoints[0].(map[string]interface{})["targetPort"]; got != tc.expectedTargetPort {
+					t.Errorf("expected target port %d to be kept, got %v", tc.expectedTargetPort, got)
+				}
+				return
+			}
+
+			desired := r.desiredServiceMonitor(nodeObs, test.TestNamespace)
+			if !equality.Semantic.DeepEqual(sm.Object["spec"], desired.Object["spec"]) {
+				t.Errorf("servicemonitor has unexpected spec:\n%s", cmp.Diff(sm.Object["spec"], desired.Object["spec"]))
+			}
+			endpoints, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+			if len(endpoints) != 1 {
+				t.Fatalf("expected 1 endpoint, got %d", len(endpoints))
+			}
+			if got := endpoints[0].(map[string]interface{})["targetPort"]; got != tc.expectedTargetPort {
+				t.Errorf("expected target port %d, got %v", tc.expectedTargetPort, got)
+			}
+			if len(sm.GetOwnerReferences()) != 1 || sm.GetOwnerReferences()[0].Name != nodeObs.Name {
+				t.Errorf("expected servicemonitor to be owned by %q, got %v", nodeObs.Name, sm.GetOwnerReferences())
+			}
+		})
+	}
+}
+
+// deleteCountingClient counts the deletions, the absent objects are not expected to be deleted.
+type deleteCountingClient struct {
+	client.Client
+	deletes int
+}
+
+func (c *deleteCountingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.deletes++
+	return c.Client.Delete(ctx, obj, opts...)
+}
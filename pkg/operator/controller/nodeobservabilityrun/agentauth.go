@@ -0,0 +1,107 @@
+package nodeobservabilityruncontroller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// agentTokenKey is the key of the bearer token
+	// in the secret referenced by the agent auth of the run
+	agentTokenKey = "token"
+	// redactedValue replaces the values of the secret in the responses of the agents
+	redactedValue = "[REDACTED]"
+)
+
+// agentAuthHeader returns the header authenticating the operator to the proxy in front of the agents,
+// nil if the run has no agent auth. The values are read from the secret referenced by the run.
+func (r *NodeObservabilityRunReconciler) agentAuthHeader(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (http.Header, error) {
+	auth := instance.Spec.AgentAuth
+	if auth == nil {
+		return nil, nil
+	}
+	name := auth.SecretRef.Name
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get agent auth secret %q: %w", name, err)
+	}
+	header := http.Header{}
+	if token := strings.TrimSpace(string(secret.Data[agentTokenKey])); token != "" {
+		header.Set(authHeader, fmt.Sprintf("Bearer %s", token))
+	}
+	for headerName, key := range auth.Headers {
+		value, found := secret.Data[key]
+		if !found {
+			return nil, fmt.Errorf("agent auth secret %q must contain the %s key of the %s header", name, key, headerName)
+		}
+		header.Set(headerName, strings.TrimSpace(string(value)))
+	}
+	if len(header) == 0 {
+		return nil, fmt.Errorf("agent auth secret %q must contain the %s key or the run must set headers", name, agentTokenKey)
+	}
+	return header, nil
+}
+
+// authTransport sets the header of the agent auth on the requests to the agents,
+// the bearer token of the operator is replaced by the one of the header if any.
+type authTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+// withAgentAuth returns the given transport authenticating the requests with the given header,
+// the transport is returned as is if there's no header.
+func withAgentAuth(transport http.RoundTripper, header http.Header) http.RoundTripper {
+	if len(header) == 0 {
+		return transport
+	}
+	return &authTransport{base: transport, header: header}
+}
+
+// RoundTrip implements http.RoundTripper.
+// The failed responses are redacted as the proxy may echo the headers of the request,
+// their body ends up in the logs and the status of the run.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode == http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(t.redact(body)))
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+// redact replaces the values of the header in the given body.
+func (t *authTransport) redact(body []byte) []byte {
+	for _, values := range t.header {
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			body = bytes.ReplaceAll(body, []byte(value), []byte(redactedValue))
+			// the bearer token may be echoed without its scheme
+			if token := strings.TrimPrefix(value, "Bearer "); token != value && token != "" {
+				body = bytes.ReplaceAll(body, []byte(token), []byte(redactedValue))
+			}
+		}
+	}
+	return body
+}
@@ -0,0 +1,198 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+const testAgentAuthSecretName = "agent-auth"
+
+func TestAgentAuthHeader(t *testing.T) {
+	cases := []struct {
+		name            string
+		auth            *operatorv1alpha2.AgentAuth
+		existingObjects []runtime.Object
+		errExpected     bool
+		expectedHeader  http.Header
+	}{
+		{
+			name: "no agent auth",
+		},
+		{
+			name:            "bearer token",
+			auth:            &operatorv1alpha2.AgentAuth{SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName}},
+			existingObjects: []runtime.Object{testAgentAuthSecret(map[string]string{agentTokenKey: "proxy-token\n"})},
+			expectedHeader:  http.Header{"Authorization": []string{"Bearer proxy-token"}},
+		},
+		{
+			name: "bearer token and headers",
+			auth: &operatorv1alpha2.AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName},
+				Headers:   map[string]string{"x-proxy-tenant": "tenant"},
+			},
+			existingObjects: []runtime.Object{testAgentAuthSecret(map[string]string{agentTokenKey: "proxy-token", "tenant": "node-team"})},
+			expectedHeader: http.Header{
+				"Authorization":  []string{"Bearer proxy-token"},
+				"X-Proxy-Tenant": []string{"node-team"},
+			},
+		},
+		{
+			name: "headers only",
+			auth: &operatorv1alpha2.AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName},
+				Headers:   map[string]string{"X-Proxy-Token": "proxy"},
+			},
+			existingObjects: []runtime.Object{testAgentAuthSecret(map[string]string{"proxy": "proxy-token"})},
+			expectedHeader:  http.Header{"X-Proxy-Token": []string{"proxy-token"}},
+		},
+		{
+			name:        "missing secret",
+			auth:        &operatorv1alpha2.AgentAuth{SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName}},
+			errExpected: true,
+		},
+		{
+			name: "missing header key",
+			auth: &operatorv1alpha2.AgentAuth{
+				SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName},
+				Headers:   map[string]string{"X-Proxy-Tenant": "tenant"},
+			},
+			existingObjects: []runtime.Object{testAgentAuthSecret(map[string]string{agentTokenKey: "proxy-token"})},
+			errExpected:     true,
+		},
+		{
+			name:            "empty secret",
+			auth:            &operatorv1alpha2.AgentAuth{SecretRef: corev1.LocalObjectReference{Name: testAgentAuthSecretName}},
+			existingObjects: []runtime.Object{testAgentAuthSecret(nil)},
+			errExpected:     true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := NodeObservabilityRunReconciler{Client: cl}
+
+			run := testNodeObservabilityRun()
+			run.Spec.AgentAuth = tc.auth
+			header, err := r.agentAuthHeader(context.Background(), run)
+			if tc.errExpected {
+				if err == nil {
+					t.Fatalf("expected error but got none")
+				}
+				if strings.Contains(err.Error(), "proxy-token") {
+					t.Errorf("expected the token to be absent from the error, got %q", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(header, tc.expectedHeader) {
+				t.Errorf("expected header %v, got %v", tc.expectedHeader, header)
+			}
+		})
+	}
+}
+
+func TestAuthTransport(t *testing.T) {
+	cases := []struct {
+		name        string
+		header      http.Header
+		status      int
+		errExpected bool
+		// expectedAuthorization is the Authorization header received by the agent
+		expectedAuthorization string
+		expectedTenant        string
+	}{
+		{
+			name:                  "token of the operator",
+			status:                http.StatusOK,
+			expectedAuthorization: "Bearer operator-token",
+		},
+		{
+			name: "token and headers of the agent auth",
+			header: http.Header{
+				"Authorization":  []string{"Bearer proxy-token"},
+				"X-Proxy-Tenant": []string{"node-team"},
+			},
+			status:                http.StatusOK,
+			expectedAuthorization: "Bearer proxy-token",
+			expectedTenant:        "node-team",
+		},
+		{
+			name: "echoed token redacted",
+			header: http.Header{
+				"Authorization":  []string{"Bearer proxy-token"},
+				"X-Proxy-Tenant": []string{"node-team"},
+			},
+			status:                http.StatusUnauthorized,
+			errExpected:           true,
+			expectedAuthorization: "Bearer proxy-token",
+			expectedTenant:        "node-team",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var authorization, tenant string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				authorization = req.Header.Get("Authorization")
+				tenant = req.Header.Get("X-Proxy-Tenant")
+				w.WriteHeader(tc.status)
+				// the proxy echoes the token and the headers of the rejected requests
+				fmt.Fprintf(w, "rejected %s for tenant %s, token %s", authorization, tenant, strings.TrimPrefix(authorization, "Bearer "))
+			}))
+			defer server.Close()
+
+			r := NodeObservabilityRunReconciler{AuthToken: []byte("operator-token")}
+			tr := withAgentAuth(server.Client().Transport, tc.header)
+			_, err := r.httpGet(context.Background(), tr, time.Minute, server.URL, nil)
+			if authorization != tc.expectedAuthorization {
+				t.Errorf("expected authorization %q, got %q", tc.expectedAuthorization, authorization)
+			}
+			if tenant != tc.expectedTenant {
+				t.Errorf("expected tenant %q, got %q", tc.expectedTenant, tenant)
+			}
+			if !tc.errExpected {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			for _, secret := range []string{"proxy-token", "node-team"} {
+				if strings.Contains(err.Error(), secret) {
+					t.Errorf("expected %q to be redacted from the error, got %q", secret, err)
+				}
+			}
+			if !strings.Contains(err.Error(), redactedValue) {
+				t.Errorf("expected the error to contain %q, got %q", redactedValue, err)
+			}
+		})
+	}
+}
+
+func testAgentAuthSecret(data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testAgentAuthSecretName, Namespace: namespace},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
@@ -0,0 +1,47 @@
+package nodeobservabilityruncontroller
+
+import (
+	"path"
+	"strings"
+	"text/template"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
+)
+
+// artifactKey returns the key of the given profile of the node in the storage backend,
+// rendered with the artifact key template of the run and suffixed with .gz if the profile is gzipped.
+func artifactKey(tmpl *template.Template, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, node, artifact string) (string, error) {
+	data := nodeobservabilityv1alpha2.ArtifactKeyData{
+		Namespace: instance.Namespace,
+		RunName:   instance.Name,
+		Node:      node,
+		Type:      strings.TrimSuffix(artifact, pprofExt),
+	}
+	if instance.Status.StartTimestamp != nil {
+		data.Timestamp = instance.Status.StartTimestamp.Time
+	}
+	key, err := nodeobservabilityv1alpha2.RenderArtifactKey(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	if gzipsArtifacts(instance) {
+		key += collector.GzipExt
+	}
+	return key, nil
+}
+
+// commonDir returns the directory shared by all the given keys,
+// an empty string if they are in different directories.
+func commonDir(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	dir := path.Dir(keys[0])
+	for _, key := range keys[1:] {
+		if path.Dir(key) != dir {
+			return ""
+		}
+	}
+	return dir
+}
@@ -0,0 +1,145 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// trackAgentRequests returns the context of the requests sent to the agents of the run,
+// aborted by abortAgentRequests once the run is cancelled. The returned function
+// releases the context when the reconciliation ends.
+func (r *NodeObservabilityRunReconciler) trackAgentRequests(ctx context.Context, key types.NamespacedName) (context.Context, func()) {
+	agentCtx, cancel := context.WithCancel(ctx)
+	r.agentRequests.Store(key, cancel)
+	return agentCtx, func() {
+		r.agentRequests.Delete(key)
+		cancel()
+	}
+}
+
+// abortAgentRequests aborts the requests in flight to the agents of the run, if any.
+func (r *NodeObservabilityRunReconciler) abortAgentRequests(key types.NamespacedName) {
+	if cancel, ok := r.agentRequests.Load(key); ok {
+		r.Log.V(1).Info("Aborting the requests to the agents of the cancelled run", "Run", key)
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// cancelHandler aborts the requests in flight to the agents as soon as a run is cancelled,
+// without waiting for the reconciliation sending them to finish.
+// The cancelled run is enqueued by the watch of the runs.
+func (r *NodeObservabilityRunReconciler) cancelHandler() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			oldRun, ok := e.ObjectOld.(*nodeobservabilityv1alpha2.NodeObservabilityRun)
+			if !ok {
+				return
+			}
+			newRun, ok := e.ObjectNew.(*nodeobservabilityv1alpha2.NodeObservabilityRun)
+			if !ok || oldRun.Spec.Cancel || !newRun.Spec.Cancel {
+				return
+			}
+			r.abortAgentRequests(types.NamespacedName{Name: newRun.Name, Namespace: newRun.Namespace})
+		},
+	}
+}
+
+// cancelRun marks the run cancelled before it started as finished without profiling the nodes.
+func (r *NodeObservabilityRunReconciler) cancelRun(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	t := metav1.Now()
+	instance.Status.FinishedTimestamp = &t
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonCancelled, "Profiling query cancelled before it started")
+	r.recordRunCancelled(instance)
+}
+
+// cancelAgents moves the agents which haven't finished the profiling,
+// running, dispatched or pending, to the cancelled agents.
+// The agents which finished keep their results.
+func cancelAgents(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	var kept []nodeobservabilityv1alpha2.AgentNode
+	for _, agent := range instance.Status.Agents {
+		if agent.Result == nodeobservabilityv1alpha2.AgentRunning || agent.Result == nodeobservabilityv1alpha2.AgentDispatched {
+			instance.Status.CancelledAgents = append(instance.Status.CancelledAgents, cancelAgent(agent))
+			continue
+		}
+		kept = append(kept, agent)
+	}
+	instance.Status.Agents = kept
+	for _, agent := range instance.Status.PendingAgents {
+		instance.Status.CancelledAgents = append(instance.Status.CancelledAgents, cancelAgent(agent))
+	}
+	instance.Status.PendingAgents = nil
+}
+
+// cancelAgent returns the given agent marked as cancelled.
+func cancelAgent(agent nodeobservabilityv1alpha2.AgentNode) nodeobservabilityv1alpha2.AgentNode {
+	agent.Result = nodeobservabilityv1alpha2.AgentCancelled
+	t := metav1.Now()
+	agent.FinishedTimestamp = &t
+	return agent
+}
+
+// cancelled returns true if the run was cancelled before all its nodes finished the profiling.
+func cancelled(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	cond := instance.Status.GetCondition(nodeobservabilityv1alpha2.DebugFinished)
+	return cond != nil && cond.Reason == nodeobservabilityv1alpha2.ReasonCancelled
+}
+
+// profilingAborted returns true if the profiling of the run was stopped
+// by its timeout or its cancellation, the condition is kept until the run finishes.
+func profilingAborted(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	cond := instance.Status.GetCondition(nodeobservabilityv1alpha2.DebugFinished)
+	return cond != nil && (cond.Reason == nodeobservabilityv1alpha2.ReasonFailed || cond.Reason == nodeobservabilityv1alpha2.ReasonCancelled)
+}
+
+// recordRunCancelled emits an event naming the client which cancelled the run
+// and summarizing the nodes on which the profiling finished before.
+func (r *NodeObservabilityRunReconciler) recordRunCancelled(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	by := "Run cancelled"
+	if manager := cancelledBy(instance); manager != "" {
+		by = fmt.Sprintf("Run cancelled by %s", manager)
+	}
+	msg := fmt.Sprintf("%s: profiling succeeded on %d node(s), failed on %d node(s) and was cancelled on %d node(s)",
+		by, succeededAgents(instance), len(instance.Status.FailedAgents), len(instance.Status.CancelledAgents))
+	r.EventRecorder.Event(instance, corev1.EventTypeNormal, nodeobservabilityv1alpha2.ReasonCancelled, msg)
+}
+
+// cancelledBy returns the field manager which last set the cancel field of the run,
+// e.g. kubectl-patch, empty if not known.
+func cancelledBy(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	var manager string
+	var latest time.Time
+	for _, entry := range instance.ManagedFields {
+		if entry.FieldsV1 == nil || entry.Subresource != "" {
+			continue
+		}
+		var fields struct {
+			Spec map[string]json.RawMessage `json:"f:spec"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields.Spec["f:cancel"]; !ok {
+			continue
+		}
+		if entry.Time != nil && entry.Time.Time.Before(latest) {
+			continue
+		}
+		if entry.Time != nil {
+			latest = entry.Time.Time
+		}
+		manager = entry.Manager
+	}
+	return manager
+}
@@ -0,0 +1,171 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestReconcileCancel(t *testing.T) {
+	doneServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer doneServer.Close()
+	busyServer := httptest.NewTLSServer(http.HandlerFunc(conflict))
+	defer busyServer.Close()
+
+	defaultTransport := transport
+	transport = doneServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	doneAgent := withResult(testAgentNode("done", doneServer), operatorv1alpha2.AgentSucceeded)
+	busyAgent := withResult(testAgentNode("busy", busyServer), operatorv1alpha2.AgentRunning)
+	pendingAgent := testAgentNode("pending", busyServer)
+	failedAgent := withResult(testAgentNode("failed", busyServer), operatorv1alpha2.AgentFailed)
+	now := metav1.Now()
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name                    string
+		status                  operatorv1alpha2.NodeObservabilityRunStatus
+		expectedPhase           operatorv1alpha2.NodeObservabilityRunPhase
+		expectedReason          string
+		expectedAgents          []operatorv1alpha2.AgentNode
+		expectedCancelledAgents []operatorv1alpha2.AgentNode
+		expectedEvent           string
+	}{
+		{
+			name:           "cancelled before start",
+			expectedPhase:  operatorv1alpha2.RunCancelled,
+			expectedReason: operatorv1alpha2.ReasonCancelled,
+			expectedEvent:  "Run cancelled by kubectl-patch: profiling succeeded on 0 node(s), failed on 0 node(s) and was cancelled on 0 node(s)",
+		},
+		{
+			name: "unfinished agents cancelled",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{doneAgent, busyAgent},
+				PendingAgents:  []operatorv1alpha2.AgentNode{pendingAgent},
+			},
+			expectedPhase:  operatorv1alpha2.RunCancelled,
+			expectedReason: operatorv1alpha2.ReasonCancelled,
+			expectedAgents: []operatorv1alpha2.AgentNode{withAgentArtifacts(doneAgent)},
+			expectedCancelledAgents: []operatorv1alpha2.AgentNode{
+				withResult(busyAgent, operatorv1alpha2.AgentCancelled),
+				withResult(pendingAgent, operatorv1alpha2.AgentCancelled),
+			},
+			expectedEvent: "Run cancelled by kubectl-patch: profiling succeeded on 1 node(s), failed on 0 node(s) and was cancelled on 2 node(s)",
+		},
+		{
+			name: "timed out run kept as failed",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{doneAgent},
+				FailedAgents:   []operatorv1alpha2.AgentNode{failedAgent},
+				ConditionalStatus: operatorv1alpha2.ConditionalStatus{
+					Conditions: []metav1.Condition{{Type: operatorv1alpha2.DebugFinished, Status: metav1.ConditionFalse, Reason: operatorv1alpha2.ReasonFailed}},
+				},
+			},
+			expectedPhase:  operatorv1alpha2.RunFailed,
+			expectedReason: operatorv1alpha2.ReasonFailed,
+			expectedAgents: []operatorv1alpha2.AgentNode{withAgentArtifacts(doneAgent)},
+			expectedEvent:  "Profiling succeeded on 1 node(s) and failed on 1 node(s)",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(tc.status)
+			run.Spec.Cancel = true
+			run.ManagedFields = []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl-create", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:nodeObservabilityRef":{}}}`)}},
+				{Manager: "kubectl-patch", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:cancel":{}}}`)}},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: recorder,
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if !finished(got) {
+				t.Fatalf("expected run to be finished")
+			}
+			if got.Status.Phase != tc.expectedPhase {
+				t.Fatalf("expected phase %q, got %q", tc.expectedPhase, got.Status.Phase)
+			}
+			cond := got.Status.GetCondition(operatorv1alpha2.DebugFinished)
+			if cond == nil || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected %s condition with reason %q, got %v", operatorv1alpha2.DebugFinished, tc.expectedReason, cond)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.Agents), tc.expectedAgents) {
+				t.Fatalf("expected agents %v, got %v", tc.expectedAgents, got.Status.Agents)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.CancelledAgents), tc.expectedCancelledAgents) {
+				t.Fatalf("expected cancelled agents %v, got %v", tc.expectedCancelledAgents, got.Status.CancelledAgents)
+			}
+			if len(got.Status.PendingAgents) != 0 {
+				t.Fatalf("expected no pending agents, got %v", got.Status.PendingAgents)
+			}
+			select {
+			case e := <-recorder.Events:
+				if !strings.HasSuffix(e, tc.expectedEvent) {
+					t.Fatalf("expected event %q, got %q", tc.expectedEvent, e)
+				}
+			default:
+				t.Fatalf("expected event %q, got none", tc.expectedEvent)
+			}
+		})
+	}
+}
+
+func TestCancelHandler(t *testing.T) {
+	key := types.NamespacedName{Name: name, Namespace: namespace}
+	r := &NodeObservabilityRunReconciler{Log: zap.New(zap.UseDevMode(true))}
+	agentCtx, untrack := r.trackAgentRequests(context.Background(), key)
+	defer untrack()
+
+	running := testNodeObservabilityRun()
+	cancelled := running.DeepCopy()
+	cancelled.Spec.Cancel = true
+	h := r.cancelHandler()
+
+	h.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running.DeepCopy()}, nil)
+	if agentCtx.Err() != nil {
+		t.Fatalf("expected the requests of the run to be kept")
+	}
+	h.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: cancelled}, nil)
+	select {
+	case <-agentCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected the requests of the cancelled run to be aborted")
+	}
+
+	untrack()
+	// the run is no longer reconciled, nothing to abort
+	h.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: cancelled}, nil)
+}
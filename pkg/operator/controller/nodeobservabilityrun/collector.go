@@ -0,0 +1,74 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// pprofCollectorParam is the URL of the collector the agent pushes the profiles to
+	pprofCollectorParam = "collector"
+	// collectorAuthHeader carries the bearer token the agent presents to the collector
+	collectorAuthHeader = "X-Collector-Authorization"
+	// collectorTokenKey is the key of the bearer token
+	// in the secret referenced by the collector endpoint
+	collectorTokenKey = "token"
+)
+
+// pushesArtifacts returns true if the agents push the profiles of the run to an external collector.
+func pushesArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	return instance.Spec.CollectorEndpoint != nil
+}
+
+// withCollectorParam returns the given agent path
+// instructing the agent to push the profiles to the collector endpoint of the run.
+func withCollectorParam(path string, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	if !pushesArtifacts(instance) {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + pprofCollectorParam + "=" + neturl.QueryEscape(instance.Spec.CollectorEndpoint.URL)
+}
+
+// collectorHeader returns the header passing the bearer token of the collector endpoint
+// to the agents, nil if the run has no collector endpoint or no token.
+func (r *NodeObservabilityRunReconciler) collectorHeader(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (http.Header, error) {
+	if !pushesArtifacts(instance) || instance.Spec.CollectorEndpoint.BearerTokenSecretRef == nil {
+		return nil, nil
+	}
+	name := instance.Spec.CollectorEndpoint.BearerTokenSecretRef.Name
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get collector token secret %q: %w", name, err)
+	}
+	token := strings.TrimSpace(string(secret.Data[collectorTokenKey]))
+	if token == "" {
+		return nil, fmt.Errorf("collector token secret %q must contain the %s key", name, collectorTokenKey)
+	}
+	header := http.Header{}
+	header.Set(collectorAuthHeader, fmt.Sprintf("Bearer %s", token))
+	return header, nil
+}
+
+// listPushedArtifacts lists the profiles pushed by the agents to the collector,
+// their location is up to the collector.
+func listPushedArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	for i := range instance.Status.Agents {
+		var artifacts []nodeobservabilityv1alpha2.ProfileArtifact
+		for _, artifact := range runArtifacts(instance) {
+			artifacts = append(artifacts, nodeobservabilityv1alpha2.ProfileArtifact{Name: artifact})
+		}
+		instance.Status.Agents[i].Artifacts = artifacts
+	}
+}
@@ -0,0 +1,154 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+const (
+	testCollectorURL        = "https://collector.example.com/profiles"
+	testCollectorSecretName = "collector-token"
+)
+
+func TestStartRunCollectorEndpoint(t *testing.T) {
+	cases := []struct {
+		name            string
+		endpoint        *operatorv1alpha2.CollectorEndpoint
+		existingObjects []runtime.Object
+		errExpected     bool
+		expectedQuery   string
+		expectedHeader  string
+	}{
+		{
+			name: "pull model",
+		},
+		{
+			name:          "collector without token",
+			endpoint:      &operatorv1alpha2.CollectorEndpoint{URL: testCollectorURL},
+			expectedQuery: "collector=https%3A%2F%2Fcollector.example.com%2Fprofiles",
+		},
+		{
+			name: "collector with token",
+			endpoint: &operatorv1alpha2.CollectorEndpoint{
+				URL:                  testCollectorURL,
+				BearerTokenSecretRef: &corev1.LocalObjectReference{Name: testCollectorSecretName},
+			},
+			existingObjects: []runtime.Object{testCollectorSecret("secret-token\n")},
+			expectedQuery:   "collector=https%3A%2F%2Fcollector.example.com%2Fprofiles",
+			expectedHeader:  "Bearer secret-token",
+		},
+		{
+			name: "missing token secret",
+			endpoint: &operatorv1alpha2.CollectorEndpoint{
+				URL:                  testCollectorURL,
+				BearerTokenSecretRef: &corev1.LocalObjectReference{Name: testCollectorSecretName},
+			},
+			errExpected: true,
+		},
+		{
+			name: "empty token",
+			endpoint: &operatorv1alpha2.CollectorEndpoint{
+				URL:                  testCollectorURL,
+				BearerTokenSecretRef: &corev1.LocalObjectReference{Name: testCollectorSecretName},
+			},
+			existingObjects: []runtime.Object{testCollectorSecret("")},
+			errExpected:     true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var query, header string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				query = req.URL.RawQuery
+				header = req.Header.Get(collectorAuthHeader)
+				pong(w, req)
+			}))
+			defer server.Close()
+
+			defaultTransport := transport
+			transport = server.Client().Transport
+			defer func() { transport = defaultTransport }()
+
+			agent := testAgentNode(name, server)
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{{IP: agent.IP, TargetRef: &corev1.ObjectReference{Name: name}}},
+						Ports:     []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+					},
+				},
+			}
+			objs := append([]runtime.Object{testNodeObservability(), endpoints}, tc.existingObjects...)
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:    cl,
+				Log:       zap.New(zap.UseDevMode(true)),
+				URL:       &testURL{},
+				AgentName: name,
+				Namespace: namespace,
+			}
+
+			run := testNodeObservabilityRun()
+			run.Spec.CollectorEndpoint = tc.endpoint
+			if err := cl.Create(context.Background(), run); err != nil {
+				t.Fatalf("failed to create the run: %v", err)
+			}
+			err := r.startRun(context.Background(), run, transport)
+			if tc.errExpected {
+				if err == nil {
+					t.Fatalf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query != tc.expectedQuery {
+				t.Errorf("expected query %q, got %q", tc.expectedQuery, query)
+			}
+			if header != tc.expectedHeader {
+				t.Errorf("expected collector authorization %q, got %q", tc.expectedHeader, header)
+			}
+		})
+	}
+}
+
+func TestListPushedArtifacts(t *testing.T) {
+	run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+		Agents: []operatorv1alpha2.AgentNode{{Name: "node-1"}},
+	})
+	run.Spec.CollectorEndpoint = &operatorv1alpha2.CollectorEndpoint{URL: testCollectorURL}
+
+	r := NodeObservabilityRunReconciler{}
+	stored, err := r.storeArtifacts(context.Background(), run, transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stored {
+		t.Fatalf("expected the pushed profiles to be considered as stored")
+	}
+	expected := []operatorv1alpha2.ProfileArtifact{{Name: "kubelet.pprof"}, {Name: "crio.pprof"}}
+	if !reflect.DeepEqual(run.Status.Agents[0].Artifacts, expected) {
+		t.Errorf("expected artifacts %v, got %v", expected, run.Status.Agents[0].Artifacts)
+	}
+}
+
+func testCollectorSecret(token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testCollectorSecretName, Namespace: namespace},
+		Data:       map[string][]byte{collectorTokenKey: []byte(token)},
+	}
+}
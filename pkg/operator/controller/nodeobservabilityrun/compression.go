@@ -0,0 +1,49 @@
+package nodeobservabilityruncontroller
+
+import (
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
+)
+
+// gzipsArtifacts returns true if the profiles of the run are gzipped
+// before being written into the storage backend.
+func gzipsArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	return instance.Spec.StorageBackend != nil && instance.Spec.Compression == nodeobservabilityv1alpha2.GzipArtifactCompression
+}
+
+// storedArtifact returns the given profile as written into the storage backend,
+// suffixed with .gz if the profiles of the run are gzipped.
+func storedArtifact(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, artifact string) nodeobservabilityv1alpha2.ProfileArtifact {
+	if !gzipsArtifacts(instance) {
+		return nodeobservabilityv1alpha2.ProfileArtifact{Name: artifact}
+	}
+	return nodeobservabilityv1alpha2.ProfileArtifact{
+		Name:        artifact + collector.GzipExt,
+		Compression: nodeobservabilityv1alpha2.GzipArtifactCompression,
+	}
+}
+
+// compressArtifact returns the data of the profile as written into the storage backend,
+// and sets the sizes of the stored profile.
+func compressArtifact(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, artifact *nodeobservabilityv1alpha2.ProfileArtifact, data []byte) ([]byte, error) {
+	if gzipsArtifacts(instance) {
+		uncompressedSize := int64(len(data))
+		artifact.UncompressedSize = &uncompressedSize
+		var err error
+		if data, err = collector.Gzip(data); err != nil {
+			return nil, err
+		}
+	}
+	size := int64(len(data))
+	artifact.Size = &size
+	return data, nil
+}
+
+// maxArtifactSize returns the maximum size in bytes of each profile
+// retrieved from the agents, 0 if the profiles are not bounded.
+func maxArtifactSize(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) int64 {
+	if instance.Spec.MaxArtifactSize == nil {
+		return 0
+	}
+	return instance.Spec.MaxArtifactSize.Value()
+}
@@ -0,0 +1,92 @@
+package nodeobservabilityruncontroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+func TestCompressArtifact(t *testing.T) {
+	data := bytes.Repeat([]byte("profile"), 100)
+	cases := []struct {
+		name                     string
+		storageBackend           *operatorv1alpha2.StorageBackend
+		compression              operatorv1alpha2.ArtifactCompression
+		expectedName             string
+		expectedCompression      operatorv1alpha2.ArtifactCompression
+		expectedUncompressedSize bool
+	}{
+		{
+			name:           "default compression",
+			storageBackend: &operatorv1alpha2.StorageBackend{Type: operatorv1alpha2.S3StorageBackendType},
+			expectedName:   "kubelet.pprof",
+		},
+		{
+			name:           "no compression",
+			storageBackend: &operatorv1alpha2.StorageBackend{Type: operatorv1alpha2.S3StorageBackendType},
+			compression:    operatorv1alpha2.NoArtifactCompression,
+			expectedName:   "kubelet.pprof",
+		},
+		{
+			name:         "gzip compression without storage backend",
+			compression:  operatorv1alpha2.GzipArtifactCompression,
+			expectedName: "kubelet.pprof",
+		},
+		{
+			name:                     "gzip compression",
+			storageBackend:           &operatorv1alpha2.StorageBackend{Type: operatorv1alpha2.S3StorageBackendType},
+			compression:              operatorv1alpha2.GzipArtifactCompression,
+			expectedName:             "kubelet.pprof.gz",
+			expectedCompression:      operatorv1alpha2.GzipArtifactCompression,
+			expectedUncompressedSize: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.StorageBackend = tc.storageBackend
+			run.Spec.Compression = tc.compression
+
+			artifact := storedArtifact(run, "kubelet.pprof")
+			stored, err := compressArtifact(run, &artifact, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if artifact.Name != tc.expectedName {
+				t.Errorf("expected name %q, got %q", tc.expectedName, artifact.Name)
+			}
+			if artifact.Compression != tc.expectedCompression {
+				t.Errorf("expected compression %q, got %q", tc.expectedCompression, artifact.Compression)
+			}
+			if artifact.Size == nil || *artifact.Size != int64(len(stored)) {
+				t.Errorf("expected size %d, got %v", len(stored), artifact.Size)
+			}
+			if !tc.expectedUncompressedSize {
+				if artifact.UncompressedSize != nil {
+					t.Errorf("expected no uncompressed size, got %d", *artifact.UncompressedSize)
+				}
+				if !bytes.Equal(stored, data) {
+					t.Errorf("expected the profile to be stored as is")
+				}
+				return
+			}
+			if artifact.UncompressedSize == nil || *artifact.UncompressedSize != int64(len(data)) {
+				t.Errorf("expected uncompressed size %d, got %v", len(data), artifact.UncompressedSize)
+			}
+			r, err := gzip.NewReader(bytes.NewReader(stored))
+			if err != nil {
+				t.Fatalf("failed to read gzipped profile: %v", err)
+			}
+			decompressed, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to decompress profile: %v", err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Errorf("expected the decompressed profile to match the original one")
+			}
+		})
+	}
+}
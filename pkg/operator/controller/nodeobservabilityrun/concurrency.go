@@ -0,0 +1,69 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// activeRun returns the run of the same NodeObservability which prevents the given run from starting,
+// nil if there is none. A run is prevented from starting by the unfinished runs
+// which either started already or were created before it.
+func (r *NodeObservabilityRunReconciler) activeRun(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (*nodeobservabilityv1alpha2.NodeObservabilityRun, error) {
+	runs := &nodeobservabilityv1alpha2.NodeObservabilityRunList{}
+	if err := r.List(ctx, runs, client.InNamespace(instance.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list nodeobservabilityruns: %w", err)
+	}
+
+	var queued *nodeobservabilityv1alpha2.NodeObservabilityRun
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if run.Name == instance.Name || finished(run) {
+			continue
+		}
+		if run.Spec.NodeObservabilityRef == nil || run.Spec.NodeObservabilityRef.Name != instance.Spec.NodeObservabilityRef.Name {
+			continue
+		}
+		if inProgress(run) {
+			return run, nil
+		}
+		if queued == nil && createdBefore(run, instance) {
+			queued = run
+		}
+	}
+	return queued, nil
+}
+
+// rejectRun marks the given run as finished without starting it
+// as the given active run of the same NodeObservability is not finished.
+func (r *NodeObservabilityRunReconciler) rejectRun(instance, active *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	msg := fmt.Sprintf("Rejected as nodeobservabilityrun %s of nodeobservability %s is active", active.Name, instance.Spec.NodeObservabilityRef.Name)
+	r.Log.V(1).Info("Run rejected", "active", active.Name)
+	t := metav1.Now()
+	instance.Status.FinishedTimestamp = &t
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonRejected, msg)
+	r.EventRecorder.Event(instance, corev1.EventTypeWarning, nodeobservabilityv1alpha2.ReasonRejected, msg)
+}
+
+// runConcurrencyPolicy returns how the run is handled when another run is active,
+// falls back to the default one if not set in the spec.
+func runConcurrencyPolicy(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) nodeobservabilityv1alpha2.RunConcurrencyPolicy {
+	if instance.Spec.ConcurrencyPolicy != "" {
+		return instance.Spec.ConcurrencyPolicy
+	}
+	return nodeobservabilityv1alpha2.QueueConcurrentRun
+}
+
+// createdBefore returns true if the run a was created before the run b,
+// the runs created in the same second are ordered by name.
+func createdBefore(a, b *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.Name < b.Name
+	}
+	return a.CreationTimestamp.Before(&b.CreationTimestamp)
+}
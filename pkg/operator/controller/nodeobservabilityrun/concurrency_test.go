@@ -0,0 +1,183 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestReconcileConcurrency(t *testing.T) {
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Minute))
+	later := metav1.NewTime(now.Add(time.Minute))
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	otherRun := func(name string, created metav1.Time, status operatorv1alpha2.NodeObservabilityRunStatus) *operatorv1alpha2.NodeObservabilityRun {
+		run := testNodeObservabilityRunWithStatus(status)
+		run.Name = name
+		run.CreationTimestamp = created
+		return run
+	}
+	started := operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &earlier}
+	succeeded := operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &earlier, FinishedTimestamp: &earlier}
+	succeeded.SetCondition(operatorv1alpha2.DebugFinished, metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, "")
+	failed := operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &earlier, FinishedTimestamp: &earlier}
+	failed.SetCondition(operatorv1alpha2.DebugFinished, metav1.ConditionFalse, operatorv1alpha2.ReasonFailed, "")
+
+	cases := []struct {
+		name            string
+		policy          operatorv1alpha2.RunConcurrencyPolicy
+		existingObjects []runtime.Object
+		res             ctrl.Result
+		started         bool
+		rejected        bool
+		expectedEvent   string
+	}{
+		{
+			name:    "no other run",
+			res:     ctrl.Result{RequeueAfter: 30 * time.Second},
+			started: true,
+		},
+		{
+			name:            "active run queues the run by default",
+			existingObjects: []runtime.Object{otherRun("active", earlier, started)},
+			res:             ctrl.Result{RequeueAfter: pollingPeriod},
+			expectedEvent:   corev1.EventTypeNormal + " " + operatorv1alpha2.ReasonQueued,
+		},
+		{
+			name:            "active run created later queues the run",
+			policy:          operatorv1alpha2.QueueConcurrentRun,
+			existingObjects: []runtime.Object{otherRun("active", later, started)},
+			res:             ctrl.Result{RequeueAfter: pollingPeriod},
+			expectedEvent:   corev1.EventTypeNormal + " " + operatorv1alpha2.ReasonQueued,
+		},
+		{
+			name:            "active run rejects the run",
+			policy:          operatorv1alpha2.RejectConcurrentRun,
+			existingObjects: []runtime.Object{otherRun("active", earlier, started)},
+			rejected:        true,
+			expectedEvent:   corev1.EventTypeWarning + " " + operatorv1alpha2.ReasonRejected,
+		},
+		{
+			name:            "run queued before",
+			existingObjects: []runtime.Object{otherRun("queued", earlier, operatorv1alpha2.NodeObservabilityRunStatus{})},
+			res:             ctrl.Result{RequeueAfter: pollingPeriod},
+			expectedEvent:   corev1.EventTypeNormal + " " + operatorv1alpha2.ReasonQueued,
+		},
+		{
+			name:            "run queued after",
+			policy:          operatorv1alpha2.RejectConcurrentRun,
+			existingObjects: []runtime.Object{otherRun("queued", later, operatorv1alpha2.NodeObservabilityRunStatus{})},
+			res:             ctrl.Result{RequeueAfter: 30 * time.Second},
+			started:         true,
+		},
+		{
+			name:            "succeeded run",
+			policy:          operatorv1alpha2.RejectConcurrentRun,
+			existingObjects: []runtime.Object{otherRun("succeeded", earlier, succeeded)},
+			res:             ctrl.Result{RequeueAfter: 30 * time.Second},
+			started:         true,
+		},
+		{
+			name:            "failed run",
+			policy:          operatorv1alpha2.RejectConcurrentRun,
+			existingObjects: []runtime.Object{otherRun("failed", earlier, failed)},
+			res:             ctrl.Result{RequeueAfter: 30 * time.Second},
+			started:         true,
+		},
+		{
+			name:   "active run of another nodeobservability",
+			policy: operatorv1alpha2.RejectConcurrentRun,
+			existingObjects: []runtime.Object{
+				func() *operatorv1alpha2.NodeObservabilityRun {
+					run := otherRun("other", earlier, started)
+					run.Spec.NodeObservabilityRef.Name = "other"
+					return run
+				}(),
+			},
+			res:     ctrl.Result{RequeueAfter: 30 * time.Second},
+			started: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.CreationTimestamp = now
+			run.Spec.ConcurrencyPolicy = tc.policy
+			// no agent to call once started
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Subsets: []corev1.EndpointSubset{
+					{Ports: []corev1.EndpointPort{{Name: "test-port", Port: 8443}}},
+				},
+			}
+			objs := append([]runtime.Object{testNodeObservability(), run, endpoints}, tc.existingObjects...)
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: recorder,
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+				CACertFile:    CAPath,
+			}
+
+			res, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			if res != tc.res {
+				t.Fatalf("expected result %v, got %v", tc.res, res)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if inProgress(got) != tc.started {
+				t.Fatalf("expected run started to be %t, got %t", tc.started, inProgress(got))
+			}
+			if finished(got) != tc.rejected {
+				t.Fatalf("expected run finished to be %t, got %t", tc.rejected, finished(got))
+			}
+			cond := got.Status.GetCondition(operatorv1alpha2.DebugReady)
+			if queued := cond != nil && cond.Reason == operatorv1alpha2.ReasonQueued; queued != (!tc.started && !tc.rejected) {
+				t.Fatalf("unexpected ready condition %v", cond)
+			}
+			if tc.rejected {
+				cond := got.Status.GetCondition(operatorv1alpha2.DebugFinished)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != operatorv1alpha2.ReasonRejected {
+					t.Fatalf("expected finished condition to be rejected, got %v", cond)
+				}
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if tc.expectedEvent == "" || !strings.HasPrefix(event, tc.expectedEvent) {
+					t.Fatalf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Fatalf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
+}
@@ -18,11 +18,13 @@ package nodeobservabilityruncontroller
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -32,14 +34,18 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 
 	"github.com/go-logr/logr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
 )
 
 const (
@@ -48,10 +54,64 @@ const (
 	ProfilingMCPName = "nodeobservability"
 	pprofPath        = "node-observability-pprof"
 	pprofStatus      = "node-observability-status"
+	pprofOutput      = "node-observability-output"
+	// pprofProfilesParam restricts the profiles taken by the agent
+	pprofProfilesParam = "profiles"
+	// pprofSecondsParam sets the duration of the CPU profiles taken by the agent
+	pprofSecondsParam = "seconds"
+	// pprofTypesParam sets the types of the profiles taken by the agent
+	pprofTypesParam = "types"
+	pprofExt        = ".pprof"
+	// defaultProfileDuration is the duration of the CPU profiles taken by the agent
+	// when no duration is requested, minProfileDuration and maxProfileDuration bound the requested one
+	defaultProfileDuration = 30 * time.Second
+	minProfileDuration     = time.Second
+	maxProfileDuration     = 300 * time.Second
+	// defaultRunTimeout is the maximum duration of a run
+	// when no timeout is set in the spec
+	defaultRunTimeout = 10 * time.Minute
+	// defaultMaxRetries is the number of times a failed request
+	// to an agent is retried when not set in the spec
+	defaultMaxRetries = 3
+	// defaultRetryBackoff is the delay before the first retry
+	// of a failed request to an agent when not set in the spec
+	defaultRetryBackoff = time.Second
+	retryBackoffFactor  = 2.0
+	// defaultAgentRequestTimeoutMargin is added to the profile duration
+	// to bound the requests to the agents when no margin is configured
+	defaultAgentRequestTimeoutMargin = 30 * time.Second
+	// agentDialTimeout bounds the establishment of the connections to the agents,
+	// agentKeepAlive is the period of the keep-alive probes of the idle connections
+	agentDialTimeout = 10 * time.Second
+	agentKeepAlive   = 30 * time.Second
+	// defaultMinSucceededNodesPercent is the minimum percentage of the nodes
+	// on which the profiling must succeed with the BestEffort failure policy
+	// when not set in the spec
+	defaultMinSucceededNodesPercent = 0
+	// defaultMaxConcurrentNodes is the maximum number of nodes
+	// profiled at the same time when not set in the spec
+	defaultMaxConcurrentNodes = 25
+	// checkpointTimeout bounds the update of the status of the run
+	// interrupted by the shutdown of the operator
+	checkpointTimeout = 5 * time.Second
+	// eventReasonEtcdProfilingRejected is the reason of the event recorded
+	// when the agents reject the profiling of etcd
+	eventReasonEtcdProfilingRejected = "EtcdProfilingRejected"
 )
 
 var (
-	transport = http.DefaultTransport
+	// profileArtifacts are the CPU profiles retrieved from the agents
+	// to be uploaded to the storage backend
+	profileArtifacts = []string{"kubelet.pprof", "crio.pprof"}
+	// kubeletProfileArtifacts are the CPU profiles retrieved from the agents
+	// when only the kubelet is profiled
+	kubeletProfileArtifacts = []string{"kubelet.pprof"}
+	// etcdProfileArtifacts are the CPU profiles retrieved from the agents
+	// when the kubelet and etcd are profiled
+	etcdProfileArtifacts = []string{"kubelet.pprof", "etcd.pprof"}
+	// defaultProfileTypes are the types of the profiles taken by the agents
+	// when no type is set in the spec
+	defaultProfileTypes = []nodeobservabilityv1alpha2.ProfileType{nodeobservabilityv1alpha2.CPUProfileType}
 )
 
 // NodeObservabilityRunReconciler reconciles a NodeObservabilityRun object
@@ -59,17 +119,50 @@ type NodeObservabilityRunReconciler struct {
 	client.Client
 	Log logr.Logger
 	URL
-	Scheme    *runtime.Scheme
-	Namespace string
-	AgentName string
-	AuthToken []byte
-	CACert    *x509.CertPool
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+	Namespace     string
+	AgentName     string
+	AuthToken     []byte
+	// CACertFile is the path of the service CA bundle
+	// verifying the serving certificates of the agents
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are the paths of the client certificate
+	// presented to the agents, no client certificate is presented if not set
+	ClientCertFile string
+	ClientKeyFile  string
+	// CollectorImage is the image of the pods which collect
+	// the profiles into the persistent volume claims
+	CollectorImage string
+	// CollectorServiceAccount is the service account of the collector pods,
+	// created in the namespace of the runs and only allowed to retrieve the profiles from the agents
+	CollectorServiceAccount string
+	// AgentRequestTimeoutMargin is added to the profile duration of the runs
+	// to bound the requests to the agents when the runs don't set a timeout
+	AgentRequestTimeoutMargin time.Duration
+	// Elected is closed once the operator replica is the leader,
+	// the run metrics are only advanced by the leader not to be counted twice in HA.
+	// The replica is always considered the leader if not set
+	Elected <-chan struct{}
+	// agentRequests holds the functions aborting the requests
+	// in flight to the agents of each run, called once the run is cancelled
+	agentRequests sync.Map
 }
 
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilityruns,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilityruns/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilityruns/finalizers,verbs=update
+//+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list
+//+kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=get
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=secrets,verbs=get
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=configmaps,verbs=get
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=persistentvolumeclaims,verbs=get
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=pods,verbs=list;get;create;delete
+//+kubebuilder:rbac:groups=core,namespace=node-observability-operator,resources=serviceaccounts,verbs=get;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;create;update
+//+kubebuilder:rbac:urls=/node-observability-output/*,verbs=get;
 
 // Reconcile manages NodeObservabilityRuns
 func (r *NodeObservabilityRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, err error) {
@@ -92,17 +185,58 @@ func (r *NodeObservabilityRunReconciler) Reconcile(ctx context.Context, req ctrl
 
 	if finished(instance) {
 		r.Log.V(1).Info("Run for this instance has been completed already")
-		return
+		if err = r.recordRunHistory(ctx, instance); err != nil {
+			return
+		}
+		return r.expireArtifacts(ctx, instance)
 	}
 
+	// the outcome of the run is counted once its finish is persisted,
+	// the finish retried after a failed status update is not counted twice
+	var runFinished bool
 	defer func() {
-		errUpdate := r.updateStatus(ctx, instance)
+		updateNodeCounts(instance)
+		updatePhase(instance)
+		updateDuration(instance)
+		updateSummary(instance)
+		statusCtx := ctx
+		if ctx.Err() != nil {
+			// the operator is shutting down, the progress of the run is kept for it to be resumed on restart
+			markInterrupted(instance)
+			var cancel context.CancelFunc
+			statusCtx, cancel = context.WithTimeout(context.Background(), checkpointTimeout)
+			defer cancel()
+		}
+		errUpdate := r.updateStatus(statusCtx, instance)
 		if errUpdate != nil {
 			errUpdate = fmt.Errorf("failed to update status: %w", errUpdate)
 			err = utilerrors.NewAggregate([]error{err, errUpdate})
+		} else if runFinished && r.isLeader() {
+			recordRunMetrics(instance)
 		}
 	}()
 
+	// a run cancelled before it starts never profiles the nodes
+	if instance.Spec.Cancel && !inProgress(instance) {
+		r.cancelRun(instance)
+		return ctrl.Result{}, nil
+	}
+
+	// an invalid spec fails the run before it's started
+	if err = validateProfileDuration(instance); err != nil {
+		r.failInvalidRun(instance, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// a mistyped reference fails the run instead of being retried forever
+	var found bool
+	if found, err = r.referenceExists(ctx, instance); !found {
+		if err != nil {
+			return
+		}
+		return r.handleMissingReference(instance), nil
+	}
+
 	var isAdopted bool
 	if isAdopted, err = r.adoptResource(ctx, instance); !isAdopted {
 		if err != nil {
@@ -113,37 +247,171 @@ func (r *NodeObservabilityRunReconciler) Reconcile(ctx context.Context, req ctrl
 		return
 	}
 
-	var canProceed bool
-	var msg string
-	if canProceed, err = r.preconditionsMet(ctx, instance); !canProceed {
+	var reason, msg string
+	if reason, msg, err = r.unmetPreconditions(ctx, instance); reason != "" || err != nil {
 		if err != nil {
 			err = fmt.Errorf("preconditions not met: %w", err)
 			return
 		}
-		msg = fmt.Sprintf("Waiting for nodeobservability %s to become ready", instance.Spec.NodeObservabilityRef.Name)
+		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, reason, msg)
+		return ctrl.Result{RequeueAfter: pollingPeriod}, nil
+	}
+
+	if !inProgress(instance) {
+		var active *nodeobservabilityv1alpha2.NodeObservabilityRun
+		if active, err = r.activeRun(ctx, instance); err != nil {
+			return
+		}
+		if active != nil {
+			if runConcurrencyPolicy(instance) == nodeobservabilityv1alpha2.RejectConcurrentRun {
+				r.rejectRun(instance, active)
+				return
+			}
+			msg = fmt.Sprintf("Queued until nodeobservabilityrun %s of nodeobservability %s finishes", active.Name, instance.Spec.NodeObservabilityRef.Name)
+			if instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonQueued, msg) {
+				r.EventRecorder.Event(instance, corev1.EventTypeNormal, nodeobservabilityv1alpha2.ReasonQueued, msg)
+			}
+			return ctrl.Result{RequeueAfter: pollingPeriod}, nil
+		}
+	}
+
+	// the agents are never contacted without verifying them unless the run skips the verification
+	var agentTransport http.RoundTripper
+	if agentTransport, err = r.agentTransport(ctx, instance); err != nil {
+		msg = fmt.Sprintf("Unable to verify the agents: %s", err)
+		// the config map may be created after the run, it's awaited without failing the run
+		if errors.IsNotFound(err) {
+			instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonCABundleNotFound, msg)
+			return ctrl.Result{RequeueAfter: pollingPeriod}, nil
+		}
+		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInProgress, msg)
+		return ctrl.Result{RequeueAfter: pollingPeriod}, err
+	}
+	var agentHeader http.Header
+	if agentHeader, err = r.agentAuthHeader(ctx, instance); err != nil {
+		msg = fmt.Sprintf("Unable to authenticate to the agents: %s", err)
 		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInProgress, msg)
+		// the secret may be created after the run, it's awaited without failing the run
+		if errors.IsNotFound(err) {
+			return ctrl.Result{RequeueAfter: pollingPeriod}, nil
+		}
 		return ctrl.Result{RequeueAfter: pollingPeriod}, err
 	}
+	agentTransport = withAgentAuth(agentTransport, agentHeader)
 	msg = "Ready to start profiling"
 	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionTrue, nodeobservabilityv1alpha2.ReasonReady, msg)
 
+	agentCtx, untrack := r.trackAgentRequests(ctx, req.NamespacedName)
+	defer untrack()
+
 	if inProgress(instance) {
 		r.Log.V(1).Info("Run is in progress")
-		var requeue bool
-		requeue, err = r.handleInProgress(instance)
-		if requeue {
-			msg = "Profiling query in progress"
-			instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInProgress, msg)
+		if cond := instance.Status.GetCondition(nodeobservabilityv1alpha2.DebugFinished); cond != nil && cond.Reason == nodeobservabilityv1alpha2.ReasonInterrupted {
+			r.Log.Info("Resuming the run interrupted by the shutdown of the operator")
+		}
+
+		// the agents are no longer polled once their profiles are being collected
+		var collecting bool
+		if collecting, err = r.collectionStarted(ctx, instance); err != nil {
+			return
+		}
+		if !collecting && instance.Spec.Cancel {
+			// the run which already timed out keeps its failure
+			if !profilingAborted(instance) {
+				cancelAgents(instance)
+				msg = fmt.Sprintf("Profiling query cancelled, %d agent(s) did not finish", len(instance.Status.CancelledAgents))
+				instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonCancelled, msg)
+			}
+		} else if !collecting {
+			timeout := runTimeout(instance)
+			deadline := instance.Status.StartTimestamp.Add(timeout)
+			timedOut := !time.Now().Before(deadline)
+
+			// the status requests still pending when the deadline is reached are aborted
+			pollCtx := agentCtx
+			if !timedOut {
+				var cancel context.CancelFunc
+				pollCtx, cancel = context.WithDeadline(agentCtx, deadline)
+				defer cancel()
+			}
+
+			var running []nodeobservabilityv1alpha2.AgentNode
+			running, err = r.handleInProgress(pollCtx, instance, agentTransport)
+			// the pending agents start as the running ones finish
+			if !timedOut && len(instance.Status.PendingAgents) > 0 {
+				// the failures of the polling are still reported with the one of the header
+				header, errHeader := r.collectorHeader(ctx, instance)
+				if errHeader != nil {
+					err = utilerrors.NewAggregate([]error{err, errHeader})
+					return
+				}
+				running = append(running, r.startPendingAgents(pollCtx, instance, agentTransport, header)...)
+			}
+			// the run was cancelled meanwhile, its agents are cancelled on the next reconciliation
+			if agentCtx.Err() != nil && ctx.Err() == nil {
+				return ctrl.Result{Requeue: true}, err
+			}
+			if len(running) > 0 || len(instance.Status.PendingAgents) > 0 {
+				if !timedOut {
+					msg = "Profiling query in progress"
+					instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInProgress, msg)
+					return ctrl.Result{RequeueAfter: pollingPeriod}, err
+				}
+				// the agents which finished before the timeout keep their results
+				for _, agent := range running {
+					r.Log.V(1).Info("Profiling timed out, removing node from list", "Name", agent.Name, "IP", agent.IP)
+					handleFailingAgent(instance, agent)
+				}
+				unfinished := len(running) + len(instance.Status.PendingAgents)
+				failPendingAgents(instance)
+				msg = fmt.Sprintf("Profiling query timed out after %s, %d agent(s) did not finish", timeout, unfinished)
+				instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonFailed, msg)
+			}
+		}
+
+		// the profiles are not stored while the operator shuts down, the run is resumed on restart
+		if ctx.Err() != nil {
+			return ctrl.Result{}, err
+		}
+
+		stored, errStore := r.storeArtifacts(ctx, instance, agentTransport)
+		err = utilerrors.NewAggregate([]error{err, errStore})
+		// the timeout or the cancellation of the profiling is kept while the profiles are being stored
+		aborted := profilingAborted(instance)
+		if !stored {
+			if !aborted {
+				msg = "Collecting the profiles"
+				instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInProgress, msg)
+			}
 			return ctrl.Result{RequeueAfter: pollingPeriod}, err
 		}
 		t := metav1.Now()
 		instance.Status.FinishedTimestamp = &t
-		msg = "Profiling query done"
-		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionTrue, nodeobservabilityv1alpha2.ReasonFinished, msg)
+		if !aborted {
+			msg = "Profiling query done"
+			instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionTrue, nodeobservabilityv1alpha2.ReasonFinished, msg)
+		}
+		runFinished = true
+		if cancelled(instance) {
+			r.recordRunCancelled(instance)
+			return
+		}
+		r.recordRunResult(instance)
 		return
 	}
 
-	err = r.startRun(ctx, instance)
+	// the listed nodes are all expected to be profiled, the run fails rather than skipping some
+	if msg, err = r.unavailableNodes(ctx, instance); msg != "" || err != nil {
+		if err != nil {
+			msg = fmt.Sprintf("Failed to initiate profiling query: %s", err.Error())
+			instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonFailed, msg)
+			return
+		}
+		r.failInvalidRun(instance, msg)
+		return ctrl.Result{}, nil
+	}
+
+	err = r.startRun(agentCtx, instance, agentTransport)
 	if err != nil {
 		msg = fmt.Sprintf("Failed to initiate profiling query: %s", err.Error())
 		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonFailed, msg)
@@ -180,57 +448,376 @@ func (r *NodeObservabilityRunReconciler) adoptResource(ctx context.Context, inst
 	return false, err
 }
 
-func (r *NodeObservabilityRunReconciler) handleInProgress(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (bool, error) {
+// handleInProgress polls the status of the agents included in the run which haven't finished yet,
+// the agents which cannot be reached are moved to the failed agents.
+// Returns the agents which are still running the profiling,
+// the agents whose status request was aborted by the context are considered as running.
+func (r *NodeObservabilityRunReconciler) handleInProgress(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, transport http.RoundTripper) ([]nodeobservabilityv1alpha2.AgentNode, error) {
 	var errors []error
+	var running []nodeobservabilityv1alpha2.AgentNode
+	backoff := agentBackoff(instance)
+	timeout := r.agentRequestTimeout(instance)
 	for _, agent := range instance.Status.Agents {
+		if agent.Result == nodeobservabilityv1alpha2.AgentSucceeded || agent.Result == nodeobservabilityv1alpha2.AgentPendingUpload {
+			continue
+		}
 		url := r.format(agent.IP, r.AgentName, r.Namespace, pprofStatus, agent.Port)
-		err := retry.OnError(retry.DefaultBackoff, IsNodeObservabilityRunErrorRetriable, r.httpGetCall(url))
+		_, err := r.callAgent(ctx, transport, backoff, timeout, url, nil)
 		if err != nil {
 			if e, ok := err.(NodeObservabilityRunError); ok && e.HttpCode == http.StatusConflict {
-				r.Log.V(1).Info("Received 407:StatusConflict, job still running")
-				return true, nil
+				r.Log.V(1).Info("Received 409:StatusConflict, job still running", "Name", agent.Name)
+				running = append(running, handleRunningAgent(instance, agent))
+				continue
+			}
+			if ctx.Err() != nil {
+				r.Log.V(1).Info("Status request aborted, job considered as still running", "Name", agent.Name, "Error", err)
+				running = append(running, agent)
+				continue
 			}
 			errors = append(errors, fmt.Errorf("failed to get the status of the agent named %q with %q IP: %w", agent.Name, agent.IP, err))
 			handleFailingAgent(instance, agent)
 			continue
 		}
+		handleFinishedAgent(instance, agent)
 	}
-	return false, utilerrors.NewAggregate(errors)
+	return running, utilerrors.NewAggregate(errors)
 }
 
-func (r *NodeObservabilityRunReconciler) startRun(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) error {
+// storeArtifacts stores the profiles of the agents which finished the run into the storage backend.
+// Returns true once the profiles are stored.
+func (r *NodeObservabilityRunReconciler) storeArtifacts(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, transport http.RoundTripper) (bool, error) {
+	if pushesArtifacts(instance) {
+		listPushedArtifacts(instance)
+		return true, nil
+	}
+	if instance.Spec.StorageBackend == nil {
+		r.listAgentArtifacts(instance)
+		return true, nil
+	}
+	switch instance.Spec.StorageBackend.Type {
+	case nodeobservabilityv1alpha2.PVCStorageBackendType:
+		return r.collectArtifacts(ctx, instance)
+	default:
+		return r.uploadArtifacts(ctx, instance, transport)
+	}
+}
+
+// uploadArtifacts uploads the profiles of the agents which finished the run
+// to the storage backend and records their object keys,
+// the agents whose profiles cannot be retrieved or uploaded are moved to the failed agents.
+// The uploads which failed for a transient reason are retried with a backoff
+// while the agents are kept as pending upload. Returns true once no upload is pending.
+func (r *NodeObservabilityRunReconciler) uploadArtifacts(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, transport http.RoundTripper) (bool, error) {
+	if instance.Spec.StorageBackend == nil || len(instance.Status.Agents) == 0 {
+		return true, nil
+	}
+
+	storage, err := r.newS3Storage(ctx, instance)
+	if err != nil {
+		failAllAgents(instance)
+		return true, fmt.Errorf("failed to configure the storage backend: %w", err)
+	}
+	tmpl, err := nodeobservabilityv1alpha2.ParseArtifactKeyTemplate(instance.Spec.ArtifactKeyTemplate)
+	if err != nil {
+		failAllAgents(instance)
+		return true, fmt.Errorf("failed to parse the artifact key template: %w", err)
+	}
+
+	now := time.Now()
+	var errors []error
+	var agents, failed []nodeobservabilityv1alpha2.AgentNode
+	var pending int
+	for _, agent := range instance.Status.Agents {
+		if !awaitsUpload(agent) {
+			agents = append(agents, agent)
+			continue
+		}
+		if !uploadDue(agent, now) {
+			pending++
+			agents = append(agents, agent)
+			continue
+		}
+		keys, artifacts, err := r.uploadAgentArtifacts(ctx, transport, storage, tmpl, instance, agent)
+		if err != nil {
+			if isUploadRetriable(err) && retryUpload(&agent, err, now) {
+				r.Log.V(1).Info("Failed to upload the profiles, retrying", "Name", agent.Name, "IP", agent.IP, "Attempts", agent.PendingUpload.Attempts, "Error", err)
+				pending++
+				agents = append(agents, agent)
+				continue
+			}
+			r.Log.V(1).Info("Failed to upload the profiles, removing node from list", "Name", agent.Name, "IP", agent.IP, "Error", err)
+			errors = append(errors, fmt.Errorf("failed to upload the profiles of the agent named %q with %q IP: %w", agent.Name, agent.IP, err))
+			failed = append(failed, failAgent(agent))
+			continue
+		}
+		agent.ObjectKeys = keys
+		agent.Artifacts = artifacts
+		agent.Result = nodeobservabilityv1alpha2.AgentSucceeded
+		agent.PendingUpload = nil
+		agents = append(agents, agent)
+	}
+	instance.Status.Agents = agents
+	instance.Status.FailedAgents = append(instance.Status.FailedAgents, failed...)
+	return pending == 0, utilerrors.NewAggregate(errors)
+}
+
+// uploadAgentArtifacts retrieves the profiles from the agent and uploads them to the storage,
+// the objects are keyed with the artifact key template of the run,
+// the profiles are inspected and gzipped first if required by the run.
+// Returns the keys of the objects and the uploaded profiles.
+func (r *NodeObservabilityRunReconciler) uploadAgentArtifacts(ctx context.Context, transport http.RoundTripper, storage *s3Storage, tmpl *template.Template, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, agent nodeobservabilityv1alpha2.AgentNode) ([]string, []nodeobservabilityv1alpha2.ProfileArtifact, error) {
+	var keys []string
+	var artifacts []nodeobservabilityv1alpha2.ProfileArtifact
+	for _, artifact := range runArtifacts(instance) {
+		key, err := artifactKey(tmpl, instance, agent.Name, artifact)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to compute the key of profile %q: %w", artifact, err)
+		}
+		url := r.format(agent.IP, r.AgentName, r.Namespace, fmt.Sprintf("%s/%s", pprofOutput, artifact), agent.Port)
+		data, err := r.httpGetArtifact(ctx, transport, r.agentRequestTimeout(instance), url, nil, maxArtifactSize(instance))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve profile %q: %w", artifact, err)
+		}
+		stored := storedArtifact(instance, artifact)
+		r.inspectSymbols(instance, &stored, data)
+		if data, err = compressArtifact(instance, &stored, data); err != nil {
+			return nil, nil, fmt.Errorf("failed to compress profile %q: %w", artifact, err)
+		}
+		if err := storage.upload(ctx, key, data); err != nil {
+			return nil, nil, uploadError{err: err}
+		}
+		keys = append(keys, key)
+		stored.URI = storage.objectURL(key).String()
+		artifacts = append(artifacts, stored)
+	}
+	return keys, artifacts, nil
+}
+
+// listAgentArtifacts lists the profiles kept on the nodes when the run has no storage backend,
+// the profiles are served by the agents until they are restarted.
+func (r *NodeObservabilityRunReconciler) listAgentArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	for i, agent := range instance.Status.Agents {
+		var artifacts []nodeobservabilityv1alpha2.ProfileArtifact
+		for _, artifact := range runArtifacts(instance) {
+			artifacts = append(artifacts, nodeobservabilityv1alpha2.ProfileArtifact{
+				Name: artifact,
+				URI:  r.format(agent.IP, r.AgentName, r.Namespace, fmt.Sprintf("%s/%s", pprofOutput, artifact), agent.Port),
+			})
+		}
+		instance.Status.Agents[i].Artifacts = artifacts
+	}
+}
+
+func (r *NodeObservabilityRunReconciler) startRun(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, transport http.RoundTripper) error {
+	nodeObs := &nodeobservabilityv1alpha2.NodeObservability{}
+	if err := r.Get(ctx, types.NamespacedName{Name: instance.Spec.NodeObservabilityRef.Name}, nodeObs); err != nil {
+		return err
+	}
 	endps, err := r.getAgentEndpoints(ctx)
 	if err != nil {
 		return err
 	}
+	header, err := r.collectorHeader(ctx, instance)
+	if err != nil {
+		return err
+	}
+	selected, err := r.selectedNodes(ctx, instance)
+	if err != nil {
+		return err
+	}
 	subset := endps.Subsets[0]
 	port := subset.Ports[0].Port
 
-	targets := []nodeobservabilityv1alpha2.AgentNode{}
+	pendingTargets := []nodeobservabilityv1alpha2.AgentNode{}
 	failedTargets := []nodeobservabilityv1alpha2.AgentNode{}
 	for _, a := range subset.NotReadyAddresses {
-		failedTargets = append(failedTargets, nodeobservabilityv1alpha2.AgentNode{Name: a.TargetRef.Name, IP: a.IP, Port: port})
+		if !selected.has(nodeName(a)) {
+			continue
+		}
+		failedTargets = append(failedTargets, failAgent(nodeobservabilityv1alpha2.AgentNode{Name: a.TargetRef.Name, NodeName: nodeName(a), IP: a.IP, Port: port}))
 	}
-
 	for _, a := range subset.Addresses {
-		url := r.format(a.IP, r.AgentName, r.Namespace, pprofPath, port)
-		r.Log.V(1).Info("Initiating new run for node", "Name", a.TargetRef.Name, "IP", a.IP, "port", port, "URL", url)
-		err := retry.OnError(retry.DefaultBackoff, IsNodeObservabilityRunErrorRetriable, r.httpGetCall(url))
-		if err != nil {
-			r.Log.V(1).Info("Failed to start profiling, removing node from list", "Name", a.TargetRef.Name, "IP", a.IP, "Error", err)
-			failedTargets = append(failedTargets, nodeobservabilityv1alpha2.AgentNode{Name: a.TargetRef.Name, IP: a.IP, Port: port})
+		if !selected.has(nodeName(a)) {
+			r.Log.V(1).Info("Skipping agent of node not selected by the run", "Name", a.TargetRef.Name, "Node", nodeName(a))
 			continue
 		}
-		targets = append(targets, nodeobservabilityv1alpha2.AgentNode{Name: a.TargetRef.Name, IP: a.IP, Port: port})
+		pendingTargets = append(pendingTargets, nodeobservabilityv1alpha2.AgentNode{Name: a.TargetRef.Name, NodeName: nodeName(a), IP: a.IP, Port: port})
 	}
 
 	t := metav1.Now()
 	instance.Status.StartTimestamp = &t
-	instance.Status.Agents = targets
+	instance.Status.ProfilingType = nodeObs.Spec.Type
+	instance.Status.SelectedNodes = selected.list()
+	instance.Status.Agents = []nodeobservabilityv1alpha2.AgentNode{}
+	instance.Status.PendingAgents = pendingTargets
 	instance.Status.FailedAgents = failedTargets
+	r.startPendingAgents(ctx, instance, transport, header)
 	return nil
 }
 
+// startPendingAgents starts the profiling on the pending agents as long as
+// fewer than MaxConcurrentNodes agents are profiling, the start requests are sent concurrently.
+// The remaining agents stay pending until the running ones finish.
+// Returns the agents which started the profiling.
+func (r *NodeObservabilityRunReconciler) startPendingAgents(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, transport http.RoundTripper, header http.Header) []nodeobservabilityv1alpha2.AgentNode {
+	slots := int(maxConcurrentNodes(instance)) - runningAgents(instance)
+	if slots <= 0 || len(instance.Status.PendingAgents) == 0 {
+		return nil
+	}
+	if slots > len(instance.Status.PendingAgents) {
+		slots = len(instance.Status.PendingAgents)
+	}
+	batch := instance.Status.PendingAgents[:slots]
+	instance.Status.PendingAgents = instance.Status.PendingAgents[slots:]
+	if len(instance.Status.PendingAgents) == 0 {
+		instance.Status.PendingAgents = nil
+	}
+
+	// the agents are recorded as dispatched before their profiling is started:
+	// an operator restarted meanwhile resumes tracking them instead of profiling the nodes twice
+	duration := metav1.Duration{Duration: profileDuration(instance)}
+	dispatched := make([]nodeobservabilityv1alpha2.AgentNode, 0, len(batch))
+	for _, agent := range batch {
+		dispatched = append(dispatched, dispatchAgent(agent, duration))
+	}
+	checkpointed := len(instance.Status.Agents)
+	instance.Status.Agents = append(instance.Status.Agents, dispatched...)
+	updateNodeCounts(instance)
+	updatePhase(instance)
+	if err := r.updateStatus(ctx, instance); err != nil {
+		r.Log.Error(err, "failed to record the dispatched agents, their profiling is started on the next reconciliation")
+		instance.Status.Agents = instance.Status.Agents[:checkpointed]
+		instance.Status.PendingAgents = append(append([]nodeobservabilityv1alpha2.AgentNode{}, batch...), instance.Status.PendingAgents...)
+		return nil
+	}
+
+	path := withSymbolizeParam(withCollectorParam(profilingPath(instance.Status.ProfilingType, instance.Spec.ProfileDuration, instance.Spec.ProfileTypes), instance), instance)
+	backoff := agentBackoff(instance)
+	timeout := r.agentRequestTimeout(instance)
+	results := make([]nodeobservabilityv1alpha2.AgentNode, len(dispatched))
+	errs := make([]error, len(dispatched))
+	var wg sync.WaitGroup
+	for i, agent := range dispatched {
+		wg.Add(1)
+		go func(i int, agent nodeobservabilityv1alpha2.AgentNode) {
+			defer wg.Done()
+			results[i], errs[i] = r.startAgent(ctx, transport, backoff, timeout, path, header, duration, agent)
+		}(i, agent)
+	}
+	wg.Wait()
+	r.warnEtcdProfilingRejected(instance, errs)
+
+	instance.Status.Agents = instance.Status.Agents[:checkpointed]
+	var started []nodeobservabilityv1alpha2.AgentNode
+	for _, agent := range results {
+		if agent.Result == nodeobservabilityv1alpha2.AgentFailed {
+			instance.Status.FailedAgents = append(instance.Status.FailedAgents, agent)
+			continue
+		}
+		instance.Status.Agents = append(instance.Status.Agents, agent)
+		started = append(started, agent)
+	}
+	if running := int32(runningAgents(instance)); running > instance.Status.PeakConcurrentNodes {
+		instance.Status.PeakConcurrentNodes = running
+	}
+	return started
+}
+
+// dispatchAgent returns the given pending agent as dispatched,
+// the profiling is considered as started until the agent answers.
+func dispatchAgent(agent nodeobservabilityv1alpha2.AgentNode, duration metav1.Duration) nodeobservabilityv1alpha2.AgentNode {
+	t := metav1.Now()
+	agent.StartTimestamp = &t
+	agent.Result = nodeobservabilityv1alpha2.AgentDispatched
+	agent.ProfileDuration = &duration
+	return agent
+}
+
+// startAgent sends the request starting the profiling to the agent,
+// returns the agent as running or as failed with the error of the request.
+// The agent stays dispatched if the request was aborted by the shutdown of the operator.
+func (r *NodeObservabilityRunReconciler) startAgent(ctx context.Context, transport http.RoundTripper, backoff wait.Backoff, timeout time.Duration, path string, header http.Header, duration metav1.Duration, agent nodeobservabilityv1alpha2.AgentNode) (nodeobservabilityv1alpha2.AgentNode, error) {
+	url := r.format(agent.IP, r.AgentName, r.Namespace, path, agent.Port)
+	r.Log.V(1).Info("Initiating new run for node", "Name", agent.Name, "IP", agent.IP, "port", agent.Port, "URL", url)
+	attempts, err := r.callAgent(ctx, transport, backoff, timeout, url, header)
+	agent.Attempts = attempts
+	if err != nil {
+		if ctx.Err() != nil {
+			r.Log.V(1).Info("Start request aborted, node kept as dispatched", "Name", agent.Name, "IP", agent.IP, "Error", err)
+			return agent, nil
+		}
+		r.Log.V(1).Info("Failed to start profiling, removing node from list", "Name", agent.Name, "IP", agent.IP, "Attempts", attempts, "Error", err)
+		agent.StartTimestamp = nil
+		agent.ProfileDuration = nil
+		return failAgent(agent), err
+	}
+	started := metav1.Now()
+	agent.StartTimestamp = &started
+	agent.Result = nodeobservabilityv1alpha2.AgentRunning
+	agent.ProfileDuration = &duration
+	return agent, nil
+}
+
+// warnEtcdProfilingRejected records a warning event if agents rejected the profiling of etcd:
+// the agent image doesn't support it or the nodes don't run etcd.
+func (r *NodeObservabilityRunReconciler) warnEtcdProfilingRejected(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, errs []error) {
+	if instance.Status.ProfilingType != nodeobservabilityv1alpha2.EtcdNodeObservabilityType {
+		return
+	}
+	var rejected int
+	var last error
+	for _, err := range errs {
+		if e, ok := err.(NodeObservabilityRunError); ok && (e.HttpCode == http.StatusBadRequest || e.HttpCode == http.StatusNotFound || e.HttpCode == http.StatusNotImplemented) {
+			rejected++
+			last = err
+		}
+	}
+	if rejected == 0 {
+		return
+	}
+	r.EventRecorder.Eventf(instance, corev1.EventTypeWarning, eventReasonEtcdProfilingRejected,
+		"The agents of %d node(s) rejected the profiling of etcd, the agent image must support it and the nodes must run etcd: %s", rejected, last)
+}
+
+// runningAgents returns the number of agents profiling the nodes,
+// including the dispatched ones.
+func runningAgents(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) int {
+	var running int
+	for _, agent := range instance.Status.Agents {
+		if agent.Result == nodeobservabilityv1alpha2.AgentRunning || agent.Result == nodeobservabilityv1alpha2.AgentDispatched {
+			running++
+		}
+	}
+	return running
+}
+
+// maxConcurrentNodes returns the maximum number of nodes profiled at the same time,
+// falls back to the default one if not set in the spec.
+func maxConcurrentNodes(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) int32 {
+	if instance.Spec.MaxConcurrentNodes != nil {
+		return *instance.Spec.MaxConcurrentNodes
+	}
+	return defaultMaxConcurrentNodes
+}
+
+// failPendingAgents moves the agents which never started the profiling to the failed agents.
+func failPendingAgents(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	for _, agent := range instance.Status.PendingAgents {
+		instance.Status.FailedAgents = append(instance.Status.FailedAgents, failAgent(agent))
+	}
+	instance.Status.PendingAgents = nil
+}
+
+// failInvalidRun marks the run with an invalid spec as finished without starting it.
+func (r *NodeObservabilityRunReconciler) failInvalidRun(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, msg string) {
+	r.Log.V(1).Info("Run failed as its spec is invalid", "reason", msg)
+	t := metav1.Now()
+	instance.Status.FinishedTimestamp = &t
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInvalid, msg)
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInvalid, msg)
+	r.EventRecorder.Event(instance, corev1.EventTypeWarning, nodeobservabilityv1alpha2.ReasonInvalid, msg)
+}
+
 func (r *NodeObservabilityRunReconciler) updateStatus(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) error {
 	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
 	freshRun := &nodeobservabilityv1alpha2.NodeObservabilityRun{}
@@ -246,13 +833,127 @@ func (r *NodeObservabilityRunReconciler) updateStatus(ctx context.Context, insta
 	})
 }
 
-func (r *NodeObservabilityRunReconciler) preconditionsMet(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (bool, error) {
+// unmetPreconditions returns the reason and the message of the wait of the run
+// for NodeObservability, empty if the run can start. The runs profiling CRI-O wait
+// for the machine config changes enabling it to be rolled out on all the nodes
+// unless the run skips the check, the kubelet runs only wait for the agents.
+func (r *NodeObservabilityRunReconciler) unmetPreconditions(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (string, string, error) {
 	no := &nodeobservabilityv1alpha2.NodeObservability{}
 	key := types.NamespacedName{Name: instance.Spec.NodeObservabilityRef.Name}
 	if err := r.Get(ctx, key, no); err != nil {
-		return false, err
+		return "", "", err
+	}
+
+	if no.Spec.Type == nodeobservabilityv1alpha2.CrioKubeletNodeObservabilityType {
+		if instance.Spec.SkipMachineConfigRolloutCheck {
+			// the agents are ready even if the machine config changes are not
+			if cond := no.Status.GetCondition(nodeobservabilityv1alpha2.DaemonSetRolledOut); cond != nil && cond.Status == metav1.ConditionTrue {
+				return "", "", nil
+			}
+		} else if cond := no.Status.GetCondition(nodeobservabilityv1alpha2.MachineConfigPoolReady); cond == nil || cond.Status != metav1.ConditionTrue || no.Status.ProfilingPendingNodes != 0 {
+			msg := fmt.Sprintf("Waiting for the CRI-O profiling to be enabled on the nodes of nodeobservability %s", no.Name)
+			if cond != nil && cond.Message != "" {
+				msg = fmt.Sprintf("%s: %s", msg, cond.Message)
+			}
+			return nodeobservabilityv1alpha2.ReasonWaiting, msg, nil
+		}
+	}
+	if !no.Status.IsReady() {
+		return nodeobservabilityv1alpha2.ReasonInProgress, fmt.Sprintf("Waiting for nodeobservability %s to become ready", no.Name), nil
+	}
+	return "", "", nil
+}
+
+// runTimeout returns the maximum duration of the run,
+// falls back to the default one if not set in the spec.
+func runTimeout(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) time.Duration {
+	if instance.Spec.Timeout.Duration > 0 {
+		return instance.Spec.Timeout.Duration
+	}
+	return defaultRunTimeout
+}
+
+// profileDuration returns the duration of the CPU profiles of the run,
+// falls back to the default duration of the agents if not set in the spec.
+func profileDuration(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) time.Duration {
+	if instance.Spec.ProfileDuration.Duration > 0 {
+		return instance.Spec.ProfileDuration.Duration
+	}
+	return defaultProfileDuration
+}
+
+// validateProfileDuration returns an error if the profile duration set in the spec
+// is out of bounds or cannot complete before the timeout of the run.
+func validateProfileDuration(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) error {
+	d := instance.Spec.ProfileDuration.Duration
+	if d != 0 && (d < minProfileDuration || d > maxProfileDuration) {
+		return fmt.Errorf("profile duration %s must be between %s and %s", d, minProfileDuration, maxProfileDuration)
+	}
+	if timeout := runTimeout(instance); d > timeout {
+		return fmt.Errorf("profile duration %s must not exceed the timeout %s", d, timeout)
 	}
-	return no.Status.IsReady(), nil
+	return nil
+}
+
+// profileTypes returns the types of the profiles of the run,
+// falls back to the CPU profile if not set in the spec.
+func profileTypes(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) []nodeobservabilityv1alpha2.ProfileType {
+	if len(instance.Spec.ProfileTypes) > 0 {
+		return instance.Spec.ProfileTypes
+	}
+	return defaultProfileTypes
+}
+
+// profilingPath returns the path of the agent endpoint starting the profiling of the given type,
+// the agent is asked to skip the CRI-O profiling when only the kubelet is profiled.
+// The duration of the CPU profiles and the profile types are only passed to the agent
+// when they differ from the defaults of the agent.
+func profilingPath(profilingType nodeobservabilityv1alpha2.NodeObservabilityType, duration metav1.Duration, types []nodeobservabilityv1alpha2.ProfileType) string {
+	var params []string
+	switch profilingType {
+	case nodeobservabilityv1alpha2.KubeletNodeObservabilityType:
+		params = append(params, pprofProfilesParam+"=kubelet")
+	case nodeobservabilityv1alpha2.EtcdNodeObservabilityType:
+		params = append(params, pprofProfilesParam+"=kubelet,etcd")
+	}
+	if duration.Duration > 0 {
+		params = append(params, fmt.Sprintf("%s=%d", pprofSecondsParam, int64(duration.Seconds())))
+	}
+	if len(types) > 0 && !equality.Semantic.DeepEqual(types, defaultProfileTypes) {
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			names = append(names, string(t))
+		}
+		params = append(params, pprofTypesParam+"="+strings.Join(names, ","))
+	}
+	if len(params) == 0 {
+		return pprofPath
+	}
+	return pprofPath + "?" + strings.Join(params, "&")
+}
+
+// runArtifacts returns the profiles produced by the agents for the run,
+// the runs started before the profiling type was recorded profiled both CRI-O and the kubelet.
+// The CPU profiles keep their historical names, the other types are suffixed with the type,
+// e.g. kubelet-heap.pprof.
+func runArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) []string {
+	cpuArtifacts := profileArtifacts
+	switch instance.Status.ProfilingType {
+	case nodeobservabilityv1alpha2.KubeletNodeObservabilityType:
+		cpuArtifacts = kubeletProfileArtifacts
+	case nodeobservabilityv1alpha2.EtcdNodeObservabilityType:
+		cpuArtifacts = etcdProfileArtifacts
+	}
+	var artifacts []string
+	for _, profileType := range profileTypes(instance) {
+		for _, artifact := range cpuArtifacts {
+			if profileType != nodeobservabilityv1alpha2.CPUProfileType {
+				artifact = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(artifact, pprofExt), profileType, pprofExt)
+			}
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	return artifacts
 }
 
 func finished(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
@@ -271,39 +972,102 @@ func inProgress(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
 	return false
 }
 
-func (r *NodeObservabilityRunReconciler) httpGetCall(url string) func() error {
+// callAgent sends a request to the agent, the request is retried with an exponential backoff
+// as long as it fails for a transient reason and the given backoff allows it.
+// Each request is bounded by the given timeout. Returns the number of requests sent.
+func (r *NodeObservabilityRunReconciler) callAgent(ctx context.Context, transport http.RoundTripper, backoff wait.Backoff, timeout time.Duration, url string, header http.Header) (int32, error) {
+	var attempts int32
+	call := r.httpGetCall(ctx, transport, timeout, url, header)
+	err := retry.OnError(backoff, func(err error) bool {
+		return ctx.Err() == nil && isAgentErrorRetriable(err)
+	}, func() error {
+		attempts++
+		return call()
+	})
+	return attempts, err
+}
+
+// agentBackoff returns the backoff of the failed requests to the agents,
+// falls back to the default retries and delay if not set in the spec.
+func agentBackoff(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) wait.Backoff {
+	maxRetries := int32(defaultMaxRetries)
+	if instance.Spec.MaxRetries != nil {
+		maxRetries = *instance.Spec.MaxRetries
+	}
+	delay := defaultRetryBackoff
+	if instance.Spec.RetryBackoff.Duration > 0 {
+		delay = instance.Spec.RetryBackoff.Duration
+	}
+	return wait.Backoff{
+		Steps:    int(maxRetries) + 1,
+		Duration: delay,
+		Factor:   retryBackoffFactor,
+		Jitter:   0.1,
+	}
+}
+
+// agentRequestTimeout returns the maximum duration of a request to the agents of the run:
+// the CPU profiles are awaited for their duration plus a margin unless the spec sets a timeout.
+func (r *NodeObservabilityRunReconciler) agentRequestTimeout(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) time.Duration {
+	if instance.Spec.AgentRequestTimeout.Duration > 0 {
+		return instance.Spec.AgentRequestTimeout.Duration
+	}
+	margin := r.AgentRequestTimeoutMargin
+	if margin <= 0 {
+		margin = defaultAgentRequestTimeoutMargin
+	}
+	return profileDuration(instance) + margin
+}
+
+func (r *NodeObservabilityRunReconciler) httpGetCall(ctx context.Context, transport http.RoundTripper, timeout time.Duration, url string, header http.Header) func() error {
 	return func() error {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return err
-		}
-		req.Header.Set(authHeader, fmt.Sprintf("Bearer %s", string(r.AuthToken)))
-		client := http.Client{
-			Timeout:   time.Second * 10,
-			Transport: transport,
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
+		_, err := r.httpGet(ctx, transport, timeout, url, header)
+		return err
+	}
+}
+
+// httpGet sends an authenticated request to the agent and returns the body of the response,
+// the given header is added to the request. The request is aborted once the timeout elapses.
+func (r *NodeObservabilityRunReconciler) httpGet(ctx context.Context, transport http.RoundTripper, timeout time.Duration, url string, header http.Header) ([]byte, error) {
+	return r.httpGetArtifact(ctx, transport, timeout, url, header, 0)
+}
 
+// httpGetArtifact is httpGet bounding the body of the successful response to maxSize bytes if positive,
+// the retrieval of a larger profile is aborted.
+func (r *NodeObservabilityRunReconciler) httpGetArtifact(ctx context.Context, transport http.RoundTripper, timeout time.Duration, url string, header http.Header, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set(authHeader, fmt.Sprintf("Bearer %s", string(r.AuthToken)))
+	client := http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if resp.StatusCode != http.StatusOK {
-			return NodeObservabilityRunError{HttpCode: resp.StatusCode, Msg: string(body)}
-		}
-		return nil
+		return nil, NodeObservabilityRunError{HttpCode: resp.StatusCode, Msg: string(body)}
 	}
+	return collector.ReadArtifact(resp, maxSize)
 }
 
 func handleFailingAgent(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, old nodeobservabilityv1alpha2.AgentNode) {
 	var newAgents []nodeobservabilityv1alpha2.AgentNode
 	for _, a := range instance.Status.Agents {
 		if a.Name == old.Name {
-			instance.Status.FailedAgents = append(instance.Status.FailedAgents, old)
+			instance.Status.FailedAgents = append(instance.Status.FailedAgents, failAgent(old))
 		} else {
 			newAgents = append(newAgents, a)
 		}
@@ -312,6 +1076,240 @@ func handleFailingAgent(instance *nodeobservabilityv1alpha2.NodeObservabilityRun
 
 }
 
+// handleFinishedAgent marks the agent which finished the profiling as succeeded.
+func handleFinishedAgent(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, agent nodeobservabilityv1alpha2.AgentNode) {
+	for i := range instance.Status.Agents {
+		if instance.Status.Agents[i].Name == agent.Name {
+			t := metav1.Now()
+			instance.Status.Agents[i].FinishedTimestamp = &t
+			instance.Status.Agents[i].Result = nodeobservabilityv1alpha2.AgentSucceeded
+		}
+	}
+}
+
+// handleRunningAgent marks the dispatched agent found profiling as running,
+// its profiling was started before the operator restarted. Returns the agent.
+func handleRunningAgent(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, agent nodeobservabilityv1alpha2.AgentNode) nodeobservabilityv1alpha2.AgentNode {
+	if agent.Result != nodeobservabilityv1alpha2.AgentDispatched {
+		return agent
+	}
+	for i := range instance.Status.Agents {
+		if instance.Status.Agents[i].Name == agent.Name {
+			instance.Status.Agents[i].Result = nodeobservabilityv1alpha2.AgentRunning
+			agent = instance.Status.Agents[i]
+		}
+	}
+	return agent
+}
+
+// markInterrupted records that the run in progress was interrupted by the shutdown of the operator,
+// the runs which already timed out or were cancelled keep their condition.
+func markInterrupted(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	if !inProgress(instance) || finished(instance) || profilingAborted(instance) {
+		return
+	}
+	msg := "Profiling query interrupted by the shutdown of the operator, resumed once it restarts"
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonInterrupted, msg)
+}
+
+// failAgent returns the given agent marked as failed,
+// the time the agent finished the profiling is kept if already set.
+func failAgent(agent nodeobservabilityv1alpha2.AgentNode) nodeobservabilityv1alpha2.AgentNode {
+	agent.Result = nodeobservabilityv1alpha2.AgentFailed
+	agent.PendingUpload = nil
+	if agent.FinishedTimestamp == nil {
+		t := metav1.Now()
+		agent.FinishedTimestamp = &t
+	}
+	return agent
+}
+
+// updateNodeCounts counts the nodes targeted by the run
+// and the ones which either finished the profiling or failed.
+func updateNodeCounts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	instance.Status.TotalNodes = int32(len(instance.Status.Agents) + len(instance.Status.PendingAgents) + len(instance.Status.FailedAgents) + len(instance.Status.CancelledAgents))
+	instance.Status.FinishedNodes = int32(len(instance.Status.FailedAgents) + succeededAgents(instance))
+}
+
+// updatePhase sets the overall phase of the run from its progress.
+func updatePhase(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	switch {
+	case finished(instance):
+		if cancelled(instance) {
+			instance.Status.Phase = nodeobservabilityv1alpha2.RunCancelled
+			return
+		}
+		if !runSucceeded(instance) {
+			instance.Status.Phase = nodeobservabilityv1alpha2.RunFailed
+			return
+		}
+		instance.Status.Phase = nodeobservabilityv1alpha2.RunSucceeded
+	case inProgress(instance):
+		instance.Status.Phase = nodeobservabilityv1alpha2.RunRunning
+	default:
+		instance.Status.Phase = nodeobservabilityv1alpha2.RunPending
+	}
+}
+
+// updateDuration records the time the finished run took,
+// the runs which finished without starting have no duration.
+func updateDuration(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	if instance.Status.StartTimestamp == nil || instance.Status.FinishedTimestamp == nil {
+		return
+	}
+	instance.Status.Duration = &metav1.Duration{Duration: instance.Status.FinishedTimestamp.Sub(instance.Status.StartTimestamp.Time).Round(time.Second)}
+}
+
+// runSucceeded returns true if the profiling of the finished run
+// succeeded on enough nodes according to its failure policy.
+func runSucceeded(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) bool {
+	cond := instance.Status.GetCondition(nodeobservabilityv1alpha2.DebugFinished)
+	if cond == nil {
+		return false
+	}
+	if runFailurePolicy(instance) == nodeobservabilityv1alpha2.AllOrNothingFailurePolicy {
+		return cond.Status == metav1.ConditionTrue && len(instance.Status.FailedAgents) == 0
+	}
+	// the nodes which timed out are counted as failed nodes,
+	// the rejected runs never succeed
+	if cond.Status != metav1.ConditionTrue && cond.Reason != nodeobservabilityv1alpha2.ReasonFailed {
+		return false
+	}
+	succeeded := succeededAgents(instance)
+	total := len(instance.Status.Agents) + len(instance.Status.FailedAgents)
+	return succeeded > 0 && succeeded*100 >= int(minSucceededNodesPercent(instance))*total
+}
+
+// recordRunResult emits an event summarizing the nodes
+// on which the profiling of the finished run succeeded and failed.
+func (r *NodeObservabilityRunReconciler) recordRunResult(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	msg := fmt.Sprintf("Profiling succeeded on %d node(s) and failed on %d node(s)", succeededAgents(instance), len(instance.Status.FailedAgents))
+	if runSucceeded(instance) {
+		r.EventRecorder.Event(instance, corev1.EventTypeNormal, nodeobservabilityv1alpha2.ReasonFinished, msg)
+		return
+	}
+	r.EventRecorder.Event(instance, corev1.EventTypeWarning, nodeobservabilityv1alpha2.ReasonFailed, msg)
+}
+
+// succeededAgents returns the number of agents which finished the profiling.
+func succeededAgents(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) int {
+	var succeeded int
+	for _, a := range instance.Status.Agents {
+		if a.Result == nodeobservabilityv1alpha2.AgentSucceeded {
+			succeeded++
+		}
+	}
+	return succeeded
+}
+
+// runFailurePolicy returns how the failed nodes affect the result of the run,
+// falls back to the default one if not set in the spec.
+func runFailurePolicy(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) nodeobservabilityv1alpha2.RunFailurePolicy {
+	if instance.Spec.FailurePolicy != "" {
+		return instance.Spec.FailurePolicy
+	}
+	return nodeobservabilityv1alpha2.AllOrNothingFailurePolicy
+}
+
+// minSucceededNodesPercent returns the minimum percentage of the nodes on which the profiling
+// must succeed with the BestEffort failure policy, falls back to the default one if not set in the spec.
+func minSucceededNodesPercent(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) int32 {
+	if instance.Spec.MinSucceededNodesPercent != nil {
+		return *instance.Spec.MinSucceededNodesPercent
+	}
+	return defaultMinSucceededNodesPercent
+}
+
+// selectedNodes returns the names of the nodes listed by the run or matching its node selector,
+// nil if the run has neither nodes nor node selector and targets all the agents.
+func (r *NodeObservabilityRunReconciler) selectedNodes(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (nodeSet, error) {
+	if len(instance.Spec.Nodes) > 0 {
+		selected := nodeSet{}
+		for _, name := range instance.Spec.Nodes {
+			selected[name] = struct{}{}
+		}
+		return selected, nil
+	}
+	if len(instance.Spec.NodeSelector) == 0 {
+		return nil, nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels(instance.Spec.NodeSelector)); err != nil {
+		return nil, fmt.Errorf("failed to list the nodes selected by the run: %w", err)
+	}
+	selected := nodeSet{}
+	for _, node := range nodes.Items {
+		selected[node.Name] = struct{}{}
+	}
+	return selected, nil
+}
+
+// unavailableNodes checks that the nodes listed by the run exist and have a running agent.
+// Returns a message describing the nodes which can't be profiled, empty if all of them can.
+func (r *NodeObservabilityRunReconciler) unavailableNodes(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (string, error) {
+	if len(instance.Spec.Nodes) == 0 {
+		return "", nil
+	}
+	endps, err := r.getAgentEndpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+	running := nodeSet{}
+	for _, a := range endps.Subsets[0].Addresses {
+		running[nodeName(a)] = struct{}{}
+	}
+	var problems []string
+	for _, name := range instance.Spec.Nodes {
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &corev1.Node{}); err != nil {
+			if !errors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to get node %q: %w", name, err)
+			}
+			problems = append(problems, fmt.Sprintf("node %q not found", name))
+			continue
+		}
+		if !running.has(name) {
+			problems = append(problems, fmt.Sprintf("node %q has no running agent", name))
+		}
+	}
+	if len(problems) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("Unable to profile the listed nodes: %s", strings.Join(problems, ", ")), nil
+}
+
+// nodeSet is a set of node names, nil selects all the nodes.
+type nodeSet map[string]struct{}
+
+// has returns true if the given node is selected.
+func (s nodeSet) has(name string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[name]
+	return ok
+}
+
+// list returns the sorted names of the selected nodes, nil if all the nodes are selected.
+func (s nodeSet) list() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nodeName returns the name of the node of the given agent endpoint address.
+func nodeName(address corev1.EndpointAddress) string {
+	if address.NodeName != nil {
+		return *address.NodeName
+	}
+	return ""
+}
+
 func (r *NodeObservabilityRunReconciler) getAgentEndpoints(ctx context.Context) (*corev1.Endpoints, error) {
 	endpoints := &corev1.Endpoints{}
 	if err := r.Get(ctx, types.NamespacedName{Name: r.AgentName, Namespace: r.Namespace}, endpoints); err != nil {
@@ -328,15 +1326,9 @@ func (r *NodeObservabilityRunReconciler) getAgentEndpoints(ctx context.Context)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeObservabilityRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	t := http.DefaultTransport.(*http.Transport).Clone()
-	t.TLSClientConfig = &tls.Config{
-		RootCAs:                  r.CACert,
-		MinVersion:               tls.VersionTLS12,
-		PreferServerCipherSuites: true,
-	}
-	transport = t
 	r.URL = &url{}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&nodeobservabilityv1alpha2.NodeObservabilityRun{}).
+		Watches(&source.Kind{Type: &nodeobservabilityv1alpha2.NodeObservabilityRun{}}, r.cancelHandler()).
 		Complete(r)
 }
@@ -3,21 +3,27 @@ package nodeobservabilityruncontroller
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"net/http/httptest"
+	"path"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -115,7 +121,7 @@ func TestHandleFailingAgent(t *testing.T) {
 			inputFailed:    nil,
 			failingNode:    operatorv1alpha2.AgentNode{Name: "failing"},
 			expectedAgents: []operatorv1alpha2.AgentNode{{Name: "good"}},
-			expectedFailed: []operatorv1alpha2.AgentNode{{Name: "failing"}},
+			expectedFailed: []operatorv1alpha2.AgentNode{{Name: "failing", Result: operatorv1alpha2.AgentFailed}},
 		},
 		{
 			// not in list
@@ -131,7 +137,7 @@ func TestHandleFailingAgent(t *testing.T) {
 			inputFailed:    []operatorv1alpha2.AgentNode{{Name: "one"}, {Name: "two"}, {Name: "three"}},
 			failingNode:    operatorv1alpha2.AgentNode{Name: "failing"},
 			expectedAgents: nil,
-			expectedFailed: []operatorv1alpha2.AgentNode{{Name: "one"}, {Name: "two"}, {Name: "three"}, {Name: "failing"}},
+			expectedFailed: []operatorv1alpha2.AgentNode{{Name: "one"}, {Name: "two"}, {Name: "three"}, {Name: "failing", Result: operatorv1alpha2.AgentFailed}},
 		},
 	}
 	for _, tc := range cases {
@@ -143,7 +149,7 @@ func TestHandleFailingAgent(t *testing.T) {
 		if !reflect.DeepEqual(tc.expectedAgents, testInstance.Status.Agents) {
 			t.Fatalf("Agents: expected result %v, got %v", tc.expectedAgents, testInstance.Status.Agents)
 		}
-		if !reflect.DeepEqual(tc.expectedFailed, testInstance.Status.FailedAgents) {
+		if !reflect.DeepEqual(tc.expectedFailed, withoutTimestamps(testInstance.Status.FailedAgents)) {
 			t.Fatalf("FailingAgents: expected result %v, got %v", tc.expectedFailed, testInstance.Status.FailedAgents)
 		}
 	}
@@ -211,6 +217,105 @@ func TestIsInProgress(t *testing.T) {
 	}
 }
 
+func TestUnmetPreconditions(t *testing.T) {
+	condition := func(condType string, status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{Type: condType, Status: status, Message: "1 of 3 machines updated"}
+	}
+	testCases := []struct {
+		name           string
+		nodeObsType    operatorv1alpha2.NodeObservabilityType
+		conditions     []metav1.Condition
+		pendingNodes   int32
+		skipCheck      bool
+		expectedReason string
+	}{
+		{
+			name:        "kubelet ready",
+			nodeObsType: operatorv1alpha2.KubeletNodeObservabilityType,
+			conditions:  []metav1.Condition{condition(operatorv1alpha2.DebugReady, metav1.ConditionTrue)},
+		},
+		{
+			name:           "kubelet not ready",
+			nodeObsType:    operatorv1alpha2.KubeletNodeObservabilityType,
+			conditions:     []metav1.Condition{condition(operatorv1alpha2.DebugReady, metav1.ConditionFalse)},
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+		},
+		{
+			name:        "crio ready",
+			nodeObsType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			conditions: []metav1.Condition{
+				condition(operatorv1alpha2.DebugReady, metav1.ConditionTrue),
+				condition(operatorv1alpha2.MachineConfigPoolReady, metav1.ConditionTrue),
+			},
+		},
+		{
+			name:        "crio machineconfigpool updating",
+			nodeObsType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			conditions: []metav1.Condition{
+				condition(operatorv1alpha2.DebugReady, metav1.ConditionFalse),
+				condition(operatorv1alpha2.DaemonSetRolledOut, metav1.ConditionTrue),
+				condition(operatorv1alpha2.MachineConfigPoolReady, metav1.ConditionFalse),
+			},
+			expectedReason: operatorv1alpha2.ReasonWaiting,
+		},
+		{
+			name:        "crio profiling pending on some nodes",
+			nodeObsType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			conditions: []metav1.Condition{
+				condition(operatorv1alpha2.DebugReady, metav1.ConditionTrue),
+				condition(operatorv1alpha2.MachineConfigPoolReady, metav1.ConditionTrue),
+			},
+			pendingNodes:   2,
+			expectedReason: operatorv1alpha2.ReasonWaiting,
+		},
+		{
+			name:        "crio machineconfigpool updating with skipped check",
+			nodeObsType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			conditions: []metav1.Condition{
+				condition(operatorv1alpha2.DebugReady, metav1.ConditionFalse),
+				condition(operatorv1alpha2.DaemonSetRolledOut, metav1.ConditionTrue),
+				condition(operatorv1alpha2.MachineConfigPoolReady, metav1.ConditionFalse),
+			},
+			pendingNodes: 2,
+			skipCheck:    true,
+		},
+		{
+			name:        "crio agents not ready with skipped check",
+			nodeObsType: operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			conditions: []metav1.Condition{
+				condition(operatorv1alpha2.DebugReady, metav1.ConditionFalse),
+				condition(operatorv1alpha2.DaemonSetRolledOut, metav1.ConditionFalse),
+			},
+			skipCheck:      true,
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = tc.nodeObsType
+			nodeObs.Status.Conditions = tc.conditions
+			nodeObs.Status.ProfilingPendingNodes = tc.pendingNodes
+			r := &NodeObservabilityRunReconciler{
+				Client: fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs).Build(),
+			}
+			run := testNodeObservabilityRun()
+			run.Spec.SkipMachineConfigRolloutCheck = tc.skipCheck
+
+			reason, msg, err := r.unmetPreconditions(context.TODO(), run)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+			if reason == operatorv1alpha2.ReasonWaiting && !strings.Contains(msg, "1 of 3 machines updated") {
+				t.Errorf("expected the message to report the machineconfigpool rollout, got %q", msg)
+			}
+		})
+	}
+}
+
 func TestReconcile(t *testing.T) {
 	go func() {
 		serverError := fakeHttpServer()
@@ -292,10 +397,11 @@ func TestReconcile(t *testing.T) {
 	for _, tc := range cases {
 		cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
 		r := NodeObservabilityRunReconciler{
-			Client:    cl,
-			URL:       &testURL{},
-			AgentName: name,
-			Namespace: namespace,
+			Client:        cl,
+			EventRecorder: record.NewFakeRecorder(10),
+			URL:           &testURL{},
+			AgentName:     name,
+			Namespace:     namespace,
 		}
 		res, err := r.Reconcile(ctx, tc.req)
 		if err != nil {
@@ -322,92 +428,1669 @@ func TestReconcile(t *testing.T) {
 
 }
 
-// testNodeObservabilityRun - minimal CR for the test
-func testNodeObservabilityRun() *operatorv1alpha2.NodeObservabilityRun {
-	return &operatorv1alpha2.NodeObservabilityRun{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Name: nodeObsName,
-				},
+func TestReconcileTimeout(t *testing.T) {
+	doneServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer doneServer.Close()
+	// the busy agent never finishes the profiling
+	busyServer := httptest.NewTLSServer(http.HandlerFunc(conflict))
+	defer busyServer.Close()
+
+	defaultTransport := transport
+	transport = doneServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	doneAgent := testAgentNode("done", doneServer)
+	busyAgent := testAgentNode("busy", busyServer)
+	now := metav1.Now()
+	longAgo := metav1.NewTime(now.Add(-time.Hour))
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name                 string
+		timeout              time.Duration
+		status               operatorv1alpha2.NodeObservabilityRunStatus
+		res                  ctrl.Result
+		finished             bool
+		expectedReason       string
+		expectedPhase        operatorv1alpha2.NodeObservabilityRunPhase
+		expectedAgents       []operatorv1alpha2.AgentNode
+		expectedFailedAgents []operatorv1alpha2.AgentNode
+	}{
+		{
+			name: "agent still running before timeout",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{doneAgent, busyAgent},
 			},
+			res:            ctrl.Result{RequeueAfter: pollingPeriod},
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+			expectedPhase:  operatorv1alpha2.RunRunning,
+			expectedAgents: []operatorv1alpha2.AgentNode{withResult(doneAgent, operatorv1alpha2.AgentSucceeded), busyAgent},
 		},
-		Spec: operatorv1alpha2.NodeObservabilityRunSpec{
-			NodeObservabilityRef: &operatorv1alpha2.NodeObservabilityRef{
-				Name: nodeObsName,
+		{
+			name: "all agents done before timeout",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &longAgo,
+				Agents:         []operatorv1alpha2.AgentNode{doneAgent},
 			},
+			timeout:        2 * time.Hour,
+			res:            ctrl.Result{},
+			finished:       true,
+			expectedReason: operatorv1alpha2.ReasonFinished,
+			expectedPhase:  operatorv1alpha2.RunSucceeded,
+			expectedAgents: []operatorv1alpha2.AgentNode{withAgentArtifacts(withResult(doneAgent, operatorv1alpha2.AgentSucceeded))},
 		},
-		Status: operatorv1alpha2.NodeObservabilityRunStatus{},
+		{
+			name: "default timeout reached",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &longAgo,
+				Agents:         []operatorv1alpha2.AgentNode{doneAgent, busyAgent},
+			},
+			res:                  ctrl.Result{},
+			finished:             true,
+			expectedReason:       operatorv1alpha2.ReasonFailed,
+			expectedPhase:        operatorv1alpha2.RunFailed,
+			expectedAgents:       []operatorv1alpha2.AgentNode{withAgentArtifacts(withResult(doneAgent, operatorv1alpha2.AgentSucceeded))},
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(busyAgent, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name: "custom timeout not reached",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &longAgo,
+				Agents:         []operatorv1alpha2.AgentNode{busyAgent},
+			},
+			timeout:        2 * time.Hour,
+			res:            ctrl.Result{RequeueAfter: pollingPeriod},
+			expectedReason: operatorv1alpha2.ReasonInProgress,
+			expectedPhase:  operatorv1alpha2.RunRunning,
+			expectedAgents: []operatorv1alpha2.AgentNode{busyAgent},
+		},
+		{
+			name: "custom timeout reached",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &longAgo,
+				Agents:         []operatorv1alpha2.AgentNode{busyAgent},
+			},
+			timeout:              30 * time.Minute,
+			res:                  ctrl.Result{},
+			finished:             true,
+			expectedReason:       operatorv1alpha2.ReasonFailed,
+			expectedPhase:        operatorv1alpha2.RunFailed,
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(busyAgent, operatorv1alpha2.AgentFailed)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(tc.status)
+			run.Spec.Timeout = metav1.Duration{Duration: tc.timeout}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			res, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			if !reflect.DeepEqual(res, tc.res) {
+				t.Fatalf("expected result %v, got %v", tc.res, res)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if finished(got) != tc.finished {
+				t.Fatalf("expected finished to be %t, got %t", tc.finished, finished(got))
+			}
+			cond := got.Status.GetCondition(operatorv1alpha2.DebugFinished)
+			if cond == nil || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected %s condition with reason %q, got %v", operatorv1alpha2.DebugFinished, tc.expectedReason, cond)
+			}
+			if got.Status.Phase != tc.expectedPhase {
+				t.Fatalf("expected phase %q, got %q", tc.expectedPhase, got.Status.Phase)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.Agents), tc.expectedAgents) {
+				t.Fatalf("expected agents %v, got %v", tc.expectedAgents, got.Status.Agents)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.FailedAgents), tc.expectedFailedAgents) {
+				t.Fatalf("expected failed agents %v, got %v", tc.expectedFailedAgents, got.Status.FailedAgents)
+			}
+		})
 	}
 }
 
-// testNodeObservability - minimal CR for the test
-func testNodeObservability() *operatorv1alpha2.NodeObservability {
-	return &operatorv1alpha2.NodeObservability{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: nodeObsName,
-		},
-		Spec: operatorv1alpha2.NodeObservabilitySpec{},
-		Status: operatorv1alpha2.NodeObservabilityStatus{
-			ConditionalStatus: operatorv1alpha2.ConditionalStatus{
-				Conditions: []metav1.Condition{
-					{
-						Type:   operatorv1alpha2.DebugReady,
-						Status: metav1.ConditionTrue,
-					},
-				},
+func TestReconcileAgentProgress(t *testing.T) {
+	doneServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer doneServer.Close()
+	busyServer := httptest.NewTLSServer(http.HandlerFunc(conflict))
+	defer busyServer.Close()
+	// the agents which finished the profiling are no longer polled
+	failingServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	defaultTransport := transport
+	transport = doneServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	now := metav1.Now()
+	// the timestamps are serialized with a second precision
+	earlier := metav1.NewTime(now.Add(-time.Minute).Truncate(time.Second))
+	running := func(name string, server *httptest.Server) operatorv1alpha2.AgentNode {
+		agent := withResult(testAgentNode(name, server), operatorv1alpha2.AgentRunning)
+		agent.StartTimestamp = &now
+		return agent
+	}
+	succeeded := withResult(testAgentNode("succeeded", failingServer), operatorv1alpha2.AgentSucceeded)
+	succeeded.FinishedTimestamp = &earlier
+	failed := withResult(testAgentNode("failed", failingServer), operatorv1alpha2.AgentFailed)
+	failed.FinishedTimestamp = &earlier
+
+	run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+		StartTimestamp: &now,
+		Agents:         []operatorv1alpha2.AgentNode{running("done", doneServer), running("busy", busyServer), succeeded},
+		FailedAgents:   []operatorv1alpha2.AgentNode{failed},
+	})
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run).Build()
+	r := NodeObservabilityRunReconciler{
+		Client:    cl,
+		URL:       &testURL{},
+		AgentName: name,
+		Namespace: namespace,
+	}
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("reconciler error: %v", err)
+	}
+
+	got := &operatorv1alpha2.NodeObservabilityRun{}
+	if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+		t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+	}
+	if finished(got) {
+		t.Fatalf("expected run not to be finished")
+	}
+	expectedResults := map[string]operatorv1alpha2.AgentResult{
+		"done":      operatorv1alpha2.AgentSucceeded,
+		"busy":      operatorv1alpha2.AgentRunning,
+		"succeeded": operatorv1alpha2.AgentSucceeded,
+	}
+	if len(got.Status.Agents) != len(expectedResults) {
+		t.Fatalf("expected %d agents, got %v", len(expectedResults), got.Status.Agents)
+	}
+	for _, agent := range got.Status.Agents {
+		if agent.Result != expectedResults[agent.Name] {
+			t.Errorf("expected agent %s to be %s, got %s", agent.Name, expectedResults[agent.Name], agent.Result)
+		}
+		if (agent.FinishedTimestamp != nil) != (agent.Result == operatorv1alpha2.AgentSucceeded) {
+			t.Errorf("unexpected finished timestamp %v of %s agent %s", agent.FinishedTimestamp, agent.Result, agent.Name)
+		}
+		if agent.Name == "succeeded" && !agent.FinishedTimestamp.Equal(&earlier) {
+			t.Errorf("expected finished timestamp of agent %s to be kept, got %v", agent.Name, agent.FinishedTimestamp)
+		}
+	}
+	if got.Status.TotalNodes != 4 || got.Status.FinishedNodes != 3 {
+		t.Fatalf("expected 3/4 finished nodes, got %d/%d", got.Status.FinishedNodes, got.Status.TotalNodes)
+	}
+}
+
+func TestStartRunAgents(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer server.Close()
+
+	defaultTransport := transport
+	transport = server.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	agent := testAgentNode("agent", server)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses:         []corev1.EndpointAddress{{IP: agent.IP, NodeName: pointer.String("node-1"), TargetRef: &corev1.ObjectReference{Name: "agent"}}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2", NodeName: pointer.String("node-2"), TargetRef: &corev1.ObjectReference{Name: "not-ready"}}},
+				Ports:             []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
 			},
 		},
 	}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), endpoints).Build()
+	r := NodeObservabilityRunReconciler{
+		Client:    cl,
+		Log:       zap.New(zap.UseDevMode(true)),
+		URL:       &testURL{},
+		AgentName: name,
+		Namespace: namespace,
+	}
+
+	run := testNodeObservabilityRun()
+	if err := cl.Create(context.Background(), run); err != nil {
+		t.Fatalf("failed to create the run: %v", err)
+	}
+	if err := r.startRun(context.Background(), run, transport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updateNodeCounts(run)
+
+	expectedAgents := []operatorv1alpha2.AgentNode{
+		{Name: "agent", NodeName: "node-1", IP: agent.IP, Port: agent.Port, Attempts: 1, Result: operatorv1alpha2.AgentRunning, ProfileDuration: &metav1.Duration{Duration: defaultProfileDuration}},
+	}
+	if !reflect.DeepEqual(withoutTimestamps(run.Status.Agents), expectedAgents) {
+		t.Fatalf("expected agents %v, got %v", expectedAgents, run.Status.Agents)
+	}
+	if run.Status.Agents[0].StartTimestamp == nil || run.Status.Agents[0].FinishedTimestamp != nil {
+		t.Fatalf("expected agent to be started only, got %v", run.Status.Agents[0])
+	}
+	expectedFailedAgents := []operatorv1alpha2.AgentNode{
+		{Name: "not-ready", NodeName: "node-2", IP: "10.0.0.2", Port: agent.Port, Result: operatorv1alpha2.AgentFailed},
+	}
+	if !reflect.DeepEqual(withoutTimestamps(run.Status.FailedAgents), expectedFailedAgents) {
+		t.Fatalf("expected failed agents %v, got %v", expectedFailedAgents, run.Status.FailedAgents)
+	}
+	if run.Status.FailedAgents[0].FinishedTimestamp == nil {
+		t.Fatalf("expected failed agent to be finished, got %v", run.Status.FailedAgents[0])
+	}
+	if run.Status.TotalNodes != 2 || run.Status.FinishedNodes != 1 {
+		t.Fatalf("expected 1/2 finished nodes, got %d/%d", run.Status.FinishedNodes, run.Status.TotalNodes)
+	}
 }
 
-func testNodeObservabilityRunWithStatus(s operatorv1alpha2.NodeObservabilityRunStatus) *operatorv1alpha2.NodeObservabilityRun {
+func TestStartRunMaxConcurrentNodes(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer server.Close()
+
+	defaultTransport := transport
+	transport = server.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	agent := testAgentNode("agent", server)
+	var addresses []corev1.EndpointAddress
+	for _, n := range []string{"agent-1", "agent-2", "agent-3"} {
+		addresses = append(addresses, corev1.EndpointAddress{IP: agent.IP, TargetRef: &corev1.ObjectReference{Name: n}})
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: addresses,
+				Ports:     []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+			},
+		},
+	}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), endpoints).Build()
+	r := NodeObservabilityRunReconciler{
+		Client:    cl,
+		Log:       zap.New(zap.UseDevMode(true)),
+		URL:       &testURL{},
+		AgentName: name,
+		Namespace: namespace,
+	}
+
 	run := testNodeObservabilityRun()
-	run.Status = s
-	return run
+	run.Spec.MaxConcurrentNodes = pointer.Int32(2)
+	if err := cl.Create(context.Background(), run); err != nil {
+		t.Fatalf("failed to create the run: %v", err)
+	}
+	if err := r.startRun(context.Background(), run, transport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updateNodeCounts(run)
+
+	if len(run.Status.Agents) != 2 || run.Status.Agents[0].Name != "agent-1" || run.Status.Agents[1].Name != "agent-2" {
+		t.Fatalf("expected agent-1 and agent-2 to be started, got %v", run.Status.Agents)
+	}
+	expectedPending := []operatorv1alpha2.AgentNode{{Name: "agent-3", IP: agent.IP, Port: agent.Port}}
+	if !reflect.DeepEqual(run.Status.PendingAgents, expectedPending) {
+		t.Fatalf("expected pending agents %v, got %v", expectedPending, run.Status.PendingAgents)
+	}
+	if run.Status.TotalNodes != 3 || run.Status.PeakConcurrentNodes != 2 {
+		t.Fatalf("expected 3 nodes with 2 at most profiled at the same time, got %d with %d", run.Status.TotalNodes, run.Status.PeakConcurrentNodes)
+	}
+
+	// no slot frees up while the started agents are profiling
+	if started := r.startPendingAgents(context.Background(), run, transport, nil); len(started) != 0 {
+		t.Fatalf("expected no agent to be started, got %v", started)
+	}
+
+	run.Status.Agents[0].Result = operatorv1alpha2.AgentSucceeded
+	started := r.startPendingAgents(context.Background(), run, transport, nil)
+	if len(started) != 1 || started[0].Name != "agent-3" || started[0].Result != operatorv1alpha2.AgentRunning {
+		t.Fatalf("expected agent-3 to be started, got %v", started)
+	}
+	if len(run.Status.PendingAgents) != 0 || len(run.Status.Agents) != 3 {
+		t.Fatalf("expected all agents to be started, got %d pending and %d started", len(run.Status.PendingAgents), len(run.Status.Agents))
+	}
+	if run.Status.PeakConcurrentNodes != 2 {
+		t.Fatalf("expected 2 nodes at most profiled at the same time, got %d", run.Status.PeakConcurrentNodes)
+	}
 }
 
-func fakeHttpServer() error {
-	caCert, err := readCACert(CAPath)
-	if err != nil {
-		return err
+func TestReconcilePollingFailureWithPendingAgents(t *testing.T) {
+	busyServer := httptest.NewTLSServer(http.HandlerFunc(conflict))
+	defer busyServer.Close()
+	failingServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	defaultTransport := transport
+	transport = busyServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	now := metav1.Now()
+	running := func(name string, server *httptest.Server) operatorv1alpha2.AgentNode {
+		agent := withResult(testAgentNode(name, server), operatorv1alpha2.AgentRunning)
+		agent.StartTimestamp = &now
+		return agent
 	}
-	t := http.DefaultTransport.(*http.Transport).Clone()
-	t.TLSClientConfig = &tls.Config{
-		RootCAs:                  caCert,
-		MinVersion:               tls.VersionTLS12,
-		PreferServerCipherSuites: true,
+	queued := testAgentNode("queued", busyServer)
+	run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+		StartTimestamp: &now,
+		Agents:         []operatorv1alpha2.AgentNode{running("busy", busyServer), running("failing", failingServer)},
+		PendingAgents:  []operatorv1alpha2.AgentNode{queued},
+	})
+	// the busy agent keeps the only slot, the queued agent is not started
+	run.Spec.MaxConcurrentNodes = pointer.Int32(1)
+	run.Spec.MaxRetries = pointer.Int32(0)
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run).Build()
+	r := NodeObservabilityRunReconciler{
+		Client:    cl,
+		URL:       &testURL{},
+		AgentName: name,
+		Namespace: namespace,
+	}
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	res, err := r.Reconcile(ctx, req)
+	if err == nil || !strings.Contains(err.Error(), `failed to get the status of the agent named "failing"`) {
+		t.Fatalf("expected the polling failure to be returned, got %v", err)
+	}
+	if res.RequeueAfter != pollingPeriod {
+		t.Fatalf("expected to be requeued after %s, got %v", pollingPeriod, res)
 	}
-	transport = t
 
-	http.HandleFunc("/node-observability-pprof", pong)
-	http.HandleFunc("/node-observability-status", pong)
-	server := &http.Server{
-		Addr:              "127.0.0.1:8443",
-		ReadHeaderTimeout: 10 * time.Second,
+	got := &operatorv1alpha2.NodeObservabilityRun{}
+	if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+		t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+	}
+	if len(got.Status.PendingAgents) != 1 || got.Status.PendingAgents[0].Name != queued.Name {
+		t.Fatalf("expected agent %s to stay queued, got %v", queued.Name, got.Status.PendingAgents)
+	}
+	if len(got.Status.FailedAgents) != 1 || got.Status.FailedAgents[0].Name != "failing" {
+		t.Fatalf("expected agent failing to be failed, got %v", got.Status.FailedAgents)
 	}
-	return server.ListenAndServeTLS(certPath, keyPath)
 }
 
-func pong(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
-	_, _ = w.Write([]byte("pong\n"))
+func TestStartRunNodeSelector(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer server.Close()
+
+	defaultTransport := transport
+	transport = server.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	agent := testAgentNode("agent", server)
+	address := func(agentName, nodeName string) corev1.EndpointAddress {
+		return corev1.EndpointAddress{IP: agent.IP, NodeName: pointer.String(nodeName), TargetRef: &corev1.ObjectReference{Name: agentName}}
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses:         []corev1.EndpointAddress{address("agent-1", "node-1"), address("agent-2", "node-2"), address("agent-3", "node-3")},
+				NotReadyAddresses: []corev1.EndpointAddress{address("agent-4", "node-4"), address("agent-5", "node-5")},
+				Ports:             []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+			},
+		},
+	}
+	node := func(name string, labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	highLoad := map[string]string{"load": "high"}
+
+	testCases := []struct {
+		name                  string
+		nodeSelector          map[string]string
+		nodes                 []string
+		expectedAgents        []string
+		expectedFailedAgents  []string
+		expectedSelectedNodes []string
+	}{
+		{
+			name:                 "all agents",
+			expectedAgents:       []string{"agent-1", "agent-2", "agent-3"},
+			expectedFailedAgents: []string{"agent-4", "agent-5"},
+		},
+		{
+			name:                  "selected agents",
+			nodeSelector:          highLoad,
+			expectedAgents:        []string{"agent-1", "agent-3"},
+			expectedFailedAgents:  []string{"agent-5"},
+			expectedSelectedNodes: []string{"node-1", "node-3", "node-5"},
+		},
+		{
+			name:                  "no node selected",
+			nodeSelector:          map[string]string{"load": "none"},
+			expectedSelectedNodes: []string{},
+		},
+		{
+			name:                  "single listed node",
+			nodes:                 []string{"node-2"},
+			expectedAgents:        []string{"agent-2"},
+			expectedSelectedNodes: []string{"node-2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(
+				testNodeObservability(), endpoints,
+				node("node-1", highLoad), node("node-2", nil), node("node-3", highLoad), node("node-4", nil), node("node-5", highLoad),
+			).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:    cl,
+				Log:       zap.New(zap.UseDevMode(true)),
+				URL:       &testURL{},
+				AgentName: name,
+				Namespace: namespace,
+			}
+
+			run := testNodeObservabilityRun()
+			run.Spec.NodeSelector = tc.nodeSelector
+			run.Spec.Nodes = tc.nodes
+			if err := cl.Create(context.Background(), run); err != nil {
+				t.Fatalf("failed to create the run: %v", err)
+			}
+			if err := r.startRun(context.Background(), run, transport); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			updateNodeCounts(run)
+
+			agentNames := func(agents []operatorv1alpha2.AgentNode) []string {
+				var names []string
+				for _, a := range agents {
+					names = append(names, a.Name)
+				}
+				return names
+			}
+			if got := agentNames(run.Status.Agents); !reflect.DeepEqual(got, tc.expectedAgents) {
+				t.Errorf("expected agents %v, got %v", tc.expectedAgents, got)
+			}
+			if got := agentNames(run.Status.FailedAgents); !reflect.DeepEqual(got, tc.expectedFailedAgents) {
+				t.Errorf("expected failed agents %v, got %v", tc.expectedFailedAgents, got)
+			}
+			if got := run.Status.SelectedNodes; !reflect.DeepEqual(got, tc.expectedSelectedNodes) {
+				t.Errorf("expected selected nodes %v, got %v", tc.expectedSelectedNodes, got)
+			}
+			if expected := int32(len(tc.expectedAgents) + len(tc.expectedFailedAgents)); run.Status.TotalNodes != expected {
+				t.Errorf("expected %d targeted nodes, got %d", expected, run.Status.TotalNodes)
+			}
+		})
+	}
 }
 
-func readCACert(caCertFile string) (*x509.CertPool, error) {
-	content, err := os.ReadFile(caCertFile)
-	if err != nil {
-		return nil, err
+func TestUnavailableNodes(t *testing.T) {
+	address := func(agentName, nodeName string) corev1.EndpointAddress {
+		return corev1.EndpointAddress{IP: "127.0.0.1", NodeName: pointer.String(nodeName), TargetRef: &corev1.ObjectReference{Name: agentName}}
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses:         []corev1.EndpointAddress{address("agent-1", "node-1")},
+				NotReadyAddresses: []corev1.EndpointAddress{address("agent-2", "node-2")},
+				Ports:             []corev1.EndpointPort{{Name: "test-port", Port: 8443}},
+			},
+		},
+	}
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	testCases := []struct {
+		name        string
+		nodes       []string
+		expectedMsg string
+	}{
+		{
+			name: "no listed nodes",
+		},
+		{
+			name:  "node with a running agent",
+			nodes: []string{"node-1"},
+		},
+		{
+			name:        "node not found",
+			nodes:       []string{"node-1", "node-9"},
+			expectedMsg: `Unable to profile the listed nodes: node "node-9" not found`,
+		},
+		{
+			name:        "agent not ready",
+			nodes:       []string{"node-2"},
+			expectedMsg: `Unable to profile the listed nodes: node "node-2" has no running agent`,
+		},
+		{
+			name:        "node without agent",
+			nodes:       []string{"node-3", "node-9"},
+			expectedMsg: `Unable to profile the listed nodes: node "node-3" has no running agent, node "node-9" not found`,
+		},
 	}
-	if len(content) <= 0 {
-		return nil, fmt.Errorf("%s is empty", caCertFile)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(
+				endpoints, node("node-1"), node("node-2"), node("node-3"),
+			).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:    cl,
+				Log:       zap.New(zap.UseDevMode(true)),
+				AgentName: name,
+				Namespace: namespace,
+			}
+
+			run := testNodeObservabilityRun()
+			run.Spec.Nodes = tc.nodes
+			msg, err := r.unavailableNodes(context.Background(), run)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg != tc.expectedMsg {
+				t.Errorf("expected message %q, got %q", tc.expectedMsg, msg)
+			}
+		})
+	}
+}
+
+func TestUpdatePhase(t *testing.T) {
+	now := metav1.Now()
+	finishedWith := func(status metav1.ConditionStatus, reason string, failedAgents ...operatorv1alpha2.AgentNode) operatorv1alpha2.NodeObservabilityRunStatus {
+		s := operatorv1alpha2.NodeObservabilityRunStatus{
+			StartTimestamp:    &now,
+			FinishedTimestamp: &now,
+			Agents:            []operatorv1alpha2.AgentNode{{Name: "succeeded", Result: operatorv1alpha2.AgentSucceeded}},
+			FailedAgents:      failedAgents,
+		}
+		s.SetCondition(operatorv1alpha2.DebugFinished, status, reason, "")
+		return s
 	}
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(content) {
-		return nil, fmt.Errorf("unable to add certificates into caCertPool: %v", err)
+	rejected := operatorv1alpha2.NodeObservabilityRunStatus{FinishedTimestamp: &now}
+	rejected.SetCondition(operatorv1alpha2.DebugFinished, metav1.ConditionFalse, operatorv1alpha2.ReasonRejected, "")
 
+	cases := []struct {
+		name          string
+		status        operatorv1alpha2.NodeObservabilityRunStatus
+		failurePolicy operatorv1alpha2.RunFailurePolicy
+		minPercent    *int32
+		expectedPhase operatorv1alpha2.NodeObservabilityRunPhase
+	}{
+		{
+			name:          "not started",
+			expectedPhase: operatorv1alpha2.RunPending,
+		},
+		{
+			name:          "started",
+			status:        operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &now},
+			expectedPhase: operatorv1alpha2.RunRunning,
+		},
+		{
+			name:          "finished on all nodes",
+			status:        finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished),
+			expectedPhase: operatorv1alpha2.RunSucceeded,
+		},
+		{
+			name:          "finished with a failed node",
+			status:        finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, operatorv1alpha2.AgentNode{Name: "failed"}),
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+		{
+			name:          "timed out",
+			status:        finishedWith(metav1.ConditionFalse, operatorv1alpha2.ReasonFailed),
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+		{
+			name:          "rejected",
+			status:        rejected,
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+		{
+			name:          "best effort with a failed node",
+			status:        finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, operatorv1alpha2.AgentNode{Name: "failed"}),
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			expectedPhase: operatorv1alpha2.RunSucceeded,
+		},
+		{
+			name:          "best effort timed out",
+			status:        finishedWith(metav1.ConditionFalse, operatorv1alpha2.ReasonFailed, operatorv1alpha2.AgentNode{Name: "failed"}),
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			expectedPhase: operatorv1alpha2.RunSucceeded,
+		},
+		{
+			name:          "best effort with the minimum percentage of succeeded nodes",
+			status:        finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, operatorv1alpha2.AgentNode{Name: "failed"}),
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			minPercent:    pointer.Int32(50),
+			expectedPhase: operatorv1alpha2.RunSucceeded,
+		},
+		{
+			name:          "best effort below the minimum percentage of succeeded nodes",
+			status:        finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, operatorv1alpha2.AgentNode{Name: "failed"}),
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			minPercent:    pointer.Int32(51),
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+		{
+			name: "best effort failed on all nodes",
+			status: func() operatorv1alpha2.NodeObservabilityRunStatus {
+				s := finishedWith(metav1.ConditionTrue, operatorv1alpha2.ReasonFinished, operatorv1alpha2.AgentNode{Name: "failed"})
+				s.Agents = nil
+				return s
+			}(),
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+		{
+			name:          "best effort rejected",
+			status:        rejected,
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			expectedPhase: operatorv1alpha2.RunFailed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(tc.status)
+			run.Spec.FailurePolicy = tc.failurePolicy
+			run.Spec.MinSucceededNodesPercent = tc.minPercent
+			updatePhase(run)
+			if run.Status.Phase != tc.expectedPhase {
+				t.Fatalf("expected phase %q, got %q", tc.expectedPhase, run.Status.Phase)
+			}
+		})
+	}
+}
+
+func TestUpdateDuration(t *testing.T) {
+	started := metav1.NewTime(time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC))
+	finished := metav1.NewTime(started.Add(95*time.Second + 300*time.Millisecond))
+
+	cases := []struct {
+		name             string
+		status           operatorv1alpha2.NodeObservabilityRunStatus
+		expectedDuration *metav1.Duration
+	}{
+		{
+			name:   "not started",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{},
+		},
+		{
+			name:   "in progress",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &started},
+		},
+		{
+			name:   "finished without starting",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{FinishedTimestamp: &finished},
+		},
+		{
+			name:             "finished",
+			status:           operatorv1alpha2.NodeObservabilityRunStatus{StartTimestamp: &started, FinishedTimestamp: &finished},
+			expectedDuration: &metav1.Duration{Duration: 95 * time.Second},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(tc.status)
+			updateDuration(run)
+			if !reflect.DeepEqual(run.Status.Duration, tc.expectedDuration) {
+				t.Fatalf("expected duration %v, got %v", tc.expectedDuration, run.Status.Duration)
+			}
+		})
+	}
+}
+
+func TestReconcileFailurePolicy(t *testing.T) {
+	doneServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer doneServer.Close()
+	// the busy agent never finishes the profiling
+	busyServer := httptest.NewTLSServer(http.HandlerFunc(conflict))
+	defer busyServer.Close()
+
+	defaultTransport := transport
+	transport = doneServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	longAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name          string
+		failurePolicy operatorv1alpha2.RunFailurePolicy
+		minPercent    *int32
+		expectedPhase operatorv1alpha2.NodeObservabilityRunPhase
+		expectedEvent string
+	}{
+		{
+			name:          "all or nothing by default",
+			expectedPhase: operatorv1alpha2.RunFailed,
+			expectedEvent: corev1.EventTypeWarning + " " + operatorv1alpha2.ReasonFailed,
+		},
+		{
+			name:          "best effort",
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			expectedPhase: operatorv1alpha2.RunSucceeded,
+			expectedEvent: corev1.EventTypeNormal + " " + operatorv1alpha2.ReasonFinished,
+		},
+		{
+			name:          "best effort below the minimum percentage",
+			failurePolicy: operatorv1alpha2.BestEffortFailurePolicy,
+			minPercent:    pointer.Int32(75),
+			expectedPhase: operatorv1alpha2.RunFailed,
+			expectedEvent: corev1.EventTypeWarning + " " + operatorv1alpha2.ReasonFailed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &longAgo,
+				Agents:         []operatorv1alpha2.AgentNode{testAgentNode("done", doneServer), testAgentNode("busy", busyServer)},
+			})
+			run.Spec.FailurePolicy = tc.failurePolicy
+			run.Spec.MinSucceededNodesPercent = tc.minPercent
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: recorder,
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if got.Status.Phase != tc.expectedPhase {
+				t.Fatalf("expected phase %q, got %q", tc.expectedPhase, got.Status.Phase)
+			}
+			if len(got.Status.FailedAgents) != 1 || got.Status.FailedAgents[0].Name != "busy" {
+				t.Fatalf("expected the busy agent to be failed, got %v", got.Status.FailedAgents)
+			}
+			select {
+			case event := <-recorder.Events:
+				expected := tc.expectedEvent + " Profiling succeeded on 1 node(s) and failed on 1 node(s)"
+				if event != expected {
+					t.Fatalf("expected event %q, got %q", expected, event)
+				}
+			default:
+				t.Fatalf("expected event %q, got none", tc.expectedEvent)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	cases := []struct {
+		name     string
+		timeout  metav1.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "default",
+			expected: defaultRunTimeout,
+		},
+		{
+			name:     "custom",
+			timeout:  metav1.Duration{Duration: time.Minute},
+			expected: time.Minute,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.Timeout = tc.timeout
+			if got := runTimeout(run); got != tc.expected {
+				t.Fatalf("expected timeout %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateProfileDuration(t *testing.T) {
+	cases := []struct {
+		name          string
+		duration      metav1.Duration
+		timeout       metav1.Duration
+		expectedError bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "minimum",
+			duration: metav1.Duration{Duration: minProfileDuration},
+		},
+		{
+			name:     "maximum",
+			duration: metav1.Duration{Duration: maxProfileDuration},
+		},
+		{
+			name:          "too short",
+			duration:      metav1.Duration{Duration: 500 * time.Millisecond},
+			expectedError: true,
+		},
+		{
+			name:          "too long",
+			duration:      metav1.Duration{Duration: 10 * time.Minute},
+			expectedError: true,
+		},
+		{
+			name:          "longer than timeout",
+			duration:      metav1.Duration{Duration: 2 * time.Minute},
+			timeout:       metav1.Duration{Duration: time.Minute},
+			expectedError: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.ProfileDuration = tc.duration
+			run.Spec.Timeout = tc.timeout
+			if err := validateProfileDuration(run); (err != nil) != tc.expectedError {
+				t.Fatalf("expected error %t, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestReconcileRetries(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name       string
+		maxRetries *int32
+		failures   int
+		started    bool
+		attempts   int32
+	}{
+		{
+			name:     "agent succeeds at first attempt",
+			started:  true,
+			attempts: 1,
+		},
+		{
+			name:     "agent succeeds after retries",
+			failures: 2,
+			started:  true,
+			attempts: 3,
+		},
+		{
+			name:     "agent fails after default retries",
+			failures: 10,
+			attempts: defaultMaxRetries + 1,
+		},
+		{
+			name:       "agent fails without retries",
+			maxRetries: pointer.Int32(0),
+			failures:   1,
+			attempts:   1,
+		},
+		{
+			name:       "agent succeeds at last retry",
+			maxRetries: pointer.Int32(1),
+			failures:   1,
+			started:    true,
+			attempts:   2,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(busyFor(tc.failures))
+			defer server.Close()
+
+			defaultTransport := transport
+			transport = server.Client().Transport
+			defer func() { transport = defaultTransport }()
+
+			agent := testAgentNode(name, server)
+			run := testNodeObservabilityRun()
+			run.Spec.MaxRetries = tc.maxRetries
+			run.Spec.RetryBackoff = metav1.Duration{Duration: time.Millisecond}
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{{IP: agent.IP, TargetRef: &corev1.ObjectReference{Name: name}}},
+						Ports:     []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+					},
+				},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run, endpoints).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:    cl,
+				URL:       &testURL{},
+				AgentName: name,
+				Namespace: namespace,
+			}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			agent.Attempts = tc.attempts
+			running := withResult(agent, operatorv1alpha2.AgentRunning)
+			running.ProfileDuration = &metav1.Duration{Duration: defaultProfileDuration}
+			expectedAgents, expectedFailedAgents := []operatorv1alpha2.AgentNode{running}, []operatorv1alpha2.AgentNode(nil)
+			if !tc.started {
+				expectedAgents, expectedFailedAgents = nil, []operatorv1alpha2.AgentNode{withResult(agent, operatorv1alpha2.AgentFailed)}
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.Agents), expectedAgents) {
+				t.Fatalf("expected agents %v, got %v", expectedAgents, got.Status.Agents)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.FailedAgents), expectedFailedAgents) {
+				t.Fatalf("expected failed agents %v, got %v", expectedFailedAgents, got.Status.FailedAgents)
+			}
+		})
+	}
+}
+
+func TestReconcileResume(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	// the operator is shut down while the agent receives the request starting the profiling
+	shutdownCtx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+	var starts, profiling int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/" + pprofPath:
+			atomic.AddInt32(&starts, 1)
+			atomic.StoreInt32(&profiling, 1)
+			shutdown()
+			<-req.Context().Done()
+		case "/" + pprofStatus:
+			if atomic.LoadInt32(&profiling) == 1 {
+				conflict(w, req)
+				return
+			}
+			pong(w, req)
+		}
+	}))
+	defer server.Close()
+
+	defaultTransport := transport
+	transport = server.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	agent := testAgentNode(name, server)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: agent.IP, TargetRef: &corev1.ObjectReference{Name: name}}},
+				Ports:     []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+			},
+		},
+	}
+	cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), testNodeObservabilityRun(), endpoints).Build()
+	reconcileRun := func(ctx context.Context) *operatorv1alpha2.NodeObservabilityRun {
+		t.Helper()
+		// a new reconciler as the operator restarts with no state but the status of the run
+		r := NodeObservabilityRunReconciler{
+			Client:        cl,
+			EventRecorder: record.NewFakeRecorder(10),
+			URL:           &testURL{},
+			AgentName:     name,
+			Namespace:     namespace,
+		}
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("reconciler error: %v", err)
+		}
+		got := &operatorv1alpha2.NodeObservabilityRun{}
+		if err := cl.Get(context.Background(), req.NamespacedName, got); err != nil {
+			t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+		}
+		return got
+	}
+	expectRun := func(run *operatorv1alpha2.NodeObservabilityRun, result operatorv1alpha2.AgentResult, reason string) {
+		t.Helper()
+		if len(run.Status.Agents) != 1 || run.Status.Agents[0].Result != result {
+			t.Fatalf("expected the agent to be %s, got %v", result, run.Status.Agents)
+		}
+		if run.Status.Agents[0].StartTimestamp == nil {
+			t.Fatalf("expected the start of the profiling to be kept, got %v", run.Status.Agents[0])
+		}
+		if cond := run.Status.GetCondition(operatorv1alpha2.DebugFinished); cond == nil || cond.Reason != reason {
+			t.Fatalf("expected the run to be %s, got %v", reason, cond)
+		}
+		if n := atomic.LoadInt32(&starts); n != 1 {
+			t.Fatalf("expected the profiling to be started once, got %d start requests", n)
+		}
+	}
+
+	run := reconcileRun(shutdownCtx)
+	if !inProgress(run) {
+		t.Fatalf("expected the run to be in progress")
+	}
+	expectRun(run, operatorv1alpha2.AgentDispatched, operatorv1alpha2.ReasonInterrupted)
+
+	run = reconcileRun(ctx)
+	expectRun(run, operatorv1alpha2.AgentRunning, operatorv1alpha2.ReasonInProgress)
+
+	atomic.StoreInt32(&profiling, 0)
+	run = reconcileRun(ctx)
+	if !finished(run) {
+		t.Fatalf("expected the run to be finished")
+	}
+	expectRun(run, operatorv1alpha2.AgentSucceeded, operatorv1alpha2.ReasonFinished)
+}
+
+func TestAgentBackoff(t *testing.T) {
+	cases := []struct {
+		name          string
+		maxRetries    *int32
+		retryBackoff  metav1.Duration
+		expectedSteps int
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "default",
+			expectedSteps: defaultMaxRetries + 1,
+			expectedDelay: defaultRetryBackoff,
+		},
+		{
+			name:          "custom",
+			maxRetries:    pointer.Int32(5),
+			retryBackoff:  metav1.Duration{Duration: time.Minute},
+			expectedSteps: 6,
+			expectedDelay: time.Minute,
+		},
+		{
+			name:          "no retries",
+			maxRetries:    pointer.Int32(0),
+			expectedSteps: 1,
+			expectedDelay: defaultRetryBackoff,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.MaxRetries = tc.maxRetries
+			run.Spec.RetryBackoff = tc.retryBackoff
+			got := agentBackoff(run)
+			if got.Steps != tc.expectedSteps {
+				t.Errorf("expected %d steps, got %d", tc.expectedSteps, got.Steps)
+			}
+			if got.Duration != tc.expectedDelay {
+				t.Errorf("expected delay %s, got %s", tc.expectedDelay, got.Duration)
+			}
+		})
+	}
+}
+
+func TestAgentRequestTimeout(t *testing.T) {
+	cases := []struct {
+		name            string
+		profileDuration time.Duration
+		requestTimeout  time.Duration
+		margin          time.Duration
+		expectedTimeout time.Duration
+	}{
+		{
+			name:            "default",
+			expectedTimeout: defaultProfileDuration + defaultAgentRequestTimeoutMargin,
+		},
+		{
+			name:            "long profile with custom margin",
+			profileDuration: 5 * time.Minute,
+			margin:          time.Minute,
+			expectedTimeout: 6 * time.Minute,
+		},
+		{
+			name:            "overridden by the spec",
+			profileDuration: 5 * time.Minute,
+			requestTimeout:  10 * time.Minute,
+			margin:          time.Minute,
+			expectedTimeout: 10 * time.Minute,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.ProfileDuration = metav1.Duration{Duration: tc.profileDuration}
+			run.Spec.AgentRequestTimeout = metav1.Duration{Duration: tc.requestTimeout}
+			r := NodeObservabilityRunReconciler{AgentRequestTimeoutMargin: tc.margin}
+			if got := r.agentRequestTimeout(run); got != tc.expectedTimeout {
+				t.Errorf("expected timeout %s, got %s", tc.expectedTimeout, got)
+			}
+		})
+	}
+}
+
+func TestSlowAgent(t *testing.T) {
+	// the agent answers once its profile of 1 second is taken
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+		pong(w, req)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name           string
+		requestTimeout time.Duration
+		errExpected    bool
+	}{
+		{
+			name: "awaited for the profile duration plus the margin",
+		},
+		{
+			name:           "aborted after the request timeout",
+			requestTimeout: 500 * time.Millisecond,
+			errExpected:    true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.ProfileDuration = metav1.Duration{Duration: time.Second}
+			run.Spec.AgentRequestTimeout = metav1.Duration{Duration: tc.requestTimeout}
+			r := NodeObservabilityRunReconciler{
+				Log:                       zap.New(zap.UseDevMode(true)),
+				AgentRequestTimeoutMargin: time.Second,
+			}
+			_, err := r.httpGet(context.Background(), server.Client().Transport, r.agentRequestTimeout(run), server.URL, nil)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected the request to time out")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReconcileUpload(t *testing.T) {
+	agentServer1 := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer agentServer1.Close()
+	agentServer2 := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer agentServer2.Close()
+
+	defaultTransport := transport
+	transport = agentServer1.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	agent1 := testAgentNode("node-1", agentServer1)
+	agent2 := testAgentNode("node-2", agentServer2)
+	withKeys := func(agent operatorv1alpha2.AgentNode) operatorv1alpha2.AgentNode {
+		for _, artifact := range profileArtifacts {
+			agent.ObjectKeys = append(agent.ObjectKeys, fmt.Sprintf("%s/%s/%s/%s", namespace, name, agent.Name, artifact))
+		}
+		return agent
+	}
+	withTemplateKeys := func(agent operatorv1alpha2.AgentNode) operatorv1alpha2.AgentNode {
+		for _, artifact := range profileArtifacts {
+			agent.ObjectKeys = append(agent.ObjectKeys, fmt.Sprintf("profiles/%s/%s", agent.Name, artifact))
+		}
+		return agent
+	}
+	now := metav1.Now()
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name                 string
+		keyTemplate          string
+		maxArtifactSize      string
+		failKeys             []string
+		failCode             int
+		existingObjects      []runtime.Object
+		errExpected          bool
+		expectedErr          string
+		expectedObjects      int
+		expectedAgents       []operatorv1alpha2.AgentNode
+		expectedFailedAgents []operatorv1alpha2.AgentNode
+	}{
+		{
+			name:            "profiles of all agents uploaded",
+			existingObjects: []runtime.Object{testS3Secret()},
+			expectedObjects: 2 * len(profileArtifacts),
+			expectedAgents:  []operatorv1alpha2.AgentNode{withKeys(withResult(agent1, operatorv1alpha2.AgentSucceeded)), withKeys(withResult(agent2, operatorv1alpha2.AgentSucceeded))},
+		},
+		{
+			name:            "profiles keyed with the artifact key template",
+			keyTemplate:     "profiles/{{.Node}}/{{.Type}}.pprof",
+			existingObjects: []runtime.Object{testS3Secret()},
+			expectedObjects: 2 * len(profileArtifacts),
+			expectedAgents:  []operatorv1alpha2.AgentNode{withTemplateKeys(withResult(agent1, operatorv1alpha2.AgentSucceeded)), withTemplateKeys(withResult(agent2, operatorv1alpha2.AgentSucceeded))},
+		},
+		{
+			name:                 "upload failure for one node",
+			failKeys:             []string{"node-1/crio.pprof"},
+			failCode:             http.StatusNotFound,
+			existingObjects:      []runtime.Object{testS3Secret()},
+			errExpected:          true,
+			expectedObjects:      1 + len(profileArtifacts),
+			expectedAgents:       []operatorv1alpha2.AgentNode{withKeys(withResult(agent2, operatorv1alpha2.AgentSucceeded))},
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name:                 "missing credentials",
+			errExpected:          true,
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed), withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name:                 "profiles exceeding the max artifact size",
+			maxArtifactSize:      "4",
+			existingObjects:      []runtime.Object{testS3Secret()},
+			errExpected:          true,
+			expectedErr:          "size of 5 bytes exceeds the maximum artifact size of 4 bytes",
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed), withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3 := &fakeS3{objects: map[string]string{}, failKeys: tc.failKeys, failCode: tc.failCode}
+			s3Server := httptest.NewTLSServer(s3)
+			defer s3Server.Close()
+
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{agent1, agent2},
+			})
+			run.Spec.StorageBackend = testS3StorageBackend(s3Server.URL)
+			run.Spec.StorageBackend.S3.CABundleRef = &corev1.LocalObjectReference{Name: testS3CABundleName}
+			run.Spec.ArtifactKeyTemplate = tc.keyTemplate
+			if tc.maxArtifactSize != "" {
+				maxArtifactSize := resource.MustParse(tc.maxArtifactSize)
+				run.Spec.MaxArtifactSize = &maxArtifactSize
+			}
+			objs := append([]runtime.Object{testNodeObservability(), run, testS3CABundle(s3Server)}, tc.existingObjects...)
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			_, err := r.Reconcile(ctx, req)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			if tc.expectedErr != "" && !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Fatalf("expected error to contain %q, got %v", tc.expectedErr, err)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if !finished(got) {
+				t.Fatalf("expected run to be finished")
+			}
+			if len(s3.objects) != tc.expectedObjects {
+				t.Fatalf("expected %d uploaded objects, got %d: %v", tc.expectedObjects, len(s3.objects), s3.objects)
+			}
+			for key, content := range s3.objects {
+				if content != "pong\n" {
+					t.Fatalf("unexpected content %q for object %q", content, key)
+				}
+			}
+			for i, agent := range got.Status.Agents {
+				if len(agent.Artifacts) != len(agent.ObjectKeys) {
+					t.Fatalf("expected an artifact per object key, got %v", agent.Artifacts)
+				}
+				for j, artifact := range agent.Artifacts {
+					key := agent.ObjectKeys[j]
+					if artifact.Name != path.Base(key) || artifact.Size == nil || *artifact.Size != int64(len("pong\n")) || artifact.URI != s3Server.URL+"/"+testBucket+"/"+key {
+						t.Fatalf("unexpected artifact %+v for object %q", artifact, key)
+					}
+				}
+				got.Status.Agents[i].Artifacts = nil
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.Agents), tc.expectedAgents) {
+				t.Fatalf("expected agents %v, got %v", tc.expectedAgents, got.Status.Agents)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(got.Status.FailedAgents), tc.expectedFailedAgents) {
+				t.Fatalf("expected failed agents %v, got %v", tc.expectedFailedAgents, got.Status.FailedAgents)
+			}
+		})
+	}
+}
+
+// testNodeObservabilityRun - minimal CR for the test
+func testNodeObservabilityRun() *operatorv1alpha2.NodeObservabilityRun {
+	return &operatorv1alpha2.NodeObservabilityRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name: nodeObsName,
+				},
+			},
+		},
+		Spec: operatorv1alpha2.NodeObservabilityRunSpec{
+			NodeObservabilityRef: &operatorv1alpha2.NodeObservabilityRef{
+				Name: nodeObsName,
+			},
+		},
+		Status: operatorv1alpha2.NodeObservabilityRunStatus{},
+	}
+}
+
+// testNodeObservability - minimal CR for the test
+func testNodeObservability() *operatorv1alpha2.NodeObservability {
+	return &operatorv1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nodeObsName,
+		},
+		Spec: operatorv1alpha2.NodeObservabilitySpec{},
+		Status: operatorv1alpha2.NodeObservabilityStatus{
+			ConditionalStatus: operatorv1alpha2.ConditionalStatus{
+				Conditions: []metav1.Condition{
+					{
+						Type:   operatorv1alpha2.DebugReady,
+						Status: metav1.ConditionTrue,
+					},
+				},
+			},
+		},
+	}
+}
+
+func testNodeObservabilityRunWithStatus(s operatorv1alpha2.NodeObservabilityRunStatus) *operatorv1alpha2.NodeObservabilityRun {
+	run := testNodeObservabilityRun()
+	run.Status = s
+	return run
+}
+
+func fakeHttpServer() error {
+	caCert, err := readCACert(CAPath)
+	if err != nil {
+		return err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{
+		RootCAs:                  caCert,
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+	}
+	transport = t
+
+	http.HandleFunc("/node-observability-pprof", pong)
+	http.HandleFunc("/node-observability-status", pong)
+	server := &http.Server{
+		Addr:              "127.0.0.1:8443",
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServeTLS(certPath, keyPath)
+}
+
+func pong(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("pong\n"))
+}
+
+func conflict(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusConflict)
+	_, _ = w.Write([]byte("profiling in progress\n"))
+}
+
+// busyFor returns a handler which responds as a busy agent to the given number of first requests
+func busyFor(failures int) http.HandlerFunc {
+	var requests int
+	return func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		pong(w, req)
+	}
+}
+
+// testAgentNode returns an agent reachable on the address of the given server
+func testAgentNode(name string, server *httptest.Server) operatorv1alpha2.AgentNode {
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	return operatorv1alpha2.AgentNode{Name: name, IP: addr.IP.String(), Port: int32(addr.Port)}
+}
+
+// withResult returns the given agent with the given profiling result
+func withResult(agent operatorv1alpha2.AgentNode, result operatorv1alpha2.AgentResult) operatorv1alpha2.AgentNode {
+	agent.Result = result
+	return agent
+}
+
+// withAgentArtifacts lists the profiles served by the agent as the artifacts of the agent
+func withAgentArtifacts(agent operatorv1alpha2.AgentNode) operatorv1alpha2.AgentNode {
+	for _, artifact := range profileArtifacts {
+		agent.Artifacts = append(agent.Artifacts, operatorv1alpha2.ProfileArtifact{
+			Name: artifact,
+			URI:  fmt.Sprintf("https://%s:%d/%s/%s", agent.IP, agent.Port, pprofOutput, artifact),
+		})
+	}
+	return agent
+}
+
+// withoutTimestamps returns the given agents without their timestamps
+// which are set to the time of the reconciliation
+func withoutTimestamps(agents []operatorv1alpha2.AgentNode) []operatorv1alpha2.AgentNode {
+	if agents == nil {
+		return nil
+	}
+	cleared := make([]operatorv1alpha2.AgentNode, 0, len(agents))
+	for _, agent := range agents {
+		agent.StartTimestamp = nil
+		agent.FinishedTimestamp = nil
+		cleared = append(cleared, agent)
+	}
+	return cleared
+}
+
+func TestStartRunProfilingType(t *testing.T) {
+	cases := []struct {
+		name              string
+		profilingType     operatorv1alpha2.NodeObservabilityType
+		profileDuration   metav1.Duration
+		profileTypes      []operatorv1alpha2.ProfileType
+		expectedQuery     string
+		expectedArtifacts []string
+		expectedDuration  time.Duration
+	}{
+		{
+			name:              "crio-kubelet",
+			profilingType:     operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			expectedArtifacts: []string{"kubelet.pprof", "crio.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "kubelet",
+			profilingType:     operatorv1alpha2.KubeletNodeObservabilityType,
+			expectedQuery:     "profiles=kubelet",
+			expectedArtifacts: []string{"kubelet.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "crio-kubelet with profile duration",
+			profilingType:     operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			profileDuration:   metav1.Duration{Duration: 2 * time.Minute},
+			expectedQuery:     "seconds=120",
+			expectedArtifacts: []string{"kubelet.pprof", "crio.pprof"},
+			expectedDuration:  2 * time.Minute,
+		},
+		{
+			name:              "kubelet with profile duration",
+			profilingType:     operatorv1alpha2.KubeletNodeObservabilityType,
+			profileDuration:   metav1.Duration{Duration: 10 * time.Second},
+			expectedQuery:     "profiles=kubelet&seconds=10",
+			expectedArtifacts: []string{"kubelet.pprof"},
+			expectedDuration:  10 * time.Second,
+		},
+		{
+			name:              "cpu profile type",
+			profilingType:     operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			profileTypes:      []operatorv1alpha2.ProfileType{operatorv1alpha2.CPUProfileType},
+			expectedArtifacts: []string{"kubelet.pprof", "crio.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "crio-kubelet with several profile types",
+			profilingType:     operatorv1alpha2.CrioKubeletNodeObservabilityType,
+			profileTypes:      []operatorv1alpha2.ProfileType{operatorv1alpha2.CPUProfileType, operatorv1alpha2.HeapProfileType},
+			expectedQuery:     "types=cpu,heap",
+			expectedArtifacts: []string{"kubelet.pprof", "crio.pprof", "kubelet-heap.pprof", "crio-heap.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "kubelet without cpu profile type",
+			profilingType:     operatorv1alpha2.KubeletNodeObservabilityType,
+			profileTypes:      []operatorv1alpha2.ProfileType{operatorv1alpha2.GoroutineProfileType, operatorv1alpha2.BlockProfileType},
+			expectedQuery:     "profiles=kubelet&types=goroutine,block",
+			expectedArtifacts: []string{"kubelet-goroutine.pprof", "kubelet-block.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "etcd",
+			profilingType:     operatorv1alpha2.EtcdNodeObservabilityType,
+			expectedQuery:     "profiles=kubelet,etcd",
+			expectedArtifacts: []string{"kubelet.pprof", "etcd.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+		{
+			name:              "etcd with several profile types",
+			profilingType:     operatorv1alpha2.EtcdNodeObservabilityType,
+			profileTypes:      []operatorv1alpha2.ProfileType{operatorv1alpha2.CPUProfileType, operatorv1alpha2.HeapProfileType},
+			expectedQuery:     "profiles=kubelet,etcd&types=cpu,heap",
+			expectedArtifacts: []string{"kubelet.pprof", "etcd.pprof", "kubelet-heap.pprof", "etcd-heap.pprof"},
+			expectedDuration:  defaultProfileDuration,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var query string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				query = req.URL.RawQuery
+				pong(w, req)
+			}))
+			defer server.Close()
+
+			defaultTransport := transport
+			transport = server.Client().Transport
+			defer func() { transport = defaultTransport }()
+
+			agent := testAgentNode(name, server)
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.Type = tc.profilingType
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{{IP: agent.IP, TargetRef: &corev1.ObjectReference{Name: name}}},
+						Ports:     []corev1.EndpointPort{{Name: "test-port", Port: agent.Port}},
+					},
+				},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(nodeObs, endpoints).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:    cl,
+				Log:       zap.New(zap.UseDevMode(true)),
+				URL:       &testURL{},
+				AgentName: name,
+				Namespace: namespace,
+			}
+
+			run := testNodeObservabilityRun()
+			run.Spec.ProfileDuration = tc.profileDuration
+			run.Spec.ProfileTypes = tc.profileTypes
+			if err := cl.Create(context.Background(), run); err != nil {
+				t.Fatalf("failed to create the run: %v", err)
+			}
+			if err := r.startRun(context.Background(), run, transport); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query != tc.expectedQuery {
+				t.Errorf("expected query %q, got %q", tc.expectedQuery, query)
+			}
+			if len(run.Status.Agents) != 1 || run.Status.Agents[0].ProfileDuration == nil || run.Status.Agents[0].ProfileDuration.Duration != tc.expectedDuration {
+				t.Errorf("expected agent with profile duration %s, got %+v", tc.expectedDuration, run.Status.Agents)
+			}
+			if run.Status.ProfilingType != tc.profilingType {
+				t.Errorf("expected profiling type %q, got %q", tc.profilingType, run.Status.ProfilingType)
+			}
+			if artifacts := runArtifacts(run); !reflect.DeepEqual(artifacts, tc.expectedArtifacts) {
+				t.Errorf("expected artifacts %v, got %v", tc.expectedArtifacts, artifacts)
+			}
+		})
+	}
+}
+
+func TestWarnEtcdProfilingRejected(t *testing.T) {
+	cases := []struct {
+		name          string
+		profilingType operatorv1alpha2.NodeObservabilityType
+		errs          []error
+		expectedEvent string
+	}{
+		{
+			name:          "etcd profiling started",
+			profilingType: operatorv1alpha2.EtcdNodeObservabilityType,
+			errs:          []error{nil, nil},
+		},
+		{
+			name:          "etcd profiling rejected",
+			profilingType: operatorv1alpha2.EtcdNodeObservabilityType,
+			errs: []error{
+				NodeObservabilityRunError{HttpCode: http.StatusBadRequest, Msg: "unknown profile etcd"},
+				nil,
+				NodeObservabilityRunError{HttpCode: http.StatusBadRequest, Msg: "unknown profile etcd"},
+			},
+			expectedEvent: "Warning EtcdProfilingRejected The agents of 2 node(s) rejected the profiling of etcd, the agent image must support it and the nodes must run etcd: Code: 400, Error: unknown profile etcd",
+		},
+		{
+			name:          "etcd profiling failed",
+			profilingType: operatorv1alpha2.EtcdNodeObservabilityType,
+			errs:          []error{NodeObservabilityRunError{HttpCode: http.StatusForbidden, Msg: "forbidden"}},
+		},
+		{
+			name:          "kubelet profiling rejected",
+			profilingType: operatorv1alpha2.KubeletNodeObservabilityType,
+			errs:          []error{NodeObservabilityRunError{HttpCode: http.StatusBadRequest, Msg: "bad request"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := NodeObservabilityRunReconciler{EventRecorder: recorder}
+			run := testNodeObservabilityRun()
+			run.Status.ProfilingType = tc.profilingType
+
+			r.warnEtcdProfilingRejected(run, tc.errs)
+			select {
+			case event := <-recorder.Events:
+				if event != tc.expectedEvent {
+					t.Errorf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Errorf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
 	}
-	return caCertPool, nil
 }
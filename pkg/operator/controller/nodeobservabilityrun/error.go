@@ -1,8 +1,11 @@
 package nodeobservabilityruncontroller
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"syscall"
 )
 
 type NodeObservabilityRunError struct {
@@ -24,3 +27,24 @@ func IsNodeObservabilityRunErrorRetriable(err error) bool {
 	}
 	return false
 }
+
+// isAgentErrorRetriable returns true if the request to the agent
+// failed for a reason which is likely to be transient:
+// the agent or the node is momentarily busy or unreachable.
+func isAgentErrorRetriable(err error) bool {
+	if IsNodeObservabilityRunErrorRetriable(err) {
+		return true
+	}
+	if e, ok := err.(NodeObservabilityRunError); ok {
+		switch e.HttpCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,51 @@
+package nodeobservabilityruncontroller
+
+import (
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+)
+
+func TestIsAgentErrorRetriable(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "internal server error",
+			err:      NodeObservabilityRunError{HttpCode: http.StatusInternalServerError},
+			expected: true,
+		},
+		{
+			name:     "service unavailable",
+			err:      NodeObservabilityRunError{HttpCode: http.StatusServiceUnavailable},
+			expected: true,
+		},
+		{
+			name: "conflict",
+			err:  NodeObservabilityRunError{HttpCode: http.StatusConflict},
+		},
+		{
+			name: "unauthorized",
+			err:  NodeObservabilityRunError{HttpCode: http.StatusUnauthorized},
+		},
+		{
+			name:     "connection refused",
+			err:      fmt.Errorf("dial failed: %w", syscall.ECONNREFUSED),
+			expected: true,
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("certificate has expired"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isAgentErrorRetriable(tc.err); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// defaultRunHistoryLimit is the number of finished runs referenced
+// in the status of NodeObservability when not set in its spec
+const defaultRunHistoryLimit = 10
+
+// recordRunHistory references the finished run in the status of its NodeObservability,
+// the references beyond the history limit are dropped and their runs are deleted
+// if NodeObservability prunes them. Recording the same run again is a no-op.
+func (r *NodeObservabilityRunReconciler) recordRunHistory(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) error {
+	if instance.DeletionTimestamp != nil {
+		return nil
+	}
+	key := types.NamespacedName{Name: instance.Spec.NodeObservabilityRef.Name}
+	nodeObs := &nodeobservabilityv1alpha2.NodeObservability{}
+	var dropped []nodeobservabilityv1alpha2.RunRef
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, key, nodeObs); err != nil {
+			return err
+		}
+		var refs []nodeobservabilityv1alpha2.RunRef
+		refs, dropped = addRunRef(nodeObs.Status.RunRefs, runRef(instance), runHistoryLimit(nodeObs))
+		if equality.Semantic.DeepEqual(refs, nodeObs.Status.RunRefs) {
+			return nil
+		}
+		nodeObs.Status.RunRefs = refs
+		return r.Status().Update(ctx, nodeObs)
+	})
+	if err != nil {
+		// the history is gone with NodeObservability
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to record the run in the history of nodeobservability %q: %w", key.Name, err)
+	}
+	if !nodeObs.Spec.PruneRuns {
+		return nil
+	}
+	for _, ref := range dropped {
+		run := &nodeobservabilityv1alpha2.NodeObservabilityRun{
+			ObjectMeta: metav1.ObjectMeta{Name: ref.Name, Namespace: ref.Namespace},
+		}
+		if err := r.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pruned nodeobservabilityrun %q: %w", ref.Name, err)
+		}
+		r.Log.V(1).Info("deleted run pruned from the history", "name", ref.Name, "namespace", ref.Namespace)
+	}
+	return nil
+}
+
+// runRef returns the reference of the given finished run.
+func runRef(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) nodeobservabilityv1alpha2.RunRef {
+	return nodeobservabilityv1alpha2.RunRef{
+		Name:              instance.Name,
+		Namespace:         instance.Namespace,
+		Phase:             instance.Status.Phase,
+		StartTimestamp:    instance.Status.StartTimestamp,
+		FinishedTimestamp: instance.Status.FinishedTimestamp,
+	}
+}
+
+// addRunRef returns the given references with the given one added or replaced,
+// sorted from the most recently finished run, and the references beyond the limit dropped from them.
+// A reference older than all the others is dropped right away once the limit is reached.
+func addRunRef(refs []nodeobservabilityv1alpha2.RunRef, ref nodeobservabilityv1alpha2.RunRef, limit int32) ([]nodeobservabilityv1alpha2.RunRef, []nodeobservabilityv1alpha2.RunRef) {
+	updated := make([]nodeobservabilityv1alpha2.RunRef, 0, len(refs)+1)
+	for _, existing := range refs {
+		if existing.Name != ref.Name || existing.Namespace != ref.Namespace {
+			updated = append(updated, existing)
+		}
+	}
+	updated = append(updated, ref)
+	sort.SliceStable(updated, func(i, j int) bool {
+		ti, tj := updated[i].FinishedTimestamp, updated[j].FinishedTimestamp
+		if ti == nil || tj == nil {
+			return tj == nil && ti != nil
+		}
+		return tj.Before(ti)
+	})
+	if int32(len(updated)) <= limit {
+		return updated, nil
+	}
+	return updated[:limit], updated[limit:]
+}
+
+// runHistoryLimit returns the number of finished runs referenced by NodeObservability,
+// falls back to the default one if not set in its spec.
+func runHistoryLimit(nodeObs *nodeobservabilityv1alpha2.NodeObservability) int32 {
+	if nodeObs.Spec.RunHistoryLimit != nil {
+		return *nodeObs.Spec.RunHistoryLimit
+	}
+	return defaultRunHistoryLimit
+}
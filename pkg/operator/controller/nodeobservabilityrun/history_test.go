@@ -0,0 +1,204 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestAddRunRef(t *testing.T) {
+	cases := []struct {
+		name            string
+		refs            []string
+		ref             string
+		limit           int32
+		expectedRefs    []string
+		expectedDropped []string
+	}{
+		{
+			name:         "first run",
+			ref:          "run-1",
+			limit:        2,
+			expectedRefs: []string{"run-1"},
+		},
+		{
+			name:         "most recent run first",
+			refs:         []string{"run-1"},
+			ref:          "run-2",
+			limit:        2,
+			expectedRefs: []string{"run-2", "run-1"},
+		},
+		{
+			name:            "oldest run dropped",
+			refs:            []string{"run-2", "run-1"},
+			ref:             "run-3",
+			limit:           2,
+			expectedRefs:    []string{"run-3", "run-2"},
+			expectedDropped: []string{"run-1"},
+		},
+		{
+			name:         "run recorded again",
+			refs:         []string{"run-2", "run-1"},
+			ref:          "run-2",
+			limit:        2,
+			expectedRefs: []string{"run-2", "run-1"},
+		},
+		{
+			name:            "run older than the history",
+			refs:            []string{"run-3", "run-2"},
+			ref:             "run-1",
+			limit:           2,
+			expectedRefs:    []string{"run-3", "run-2"},
+			expectedDropped: []string{"run-1"},
+		},
+		{
+			name:            "limit lowered",
+			refs:            []string{"run-3", "run-2", "run-1"},
+			ref:             "run-4",
+			limit:           1,
+			expectedRefs:    []string{"run-4"},
+			expectedDropped: []string{"run-3", "run-2", "run-1"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var refs []operatorv1alpha2.RunRef
+			for _, name := range tc.refs {
+				refs = append(refs, testRunRef(name))
+			}
+			gotRefs, gotDropped := addRunRef(refs, testRunRef(tc.ref), tc.limit)
+			if names := runRefNames(gotRefs); !reflect.DeepEqual(names, tc.expectedRefs) {
+				t.Errorf("expected refs %v, got %v", tc.expectedRefs, names)
+			}
+			if names := runRefNames(gotDropped); !reflect.DeepEqual(names, tc.expectedDropped) {
+				t.Errorf("expected dropped refs %v, got %v", tc.expectedDropped, names)
+			}
+		})
+	}
+}
+
+func TestRecordRunHistory(t *testing.T) {
+	cases := []struct {
+		name            string
+		pruneRuns       bool
+		existingRefs    []string
+		noNodeObs       bool
+		expectedRefs    []string
+		expectedDeleted []string
+	}{
+		{
+			name:         "first run",
+			expectedRefs: []string{"run-1"},
+		},
+		{
+			name:         "oldest run dropped",
+			existingRefs: []string{"run-0", "run-minus-1"},
+			expectedRefs: []string{"run-1", "run-0"},
+		},
+		{
+			name:            "oldest run pruned",
+			pruneRuns:       true,
+			existingRefs:    []string{"run-0", "run-minus-1"},
+			expectedRefs:    []string{"run-1", "run-0"},
+			expectedDeleted: []string{"run-minus-1"},
+		},
+		{
+			name:      "nodeobservability deleted",
+			noNodeObs: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testRunWithHistory("run-1", 1)
+			objs := []runtime.Object{run}
+			nodeObs := testNodeObservability()
+			nodeObs.Spec.RunHistoryLimit = pointer.Int32(2)
+			nodeObs.Spec.PruneRuns = tc.pruneRuns
+			for i, name := range tc.existingRefs {
+				existing := testRunWithHistory(name, -i)
+				nodeObs.Status.RunRefs = append(nodeObs.Status.RunRefs, runRef(existing))
+				objs = append(objs, existing)
+			}
+			if !tc.noNodeObs {
+				objs = append(objs, nodeObs)
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r := NodeObservabilityRunReconciler{Client: cl, Log: logr.Discard()}
+
+			if err := r.recordRunHistory(context.Background(), run); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.noNodeObs {
+				return
+			}
+			got := &operatorv1alpha2.NodeObservability{}
+			if err := cl.Get(context.Background(), types.NamespacedName{Name: nodeObsName}, got); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+			if names := runRefNames(got.Status.RunRefs); !reflect.DeepEqual(names, tc.expectedRefs) {
+				t.Errorf("expected refs %v, got %v", tc.expectedRefs, names)
+			}
+			if ref := got.Status.RunRefs[0]; ref.Phase != operatorv1alpha2.RunSucceeded || ref.FinishedTimestamp == nil {
+				t.Errorf("expected the ref of the finished run, got %+v", ref)
+			}
+			for _, name := range tc.existingRefs {
+				err := cl.Get(context.Background(), types.NamespacedName{Name: name, Namespace: namespace}, &operatorv1alpha2.NodeObservabilityRun{})
+				deleted := kerrors.IsNotFound(err)
+				if err != nil && !deleted {
+					t.Fatalf("failed to get run %q: %v", name, err)
+				}
+				if expected := contains(tc.expectedDeleted, name); deleted != expected {
+					t.Errorf("expected run %q to be deleted: %t, got %t", name, expected, deleted)
+				}
+			}
+		})
+	}
+}
+
+// testRunWithHistory returns a run which succeeded the given number of hours from now.
+func testRunWithHistory(name string, hours int) *operatorv1alpha2.NodeObservabilityRun {
+	run := testNodeObservabilityRun()
+	run.Name = name
+	finishedAt := metav1.NewTime(time.Now().Add(time.Duration(hours) * time.Hour).Truncate(time.Second))
+	run.Status.Phase = operatorv1alpha2.RunSucceeded
+	run.Status.FinishedTimestamp = &finishedAt
+	return run
+}
+
+// testRunRef returns the ref of a run named run-<n> finished n hours after the epoch.
+func testRunRef(name string) operatorv1alpha2.RunRef {
+	var n int
+	_, _ = fmt.Sscanf(name, "run-%d", &n)
+	finishedAt := metav1.NewTime(time.Unix(0, 0).Add(time.Duration(n) * time.Hour))
+	return operatorv1alpha2.RunRef{Name: name, Namespace: namespace, FinishedTimestamp: &finishedAt}
+}
+
+func runRefNames(refs []operatorv1alpha2.RunRef) []string {
+	var names []string
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
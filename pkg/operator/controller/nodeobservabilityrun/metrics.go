@@ -0,0 +1,76 @@
+package nodeobservabilityruncontroller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	runResultLabel     = "result"
+	runResultSucceeded = "succeeded"
+	runResultFailed    = "failed"
+	runResultCancelled = "cancelled"
+)
+
+var (
+	runsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nodeobservability_runs_total",
+			Help: "Number of finished NodeObservabilityRuns by result.",
+		},
+		[]string{runResultLabel},
+	)
+	runDurationSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "nodeobservability_run_duration_seconds",
+			Help:    "Duration of the finished NodeObservabilityRuns.",
+			Buckets: []float64{30, 60, 120, 300, 600, 1200, 1800},
+		},
+	)
+	agentsFailed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nodeobservability_agents_failed",
+			Help: "Number of agents which failed in the last finished NodeObservabilityRun.",
+		},
+	)
+)
+
+func init() {
+	// registered on the controller-runtime registry
+	// to be exposed on the operator metrics endpoint
+	metrics.Registry.MustRegister(runsTotal, runDurationSeconds, agentsFailed)
+}
+
+// isLeader returns true if the operator replica won the leader election,
+// the standby replicas report the run metrics without any sample.
+func (r *NodeObservabilityRunReconciler) isLeader() bool {
+	if r.Elected == nil {
+		return true
+	}
+	select {
+	case <-r.Elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordRunMetrics updates the metrics with the outcome of the given finished run.
+func recordRunMetrics(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	result := runResultFailed
+	if cond := instance.Status.GetCondition(nodeobservabilityv1alpha2.DebugFinished); cond != nil && cond.Status == metav1.ConditionTrue {
+		result = runResultSucceeded
+	}
+	if cancelled(instance) {
+		result = runResultCancelled
+	}
+	runsTotal.WithLabelValues(result).Inc()
+
+	if instance.Status.StartTimestamp != nil && instance.Status.FinishedTimestamp != nil {
+		runDurationSeconds.Observe(instance.Status.FinishedTimestamp.Sub(instance.Status.StartTimestamp.Time).Seconds())
+	}
+	agentsFailed.Set(float64(len(instance.Status.FailedAgents)))
+}
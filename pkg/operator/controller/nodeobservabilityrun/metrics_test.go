@@ -0,0 +1,300 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestRecordRunMetrics(t *testing.T) {
+	start := metav1.Now()
+	finish := metav1.NewTime(start.Add(time.Minute))
+	agent := operatorv1alpha2.AgentNode{Name: "agent", IP: "127.0.0.1", Port: 8443}
+
+	cases := []struct {
+		name                 string
+		conditionStatus      metav1.ConditionStatus
+		conditionReason      string
+		failedAgents         []operatorv1alpha2.AgentNode
+		expectedResult       string
+		expectedAgentsFailed float64
+	}{
+		{
+			name:            "succeeded run",
+			conditionStatus: metav1.ConditionTrue,
+			expectedResult:  runResultSucceeded,
+		},
+		{
+			name:                 "succeeded run with failed agents",
+			conditionStatus:      metav1.ConditionTrue,
+			failedAgents:         []operatorv1alpha2.AgentNode{agent},
+			expectedResult:       runResultSucceeded,
+			expectedAgentsFailed: 1,
+		},
+		{
+			name:                 "failed run",
+			conditionStatus:      metav1.ConditionFalse,
+			failedAgents:         []operatorv1alpha2.AgentNode{agent, agent},
+			expectedResult:       runResultFailed,
+			expectedAgentsFailed: 2,
+		},
+		{
+			name:            "cancelled run",
+			conditionStatus: metav1.ConditionFalse,
+			conditionReason: operatorv1alpha2.ReasonCancelled,
+			expectedResult:  runResultCancelled,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp:    &start,
+				FinishedTimestamp: &finish,
+				FailedAgents:      tc.failedAgents,
+			})
+			reason := tc.conditionReason
+			if reason == "" {
+				reason = operatorv1alpha2.ReasonFinished
+			}
+			run.Status.SetCondition(operatorv1alpha2.DebugFinished, tc.conditionStatus, reason, "")
+
+			runsBefore := metricValue(t, runsTotal.WithLabelValues(tc.expectedResult)).GetCounter().GetValue()
+			durationsBefore := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount()
+			durationSumBefore := metricValue(t, runDurationSeconds).GetHistogram().GetSampleSum()
+
+			recordRunMetrics(run)
+
+			if got := metricValue(t, runsTotal.WithLabelValues(tc.expectedResult)).GetCounter().GetValue(); got != runsBefore+1 {
+				t.Errorf("expected %s runs counter to be %v, got %v", tc.expectedResult, runsBefore+1, got)
+			}
+			if got := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount(); got != durationsBefore+1 {
+				t.Errorf("expected %d observed durations, got %d", durationsBefore+1, got)
+			}
+			// the sum accumulates the durations of the runs finished by the other tests
+			if got := metricValue(t, runDurationSeconds).GetHistogram().GetSampleSum() - durationSumBefore; math.Abs(got-time.Minute.Seconds()) > 1e-6 {
+				t.Errorf("expected observed duration of %v seconds, got %v", time.Minute.Seconds(), got)
+			}
+			if got := metricValue(t, agentsFailed).GetGauge().GetValue(); got != tc.expectedAgentsFailed {
+				t.Errorf("expected %v failed agents, got %v", tc.expectedAgentsFailed, got)
+			}
+		})
+	}
+}
+
+func TestReconcileRunMetricsFinishPersisted(t *testing.T) {
+	agentServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer agentServer.Close()
+
+	defaultTransport := transport
+	transport = agentServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	cases := []struct {
+		name string
+		// statusFailures is the number of status updates failing before they succeed
+		statusFailures int
+		// expectedReconciles is the number of reconciles after which the run is finished
+		expectedReconciles int
+	}{
+		{
+			name:               "finish persisted",
+			expectedReconciles: 1,
+		},
+		{
+			name:               "finish retried after a failed status update",
+			statusFailures:     1,
+			expectedReconciles: 2,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3Server := httptest.NewTLSServer(&fakeS3{objects: map[string]string{}})
+			defer s3Server.Close()
+
+			now := metav1.Now()
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{withResult(testAgentNode("node-1", agentServer), operatorv1alpha2.AgentSucceeded)},
+			})
+			run.Spec.StorageBackend = testS3StorageBackend(s3Server.URL)
+			run.Spec.StorageBackend.S3.CABundleRef = &corev1.LocalObjectReference{Name: testS3CABundleName}
+			objs := []runtime.Object{testNodeObservability(), run, testS3CABundle(s3Server), testS3Secret()}
+			cl := &failingStatusClient{
+				Client:   fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build(),
+				failures: tc.statusFailures,
+			}
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			runsBefore := metricValue(t, runsTotal.WithLabelValues(runResultSucceeded)).GetCounter().GetValue()
+			durationsBefore := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount()
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			for i := 1; i <= tc.expectedReconciles; i++ {
+				_, err := r.Reconcile(ctx, req)
+				if i <= tc.statusFailures && err == nil {
+					t.Fatalf("expected the status update to fail")
+				}
+				if i > tc.statusFailures && err != nil {
+					t.Fatalf("reconciler error: %v", err)
+				}
+				if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+					t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+				}
+				var expectedRuns float64
+				if finished(got) {
+					expectedRuns = 1
+				}
+				if runs := metricValue(t, runsTotal.WithLabelValues(runResultSucceeded)).GetCounter().GetValue() - runsBefore; runs != expectedRuns {
+					t.Errorf("expected the succeeded runs counter to be advanced by %v after %d reconcile(s), got %v", expectedRuns, i, runs)
+				}
+			}
+			if !finished(got) {
+				t.Fatalf("expected the run to be finished")
+			}
+			if durations := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount() - durationsBefore; durations != 1 {
+				t.Errorf("expected a single observed duration, got %d", durations)
+			}
+		})
+	}
+}
+
+func TestReconcileRunMetricsLeader(t *testing.T) {
+	agentServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer agentServer.Close()
+
+	defaultTransport := transport
+	transport = agentServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	elected := make(chan struct{})
+	close(elected)
+	cases := []struct {
+		name            string
+		elected         <-chan struct{}
+		expectedCounted bool
+	}{
+		{
+			name:            "leader",
+			elected:         elected,
+			expectedCounted: true,
+		},
+		{
+			name:            "no leader election",
+			expectedCounted: true,
+		},
+		{
+			name:    "standby replica",
+			elected: make(chan struct{}),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3Server := httptest.NewTLSServer(&fakeS3{objects: map[string]string{}})
+			defer s3Server.Close()
+
+			now := metav1.Now()
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{withResult(testAgentNode("node-1", agentServer), operatorv1alpha2.AgentSucceeded)},
+			})
+			run.Spec.StorageBackend = testS3StorageBackend(s3Server.URL)
+			run.Spec.StorageBackend.S3.CABundleRef = &corev1.LocalObjectReference{Name: testS3CABundleName}
+			objs := []runtime.Object{testNodeObservability(), run, testS3CABundle(s3Server), testS3Secret()}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+				Elected:       tc.elected,
+			}
+			runsBefore := metricValue(t, runsTotal.WithLabelValues(runResultSucceeded)).GetCounter().GetValue()
+			durationsBefore := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount()
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if !finished(got) {
+				t.Fatalf("expected the run to be finished")
+			}
+
+			var expectedRuns float64
+			var expectedDurations uint64
+			if tc.expectedCounted {
+				expectedRuns, expectedDurations = 1, 1
+			}
+			if got := metricValue(t, runsTotal.WithLabelValues(runResultSucceeded)).GetCounter().GetValue() - runsBefore; got != expectedRuns {
+				t.Errorf("expected the succeeded runs counter to be advanced by %v, got %v", expectedRuns, got)
+			}
+			if got := metricValue(t, runDurationSeconds).GetHistogram().GetSampleCount() - durationsBefore; got != expectedDurations {
+				t.Errorf("expected %d observed durations, got %d", expectedDurations, got)
+			}
+		})
+	}
+}
+
+// failingStatusClient fails the given number of status updates, like an unavailable API server.
+type failingStatusClient struct {
+	client.Client
+	failures int
+}
+
+func (c *failingStatusClient) Status() client.StatusWriter {
+	return &failingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type failingStatusWriter struct {
+	client.StatusWriter
+	client *failingStatusClient
+}
+
+func (w *failingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if w.client.failures > 0 {
+		w.client.failures--
+		return kerrors.NewServiceUnavailable("unavailable")
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func metricValue(t *testing.T, m prometheus.Metric) *dto.Metric {
+	t.Helper()
+	out := &dto.Metric{}
+	if err := m.Write(out); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return out
+}
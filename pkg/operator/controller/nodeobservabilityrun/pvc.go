@@ -0,0 +1,397 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
+)
+
+const (
+	// collectorLabel is set on all the collector pods
+	collectorLabel         = "nodeobservability.olm.openshift.io/collector"
+	collectorContainerName = "collector"
+	collectorBinary        = "/node-observability-operator"
+	collectorOutputName    = "profiles"
+	collectorOutputPath    = "/profiles"
+	collectorCAName        = "ca-bundle"
+	collectorCAPath        = "/var/run/secrets/openshift.io/certs"
+	collectorCAFile        = "service-ca.crt"
+	collectorCAConfigMap   = "openshift-service-ca.crt"
+	collectorTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	collectorPodNameSuffix = "-collector"
+)
+
+// collectorPodName returns the name of the collector pod of the run
+func collectorPodName(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	return instance.Name + collectorPodNameSuffix
+}
+
+// runPath returns the directory of the profiles of the run in the claim
+func runPath(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	return path.Join(instance.Namespace, instance.Name)
+}
+
+// currentCollectorPod returns the collector pod of the run, nil if it doesn't exist.
+func (r *NodeObservabilityRunReconciler) currentCollectorPod(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Name: collectorPodName(instance), Namespace: instance.Namespace}, pod); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get collector pod: %w", err)
+	}
+	return pod, nil
+}
+
+// collectionStarted returns true if the profiles of the run are being collected
+// into the persistent volume claim.
+func (r *NodeObservabilityRunReconciler) collectionStarted(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (bool, error) {
+	if instance.Spec.StorageBackend == nil || instance.Spec.StorageBackend.Type != nodeobservabilityv1alpha2.PVCStorageBackendType {
+		return false, nil
+	}
+	pod, err := r.currentCollectorPod(ctx, instance)
+	return pod != nil, err
+}
+
+// collectArtifacts collects the profiles of the agents which finished the run
+// into the persistent volume claim and records their path.
+// The profiles are collected by a pod which mounts the claim,
+// the agents whose profiles cannot be collected are moved to the failed agents.
+// Returns true once the collection is completed.
+func (r *NodeObservabilityRunReconciler) collectArtifacts(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (bool, error) {
+	if len(instance.Status.Agents) == 0 {
+		return true, nil
+	}
+
+	spec := instance.Spec.StorageBackend.PVC
+	if spec == nil {
+		failAllAgents(instance)
+		return true, fmt.Errorf("pvc storage backend is not configured")
+	}
+
+	pod, err := r.currentCollectorPod(ctx, instance)
+	if err != nil {
+		return false, err
+	}
+	if pod == nil {
+		claim := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, types.NamespacedName{Name: spec.ClaimName, Namespace: instance.Namespace}, claim); err != nil {
+			if errors.IsNotFound(err) {
+				failAllAgents(instance)
+				return true, fmt.Errorf("persistent volume claim %q not found", spec.ClaimName)
+			}
+			return false, fmt.Errorf("failed to get persistent volume claim %q: %w", spec.ClaimName, err)
+		}
+		if !claimWritable(claim) {
+			failAllAgents(instance)
+			return true, fmt.Errorf("persistent volume claim %q is not writable: access modes %v", spec.ClaimName, claim.Spec.AccessModes)
+		}
+		if !claimShareable(claim) {
+			// a ReadWriteOnce claim can only be attached to a single node,
+			// the collectors of the different runs using it are serialized
+			busy, err := r.claimInUse(ctx, instance, spec.ClaimName)
+			if err != nil {
+				return false, err
+			}
+			if busy != "" {
+				r.Log.V(1).Info("Persistent volume claim is used by another collector, waiting", "Claim", spec.ClaimName, "Pod", busy)
+				return false, nil
+			}
+		}
+
+		pullSecrets, err := r.collectorImagePullSecrets(ctx, instance)
+		if err != nil {
+			return false, err
+		}
+		if err := r.ensureCollectorServiceAccount(ctx, instance); err != nil {
+			return false, err
+		}
+		desired := r.desiredCollectorPod(instance, pullSecrets)
+		if err := ctrlutil.SetControllerReference(instance, desired, r.Scheme); err != nil {
+			return false, fmt.Errorf("failed to set the controller reference for collector pod: %w", err)
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return false, fmt.Errorf("failed to create collector pod: %w", err)
+		}
+		r.Log.V(1).Info("Created collector pod", "Pod", desired.Name, "Claim", spec.ClaimName)
+		return false, nil
+	}
+
+	var results collector.Results
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		results, err = collectorResults(pod)
+		if err != nil {
+			failAllAgents(instance)
+			return true, fmt.Errorf("failed to parse the results of collector pod %q: %w", pod.Name, err)
+		}
+	case corev1.PodFailed:
+		failAllAgents(instance)
+		return true, fmt.Errorf("collector pod %q failed: %s", pod.Name, pod.Status.Message)
+	default:
+		return false, nil
+	}
+
+	tmpl, err := nodeobservabilityv1alpha2.ParseArtifactKeyTemplate(instance.Spec.ArtifactKeyTemplate)
+	if err != nil {
+		failAllAgents(instance)
+		return true, fmt.Errorf("failed to parse the artifact key template: %w", err)
+	}
+
+	var errs []error
+	var collected, failed []nodeobservabilityv1alpha2.AgentNode
+	for _, agent := range instance.Status.Agents {
+		if msg, found := results[agent.Name]; found {
+			r.Log.V(1).Info("Failed to collect the profiles, removing node from list", "Name", agent.Name, "IP", agent.IP, "Error", msg)
+			errs = append(errs, fmt.Errorf("failed to collect the profiles of the agent named %q with %q IP: %s", agent.Name, agent.IP, msg))
+			failed = append(failed, failAgent(agent))
+			continue
+		}
+		artifacts, dir, err := claimArtifacts(tmpl, instance, spec.ClaimName, agent.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list the profiles of the agent named %q with %q IP: %w", agent.Name, agent.IP, err))
+			failed = append(failed, failAgent(agent))
+			continue
+		}
+		agent.Artifacts = artifacts
+		agent.Path = dir
+		collected = append(collected, agent)
+	}
+	instance.Status.Agents = collected
+	instance.Status.FailedAgents = append(instance.Status.FailedAgents, failed...)
+	return true, utilerrors.NewAggregate(errs)
+}
+
+// claimInUse returns the name of the running collector pod of another run
+// which mounts the given claim, an empty string if there is none.
+func (r *NodeObservabilityRunReconciler) claimInUse(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, claimName string) (string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(instance.Namespace), client.HasLabels{collectorLabel}); err != nil {
+		return "", fmt.Errorf("failed to list collector pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Name == collectorPodName(instance) || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == claimName {
+				return pod.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// collectorImagePullSecrets returns the image pull secrets
+// of the NodeObservability referenced by the run, none if it doesn't exist.
+func (r *NodeObservabilityRunReconciler) collectorImagePullSecrets(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) ([]corev1.LocalObjectReference, error) {
+	nodeObs := &nodeobservabilityv1alpha2.NodeObservability{}
+	if err := r.Get(ctx, types.NamespacedName{Name: instance.Spec.NodeObservabilityRef.Name}, nodeObs); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get NodeObservability %q: %w", instance.Spec.NodeObservabilityRef.Name, err)
+	}
+	return nodeObs.Spec.ImagePullSecrets, nil
+}
+
+// desiredCollectorPod returns the pod which collects the profiles
+// of the agents of the run into the persistent volume claim.
+func (r *NodeObservabilityRunReconciler) desiredCollectorPod(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, pullSecrets []corev1.LocalObjectReference) *corev1.Pod {
+	outputDir := path.Join(collectorOutputPath, runPath(instance))
+	if instance.Spec.ArtifactKeyTemplate != "" {
+		// the keys are relative to the root of the claim
+		outputDir = collectorOutputPath
+	}
+	args := []string{
+		collector.Command,
+		fmt.Sprintf("--output-dir=%s", outputDir),
+		fmt.Sprintf("--artifacts=%s", strings.Join(runArtifacts(instance), ",")),
+		fmt.Sprintf("--token-file=%s", collectorTokenFile),
+		fmt.Sprintf("--ca-cert-file=%s", path.Join(collectorCAPath, collectorCAFile)),
+	}
+	if gzipsArtifacts(instance) {
+		args = append(args, "--gzip")
+	}
+	if instance.Spec.ArtifactKeyTemplate != "" {
+		args = append(args,
+			fmt.Sprintf("--key-template=%s", instance.Spec.ArtifactKeyTemplate),
+			fmt.Sprintf("--namespace=%s", instance.Namespace),
+			fmt.Sprintf("--run-name=%s", instance.Name),
+		)
+		if instance.Status.StartTimestamp != nil {
+			args = append(args, fmt.Sprintf("--timestamp=%s", instance.Status.StartTimestamp.UTC().Format(time.RFC3339)))
+		}
+	}
+	if maxSize := maxArtifactSize(instance); maxSize > 0 {
+		args = append(args, fmt.Sprintf("--max-artifact-size=%d", maxSize))
+	}
+	for _, agent := range instance.Status.Agents {
+		url := r.format(agent.IP, r.AgentName, r.Namespace, pprofOutput, agent.Port)
+		args = append(args, fmt.Sprintf("--agent=%s=%s", agent.Name, url))
+	}
+
+	// the labels of the run never override the one the collector pods are tracked with
+	labels := map[string]string{}
+	for k, v := range instance.Spec.PodLabels {
+		labels[k] = v
+	}
+	labels[collectorLabel] = ""
+	var annotations map[string]string
+	if len(instance.Spec.PodAnnotations) > 0 {
+		annotations = make(map[string]string, len(instance.Spec.PodAnnotations))
+		for k, v := range instance.Spec.PodAnnotations {
+			annotations[k] = v
+		}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        collectorPodName(instance),
+			Namespace:   instance.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: r.CollectorServiceAccount,
+			ImagePullSecrets:   pullSecrets,
+			Containers: []corev1.Container{
+				{
+					Name:                     collectorContainerName,
+					Image:                    r.CollectorImage,
+					ImagePullPolicy:          corev1.PullIfNotPresent,
+					Command:                  []string{collectorBinary},
+					Args:                     args,
+					TerminationMessagePath:   collector.DefaultTerminationMessagePath,
+					TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: pointer.Bool(false),
+						Capabilities: &corev1.Capabilities{
+							Drop: []corev1.Capability{"ALL"},
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      collectorOutputName,
+							MountPath: collectorOutputPath,
+						},
+						{
+							Name:      collectorCAName,
+							MountPath: collectorCAPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: collectorOutputName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: instance.Spec.StorageBackend.PVC.ClaimName,
+						},
+					},
+				},
+				{
+					Name: collectorCAName,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: collectorCABundleConfigMap(instance),
+							},
+							Items: []corev1.KeyToPath{
+								{
+									Key:  collectorCAFile,
+									Path: collectorCAFile,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// collectorCABundleConfigMap returns the config map with the service CA bundle
+// verifying the agents mounted by the collector pod, the one referenced by the run if any.
+func collectorCABundleConfigMap(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	if ref := instance.Spec.ServiceCABundleRef; ref != nil {
+		return ref.Name
+	}
+	return collectorCAConfigMap
+}
+
+// collectorResults returns the results written by the collector in its termination message.
+func collectorResults(pod *corev1.Pod) (collector.Results, error) {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == collectorContainerName && cs.State.Terminated != nil {
+			return collector.ParseResults(cs.State.Terminated.Message)
+		}
+	}
+	return nil, fmt.Errorf("no terminated %s container", collectorContainerName)
+}
+
+// claimArtifacts returns the profiles of the node written by the collector in the claim
+// with the artifact key template of the run, their size is not reported by the collector.
+// Also returns the directory of the profiles in the claim,
+// empty if the template spreads them over several directories.
+func claimArtifacts(tmpl *template.Template, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, claimName, node string) ([]nodeobservabilityv1alpha2.ProfileArtifact, string, error) {
+	var artifacts []nodeobservabilityv1alpha2.ProfileArtifact
+	var keys []string
+	for _, artifact := range runArtifacts(instance) {
+		key, err := artifactKey(tmpl, instance, node, artifact)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to compute the key of profile %q: %w", artifact, err)
+		}
+		stored := storedArtifact(instance, artifact)
+		stored.URI = fmt.Sprintf("pvc://%s/%s", claimName, key)
+		artifacts = append(artifacts, stored)
+		keys = append(keys, key)
+	}
+	return artifacts, commonDir(keys), nil
+}
+
+// claimWritable returns true if the claim can be mounted read-write
+func claimWritable(claim *corev1.PersistentVolumeClaim) bool {
+	for _, mode := range claim.Spec.AccessModes {
+		if mode == corev1.ReadWriteOnce || mode == corev1.ReadWriteMany || mode == corev1.ReadWriteOncePod {
+			return true
+		}
+	}
+	return false
+}
+
+// claimShareable returns true if the claim can be mounted read-write by several nodes at once
+func claimShareable(claim *corev1.PersistentVolumeClaim) bool {
+	for _, mode := range claim.Spec.AccessModes {
+		if mode == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+// failAllAgents moves all the agents of the run to the failed agents
+func failAllAgents(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	for _, agent := range instance.Status.Agents {
+		instance.Status.FailedAgents = append(instance.Status.FailedAgents, failAgent(agent))
+	}
+	instance.Status.Agents = nil
+}
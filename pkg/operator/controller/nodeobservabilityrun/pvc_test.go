@@ -0,0 +1,448 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+const (
+	testClaimName      = "profiles"
+	testCollectorImage = "quay.io/openshift/node-observability-operator:test"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	agent1 := operatorv1alpha2.AgentNode{Name: "node-1", IP: "10.0.0.1", Port: 8443}
+	agent2 := operatorv1alpha2.AgentNode{Name: "node-2", IP: "10.0.0.2", Port: 8443}
+	withPath := func(agent operatorv1alpha2.AgentNode) operatorv1alpha2.AgentNode {
+		agent.Path = namespace + "/" + name + "/" + agent.Name
+		for _, artifact := range profileArtifacts {
+			agent.Artifacts = append(agent.Artifacts, operatorv1alpha2.ProfileArtifact{
+				Name: artifact,
+				URI:  "pvc://" + testClaimName + "/" + agent.Path + "/" + artifact,
+			})
+		}
+		return agent
+	}
+
+	cases := []struct {
+		name                 string
+		existingObjects      []runtime.Object
+		done                 bool
+		errExpected          bool
+		podExpected          bool
+		expectedAgents       []operatorv1alpha2.AgentNode
+		expectedFailedAgents []operatorv1alpha2.AgentNode
+	}{
+		{
+			name:            "collector pod created",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce)},
+			podExpected:     true,
+			expectedAgents:  []operatorv1alpha2.AgentNode{agent1, agent2},
+		},
+		{
+			name: "collector of another run using the ReadWriteOnce claim",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod("other", testClaimName, corev1.PodRunning, ""),
+			},
+			expectedAgents: []operatorv1alpha2.AgentNode{agent1, agent2},
+		},
+		{
+			name: "collector of another run finished",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod("other", testClaimName, corev1.PodSucceeded, "{}"),
+			},
+			podExpected:    true,
+			expectedAgents: []operatorv1alpha2.AgentNode{agent1, agent2},
+		},
+		{
+			name: "collector of another run using the ReadWriteMany claim",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteMany),
+				testCollectorPod("other", testClaimName, corev1.PodRunning, ""),
+			},
+			podExpected:    true,
+			expectedAgents: []operatorv1alpha2.AgentNode{agent1, agent2},
+		},
+		{
+			name:                 "claim not found",
+			done:                 true,
+			errExpected:          true,
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed), withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name:                 "read only claim",
+			existingObjects:      []runtime.Object{testClaim(testClaimName, corev1.ReadOnlyMany)},
+			done:                 true,
+			errExpected:          true,
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed), withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name: "collector pod running",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod(name, testClaimName, corev1.PodRunning, ""),
+			},
+			podExpected:    true,
+			expectedAgents: []operatorv1alpha2.AgentNode{agent1, agent2},
+		},
+		{
+			name: "profiles of all agents collected",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod(name, testClaimName, corev1.PodSucceeded, "{}"),
+			},
+			done:           true,
+			podExpected:    true,
+			expectedAgents: []operatorv1alpha2.AgentNode{withPath(agent1), withPath(agent2)},
+		},
+		{
+			name: "profiles of one agent not collected",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod(name, testClaimName, corev1.PodSucceeded, `{"node-2":"failed to retrieve crio.pprof: code 500"}`),
+			},
+			done:                 true,
+			errExpected:          true,
+			podExpected:          true,
+			expectedAgents:       []operatorv1alpha2.AgentNode{withPath(agent1)},
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+		{
+			name: "collector pod failed",
+			existingObjects: []runtime.Object{
+				testClaim(testClaimName, corev1.ReadWriteOnce),
+				testCollectorPod(name, testClaimName, corev1.PodFailed, ""),
+			},
+			done:                 true,
+			errExpected:          true,
+			podExpected:          true,
+			expectedFailedAgents: []operatorv1alpha2.AgentNode{withResult(agent1, operatorv1alpha2.AgentFailed), withResult(agent2, operatorv1alpha2.AgentFailed)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				Agents: []operatorv1alpha2.AgentNode{agent1, agent2},
+			})
+			run.Spec.StorageBackend = &operatorv1alpha2.StorageBackend{
+				Type: operatorv1alpha2.PVCStorageBackendType,
+				PVC:  &operatorv1alpha2.PVCStorageBackend{ClaimName: testClaimName},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:                  cl,
+				Scheme:                  test.Scheme,
+				Log:                     zap.New(zap.UseDevMode(true)),
+				URL:                     &url{},
+				AgentName:               name,
+				Namespace:               namespace,
+				CollectorImage:          testCollectorImage,
+				CollectorServiceAccount: name,
+			}
+
+			done, err := r.collectArtifacts(context.Background(), run)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if done != tc.done {
+				t.Fatalf("expected done to be %t, got %t", tc.done, done)
+			}
+			if !reflect.DeepEqual(run.Status.Agents, tc.expectedAgents) {
+				t.Fatalf("expected agents %v, got %v", tc.expectedAgents, run.Status.Agents)
+			}
+			if !reflect.DeepEqual(withoutTimestamps(run.Status.FailedAgents), tc.expectedFailedAgents) {
+				t.Fatalf("expected failed agents %v, got %v", tc.expectedFailedAgents, run.Status.FailedAgents)
+			}
+
+			pod := &corev1.Pod{}
+			err = cl.Get(context.Background(), types.NamespacedName{Name: collectorPodName(run), Namespace: namespace}, pod)
+			if tc.podExpected != (err == nil) {
+				t.Fatalf("expected collector pod to exist: %t, got error %v", tc.podExpected, err)
+			}
+		})
+	}
+}
+
+func TestCollectorServiceAccount(t *testing.T) {
+	const (
+		operatorNamespace = "node-observability-operator"
+		operandNamespace  = "node-observability-operand"
+		saName            = "node-observability-collector"
+	)
+	otherSubject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: saName, Namespace: "other"}
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: saName, Namespace: operatorNamespace}
+	roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: collectorClusterRoleName}
+
+	cases := []struct {
+		name             string
+		existingObjects  []runtime.Object
+		expectedSubjects []rbacv1.Subject
+	}{
+		{
+			name:             "service account and binding created",
+			expectedSubjects: []rbacv1.Subject{subject},
+		},
+		{
+			name: "service account bound next to the one of another namespace",
+			existingObjects: []runtime.Object{
+				&rbacv1.ClusterRoleBinding{
+					ObjectMeta: metav1.ObjectMeta{Name: collectorClusterRoleBindingName},
+					Subjects:   []rbacv1.Subject{otherSubject},
+					RoleRef:    roleRef,
+				},
+			},
+			expectedSubjects: []rbacv1.Subject{otherSubject, subject},
+		},
+		{
+			name: "service account already bound",
+			existingObjects: []runtime.Object{
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: saName, Namespace: operatorNamespace}},
+				&rbacv1.ClusterRoleBinding{
+					ObjectMeta: metav1.ObjectMeta{Name: collectorClusterRoleBindingName},
+					Subjects:   []rbacv1.Subject{subject},
+					RoleRef:    roleRef,
+				},
+			},
+			expectedSubjects: []rbacv1.Subject{subject},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the run is reconciled in the operator namespace while the agents run in the operand namespace
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				Agents: []operatorv1alpha2.AgentNode{{Name: "node-1", IP: "10.0.0.1", Port: 8443}},
+			})
+			run.Namespace = operatorNamespace
+			run.Spec.StorageBackend = &operatorv1alpha2.StorageBackend{
+				Type: operatorv1alpha2.PVCStorageBackendType,
+				PVC:  &operatorv1alpha2.PVCStorageBackend{ClaimName: testClaimName},
+			}
+			claim := testClaim(testClaimName, corev1.ReadWriteOnce)
+			claim.Namespace = operatorNamespace
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(append(tc.existingObjects, claim)...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:                  cl,
+				Scheme:                  test.Scheme,
+				Log:                     zap.New(zap.UseDevMode(true)),
+				URL:                     &url{},
+				AgentName:               name,
+				Namespace:               operandNamespace,
+				CollectorImage:          testCollectorImage,
+				CollectorServiceAccount: saName,
+			}
+
+			if _, err := r.collectArtifacts(context.Background(), run); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			pod := &corev1.Pod{}
+			if err := cl.Get(context.Background(), types.NamespacedName{Name: collectorPodName(run), Namespace: operatorNamespace}, pod); err != nil {
+				t.Fatalf("failed to get collector pod: %v", err)
+			}
+			if pod.Spec.ServiceAccountName != saName {
+				t.Errorf("expected service account %q, got %q", saName, pod.Spec.ServiceAccountName)
+			}
+			if err := cl.Get(context.Background(), types.NamespacedName{Name: saName, Namespace: operatorNamespace}, &corev1.ServiceAccount{}); err != nil {
+				t.Errorf("expected the collector service account in the namespace of the run: %v", err)
+			}
+			crb := &rbacv1.ClusterRoleBinding{}
+			if err := cl.Get(context.Background(), types.NamespacedName{Name: collectorClusterRoleBindingName}, crb); err != nil {
+				t.Fatalf("failed to get collector clusterrolebinding: %v", err)
+			}
+			if !reflect.DeepEqual(crb.RoleRef, roleRef) {
+				t.Errorf("expected role ref %v, got %v", roleRef, crb.RoleRef)
+			}
+			if !reflect.DeepEqual(crb.Subjects, tc.expectedSubjects) {
+				t.Errorf("expected subjects %v, got %v", tc.expectedSubjects, crb.Subjects)
+			}
+		})
+	}
+}
+
+func TestDesiredCollectorPod(t *testing.T) {
+	run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+		Agents: []operatorv1alpha2.AgentNode{{Name: "node-1", IP: "10.0.0.1", Port: 8443}},
+	})
+	run.Spec.StorageBackend = &operatorv1alpha2.StorageBackend{
+		Type: operatorv1alpha2.PVCStorageBackendType,
+		PVC:  &operatorv1alpha2.PVCStorageBackend{ClaimName: testClaimName},
+	}
+	r := NodeObservabilityRunReconciler{
+		URL:                     &url{},
+		AgentName:               "node-observability-agent",
+		Namespace:               "node-observability-operator",
+		CollectorImage:          testCollectorImage,
+		CollectorServiceAccount: "node-observability-collector",
+	}
+
+	pullSecrets := []corev1.LocalObjectReference{{Name: "registry-pull-secret"}}
+	pod := r.desiredCollectorPod(run, pullSecrets)
+	if pod.Spec.ServiceAccountName != "node-observability-collector" {
+		t.Errorf("expected service account %q, got %q", "node-observability-collector", pod.Spec.ServiceAccountName)
+	}
+	if !reflect.DeepEqual(pod.Spec.ImagePullSecrets, pullSecrets) {
+		t.Errorf("expected image pull secrets %v, got %v", pullSecrets, pod.Spec.ImagePullSecrets)
+	}
+	if pod.Spec.Containers[0].Image != testCollectorImage {
+		t.Errorf("expected image %q, got %q", testCollectorImage, pod.Spec.Containers[0].Image)
+	}
+	expectedArgs := []string{
+		"collect",
+		"--output-dir=/profiles/test/agent",
+		"--artifacts=kubelet.pprof,crio.pprof",
+		"--token-file=/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"--ca-cert-file=/var/run/secrets/openshift.io/certs/service-ca.crt",
+		"--agent=node-1=https://10-0-0-1.node-observability-agent.node-observability-operator.svc:8443/node-observability-output",
+	}
+	if !reflect.DeepEqual(pod.Spec.Containers[0].Args, expectedArgs) {
+		t.Errorf("expected args %v, got %v", expectedArgs, pod.Spec.Containers[0].Args)
+	}
+	if claim := pod.Spec.Volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != testClaimName {
+		t.Errorf("expected volume of claim %q, got %v", testClaimName, pod.Spec.Volumes[0])
+	}
+
+	run.Spec.Compression = operatorv1alpha2.GzipArtifactCompression
+	pod = r.desiredCollectorPod(run, pullSecrets)
+	if args := pod.Spec.Containers[0].Args; args[5] != "--gzip" {
+		t.Errorf("expected the gzip argument, got args %v", args)
+	}
+	expected := []operatorv1alpha2.ProfileArtifact{
+		{Name: "kubelet.pprof.gz", Compression: operatorv1alpha2.GzipArtifactCompression, URI: "pvc://" + testClaimName + "/test/agent/node-1/kubelet.pprof.gz"},
+		{Name: "crio.pprof.gz", Compression: operatorv1alpha2.GzipArtifactCompression, URI: "pvc://" + testClaimName + "/test/agent/node-1/crio.pprof.gz"},
+	}
+	artifacts, dir, err := claimArtifacts(template.Must(operatorv1alpha2.ParseArtifactKeyTemplate("")), run, testClaimName, "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(artifacts, expected) {
+		t.Errorf("expected artifacts %v, got %v", expected, artifacts)
+	}
+	if dir != "test/agent/node-1" {
+		t.Errorf("expected directory %q, got %q", "test/agent/node-1", dir)
+	}
+
+	run.Spec.ArtifactKeyTemplate = `profiles/{{.Timestamp.Format "2006-01-02"}}/{{.Node}}/{{.Type}}.pprof`
+	run.Status.StartTimestamp = &metav1.Time{Time: time.Date(2022, time.May, 12, 15, 25, 54, 0, time.UTC)}
+	pod = r.desiredCollectorPod(run, pullSecrets)
+	expectedArgs = []string{
+		"collect",
+		"--output-dir=/profiles",
+		"--artifacts=kubelet.pprof,crio.pprof",
+		"--token-file=/var/run/secrets/kubernetes.io/serviceaccount/token",
+		"--ca-cert-file=/var/run/secrets/openshift.io/certs/service-ca.crt",
+		"--gzip",
+		`--key-template=profiles/{{.Timestamp.Format "2006-01-02"}}/{{.Node}}/{{.Type}}.pprof`,
+		"--namespace=test",
+		"--run-name=agent",
+		"--timestamp=2022-05-12T15:25:54Z",
+		"--agent=node-1=https://10-0-0-1.node-observability-agent.node-observability-operator.svc:8443/node-observability-output",
+	}
+	if !reflect.DeepEqual(pod.Spec.Containers[0].Args, expectedArgs) {
+		t.Errorf("expected args %v, got %v", expectedArgs, pod.Spec.Containers[0].Args)
+	}
+	tmpl, err := operatorv1alpha2.ParseArtifactKeyTemplate(run.Spec.ArtifactKeyTemplate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	artifacts, dir, err = claimArtifacts(tmpl, run, testClaimName, "node-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if artifacts[0].URI != "pvc://"+testClaimName+"/profiles/2022-05-12/node-1/kubelet.pprof.gz" {
+		t.Errorf("unexpected location %q of the profile", artifacts[0].URI)
+	}
+	if dir != "profiles/2022-05-12/node-1" {
+		t.Errorf("expected directory %q, got %q", "profiles/2022-05-12/node-1", dir)
+	}
+
+	maxArtifactSize := resource.MustParse("100Mi")
+	run.Spec.MaxArtifactSize = &maxArtifactSize
+	pod = r.desiredCollectorPod(run, pullSecrets)
+	if args := pod.Spec.Containers[0].Args; args[len(args)-2] != "--max-artifact-size=104857600" {
+		t.Errorf("expected the max artifact size argument, got args %v", args)
+	}
+
+	run.Spec.ServiceCABundleRef = &corev1.LocalObjectReference{Name: "custom-service-ca"}
+	pod = r.desiredCollectorPod(run, pullSecrets)
+	var caBundle string
+	for _, v := range pod.Spec.Volumes {
+		if v.ConfigMap != nil {
+			caBundle = v.ConfigMap.Name
+		}
+	}
+	if caBundle != "custom-service-ca" {
+		t.Errorf("expected the referenced service CA bundle to be mounted, got config map %q", caBundle)
+	}
+
+	run.Spec.PodLabels = map[string]string{"cost-center": "infra", collectorLabel: "false"}
+	run.Spec.PodAnnotations = map[string]string{"example.com/chargeback": "infra"}
+	pod = r.desiredCollectorPod(run, pullSecrets)
+	expectedLabels := map[string]string{"cost-center": "infra", collectorLabel: ""}
+	if !reflect.DeepEqual(pod.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, pod.Labels)
+	}
+	if !reflect.DeepEqual(pod.Annotations, run.Spec.PodAnnotations) {
+		t.Errorf("expected annotations %v, got %v", run.Spec.PodAnnotations, pod.Annotations)
+	}
+}
+
+func testClaim(name string, mode corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{mode},
+		},
+	}
+}
+
+// testCollectorPod returns the collector pod of the given run in the given phase,
+// the results are set as the termination message of the terminated pod.
+func testCollectorPod(runName, claimName string, phase corev1.PodPhase, results string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runName + collectorPodNameSuffix,
+			Namespace: namespace,
+			Labels:    map[string]string{collectorLabel: ""},
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: collectorOutputName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if phase == corev1.PodSucceeded || phase == corev1.PodFailed {
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				Name:  collectorContainerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: results}},
+			},
+		}
+	}
+	return pod
+}
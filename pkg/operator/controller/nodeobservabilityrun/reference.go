@@ -0,0 +1,55 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// referenceGracePeriod is the time given to the referenced NodeObservability
+// to be created after the creation of the run
+const referenceGracePeriod = 2 * time.Minute
+
+// referenceExists returns true if the NodeObservability referenced by the given run exists.
+func (r *NodeObservabilityRunReconciler) referenceExists(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (bool, error) {
+	nodeObs := &nodeobservabilityv1alpha2.NodeObservability{}
+	if err := r.Get(ctx, types.NamespacedName{Name: instance.Spec.NodeObservabilityRef.Name}, nodeObs); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get nodeobservability: %w", err)
+	}
+	return true, nil
+}
+
+// handleMissingReference waits for the NodeObservability referenced by the given run
+// to be created during the grace period, the run is marked as finished without
+// being started once the grace period is over.
+func (r *NodeObservabilityRunReconciler) handleMissingReference(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) ctrl.Result {
+	msg := fmt.Sprintf("Referenced nodeobservability %s not found", instance.Spec.NodeObservabilityRef.Name)
+	if remaining := time.Until(instance.CreationTimestamp.Add(referenceGracePeriod)); remaining > 0 {
+		r.Log.V(1).Info("Waiting for the referenced nodeobservability", "name", instance.Spec.NodeObservabilityRef.Name)
+		instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonReferenceNotFound, msg)
+		if remaining > pollingPeriod {
+			remaining = pollingPeriod
+		}
+		return ctrl.Result{RequeueAfter: remaining}
+	}
+
+	msg = fmt.Sprintf("%s after %s", msg, referenceGracePeriod)
+	r.Log.V(1).Info("Run failed as the referenced nodeobservability was not found", "name", instance.Spec.NodeObservabilityRef.Name)
+	t := metav1.Now()
+	instance.Status.FinishedTimestamp = &t
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugReady, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonReferenceNotFound, msg)
+	instance.Status.SetCondition(nodeobservabilityv1alpha2.DebugFinished, metav1.ConditionFalse, nodeobservabilityv1alpha2.ReasonReferenceNotFound, msg)
+	r.EventRecorder.Event(instance, corev1.EventTypeWarning, nodeobservabilityv1alpha2.ReasonReferenceNotFound, msg)
+	return ctrl.Result{}
+}
@@ -0,0 +1,136 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestReconcileReference(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+
+	cases := []struct {
+		name            string
+		created         time.Duration
+		existingObjects []runtime.Object
+		// requeueAfter is the expected requeue delay, up to a second shorter
+		// as the time passes since the creation of the run
+		requeueAfter  time.Duration
+		started       bool
+		failed        bool
+		expectedEvent string
+	}{
+		{
+			name:            "reference exists",
+			created:         -time.Minute,
+			existingObjects: []runtime.Object{testNodeObservability()},
+			requeueAfter:    30 * time.Second,
+			started:         true,
+		},
+		{
+			name:         "reference not found within the grace period",
+			created:      -time.Minute,
+			requeueAfter: pollingPeriod,
+		},
+		{
+			name:         "reference not found at the end of the grace period",
+			created:      -referenceGracePeriod + time.Second,
+			requeueAfter: time.Second,
+		},
+		{
+			name:          "reference not found after the grace period",
+			created:       -referenceGracePeriod - time.Second,
+			failed:        true,
+			expectedEvent: corev1.EventTypeWarning + " " + operatorv1alpha2.ReasonReferenceNotFound,
+		},
+		{
+			name:            "reference created after the grace period",
+			created:         -referenceGracePeriod - time.Second,
+			existingObjects: []runtime.Object{testNodeObservability()},
+			requeueAfter:    30 * time.Second,
+			started:         true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.CreationTimestamp = metav1.NewTime(time.Now().Add(tc.created))
+			// no agent to call once started
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Subsets: []corev1.EndpointSubset{
+					{Ports: []corev1.EndpointPort{{Name: "test-port", Port: 8443}}},
+				},
+			}
+			objs := append([]runtime.Object{run, endpoints}, tc.existingObjects...)
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: recorder,
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+				CACertFile:    CAPath,
+			}
+
+			res, err := r.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			if res.Requeue || res.RequeueAfter > tc.requeueAfter || res.RequeueAfter <= tc.requeueAfter-time.Second {
+				t.Fatalf("expected requeue after %s, got %v", tc.requeueAfter, res)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if inProgress(got) != tc.started {
+				t.Fatalf("expected run started to be %t, got %t", tc.started, inProgress(got))
+			}
+			if finished(got) != tc.failed {
+				t.Fatalf("expected run finished to be %t, got %t", tc.failed, finished(got))
+			}
+			cond := got.Status.GetCondition(operatorv1alpha2.DebugReady)
+			if notFound := cond != nil && cond.Reason == operatorv1alpha2.ReasonReferenceNotFound; notFound != !tc.started {
+				t.Fatalf("unexpected ready condition %v", cond)
+			}
+			if tc.failed {
+				cond := got.Status.GetCondition(operatorv1alpha2.DebugFinished)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != operatorv1alpha2.ReasonReferenceNotFound {
+					t.Fatalf("expected finished condition to be reference not found, got %v", cond)
+				}
+				if got.Status.Phase != operatorv1alpha2.RunFailed {
+					t.Fatalf("expected phase %s, got %s", operatorv1alpha2.RunFailed, got.Status.Phase)
+				}
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if tc.expectedEvent == "" || !strings.HasPrefix(event, tc.expectedEvent) {
+					t.Fatalf("expected event %q, got %q", tc.expectedEvent, event)
+				}
+			default:
+				if tc.expectedEvent != "" {
+					t.Fatalf("expected event %q, got none", tc.expectedEvent)
+				}
+			}
+		})
+	}
+}
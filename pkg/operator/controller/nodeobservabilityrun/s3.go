@@ -0,0 +1,310 @@
+package nodeobservabilityruncontroller
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	configv1 "github.com/openshift/api/config/v1"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// s3AccessKeyIDKey and s3SecretAccessKeyKey are the keys
+	// of the credentials in the secret referenced by the S3 storage backend
+	s3AccessKeyIDKey     = "aws_access_key_id"
+	s3SecretAccessKeyKey = "aws_secret_access_key"
+	// s3CABundleKey is the key of the CA bundle
+	// in the config map referenced by the S3 storage backend
+	s3CABundleKey = "ca-bundle.crt"
+
+	// clusterProxyName is the name of the cluster-wide Proxy,
+	// its CA bundle config map is in the clusterProxyCANamespace namespace
+	clusterProxyName        = "cluster"
+	clusterProxyCANamespace = "openshift-config"
+
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+)
+
+// proxyFromEnvironment returns the proxy of the requests
+// from the environment variables of the operator
+var proxyFromEnvironment = http.ProxyFromEnvironment
+
+// s3Storage uploads objects to an S3-compatible bucket
+type s3Storage struct {
+	endpoint        *neturl.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	forcePathStyle  bool
+	client          *http.Client
+}
+
+// newS3Storage builds the S3 storage from the storage backend of the run,
+// the credentials and the CA bundle are read from the namespace of the run.
+func (r *NodeObservabilityRunReconciler) newS3Storage(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (*s3Storage, error) {
+	backend := instance.Spec.StorageBackend
+	if backend.Type != nodeobservabilityv1alpha2.S3StorageBackendType {
+		return nil, fmt.Errorf("unsupported storage backend type %q", backend.Type)
+	}
+	spec := backend.S3
+	if spec == nil {
+		return nil, fmt.Errorf("s3 storage backend is not configured")
+	}
+
+	region := spec.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	rawEndpoint := spec.Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	endpoint, err := neturl.Parse(rawEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse s3 endpoint %q: %w", rawEndpoint, err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: expected an http or https URL", rawEndpoint)
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: spec.CredentialsSecretRef.Name, Namespace: instance.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get s3 credentials secret %q: %w", spec.CredentialsSecretRef.Name, err)
+	}
+	accessKeyID, secretAccessKey := string(secret.Data[s3AccessKeyIDKey]), string(secret.Data[s3SecretAccessKeyKey])
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("s3 credentials secret %q must contain the %s and %s keys", spec.CredentialsSecretRef.Name, s3AccessKeyIDKey, s3SecretAccessKeyKey)
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = s3Proxy(spec)
+	pool, err := r.s3RootCAs(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	if pool != nil {
+		t.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &s3Storage{
+		endpoint:        endpoint,
+		bucket:          spec.Bucket,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		forcePathStyle:  spec.ForcePathStyle,
+		client: &http.Client{
+			Timeout:   time.Minute,
+			Transport: t,
+		},
+	}, nil
+}
+
+// s3Proxy returns the proxy of the uploads to the S3 storage backend,
+// falls back to the proxy from the environment if not set in the spec.
+func s3Proxy(spec *nodeobservabilityv1alpha2.S3StorageBackend) func(*http.Request) (*neturl.URL, error) {
+	if spec.ProxyPolicy == nodeobservabilityv1alpha2.NoProxyPolicy {
+		return nil
+	}
+	return proxyFromEnvironment
+}
+
+// s3RootCAs returns the CAs trusted by the uploads to the S3 storage backend
+// in addition to the system ones, nil if only the system ones are trusted.
+func (r *NodeObservabilityRunReconciler) s3RootCAs(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (*x509.CertPool, error) {
+	spec := instance.Spec.StorageBackend.S3
+	if spec.CABundleRef == nil && !spec.TrustClusterProxyCA {
+		return nil, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if spec.CABundleRef != nil {
+		cm := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: spec.CABundleRef.Name, Namespace: instance.Namespace}, cm); err != nil {
+			return nil, fmt.Errorf("failed to get s3 CA bundle config map %q: %w", spec.CABundleRef.Name, err)
+		}
+		if !pool.AppendCertsFromPEM([]byte(cm.Data[s3CABundleKey])) {
+			return nil, fmt.Errorf("no valid certificate found in the %s key of the s3 CA bundle config map %q", s3CABundleKey, spec.CABundleRef.Name)
+		}
+	}
+
+	if spec.TrustClusterProxyCA {
+		proxy := &configv1.Proxy{}
+		if err := r.Get(ctx, types.NamespacedName{Name: clusterProxyName}, proxy); err != nil {
+			return nil, fmt.Errorf("failed to get cluster proxy %q: %w", clusterProxyName, err)
+		}
+		// the cluster proxy may not have any CA bundle
+		if name := proxy.Spec.TrustedCA.Name; name != "" {
+			cm := &corev1.ConfigMap{}
+			if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: clusterProxyCANamespace}, cm); err != nil {
+				return nil, fmt.Errorf("failed to get cluster proxy CA bundle config map %q: %w", name, err)
+			}
+			if !pool.AppendCertsFromPEM([]byte(cm.Data[s3CABundleKey])) {
+				return nil, fmt.Errorf("no valid certificate found in the %s key of the cluster proxy CA bundle config map %q", s3CABundleKey, name)
+			}
+		}
+	}
+	return pool, nil
+}
+
+// objectURL returns the URL of the object with the given key,
+// the bucket is either in the path or in the host name of the URL.
+func (s *s3Storage) objectURL(key string) *neturl.URL {
+	u := *s.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.forcePathStyle {
+		u.Path = fmt.Sprintf("%s/%s/%s", basePath, s.bucket, key)
+	} else {
+		u.Host = fmt.Sprintf("%s.%s", s.bucket, u.Host)
+		u.Path = fmt.Sprintf("%s/%s", basePath, key)
+	}
+	return &u
+}
+
+// upload puts the given data in the object with the given key.
+func (s *s3Storage) upload(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	payloadHash := sha256.Sum256(data)
+	signRequest(req, hex.EncodeToString(payloadHash[:]), s.accessKeyID, s.secretAccessKey, s.region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return s3StatusError{op: "upload", key: key, code: resp.StatusCode, body: string(body)}
+	}
+	return nil
+}
+
+// delete deletes the object with the given key,
+// an object which doesn't exist is considered as deleted.
+func (s *s3Storage) delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256.Sum256(nil)
+	signRequest(req, hex.EncodeToString(payloadHash[:]), s.accessKeyID, s.secretAccessKey, s.region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return fmt.Errorf("failed to delete object %q: code %d: %s", key, resp.StatusCode, string(body))
+}
+
+// s3StatusError is the unexpected status of the response to a request on an object
+type s3StatusError struct {
+	op   string
+	key  string
+	code int
+	body string
+}
+
+func (e s3StatusError) Error() string {
+	return fmt.Sprintf("failed to %s object %q: code %d: %s", e.op, e.key, e.code, e.body)
+}
+
+// signRequest signs the request with AWS Signature Version 4
+// for the S3 service, all the headers of the request are signed.
+func signRequest(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	date := t.Format(sigV4DateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// collectorClusterRoleName only allows to retrieve the profiles from the agents,
+	// it is created via operator bundle, refer to config/rbac/collector_role.yaml
+	collectorClusterRoleName        = "node-observability-operator-collector"
+	collectorClusterRoleBindingName = "node-observability-collector"
+)
+
+// ensureCollectorServiceAccount ensures that the service account of the collector pods
+// exists in the namespace of the run and is bound to the collector cluster role.
+// The service account is shared by the runs of the namespace, it's not owned by any of them.
+func (r *NodeObservabilityRunReconciler) ensureCollectorServiceAccount(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) error {
+	nameSpace := types.NamespacedName{Namespace: instance.Namespace, Name: r.CollectorServiceAccount}
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, nameSpace, sa); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get collector serviceaccount %q: %w", nameSpace, err)
+		}
+		sa = &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: nameSpace.Namespace,
+				Name:      nameSpace.Name,
+			},
+		}
+		if err := r.Create(ctx, sa); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create collector serviceaccount %q: %w", nameSpace, err)
+		}
+		r.Log.V(1).Info("Created collector serviceaccount", "ServiceAccount", nameSpace)
+	}
+
+	subject := rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      nameSpace.Name,
+		Namespace: nameSpace.Namespace,
+	}
+	crb := &rbacv1.ClusterRoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: collectorClusterRoleBindingName}, crb); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get clusterrolebinding %q: %w", collectorClusterRoleBindingName, err)
+		}
+		crb = &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: collectorClusterRoleBindingName,
+			},
+			Subjects: []rbacv1.Subject{subject},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     collectorClusterRoleName,
+			},
+		}
+		if err := r.Create(ctx, crb); err != nil {
+			return fmt.Errorf("failed to create clusterrolebinding %q: %w", collectorClusterRoleBindingName, err)
+		}
+		r.Log.V(1).Info("Created collector clusterrolebinding", "ClusterRoleBinding", collectorClusterRoleBindingName)
+		return nil
+	}
+
+	// the collectors of the runs of all the namespaces are bound to the same cluster role
+	for _, s := range crb.Subjects {
+		if s == subject {
+			return nil
+		}
+	}
+	updated := crb.DeepCopy()
+	updated.Subjects = append(updated.Subjects, subject)
+	if err := r.Update(ctx, updated); err != nil {
+		return fmt.Errorf("failed to update clusterrolebinding %q: %w", collectorClusterRoleBindingName, err)
+	}
+	r.Log.V(1).Info("Bound collector serviceaccount", "ClusterRoleBinding", collectorClusterRoleBindingName, "ServiceAccount", nameSpace)
+	return nil
+}
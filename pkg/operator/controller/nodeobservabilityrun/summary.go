@@ -0,0 +1,65 @@
+package nodeobservabilityruncontroller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+// updateSummary aggregates the profiling of the nodes which finished it:
+// the succeeded and failed nodes, the time they took and the size of their profiles.
+// The nodes which failed before starting the profiling have no duration.
+func updateSummary(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	summary := &nodeobservabilityv1alpha2.RunSummary{}
+	var total time.Duration
+	var timed int64
+	observe := func(agent nodeobservabilityv1alpha2.AgentNode) {
+		for _, artifact := range agent.Artifacts {
+			if artifact.Size != nil {
+				summary.TotalBytes += *artifact.Size
+			}
+		}
+		if agent.StartTimestamp == nil || agent.FinishedTimestamp == nil {
+			return
+		}
+		d := agent.FinishedTimestamp.Sub(agent.StartTimestamp.Time).Round(time.Second)
+		if summary.MinDuration == nil || d < summary.MinDuration.Duration {
+			summary.MinDuration, summary.MinDurationNode = &metav1.Duration{Duration: d}, agentNodeName(agent)
+		}
+		if summary.MaxDuration == nil || d > summary.MaxDuration.Duration {
+			summary.MaxDuration, summary.MaxDurationNode = &metav1.Duration{Duration: d}, agentNodeName(agent)
+		}
+		total += d
+		timed++
+	}
+
+	for _, agent := range instance.Status.Agents {
+		if agent.Result == nodeobservabilityv1alpha2.AgentSucceeded {
+			summary.SucceededNodes++
+			observe(agent)
+		}
+	}
+	for _, agent := range instance.Status.FailedAgents {
+		summary.FailedNodes++
+		observe(agent)
+	}
+	if summary.SucceededNodes == 0 && summary.FailedNodes == 0 {
+		instance.Status.Summary = nil
+		return
+	}
+	if timed > 0 {
+		summary.AverageDuration = &metav1.Duration{Duration: (total / time.Duration(timed)).Round(time.Second)}
+	}
+	instance.Status.Summary = summary
+}
+
+// agentNodeName returns the name of the node of the agent,
+// the name of the agent if the node is not known.
+func agentNodeName(agent nodeobservabilityv1alpha2.AgentNode) string {
+	if agent.NodeName != "" {
+		return agent.NodeName
+	}
+	return agent.Name
+}
@@ -0,0 +1,82 @@
+package nodeobservabilityruncontroller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+func TestUpdateSummary(t *testing.T) {
+	started := metav1.NewTime(time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC))
+	agent := func(node string, result operatorv1alpha2.AgentResult, took time.Duration, sizes ...int64) operatorv1alpha2.AgentNode {
+		a := operatorv1alpha2.AgentNode{Name: "agent-" + node, NodeName: node, Result: result, StartTimestamp: &started}
+		if took > 0 {
+			finished := metav1.NewTime(started.Add(took))
+			a.FinishedTimestamp = &finished
+		}
+		for _, size := range sizes {
+			a.Artifacts = append(a.Artifacts, operatorv1alpha2.ProfileArtifact{Name: "kubelet.pprof", Size: pointer.Int64(size)})
+		}
+		return a
+	}
+	duration := func(d time.Duration) *metav1.Duration {
+		return &metav1.Duration{Duration: d}
+	}
+
+	cases := []struct {
+		name            string
+		status          operatorv1alpha2.NodeObservabilityRunStatus
+		expectedSummary *operatorv1alpha2.RunSummary
+	}{
+		{
+			name: "no node finished",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				Agents: []operatorv1alpha2.AgentNode{agent("node-1", operatorv1alpha2.AgentRunning, 0)},
+			},
+		},
+		{
+			name: "nodes finished",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				Agents: []operatorv1alpha2.AgentNode{
+					agent("node-1", operatorv1alpha2.AgentSucceeded, 31*time.Second, 1024, 2048),
+					agent("node-2", operatorv1alpha2.AgentSucceeded, 92*time.Second, 512),
+					agent("node-3", operatorv1alpha2.AgentRunning, 0),
+				},
+				FailedAgents: []operatorv1alpha2.AgentNode{
+					agent("node-4", operatorv1alpha2.AgentFailed, 44*time.Second),
+				},
+			},
+			expectedSummary: &operatorv1alpha2.RunSummary{
+				SucceededNodes:  2,
+				FailedNodes:     1,
+				MinDuration:     duration(31 * time.Second),
+				MinDurationNode: "node-1",
+				MaxDuration:     duration(92 * time.Second),
+				MaxDurationNode: "node-2",
+				AverageDuration: duration(56 * time.Second),
+				TotalBytes:      3584,
+			},
+		},
+		{
+			name: "failed before starting",
+			status: operatorv1alpha2.NodeObservabilityRunStatus{
+				FailedAgents: []operatorv1alpha2.AgentNode{{Name: "agent-node-1", Result: operatorv1alpha2.AgentFailed}},
+			},
+			expectedSummary: &operatorv1alpha2.RunSummary{FailedNodes: 1},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRunWithStatus(tc.status)
+			updateSummary(run)
+			if !reflect.DeepEqual(run.Status.Summary, tc.expectedSummary) {
+				t.Fatalf("expected summary %+v, got %+v", tc.expectedSummary, run.Status.Summary)
+			}
+		})
+	}
+}
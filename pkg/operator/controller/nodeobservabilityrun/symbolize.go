@@ -0,0 +1,39 @@
+package nodeobservabilityruncontroller
+
+import (
+	"strings"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
+)
+
+// pprofSymbolizeParam requests the agent to symbolize the profiles with the binaries of the node
+const pprofSymbolizeParam = "symbolize"
+
+// withSymbolizeParam returns the given agent path
+// requesting the agent to symbolize the profiles if required by the run.
+func withSymbolizeParam(path string, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	if !instance.Spec.Symbolize {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + pprofSymbolizeParam + "=true"
+}
+
+// inspectSymbols reports the symbolization of the retrieved profile in the stored artifact
+// if the run requests symbolized profiles, the profiles which cannot be parsed are left unreported.
+func (r *NodeObservabilityRunReconciler) inspectSymbols(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, artifact *nodeobservabilityv1alpha2.ProfileArtifact, data []byte) {
+	if !instance.Spec.Symbolize {
+		return
+	}
+	symbols, err := collector.InspectSymbols(data)
+	if err != nil {
+		r.Log.V(1).Info("Unable to inspect the symbols of the profile", "Profile", artifact.Name, "Error", err.Error())
+		return
+	}
+	artifact.Symbolized = &symbols.Symbolized
+	artifact.BuildIDs = symbols.BuildIDs
+}
@@ -0,0 +1,104 @@
+package nodeobservabilityruncontroller
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+func TestWithSymbolizeParam(t *testing.T) {
+	cases := []struct {
+		name         string
+		path         string
+		symbolize    bool
+		expectedPath string
+	}{
+		{
+			name:         "symbolization not requested",
+			path:         pprofPath,
+			expectedPath: pprofPath,
+		},
+		{
+			name:         "path without query",
+			path:         pprofPath,
+			symbolize:    true,
+			expectedPath: pprofPath + "?symbolize=true",
+		},
+		{
+			name:         "path with query",
+			path:         pprofPath + "?profiles=kubelet",
+			symbolize:    true,
+			expectedPath: pprofPath + "?profiles=kubelet&symbolize=true",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.Symbolize = tc.symbolize
+			if got := withSymbolizeParam(tc.path, run); got != tc.expectedPath {
+				t.Errorf("expected path %q, got %q", tc.expectedPath, got)
+			}
+		})
+	}
+}
+
+func TestInspectSymbols(t *testing.T) {
+	// a profile with a single symbolized location in the binary with the "kubelet-build-id" build ID
+	var mapping, line, location, profile []byte
+	mapping = protowire.AppendTag(mapping, 6, protowire.VarintType)
+	mapping = protowire.AppendVarint(mapping, 1)
+	line = protowire.AppendTag(line, 1, protowire.VarintType)
+	line = protowire.AppendVarint(line, 1)
+	location = protowire.AppendTag(location, 4, protowire.BytesType)
+	location = protowire.AppendBytes(location, line)
+	profile = protowire.AppendTag(profile, 3, protowire.BytesType)
+	profile = protowire.AppendBytes(profile, mapping)
+	profile = protowire.AppendTag(profile, 4, protowire.BytesType)
+	profile = protowire.AppendBytes(profile, location)
+	for _, s := range []string{"", "kubelet-build-id"} {
+		profile = protowire.AppendTag(profile, 6, protowire.BytesType)
+		profile = protowire.AppendString(profile, s)
+	}
+	symbolized := true
+
+	cases := []struct {
+		name             string
+		symbolize        bool
+		data             []byte
+		expectedArtifact operatorv1alpha2.ProfileArtifact
+	}{
+		{
+			name:             "symbolization not requested",
+			data:             profile,
+			expectedArtifact: operatorv1alpha2.ProfileArtifact{Name: "kubelet.pprof"},
+		},
+		{
+			name:             "symbolized profile",
+			symbolize:        true,
+			data:             profile,
+			expectedArtifact: operatorv1alpha2.ProfileArtifact{Name: "kubelet.pprof", Symbolized: &symbolized, BuildIDs: []string{"kubelet-build-id"}},
+		},
+		{
+			name:             "invalid profile",
+			symbolize:        true,
+			data:             []byte("pong\n"),
+			expectedArtifact: operatorv1alpha2.ProfileArtifact{Name: "kubelet.pprof"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NodeObservabilityRunReconciler{Log: zap.New(zap.UseDevMode(true))}
+			run := testNodeObservabilityRun()
+			run.Spec.Symbolize = tc.symbolize
+			artifact := operatorv1alpha2.ProfileArtifact{Name: "kubelet.pprof"}
+			r.inspectSymbols(run, &artifact, tc.data)
+			if !reflect.DeepEqual(artifact, tc.expectedArtifact) {
+				t.Errorf("expected artifact %+v, got %+v", tc.expectedArtifact, artifact)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+var (
+	// transport verifies the serving certificates of the agents with the service CA bundle,
+	// it's set once the bundle is available
+	transport http.RoundTripper
+	// insecureTransport skips the verification of the serving certificates of the agents,
+	// it's only used by the runs which request it for debugging
+	insecureTransport http.RoundTripper
+	// bundleTransports verify the serving certificates of the agents with the service CA bundles
+	// of the config maps referenced by the runs, keyed by config map
+	bundleTransports     = map[types.NamespacedName]bundleTransport{}
+	bundleTransportsLock sync.Mutex
+)
+
+// serviceCABundleKey is the key of the service CA bundle
+// in the config map referenced by the run
+const serviceCABundleKey = "service-ca.crt"
+
+// bundleTransport is a transport verifying the agents with the given service CA bundle
+type bundleTransport struct {
+	bundle    string
+	transport http.RoundTripper
+}
+
+// agentTransport returns the transport used to contact the agents of the run.
+// The serving certificates of the agents are verified with the service CA bundle,
+// the one of the config map referenced by the run if any, unless the run skips the verification.
+// An error is returned if the bundle is not available yet.
+func (r *NodeObservabilityRunReconciler) agentTransport(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (http.RoundTripper, error) {
+	if instance.Spec.InsecureSkipTLSVerify {
+		if insecureTransport == nil {
+			t, err := r.newAgentTransport(nil)
+			if err != nil {
+				return nil, err
+			}
+			insecureTransport = t
+		}
+		return insecureTransport, nil
+	}
+
+	if ref := instance.Spec.ServiceCABundleRef; ref != nil {
+		return r.bundleAgentTransport(ctx, types.NamespacedName{Name: ref.Name, Namespace: instance.Namespace})
+	}
+
+	if transport == nil {
+		pool, err := readCACert(r.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		t, err := r.newAgentTransport(pool)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+	return transport, nil
+}
+
+// bundleAgentTransport returns the transport verifying the agents with the service CA bundle
+// of the given config map, the transport is reused as long as the bundle doesn't change.
+func (r *NodeObservabilityRunReconciler) bundleAgentTransport(ctx context.Context, name types.NamespacedName) (http.RoundTripper, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, name, cm); err != nil {
+		return nil, fmt.Errorf("failed to get service CA bundle config map %q: %w", name.Name, err)
+	}
+	bundle := cm.Data[serviceCABundleKey]
+
+	bundleTransportsLock.Lock()
+	defer bundleTransportsLock.Unlock()
+	if cached, found := bundleTransports[name]; found && cached.bundle == bundle {
+		return cached.transport, nil
+	}
+	pool, err := parseCACert([]byte(bundle), fmt.Sprintf("%s key of config map %s", serviceCABundleKey, name.Name))
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.newAgentTransport(pool)
+	if err != nil {
+		return nil, err
+	}
+	bundleTransports[name] = bundleTransport{bundle: bundle, transport: t}
+	return t, nil
+}
+
+// newAgentTransport returns a transport verifying the agents with the given CA pool,
+// the verification is skipped if no pool is given.
+// The client certificate is presented to the agents if configured.
+func (r *NodeObservabilityRunReconciler) newAgentTransport(pool *x509.CertPool) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// the connections are kept alive during the long CPU profiles, the requests are bounded by the client timeout
+	t.DialContext = (&net.Dialer{Timeout: agentDialTimeout, KeepAlive: agentKeepAlive}).DialContext
+	t.TLSClientConfig = &tls.Config{
+		RootCAs:                  pool,
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+		// #nosec G402: only skipped when requested in the run for debugging
+		InsecureSkipVerify: pool == nil,
+	}
+
+	if r.ClientCertFile == "" && r.ClientKeyFile == "" {
+		return t, nil
+	}
+	if r.ClientCertFile == "" || r.ClientKeyFile == "" {
+		return nil, fmt.Errorf("both the client certificate and key must be set to authenticate to the agents")
+	}
+	cert, err := tls.LoadX509KeyPair(r.ClientCertFile, r.ClientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the client certificate %q: %w", r.ClientCertFile, err)
+	}
+	t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return t, nil
+}
+
+// readCACert reads the service CA bundle verifying the serving certificates of the agents.
+func readCACert(caCertFile string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("service CA bundle %q is not available yet: %w", caCertFile, err)
+	}
+	return parseCACert(content, caCertFile)
+}
+
+// parseCACert parses the given service CA bundle, the source describes where it comes from.
+func parseCACert(content []byte, source string) (*x509.CertPool, error) {
+	if len(content) == 0 {
+		return nil, fmt.Errorf("service CA bundle %q is empty", source)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no certificate found in the service CA bundle %q", source)
+	}
+	return pool, nil
+}
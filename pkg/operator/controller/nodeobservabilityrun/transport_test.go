@@ -0,0 +1,206 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestAgentTransport(t *testing.T) {
+	emptyCA := filepath.Join(t.TempDir(), "empty.crt")
+	if err := os.WriteFile(emptyCA, nil, 0600); err != nil {
+		t.Fatalf("failed to write the empty CA bundle: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		// trusted is true if the service CA bundle holds the certificate of the agent
+		trusted bool
+		// requireClientCert is true if the agent requires a client certificate
+		requireClientCert bool
+		caCertFile        string
+		// bundleRef is the config map with the service CA bundle referenced by the run,
+		// it holds the certificate of the agent if bundleExists
+		bundleRef      string
+		bundleExists   bool
+		clientCertFile string
+		clientKeyFile  string
+		insecure       bool
+		// transportErr is the expected error when building the transport
+		transportErr string
+		// notFound is true if the transport error is expected to be a not found one
+		notFound bool
+		// requestFails is true if the request to the agent is expected to fail
+		requestFails bool
+	}{
+		{
+			name:    "agent verified with the service CA",
+			trusted: true,
+		},
+		{
+			name:         "agent not signed by the service CA",
+			caCertFile:   CAPath,
+			requestFails: true,
+		},
+		{
+			name:         "service CA bundle not available yet",
+			caCertFile:   "/nonexistent/service-ca.crt",
+			transportErr: "is not available yet",
+		},
+		{
+			name:         "empty service CA bundle",
+			caCertFile:   emptyCA,
+			transportErr: "is empty",
+		},
+		{
+			name:         "no certificate in the service CA bundle",
+			caCertFile:   keyPath,
+			transportErr: "no certificate found",
+		},
+		{
+			name:         "agent verified with the referenced service CA bundle",
+			caCertFile:   "/nonexistent/service-ca.crt",
+			bundleRef:    "custom-service-ca",
+			bundleExists: true,
+		},
+		{
+			name:         "referenced service CA bundle not found",
+			trusted:      true,
+			bundleRef:    "custom-service-ca",
+			transportErr: `failed to get service CA bundle config map "custom-service-ca"`,
+			notFound:     true,
+		},
+		{
+			name:       "verification skipped",
+			caCertFile: "/nonexistent/service-ca.crt",
+			insecure:   true,
+		},
+		{
+			name:              "client certificate presented",
+			trusted:           true,
+			requireClientCert: true,
+			clientCertFile:    certPath,
+			clientKeyFile:     keyPath,
+		},
+		{
+			name:              "no client certificate presented",
+			trusted:           true,
+			requireClientCert: true,
+			requestFails:      true,
+		},
+		{
+			name:           "client key not set",
+			trusted:        true,
+			clientCertFile: certPath,
+			transportErr:   "both the client certificate and key must be set",
+		},
+		{
+			name:           "client certificate not available",
+			trusted:        true,
+			clientCertFile: "/nonexistent/tls.crt",
+			clientKeyFile:  "/nonexistent/tls.key",
+			transportErr:   "failed to load the client certificate",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(pong))
+			server.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+			if tc.requireClientCert {
+				server.TLS.ClientAuth = tls.RequireAnyClientCert
+			}
+			server.StartTLS()
+			defer server.Close()
+
+			caCertFile := tc.caCertFile
+			if tc.trusted {
+				// the certificate of the test server is self signed
+				caCertFile = filepath.Join(t.TempDir(), "service-ca.crt")
+				caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+				if err := os.WriteFile(caCertFile, caCert, 0600); err != nil {
+					t.Fatalf("failed to write the CA bundle: %v", err)
+				}
+			}
+
+			defaultTransport, defaultInsecureTransport := transport, insecureTransport
+			transport, insecureTransport = nil, nil
+			defer func() { transport, insecureTransport = defaultTransport, defaultInsecureTransport }()
+
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).Build()
+			if tc.bundleExists {
+				caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+				cm := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: tc.bundleRef, Namespace: namespace},
+					Data:       map[string]string{serviceCABundleKey: string(caCert)},
+				}
+				if err := cl.Create(context.Background(), cm); err != nil {
+					t.Fatalf("failed to create the CA bundle config map: %v", err)
+				}
+			}
+
+			r := &NodeObservabilityRunReconciler{
+				Client:         cl,
+				CACertFile:     caCertFile,
+				ClientCertFile: tc.clientCertFile,
+				ClientKeyFile:  tc.clientKeyFile,
+			}
+			run := testNodeObservabilityRun()
+			run.Spec.InsecureSkipTLSVerify = tc.insecure
+			if tc.bundleRef != "" {
+				run.Spec.ServiceCABundleRef = &corev1.LocalObjectReference{Name: tc.bundleRef}
+			}
+
+			tr, err := r.agentTransport(context.Background(), run)
+			if tc.transportErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.transportErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.transportErr, err)
+				}
+				if tc.notFound != errors.IsNotFound(err) {
+					t.Fatalf("expected not found error %t, got %v", tc.notFound, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = r.httpGet(context.Background(), tr, time.Minute, server.URL, nil)
+			if tc.requestFails && err == nil {
+				t.Fatalf("expected the request to the agent to fail")
+			}
+			if !tc.requestFails && err != nil {
+				t.Fatalf("unexpected error from the agent: %v", err)
+			}
+		})
+	}
+}
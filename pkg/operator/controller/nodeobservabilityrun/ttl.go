@@ -0,0 +1,195 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/collector"
+)
+
+const purgePodNameSuffix = "-purge"
+
+// purgePodName returns the name of the pod deleting the expired profiles of the run from the claim
+func purgePodName(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) string {
+	return instance.Name + purgePodNameSuffix
+}
+
+// artifactsExpiry returns the time the stored profiles of the finished run expire,
+// false if they never expire or were already deleted.
+func artifactsExpiry(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (time.Time, bool) {
+	if instance.Spec.ArtifactTTL.Duration <= 0 || instance.Spec.StorageBackend == nil || instance.Status.ArtifactsExpired || !finished(instance) {
+		return time.Time{}, false
+	}
+	return instance.Status.FinishedTimestamp.Add(instance.Spec.ArtifactTTL.Duration), true
+}
+
+// expireArtifacts deletes the stored profiles of the finished run once its artifact TTL elapsed
+// and removes them from the status. The run is requeued until the profiles expire,
+// the deletion is retried until all of them are deleted.
+func (r *NodeObservabilityRunReconciler) expireArtifacts(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun) (ctrl.Result, error) {
+	expiry, expires := artifactsExpiry(instance)
+	if !expires {
+		return ctrl.Result{}, nil
+	}
+	if wait := time.Until(expiry); wait > 0 {
+		r.Log.V(1).Info("Profiles kept until the artifact TTL elapses", "Expiry", expiry)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	keys, err := expiredArtifactKeys(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	deleted := true
+	switch instance.Spec.StorageBackend.Type {
+	case nodeobservabilityv1alpha2.PVCStorageBackendType:
+		deleted, err = r.purgeClaim(ctx, instance, keys)
+	default:
+		err = r.deleteObjects(ctx, instance, keys)
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to delete the expired profiles: %w", err)
+	}
+	if !deleted {
+		return ctrl.Result{RequeueAfter: pollingPeriod}, nil
+	}
+
+	clearArtifacts(instance)
+	instance.Status.ArtifactsExpired = true
+	if err := r.updateStatus(ctx, instance); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+	}
+	msg := fmt.Sprintf("Deleted the %d expired profile(s) from the storage backend", len(keys))
+	r.EventRecorder.Event(instance, corev1.EventTypeNormal, nodeobservabilityv1alpha2.ReasonArtifactsExpired, msg)
+	return ctrl.Result{}, nil
+}
+
+// expiredArtifactKeys returns the keys of the profiles of all the nodes of the run in the storage backend,
+// the failed nodes are included as some of their profiles may have been stored.
+func expiredArtifactKeys(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) ([]string, error) {
+	tmpl, err := nodeobservabilityv1alpha2.ParseArtifactKeyTemplate(instance.Spec.ArtifactKeyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the artifact key template: %w", err)
+	}
+	var keys []string
+	agents := append(append([]nodeobservabilityv1alpha2.AgentNode{}, instance.Status.Agents...), instance.Status.FailedAgents...)
+	for _, agent := range agents {
+		for _, artifact := range runArtifacts(instance) {
+			key, err := artifactKey(tmpl, instance, agent.Name, artifact)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute the key of profile %q of the agent named %q: %w", artifact, agent.Name, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// deleteObjects deletes the objects with the given keys from the S3 bucket,
+// the objects which don't exist are considered as deleted.
+func (r *NodeObservabilityRunReconciler) deleteObjects(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	storage, err := r.newS3Storage(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("failed to configure the storage backend: %w", err)
+	}
+	var errs []error
+	for _, key := range keys {
+		if err := storage.delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// purgeClaim deletes the profiles with the given keys from the persistent volume claim,
+// with a pod which mounts the claim. A failed pod is replaced as the purge can be repeated.
+// Returns true once the profiles are deleted.
+func (r *NodeObservabilityRunReconciler) purgeClaim(ctx context.Context, instance *nodeobservabilityv1alpha2.NodeObservabilityRun, keys []string) (bool, error) {
+	spec := instance.Spec.StorageBackend.PVC
+	if spec == nil || len(keys) == 0 {
+		return true, nil
+	}
+
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Name: purgePodName(instance), Namespace: instance.Namespace}, pod); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get purge pod: %w", err)
+		}
+
+		claim := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, types.NamespacedName{Name: spec.ClaimName, Namespace: instance.Namespace}, claim); err != nil {
+			if errors.IsNotFound(err) {
+				r.Log.V(1).Info("Persistent volume claim deleted with the profiles", "Claim", spec.ClaimName)
+				return true, nil
+			}
+			return false, fmt.Errorf("failed to get persistent volume claim %q: %w", spec.ClaimName, err)
+		}
+		if !claimShareable(claim) {
+			busy, err := r.claimInUse(ctx, instance, spec.ClaimName)
+			if err != nil {
+				return false, err
+			}
+			if busy != "" {
+				r.Log.V(1).Info("Persistent volume claim is used by another collector, waiting", "Claim", spec.ClaimName, "Pod", busy)
+				return false, nil
+			}
+		}
+
+		pullSecrets, err := r.collectorImagePullSecrets(ctx, instance)
+		if err != nil {
+			return false, err
+		}
+		desired := r.desiredPurgePod(instance, pullSecrets, keys)
+		if err := ctrlutil.SetControllerReference(instance, desired, r.Scheme); err != nil {
+			return false, fmt.Errorf("failed to set the controller reference for purge pod: %w", err)
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return false, fmt.Errorf("failed to create purge pod: %w", err)
+		}
+		r.Log.V(1).Info("Created purge pod", "Pod", desired.Name, "Claim", spec.ClaimName)
+		return false, nil
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return true, nil
+	case corev1.PodFailed:
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete failed purge pod %q: %w", pod.Name, err)
+		}
+		return false, fmt.Errorf("purge pod %q failed: %s", pod.Name, pod.Status.Message)
+	}
+	return false, nil
+}
+
+// desiredPurgePod returns the pod which deletes the profiles with the given keys
+// from the persistent volume claim, the collector pod running the purge command.
+func (r *NodeObservabilityRunReconciler) desiredPurgePod(instance *nodeobservabilityv1alpha2.NodeObservabilityRun, pullSecrets []corev1.LocalObjectReference, keys []string) *corev1.Pod {
+	pod := r.desiredCollectorPod(instance, pullSecrets)
+	pod.Name = purgePodName(instance)
+	pod.Spec.Containers[0].Args = append([]string{collector.PurgeCommand, fmt.Sprintf("--dir=%s", collectorOutputPath)}, keys...)
+	return pod
+}
+
+// clearArtifacts removes the expired profiles from the agents of the status
+func clearArtifacts(instance *nodeobservabilityv1alpha2.NodeObservabilityRun) {
+	for _, agents := range [][]nodeobservabilityv1alpha2.AgentNode{instance.Status.Agents, instance.Status.FailedAgents} {
+		for i := range agents {
+			agents[i].Artifacts = nil
+			agents[i].ObjectKeys = nil
+			agents[i].Path = ""
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestReconcileArtifactTTL(t *testing.T) {
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	storedObjects := map[string]string{
+		"/profiles/test/agent/node-1/kubelet.pprof": "pong\n",
+		"/profiles/test/agent/node-1/crio.pprof":    "pong\n",
+		// a failed node may have some of its profiles uploaded
+		"/profiles/test/agent/node-2/kubelet.pprof": "pong\n",
+		"/profiles/test/other/node-1/kubelet.pprof": "pong\n",
+	}
+	uploaded := operatorv1alpha2.AgentNode{
+		Name:       "node-1",
+		Result:     operatorv1alpha2.AgentSucceeded,
+		ObjectKeys: []string{"test/agent/node-1/kubelet.pprof", "test/agent/node-1/crio.pprof"},
+		Artifacts: []operatorv1alpha2.ProfileArtifact{
+			{Name: "kubelet.pprof", URI: "https://s3.example.com/profiles/test/agent/node-1/kubelet.pprof"},
+			{Name: "crio.pprof", URI: "https://s3.example.com/profiles/test/agent/node-1/crio.pprof"},
+		},
+	}
+	failed := operatorv1alpha2.AgentNode{Name: "node-2", Result: operatorv1alpha2.AgentFailed}
+
+	cases := []struct {
+		name            string
+		finishedAgo     time.Duration
+		ttl             time.Duration
+		failKeys        []string
+		errExpected     bool
+		expectedRequeue bool
+		expectedExpired bool
+		expectedObjects []string
+	}{
+		{
+			name:            "profiles kept without TTL",
+			finishedAgo:     time.Hour,
+			expectedObjects: []string{"/profiles/test/agent/node-1/kubelet.pprof", "/profiles/test/agent/node-1/crio.pprof", "/profiles/test/agent/node-2/kubelet.pprof", "/profiles/test/other/node-1/kubelet.pprof"},
+		},
+		{
+			name:            "profiles kept until the TTL elapses",
+			finishedAgo:     time.Minute,
+			ttl:             time.Hour,
+			expectedRequeue: true,
+			expectedObjects: []string{"/profiles/test/agent/node-1/kubelet.pprof", "/profiles/test/agent/node-1/crio.pprof", "/profiles/test/agent/node-2/kubelet.pprof", "/profiles/test/other/node-1/kubelet.pprof"},
+		},
+		{
+			name:            "expired profiles deleted",
+			finishedAgo:     2 * time.Hour,
+			ttl:             time.Hour,
+			expectedExpired: true,
+			expectedObjects: []string{"/profiles/test/other/node-1/kubelet.pprof"},
+		},
+		{
+			name:            "deletion failure",
+			finishedAgo:     2 * time.Hour,
+			ttl:             time.Hour,
+			failKeys:        []string{"node-1/crio.pprof"},
+			errExpected:     true,
+			expectedObjects: []string{"/profiles/test/agent/node-1/crio.pprof", "/profiles/test/other/node-1/kubelet.pprof"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3 := &fakeS3{objects: map[string]string{}, failKeys: tc.failKeys}
+			for k, v := range storedObjects {
+				s3.objects[k] = v
+			}
+			s3Server := httptest.NewTLSServer(s3)
+			defer s3Server.Close()
+
+			started := metav1.NewTime(time.Now().Add(-tc.finishedAgo - time.Minute))
+			finishedAt := metav1.NewTime(time.Now().Add(-tc.finishedAgo))
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp:    &started,
+				FinishedTimestamp: &finishedAt,
+				Agents:            []operatorv1alpha2.AgentNode{uploaded},
+				FailedAgents:      []operatorv1alpha2.AgentNode{failed},
+			})
+			run.Spec.StorageBackend = testS3StorageBackend(s3Server.URL)
+			run.Spec.StorageBackend.S3.CABundleRef = &corev1.LocalObjectReference{Name: testS3CABundleName}
+			run.Spec.ArtifactTTL = metav1.Duration{Duration: tc.ttl}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(testNodeObservability(), run, testS3Secret(), testS3CABundle(s3Server)).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+
+			res, err := r.Reconcile(ctx, req)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+			if tc.expectedRequeue != (res.RequeueAfter > 0) {
+				t.Fatalf("expected requeue to be %t, got %v", tc.expectedRequeue, res)
+			}
+
+			var objects []string
+			for _, key := range []string{"/profiles/test/agent/node-1/kubelet.pprof", "/profiles/test/agent/node-1/crio.pprof", "/profiles/test/agent/node-2/kubelet.pprof", "/profiles/test/other/node-1/kubelet.pprof"} {
+				if _, found := s3.objects[key]; found {
+					objects = append(objects, key)
+				}
+			}
+			if !reflect.DeepEqual(objects, tc.expectedObjects) {
+				t.Fatalf("expected objects %v, got %v", tc.expectedObjects, objects)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if got.Status.ArtifactsExpired != tc.expectedExpired {
+				t.Fatalf("expected artifacts expired to be %t, got %t", tc.expectedExpired, got.Status.ArtifactsExpired)
+			}
+			listed := len(got.Status.Agents[0].ObjectKeys) > 0 && len(got.Status.Agents[0].Artifacts) > 0
+			if listed == tc.expectedExpired {
+				t.Fatalf("expected the profiles to be listed: %t, got %v", !tc.expectedExpired, got.Status.Agents[0])
+			}
+		})
+	}
+}
+
+func TestPurgeClaim(t *testing.T) {
+	purgePod := func(phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name + purgePodNameSuffix, Namespace: namespace},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	cases := []struct {
+		name            string
+		existingObjects []runtime.Object
+		done            bool
+		errExpected     bool
+		podExpected     bool
+	}{
+		{
+			name:            "purge pod created",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce)},
+			podExpected:     true,
+		},
+		{
+			name:            "claim used by another collector",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce), testCollectorPod("other", testClaimName, corev1.PodRunning, "")},
+		},
+		{
+			name:            "claim deleted",
+			existingObjects: nil,
+			done:            true,
+		},
+		{
+			name:            "purge in progress",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce), purgePod(corev1.PodRunning)},
+			podExpected:     true,
+		},
+		{
+			name:            "profiles purged",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce), purgePod(corev1.PodSucceeded)},
+			done:            true,
+			podExpected:     true,
+		},
+		{
+			name:            "failed purge pod replaced",
+			existingObjects: []runtime.Object{testClaim(testClaimName, corev1.ReadWriteOnce), purgePod(corev1.PodFailed)},
+			errExpected:     true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := testNodeObservabilityRun()
+			run.Spec.StorageBackend = &operatorv1alpha2.StorageBackend{
+				Type: operatorv1alpha2.PVCStorageBackendType,
+				PVC:  &operatorv1alpha2.PVCStorageBackend{ClaimName: testClaimName},
+			}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(tc.existingObjects...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:                  cl,
+				Scheme:                  test.Scheme,
+				Log:                     zap.New(zap.UseDevMode(true)),
+				URL:                     &url{},
+				AgentName:               name,
+				Namespace:               namespace,
+				CollectorImage:          testCollectorImage,
+				CollectorServiceAccount: name,
+			}
+
+			keys := []string{"test/agent/node-1/kubelet.pprof", "test/agent/node-1/crio.pprof"}
+			done, err := r.purgeClaim(context.Background(), run, keys)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if done != tc.done {
+				t.Fatalf("expected done to be %t, got %t", tc.done, done)
+			}
+
+			pod := &corev1.Pod{}
+			err = cl.Get(context.Background(), types.NamespacedName{Name: purgePodName(run), Namespace: namespace}, pod)
+			if tc.podExpected != (err == nil) {
+				t.Fatalf("expected purge pod to exist: %t, got error %v", tc.podExpected, err)
+			}
+			if tc.name != "purge pod created" {
+				return
+			}
+			expectedArgs := []string{"purge", "--dir=/profiles", "test/agent/node-1/kubelet.pprof", "test/agent/node-1/crio.pprof"}
+			if args := pod.Spec.Containers[0].Args; !reflect.DeepEqual(args, expectedArgs) {
+				t.Fatalf("expected args %v, got %v", expectedArgs, args)
+			}
+			if claim := pod.Spec.Volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != testClaimName {
+				t.Fatalf("expected volume of claim %q, got %v", testClaimName, pod.Spec.Volumes[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package nodeobservabilityruncontroller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nodeobservabilityv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// maxUploadAttempts is the number of uploads of the profiles of a node
+	// before the node is moved to the failed agents
+	maxUploadAttempts = 6
+	// initialUploadRetryDelay is the delay before the first retry of a failed upload,
+	// it doubles with each failed upload up to maxUploadRetryDelay
+	initialUploadRetryDelay = 10 * time.Second
+	maxUploadRetryDelay     = 5 * time.Minute
+)
+
+// uploadError is the failure of the upload of a profile retrieved from the agent.
+type uploadError struct {
+	err error
+}
+
+func (e uploadError) Error() string {
+	return e.err.Error()
+}
+
+func (e uploadError) Unwrap() error {
+	return e.err
+}
+
+// isUploadRetriable returns true if the profiles failed to be uploaded
+// for a reason which is likely to be transient: the storage backend is unreachable,
+// overloaded or failing. The profiles which cannot be retrieved from the agent are not retried.
+func isUploadRetriable(err error) bool {
+	var upErr uploadError
+	if !errors.As(err, &upErr) {
+		return false
+	}
+	var statusErr s3StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch statusErr.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// awaitsUpload returns true if the profiles of the agent which finished the profiling
+// are not uploaded yet.
+func awaitsUpload(agent nodeobservabilityv1alpha2.AgentNode) bool {
+	return agent.Result == nodeobservabilityv1alpha2.AgentPendingUpload ||
+		(agent.Result == nodeobservabilityv1alpha2.AgentSucceeded && agent.Artifacts == nil)
+}
+
+// uploadDue returns true if the upload of the profiles of the agent
+// is not delayed by the backoff of the previous failed upload.
+func uploadDue(agent nodeobservabilityv1alpha2.AgentNode, now time.Time) bool {
+	p := agent.PendingUpload
+	return p == nil || p.NextAttemptTime == nil || !now.Before(p.NextAttemptTime.Time)
+}
+
+// retryUpload records the failed upload of the profiles of the agent,
+// returns false if the agent ran out of attempts.
+func retryUpload(agent *nodeobservabilityv1alpha2.AgentNode, err error, now time.Time) bool {
+	var attempts int32 = 1
+	if agent.PendingUpload != nil {
+		attempts = agent.PendingUpload.Attempts + 1
+	}
+	if attempts >= maxUploadAttempts {
+		return false
+	}
+	next := metav1.NewTime(now.Add(uploadRetryDelay(attempts)))
+	agent.Result = nodeobservabilityv1alpha2.AgentPendingUpload
+	agent.PendingUpload = &nodeobservabilityv1alpha2.PendingUpload{
+		Attempts:        attempts,
+		LastError:       err.Error(),
+		NextAttemptTime: &next,
+	}
+	return true
+}
+
+// uploadRetryDelay returns the delay before the next upload
+// after the given number of failed uploads.
+func uploadRetryDelay(attempts int32) time.Duration {
+	delay := initialUploadRetryDelay
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= maxUploadRetryDelay {
+			return maxUploadRetryDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,212 @@
+package nodeobservabilityruncontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+func TestIsUploadRetriable(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "storage unreachable",
+			err:      uploadError{err: fmt.Errorf("dial tcp: connection refused")},
+			expected: true,
+		},
+		{
+			name:     "storage unavailable",
+			err:      fmt.Errorf("wrapped: %w", uploadError{err: s3StatusError{op: "upload", code: http.StatusServiceUnavailable}}),
+			expected: true,
+		},
+		{
+			name:     "storage throttling",
+			err:      uploadError{err: s3StatusError{op: "upload", code: http.StatusTooManyRequests}},
+			expected: true,
+		},
+		{
+			name: "access denied",
+			err:  uploadError{err: s3StatusError{op: "upload", code: http.StatusForbidden}},
+		},
+		{
+			name: "profile not retrieved from the agent",
+			err:  errors.New("failed to retrieve profile \"kubelet.pprof\": connection refused"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isUploadRetriable(tc.err); got != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestUploadRetryDelay(t *testing.T) {
+	for attempts, expected := range map[int32]time.Duration{
+		1: initialUploadRetryDelay,
+		2: 2 * initialUploadRetryDelay,
+		3: 4 * initialUploadRetryDelay,
+		5: 16 * initialUploadRetryDelay,
+		6: maxUploadRetryDelay,
+		9: maxUploadRetryDelay,
+	} {
+		if got := uploadRetryDelay(attempts); got != expected {
+			t.Errorf("expected a delay of %s after %d attempts, got %s", expected, attempts, got)
+		}
+	}
+}
+
+func TestReconcileUploadRetry(t *testing.T) {
+	agentServer := httptest.NewTLSServer(http.HandlerFunc(pong))
+	defer agentServer.Close()
+
+	defaultTransport := transport
+	transport = agentServer.Client().Transport
+	defer func() { transport = defaultTransport }()
+
+	now := metav1.Now()
+	past := metav1.NewTime(now.Add(-time.Minute))
+	future := metav1.NewTime(now.Add(time.Hour))
+	ctx := logr.NewContext(context.Background(), zap.New(zap.UseDevMode(true)))
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	withPendingUpload := func(attempts int32, next metav1.Time) operatorv1alpha2.AgentNode {
+		agent := withResult(testAgentNode("node-1", agentServer), operatorv1alpha2.AgentPendingUpload)
+		agent.PendingUpload = &operatorv1alpha2.PendingUpload{Attempts: attempts, LastError: "code 503", NextAttemptTime: &next}
+		return agent
+	}
+
+	cases := []struct {
+		name             string
+		agent            operatorv1alpha2.AgentNode
+		storageDown      bool
+		errExpected      bool
+		expectedFinished bool
+		expectedResult   operatorv1alpha2.AgentResult
+		expectedAttempts int32
+		expectedObjects  int
+	}{
+		{
+			name:             "storage briefly unavailable",
+			agent:            withResult(testAgentNode("node-1", agentServer), operatorv1alpha2.AgentSucceeded),
+			storageDown:      true,
+			expectedResult:   operatorv1alpha2.AgentPendingUpload,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "upload retried once available",
+			agent:            withPendingUpload(2, past),
+			expectedFinished: true,
+			expectedResult:   operatorv1alpha2.AgentSucceeded,
+			expectedObjects:  len(profileArtifacts),
+		},
+		{
+			name:             "upload delayed by the backoff",
+			agent:            withPendingUpload(2, future),
+			expectedResult:   operatorv1alpha2.AgentPendingUpload,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "storage unavailable again",
+			agent:            withPendingUpload(2, past),
+			storageDown:      true,
+			expectedResult:   operatorv1alpha2.AgentPendingUpload,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "upload attempts exhausted",
+			agent:            withPendingUpload(maxUploadAttempts-1, past),
+			storageDown:      true,
+			errExpected:      true,
+			expectedFinished: true,
+			expectedResult:   operatorv1alpha2.AgentFailed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s3 := &fakeS3{objects: map[string]string{}, failCode: http.StatusServiceUnavailable}
+			if tc.storageDown {
+				s3.failKeys = []string{"/"}
+			}
+			s3Server := httptest.NewTLSServer(s3)
+			defer s3Server.Close()
+
+			run := testNodeObservabilityRunWithStatus(operatorv1alpha2.NodeObservabilityRunStatus{
+				StartTimestamp: &now,
+				Agents:         []operatorv1alpha2.AgentNode{tc.agent},
+			})
+			run.Spec.StorageBackend = testS3StorageBackend(s3Server.URL)
+			run.Spec.StorageBackend.S3.CABundleRef = &corev1.LocalObjectReference{Name: testS3CABundleName}
+			objs := []runtime.Object{testNodeObservability(), run, testS3CABundle(s3Server), testS3Secret()}
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(objs...).Build()
+			r := NodeObservabilityRunReconciler{
+				Client:        cl,
+				EventRecorder: record.NewFakeRecorder(10),
+				URL:           &testURL{},
+				AgentName:     name,
+				Namespace:     namespace,
+			}
+			res, err := r.Reconcile(ctx, req)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("reconciler error: %v", err)
+			}
+
+			got := &operatorv1alpha2.NodeObservabilityRun{}
+			if err := cl.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("error while trying to get NodeObservabilityRun: %v", err)
+			}
+			if finished(got) != tc.expectedFinished {
+				t.Fatalf("expected run to be finished: %t", tc.expectedFinished)
+			}
+			if !tc.expectedFinished && res.RequeueAfter == 0 {
+				t.Errorf("expected the run to be requeued")
+			}
+			if len(s3.objects) != tc.expectedObjects {
+				t.Errorf("expected %d uploaded objects, got %d: %v", tc.expectedObjects, len(s3.objects), s3.objects)
+			}
+			agents := append(append([]operatorv1alpha2.AgentNode{}, got.Status.Agents...), got.Status.FailedAgents...)
+			if len(agents) != 1 {
+				t.Fatalf("expected a single agent, got %v", agents)
+			}
+			agent := agents[0]
+			if agent.Result != tc.expectedResult {
+				t.Errorf("expected result %q, got %q", tc.expectedResult, agent.Result)
+			}
+			if tc.expectedAttempts == 0 {
+				if agent.PendingUpload != nil {
+					t.Errorf("expected no pending upload, got %+v", agent.PendingUpload)
+				}
+				return
+			}
+			if agent.PendingUpload == nil || agent.PendingUpload.Attempts != tc.expectedAttempts || agent.PendingUpload.NextAttemptTime == nil {
+				t.Fatalf("expected a pending upload after %d attempts, got %+v", tc.expectedAttempts, agent.PendingUpload)
+			}
+			if agent.Artifacts != nil {
+				t.Errorf("expected no artifact while the upload is pending, got %v", agent.Artifacts)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulecontroller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+)
+
+const (
+	// ScheduledByLabel is set on the runs created on the schedule of a NodeObservability,
+	// its value is the name of the NodeObservability
+	ScheduledByLabel = "nodeobservability.olm.openshift.io/scheduled-by"
+	// scheduledTimeAnnotation is the time for which the run was scheduled
+	scheduledTimeAnnotation = "nodeobservability.olm.openshift.io/scheduled-time"
+	// defaultSuccessfulRunsHistoryLimit is the number of successful scheduled runs
+	// kept when not set in the spec
+	defaultSuccessfulRunsHistoryLimit = 3
+	// requeueDelay is added to the next schedule time to make sure it's passed when requeued
+	requeueDelay = time.Second
+)
+
+// ScheduleReconciler creates the NodeObservabilityRuns on the schedule of NodeObservability
+type ScheduleReconciler struct {
+	client.Client
+
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Clock  clock.PassiveClock
+	// Namespace is the namespace in which the scheduled runs are created
+	Namespace string
+}
+
+//+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities,verbs=get;list;watch
+//+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilities/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=nodeobservability.olm.openshift.io,resources=nodeobservabilityruns,verbs=get;list;watch;create;delete
+
+// Reconcile creates the scheduled NodeObservabilityRuns and deletes the old ones
+func (r *ScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if ctxLog, err := logr.FromContext(ctx); err == nil {
+		r.Log = ctxLog
+	}
+
+	nodeObs := &v1alpha2.NodeObservability{}
+	if err := r.Get(ctx, req.NamespacedName, nodeObs); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get nodeobservability %q: %w", req.NamespacedName, err)
+	}
+	if nodeObs.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	origStatus := nodeObs.Status.DeepCopy()
+
+	if nodeObs.Spec.Schedule == "" {
+		if nodeObs.Status.GetCondition(v1alpha2.Scheduled) != nil {
+			nodeObs.Status.SetCondition(v1alpha2.Scheduled, metav1.ConditionFalse, v1alpha2.ReasonDisabled, "No schedule set")
+			nodeObs.Status.NextScheduleTime = nil
+		}
+		return ctrl.Result{}, r.updateStatus(ctx, nodeObs, origStatus)
+	}
+
+	sched, err := parseSchedule(nodeObs.Spec.Schedule)
+	if err != nil {
+		nodeObs.Status.SetCondition(v1alpha2.Scheduled, metav1.ConditionFalse, v1alpha2.ReasonInvalid, fmt.Sprintf("Invalid schedule %q: %v", nodeObs.Spec.Schedule, err))
+		nodeObs.Status.NextScheduleTime = nil
+		// no need to requeue, the schedule has to be fixed in the spec
+		return ctrl.Result{}, r.updateStatus(ctx, nodeObs, origStatus)
+	}
+
+	runs, err := r.scheduledRuns(ctx, nodeObs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var active, successful []v1alpha2.NodeObservabilityRun
+	for _, run := range runs {
+		switch {
+		case run.Status.FinishedTimestamp == nil:
+			active = append(active, run)
+		case isSuccessful(&run):
+			successful = append(successful, run)
+		}
+	}
+	if err := r.deleteOldRuns(ctx, successful, successfulRunsHistoryLimit(nodeObs)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := r.Clock.Now().UTC()
+	earliest := nodeObs.CreationTimestamp.Time
+	if nodeObs.Status.LastScheduleTime != nil {
+		earliest = nodeObs.Status.LastScheduleTime.Time
+	}
+	if earliest.IsZero() {
+		earliest = now
+	}
+	if scheduledTime := mostRecentScheduleTime(sched, earliest.UTC(), now); !scheduledTime.IsZero() {
+		if err := r.scheduleRun(ctx, nodeObs, scheduledTime, active); err != nil {
+			return ctrl.Result{}, err
+		}
+		lastScheduleTime := metav1.NewTime(scheduledTime)
+		nodeObs.Status.LastScheduleTime = &lastScheduleTime
+	}
+
+	next := sched.next(now)
+	if next.IsZero() {
+		nodeObs.Status.SetCondition(v1alpha2.Scheduled, metav1.ConditionFalse, v1alpha2.ReasonInvalid, fmt.Sprintf("Schedule %q never matches", nodeObs.Spec.Schedule))
+		nodeObs.Status.NextScheduleTime = nil
+		return ctrl.Result{}, r.updateStatus(ctx, nodeObs, origStatus)
+	}
+	nextScheduleTime := metav1.NewTime(next)
+	nodeObs.Status.NextScheduleTime = &nextScheduleTime
+	nodeObs.Status.SetCondition(v1alpha2.Scheduled, metav1.ConditionTrue, v1alpha2.ReasonReady, fmt.Sprintf("Runs are created on schedule %q", nodeObs.Spec.Schedule))
+	if err := r.updateStatus(ctx, nodeObs, origStatus); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Log.V(1).Info("next run scheduled", "time", next)
+	return ctrl.Result{RequeueAfter: next.Sub(now) + requeueDelay}, nil
+}
+
+// scheduleRun creates the run for the given schedule time
+// according to the concurrency policy of NodeObservability.
+func (r *ScheduleReconciler) scheduleRun(ctx context.Context, nodeObs *v1alpha2.NodeObservability, scheduledTime time.Time, active []v1alpha2.NodeObservabilityRun) error {
+	switch nodeObs.Spec.ConcurrencyPolicy {
+	case v1alpha2.ForbidConcurrent:
+		if len(active) > 0 {
+			r.Log.V(1).Info("previous scheduled run not finished, skipping the run", "time", scheduledTime, "active", active[0].Name)
+			return nil
+		}
+	case v1alpha2.ReplaceConcurrent:
+		for i := range active {
+			if err := r.Delete(ctx, &active[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete active nodeobservabilityrun %q: %w", active[i].Name, err)
+			}
+			r.Log.V(1).Info("deleted active scheduled run", "name", active[i].Name)
+		}
+	}
+
+	run := desiredRun(nodeObs, scheduledTime, r.Namespace)
+	if err := controllerutil.SetControllerReference(nodeObs, run, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set the controller reference for nodeobservabilityrun %q: %w", run.Name, err)
+	}
+	if err := r.Create(ctx, run); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to create nodeobservabilityrun %q: %w", run.Name, err)
+	}
+	r.Log.V(1).Info("created scheduled run", "name", run.Name, "time", scheduledTime)
+	return nil
+}
+
+// scheduledRuns returns the runs created on the schedule of the given NodeObservability
+func (r *ScheduleReconciler) scheduledRuns(ctx context.Context, nodeObs *v1alpha2.NodeObservability) ([]v1alpha2.NodeObservabilityRun, error) {
+	runs := &v1alpha2.NodeObservabilityRunList{}
+	if err := r.List(ctx, runs, client.InNamespace(r.Namespace), client.MatchingLabels{ScheduledByLabel: nodeObs.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list scheduled nodeobservabilityruns: %w", err)
+	}
+	return runs.Items, nil
+}
+
+// deleteOldRuns deletes the runs which finished first
+// until the given number of runs remains.
+func (r *ScheduleReconciler) deleteOldRuns(ctx context.Context, runs []v1alpha2.NodeObservabilityRun, limit int32) error {
+	if int32(len(runs)) <= limit {
+		return nil
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Status.FinishedTimestamp.Before(runs[j].Status.FinishedTimestamp)
+	})
+	for i := 0; i < len(runs)-int(limit); i++ {
+		if err := r.Delete(ctx, &runs[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete old nodeobservabilityrun %q: %w", runs[i].Name, err)
+		}
+		r.Log.V(1).Info("deleted old scheduled run", "name", runs[i].Name)
+	}
+	return nil
+}
+
+func (r *ScheduleReconciler) updateStatus(ctx context.Context, nodeObs *v1alpha2.NodeObservability, origStatus *v1alpha2.NodeObservabilityStatus) error {
+	if equality.Semantic.DeepEqual(origStatus, &nodeObs.Status) {
+		return nil
+	}
+	if err := r.Status().Update(ctx, nodeObs); err != nil {
+		return fmt.Errorf("failed to update nodeobservability status: %w", err)
+	}
+	return nil
+}
+
+// desiredRun returns the run scheduled at the given time,
+// its name is derived from the time to avoid duplicates.
+func desiredRun(nodeObs *v1alpha2.NodeObservability, scheduledTime time.Time, ns string) *v1alpha2.NodeObservabilityRun {
+	return &v1alpha2.NodeObservabilityRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", nodeObs.Name, scheduledTime.Unix()/60),
+			Namespace: ns,
+			Labels: map[string]string{
+				ScheduledByLabel: nodeObs.Name,
+			},
+			Annotations: map[string]string{
+				scheduledTimeAnnotation: scheduledTime.Format(time.RFC3339),
+			},
+		},
+		Spec: v1alpha2.NodeObservabilityRunSpec{
+			NodeObservabilityRef: &v1alpha2.NodeObservabilityRef{
+				Name: nodeObs.Name,
+			},
+		},
+	}
+}
+
+// mostRecentScheduleTime returns the latest time matching the schedule
+// after earliest and not after now, the zero time if there is none.
+// The older missed times are skipped.
+func mostRecentScheduleTime(sched *cronSchedule, earliest, now time.Time) time.Time {
+	var last time.Time
+	for t := sched.next(earliest); !t.IsZero() && !t.After(now); t = sched.next(t) {
+		last = t
+	}
+	return last
+}
+
+// successfulRunsHistoryLimit returns the number of successful scheduled runs to keep,
+// falls back to the default one if not set in the spec.
+func successfulRunsHistoryLimit(nodeObs *v1alpha2.NodeObservability) int32 {
+	if nodeObs.Spec.SuccessfulRunsHistoryLimit != nil {
+		return *nodeObs.Spec.SuccessfulRunsHistoryLimit
+	}
+	return defaultSuccessfulRunsHistoryLimit
+}
+
+func isSuccessful(run *v1alpha2.NodeObservabilityRun) bool {
+	cond := run.Status.GetCondition(v1alpha2.DebugFinished)
+	return cond != nil && cond.Status == metav1.ConditionTrue
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = clock.RealClock{}
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nodeobservability-schedule").
+		For(&v1alpha2.NodeObservability{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&v1alpha2.NodeObservabilityRun{}).
+		Complete(r)
+}
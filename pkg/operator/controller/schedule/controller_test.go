@@ -0,0 +1,273 @@
+package schedulecontroller
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	v1alpha2 "github.com/openshift/node-observability-operator/api/v1alpha2"
+	"github.com/openshift/node-observability-operator/pkg/operator/controller/utils/test"
+)
+
+const (
+	nodeObsName = "cluster"
+	namespace   = "test"
+)
+
+func TestReconcile(t *testing.T) {
+	created := time.Date(2022, time.December, 30, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2022, time.December, 30, 10, 15, 30, 0, time.UTC)
+	// the most recent schedule time of "0 * * * *" before now
+	scheduled := time.Date(2022, time.December, 30, 10, 0, 0, 0, time.UTC)
+	scheduledRunName := nodeObsName + "-27873240"
+
+	cases := []struct {
+		name                 string
+		nodeObs              *v1alpha2.NodeObservability
+		existingRuns         []runtime.Object
+		expectedRuns         []string
+		expectedCondition    metav1.ConditionStatus
+		expectedReason       string
+		expectedRequeue      time.Duration
+		expectedLastSchedule *time.Time
+	}{
+		{
+			name:              "no schedule",
+			nodeObs:           testNodeObservability(""),
+			expectedCondition: "",
+		},
+		{
+			name:              "invalid schedule",
+			nodeObs:           testNodeObservability("0 25 * * *"),
+			expectedCondition: metav1.ConditionFalse,
+			expectedReason:    v1alpha2.ReasonInvalid,
+		},
+		{
+			name:                 "run created",
+			nodeObs:              testNodeObservability("0 * * * *"),
+			expectedRuns:         []string{scheduledRunName},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:    "run already created",
+			nodeObs: withLastScheduleTime(testNodeObservability("0 * * * *"), scheduled),
+			existingRuns: []runtime.Object{
+				testRun(scheduledRunName, nil),
+			},
+			expectedRuns:         []string{scheduledRunName},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:    "concurrent run allowed",
+			nodeObs: testNodeObservability("0 * * * *"),
+			existingRuns: []runtime.Object{
+				testRun("active", nil),
+			},
+			expectedRuns:         []string{"active", scheduledRunName},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:    "concurrent run forbidden",
+			nodeObs: withConcurrencyPolicy(testNodeObservability("0 * * * *"), v1alpha2.ForbidConcurrent),
+			existingRuns: []runtime.Object{
+				testRun("active", nil),
+			},
+			expectedRuns:         []string{"active"},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:    "concurrent run replaced",
+			nodeObs: withConcurrencyPolicy(testNodeObservability("0 * * * *"), v1alpha2.ReplaceConcurrent),
+			existingRuns: []runtime.Object{
+				testRun("active", nil),
+			},
+			expectedRuns:         []string{scheduledRunName},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:    "finished runs do not prevent a new run",
+			nodeObs: withConcurrencyPolicy(testNodeObservability("0 * * * *"), v1alpha2.ForbidConcurrent),
+			existingRuns: []runtime.Object{
+				testRun("failed", &created),
+			},
+			expectedRuns:         []string{scheduledRunName, "failed"},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name: "old successful runs deleted",
+			nodeObs: func() *v1alpha2.NodeObservability {
+				nodeObs := withLastScheduleTime(testNodeObservability("0 * * * *"), scheduled)
+				nodeObs.Spec.SuccessfulRunsHistoryLimit = pointer.Int32(1)
+				return nodeObs
+			}(),
+			existingRuns: []runtime.Object{
+				withSuccess(testRun("first", &created)),
+				withSuccess(testRun("second", &scheduled)),
+				testRun("failed", &created),
+			},
+			expectedRuns:         []string{"failed", "second"},
+			expectedCondition:    metav1.ConditionTrue,
+			expectedReason:       v1alpha2.ReasonReady,
+			expectedRequeue:      44*time.Minute + 30*time.Second + requeueDelay,
+			expectedLastSchedule: &scheduled,
+		},
+		{
+			name:              "schedule never matching",
+			nodeObs:           testNodeObservability("0 0 30 feb *"),
+			expectedCondition: metav1.ConditionFalse,
+			expectedReason:    v1alpha2.ReasonInvalid,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.nodeObs.CreationTimestamp = metav1.NewTime(created)
+			cl := fake.NewClientBuilder().WithScheme(test.Scheme).WithRuntimeObjects(append(tc.existingRuns, tc.nodeObs)...).Build()
+			r := &ScheduleReconciler{
+				Client:    cl,
+				Scheme:    test.Scheme,
+				Log:       zap.New(zap.UseDevMode(true)),
+				Clock:     clocktesting.NewFakePassiveClock(now),
+				Namespace: namespace,
+			}
+
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeObsName}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.RequeueAfter != tc.expectedRequeue {
+				t.Errorf("expected requeue after %v, got %v", tc.expectedRequeue, res.RequeueAfter)
+			}
+
+			runs := &v1alpha2.NodeObservabilityRunList{}
+			if err := cl.List(context.Background(), runs, client.InNamespace(namespace)); err != nil {
+				t.Fatalf("failed to list runs: %v", err)
+			}
+			var names []string
+			for _, run := range runs.Items {
+				names = append(names, run.Name)
+			}
+			sort.Strings(names)
+			if len(names) != len(tc.expectedRuns) {
+				t.Fatalf("expected runs %v, got %v", tc.expectedRuns, names)
+			}
+			for i := range names {
+				if names[i] != tc.expectedRuns[i] {
+					t.Fatalf("expected runs %v, got %v", tc.expectedRuns, names)
+				}
+			}
+
+			nodeObs := &v1alpha2.NodeObservability{}
+			if err := cl.Get(context.Background(), types.NamespacedName{Name: nodeObsName}, nodeObs); err != nil {
+				t.Fatalf("failed to get nodeobservability: %v", err)
+			}
+			cond := nodeObs.Status.GetCondition(v1alpha2.Scheduled)
+			switch {
+			case tc.expectedCondition == "" && cond != nil:
+				t.Fatalf("expected no scheduled condition, got %v", cond)
+			case tc.expectedCondition != "" && cond == nil:
+				t.Fatalf("expected scheduled condition %s, got none", tc.expectedCondition)
+			case cond != nil && (cond.Status != tc.expectedCondition || cond.Reason != tc.expectedReason):
+				t.Fatalf("expected scheduled condition %s/%s, got %s/%s", tc.expectedCondition, tc.expectedReason, cond.Status, cond.Reason)
+			}
+			if tc.expectedLastSchedule == nil {
+				if nodeObs.Status.LastScheduleTime != nil {
+					t.Errorf("expected no last schedule time, got %v", nodeObs.Status.LastScheduleTime)
+				}
+			} else if nodeObs.Status.LastScheduleTime == nil || !nodeObs.Status.LastScheduleTime.Time.Equal(*tc.expectedLastSchedule) {
+				t.Errorf("expected last schedule time %v, got %v", *tc.expectedLastSchedule, nodeObs.Status.LastScheduleTime)
+			}
+		})
+	}
+}
+
+func TestDesiredRun(t *testing.T) {
+	scheduled := time.Date(2022, time.December, 30, 10, 0, 0, 0, time.UTC)
+	run := desiredRun(testNodeObservability("0 * * * *"), scheduled, namespace)
+
+	if run.Name != nodeObsName+"-27873240" {
+		t.Errorf("unexpected run name %q", run.Name)
+	}
+	if run.Namespace != namespace {
+		t.Errorf("expected namespace %q, got %q", namespace, run.Namespace)
+	}
+	if run.Labels[ScheduledByLabel] != nodeObsName {
+		t.Errorf("expected label %s=%s, got %v", ScheduledByLabel, nodeObsName, run.Labels)
+	}
+	if run.Annotations[scheduledTimeAnnotation] != "2022-12-30T10:00:00Z" {
+		t.Errorf("unexpected scheduled time annotation %v", run.Annotations)
+	}
+	if run.Spec.NodeObservabilityRef == nil || run.Spec.NodeObservabilityRef.Name != nodeObsName {
+		t.Errorf("expected reference to %q, got %v", nodeObsName, run.Spec.NodeObservabilityRef)
+	}
+}
+
+func testNodeObservability(schedule string) *v1alpha2.NodeObservability {
+	return &v1alpha2.NodeObservability{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeObsName},
+		Spec: v1alpha2.NodeObservabilitySpec{
+			Schedule: schedule,
+		},
+	}
+}
+
+func withLastScheduleTime(nodeObs *v1alpha2.NodeObservability, t time.Time) *v1alpha2.NodeObservability {
+	lastScheduleTime := metav1.NewTime(t)
+	nodeObs.Status.LastScheduleTime = &lastScheduleTime
+	return nodeObs
+}
+
+func withConcurrencyPolicy(nodeObs *v1alpha2.NodeObservability, policy v1alpha2.ConcurrencyPolicy) *v1alpha2.NodeObservability {
+	nodeObs.Spec.ConcurrencyPolicy = policy
+	return nodeObs
+}
+
+// testRun returns a run scheduled by the test NodeObservability,
+// the run is active if not finished.
+func testRun(name string, finished *time.Time) *v1alpha2.NodeObservabilityRun {
+	run := &v1alpha2.NodeObservabilityRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{ScheduledByLabel: nodeObsName},
+		},
+	}
+	if finished != nil {
+		finishedTimestamp := metav1.NewTime(*finished)
+		run.Status.FinishedTimestamp = &finishedTimestamp
+	}
+	return run
+}
+
+func withSuccess(run *v1alpha2.NodeObservabilityRun) *v1alpha2.NodeObservabilityRun {
+	run.Status.SetCondition(v1alpha2.DebugFinished, metav1.ConditionTrue, v1alpha2.ReasonFinished, "")
+	return run
+}
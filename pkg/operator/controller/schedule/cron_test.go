@@ -0,0 +1,110 @@
+package schedulecontroller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	cases := []struct {
+		name        string
+		schedule    string
+		errExpected bool
+	}{
+		{name: "every minute", schedule: "* * * * *"},
+		{name: "lists ranges and steps", schedule: "0,30 8-18/2 1-15 */3 1-5"},
+		{name: "named months and days", schedule: "0 0 * jan-mar MON,fri"},
+		{name: "sunday as 7", schedule: "0 0 * * 7"},
+		{name: "macro", schedule: "@daily"},
+		{name: "empty", schedule: "", errExpected: true},
+		{name: "too many fields", schedule: "* * * * * *", errExpected: true},
+		{name: "minute out of range", schedule: "60 * * * *", errExpected: true},
+		{name: "day of month out of range", schedule: "0 0 0 * *", errExpected: true},
+		{name: "reversed range", schedule: "0 10-8 * * *", errExpected: true},
+		{name: "invalid step", schedule: "*/0 * * * *", errExpected: true},
+		{name: "unknown name", schedule: "0 0 * foo *", errExpected: true},
+		{name: "unknown macro", schedule: "@every 5m", errExpected: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseSchedule(tc.schedule)
+			if tc.errExpected && err == nil {
+				t.Fatalf("expected error but got none")
+			}
+			if !tc.errExpected && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Friday
+	from := time.Date(2022, time.December, 30, 10, 15, 30, 0, time.UTC)
+	cases := []struct {
+		name     string
+		schedule string
+		expected time.Time
+	}{
+		{
+			name:     "every minute",
+			schedule: "* * * * *",
+			expected: time.Date(2022, time.December, 30, 10, 16, 0, 0, time.UTC),
+		},
+		{
+			name:     "every 20 minutes",
+			schedule: "*/20 * * * *",
+			expected: time.Date(2022, time.December, 30, 10, 20, 0, 0, time.UTC),
+		},
+		{
+			name:     "hourly",
+			schedule: "@hourly",
+			expected: time.Date(2022, time.December, 30, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "next year",
+			schedule: "@yearly",
+			expected: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "week days only",
+			schedule: "0 9 * * mon-fri",
+			expected: time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "sunday as 7",
+			schedule: "0 0 * * 7",
+			expected: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month or day of week",
+			schedule: "0 0 15 * sat",
+			expected: time.Date(2022, time.December, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month skipping short months",
+			schedule: "0 0 31 feb-apr *",
+			expected: time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap day",
+			schedule: "0 0 29 feb *",
+			expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "never matching",
+			schedule: "0 0 30 feb *",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sched, err := parseSchedule(tc.schedule)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if next := sched.next(from); !next.Equal(tc.expected) {
+				t.Fatalf("expected next time %v, got %v", tc.expected, next)
+			}
+		})
+	}
+}
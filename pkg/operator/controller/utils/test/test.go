@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
 	securityv1 "github.com/openshift/api/security/v1"
 	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	appsv1 "k8s.io/api/apps/v1"
@@ -57,6 +58,9 @@ func init() {
 	if err := mcv1.AddToScheme(Scheme); err != nil {
 		panic(err)
 	}
+	if err := configv1.AddToScheme(Scheme); err != nil {
+		panic(err)
+	}
 }
 
 // NewEvent returns an event instance created from the controller runtime's watch event.
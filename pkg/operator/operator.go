@@ -18,11 +18,11 @@ package operator
 
 import (
 	"context"
-	"crypto/x509"
 	"fmt"
 	"os"
 
 	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -38,6 +38,7 @@ import (
 	machineconfigcontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/machineconfig"
 	nodeobservabilitycontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/nodeobservability"
 	nodeobservabilityrun "github.com/openshift/node-observability-operator/pkg/operator/controller/nodeobservabilityrun"
+	schedulecontroller "github.com/openshift/node-observability-operator/pkg/operator/controller/schedule"
 )
 
 // Operator hold the manager resource.
@@ -52,10 +53,6 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read serviceaccount token: %w", err)
 	}
-	ca, err := readCACert(opCfg.CaCertFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA cert: %w", err)
-	}
 
 	config := ctrl.GetConfigOrDie()
 	// Use a non-caching client everywhere. The default split client does not
@@ -70,7 +67,7 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 		return client.New(config, options)
 	}
 
-	mgr, err := ctrl.NewManager(config, ctrl.Options{
+	mgrOpts := ctrl.Options{
 		Logger:                 ctrl.Log.WithName("operator manager"),
 		Scheme:                 GetOperatorScheme(),
 		MetricsBindAddress:     opCfg.MetricsBindAddress,
@@ -80,7 +77,17 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 		LeaderElectionID:       "94c735b6.olm.openshift.io",
 		Namespace:              opCfg.OperatorNamespace,
 		NewClient:              newNoCacheClientFunc,
-	})
+	}
+	// the operands can be deployed in a namespace other than the operator's,
+	// the manager watches both of them then
+	operandNamespace := opCfg.OperatorNamespace
+	if opCfg.OperandNamespace != "" && opCfg.OperandNamespace != opCfg.OperatorNamespace {
+		operandNamespace = opCfg.OperandNamespace
+		mgrOpts.Namespace = ""
+		mgrOpts.NewCache = cache.MultiNamespacedCacheBuilder([]string{opCfg.OperatorNamespace, operandNamespace})
+	}
+
+	mgr, err := ctrl.NewManager(config, mgrOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create manager: %w", err)
 	}
@@ -113,19 +120,27 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 	// Create and register the CA config map controller with the operator manager.
 	if _, err := caconfigmapcontroller.New(mgr, caconfigmapcontroller.Config{
 		SourceNamespace: opctrl.SourceKubeletCAConfigMapNamespace,
-		TargetNamespace: opCfg.OperatorNamespace,
+		TargetNamespace: operandNamespace,
 		CAConfigMapName: opctrl.KubeletCAConfigMapName,
 		Cluster:         cluster,
 	}); err != nil {
 		return nil, fmt.Errorf("failed to create CA config map controller controller: %w", err)
 	}
 
+	var agentPriorityClassName string
+	if opCfg.EnableAgentNodeCriticalPriority {
+		agentPriorityClassName = nodeobservabilitycontroller.NodeCriticalPriorityClassName
+	}
 	if err := (&nodeobservabilitycontroller.NodeObservabilityReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		Log:        ctrl.Log.WithName("controller.nodeobservability"),
-		Namespace:  opCfg.OperatorNamespace,
-		AgentImage: opCfg.AgentImage,
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		Log:                    ctrl.Log.WithName("controller.nodeobservability"),
+		EventRecorder:          mgr.GetEventRecorderFor("node-observability-operator"),
+		Namespace:              operandNamespace,
+		OperatorNamespace:      opCfg.OperatorNamespace,
+		AgentImage:             opCfg.AgentImage,
+		AgentPriorityClassName: agentPriorityClassName,
+		RequeuePeriod:          opCfg.RequeuePeriod,
 	}).SetupWithManager(mgr); err != nil {
 		return nil, fmt.Errorf("failed to create nodeobservability controller: %w", err)
 	}
@@ -134,17 +149,39 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 	}
 
 	if err := (&nodeobservabilityrun.NodeObservabilityRunReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		Log:       ctrl.Log.WithName("controller.nodeobservabilityrun"),
-		Namespace: opCfg.OperatorNamespace,
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Log:           ctrl.Log.WithName("controller.nodeobservabilityrun"),
+		EventRecorder: mgr.GetEventRecorderFor("node-observability-operator"),
+		// the agents are reached through their service in the operand namespace
+		Namespace: operandNamespace,
 		AgentName: opctrl.AgentName,
 		AuthToken: token,
-		CACert:    ca,
+		// the CA bundle is read once a run needs to contact the agents
+		CACertFile:     opCfg.CaCertFile,
+		ClientCertFile: opCfg.AgentClientCertFile,
+		ClientKeyFile:  opCfg.AgentClientKeyFile,
+		// the CPU profiles are awaited for their duration plus the margin
+		AgentRequestTimeoutMargin: opCfg.AgentRequestTimeoutMargin,
+		// the collector service account is only allowed to retrieve the profiles
+		CollectorServiceAccount: opctrl.CollectorServiceAccountName,
+		CollectorImage:          opCfg.CollectorImage,
+		// closed right away when the leader election is disabled
+		Elected: mgr.Elected(),
 	}).SetupWithManager(mgr); err != nil {
 		return nil, fmt.Errorf("failed to create nodeobservabilityrun controller: %w", err)
 	}
 
+	if err := (&schedulecontroller.ScheduleReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       ctrl.Log.WithName("controller.nodeobservabilityschedule"),
+		Clock:     clock.RealClock{},
+		Namespace: opCfg.OperatorNamespace,
+	}).SetupWithManager(mgr); err != nil {
+		return nil, fmt.Errorf("failed to create nodeobservability schedule controller: %w", err)
+	}
+
 	if opCfg.EnableWebhook {
 		if err = (&nodeobservabilityv1alpha1.NodeObservability{}).SetupWebhookWithManager(mgr); err != nil {
 			return nil, fmt.Errorf("failed to create webhook nodeobservability version v1alpha1: %w", err)
@@ -152,12 +189,19 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 		if err = (&nodeobservabilityv1alpha1.NodeObservabilityMachineConfig{}).SetupWebhookWithManager(mgr); err != nil {
 			return nil, fmt.Errorf("failed to create webhook nodeobservabilitymachineconfig version v1alpha1: %w", err)
 		}
-		if err = (&nodeobservabilityv1alpha2.NodeObservability{}).SetupWebhookWithManager(mgr); err != nil {
+		if err = (&nodeobservabilityv1alpha2.NodeObservability{}).SetupWebhookWithManager(mgr, &nodeobservabilityv1alpha2.NodeObservabilityDefaulter{
+			AgentImage: opCfg.AgentImage,
+		}, &nodeobservabilityv1alpha2.NodeObservabilityValidator{
+			Client: mgr.GetClient(),
+		}); err != nil {
 			return nil, fmt.Errorf("failed to create webhook nodeobservability version v1alpha2: %w", err)
 		}
 		if err = (&nodeobservabilityv1alpha2.NodeObservabilityMachineConfig{}).SetupWebhookWithManager(mgr); err != nil {
 			return nil, fmt.Errorf("failed to create webhook nodeobservabilitymachineconfig version v1alpha2: %w", err)
 		}
+		if err = (&nodeobservabilityv1alpha2.NodeObservabilityRun{}).SetupWebhookWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("failed to create webhook nodeobservabilityrun version v1alpha2: %w", err)
+		}
 	}
 	//+kubebuilder:scaffold:builder
 
@@ -170,18 +214,3 @@ func New(cliCfg *rest.Config, opCfg *operatorconfig.Config) (*Operator, error) {
 func (o *Operator) Start(ctx context.Context) error {
 	return o.manager.Start(ctx)
 }
-func readCACert(caCertFile string) (*x509.CertPool, error) {
-	content, err := os.ReadFile(caCertFile)
-	if err != nil {
-		return nil, err
-	}
-	if len(content) <= 0 {
-		return nil, fmt.Errorf("%s is empty", caCertFile)
-	}
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(content) {
-		return nil, fmt.Errorf("unable to add certificates into caCertPool: %w", err)
-
-	}
-	return caCertPool, nil
-}